@@ -172,7 +172,7 @@ func runInteractiveAgent(a *agent.Agent) {
 		}
 
 		fmt.Println()
-		fmt.Printf(green("Agent: ") + result.Output)
+		fmt.Print(green("Agent: ") + result.Output)
 		fmt.Println()
 		fmt.Println()
 	}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/eval"
+	"github.com/nuulab/goflow/pkg/tools"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(evalCmd)
+
+	evalCmd.AddCommand(evalRunCmd)
+
+	evalRunCmd.Flags().String("gate", "", "gate file with thresholds (exit nonzero on failure)")
+	evalRunCmd.Flags().IntP("max-iterations", "m", 10, "maximum iterations per case")
+	evalRunCmd.Flags().String("model", "", "LLM model to use")
+	evalRunCmd.Flags().String("provider", "", "LLM provider (openai, anthropic, gemini)")
+}
+
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Evaluate agents against test suites",
+}
+
+var evalRunCmd = &cobra.Command{
+	Use:   "run <suite.yaml>",
+	Short: "Run an evaluation suite",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		gatePath, _ := cmd.Flags().GetString("gate")
+		maxIter, _ := cmd.Flags().GetInt("max-iterations")
+		model, _ := cmd.Flags().GetString("model")
+		provider, _ := cmd.Flags().GetString("provider")
+
+		suite, err := eval.LoadSuite(args[0])
+		if err != nil {
+			fail(err.Error())
+			os.Exit(1)
+		}
+
+		llm, err := createLLM(provider, model)
+		if err != nil {
+			fail(err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Println(bold("🧪 Running Evaluation"))
+		fmt.Printf("   Suite: %s (%d cases)\n", cyan(suite.Name), len(suite.Cases))
+		fmt.Println()
+
+		report, err := eval.Run(context.Background(), suite, func() *agent.Agent {
+			return agent.New(llm, tools.BuiltinTools(), agent.WithMaxIterations(maxIter))
+		})
+		if err != nil {
+			fail(fmt.Sprintf("Evaluation failed: %v", err))
+			os.Exit(1)
+		}
+
+		printEvalReport(report)
+
+		if gatePath == "" {
+			return
+		}
+
+		gate, err := eval.LoadGate(gatePath)
+		if err != nil {
+			fail(err.Error())
+			os.Exit(1)
+		}
+
+		result := gate.Evaluate(report)
+		fmt.Println()
+		if !result.Passed {
+			fail("Gate failed:")
+			for _, v := range result.Violations {
+				fmt.Printf("  - %s\n", v)
+			}
+			os.Exit(1)
+		}
+		success("Gate passed")
+	},
+}
+
+func printEvalReport(report *eval.Report) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CASE\tRESULT\tITERATIONS\tTOOL ERRORS")
+	fmt.Fprintln(w, "----\t------\t----------\t-----------")
+	for _, c := range report.Cases {
+		result := green("pass")
+		if !c.Passed {
+			result = red("fail")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", c.Name, result, c.Iterations, c.ToolErrors)
+	}
+	w.Flush()
+
+	fmt.Println()
+	fmt.Printf("Accuracy:        %.1f%% (%d/%d)\n", report.Accuracy*100, report.Passed, report.Total)
+	fmt.Printf("Mean iterations: %.2f\n", report.MeanIterations)
+	fmt.Printf("Tool error rate: %.1f%%\n", report.ToolErrorRate*100)
+	fmt.Printf("Cost per case:   %.4f\n", report.CostPerCase)
+}
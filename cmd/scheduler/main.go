@@ -10,6 +10,8 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/redis/go-redis/v9"
+
 	"github.com/nuulab/goflow/pkg/queue"
 	"github.com/nuulab/goflow/pkg/workflow"
 )
@@ -41,8 +43,10 @@ func main() {
 	// Create workflow engine
 	engine := workflow.NewEngine(nil)
 
-	// Create cron scheduler
+	// Create cron scheduler. Schedules persist in Redis, and replicas
+	// sharing it fire each schedule once.
 	cron := workflow.NewCron(engine)
+	cron.SetStore(workflow.NewRedisCronStore(redis.NewClient(&redis.Options{Addr: *redisAddr}), "goflow:cron"))
 
 	// Register scheduled jobs from environment or config
 	// In production, load from config file or database
@@ -62,7 +66,9 @@ func main() {
 
 	// Start scheduler
 	log.Println("🚀 Starting scheduler...")
-	cron.Start(ctx)
+	if err := cron.Start(ctx); err != nil {
+		log.Fatalf("Failed to start scheduler: %v", err)
+	}
 
 	// Keep running
 	<-ctx.Done()
@@ -8,12 +8,26 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nuulab/goflow/pkg/agent"
 	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/blueprint"
 	"github.com/nuulab/goflow/pkg/cache"
 	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/egress"
+	"github.com/nuulab/goflow/pkg/integrations/browserbase"
+	"github.com/nuulab/goflow/pkg/integrations/e2b"
+	"github.com/nuulab/goflow/pkg/integrations/sessions"
+	"github.com/nuulab/goflow/pkg/metrics"
+	"github.com/nuulab/goflow/pkg/metrics/alerts"
+	"github.com/nuulab/goflow/pkg/notify"
 	"github.com/nuulab/goflow/pkg/tools"
+	"github.com/nuulab/goflow/pkg/webhook"
 	"github.com/nuulab/goflow/pkg/workflow"
 )
 
@@ -22,6 +36,13 @@ func main() {
 	port := flag.Int("port", 8080, "Server port")
 	redisAddr := flag.String("redis", "", "Redis/DragonflyDB address (optional)")
 	verbose := flag.Bool("verbose", false, "Enable verbose logging")
+	requireLLM := flag.Bool("require-llm", false, "Exit if the LLM provider health check fails")
+	blueprintDir := flag.String("blueprints", "", "Directory of additional blueprints (optional)")
+	postgresDSN := flag.String("postgres", "", "Postgres DSN for workflow persistence (optional, overrides Redis)")
+	egressAllow := flag.String("egress-allow", "", "Comma-separated host patterns agents may reach (e.g. api.github.com,*.example.com)")
+	egressDeny := flag.Bool("egress-deny-by-default", false, "Block outbound requests to hosts not in -egress-allow")
+	alertRules := flag.String("alert-rules", "", "JSON file of alert rules evaluated against server metrics (optional)")
+	sessionQuota := flag.Int("session-quota", 0, "Concurrent E2B and Browserbase sessions per tenant (0 = unlimited)")
 	flag.Parse()
 
 	// Environment variable overrides
@@ -31,6 +52,24 @@ func main() {
 	if envRedis := os.Getenv("GOFLOW_REDIS"); envRedis != "" {
 		*redisAddr = envRedis
 	}
+	if envBlueprints := os.Getenv("GOFLOW_BLUEPRINTS"); envBlueprints != "" {
+		*blueprintDir = envBlueprints
+	}
+	if envPostgres := os.Getenv("GOFLOW_POSTGRES"); envPostgres != "" {
+		*postgresDSN = envPostgres
+	}
+	if envAllow := os.Getenv("GOFLOW_EGRESS_ALLOW"); envAllow != "" {
+		*egressAllow = envAllow
+	}
+	if envDeny := os.Getenv("GOFLOW_EGRESS_DENY_BY_DEFAULT"); envDeny == "true" || envDeny == "1" {
+		*egressDeny = true
+	}
+	if envAlerts := os.Getenv("GOFLOW_ALERT_RULES"); envAlerts != "" {
+		*alertRules = envAlerts
+	}
+	if envQuota := os.Getenv("GOFLOW_SESSION_QUOTA"); envQuota != "" {
+		fmt.Sscanf(envQuota, "%d", sessionQuota)
+	}
 
 	// Banner
 	printBanner()
@@ -38,6 +77,18 @@ func main() {
 	// Initialize LLM (users should implement their own)
 	llm := &StubLLM{} // Replace with real LLM
 
+	// Restrict outbound HTTP from tools and integrations
+	if *egressDeny {
+		var allow []string
+		for _, host := range strings.Split(*egressAllow, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				allow = append(allow, host)
+			}
+		}
+		egress.SetPolicy(egress.Policy{Allow: allow, DenyByDefault: true})
+		log.Printf("🔒 Egress allowlist enabled (%d host patterns)", len(allow))
+	}
+
 	// Initialize tool registry with all built-in tools
 	registry := tools.BuiltinTools()
 	registry.Register(tools.CalculatorTool())
@@ -65,25 +116,143 @@ func main() {
 	}
 
 	// Initialize workflow engine
-	var persistence *workflow.Persistence
-	if cacheInstance != nil {
+	var persistence workflow.Persistence
+	if *postgresDSN != "" {
+		pool, err := pgxpool.New(context.Background(), *postgresDSN)
+		if err != nil {
+			log.Fatalf("❌ Invalid Postgres DSN: %v", err)
+		}
+		pg, err := workflow.NewPostgresPersistence(context.Background(), pool)
+		if err != nil {
+			log.Fatalf("❌ Postgres persistence failed: %v", err)
+		}
+		persistence = pg
+		log.Printf("✅ Workflow state persisted to Postgres")
+	} else if cacheInstance != nil {
 		if dc, ok := cacheInstance.(*cache.DragonflyCache); ok {
 			persistence = workflow.NewPersistence(dc.Client())
 		}
 	}
 	workflowEngine := workflow.NewEngine(persistence)
+	workflowEngine.SetTools(registry)
+	workflowEngine.StartEscalationMonitor(context.Background(), time.Minute)
+	workflowEngine.StartStuckMonitor(context.Background(), time.Minute)
 	log.Printf("🔄 Workflow engine initialized")
 
+	// Track cloud sandboxes and browser sessions by the run that created
+	// them, so the janitor reaps those left behind by finished or crashed
+	// runs
+	tracker := sessions.NewTracker(sessions.WithQuota(*sessionQuota))
+	workflowEngine.Subscribe(func(ctx context.Context, ev workflow.RunEvent) {
+		if ev.Final() {
+			tracker.FinishRun(ev.RunID)
+		}
+	})
+	registry.Use(attributeSessions)
+	if key := os.Getenv("E2B_API_KEY"); key != "" {
+		sandbox := e2b.NewTool(key, "").WithTracker(tracker)
+		registry.Register(&tools.Tool{
+			Name:        sandbox.Name(),
+			Description: sandbox.Description(),
+			Parameters: tools.Schema{
+				Type: "object",
+				Properties: map[string]tools.Property{
+					"code":     {Type: "string", Description: "Code to run"},
+					"language": {Type: "string", Description: "python, javascript or bash"},
+				},
+				Required: []string{"code"},
+			},
+			Execute: sandbox.Execute,
+		})
+		log.Printf("🧪 E2B sandbox tool enabled")
+	}
+	if key, project := os.Getenv("BROWSERBASE_API_KEY"), os.Getenv("BROWSERBASE_PROJECT_ID"); key != "" && project != "" {
+		browsers := browserbase.New(key, project).WithTracker(tracker)
+		browserbase.NewSessionPool(browsers, 0, nil).Toolkit().RegisterTo(registry)
+		log.Printf("🌐 Browserbase tools enabled")
+	}
+	tracker.StartJanitor(context.Background(), time.Minute)
+
 	// Initialize cron scheduler
 	cron := workflow.NewCron(workflowEngine)
 	cron.Start(context.Background())
 	log.Printf("⏰ Cron scheduler started")
 
+	// Initialize webhooks (configs persist in the cache when available).
+	// Deliveries are logged as receipts, and duplicates acknowledged
+	// without triggering again, in Redis when available so replicas share
+	// them
+	webhooks := webhook.NewWebhookHandler(nil, workflowEngine)
+	if cacheInstance != nil {
+		if err := webhooks.SetStore(context.Background(), cacheInstance); err != nil {
+			log.Printf("⚠️  Failed to load webhooks: %v", err)
+		}
+	}
+	if dc, ok := cacheInstance.(*cache.DragonflyCache); ok {
+		webhooks.SetReceiptStore(webhook.NewRedisReceiptStore(dc.Client(), 0))
+	} else {
+		webhooks.SetReceiptStore(webhook.NewMemoryReceiptStore(0))
+	}
+
+	// Load blueprints (built-in plus an optional directory)
+	blueprints := blueprint.Builtin()
+	if *blueprintDir != "" {
+		if err := blueprints.LoadDir(*blueprintDir); err != nil {
+			log.Printf("⚠️  Failed to load blueprints: %v", err)
+		}
+	}
+	log.Printf("📐 Loaded %d blueprints", len(blueprints.List()))
+
+	// Alert rules (managed at /api/alerts, delivered over the WebSocket hub
+	// and to the server log). The queue, worker and engine count jobs sent
+	// to the DLQ and workflow outcomes in metrics.DefaultMetrics.
+	alertLog := notify.NotifierFunc(func(ctx context.Context, msg notify.Message) error {
+		log.Printf("🚨 [%s] %s: %s", msg.Severity, msg.Title, msg.Body)
+		return nil
+	})
+	alertManager := alerts.NewManager(metrics.DefaultMetrics, alertLog)
+	if *alertRules != "" {
+		rules, err := alerts.LoadRules(*alertRules)
+		if err != nil {
+			log.Fatalf("❌ Failed to load alert rules: %v", err)
+		}
+		for _, rule := range rules {
+			if err := alertManager.Add(rule); err != nil {
+				log.Fatalf("❌ Alert rule %q: %v", rule.Name, err)
+			}
+		}
+		log.Printf("🚨 Loaded %d alert rules", len(rules))
+	}
+	alertManager.Start(context.Background(), 15*time.Second)
+
+	// Run events replay to reconnecting clients; keep them in the cache when
+	// available so they survive restarts
+	var runEvents api.RunEventStore
+	if dc, ok := cacheInstance.(*cache.DragonflyCache); ok {
+		runEvents = api.NewRedisRunEventStore(dc.Client(), 0)
+	}
+
+	// Agents continue their conversations after a restart when the cache
+	// is available
+	var agentStore agent.Store
+	if cacheInstance != nil {
+		agentStore = agent.NewCacheStore(cacheInstance, 0)
+	}
+
 	// Create API server
 	server := api.NewServer(api.Config{
-		Port:     *port,
-		LLM:      llm,
-		Registry: registry,
+		Port:       *port,
+		LLM:        llm,
+		Registry:   registry,
+		Engine:     workflowEngine,
+		Webhooks:   webhooks,
+		Blueprints: blueprints,
+		Alerts:     alertManager,
+		Cron:       cron,
+		RunEvents:  runEvents,
+		AgentStore: agentStore,
+		Sessions:   tracker,
+		Secrets:    os.LookupEnv,
 		Settings: &api.Settings{
 			MaxIterations:   10,
 			VerboseLogging:  *verbose,
@@ -91,6 +260,22 @@ func main() {
 		},
 	})
 
+	// Check LLM provider health (warn only unless --require-llm)
+	health := server.CheckLLMHealth(context.Background())
+	for _, p := range health.Providers {
+		switch p.Status {
+		case core.HealthOK:
+			log.Printf("✅ LLM %s (%s) is healthy", p.Provider, p.Model)
+		case core.HealthError:
+			log.Printf("⚠️  LLM %s (%s) health check failed: %s", p.Provider, p.Model, p.Error)
+		default:
+			log.Printf("ℹ️  LLM %s does not support health checks", p.Provider)
+		}
+	}
+	if *requireLLM && health.Status == core.HealthError {
+		log.Fatalf("LLM health check failed (--require-llm)")
+	}
+
 	// Graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -116,6 +301,20 @@ func main() {
 	<-ctx.Done()
 }
 
+// attributeSessions attributes the sessions a tool creates during a
+// workflow run to that run.
+func attributeSessions(next tools.ToolFunc) tools.ToolFunc {
+	return func(ctx context.Context, name, jsonInput string) (string, error) {
+		if runID, ok := workflow.RunIDFromContext(ctx); ok {
+			if owner := sessions.OwnerFromContext(ctx); owner.RunID == "" {
+				owner.RunID = runID
+				ctx = sessions.WithOwner(ctx, owner)
+			}
+		}
+		return next(ctx, name, jsonInput)
+	}
+}
+
 func printBanner() {
 	fmt.Println(`
    ____       _____ _               
@@ -12,6 +12,8 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
+
 	"github.com/nuulab/goflow/pkg/core"
 	"github.com/nuulab/goflow/pkg/queue"
 	"github.com/nuulab/goflow/pkg/tools"
@@ -21,26 +23,31 @@ func main() {
 	// Flags
 	concurrency := flag.Int("concurrency", 5, "Number of concurrent workers")
 	redisAddr := flag.String("redis", "localhost:6379", "Redis/DragonflyDB address")
+	postgresDSN := flag.String("postgres", "", "Postgres DSN (optional, uses a Postgres queue instead of Redis)")
 	flag.Parse()
 
 	// Environment overrides
 	if envRedis := os.Getenv("GOFLOW_REDIS"); envRedis != "" {
 		*redisAddr = envRedis
 	}
+	if envPostgres := os.Getenv("GOFLOW_POSTGRES"); envPostgres != "" {
+		*postgresDSN = envPostgres
+	}
 	if envConc := os.Getenv("GOFLOW_WORKER_CONCURRENCY"); envConc != "" {
 		fmt.Sscanf(envConc, "%d", concurrency)
 	}
 
 	// Banner
 	fmt.Println("🔧 GoFlow Worker")
-	fmt.Printf("   Redis: %s\n", *redisAddr)
+	if *postgresDSN != "" {
+		fmt.Println("   Queue: Postgres")
+	} else {
+		fmt.Printf("   Redis: %s\n", *redisAddr)
+	}
 	fmt.Printf("   Concurrency: %d\n", *concurrency)
 
 	// Connect to queue
-	q, err := queue.NewDragonflyQueue(queue.Config{
-		Address:   *redisAddr,
-		QueueName: "goflow:jobs",
-	})
+	q, err := connectQueue(*redisAddr, *postgresDSN)
 	if err != nil {
 		log.Fatalf("Failed to connect to queue: %v", err)
 	}
@@ -86,6 +93,24 @@ func main() {
 	log.Println("👋 Worker stopped")
 }
 
+// connectQueue opens the Postgres queue when a DSN is given and the
+// DragonflyDB queue otherwise.
+func connectQueue(redisAddr, postgresDSN string) (queue.Queue, error) {
+	if postgresDSN == "" {
+		return queue.NewDragonflyQueue(queue.Config{
+			Address:   redisAddr,
+			QueueName: "goflow:jobs",
+		})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pool, err := pgxpool.New(ctx, postgresDSN)
+	if err != nil {
+		return nil, err
+	}
+	return queue.NewPostgresQueue(ctx, pool, "goflow:jobs")
+}
+
 // ============ Job Handlers ============
 
 // AgentTaskPayload is the payload for agent tasks
@@ -7,9 +7,14 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"strconv"
 	"time"
 )
 
+// DefaultMaxRetryAfter caps the server's Retry-After when
+// Config.MaxRetryAfter is zero.
+const DefaultMaxRetryAfter = time.Minute
+
 // Config holds configuration for the resilient HTTP client.
 type Config struct {
 	// MaxRetries is the maximum number of retry attempts.
@@ -18,6 +23,9 @@ type Config struct {
 	BaseDelay time.Duration
 	// MaxDelay is the maximum delay between retries.
 	MaxDelay time.Duration
+	// MaxRetryAfter caps the delay a server's Retry-After can ask for.
+	// Zero means DefaultMaxRetryAfter.
+	MaxRetryAfter time.Duration
 	// Timeout is the request timeout.
 	Timeout time.Duration
 	// RetryableStatusCodes defines which HTTP status codes should trigger a retry.
@@ -30,8 +38,9 @@ func DefaultConfig() Config {
 		MaxRetries:           3,
 		BaseDelay:            500 * time.Millisecond,
 		MaxDelay:             30 * time.Second,
+		MaxRetryAfter:        DefaultMaxRetryAfter,
 		Timeout:              60 * time.Second,
-		RetryableStatusCodes: []int{429, 500, 502, 503, 504},
+		RetryableStatusCodes: []int{408, 429, 500, 502, 503, 504, 529},
 	}
 }
 
@@ -59,49 +68,55 @@ func NewWithHTTPClient(cfg Config, client *http.Client) *Client {
 	}
 }
 
-// Do executes an HTTP request with retries.
+// Do executes an HTTP request with retries. Requests are retried on
+// connection errors and retryable status codes, waiting for the longer of
+// the backoff and the server's Retry-After, capped at MaxRetryAfter. A
+// retry that could not finish before the ctx deadline is not attempted.
+// When retries run out on a retryable status, the last response is
+// returned with its body unread so callers can report the server's error.
 func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
 	var lastErr error
-	var lastResp *http.Response
-
-	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
-		// Check context before making request
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
 
-		// Clone the request for retry (body may have been consumed)
+	for attempt := 0; ; attempt++ {
 		reqCopy := req.Clone(ctx)
+		if req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			reqCopy.Body = body
+		}
 
 		resp, err := c.httpClient.Do(reqCopy)
-		if err != nil {
+		if ctx.Err() != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+
+		var retryAfter time.Duration
+		switch {
+		case err != nil:
 			lastErr = err
-			c.waitForRetry(ctx, attempt)
-			continue
+		case c.isRetryable(resp.StatusCode):
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		default:
+			return resp, nil
 		}
 
-		// Check if status code is retryable
-		if c.isRetryable(resp.StatusCode) {
-			// Close the response body to allow connection reuse
+		if attempt >= c.config.MaxRetries || !c.waitForRetry(ctx, attempt, retryAfter) {
+			if err != nil {
+				return nil, fmt.Errorf("request failed after %d attempts: %w", attempt+1, lastErr)
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			// Drain the body to allow connection reuse.
 			io.Copy(io.Discard, resp.Body)
 			resp.Body.Close()
-			
-			lastResp = resp
-			lastErr = fmt.Errorf("received retryable status code: %d", resp.StatusCode)
-			c.waitForRetry(ctx, attempt)
-			continue
 		}
-
-		return resp, nil
 	}
-
-	if lastErr != nil {
-		return lastResp, fmt.Errorf("request failed after %d attempts: %w", c.config.MaxRetries+1, lastErr)
-	}
-
-	return lastResp, nil
 }
 
 // isRetryable checks if a status code should trigger a retry.
@@ -114,15 +129,27 @@ func (c *Client) isRetryable(statusCode int) bool {
 	return false
 }
 
-// waitForRetry waits for the appropriate backoff duration.
-func (c *Client) waitForRetry(ctx context.Context, attempt int) {
-	delay := c.calculateBackoff(attempt)
-	
+// waitForRetry waits for the backoff of attempt, or retryAfter if longer,
+// with retryAfter capped at MaxRetryAfter. It returns false without
+// waiting when the retry could not start before the ctx deadline, and
+// false if ctx is done while waiting.
+func (c *Client) waitForRetry(ctx context.Context, attempt int, retryAfter time.Duration) bool {
+	limit := c.config.MaxRetryAfter
+	if limit <= 0 {
+		limit = DefaultMaxRetryAfter
+	}
+	delay := max(c.calculateBackoff(attempt), min(retryAfter, limit))
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
 	select {
 	case <-ctx.Done():
-		return
-	case <-time.After(delay):
-		return
+		return false
+	case <-timer.C:
+		return true
 	}
 }
 
@@ -135,6 +162,21 @@ func (c *Client) calculateBackoff(attempt int) time.Duration {
 	return delay
 }
 
+// parseRetryAfter reads a Retry-After header given in seconds or as an
+// HTTP date. It returns zero when the header is absent or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
 // Get performs a GET request with retries.
 func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
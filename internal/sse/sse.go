@@ -0,0 +1,99 @@
+// Package sse provides a reader for server-sent event streams.
+package sse
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Done is the data of the sentinel event that ends OpenAI-style streams.
+const Done = "[DONE]"
+
+// Event is a single server-sent event.
+type Event struct {
+	// Event is the event type, empty for the default "message" type.
+	Event string
+	// Data is the event payload. Multiple data lines are joined with "\n".
+	Data string
+	ID   string
+}
+
+// Reader reads events from an SSE stream. Lines may end in "\n", "\r\n"
+// or "\r", and may span any number of underlying reads.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader creates a reader for the stream r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next event. At the end of the stream it returns io.EOF;
+// a final event that is not followed by a blank line is still returned
+// first. Events without data are skipped.
+func (r *Reader) Next() (Event, error) {
+	var ev Event
+	var data []string
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) && data != nil {
+				ev.Data = strings.Join(data, "\n")
+				return ev, nil
+			}
+			return Event{}, err
+		}
+
+		if line == "" {
+			if data != nil {
+				ev.Data = strings.Join(data, "\n")
+				return ev, nil
+			}
+			ev = Event{}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // comment
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = append(data, value)
+		case "event":
+			ev.Event = value
+		case "id":
+			ev.ID = value
+		}
+	}
+}
+
+// readLine returns the next line without its terminator. A partial last
+// line is returned with a nil error; io.EOF is only returned once no data
+// is left.
+func (r *Reader) readLine() (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) && sb.Len() > 0 {
+				return sb.String(), nil
+			}
+			return "", err
+		}
+		switch b {
+		case '\n':
+			return sb.String(), nil
+		case '\r':
+			if next, err := r.r.Peek(1); err == nil && next[0] == '\n' {
+				r.r.ReadByte()
+			}
+			return sb.String(), nil
+		}
+		sb.WriteByte(b)
+	}
+}
@@ -0,0 +1,101 @@
+package sse_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/nuulab/goflow/internal/sse"
+)
+
+// chunkReader returns the stream in the given pieces, one per Read.
+type chunkReader struct {
+	chunks []string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks[0] = r.chunks[0][n:]
+	if r.chunks[0] == "" {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+func readAll(t *testing.T, r io.Reader) []sse.Event {
+	t.Helper()
+	reader := sse.NewReader(r)
+	var events []sse.Event
+	for {
+		ev, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+}
+
+func TestReader_Events(t *testing.T) {
+	stream := ": keep-alive\r\n" +
+		"event: message_start\r\n" +
+		"id: 7\r\n" +
+		"data: {\"a\":1}\r\n" +
+		"\r\n" +
+		"data: first\n" +
+		"data:second\n" +
+		"\n" +
+		"event: ping\n\n" +
+		"data: " + sse.Done + "\r\r" +
+		"data: trailing"
+
+	events := readAll(t, strings.NewReader(stream))
+	want := []sse.Event{
+		{Event: "message_start", ID: "7", Data: `{"a":1}`},
+		{Data: "first\nsecond"},
+		{Data: sse.Done},
+		{Data: "trailing"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %+v", len(want), events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("Event %d: expected %+v, got %+v", i, want[i], events[i])
+		}
+	}
+}
+
+func TestReader_ChunkBoundaries(t *testing.T) {
+	// "é" is 0xC3 0xA9; split it, the data prefix and the CRLF across reads.
+	r := &chunkReader{chunks: []string{"da", "ta: caf\xc3", "\xa9\r", "\n\r", "\ndata: ok\n\n"}}
+
+	events := readAll(t, r)
+	if len(events) != 2 || events[0].Data != "café" || events[1].Data != "ok" {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+
+	events = readAll(t, iotest.OneByteReader(strings.NewReader("data: 日本\r\n\r\n")))
+	if len(events) != 1 || events[0].Data != "日本" {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+}
+
+func TestReader_Error(t *testing.T) {
+	boom := errors.New("connection reset")
+	reader := sse.NewReader(io.MultiReader(strings.NewReader("data: one\n\ndata: tw"), iotest.ErrReader(boom)))
+
+	if ev, err := reader.Next(); err != nil || ev.Data != "one" {
+		t.Fatalf("Expected first event, got %+v, %v", ev, err)
+	}
+	if _, err := reader.Next(); !errors.Is(err, boom) {
+		t.Fatalf("Expected read error, got %v", err)
+	}
+}
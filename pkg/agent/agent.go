@@ -5,10 +5,13 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/egress"
 	"github.com/nuulab/goflow/pkg/tools"
 )
 
@@ -22,6 +25,35 @@ type Config struct {
 	Verbose bool
 	// StopOnError halts execution on first tool error. Default: false.
 	StopOnError bool
+	// DisableToolCalling keeps the JSON prompt mode even when the LLM
+	// supports native tool calling. Default: false.
+	DisableToolCalling bool
+	// AllowedTools limits the agent to the named tools. Empty allows all.
+	AllowedTools []string
+	// DeniedTools are hidden from the agent even when allowed.
+	DeniedTools []string
+	// ToolDocsThreshold is the number of visible tools above which the
+	// describe_tool and list_tools meta-tools are offered. Zero uses
+	// DefaultToolDocsThreshold; a negative value never offers them.
+	ToolDocsThreshold int
+	// Timeout bounds each run. Zero means no limit beyond the context.
+	Timeout time.Duration
+	// StepTimeout bounds the LLM call of each step. Zero means no limit.
+	StepTimeout time.Duration
+	// ToolTimeout bounds each tool execution. Zero means no limit.
+	ToolTimeout time.Duration
+	// ToolConcurrency limits how many tool calls of a step run at once.
+	// Zero runs them all at once.
+	ToolConcurrency int
+	// CritiquePrompt replaces DefaultCritiquePrompt for WithReflection.
+	CritiquePrompt string
+	// MaxObservationSize caps observations, in bytes. Longer outputs are
+	// truncated and kept for the read_artifact tool. Zero means no limit.
+	MaxObservationSize int
+	// History is the number of earlier messages from memory replayed at
+	// the start of each run. Zero replays none. A Retriever memory, such
+	// as VectorMemory, replays what it recalls for the task instead.
+	History int
 }
 
 // DefaultConfig returns sensible defaults for agent configuration.
@@ -39,12 +71,21 @@ const defaultSystemPrompt = `You are a helpful AI assistant that can use tools t
 When you need to use a tool, respond with a JSON object in this exact format:
 {"action": "tool_name", "action_input": {"param1": "value1"}}
 
+To use several independent tools at once, respond with a JSON array of such
+objects; they run in parallel.
+
 When you have the final answer and no more tools are needed, respond with:
 {"action": "final_answer", "action_input": "Your final response here"}
 
 Think step by step about what tools you need to use and in what order.
 Always explain your reasoning before taking an action.`
 
+const criticInstruction = `
+
+When you are not confident in an answer, call the evaluate_answer tool with
+the task and your candidate answer before giving the final answer. If the
+score is low, address the issues it reports and evaluate again.`
+
 // Agent is an autonomous AI that can reason and use tools to complete tasks.
 type Agent struct {
 	llm      core.LLM
@@ -53,6 +94,16 @@ type Agent struct {
 	config   Config
 	messages []core.Message
 	hooks    Hooks
+	guard    *ContextWindowGuard
+	task     int // index of the run's task in messages
+	calls    int // tool call IDs issued in native tool message mode
+	stream   StreamHandler
+	output   *outputSpec
+	confirm  ConfirmationHandler
+	cycles   int // think/act cycles of all runs
+	critic   *reflection
+	chain    []StepMiddleware
+	stash    artifacts // full outputs of truncated observations
 }
 
 // New creates a new Agent with the given LLM and tools.
@@ -89,6 +140,14 @@ func WithMemory(mem Memory) Option {
 	}
 }
 
+// WithHistory replays up to n of the latest messages from memory at the
+// start of each run, so the agent can continue earlier conversations.
+func WithHistory(n int) Option {
+	return func(a *Agent) {
+		a.config.History = n
+	}
+}
+
 // WithMaxIterations sets the maximum number of iterations.
 func WithMaxIterations(n int) Option {
 	return func(a *Agent) {
@@ -110,6 +169,14 @@ func WithVerbose(v bool) Option {
 	}
 }
 
+// WithToolCalling enables or disables native tool calling for LLMs that
+// implement core.ToolCallingLLM. It is enabled by default.
+func WithToolCalling(enabled bool) Option {
+	return func(a *Agent) {
+		a.config.DisableToolCalling = !enabled
+	}
+}
+
 // AgentAction represents a parsed action from the LLM response.
 type AgentAction struct {
 	// Action is the tool name to execute.
@@ -132,6 +199,22 @@ type StepResult struct {
 	Error error
 	// IsFinal indicates this is the final answer.
 	IsFinal bool
+	// Trim records context windowing applied before the LLM call, if any.
+	Trim *TrimReport
+	// Trimmed is the number of messages dropped or summarized by Trim.
+	Trimmed int
+	// ToolCallID identifies the tool call when the LLM supports native tool
+	// messages. Empty when observations are sent as user messages.
+	ToolCallID string
+	// Usage is the token usage of the step's LLM call, if reported.
+	Usage core.Usage
+	// TimedOut marks a step whose LLM call or tool execution exceeded
+	// StepTimeout or ToolTimeout.
+	TimedOut bool
+	// Calls holds each tool call of a step that made several at once, in
+	// call order. Action is then the first call, and Observation and Error
+	// combine those of all calls.
+	Calls []ToolCallResult
 }
 
 // RunResult represents the final outcome of an agent run.
@@ -146,6 +229,38 @@ type RunResult struct {
 	Error error
 	// ToolCalls contains all tool invocations made during execution.
 	ToolCalls []ToolCallRecord
+	// EgressDenied lists tool requests blocked by the egress policy.
+	EgressDenied []*egress.DeniedError
+	// TimedOut holds the indices into Steps of steps that timed out.
+	TimedOut []int
+	// Preview marks results of PreviewRun. Preview runs should be kept out
+	// of analytics and accounted separately from regular runs.
+	Preview bool
+	// Value is the final answer decoded into the type given to
+	// WithOutputSchema or RunStructured. It is nil without an output schema.
+	Value any
+	// WouldContinue reports that a preview stopped before a final answer.
+	WouldContinue bool
+	// Transcript is the conversation at the end of a preview.
+	Transcript []core.Message
+	// Critiques holds the critic's reviews of final answers, in order.
+	Critiques []Critique
+	// Revisions is the number of final answers the critic sent back.
+	Revisions int
+	// TotalPromptTokens and TotalCompletionTokens sum the token usage of
+	// all steps.
+	TotalPromptTokens     int
+	TotalCompletionTokens int
+}
+
+// addStep records a step and accumulates its token usage.
+func (r *RunResult) addStep(step StepResult) {
+	if step.TimedOut {
+		r.TimedOut = append(r.TimedOut, len(r.Steps))
+	}
+	r.Steps = append(r.Steps, step)
+	r.TotalPromptTokens += step.Usage.PromptTokens
+	r.TotalCompletionTokens += step.Usage.CompletionTokens
 }
 
 // ToolCallRecord represents a tool invocation during agent execution.
@@ -155,13 +270,55 @@ type ToolCallRecord struct {
 	Output string
 }
 
+// ErrMaxIterations is returned when a run ends without a final answer.
+var ErrMaxIterations = errors.New("agent reached max iterations")
+
 // Run executes the agent on a task until completion or max iterations.
 func (a *Agent) Run(ctx context.Context, task string) (*RunResult, error) {
-	// Initialize conversation
-	a.messages = []core.Message{
-		{Role: core.RoleSystem, Content: a.buildSystemPrompt()},
-		{Role: core.RoleUser, Content: task},
+	return a.run(ctx, task, a.config.MaxIterations)
+}
+
+// PreviewRun executes at most n iterations for quick validation. Stopping
+// at the limit is not an error: the result has WouldContinue set and carries
+// the transcript so far. n is capped at the configured MaxIterations.
+func (a *Agent) PreviewRun(ctx context.Context, task string, n int) (*RunResult, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("agent: preview iterations must be positive, got %d", n)
+	}
+	n = min(n, a.config.MaxIterations)
+
+	result, err := a.run(ctx, task, n)
+	result.Preview = true
+	result.Transcript = a.GetMessages()
+	if errors.Is(err, ErrMaxIterations) {
+		result.WouldContinue = true
+		result.Error = nil
+		return result, nil
+	}
+	return result, err
+}
+
+// run executes task with the lifecycle hooks around the step loop.
+func (a *Agent) run(ctx context.Context, task string, limit int) (*RunResult, error) {
+	if a.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.config.Timeout)
+		defer cancel()
 	}
+	a.hookStart(ctx, task)
+	result, err := a.runSteps(ctx, task, limit)
+	a.cycles += result.Iterations
+	a.hookComplete(ctx, result)
+	return result, err
+}
+
+func (a *Agent) runSteps(ctx context.Context, task string, limit int) (*RunResult, error) {
+	// Initialize conversation, continuing earlier runs from memory if enabled
+	output := a.outputSpec(ctx)
+	a.messages = []core.Message{{Role: core.RoleSystem, Content: a.buildSystemPrompt(output)}}
+	a.messages = append(a.messages, a.earlier(ctx, task)...)
+	a.messages = append(a.messages, core.Message{Role: core.RoleUser, Content: task})
+	a.task = len(a.messages) - 1
 
 	// Store in memory
 	a.memory.Add(core.Message{Role: core.RoleUser, Content: task})
@@ -169,56 +326,127 @@ func (a *Agent) Run(ctx context.Context, task string) (*RunResult, error) {
 	result := &RunResult{
 		Steps: make([]StepResult, 0),
 	}
+	reprompted := false
 
-	for i := 0; i < a.config.MaxIterations; i++ {
+	for i := 0; i < limit; i++ {
 		select {
 		case <-ctx.Done():
 			result.Error = ctx.Err()
+			a.hookError(ctx, result.Error)
 			return result, ctx.Err()
 		default:
 		}
 
 		result.Iterations = i + 1
+		a.hookBeforeStep(ctx, result.Iterations)
 
 		// Execute one step
-		stepResult, err := a.Step(ctx)
+		stepResult, err := a.step(ctx)
 		if err != nil {
 			result.Error = err
-			if a.config.StopOnError {
+			a.hookError(ctx, err)
+			if ctx.Err() != nil {
+				result.addStep(stepResult)
+				return result, err
+			}
+			if a.config.StopOnError || errors.Is(err, ErrContextOverflow) {
 				return result, err
 			}
 		}
 
-		result.Steps = append(result.Steps, stepResult)
+		result.addStep(stepResult)
+		a.hookAfterStep(ctx, stepResult)
+
+		var denied *egress.DeniedError
+		if errors.As(stepResult.Error, &denied) {
+			result.EgressDenied = append(result.EgressDenied, denied)
+		}
 
 		// Check if we have a final answer
 		if stepResult.IsFinal {
 			result.Output = stepResult.Observation
-			return result, nil
+			if output != nil {
+				done, err := a.checkOutput(output, result, &reprompted)
+				if err != nil {
+					result.Error = err
+					a.hookError(ctx, err)
+					return result, err
+				}
+				if !done {
+					limit++ // the correction gets its own iteration
+					continue
+				}
+			}
+			// Revisions come out of the iteration budget.
+			if a.critic == nil || result.Revisions >= a.critic.maxRevisions || i+1 >= limit {
+				return result, nil
+			}
+			if a.reflect(ctx, task, result) {
+				return result, nil
+			}
+			continue
 		}
 
 		// Add observation to conversation for next iteration
 		if stepResult.Observation != "" {
-			obsMsg := core.Message{
-				Role:    core.RoleUser,
-				Content: fmt.Sprintf("Observation: %s", stepResult.Observation),
+			for _, obsMsg := range observationMessages(stepResult) {
+				a.messages = append(a.messages, obsMsg)
+				a.memory.Add(obsMsg)
 			}
-			a.messages = append(a.messages, obsMsg)
-			a.memory.Add(obsMsg)
 		}
 	}
 
-	result.Error = fmt.Errorf("agent reached max iterations (%d) without final answer", a.config.MaxIterations)
+	result.Error = fmt.Errorf("%w (%d) without final answer", ErrMaxIterations, limit)
+	a.hookError(ctx, result.Error)
 	return result, result.Error
 }
 
+// callOptions returns the options for the agent's reasoning calls in ctx:
+// they are tagged for the smart model tier and their token usage is added
+// to usage.
+func (a *Agent) callOptions(ctx context.Context, usage *core.Usage) []core.Option {
+	opts := []core.Option{
+		core.WithModelTier(core.TierSmart),
+		core.WithUsageCallback(func(u core.Usage) {
+			usage.Provider, usage.Model = u.Provider, u.Model
+			usage.Add(u)
+		}),
+	}
+	if output := a.outputSpec(ctx); output != nil && output.schema.Type == "object" {
+		opts = append(opts, core.WithJSONResponse())
+	}
+	return opts
+}
+
 // Step executes a single think/act cycle.
 func (a *Agent) Step(ctx context.Context) (StepResult, error) {
 	var result StepResult
 
+	// Fit the conversation into the model's context window
+	if a.guard != nil {
+		messages, task, trim, err := a.guard.fit(ctx, a.messages, a.task)
+		if err != nil {
+			result.Error = err
+			return result, err
+		}
+		a.messages, a.task = messages, task
+		result.Trim = trim
+		result.Trimmed = trim.Trimmed()
+	}
+
+	if llm, ok := a.toolCaller(); ok {
+		return a.stepWithTools(ctx, llm, result)
+	}
+
 	// Get LLM response
-	response, err := a.llm.GenerateChat(ctx, a.messages)
+	a.hookLLMRequest(ctx)
+	llmCtx, cancel, timedOut := withTimeout(ctx, a.config.StepTimeout, "")
+	response, err := a.generate(llmCtx, a.callOptions(ctx, &result.Usage)...)
+	cancel()
+	err = timedOut(err)
+	a.hookLLMResponse(ctx, response, result.Usage, err)
 	if err != nil {
+		result.TimedOut = errors.Is(err, ErrStepTimeout)
 		result.Error = fmt.Errorf("LLM generation failed: %w", err)
 		return result, result.Error
 	}
@@ -231,14 +459,19 @@ func (a *Agent) Step(ctx context.Context) (StepResult, error) {
 	a.memory.Add(core.Message{Role: core.RoleAssistant, Content: response})
 
 	// Parse the action from response
-	action, err := a.parseAction(response)
+	actions, err := a.parseActions(response)
 	if err != nil {
 		result.Error = fmt.Errorf("failed to parse action: %w", err)
 		result.Observation = fmt.Sprintf("Error: Could not parse your response as a valid action. Please respond with valid JSON. Error: %s", err)
 		return result, nil // Don't return error, let agent self-correct
 	}
 
+	action := actions[0]
 	result.Action = action
+	for i := range actions {
+		a.emit(StreamEvent{Type: StreamAction, Action: &actions[i]})
+	}
+	a.hookThought(ctx, action.Thought)
 
 	// Check for final answer
 	if action.Action == "final_answer" {
@@ -254,25 +487,171 @@ func (a *Agent) Step(ctx context.Context) (StepResult, error) {
 		return result, nil
 	}
 
-	// Execute the tool
-	observation, err := a.executeTool(ctx, action)
-	if err != nil {
+	// Don't start tools for a run cancelled during the LLM call
+	if err := ctx.Err(); err != nil {
 		result.Error = err
-		result.Observation = fmt.Sprintf("Error executing tool '%s': %s", action.Action, err)
-	} else {
-		result.Observation = observation
+		return result, err
+	}
+
+	if len(actions) > 1 {
+		ids := make([]string, len(actions))
+		if a.supportsToolMessages() {
+			last := &a.messages[len(a.messages)-1]
+			for i, act := range actions {
+				a.calls++
+				ids[i] = fmt.Sprintf("call_%d", a.calls)
+				last.ToolCalls = append(last.ToolCalls, core.ToolCall{ID: ids[i], Name: act.Action, Arguments: string(act.ActionInput)})
+			}
+		}
+		a.observeAll(ctx, &result, actions, ids)
+		return result, nil
+	}
+
+	a.observe(ctx, &result)
+
+	// Record the call on the assistant message so the result can be sent
+	// back as a role=tool message referencing it.
+	if a.supportsToolMessages() {
+		a.calls++
+		result.ToolCallID = fmt.Sprintf("call_%d", a.calls)
+		last := &a.messages[len(a.messages)-1]
+		last.ToolCalls = append(last.ToolCalls, core.ToolCall{
+			ID:        result.ToolCallID,
+			Name:      action.Action,
+			Arguments: string(action.ActionInput),
+		})
 	}
 
 	return result, nil
 }
 
-// buildSystemPrompt constructs the full system prompt with tool descriptions.
-func (a *Agent) buildSystemPrompt() string {
+// observe executes the step's action and records the observation.
+func (a *Agent) observe(ctx context.Context, result *StepResult) {
+	name := result.Action.Action
+	err := a.approve(ctx, &result.Action)
+	var observation string
+	if err == nil {
+		a.hookToolCall(ctx, name, string(result.Action.ActionInput))
+		observation, err = a.executeTool(ctx, result.Action)
+		a.hookToolResult(ctx, name, observation, err)
+	}
+
+	if err != nil {
+		result.Error = err
+	}
+	result.Observation, result.TimedOut = describeCall(name, observation, err)
+	result.Observation = a.clip(name, result.Observation)
+	ev := StreamEvent{Type: StreamObservation, Observation: result.Observation}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	a.emit(ev)
+}
+
+// approve runs the before-tool-call hooks and the confirmation for action,
+// which may replace its input.
+func (a *Agent) approve(ctx context.Context, action *AgentAction) error {
+	input, err := a.hookBeforeToolCall(ctx, action.Action, action.ActionInput)
+	if err == nil {
+		action.ActionInput = input
+		input, err = a.confirmAction(ctx, *action)
+	}
+	if err == nil {
+		action.ActionInput = input
+	}
+	return err
+}
+
+// describeCall returns the observation for a call to the named tool that
+// produced output and err, and whether the call timed out.
+func describeCall(name, output string, err error) (string, bool) {
+	var partial *tools.PartialError
+	var denied *ToolDeniedError
+	var timeout *StepTimeoutError
+	switch {
+	case errors.As(err, &timeout):
+		if errors.As(err, &partial) {
+			return fmt.Sprintf("%s\n[Tool '%s' timed out after %s; output is incomplete]", partial.Output, name, timeout.After), true
+		}
+		return fmt.Sprintf("Tool '%s' timed out after %s", name, timeout.After), true
+	case errors.As(err, &partial):
+		return fmt.Sprintf("%s\n[Tool '%s' was cancelled; output is incomplete]", partial.Output, name), false
+	case errors.As(err, &denied):
+		return fmt.Sprintf("The call to '%s' was not approved: %s. Do not repeat it unchanged; find another way or explain what you need.", name, denied.Reason), false
+	case err != nil:
+		return fmt.Sprintf("Error executing tool '%s': %s", name, err), false
+	}
+	return output, false
+}
+
+// supportsToolMessages reports whether the LLM accepts role=tool messages.
+func (a *Agent) supportsToolMessages() bool {
+	s, ok := a.llm.(core.ToolMessageSupport)
+	return ok && s.SupportsToolMessages()
+}
+
+// observationMessages builds the conversation messages carrying a step's
+// observation: a tool message per tool call ID, since providers require an
+// answer to every call, otherwise a single user message prefixed with
+// "Observation:".
+func observationMessages(step StepResult) []core.Message {
+	if len(step.Calls) > 0 && step.Calls[0].ToolCallID != "" {
+		messages := make([]core.Message, len(step.Calls))
+		for i, call := range step.Calls {
+			messages[i] = core.Message{
+				Role:       core.RoleTool,
+				Content:    call.Observation,
+				Name:       call.Action.Action,
+				ToolCallID: call.ToolCallID,
+			}
+		}
+		return messages
+	}
+	if step.ToolCallID != "" {
+		return []core.Message{{
+			Role:       core.RoleTool,
+			Content:    step.Observation,
+			Name:       step.Action.Action,
+			ToolCallID: step.ToolCallID,
+		}}
+	}
+	return []core.Message{{
+		Role:    core.RoleUser,
+		Content: fmt.Sprintf("Observation: %s", step.Observation),
+	}}
+}
+
+// buildSystemPrompt constructs the full system prompt with tool descriptions
+// and the output schema, if any. The default prompt also asks the model to
+// self-check with the critic tool when it is registered, and points to
+// describe_tool when the documentation meta-tools are offered.
+func (a *Agent) buildSystemPrompt(output *outputSpec) string {
+	registry := a.registry()
+	prompt := a.config.SystemPrompt
+	if _, ok := a.toolCaller(); ok && prompt == defaultSystemPrompt {
+		prompt = toolCallingSystemPrompt
+	}
+	if _, ok := registry.Get(tools.CriticToolName); ok && a.config.SystemPrompt == defaultSystemPrompt {
+		prompt += criticInstruction
+	}
+	if _, ok := registry.Get(tools.DescribeToolName); ok && a.config.SystemPrompt == defaultSystemPrompt {
+		prompt += toolDocsInstruction
+	}
+	if output != nil {
+		_, toolCalling := a.toolCaller()
+		prompt += output.instruction(toolCalling)
+	}
+
+	if _, ok := a.toolCaller(); ok {
+		// Tools are passed to the provider natively.
+		return prompt
+	}
+
 	var sb strings.Builder
-	sb.WriteString(a.config.SystemPrompt)
+	sb.WriteString(prompt)
 	sb.WriteString("\n\nAvailable tools:\n")
 
-	for _, tool := range a.tools.List() {
+	for _, tool := range registry.List() {
 		sb.WriteString(fmt.Sprintf("- %s: %s\n", tool.Name, tool.Description))
 	}
 
@@ -312,9 +691,9 @@ func (a *Agent) parseAction(response string) (AgentAction, error) {
 
 // executeTool runs the specified tool with the given input.
 func (a *Agent) executeTool(ctx context.Context, action AgentAction) (string, error) {
-	tool, exists := a.tools.Get(action.Action)
-	if !exists {
-		return "", fmt.Errorf("unknown tool: %s", action.Action)
+	registry := a.registry()
+	if err := a.checkTool(registry, action.Action); err != nil {
+		return "", err
 	}
 
 	// Convert ActionInput to string for tool execution
@@ -328,7 +707,46 @@ func (a *Agent) executeTool(ctx context.Context, action AgentAction) (string, er
 		inputStr = string(action.ActionInput)
 	}
 
-	return tool.Execute(ctx, inputStr)
+	ctx, cancel, timedOut := withTimeout(ctx, a.config.ToolTimeout, action.Action)
+	defer cancel()
+	output, err := registry.Execute(ctx, action.Action, inputStr)
+	return output, timedOut(err)
+}
+
+// earlier returns the messages of earlier runs to start a run for task
+// with: the ones a Retriever memory recalls for it, or else the latest
+// History messages. A failed recall starts without them; VectorMemory
+// reports the error with Err.
+func (a *Agent) earlier(ctx context.Context, task string) []core.Message {
+	retriever, ok := a.memory.(Retriever)
+	if !ok {
+		return history(a.memory.Get(), a.config.History)
+	}
+	recalled, err := retriever.Recall(ctx, task)
+	if err != nil {
+		return nil
+	}
+	return history(recalled, len(recalled))
+}
+
+// history returns up to limit of the latest turns of earlier runs to
+// replay at the start of a run. Tool calls and results are left out:
+// providers reject tool results whose calls are not part of the same
+// exchange.
+func history(messages []core.Message, limit int) []core.Message {
+	if limit <= 0 {
+		return nil
+	}
+	var turns []core.Message
+	for _, m := range messages {
+		if (m.Role == core.RoleUser || m.Role == core.RoleAssistant) && len(m.ToolCalls) == 0 && m.Content != "" {
+			turns = append(turns, m)
+		}
+	}
+	if len(turns) > limit {
+		turns = turns[len(turns)-limit:]
+	}
+	return turns
 }
 
 // GetMessages returns the current conversation messages.
@@ -339,7 +757,10 @@ func (a *Agent) GetMessages() []core.Message {
 // Reset clears the agent's conversation state.
 func (a *Agent) Reset() {
 	a.messages = make([]core.Message, 0)
+	a.cycles = 0
 	a.memory.Clear()
+	a.stash.clear()
+	a.hookReset()
 }
 
 // Name returns the agent's name.
@@ -0,0 +1,125 @@
+// Package agent provides truncation of large observations, with the full
+// output kept as an artifact the model can page through.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// ReadArtifactToolName is the name of the tool that pages through the full
+// output of a truncated observation.
+const ReadArtifactToolName = "read_artifact"
+
+// WithMaxObservationSize caps observations at n bytes. A longer tool output
+// is replaced by its first n bytes and a note with an artifact ID, and the
+// agent gets a read_artifact tool to read the rest. Artifacts are kept
+// until Reset.
+func WithMaxObservationSize(n int) Option {
+	return func(a *Agent) {
+		a.config.MaxObservationSize = n
+	}
+}
+
+// artifacts holds the full outputs of truncated observations by ID.
+type artifacts struct {
+	mu    sync.RWMutex
+	items map[string]string
+}
+
+func (s *artifacts) add(content string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.items == nil {
+		s.items = make(map[string]string)
+	}
+	id := fmt.Sprintf("artifact-%d", len(s.items)+1)
+	s.items[id] = content
+	return id
+}
+
+func (s *artifacts) get(id string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	content, ok := s.items[id]
+	return content, ok
+}
+
+func (s *artifacts) clear() {
+	s.mu.Lock()
+	s.items = nil
+	s.mu.Unlock()
+}
+
+// clip truncates an observation of the named tool that exceeds
+// MaxObservationSize and stores the full text as an artifact.
+func (a *Agent) clip(name, observation string) string {
+	limit := a.config.MaxObservationSize
+	if limit <= 0 || len(observation) <= limit || name == ReadArtifactToolName {
+		return observation
+	}
+	preview := prefix(observation, limit)
+	id := a.stash.add(observation)
+	return fmt.Sprintf("%s\n[Output truncated: showing %d of %d bytes. Call %s with {\"id\": %q, \"offset\": %d} to read more.]",
+		preview, len(preview), len(observation), ReadArtifactToolName, id, len(preview))
+}
+
+// prefix returns at most n bytes of s, without splitting a UTF-8 sequence.
+func prefix(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// readArtifactTool pages through the agent's artifacts.
+func (a *Agent) readArtifactTool() *tools.Tool {
+	return &tools.Tool{
+		Name:        ReadArtifactToolName,
+		Description: "Read part of a tool output that was truncated, by artifact ID and byte offset.",
+		Parameters: tools.Schema{
+			Type: "object",
+			Properties: map[string]tools.Property{
+				"id":     {Type: "string", Description: "The artifact ID from the truncation note"},
+				"offset": {Type: "integer", Description: "Byte offset to start reading at"},
+				"length": {Type: "integer", Description: "Number of bytes to read (optional)"},
+			},
+			Required: []string{"id"},
+		},
+		Execute: func(ctx context.Context, jsonInput string) (string, error) {
+			var input struct {
+				ID     string `json:"id"`
+				Offset int    `json:"offset"`
+				Length int    `json:"length"`
+			}
+			if err := json.Unmarshal([]byte(jsonInput), &input); err != nil {
+				return "", fmt.Errorf("invalid input: %w", err)
+			}
+			content, ok := a.stash.get(input.ID)
+			if !ok {
+				return "", fmt.Errorf("unknown artifact %q", input.ID)
+			}
+			if input.Offset < 0 || input.Offset > len(content) {
+				return "", fmt.Errorf("offset %d is outside the artifact's %d bytes", input.Offset, len(content))
+			}
+			length := a.config.MaxObservationSize
+			if input.Length > 0 && input.Length < length {
+				length = input.Length
+			}
+			page := prefix(content[input.Offset:], length)
+			end := input.Offset + len(page)
+			if end == len(content) {
+				return fmt.Sprintf("%s\n[Bytes %d-%d of %d; end of artifact.]", page, input.Offset, end, len(content)), nil
+			}
+			return fmt.Sprintf("%s\n[Bytes %d-%d of %d; continue at offset %d.]", page, input.Offset, end, len(content), end), nil
+		},
+	}
+}
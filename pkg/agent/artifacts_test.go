@@ -0,0 +1,72 @@
+// Package agent_test provides tests for truncated observations and
+// artifacts.
+package agent_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestAgent_MaxObservationSize(t *testing.T) {
+	page := strings.Repeat("a", 10) + strings.Repeat("b", 10) + "cc"
+	registry := tools.NewRegistry()
+	registry.Register(tools.QuickTool("fetch", "fetch a page", func(ctx context.Context, input string) (string, error) {
+		return page, nil
+	}))
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "fetch", "action_input": "url"}`,
+		`{"action": "read_artifact", "action_input": {"id": "artifact-1", "offset": 10}}`,
+		`{"action": "read_artifact", "action_input": {"id": "artifact-1", "offset": 20}}`,
+		`{"action": "final_answer", "action_input": "done"}`,
+	}}
+	a := agent.New(llm, registry, agent.WithMaxObservationSize(10))
+
+	result, err := a.Run(context.Background(), "read the page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(llm.calls[0][0].Content, "read_artifact") {
+		t.Error("Expected read_artifact offered in the system prompt")
+	}
+	want := []string{
+		"aaaaaaaaaa\n[Output truncated: showing 10 of 22 bytes. Call read_artifact with {\"id\": \"artifact-1\", \"offset\": 10} to read more.]",
+		"bbbbbbbbbb\n[Bytes 10-20 of 22; continue at offset 20.]",
+		"cc\n[Bytes 20-22 of 22; end of artifact.]",
+	}
+	for i, w := range want {
+		if got := result.Steps[i].Observation; got != w {
+			t.Errorf("step %d:\n got: %q\nwant: %q", i, got, w)
+		}
+	}
+
+}
+
+func TestAgent_ResetClearsArtifacts(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(tools.QuickTool("fetch", "fetch a page", func(ctx context.Context, input string) (string, error) {
+		return "0123456789abcdef", nil
+	}))
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "fetch", "action_input": "url"}`,
+		`{"action": "final_answer", "action_input": "done"}`,
+		`{"action": "read_artifact", "action_input": {"id": "artifact-1"}}`,
+		`{"action": "final_answer", "action_input": "done"}`,
+	}}
+	a := agent.New(llm, registry, agent.WithMaxObservationSize(8))
+	if _, err := a.Run(context.Background(), "fetch"); err != nil {
+		t.Fatal(err)
+	}
+
+	a.Reset()
+	result, err := a.Run(context.Background(), "read it")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result.Steps[0].Observation, `unknown artifact "artifact-1"`) {
+		t.Errorf("Expected the artifact cleared by Reset, got %q", result.Steps[0].Observation)
+	}
+}
@@ -0,0 +1,46 @@
+// Package agent_test provides tests for partial tool output on cancellation.
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestAgent_PartialObservation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{
+		Name: "weather",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			cancel() // the run is stopped while the tool works
+			if tools.Canceled(ctx) {
+				tools.PartialResult(ctx, "Paris: sunny")
+				return "", ctx.Err()
+			}
+			return "Paris: sunny\nRome: rainy", nil
+		},
+	})
+
+	a := agent.New(&scriptedLLM{responses: weatherScript}, registry)
+	result, err := a.Run(ctx, "weather?")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected run to be cancelled, got %v", err)
+	}
+	if len(result.Steps) != 1 {
+		t.Fatalf("Expected the interrupted step to be recorded, got %d steps", len(result.Steps))
+	}
+
+	step := result.Steps[0]
+	var partial *tools.PartialError
+	if !errors.As(step.Error, &partial) {
+		t.Fatalf("Expected PartialError on step, got %v", step.Error)
+	}
+	if !strings.HasPrefix(step.Observation, "Paris: sunny\n") || !strings.Contains(step.Observation, "incomplete") {
+		t.Errorf("Expected truncated observation, got %q", step.Observation)
+	}
+}
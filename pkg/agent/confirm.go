@@ -0,0 +1,86 @@
+// Package agent provides human confirmation of tool calls.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ToolDeniedError reports a tool call denied by the confirmation handler.
+type ToolDeniedError struct {
+	Tool   string
+	Reason string
+}
+
+func (e *ToolDeniedError) Error() string {
+	return fmt.Sprintf("agent: call to %s denied: %s", e.Tool, e.Reason)
+}
+
+// Decision is the outcome of a confirmation request.
+type Decision string
+
+const (
+	// Approve runs the tool call as proposed.
+	Approve Decision = "approve"
+	// Deny skips the tool call. The model is told it was denied and why.
+	Deny Decision = "deny"
+	// Modify runs the tool call with a replacement input.
+	Modify Decision = "modify"
+)
+
+// Confirmation answers a confirmation request.
+type Confirmation struct {
+	Decision Decision `json:"decision"`
+	// Input replaces the tool input when Decision is Modify.
+	Input json.RawMessage `json:"input,omitempty"`
+	// Reason is passed to the model when the call is denied.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ConfirmationHandler decides whether a pending call to a tool marked
+// RequiresConfirmation may run. It blocks the run until it returns; an
+// error fails the step.
+type ConfirmationHandler func(ctx context.Context, action AgentAction) (Confirmation, error)
+
+// WithConfirmationHandler asks h to approve calls to tools that require
+// confirmation. Without a handler such tools run like any other.
+func WithConfirmationHandler(h ConfirmationHandler) Option {
+	return func(a *Agent) {
+		a.confirm = h
+	}
+}
+
+// confirmAction returns the input to run action with, or an error if the
+// call was denied or could not be confirmed.
+func (a *Agent) confirmAction(ctx context.Context, action AgentAction) (json.RawMessage, error) {
+	if a.confirm == nil {
+		return action.ActionInput, nil
+	}
+	tool, ok := a.registry().Get(action.Action)
+	if !ok || !tool.RequiresConfirmation {
+		return action.ActionInput, nil
+	}
+
+	c, err := a.confirm(ctx, action)
+	if err != nil {
+		return nil, fmt.Errorf("agent: confirming %s: %w", action.Action, err)
+	}
+	switch c.Decision {
+	case Approve:
+		return action.ActionInput, nil
+	case Modify:
+		if !json.Valid(c.Input) {
+			return nil, fmt.Errorf("agent: confirming %s: modified input is not valid JSON", action.Action)
+		}
+		return c.Input, nil
+	case Deny:
+		reason := c.Reason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		return nil, &ToolDeniedError{Tool: action.Action, Reason: reason}
+	default:
+		return nil, fmt.Errorf("agent: confirming %s: unknown decision %q", action.Action, c.Decision)
+	}
+}
@@ -0,0 +1,107 @@
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func confirmRegistry(executed *[]string) *tools.Registry {
+	registry := tools.NewRegistry()
+	registry.Register(tools.Build("delete_file").
+		Description("delete a file").
+		RequiresConfirmation().
+		Handler(func(ctx context.Context, input string) (string, error) {
+			*executed = append(*executed, input)
+			return "deleted", nil
+		}).
+		Create())
+	registry.Register(tools.QuickTool("list_files", "list files", func(ctx context.Context, input string) (string, error) {
+		*executed = append(*executed, "list")
+		return "a.txt b.txt", nil
+	}))
+	return registry
+}
+
+func TestConfirmation_Decisions(t *testing.T) {
+	var executed []string
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "list_files", "action_input": ""}`,
+		`{"action": "delete_file", "action_input": {"path": "/"}}`,
+		`{"action": "delete_file", "action_input": {"path": "a.txt"}}`,
+		`{"action": "delete_file", "action_input": {"path": "b.txt"}}`,
+		`{"action": "final_answer", "action_input": "cleaned up"}`,
+	}}
+
+	var asked []string
+	decisions := []agent.Confirmation{
+		{Decision: agent.Deny, Reason: "never delete the root"},
+		{Decision: agent.Approve},
+		{Decision: agent.Modify, Input: []byte(`{"path":"b.txt.bak"}`)},
+	}
+	handler := func(ctx context.Context, action agent.AgentAction) (agent.Confirmation, error) {
+		asked = append(asked, action.Action)
+		c := decisions[0]
+		decisions = decisions[1:]
+		return c, nil
+	}
+
+	result, err := agent.New(llm, confirmRegistry(&executed), agent.WithConfirmationHandler(handler)).Run(context.Background(), "clean up")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(asked) != 3 {
+		t.Errorf("Expected only delete_file calls to need confirmation, got %q", asked)
+	}
+	want := []string{"list", `{"path": "a.txt"}`, `{"path":"b.txt.bak"}`}
+	if strings.Join(executed, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %q to run, got %q", want, executed)
+	}
+
+	denied := result.Steps[1]
+	var deniedErr *agent.ToolDeniedError
+	if !errors.As(denied.Error, &deniedErr) || deniedErr.Reason != "never delete the root" {
+		t.Errorf("Expected a ToolDeniedError, got %v", denied.Error)
+	}
+	if !strings.Contains(denied.Observation, "never delete the root") {
+		t.Errorf("Expected the reason in the observation, got %q", denied.Observation)
+	}
+}
+
+func TestConfirmation_WithoutHandler(t *testing.T) {
+	var executed []string
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "delete_file", "action_input": {"path": "a.txt"}}`,
+		`{"action": "final_answer", "action_input": "done"}`,
+	}}
+	if _, err := agent.New(llm, confirmRegistry(&executed)).Run(context.Background(), "delete"); err != nil {
+		t.Fatal(err)
+	}
+	if len(executed) != 1 {
+		t.Errorf("Expected the tool to run without a handler, got %q", executed)
+	}
+}
+
+func TestConfirmation_HandlerError(t *testing.T) {
+	var executed []string
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "delete_file", "action_input": {"path": "a.txt"}}`,
+		`{"action": "final_answer", "action_input": "done"}`,
+	}}
+	handler := func(ctx context.Context, action agent.AgentAction) (agent.Confirmation, error) {
+		return agent.Confirmation{}, context.DeadlineExceeded
+	}
+
+	result, err := agent.New(llm, confirmRegistry(&executed), agent.WithConfirmationHandler(handler)).Run(context.Background(), "delete")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(executed) != 0 || !errors.Is(result.Steps[0].Error, context.DeadlineExceeded) {
+		t.Errorf("Expected the call to fail unconfirmed, got %q and %v", executed, result.Steps[0].Error)
+	}
+}
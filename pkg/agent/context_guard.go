@@ -0,0 +1,427 @@
+// Package agent provides context window management for agent conversations.
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+// ErrContextOverflow is returned when a conversation cannot be reduced to
+// fit the model's context window. Use errors.As with *ContextOverflowError
+// for details.
+var ErrContextOverflow = errors.New("context window overflow")
+
+// ContextOverflowError describes a conversation that does not fit even
+// after all windowing strategies were applied.
+type ContextOverflowError struct {
+	Model  string
+	Tokens int // tokens of the minimal message set
+	Limit  int // available tokens after the response reserve
+}
+
+func (e *ContextOverflowError) Error() string {
+	return fmt.Sprintf("%v: minimal conversation needs %d tokens, model %q allows %d", ErrContextOverflow, e.Tokens, e.Model, e.Limit)
+}
+
+// Is reports whether target is ErrContextOverflow.
+func (e *ContextOverflowError) Is(target error) bool {
+	return target == ErrContextOverflow
+}
+
+// DefaultContextLimits holds context window sizes (in tokens) for common models.
+// Lookups fall back to the longest matching prefix, so "gpt-4o-2024-08-06"
+// resolves to "gpt-4o".
+var DefaultContextLimits = map[string]int{
+	"gpt-4o":            128000,
+	"gpt-4o-mini":       128000,
+	"gpt-4-turbo":       128000,
+	"gpt-4":             8192,
+	"gpt-3.5-turbo":     16385,
+	"o1":                200000,
+	"o3-mini":           200000,
+	"claude-3-5-sonnet": 200000,
+	"claude-3-5-haiku":  200000,
+	"claude-3-opus":     200000,
+	"claude-3-sonnet":   200000,
+	"claude-3-haiku":    200000,
+	"gemini-1.5-pro":    2097152,
+	"gemini-1.5-flash":  1048576,
+	"gemini-2.0-flash":  1048576,
+	"gemini-pro":        32760,
+}
+
+// defaultResponseReserve is the number of tokens kept free for the reply.
+const defaultResponseReserve = 1024
+
+// messageOverhead approximates per-message formatting tokens.
+const messageOverhead = 4
+
+// observationPrefix marks tool observations added by the agent loop.
+const observationPrefix = "Observation: "
+
+// ContextWindowGuard trims a conversation to fit a model's context window
+// before it is sent to the provider.
+//
+// Windowing preserves the leading system prompt, the original task and the
+// latest user turn (or the latest tool call and its results) and is applied
+// in stages: drop the oldest observations, then apply Strategy, then drop the
+// oldest remaining messages. History replayed before the task is trimmed
+// before the run's own steps. If the preserved messages alone do not fit,
+// Fit returns a *ContextOverflowError.
+type ContextWindowGuard struct {
+	// Model selects the limit from Limits. Empty uses the LLM's model if it
+	// implements core.ModelInfo.
+	Model string
+	// Limits maps model names to context sizes. Default: DefaultContextLimits.
+	Limits map[string]int
+	// MaxTokens overrides the limit lookup when > 0.
+	MaxTokens int
+	// Reserve is the number of tokens kept free for the response.
+	Reserve int
+	// Counter counts tokens. Nil uses the LLM if it implements
+	// core.TokenCounter, otherwise a 4-characters-per-token estimate.
+	Counter core.TokenCounter
+	// Strategy trims history that still does not fit once observations are
+	// dropped. Nil uses SummarizeOldest with Summarizer if set, otherwise
+	// DropOldest.
+	Strategy TrimStrategy
+	// Summarizer compresses dropped history into a single message.
+	Summarizer *SummaryMemory
+}
+
+// NewContextWindowGuard creates a guard for the given model with the default limit table.
+func NewContextWindowGuard(model string) *ContextWindowGuard {
+	return &ContextWindowGuard{
+		Model:   model,
+		Limits:  DefaultContextLimits,
+		Reserve: defaultResponseReserve,
+	}
+}
+
+// TrimReport records the windowing applied before an LLM call.
+type TrimReport struct {
+	OriginalTokens int `json:"original_tokens"`
+	Tokens         int `json:"tokens"`
+	Limit          int `json:"limit"`
+	Dropped        int `json:"dropped"`
+	Summarized     int `json:"summarized"`
+}
+
+// Trimmed returns the number of messages dropped or summarized.
+func (r *TrimReport) Trimmed() int {
+	if r == nil {
+		return 0
+	}
+	return r.Dropped + r.Summarized
+}
+
+// TrimStrategy shortens conversation history that does not fit the context
+// window.
+type TrimStrategy interface {
+	// Trim returns history shortened by at least excess tokens where
+	// possible, recording what it removed in report. count returns the
+	// tokens of a message.
+	Trim(ctx context.Context, history []core.Message, excess int, count func(core.Message) (int, error), report *TrimReport) ([]core.Message, error)
+}
+
+// DropOldest drops the oldest messages until the history fits.
+type DropOldest struct{}
+
+// Trim drops messages from the front of history.
+func (DropOldest) Trim(ctx context.Context, history []core.Message, excess int, count func(core.Message) (int, error), report *TrimReport) ([]core.Message, error) {
+	for len(history) > 0 && excess > 0 {
+		cost, err := count(history[0])
+		if err != nil {
+			return nil, err
+		}
+		excess -= cost
+		history = history[1:]
+		report.Dropped++
+	}
+	return history, nil
+}
+
+// SummarizeOldest replaces the history with a single summary message from
+// Summarizer. If summarizing fails the history is returned unchanged.
+type SummarizeOldest struct {
+	Summarizer *SummaryMemory
+}
+
+// Trim summarizes history.
+func (s SummarizeOldest) Trim(ctx context.Context, history []core.Message, excess int, count func(core.Message) (int, error), report *TrimReport) ([]core.Message, error) {
+	if len(history) == 0 || excess <= 0 {
+		return history, nil
+	}
+	summary, err := s.Summarizer.Summarize(ctx, history)
+	if err != nil {
+		return history, nil
+	}
+	report.Summarized += len(history)
+	return []core.Message{{Role: core.RoleSystem, Content: "Conversation summary: " + summary}}, nil
+}
+
+// Limit returns the context window of the guard's model, or 0 if unknown.
+func (g *ContextWindowGuard) Limit() int {
+	if g.MaxTokens > 0 {
+		return g.MaxTokens
+	}
+	limits := g.Limits
+	if limits == nil {
+		limits = DefaultContextLimits
+	}
+	if n, ok := limits[g.Model]; ok {
+		return n
+	}
+
+	// Longest prefix match handles dated model versions.
+	prefixes := make([]string, 0, len(limits))
+	for name := range limits {
+		if strings.HasPrefix(g.Model, name) {
+			prefixes = append(prefixes, name)
+		}
+	}
+	if len(prefixes) == 0 {
+		return 0
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	return limits[prefixes[0]]
+}
+
+// Fit returns messages trimmed to the context window. The report is nil if
+// nothing was trimmed. Models without a known limit are passed through. The
+// first user message after the system prompt is kept as the task.
+func (g *ContextWindowGuard) Fit(ctx context.Context, messages []core.Message) ([]core.Message, *TrimReport, error) {
+	out, _, report, err := g.fit(ctx, messages, -1)
+	return out, report, err
+}
+
+// fit is Fit with the index of the task to keep, or -1 for the first user
+// message. It also returns the task's index in the trimmed messages.
+func (g *ContextWindowGuard) fit(ctx context.Context, messages []core.Message, task int) ([]core.Message, int, *TrimReport, error) {
+	limit := g.Limit()
+	if limit <= 0 {
+		return messages, task, nil, nil
+	}
+	budget := limit - g.Reserve
+	if budget <= 0 {
+		budget = limit
+	}
+
+	total, err := g.countAll(ctx, messages)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if total <= budget {
+		return messages, task, nil, nil
+	}
+
+	report := &TrimReport{OriginalTokens: total, Limit: budget}
+
+	// Split into pinned head (system prompt), history before the task, the
+	// pinned task, the run's steps and pinned tail (latest user turn and
+	// everything after it).
+	head := 0
+	for head < len(messages) && messages[head].Role == core.RoleSystem {
+		head++
+	}
+	tail := len(messages)
+	for i := len(messages) - 1; i >= head; i-- {
+		if messages[i].Role == core.RoleUser || messages[i].Role == core.RoleTool {
+			tail = i
+			break
+		}
+	}
+	// A trailing tool result is pinned together with the call that issued it.
+	for tail > head && tail < len(messages) && messages[tail].Role == core.RoleTool {
+		tail--
+	}
+	if task < head || task >= len(messages) || messages[task].Role != core.RoleUser {
+		task = head
+		for task < tail && messages[task].Role != core.RoleUser {
+			task++
+		}
+	}
+	var pinned, before, steps []core.Message
+	if task < tail {
+		pinned = messages[task : task+1]
+		before = append([]core.Message{}, messages[head:task]...)
+		steps = append([]core.Message{}, messages[task+1:tail]...)
+	} else {
+		before = append([]core.Message{}, messages[head:tail]...)
+	}
+	pinnedHead := messages[:head]
+	pinnedTail := messages[tail:]
+
+	minimal, err := g.countAll(ctx, append(append(append([]core.Message{}, pinnedHead...), pinned...), pinnedTail...))
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if minimal > budget {
+		return nil, 0, nil, &ContextOverflowError{Model: g.Model, Tokens: minimal, Limit: budget}
+	}
+
+	count := func(m core.Message) (int, error) { return g.count(ctx, m) }
+	strategy := g.Strategy
+	if strategy == nil && g.Summarizer != nil {
+		strategy = SummarizeOldest{Summarizer: g.Summarizer}
+	} else if strategy == nil {
+		strategy = DropOldest{}
+	}
+	segments := []*[]core.Message{&before, &steps}
+
+	// Stage 1: drop the oldest observations.
+	for _, history := range segments {
+		kept := (*history)[:0]
+		for _, m := range *history {
+			if total > budget && isObservation(m) {
+				cost, err := count(m)
+				if err != nil {
+					return nil, 0, nil, err
+				}
+				total -= cost
+				report.Dropped++
+				continue
+			}
+			kept = append(kept, m)
+		}
+		*history = kept
+	}
+
+	// Stage 2: apply the strategy. Stage 3: drop the oldest remaining messages.
+	for _, s := range []TrimStrategy{strategy, DropOldest{}} {
+		for _, history := range segments {
+			if total <= budget {
+				break
+			}
+			if len(*history) == 0 {
+				continue
+			}
+			if *history, err = s.Trim(ctx, *history, total-budget, count, report); err != nil {
+				return nil, 0, nil, err
+			}
+			if total, err = g.countAll(ctx, append(append([]core.Message{}, before...), steps...)); err != nil {
+				return nil, 0, nil, err
+			}
+			total += minimal
+		}
+	}
+
+	out := make([]core.Message, 0, len(pinnedHead)+len(before)+len(pinned)+len(steps)+len(pinnedTail))
+	out = append(out, pinnedHead...)
+	out = append(out, before...)
+	task = -1
+	if len(pinned) > 0 {
+		task = len(out)
+	}
+	out = append(out, pinned...)
+	out = append(out, steps...)
+	out = append(out, pinnedTail...)
+
+	paired, task := pairToolMessages(out, task)
+	if n := len(out) - len(paired); n > 0 {
+		report.Dropped += n
+		if total, err = g.countAll(ctx, paired); err != nil {
+			return nil, 0, nil, err
+		}
+	}
+	report.Tokens = total
+	return paired, task, report, nil
+}
+
+// isObservation reports whether m carries a tool result.
+func isObservation(m core.Message) bool {
+	return m.Role == core.RoleTool ||
+		(m.Role == core.RoleUser && strings.HasPrefix(m.Content, observationPrefix))
+}
+
+// pairToolMessages removes tool results whose call was dropped and tool calls
+// whose result was dropped, since providers reject unmatched pairs. It also
+// returns the new index of messages[pin].
+func pairToolMessages(messages []core.Message, pin int) ([]core.Message, int) {
+	answered := make(map[string]bool)
+	for _, m := range messages {
+		if m.Role == core.RoleTool && m.ToolCallID != "" {
+			answered[m.ToolCallID] = true
+		}
+	}
+
+	issued := make(map[string]bool)
+	out := make([]core.Message, 0, len(messages))
+	moved := pin
+	for i, m := range messages {
+		if i == pin {
+			moved = len(out)
+		}
+		switch {
+		case len(m.ToolCalls) > 0:
+			var calls []core.ToolCall
+			for _, tc := range m.ToolCalls {
+				if answered[tc.ID] {
+					calls = append(calls, tc)
+					issued[tc.ID] = true
+				}
+			}
+			m.ToolCalls = calls
+		case m.Role == core.RoleTool && m.ToolCallID != "" && !issued[m.ToolCallID]:
+			continue
+		}
+		out = append(out, m)
+	}
+	return out, moved
+}
+
+func (g *ContextWindowGuard) countAll(ctx context.Context, messages []core.Message) (int, error) {
+	total := 0
+	for _, m := range messages {
+		n, err := g.count(ctx, m)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (g *ContextWindowGuard) count(ctx context.Context, m core.Message) (int, error) {
+	if g.Counter == nil {
+		return len(m.Content)/4 + messageOverhead, nil
+	}
+	n, err := g.Counter.CountTokens(ctx, m.Content)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tokens: %w", err)
+	}
+	return n + messageOverhead, nil
+}
+
+// WithContextWindowGuard trims the conversation to the model's context window
+// before every LLM call. The guard's model and token counter default to the
+// agent's LLM when it implements core.ModelInfo or core.TokenCounter.
+func WithContextWindowGuard(g *ContextWindowGuard) Option {
+	return func(a *Agent) {
+		guard := *g
+		if guard.Model == "" {
+			if info, ok := a.llm.(core.ModelInfo); ok {
+				guard.Model = info.Model()
+			}
+		}
+		if guard.Counter == nil {
+			if counter, ok := a.llm.(core.TokenCounter); ok {
+				guard.Counter = counter
+			}
+		}
+		a.guard = &guard
+	}
+}
+
+// WithContextBudget trims the conversation to at most tokens before every LLM
+// call using strategy, or DropOldest if it is nil. The system prompt and the
+// task are always kept. Tokens are counted by the LLM when it implements
+// core.TokenCounter.
+func WithContextBudget(tokens int, strategy TrimStrategy) Option {
+	return WithContextWindowGuard(&ContextWindowGuard{MaxTokens: tokens, Strategy: strategy})
+}
@@ -0,0 +1,271 @@
+// Package agent_test provides tests for context window management.
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// wordCounter counts one token per word.
+type wordCounter struct{}
+
+func (wordCounter) CountTokens(ctx context.Context, text string) (int, error) {
+	return len(strings.Fields(text)), nil
+}
+
+// scriptedLLM replays responses and records every conversation it receives.
+type scriptedLLM struct {
+	responses []string
+	calls     [][]core.Message
+}
+
+func (s *scriptedLLM) Generate(ctx context.Context, prompt string, opts ...core.Option) (string, error) {
+	return "short", nil
+}
+
+func (s *scriptedLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	s.calls = append(s.calls, append([]core.Message{}, messages...))
+	if len(s.calls) > len(s.responses) {
+		return "", fmt.Errorf("no more responses")
+	}
+	return s.responses[len(s.calls)-1], nil
+}
+
+func (s *scriptedLLM) Stream(ctx context.Context, prompt string, opts ...core.Option) (<-chan string, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (s *scriptedLLM) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func oversizedConversation() []core.Message {
+	return []core.Message{
+		{Role: core.RoleSystem, Content: "system"},                             // 5
+		{Role: core.RoleUser, Content: "task"},                                 // 5
+		{Role: core.RoleAssistant, Content: "a1"},                              // 5
+		{Role: core.RoleUser, Content: "Observation: one two three four five"}, // 10
+		{Role: core.RoleAssistant, Content: "a2"},                              // 5
+		{Role: core.RoleUser, Content: "Observation: six seven eight"},         // 8
+		{Role: core.RoleAssistant, Content: "a3"},                              // 5
+		{Role: core.RoleUser, Content: "Observation: latest"},                  // 6
+	}
+}
+
+func contents(messages []core.Message) []string {
+	out := make([]string, len(messages))
+	for i, m := range messages {
+		out[i] = m.Content
+	}
+	return out
+}
+
+func assertContents(t *testing.T, got []core.Message, want ...string) {
+	t.Helper()
+	if strings.Join(contents(got), "|") != strings.Join(want, "|") {
+		t.Fatalf("Unexpected messages:\n got: %q\nwant: %q", contents(got), want)
+	}
+}
+
+func TestContextWindowGuard_FitsUnchanged(t *testing.T) {
+	guard := &agent.ContextWindowGuard{MaxTokens: 100, Counter: wordCounter{}}
+	msgs := oversizedConversation()
+
+	out, report, err := guard.Fit(context.Background(), msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != nil {
+		t.Errorf("Expected no trimming, got %+v", report)
+	}
+	if len(out) != len(msgs) {
+		t.Errorf("Expected %d messages, got %d", len(msgs), len(out))
+	}
+}
+
+func TestContextWindowGuard_DropsOldestObservations(t *testing.T) {
+	guard := &agent.ContextWindowGuard{MaxTokens: 40, Counter: wordCounter{}}
+
+	out, report, err := guard.Fit(context.Background(), oversizedConversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, out,
+		"system", "task", "a1", "a2", "Observation: six seven eight", "a3", "Observation: latest")
+	if report.OriginalTokens != 49 || report.Tokens != 39 || report.Dropped != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestContextWindowGuard_Summarizes(t *testing.T) {
+	summarizer := agent.NewSummaryMemory(&scriptedLLM{}, 10)
+	guard := &agent.ContextWindowGuard{MaxTokens: 25, Counter: wordCounter{}, Summarizer: summarizer}
+
+	out, report, err := guard.Fit(context.Background(), oversizedConversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, out, "system", "task", "Conversation summary: short", "Observation: latest")
+	if out[0].Role != core.RoleSystem || out[len(out)-1].Role != core.RoleUser {
+		t.Error("System prompt and latest user turn must be preserved")
+	}
+	if report.Dropped != 2 || report.Summarized != 3 || report.Tokens != 23 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestContextWindowGuard_DropsWithoutSummarizer(t *testing.T) {
+	guard := &agent.ContextWindowGuard{MaxTokens: 25, Counter: wordCounter{}}
+
+	out, _, err := guard.Fit(context.Background(), oversizedConversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, out, "system", "task", "a3", "Observation: latest")
+}
+
+func TestContextWindowGuard_Overflow(t *testing.T) {
+	guard := &agent.ContextWindowGuard{Model: "tiny", MaxTokens: 8, Counter: wordCounter{}}
+
+	_, _, err := guard.Fit(context.Background(), oversizedConversation())
+	if !errors.Is(err, agent.ErrContextOverflow) {
+		t.Fatalf("Expected ErrContextOverflow, got %v", err)
+	}
+	var overflow *agent.ContextOverflowError
+	if !errors.As(err, &overflow) || overflow.Tokens != 16 || overflow.Limit != 8 {
+		t.Errorf("Unexpected overflow details: %+v", overflow)
+	}
+}
+
+func TestContextWindowGuard_Limits(t *testing.T) {
+	tests := map[string]int{
+		"gpt-4o":                     128000,
+		"gpt-4o-mini-2024-07-18":     128000,
+		"gpt-4-0613":                 8192,
+		"claude-3-5-sonnet-20241022": 200000,
+		"unknown-model":              0,
+	}
+	for model, want := range tests {
+		if got := agent.NewContextWindowGuard(model).Limit(); got != want {
+			t.Errorf("Limit(%q) = %d, want %d", model, got, want)
+		}
+	}
+}
+
+func TestAgent_ContextWindowGuard(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{
+		Name:        "fetch",
+		Description: "fetch",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			return strings.Repeat("data ", 20), nil
+		},
+	})
+
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "fetch", "action_input": {}}`,
+		`{"action": "fetch", "action_input": {}}`,
+		`{"action": "final_answer", "action_input": "done"}`,
+	}}
+	a := agent.New(llm, registry,
+		agent.WithSystemPrompt("sys"),
+		agent.WithContextWindowGuard(&agent.ContextWindowGuard{MaxTokens: 60, Counter: wordCounter{}}),
+	)
+
+	result, err := a.Run(context.Background(), "task")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := llm.calls[2]
+	if last[0].Role != core.RoleSystem {
+		t.Error("System prompt was not preserved")
+	}
+	if !strings.HasPrefix(last[len(last)-1].Content, "Observation: data") {
+		t.Errorf("Latest observation was not preserved: %q", last[len(last)-1].Content)
+	}
+	for _, call := range llm.calls {
+		if strings.Count(strings.Join(contents(call), " "), "Observation:") > 1 {
+			t.Errorf("Expected older observations to be dropped, got %q", contents(call))
+		}
+	}
+	if result.Steps[2].Trim == nil || result.Steps[2].Trim.Dropped != 1 {
+		t.Errorf("Expected trimming recorded on step, got %+v", result.Steps[2].Trim)
+	}
+}
+
+func TestAgent_ContextOverflowStopsRun(t *testing.T) {
+	llm := &scriptedLLM{responses: []string{`{"action": "final_answer", "action_input": "done"}`}}
+	a := agent.New(llm, tools.NewRegistry(),
+		agent.WithContextWindowGuard(&agent.ContextWindowGuard{MaxTokens: 5, Counter: wordCounter{}}),
+	)
+
+	_, err := a.Run(context.Background(), "a task that is far too long")
+	if !errors.Is(err, agent.ErrContextOverflow) {
+		t.Fatalf("Expected ErrContextOverflow, got %v", err)
+	}
+	if len(llm.calls) != 0 {
+		t.Error("Oversized conversation must not reach the provider")
+	}
+}
+
+// countingLLM is a scriptedLLM that counts one token per word.
+type countingLLM struct {
+	scriptedLLM
+	wordCounter
+}
+
+// recordingStrategy records the history it is asked to trim and drops it.
+type recordingStrategy struct{ seen [][]string }
+
+func (s *recordingStrategy) Trim(ctx context.Context, history []core.Message, excess int, count func(core.Message) (int, error), report *agent.TrimReport) ([]core.Message, error) {
+	s.seen = append(s.seen, contents(history))
+	return agent.DropOldest{}.Trim(ctx, history, excess, count, report)
+}
+
+func TestAgent_ContextBudgetKeepsTask(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(tools.QuickTool("fetch", "fetch", func(ctx context.Context, input string) (string, error) {
+		return strings.Repeat("data ", 20), nil
+	}))
+	llm := &countingLLM{scriptedLLM: scriptedLLM{responses: []string{
+		`{"action": "fetch", "action_input": "a"}`,
+		`{"action": "fetch", "action_input": "b"}`,
+		`{"action": "final_answer", "action_input": "done"}`,
+	}}}
+	memory := agent.NewBufferMemory(10)
+	memory.Add(core.Message{Role: core.RoleUser, Content: "an earlier question"})
+	memory.Add(core.Message{Role: core.RoleAssistant, Content: "an earlier answer"})
+	strategy := &recordingStrategy{}
+	a := agent.New(llm, registry,
+		agent.WithSystemPrompt("sys"),
+		agent.WithMemory(memory),
+		agent.WithHistory(10),
+		agent.WithContextBudget(50, strategy),
+	)
+
+	result, err := a.Run(context.Background(), "the task")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Earlier history goes before the run's own steps; the task stays.
+	last := contents(llm.calls[2])
+	if len(last) != 4 || last[1] != "the task" || !strings.Contains(last[2], `"b"`) {
+		t.Errorf("Unexpected conversation: %q", last)
+	}
+	if result.Steps[1].Trimmed != 2 || result.Steps[2].Trimmed != 2 {
+		t.Errorf("Expected 2 messages trimmed per step, got %d and %d", result.Steps[1].Trimmed, result.Steps[2].Trimmed)
+	}
+	want := `[[an earlier question an earlier answer] [{"action": "fetch", "action_input": "a"} {"action": "fetch", "action_input": "b"}]]`
+	if got := fmt.Sprint(strategy.seen); got != want {
+		t.Errorf("Unexpected strategy calls:\n got: %s\nwant: %s", got, want)
+	}
+}
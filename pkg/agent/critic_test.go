@@ -0,0 +1,69 @@
+// Package agent_test provides tests for agents using the critic tool.
+package agent_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// scriptedCritic replays critiques from Generate.
+type scriptedCritic struct {
+	scriptedLLM
+	critiques []string
+	n         int
+}
+
+func (s *scriptedCritic) Generate(ctx context.Context, prompt string, opts ...core.Option) (string, error) {
+	s.n++
+	return s.critiques[s.n-1], nil
+}
+
+func TestAgent_CriticRevision(t *testing.T) {
+	critic := &scriptedCritic{critiques: []string{
+		`{"score": 20, "issues": ["Missing the capital"], "suggestions": ["Name the capital"]}`,
+		`{"score": 95, "issues": [], "suggestions": []}`,
+	}}
+	registry := tools.NewRegistry()
+	registry.Register(tools.CriticTool(critic))
+
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "evaluate_answer", "action_input": {"task": "About France", "answer": "France is in Europe."}}`,
+		`{"action": "evaluate_answer", "action_input": {"task": "About France", "answer": "France is in Europe; its capital is Paris."}}`,
+		`{"action": "final_answer", "action_input": "France is in Europe; its capital is Paris."}`,
+	}}
+	a := agent.New(llm, registry)
+
+	result, err := a.Run(context.Background(), "About France")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result.Output, "Paris") {
+		t.Errorf("Expected revised answer, got %q", result.Output)
+	}
+	if !strings.Contains(llm.calls[0][0].Content, "evaluate_answer") {
+		t.Error("Default prompt should ask for self-evaluation when the critic is registered")
+	}
+
+	low := llm.calls[1][len(llm.calls[1])-1].Content
+	if !strings.Contains(low, `"score":20`) || !strings.Contains(low, "Missing the capital") {
+		t.Errorf("Low-score critique not fed back to the agent: %q", low)
+	}
+	if critic.n != 2 {
+		t.Errorf("Expected 2 critiques, got %d", critic.n)
+	}
+}
+
+func TestAgent_CriticPromptOnlyWhenRegistered(t *testing.T) {
+	llm := &scriptedLLM{responses: weatherScript}
+	if _, err := agent.New(llm, weatherRegistry()).Run(context.Background(), "weather?"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(llm.calls[0][0].Content, "evaluate_answer") {
+		t.Error("Critic instruction must not appear without the tool")
+	}
+}
@@ -0,0 +1,41 @@
+// Package agent_test provides tests for egress denials in agent runs.
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/egress"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestAgent_RecordsEgressDenials(t *testing.T) {
+	egress.SetPolicy(egress.Policy{Allow: []string{"api.example.com"}, DenyByDefault: true})
+	defer egress.SetPolicy(egress.Policy{})
+
+	registry := tools.NewRegistry()
+	tools.WebToolkit().RegisterTo(registry)
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "http_get", "action_input": {"url": "http://blocked.test/secrets"}}`,
+		`{"action": "final_answer", "action_input": "blocked"}`,
+	}}
+
+	result, err := agent.New(llm, registry).Run(context.Background(), "fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.EgressDenied) != 1 || result.EgressDenied[0].Host != "blocked.test" {
+		t.Fatalf("Expected one denial for blocked.test, got %+v", result.EgressDenied)
+	}
+	if !errors.Is(result.Steps[0].Error, egress.ErrEgressDenied) {
+		t.Errorf("Expected step error to be ErrEgressDenied, got %v", result.Steps[0].Error)
+	}
+
+	// The observation tells the model why the call failed.
+	if obs := result.Steps[0].Observation; !strings.Contains(obs, "not allowed") {
+		t.Errorf("Expected error observation, got %q", obs)
+	}
+}
@@ -3,10 +3,21 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/nuulab/goflow/pkg/core"
 )
 
-// Hooks provides callbacks for agent lifecycle events.
+// ErrToolVetoed is returned for tool calls rejected by a BeforeToolCall hook.
+var ErrToolVetoed = errors.New("agent: tool call vetoed")
+
+// Hooks provides callbacks for agent lifecycle events. Nil callbacks are
+// skipped. Callbacks run synchronously on the goroutine running the agent,
+// so a Hooks value shared by agents that run concurrently must be safe for
+// concurrent use.
 type Hooks struct {
 	// OnStart is called when the agent begins a task.
 	OnStart func(ctx context.Context, task string)
@@ -14,7 +25,18 @@ type Hooks struct {
 	OnBeforeStep func(ctx context.Context, iteration int)
 	// OnAfterStep is called after each step completes.
 	OnAfterStep func(ctx context.Context, step StepResult)
-	// OnToolCall is called before a tool is executed.
+	// OnLLMRequest is called before each LLM call with a copy of the
+	// conversation sent.
+	OnLLMRequest func(ctx context.Context, messages []core.Message)
+	// OnLLMResponse is called after each LLM call with the reply text, the
+	// call's token usage and its error.
+	OnLLMResponse func(ctx context.Context, response string, usage core.Usage, err error)
+	// BeforeToolCall is called before a tool is executed with its JSON
+	// input. It returns the input to use, which may be rewritten but must
+	// stay valid JSON. A non-nil error vetoes the call: the tool does not
+	// run and the model sees ErrToolVetoed with the error as the result.
+	BeforeToolCall func(ctx context.Context, toolName string, input string) (string, error)
+	// OnToolCall is called before a tool is executed, after BeforeToolCall.
 	OnToolCall func(ctx context.Context, toolName string, input string)
 	// OnToolResult is called after a tool returns.
 	OnToolResult func(ctx context.Context, toolName string, result string, err error)
@@ -22,8 +44,12 @@ type Hooks struct {
 	OnThought func(ctx context.Context, thought string)
 	// OnError is called when an error occurs.
 	OnError func(ctx context.Context, err error)
-	// OnComplete is called when the agent finishes.
+	// OnComplete is called when the agent finishes, whether or not it
+	// reached a final answer.
 	OnComplete func(ctx context.Context, result *RunResult)
+	// OnReset is called by Reset, e.g. to release resources held for the
+	// conversation such as browser sessions.
+	OnReset func()
 }
 
 // HookBuilder provides a fluent API for building hooks.
@@ -54,6 +80,24 @@ func (b *HookBuilder) OnAfterStep(fn func(ctx context.Context, step StepResult))
 	return b
 }
 
+// OnLLMRequest sets the LLM request callback.
+func (b *HookBuilder) OnLLMRequest(fn func(ctx context.Context, messages []core.Message)) *HookBuilder {
+	b.hooks.OnLLMRequest = fn
+	return b
+}
+
+// OnLLMResponse sets the LLM response callback.
+func (b *HookBuilder) OnLLMResponse(fn func(ctx context.Context, response string, usage core.Usage, err error)) *HookBuilder {
+	b.hooks.OnLLMResponse = fn
+	return b
+}
+
+// BeforeToolCall sets the callback that can rewrite or veto tool calls.
+func (b *HookBuilder) BeforeToolCall(fn func(ctx context.Context, toolName string, input string) (string, error)) *HookBuilder {
+	b.hooks.BeforeToolCall = fn
+	return b
+}
+
 // OnToolCall sets the tool-call callback.
 func (b *HookBuilder) OnToolCall(fn func(ctx context.Context, toolName string, input string)) *HookBuilder {
 	b.hooks.OnToolCall = fn
@@ -84,6 +128,12 @@ func (b *HookBuilder) OnComplete(fn func(ctx context.Context, result *RunResult)
 	return b
 }
 
+// OnReset sets the reset callback.
+func (b *HookBuilder) OnReset(fn func()) *HookBuilder {
+	b.hooks.OnReset = fn
+	return b
+}
+
 // Build returns the constructed Hooks.
 func (b *HookBuilder) Build() Hooks {
 	return b.hooks
@@ -96,6 +146,89 @@ func WithHooks(hooks Hooks) Option {
 	}
 }
 
+// ============ Invocation ============
+
+func (a *Agent) hookStart(ctx context.Context, task string) {
+	if a.hooks.OnStart != nil {
+		a.hooks.OnStart(ctx, task)
+	}
+}
+
+func (a *Agent) hookBeforeStep(ctx context.Context, iteration int) {
+	if a.hooks.OnBeforeStep != nil {
+		a.hooks.OnBeforeStep(ctx, iteration)
+	}
+}
+
+func (a *Agent) hookAfterStep(ctx context.Context, step StepResult) {
+	if a.hooks.OnAfterStep != nil {
+		a.hooks.OnAfterStep(ctx, step)
+	}
+}
+
+func (a *Agent) hookLLMRequest(ctx context.Context) {
+	if a.hooks.OnLLMRequest != nil {
+		a.hooks.OnLLMRequest(ctx, a.GetMessages())
+	}
+}
+
+func (a *Agent) hookLLMResponse(ctx context.Context, response string, usage core.Usage, err error) {
+	if a.hooks.OnLLMResponse != nil {
+		a.hooks.OnLLMResponse(ctx, response, usage, err)
+	}
+}
+
+// hookBeforeToolCall returns the input to run the tool with, or the veto.
+func (a *Agent) hookBeforeToolCall(ctx context.Context, toolName string, input json.RawMessage) (json.RawMessage, error) {
+	if a.hooks.BeforeToolCall == nil {
+		return input, nil
+	}
+	rewritten, err := a.hooks.BeforeToolCall(ctx, toolName, string(input))
+	if err != nil {
+		return input, fmt.Errorf("%w: %w", ErrToolVetoed, err)
+	}
+	if !json.Valid([]byte(rewritten)) {
+		return input, fmt.Errorf("%w: rewritten input is not valid JSON", ErrToolVetoed)
+	}
+	return json.RawMessage(rewritten), nil
+}
+
+func (a *Agent) hookToolCall(ctx context.Context, toolName, input string) {
+	if a.hooks.OnToolCall != nil {
+		a.hooks.OnToolCall(ctx, toolName, input)
+	}
+}
+
+func (a *Agent) hookToolResult(ctx context.Context, toolName, result string, err error) {
+	if a.hooks.OnToolResult != nil {
+		a.hooks.OnToolResult(ctx, toolName, result, err)
+	}
+}
+
+func (a *Agent) hookThought(ctx context.Context, thought string) {
+	if thought != "" && a.hooks.OnThought != nil {
+		a.hooks.OnThought(ctx, thought)
+	}
+}
+
+func (a *Agent) hookError(ctx context.Context, err error) {
+	if a.hooks.OnError != nil {
+		a.hooks.OnError(ctx, err)
+	}
+}
+
+func (a *Agent) hookComplete(ctx context.Context, result *RunResult) {
+	if a.hooks.OnComplete != nil {
+		a.hooks.OnComplete(ctx, result)
+	}
+}
+
+func (a *Agent) hookReset() {
+	if a.hooks.OnReset != nil {
+		a.hooks.OnReset()
+	}
+}
+
 // LoggingHooks returns hooks that log agent activity.
 func LoggingHooks(logFn func(string, ...any)) Hooks {
 	return NewHooks().
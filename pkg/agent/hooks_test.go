@@ -0,0 +1,176 @@
+// Package agent_test provides tests for agent lifecycle hooks.
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestHooks_Order(t *testing.T) {
+	llm := &usageLLM{
+		scriptedLLM: scriptedLLM{responses: weatherScript},
+		usage:       core.Usage{PromptTokens: 10, CompletionTokens: 2},
+	}
+
+	var events []string
+	record := func(format string, args ...any) { events = append(events, fmt.Sprintf(format, args...)) }
+	hooks := agent.NewHooks().
+		OnStart(func(ctx context.Context, task string) { record("start %s", task) }).
+		OnBeforeStep(func(ctx context.Context, iteration int) { record("step %d", iteration) }).
+		OnLLMRequest(func(ctx context.Context, messages []core.Message) { record("request %d", len(messages)) }).
+		OnLLMResponse(func(ctx context.Context, response string, usage core.Usage, err error) {
+			record("response %d", usage.PromptTokens)
+		}).
+		BeforeToolCall(func(ctx context.Context, name, input string) (string, error) {
+			record("before %s %s", name, input)
+			return input, nil
+		}).
+		OnToolCall(func(ctx context.Context, name, input string) { record("call %s", name) }).
+		OnToolResult(func(ctx context.Context, name, result string, err error) { record("result %s", result) }).
+		OnAfterStep(func(ctx context.Context, step agent.StepResult) { record("after final=%t", step.IsFinal) }).
+		OnComplete(func(ctx context.Context, result *agent.RunResult) { record("complete %s", result.Output) }).
+		Build()
+
+	if _, err := agent.New(llm, weatherRegistry(), agent.WithHooks(hooks)).Run(context.Background(), "weather?"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"start weather?",
+		"step 1", "request 2", "response 10",
+		`before weather {"city": "Paris"}`, "call weather", "result sunny",
+		"after final=false",
+		"step 2", "request 4", "response 10", "after final=true",
+		"complete sunny",
+	}
+	if strings.Join(events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected hook order:\n%s\nwant:\n%s", strings.Join(events, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestHooks_BeforeToolCall(t *testing.T) {
+	var executed []string
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{
+		Name:        "deploy",
+		Description: "deploy a service",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			executed = append(executed, input)
+			return "deployed " + input, nil
+		},
+	})
+
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "deploy", "action_input": {"env": "staging"}}`,
+		`{"action": "deploy", "action_input": {"env": "prod"}}`,
+		`{"action": "final_answer", "action_input": "done"}`,
+	}}
+	hooks := agent.NewHooks().
+		BeforeToolCall(func(ctx context.Context, name, input string) (string, error) {
+			if strings.Contains(input, "prod") {
+				return "", errors.New("production deploys need approval")
+			}
+			return `{"env":"staging","dry_run":true}`, nil
+		}).
+		Build()
+
+	result, err := agent.New(llm, registry, agent.WithHooks(hooks)).Run(context.Background(), "deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(executed) != 1 || executed[0] != `{"env":"staging","dry_run":true}` {
+		t.Fatalf("Expected only the rewritten call to run, got %q", executed)
+	}
+	if string(result.Steps[0].Action.ActionInput) != executed[0] {
+		t.Errorf("Expected the step to record the rewritten input, got %s", result.Steps[0].Action.ActionInput)
+	}
+
+	vetoed := result.Steps[1]
+	if !errors.Is(vetoed.Error, agent.ErrToolVetoed) || !strings.Contains(vetoed.Observation, "production deploys need approval") {
+		t.Errorf("Expected a vetoed call, got error %v and observation %q", vetoed.Error, vetoed.Observation)
+	}
+}
+
+func TestHooks_Nil(t *testing.T) {
+	// Zero hooks and partially set hooks are both valid.
+	for _, hooks := range []agent.Hooks{{}, agent.NewHooks().OnError(nil).Build()} {
+		llm := &scriptedLLM{responses: weatherScript}
+		if _, err := agent.New(llm, weatherRegistry(), agent.WithHooks(hooks)).Run(context.Background(), "weather?"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var errs []error
+	var completed *agent.RunResult
+	hooks := agent.NewHooks().
+		OnError(func(ctx context.Context, err error) { errs = append(errs, err) }).
+		OnComplete(func(ctx context.Context, result *agent.RunResult) { completed = result }).
+		Build()
+
+	llm := &scriptedLLM{} // every call fails
+	_, err := agent.New(llm, weatherRegistry(), agent.WithHooks(hooks), agent.WithMaxIterations(1)).Run(context.Background(), "weather?")
+	if !errors.Is(err, agent.ErrMaxIterations) {
+		t.Fatalf("Expected ErrMaxIterations, got %v", err)
+	}
+	if len(errs) != 2 || !errors.Is(errs[1], agent.ErrMaxIterations) {
+		t.Errorf("Expected the step error and the run error, got %v", errs)
+	}
+	if completed == nil || completed.Error == nil {
+		t.Errorf("Expected OnComplete with the error, got %+v", completed)
+	}
+}
+
+func TestHooks_OnReset(t *testing.T) {
+	resets := 0
+	a := agent.New(&scriptedLLM{responses: weatherScript}, weatherRegistry(),
+		agent.WithHooks(agent.NewHooks().OnReset(func() { resets++ }).Build()))
+	if _, err := a.Run(context.Background(), "weather?"); err != nil {
+		t.Fatal(err)
+	}
+	if resets != 0 {
+		t.Fatalf("Expected no reset during a run, got %d", resets)
+	}
+	a.Reset()
+	if resets != 1 {
+		t.Errorf("Expected OnReset once, got %d", resets)
+	}
+}
+
+func TestHooks_SharedAcrossConcurrentAgents(t *testing.T) {
+	var starts, requests, toolCalls, completes atomic.Int64
+	hooks := agent.NewHooks().
+		OnStart(func(ctx context.Context, task string) { starts.Add(1) }).
+		OnLLMRequest(func(ctx context.Context, messages []core.Message) { requests.Add(1) }).
+		OnToolCall(func(ctx context.Context, name, input string) { toolCalls.Add(1) }).
+		OnComplete(func(ctx context.Context, result *agent.RunResult) { completes.Add(1) }).
+		Build()
+
+	const agents = 8
+	var wg sync.WaitGroup
+	for i := 0; i < agents; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			llm := &scriptedLLM{responses: weatherScript}
+			if _, err := agent.New(llm, weatherRegistry(), agent.WithHooks(hooks)).Run(context.Background(), "weather?"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if starts.Load() != agents || requests.Load() != 2*agents || toolCalls.Load() != agents || completes.Load() != agents {
+		t.Errorf("Unexpected counts: starts=%d requests=%d tool calls=%d completes=%d",
+			starts.Load(), requests.Load(), toolCalls.Load(), completes.Load())
+	}
+}
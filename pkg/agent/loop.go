@@ -246,7 +246,7 @@ func (s *StreamingLoop) OnResult(fn func(string)) *StreamingLoop {
 func (s *StreamingLoop) Execute(ctx context.Context, task string) (*RunResult, error) {
 	// Initialize conversation
 	s.agent.messages = []core.Message{
-		{Role: core.RoleSystem, Content: s.agent.buildSystemPrompt()},
+		{Role: core.RoleSystem, Content: s.agent.buildSystemPrompt(s.agent.outputSpec(ctx))},
 		{Role: core.RoleUser, Content: task},
 	}
 
@@ -262,7 +262,7 @@ func (s *StreamingLoop) Execute(ctx context.Context, task string) (*RunResult, e
 
 		result.Iterations = i + 1
 
-		stepResult, err := s.agent.Step(ctx)
+		stepResult, err := s.agent.step(ctx)
 		if err != nil && s.agent.config.StopOnError {
 			result.Error = err
 			return result, err
@@ -279,7 +279,7 @@ func (s *StreamingLoop) Execute(ctx context.Context, task string) (*RunResult, e
 			s.onResult(stepResult.Observation)
 		}
 
-		result.Steps = append(result.Steps, stepResult)
+		result.addStep(stepResult)
 
 		if stepResult.IsFinal {
 			result.Output = stepResult.Observation
@@ -288,11 +288,7 @@ func (s *StreamingLoop) Execute(ctx context.Context, task string) (*RunResult, e
 
 		// Continue to next iteration
 		if stepResult.Observation != "" {
-			obsMsg := core.Message{
-				Role:    core.RoleUser,
-				Content: fmt.Sprintf("Observation: %s", stepResult.Observation),
-			}
-			s.agent.messages = append(s.agent.messages, obsMsg)
+			s.agent.messages = append(s.agent.messages, observationMessages(stepResult)...)
 		}
 	}
 
@@ -22,6 +22,15 @@ type Memory interface {
 	Clear()
 }
 
+// Retriever is a Memory that recalls the messages most relevant to a
+// query. An agent starts each run with what it recalls for the task
+// instead of replaying the latest messages.
+type Retriever interface {
+	Memory
+	// Recall returns the stored messages most relevant to query.
+	Recall(ctx context.Context, query string) ([]core.Message, error)
+}
+
 // BufferMemory implements a simple sliding window memory.
 // It keeps the last N messages in a circular buffer.
 type BufferMemory struct {
@@ -64,7 +73,7 @@ func (b *BufferMemory) GetContext() string {
 
 	var sb strings.Builder
 	for _, msg := range b.messages {
-		sb.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
+		sb.WriteString(formatMessage(msg))
 	}
 	return sb.String()
 }
@@ -76,83 +85,147 @@ func (b *BufferMemory) Clear() {
 	b.messages = make([]core.Message, 0, b.maxSize)
 }
 
-// SummaryMemory uses an LLM to summarize conversation history.
-// When the buffer fills, it summarizes and compresses the history.
+// SummaryMemory keeps the most recent messages verbatim and folds older
+// ones into a rolling summary with an LLM. Folding runs in the background,
+// so Add never waits for the LLM.
 type SummaryMemory struct {
-	mu           sync.RWMutex
-	llm          core.LLM
-	messages     []core.Message
-	summary      string
-	bufferSize   int
-	summarySize  int // Target size for summaries
+	mu          sync.RWMutex
+	idle        *sync.Cond // signalled when folding stops
+	llm         core.LLM
+	counter     core.TokenCounter
+	messages    []core.Message
+	tokens      []int          // token count of each kept message
+	evicted     []core.Message // waiting to be folded into the summary
+	summary     string
+	bufferSize  int
+	tokenBudget int
+	folding     bool
+	generation  int // bumped by Clear so stale folds are discarded
 }
 
-// NewSummaryMemory creates a new summary-based memory.
+// NewSummaryMemory creates a memory keeping the last bufferSize messages
+// verbatim. Older messages are summarized with llm.
 func NewSummaryMemory(llm core.LLM, bufferSize int) *SummaryMemory {
-	return &SummaryMemory{
-		llm:         llm,
-		messages:    make([]core.Message, 0, bufferSize),
-		bufferSize:  bufferSize,
-		summarySize: 500, // Default summary target length
+	s := &SummaryMemory{
+		llm:        llm,
+		messages:   make([]core.Message, 0, bufferSize),
+		bufferSize: bufferSize,
+	}
+	s.idle = sync.NewCond(&s.mu)
+	if counter, ok := llm.(core.TokenCounter); ok {
+		s.counter = counter
 	}
+	return s
 }
 
-// Add stores a message and triggers summarization if needed.
-func (s *SummaryMemory) Add(message core.Message) {
+// SetTokenBudget caps the tokens of the messages kept verbatim; older
+// messages are summarized even if fewer than bufferSize are kept. Tokens are
+// counted with the LLM if it implements core.TokenCounter, otherwise
+// estimated at 4 characters per token. Zero means no cap.
+func (s *SummaryMemory) SetTokenBudget(tokens int) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.tokenBudget = tokens
+	s.evict()
+}
+
+// Add stores a message. Messages beyond the buffer size or token budget
+// are evicted oldest first and folded into the summary.
+func (s *SummaryMemory) Add(message core.Message) {
+	n := s.countTokens(message)
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.messages = append(s.messages, message)
+	s.tokens = append(s.tokens, n)
+	s.evict()
+}
 
-	// Check if we need to summarize
-	if len(s.messages) >= s.bufferSize {
-		// Summarize in background (don't block Add)
-		go s.summarize()
+// evict moves messages over the limits to the fold queue and starts
+// folding. The newest message is always kept. Callers hold the lock.
+func (s *SummaryMemory) evict() {
+	total := 0
+	for _, n := range s.tokens {
+		total += n
+	}
+	for len(s.messages) > 1 &&
+		((s.bufferSize > 0 && len(s.messages) > s.bufferSize) || (s.tokenBudget > 0 && total > s.tokenBudget)) {
+		s.evicted = append(s.evicted, s.messages[0])
+		total -= s.tokens[0]
+		s.messages = s.messages[1:]
+		s.tokens = s.tokens[1:]
+	}
+	if len(s.evicted) > 0 && !s.folding {
+		s.folding = true
+		go s.fold()
 	}
 }
 
-// summarize compresses the message history using the LLM.
-func (s *SummaryMemory) summarize() {
-	s.mu.Lock()
-	if len(s.messages) < s.bufferSize/2 {
-		s.mu.Unlock()
-		return
+func (s *SummaryMemory) countTokens(message core.Message) int {
+	if s.counter != nil {
+		if n, err := s.counter.CountTokens(context.Background(), message.Content); err == nil {
+			return n
+		}
 	}
+	return len(message.Content) / 4
+}
 
-	// Keep last few messages, summarize the rest
-	keepCount := s.bufferSize / 4
-	toSummarize := s.messages[:len(s.messages)-keepCount]
-	toKeep := s.messages[len(s.messages)-keepCount:]
+// fold summarizes evicted messages until none are left. If summarization
+// fails, the batch is dropped and the previous summary kept.
+func (s *SummaryMemory) fold() {
+	for {
+		s.mu.Lock()
+		batch, previous, generation := s.evicted, s.summary, s.generation
+		s.evicted = nil
+		if len(batch) == 0 {
+			s.folding = false
+			s.idle.Broadcast()
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
 
-	// Build summarization prompt
-	var sb strings.Builder
-	sb.WriteString("Summarize the following conversation, keeping key information:\n\n")
-	if s.summary != "" {
-		sb.WriteString(fmt.Sprintf("Previous summary: %s\n\n", s.summary))
-	}
-	for _, msg := range toSummarize {
-		sb.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
-	}
-	s.mu.Unlock()
+		summary, err := s.generate(context.Background(), previous, batch)
 
-	// Generate summary (outside lock)
-	ctx := context.Background()
-	newSummary, err := s.llm.Generate(ctx, sb.String())
-	if err != nil {
-		// If summarization fails, just drop old messages
 		s.mu.Lock()
-		s.messages = toKeep
+		if err == nil && generation == s.generation {
+			s.summary = summary
+		}
 		s.mu.Unlock()
-		return
 	}
+}
 
+// Wait blocks until evicted messages have been folded into the summary.
+func (s *SummaryMemory) Wait() {
 	s.mu.Lock()
-	s.summary = newSummary
-	s.messages = toKeep
-	s.mu.Unlock()
+	defer s.mu.Unlock()
+	for s.folding {
+		s.idle.Wait()
+	}
 }
 
-// Get returns all stored messages.
+// Summarize synchronously summarizes the given messages, folding in the
+// current summary. It does not modify the memory.
+func (s *SummaryMemory) Summarize(ctx context.Context, messages []core.Message) (string, error) {
+	s.mu.RLock()
+	previous := s.summary
+	s.mu.RUnlock()
+	return s.generate(ctx, previous, messages)
+}
+
+func (s *SummaryMemory) generate(ctx context.Context, previous string, messages []core.Message) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("Summarize the following conversation, keeping key information:\n\n")
+	if previous != "" {
+		sb.WriteString(fmt.Sprintf("Previous summary: %s\n\n", previous))
+	}
+	for _, msg := range messages {
+		sb.WriteString(formatMessage(msg))
+	}
+	return s.llm.Generate(ctx, sb.String(), core.WithModelTier(core.TierFast))
+}
+
+// Get returns the messages kept verbatim.
 func (s *SummaryMemory) Get() []core.Message {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -170,17 +243,21 @@ func (s *SummaryMemory) GetContext() string {
 	}
 	sb.WriteString("Recent messages:\n")
 	for _, msg := range s.messages {
-		sb.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
+		sb.WriteString(formatMessage(msg))
 	}
 	return sb.String()
 }
 
-// Clear removes all messages and summary.
+// Clear removes all messages and the summary. Folds in progress are
+// discarded.
 func (s *SummaryMemory) Clear() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.messages = make([]core.Message, 0, s.bufferSize)
+	s.tokens = nil
+	s.evicted = nil
 	s.summary = ""
+	s.generation++
 }
 
 // GetSummary returns the current conversation summary.
@@ -245,7 +322,7 @@ func (w *WindowMemory) GetContext() string {
 
 	var sb strings.Builder
 	for _, msg := range w.messages {
-		sb.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, msg.Content))
+		sb.WriteString(formatMessage(msg))
 	}
 	return sb.String()
 }
@@ -256,3 +333,23 @@ func (w *WindowMemory) Clear() {
 	defer w.mu.Unlock()
 	w.messages = make([]core.Message, 0, w.windowSize)
 }
+
+// formatMessage renders a message as a transcript line, keeping tool call
+// identifiers so summaries and context retain which tool produced what.
+func formatMessage(msg core.Message) string {
+	var sb strings.Builder
+	sb.WriteString(string(msg.Role))
+	if msg.Role == core.RoleTool && msg.Name != "" {
+		sb.WriteString(fmt.Sprintf(" (%s", msg.Name))
+		if msg.ToolCallID != "" {
+			sb.WriteString(", " + msg.ToolCallID)
+		}
+		sb.WriteString(")")
+	}
+	sb.WriteString(": " + msg.Content)
+	for _, tc := range msg.ToolCalls {
+		sb.WriteString(fmt.Sprintf(" [call %s: %s(%s)]", tc.ID, tc.Name, tc.Arguments))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
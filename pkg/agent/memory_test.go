@@ -0,0 +1,149 @@
+// Package agent_test provides tests for agent memory.
+package agent_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// summarizingLLM records summarization prompts and answers with a numbered
+// summary. Calls wait on gate when it is set.
+type summarizingLLM struct {
+	scriptedLLM
+	wordCounter
+	gate    chan struct{}
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (l *summarizingLLM) Generate(ctx context.Context, prompt string, opts ...core.Option) (string, error) {
+	if l.gate != nil {
+		<-l.gate
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prompts = append(l.prompts, prompt)
+	return fmt.Sprintf("summary %d", len(l.prompts)), nil
+}
+
+func (l *summarizingLLM) Prompts() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string{}, l.prompts...)
+}
+
+func addMessages(mem agent.Memory, contents ...string) {
+	for _, c := range contents {
+		mem.Add(core.Message{Role: core.RoleUser, Content: c})
+	}
+}
+
+func joined(messages []core.Message) string {
+	var parts []string
+	for _, m := range messages {
+		parts = append(parts, m.Content)
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestSummaryMemory_FoldsOldMessages(t *testing.T) {
+	llm := &summarizingLLM{}
+	mem := agent.NewSummaryMemory(llm, 3)
+
+	addMessages(mem, "m1", "m2", "m3", "m4", "m5")
+	mem.Wait()
+	if got := joined(mem.Get()); got != "m3,m4,m5" {
+		t.Errorf("Expected the last 3 messages, got %s", got)
+	}
+	prompts := llm.Prompts()
+	folded := strings.Join(prompts, "\n")
+	if !strings.Contains(folded, "m1") || !strings.Contains(folded, "m2") || strings.Contains(folded, "m3") {
+		t.Errorf("Expected m1 and m2 folded, got prompts %q", prompts)
+	}
+	summary := mem.GetSummary()
+	if summary != fmt.Sprintf("summary %d", len(prompts)) {
+		t.Errorf("Unexpected summary %q", summary)
+	}
+
+	// Later folds build on the previous summary.
+	addMessages(mem, "m6")
+	mem.Wait()
+	last := llm.Prompts()[len(llm.Prompts())-1]
+	if !strings.Contains(last, "Previous summary: "+summary) || !strings.Contains(last, "m3") {
+		t.Errorf("Expected a rolling summary, got prompt %q", last)
+	}
+	if ctx := mem.GetContext(); !strings.Contains(ctx, "Conversation summary: "+mem.GetSummary()) || !strings.Contains(ctx, "m6") {
+		t.Errorf("Unexpected context %q", ctx)
+	}
+
+	mem.Clear()
+	if mem.GetSummary() != "" || len(mem.Get()) != 0 {
+		t.Error("Expected Clear to drop the summary and messages")
+	}
+}
+
+func TestSummaryMemory_AddDoesNotWaitForLLM(t *testing.T) {
+	llm := &summarizingLLM{gate: make(chan struct{})}
+	mem := agent.NewSummaryMemory(llm, 2)
+
+	addMessages(mem, "m1", "m2", "m3", "m4")
+	if got := joined(mem.Get()); got != "m3,m4" || mem.GetSummary() != "" {
+		t.Fatalf("Expected the window to move before folding, got %s / %q", got, mem.GetSummary())
+	}
+
+	close(llm.gate)
+	mem.Wait()
+	folded := strings.Join(llm.Prompts(), "\n")
+	if !strings.Contains(folded, "m1") || !strings.Contains(folded, "m2") || mem.GetSummary() == "" {
+		t.Errorf("Expected m1 and m2 folded, got %q", llm.Prompts())
+	}
+}
+
+func TestSummaryMemory_TokenBudget(t *testing.T) {
+	llm := &summarizingLLM{} // counts one token per word
+	mem := agent.NewSummaryMemory(llm, 10)
+	mem.SetTokenBudget(5)
+
+	addMessages(mem, "one two three", "four five", "six seven eight")
+	mem.Wait()
+	if got := joined(mem.Get()); got != "four five,six seven eight" {
+		t.Errorf("Expected 5 tokens of messages, got %s", got)
+	}
+	if folded := strings.Join(llm.Prompts(), "\n"); !strings.Contains(folded, "one two three") {
+		t.Errorf("Expected the first message folded, got %q", llm.Prompts())
+	}
+
+	// The newest message is kept even when it alone exceeds the budget.
+	addMessages(mem, "a b c d e f")
+	mem.Wait()
+	if got := joined(mem.Get()); got != "a b c d e f" {
+		t.Errorf("Expected only the newest message, got %s", got)
+	}
+}
+
+func TestAgent_HistoryIsOptInAndBounded(t *testing.T) {
+	memory := agent.NewBufferMemory(10)
+	addMessages(memory, "q1", "a1", "q2", "a2")
+	answer := `{"action": "final_answer", "action_input": "done"}`
+
+	llm := &scriptedLLM{responses: []string{answer}}
+	a := agent.New(llm, tools.NewRegistry(), agent.WithMemory(memory))
+	if _, err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, llm.calls[0][1:], "task")
+
+	llm = &scriptedLLM{responses: []string{answer}}
+	a = agent.New(llm, tools.NewRegistry(), agent.WithMemory(memory), agent.WithHistory(2))
+	if _, err := a.Run(context.Background(), "next"); err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, llm.calls[0][1:], "task", answer, "next")
+}
@@ -0,0 +1,94 @@
+// Package agent provides middleware that wraps agent steps.
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+// StepFunc executes one think/act cycle, like Agent.Step.
+type StepFunc func(ctx context.Context) (StepResult, error)
+
+// StepMiddleware wraps a StepFunc. It may act before and after calling
+// next, change the step's result, or return without calling next.
+type StepMiddleware func(next StepFunc) StepFunc
+
+// WithMiddleware wraps each step of a run in mw. The first middleware is
+// the outermost: it sees the step first and its result last. Changes to
+// the result, such as a rewritten observation, are what the model sees.
+func WithMiddleware(mw ...StepMiddleware) Option {
+	return func(a *Agent) {
+		a.chain = append(a.chain, mw...)
+	}
+}
+
+// step runs Step through the agent's middleware.
+func (a *Agent) step(ctx context.Context) (StepResult, error) {
+	next := StepFunc(a.Step)
+	for i := len(a.chain) - 1; i >= 0; i-- {
+		next = a.chain[i](next)
+	}
+	return next(ctx)
+}
+
+// LoggingMiddleware logs each step to logger, or to slog.Default when
+// logger is nil. Failed steps are logged at error level.
+func LoggingMiddleware(logger core.Logger) StepMiddleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next StepFunc) StepFunc {
+		return func(ctx context.Context) (StepResult, error) {
+			start := time.Now()
+			result, err := next(ctx)
+			args := []any{
+				"action", result.Action.Action,
+				"final", result.IsFinal,
+				"observation_len", len(result.Observation),
+				"duration", time.Since(start),
+			}
+			if err == nil {
+				err = result.Error
+			}
+			if err != nil {
+				logger.Error("agent step failed", append(args, "error", err)...)
+			} else {
+				logger.Info("agent step", args...)
+			}
+			return result, err
+		}
+	}
+}
+
+// TruncateObservations caps tool observations at limit bytes, keeping the
+// start and noting how much was cut. Final answers are left alone.
+func TruncateObservations(limit int) StepMiddleware {
+	cut := func(s string) string {
+		if len(s) <= limit {
+			return s
+		}
+		return s[:limit] + fmt.Sprintf("\n... (truncated %d bytes)", len(s)-limit)
+	}
+	return func(next StepFunc) StepFunc {
+		return func(ctx context.Context) (StepResult, error) {
+			result, err := next(ctx)
+			if result.IsFinal {
+				return result, err
+			}
+			result.Observation = cut(result.Observation)
+			if len(result.Calls) > 0 {
+				calls := make([]ToolCallResult, len(result.Calls))
+				for i, call := range result.Calls {
+					call.Observation = cut(call.Observation)
+					calls[i] = call
+				}
+				result.Calls = calls
+			}
+			return result, err
+		}
+	}
+}
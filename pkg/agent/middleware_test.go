@@ -0,0 +1,126 @@
+// Package agent_test provides tests for step middleware.
+package agent_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// tracing records when it runs relative to the rest of the chain.
+func tracing(name string, trace *[]string) agent.StepMiddleware {
+	return func(next agent.StepFunc) agent.StepFunc {
+		return func(ctx context.Context) (agent.StepResult, error) {
+			*trace = append(*trace, name+" before")
+			result, err := next(ctx)
+			*trace = append(*trace, name+" after")
+			return result, err
+		}
+	}
+}
+
+func TestAgent_MiddlewareOrder(t *testing.T) {
+	var trace []string
+	llm := &scriptedLLM{responses: []string{`{"action": "final_answer", "action_input": "done"}`}}
+	a := agent.New(llm, tools.NewRegistry(),
+		agent.WithMiddleware(tracing("outer", &trace)),
+		agent.WithMiddleware(tracing("inner", &trace)),
+	)
+
+	if _, err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(trace, ", "); got != "outer before, inner before, inner after, outer after" {
+		t.Errorf("Unexpected order: %s", got)
+	}
+}
+
+func TestAgent_MiddlewareErrors(t *testing.T) {
+	// The step's error reaches the middleware.
+	var seen error
+	observe := func(next agent.StepFunc) agent.StepFunc {
+		return func(ctx context.Context) (agent.StepResult, error) {
+			result, err := next(ctx)
+			seen = err
+			return result, err
+		}
+	}
+	a := agent.New(&scriptedLLM{}, tools.NewRegistry(), agent.WithMiddleware(observe), agent.WithMaxIterations(1))
+	if _, err := a.Run(context.Background(), "task"); err == nil || seen == nil || !strings.Contains(seen.Error(), "no more responses") {
+		t.Errorf("Expected the LLM error seen by the middleware, got %v (run: %v)", seen, err)
+	}
+
+	// A middleware error fails the step without running it.
+	limited := errors.New("rate limited")
+	deny := func(next agent.StepFunc) agent.StepFunc {
+		return func(ctx context.Context) (agent.StepResult, error) {
+			return agent.StepResult{Error: limited}, limited
+		}
+	}
+	llm := &scriptedLLM{}
+	a = agent.New(llm, tools.NewRegistry(),
+		agent.WithMiddleware(deny),
+		agent.WithConfig(agent.Config{MaxIterations: 3, StopOnError: true}),
+	)
+	result, err := a.Run(context.Background(), "task")
+	if !errors.Is(err, limited) || !errors.Is(result.Error, limited) || len(llm.calls) != 0 {
+		t.Errorf("Expected the run stopped by the middleware, got %v after %d LLM calls", err, len(llm.calls))
+	}
+}
+
+func TestTruncateObservations(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(tools.QuickTool("dump", "dump", func(ctx context.Context, input string) (string, error) {
+		return strings.Repeat("x", 100), nil
+	}))
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "dump", "action_input": ""}`,
+		`{"action": "final_answer", "action_input": "` + strings.Repeat("y", 40) + `"}`,
+	}}
+	a := agent.New(llm, registry, agent.WithMiddleware(agent.TruncateObservations(10)))
+
+	result, err := a.Run(context.Background(), "task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "xxxxxxxxxx\n... (truncated 90 bytes)"
+	if result.Steps[0].Observation != want {
+		t.Errorf("Unexpected observation %q", result.Steps[0].Observation)
+	}
+	if last := llm.calls[1][len(llm.calls[1])-1].Content; last != "Observation: "+want {
+		t.Errorf("Expected the truncated observation sent, got %q", last)
+	}
+	if len(result.Output) != 40 {
+		t.Errorf("Final answer must not be truncated, got %q", result.Output)
+	}
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "missing", "action_input": ""}`,
+		`{"action": "final_answer", "action_input": "done"}`,
+	}}
+	a := agent.New(llm, tools.NewRegistry(), agent.WithMiddleware(agent.LoggingMiddleware(logger)))
+
+	if _, err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per step, got %q", buf.String())
+	}
+	if !strings.Contains(lines[0], "level=ERROR") || !strings.Contains(lines[0], "action=missing") || !strings.Contains(lines[0], "unknown tool") {
+		t.Errorf("Unexpected line for the failed step: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "level=INFO") || !strings.Contains(lines[1], "final=true") {
+		t.Errorf("Unexpected line for the final step: %s", lines[1])
+	}
+}
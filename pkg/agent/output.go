@@ -0,0 +1,138 @@
+// Package agent provides structured final answers validated against a schema.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// ErrInvalidOutput is returned when the final answer still does not match
+// the output schema after the model was asked to correct it.
+var ErrInvalidOutput = errors.New("agent: final answer does not match the output schema")
+
+const outputRetryPrompt = `Your final answer does not match the required JSON schema: %s
+Reply with the corrected final answer.`
+
+// outputSpec is the schema a final answer must match and the decoder for
+// its Go type.
+type outputSpec struct {
+	schema tools.Schema
+	decode func(data []byte) (any, error)
+}
+
+func newOutputSpec[T any]() *outputSpec {
+	return &outputSpec{
+		schema: tools.SchemaFor[T](),
+		decode: func(data []byte) (any, error) {
+			var v T
+			if err := json.Unmarshal(data, &v); err != nil {
+				return nil, err
+			}
+			return v, nil
+		},
+	}
+}
+
+// WithOutputSchema makes the final answer a JSON value of type T. The
+// schema inferred from T is added to the system prompt, providers with a
+// JSON mode are asked to use it, and the answer is validated and decoded
+// into RunResult.Value. An invalid answer is sent back to the model once
+// for correction; if it is still invalid the run fails with
+// ErrInvalidOutput.
+func WithOutputSchema[T any]() Option {
+	return func(a *Agent) {
+		a.output = newOutputSpec[T]()
+	}
+}
+
+// RunStructured runs task with T as the output schema and returns the
+// decoded final answer. The schema applies to this run only, in place of
+// any set with WithOutputSchema.
+func RunStructured[T any](ctx context.Context, a *Agent, task string) (T, *RunResult, error) {
+	var zero T
+	result, err := a.Run(context.WithValue(ctx, outputKey{}, newOutputSpec[T]()), task)
+	if err != nil {
+		return zero, result, err
+	}
+	value, _ := result.Value.(T)
+	return value, result, nil
+}
+
+// outputKey carries the output schema of a RunStructured call.
+type outputKey struct{}
+
+// outputSpec returns the output schema for the run in ctx: the one given
+// to RunStructured, or else the agent's. It is nil without either.
+func (a *Agent) outputSpec(ctx context.Context) *outputSpec {
+	if spec, ok := ctx.Value(outputKey{}).(*outputSpec); ok {
+		return spec
+	}
+	return a.output
+}
+
+// instruction describes the expected final answer for the system prompt.
+func (o *outputSpec) instruction(toolCalling bool) string {
+	schema, _ := json.Marshal(o.schema)
+	if toolCalling {
+		return "\n\nWhen you give the final answer, reply with only a JSON value matching this JSON schema, without any other text:\n" + string(schema)
+	}
+	return "\n\nThe action_input of your final_answer must be a JSON value matching this JSON schema:\n" + string(schema)
+}
+
+// parse validates answer against the schema and decodes it.
+func (o *outputSpec) parse(answer string) (any, error) {
+	data := []byte(unfence(answer))
+	if o.schema.Type == "object" {
+		var fields map[string]any
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, fmt.Errorf("not a JSON object: %w", err)
+		}
+		if err := o.schema.Validate(fields); err != nil {
+			return nil, err
+		}
+	}
+	value, err := o.decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode: %w", err)
+	}
+	return value, nil
+}
+
+// unfence strips a Markdown code fence around a reply.
+func unfence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:] // drop the language tag
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
+
+// checkOutput decodes a final answer into result.Value. The first invalid
+// answer is answered with a correction request and reported as not done,
+// so the run continues; a second one fails the run.
+func (a *Agent) checkOutput(spec *outputSpec, result *RunResult, reprompted *bool) (bool, error) {
+	value, err := spec.parse(result.Output)
+	if err == nil {
+		result.Value = value
+		return true, nil
+	}
+	if *reprompted {
+		return true, fmt.Errorf("%w: %v", ErrInvalidOutput, err)
+	}
+	*reprompted = true
+
+	msg := core.Message{Role: core.RoleUser, Content: fmt.Sprintf(outputRetryPrompt, err)}
+	a.messages = append(a.messages, msg)
+	a.memory.Add(msg)
+	return false, nil
+}
@@ -0,0 +1,125 @@
+// Package agent_test provides tests for structured agent output.
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+type verdict struct {
+	Label      string  `json:"label" enum:"spam,ham"`
+	Confidence float64 `json:"confidence"`
+	Reason     string  `json:"reason,omitempty"`
+}
+
+// jsonModeLLM records whether each call asked for a JSON response.
+type jsonModeLLM struct {
+	scriptedLLM
+	jsonMode []bool
+}
+
+func (j *jsonModeLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	var o core.CallOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	j.jsonMode = append(j.jsonMode, o.JSONResponse)
+	return j.scriptedLLM.GenerateChat(ctx, messages, opts...)
+}
+
+func TestRunStructured(t *testing.T) {
+	llm := &jsonModeLLM{scriptedLLM: scriptedLLM{responses: []string{
+		`{"action": "final_answer", "action_input": {"label": "maybe"}}`,
+		`{"action": "final_answer", "action_input": {"label": "spam", "confidence": 0.9}}`,
+	}}}
+	a := agent.New(llm, tools.NewRegistry())
+
+	v, result, err := agent.RunStructured[verdict](context.Background(), a, "classify this email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Label != "spam" || v.Confidence != 0.9 {
+		t.Errorf("Unexpected value: %+v", v)
+	}
+	if got, ok := result.Value.(verdict); !ok || got != v {
+		t.Errorf("Expected RunResult.Value to hold the verdict, got %#v", result.Value)
+	}
+	if len(llm.jsonMode) != 2 || !llm.jsonMode[0] || !llm.jsonMode[1] {
+		t.Errorf("Expected JSON mode on every call, got %v", llm.jsonMode)
+	}
+
+	system := llm.calls[0][0].Content
+	if !strings.Contains(system, `"enum":["spam","ham"]`) || !strings.Contains(system, "action_input of your final_answer") {
+		t.Errorf("Expected the schema in the system prompt:\n%s", system)
+	}
+
+	second := llm.calls[1]
+	retry := second[len(second)-1]
+	if retry.Role != core.RoleUser || !strings.Contains(retry.Content, "confidence: required") || !strings.Contains(retry.Content, "label: must be one of") {
+		t.Errorf("Expected a correction request, got %+v", retry)
+	}
+}
+
+func TestRunStructured_SchemaPerCall(t *testing.T) {
+	llm := &jsonModeLLM{scriptedLLM: scriptedLLM{responses: []string{
+		`{"action": "final_answer", "action_input": {"label": "ham", "confidence": 0.7}}`,
+		`{"action": "final_answer", "action_input": "plain text"}`,
+	}}}
+	a := agent.New(llm, tools.NewRegistry())
+
+	if _, _, err := agent.RunStructured[verdict](context.Background(), a, "classify this email"); err != nil {
+		t.Fatal(err)
+	}
+	// A later plain run does not inherit the schema.
+	result, err := a.Run(context.Background(), "summarize this email")
+	if err != nil || result.Output != "plain text" || result.Value != nil {
+		t.Fatalf("Expected a plain answer, got %q, %#v (%v)", result.Output, result.Value, err)
+	}
+	if len(llm.jsonMode) != 2 || llm.jsonMode[1] {
+		t.Errorf("Expected JSON mode only on the structured run, got %v", llm.jsonMode)
+	}
+	if strings.Contains(llm.calls[1][0].Content, "JSON schema") {
+		t.Errorf("Expected no schema in the plain run's system prompt:\n%s", llm.calls[1][0].Content)
+	}
+}
+
+func TestWithOutputSchema_Invalid(t *testing.T) {
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "final_answer", "action_input": "spam"}`,
+		`{"action": "final_answer", "action_input": "definitely spam"}`,
+	}}
+	a := agent.New(llm, tools.NewRegistry(), agent.WithOutputSchema[verdict]())
+
+	result, err := a.Run(context.Background(), "classify this email")
+	if !errors.Is(err, agent.ErrInvalidOutput) {
+		t.Fatalf("Expected ErrInvalidOutput, got %v", err)
+	}
+	if len(llm.calls) != 2 || result.Value != nil {
+		t.Errorf("Expected one correction and no value, got %d calls and %v", len(llm.calls), result.Value)
+	}
+}
+
+func TestWithOutputSchema_ToolCalling(t *testing.T) {
+	llm := &toolCallingLLM{replies: []core.Response{
+		{ToolCalls: []core.ToolCall{{ID: "call_1", Name: "weather", Arguments: `{"city":"Paris"}`}}},
+		{Content: "```json\n{\"label\": \"ham\", \"confidence\": 0.6}\n```"},
+	}}
+	a := agent.New(llm, weatherRegistry(), agent.WithOutputSchema[verdict]())
+
+	result, err := a.Run(context.Background(), "is the weather report spam?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := result.Value.(verdict); !ok || v.Label != "ham" {
+		t.Errorf("Unexpected value: %#v", result.Value)
+	}
+	if !strings.Contains(llm.calls[0][0].Content, "reply with only a JSON value") {
+		t.Errorf("Expected the tool calling instruction, got:\n%s", llm.calls[0][0].Content)
+	}
+}
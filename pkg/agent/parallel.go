@@ -0,0 +1,125 @@
+// Package agent provides concurrent execution of the tool calls of a step.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// ToolCallResult is the outcome of one of several tool calls made in a
+// single step.
+type ToolCallResult struct {
+	Action      AgentAction
+	ToolCallID  string
+	Observation string
+	Error       error
+	TimedOut    bool
+}
+
+// WithToolConcurrency limits how many tool calls of a step run at once when
+// the model requests several. Zero, the default, runs them all at once.
+func WithToolConcurrency(n int) Option {
+	return func(a *Agent) {
+		a.config.ToolConcurrency = n
+	}
+}
+
+// parseActions parses a response holding an action object or a JSON array
+// of action objects.
+func (a *Agent) parseActions(response string) ([]AgentAction, error) {
+	start := strings.Index(response, "[")
+	end := strings.LastIndex(response, "]")
+	if obj := strings.Index(response, "{"); start == -1 || obj < start || end < start {
+		action, err := a.parseAction(response)
+		return []AgentAction{action}, err
+	}
+
+	var actions []AgentAction
+	if err := json.Unmarshal([]byte(response[start:end+1]), &actions); err != nil || len(actions) == 0 {
+		// Brackets in the reasoning before a single action.
+		action, err := a.parseAction(response)
+		return []AgentAction{action}, err
+	}
+	thought := strings.TrimSpace(response[:start])
+	for i := range actions {
+		actions[i].Thought = thought
+		actions[i].RawResponse = response
+		if actions[i].Action == "" {
+			return actions, fmt.Errorf("action field is required")
+		}
+		if actions[i].Action == "final_answer" && len(actions) > 1 {
+			return actions, fmt.Errorf("final_answer cannot be combined with tool calls")
+		}
+	}
+	return actions, nil
+}
+
+// observeAll executes several actions of one step. Each is approved in call
+// order; the approved calls then run concurrently through the registry.
+// Their observations are combined in call order into the step's.
+func (a *Agent) observeAll(ctx context.Context, result *StepResult, actions []AgentAction, ids []string) {
+	result.Calls = make([]ToolCallResult, len(actions))
+	outputs := make([]string, len(actions))
+	registry := a.registry()
+	var calls []tools.ToolCall
+	var dispatched []int
+	for i := range actions {
+		call := &result.Calls[i]
+		call.Action, call.ToolCallID = actions[i], ids[i]
+		if err := a.approve(ctx, &call.Action); err != nil {
+			call.Error = err
+			continue
+		}
+		if err := a.checkTool(registry, call.Action.Action); err != nil {
+			call.Error = err
+			continue
+		}
+		a.hookToolCall(ctx, call.Action.Action, string(call.Action.ActionInput))
+		calls = append(calls, tools.ToolCall{ID: ids[i], Name: call.Action.Action, Arguments: string(call.Action.ActionInput)})
+		dispatched = append(dispatched, i)
+	}
+
+	opts := []tools.CallOption{
+		tools.WithConcurrency(a.config.ToolConcurrency),
+		tools.WithCallTimeout(a.config.ToolTimeout),
+	}
+	if a.config.StopOnError {
+		opts = append(opts, tools.WithStopOnError())
+	}
+	for j, res := range registry.ExecuteCalls(ctx, calls, opts...) {
+		i := dispatched[j]
+		call := &result.Calls[i]
+		err := res.Err
+		if res.TimedOut {
+			err = &StepTimeoutError{Tool: call.Action.Action, After: a.config.ToolTimeout, Err: err}
+		}
+		a.hookToolResult(ctx, call.Action.Action, res.Content, err)
+		outputs[i], call.Error = res.Content, err
+	}
+
+	var parts []string
+	var errs []error
+	for i := range result.Calls {
+		call := &result.Calls[i]
+		call.Observation, call.TimedOut = describeCall(call.Action.Action, outputs[i], call.Error)
+		call.Observation = a.clip(call.Action.Action, call.Observation)
+		parts = append(parts, fmt.Sprintf("[%d] %s: %s", i+1, call.Action.Action, call.Observation))
+		if call.Error != nil {
+			errs = append(errs, call.Error)
+		}
+		result.TimedOut = result.TimedOut || call.TimedOut
+	}
+	result.Observation = strings.Join(parts, "\n")
+	result.Error = errors.Join(errs...)
+
+	ev := StreamEvent{Type: StreamObservation, Observation: result.Observation}
+	if result.Error != nil {
+		ev.Error = result.Error.Error()
+	}
+	a.emit(ev)
+}
@@ -0,0 +1,108 @@
+// Package agent_test provides tests for parallel tool calls.
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// rendezvousRegistry has tools that each wait up to patience until n of
+// them are running at once, so they only succeed when calls run concurrently.
+func rendezvousRegistry(n int, patience time.Duration) *tools.Registry {
+	var started sync.WaitGroup
+	started.Add(n)
+	all := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(all)
+	}()
+	wait := func(name string, err error) *tools.Tool {
+		return tools.QuickTool(name, name, func(ctx context.Context, input string) (string, error) {
+			started.Done()
+			select {
+			case <-all:
+			case <-time.After(patience):
+				return "", errors.New("ran alone")
+			}
+			if err != nil {
+				return "", err
+			}
+			return name + " " + input, nil
+		})
+	}
+	registry := tools.NewRegistry()
+	registry.Register(wait("flights", nil))
+	registry.Register(wait("hotels", errors.New("no rooms")))
+	return registry
+}
+
+func TestAgent_ParallelToolCalls(t *testing.T) {
+	llm := &scriptedLLM{responses: []string{
+		`Both at once. [{"action": "flights", "action_input": "LIS"}, {"action": "hotels", "action_input": "LIS"}]`,
+		`{"action": "final_answer", "action_input": "booked"}`,
+	}}
+	a := agent.New(llm, rendezvousRegistry(2, time.Second))
+
+	result, err := a.Run(context.Background(), "plan a trip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := result.Steps[0]
+	if len(step.Calls) != 2 || step.Action.Action != "flights" || step.Action.Thought != "Both at once." {
+		t.Fatalf("Unexpected step: %+v", step)
+	}
+	// The failed call does not cancel its sibling.
+	if step.Calls[0].Observation != `flights "LIS"` || step.Calls[0].Error != nil {
+		t.Errorf("Unexpected first call: %+v", step.Calls[0])
+	}
+	if step.Calls[1].Error == nil || !strings.Contains(step.Calls[1].Observation, "no rooms") {
+		t.Errorf("Expected the second call to fail, got %+v", step.Calls[1])
+	}
+
+	// Observations come back as one message, in call order.
+	sent := llm.calls[1]
+	want := "Observation: [1] flights: flights \"LIS\"\n[2] hotels: Error executing tool 'hotels': no rooms"
+	if last := sent[len(sent)-1]; last.Role != core.RoleUser || last.Content != want {
+		t.Errorf("Unexpected observation message:\n got: %q\nwant: %q", last.Content, want)
+	}
+}
+
+func TestAgent_ToolConcurrency(t *testing.T) {
+	llm := &scriptedLLM{responses: []string{
+		`[{"action": "flights", "action_input": "LIS"}, {"action": "hotels", "action_input": "LIS"}]`,
+		`{"action": "final_answer", "action_input": "booked"}`,
+	}}
+	a := agent.New(llm, rendezvousRegistry(2, 20*time.Millisecond), agent.WithToolConcurrency(1))
+
+	result, err := a.Run(context.Background(), "plan a trip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obs := result.Steps[0].Observation; !strings.Contains(obs, "ran alone") {
+		t.Errorf("Expected calls to run one at a time, got %q", obs)
+	}
+}
+
+func TestAgent_FinalAnswerWithToolCalls(t *testing.T) {
+	llm := &scriptedLLM{responses: []string{
+		`[{"action": "flights", "action_input": "LIS"}, {"action": "final_answer", "action_input": "done"}]`,
+		`{"action": "final_answer", "action_input": "done"}`,
+	}}
+	a := agent.New(llm, rendezvousRegistry(2, time.Second))
+
+	result, err := a.Run(context.Background(), "plan a trip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Steps[0].IsFinal || !strings.Contains(result.Steps[0].Observation, "final_answer cannot be combined") {
+		t.Errorf("Expected the mixed response rejected, got %+v", result.Steps[0])
+	}
+}
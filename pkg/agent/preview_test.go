@@ -0,0 +1,70 @@
+// Package agent_test provides tests for preview runs.
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+var loopingScript = []string{
+	`{"action": "weather", "action_input": {"city": "Paris"}}`,
+	`{"action": "weather", "action_input": {"city": "Rome"}}`,
+	`{"action": "weather", "action_input": {"city": "Oslo"}}`,
+	`{"action": "final_answer", "action_input": "sunny everywhere"}`,
+}
+
+func TestAgent_PreviewRun(t *testing.T) {
+	llm := &scriptedLLM{responses: loopingScript}
+	a := agent.New(llm, weatherRegistry())
+
+	result, err := a.PreviewRun(context.Background(), "weather?", 2)
+	if err != nil {
+		t.Fatalf("Preview stopping early must not be an error: %v", err)
+	}
+	if !result.Preview || !result.WouldContinue || result.Error != nil {
+		t.Errorf("Expected clean preview that would continue, got %+v", result)
+	}
+	if result.Iterations != 2 || len(llm.calls) != 2 {
+		t.Errorf("Expected 2 iterations, got %d (%d LLM calls)", result.Iterations, len(llm.calls))
+	}
+
+	// system, user, then assistant + observation per iteration
+	if len(result.Transcript) != 6 {
+		t.Fatalf("Expected 6 transcript messages, got %d", len(result.Transcript))
+	}
+	if last := result.Transcript[len(result.Transcript)-1]; last.Role != core.RoleUser || last.Content != "Observation: sunny" {
+		t.Errorf("Unexpected last transcript message: %+v", last)
+	}
+}
+
+func TestAgent_PreviewRunFinishes(t *testing.T) {
+	a := agent.New(&scriptedLLM{responses: weatherScript}, weatherRegistry())
+
+	result, err := a.PreviewRun(context.Background(), "weather?", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Preview || result.WouldContinue || result.Output != "sunny" {
+		t.Errorf("Expected finished preview, got %+v", result)
+	}
+
+	if _, err := a.PreviewRun(context.Background(), "weather?", 0); err == nil {
+		t.Error("Expected error for zero iterations")
+	}
+}
+
+func TestAgent_RunMaxIterations(t *testing.T) {
+	a := agent.New(&scriptedLLM{responses: loopingScript}, weatherRegistry(), agent.WithMaxIterations(2))
+
+	result, err := a.Run(context.Background(), "weather?")
+	if !errors.Is(err, agent.ErrMaxIterations) {
+		t.Fatalf("Expected ErrMaxIterations, got %v", err)
+	}
+	if result.Preview || result.WouldContinue {
+		t.Error("Regular runs must not be marked as previews")
+	}
+}
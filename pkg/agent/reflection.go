@@ -0,0 +1,117 @@
+// Package agent provides a critic pass that reviews final answers.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+// DefaultCritiquePrompt is the prompt sent to the critic. It is formatted
+// with the task and the answer, in that order.
+const DefaultCritiquePrompt = `You are a strict reviewer. Decide whether the answer fully and correctly solves the task.
+
+Task:
+%s
+
+Answer:
+%s
+
+Respond with only a JSON object in this exact format:
+{"accept": true, "feedback": "what must change when the answer is not accepted"}`
+
+const revisionPrompt = `A reviewer did not accept your final answer:
+%s
+Address the feedback and give the final answer again.`
+
+// Critique is one review of a final answer by the critic.
+type Critique struct {
+	// Answer is the final answer that was reviewed.
+	Answer string
+	// Accepted reports whether the answer was kept.
+	Accepted bool
+	// Feedback is the critic's reason for sending the answer back.
+	Feedback string
+	// Prompt and Response are the critic call's transcript.
+	Prompt   string
+	Response string
+	// Error is set when the critic call failed or its response could not
+	// be parsed. The answer is then accepted.
+	Error error
+}
+
+// reflection is the critic configured with WithReflection.
+type reflection struct {
+	llm          core.LLM
+	maxRevisions int
+}
+
+// WithReflection reviews each final answer with critic. A rejected answer
+// is sent back to the agent with the critic's feedback, up to maxRevisions
+// times per run. Revisions take iterations from MaxIterations; when none
+// are left the answer is kept without review.
+func WithReflection(critic core.LLM, maxRevisions int) Option {
+	return func(a *Agent) {
+		a.critic = &reflection{llm: critic, maxRevisions: maxRevisions}
+	}
+}
+
+// WithCritiquePrompt replaces DefaultCritiquePrompt. The prompt is
+// formatted with the task and the answer, in that order.
+func WithCritiquePrompt(prompt string) Option {
+	return func(a *Agent) {
+		a.config.CritiquePrompt = prompt
+	}
+}
+
+// reflect has the critic review result.Output and records the critique.
+// A rejected answer is answered with a revision request and reported as
+// not accepted, so the run continues.
+func (a *Agent) reflect(ctx context.Context, task string, result *RunResult) bool {
+	prompt := a.config.CritiquePrompt
+	if prompt == "" {
+		prompt = DefaultCritiquePrompt
+	}
+	critique := Critique{
+		Answer: result.Output,
+		Prompt: fmt.Sprintf(prompt, task, result.Output),
+	}
+
+	critique.Response, critique.Error = a.critic.llm.Generate(ctx, critique.Prompt, core.WithModelTier(core.TierSmart))
+	if critique.Error == nil {
+		critique.Accepted, critique.Feedback, critique.Error = parseVerdict(critique.Response)
+	}
+	if critique.Error != nil {
+		critique.Accepted = true
+	}
+	result.Critiques = append(result.Critiques, critique)
+	if critique.Accepted {
+		return true
+	}
+
+	result.Revisions++
+	msg := core.Message{Role: core.RoleUser, Content: fmt.Sprintf(revisionPrompt, critique.Feedback)}
+	a.messages = append(a.messages, msg)
+	a.memory.Add(msg)
+	return false
+}
+
+// parseVerdict extracts the verdict JSON from the critic's response.
+func parseVerdict(response string) (bool, string, error) {
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start == -1 || end < start {
+		return false, "", fmt.Errorf("agent: critique is not JSON: %q", response)
+	}
+	var verdict struct {
+		Accept   bool   `json:"accept"`
+		Feedback string `json:"feedback"`
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &verdict); err != nil {
+		return false, "", fmt.Errorf("agent: invalid critique: %w", err)
+	}
+	return verdict.Accept, verdict.Feedback, nil
+}
@@ -0,0 +1,82 @@
+// Package agent_test provides tests for the reflection pass.
+package agent_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestAgent_Reflection(t *testing.T) {
+	critic := &scriptedCritic{critiques: []string{
+		`{"accept": false, "feedback": "Name the capital."}`,
+		`{"accept": true}`,
+	}}
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "final_answer", "action_input": "France is in Europe."}`,
+		`{"action": "final_answer", "action_input": "France is in Europe; its capital is Paris."}`,
+	}}
+	a := agent.New(llm, tools.NewRegistry(), agent.WithReflection(critic, 2))
+
+	result, err := a.Run(context.Background(), "About France")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Output != "France is in Europe; its capital is Paris." || result.Revisions != 1 || result.Iterations != 2 {
+		t.Errorf("Unexpected result: %q after %d revisions, %d iterations", result.Output, result.Revisions, result.Iterations)
+	}
+	if len(result.Critiques) != 2 || result.Critiques[0].Accepted || !result.Critiques[1].Accepted {
+		t.Fatalf("Unexpected critiques: %+v", result.Critiques)
+	}
+	first := result.Critiques[0]
+	if first.Answer != "France is in Europe." || !strings.Contains(first.Prompt, "About France") || first.Feedback != "Name the capital." {
+		t.Errorf("Unexpected first critique: %+v", first)
+	}
+	if sent := joined(llm.calls[1]); !strings.Contains(sent, "Name the capital.") {
+		t.Errorf("Expected the feedback sent to the agent, got %s", sent)
+	}
+}
+
+func TestAgent_ReflectionLimits(t *testing.T) {
+	reject := `{"accept": false, "feedback": "Try again."}`
+	answer := `{"action": "final_answer", "action_input": "draft"}`
+
+	// maxRevisions caps the revisions; the last answer is kept unreviewed.
+	critic := &scriptedCritic{critiques: []string{reject, reject, reject}}
+	llm := &scriptedLLM{responses: []string{answer, answer, answer}}
+	a := agent.New(llm, tools.NewRegistry(), agent.WithReflection(critic, 1))
+	result, err := a.Run(context.Background(), "task")
+	if err != nil || result.Revisions != 1 || critic.n != 1 || result.Iterations != 2 {
+		t.Errorf("Expected one revision, got %d (%d critiques, %v)", result.Revisions, critic.n, err)
+	}
+
+	// Revisions share MaxIterations, and the last iteration's answer stands.
+	critic = &scriptedCritic{critiques: []string{reject, reject, reject}}
+	llm = &scriptedLLM{responses: []string{answer, answer, answer}}
+	a = agent.New(llm, tools.NewRegistry(), agent.WithReflection(critic, 5), agent.WithMaxIterations(2))
+	result, err = a.Run(context.Background(), "task")
+	if err != nil || result.Output != "draft" || result.Iterations != 2 || result.Revisions != 1 {
+		t.Errorf("Expected the budget to end the revisions, got %+v (%v)", result, err)
+	}
+}
+
+func TestAgent_ReflectionUnparsableCritique(t *testing.T) {
+	critic := &scriptedCritic{critiques: []string{"Looks fine to me."}}
+	llm := &scriptedLLM{responses: []string{`{"action": "final_answer", "action_input": "done"}`}}
+	a := agent.New(llm, tools.NewRegistry(),
+		agent.WithReflection(critic, 2),
+		agent.WithCritiquePrompt("Task %s, answer %s. Verdict?"),
+	)
+
+	result, err := a.Run(context.Background(), "task")
+	if err != nil || result.Output != "done" {
+		t.Fatalf("Expected the answer kept, got %q (%v)", result.Output, err)
+	}
+	c := result.Critiques[0]
+	if !c.Accepted || c.Error == nil || c.Prompt != "Task task, answer done. Verdict?" || c.Response != "Looks fine to me." {
+		t.Errorf("Unexpected critique: %+v", c)
+	}
+}
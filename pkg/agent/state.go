@@ -0,0 +1,176 @@
+// Package agent provides persistence of agent conversations.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/nuulab/goflow/pkg/cache"
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+// StateVersion is the version of the format written by SaveState.
+const StateVersion = 1
+
+var (
+	// ErrStateVersion is returned by LoadState for state written in an
+	// unknown format version.
+	ErrStateVersion = errors.New("agent: unsupported state version")
+	// ErrStateNotFound is returned by a Store with no state for an ID.
+	ErrStateNotFound = errors.New("agent: state not found")
+)
+
+// SavedState is a serializable snapshot of an agent's conversation.
+type SavedState struct {
+	Version int `json:"version"`
+	// Messages is the conversation of the latest run.
+	Messages []core.Message `json:"messages"`
+	// Memory holds the memory's messages, and Summary the rolling summary
+	// of a SummaryMemory.
+	Memory  []core.Message `json:"memory"`
+	Summary string         `json:"summary,omitempty"`
+	// Iterations counts the think/act cycles of all runs.
+	Iterations int       `json:"iterations"`
+	SavedAt    time.Time `json:"saved_at"`
+}
+
+// Iterations returns the number of think/act cycles of all runs.
+func (a *Agent) Iterations() int {
+	return a.cycles
+}
+
+// SaveState serializes the agent's conversation, memory and iteration
+// count. Configuration, tools and hooks are not included; restore state
+// into an agent built the same way.
+func (a *Agent) SaveState() ([]byte, error) {
+	state := SavedState{
+		Version:    StateVersion,
+		Messages:   a.messages,
+		Memory:     a.memory.Get(),
+		Iterations: a.cycles,
+		SavedAt:    time.Now(),
+	}
+	if s, ok := a.memory.(*SummaryMemory); ok {
+		s.Wait()
+		state.Memory = s.Get()
+		state.Summary = s.GetSummary()
+	}
+	return json.Marshal(state)
+}
+
+// LoadState replaces the agent's conversation, memory and iteration count
+// with state from SaveState. Memory is cleared and refilled, so messages
+// are embedded again by a VectorMemory.
+func (a *Agent) LoadState(data []byte) error {
+	var state SavedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("agent: invalid state: %w", err)
+	}
+	if state.Version < 1 || state.Version > StateVersion {
+		return fmt.Errorf("%w %d", ErrStateVersion, state.Version)
+	}
+
+	a.messages = append([]core.Message{}, state.Messages...)
+	a.cycles = state.Iterations
+	a.memory.Clear()
+	if s, ok := a.memory.(*SummaryMemory); ok {
+		s.restore(state.Memory, state.Summary)
+		return nil
+	}
+	for _, m := range state.Memory {
+		a.memory.Add(m)
+	}
+	return nil
+}
+
+// restore sets the kept messages and summary without folding.
+func (s *SummaryMemory) restore(messages []core.Message, summary string) {
+	tokens := make([]int, len(messages))
+	for i, m := range messages {
+		tokens[i] = s.countTokens(m)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages[:0], messages...)
+	s.tokens = tokens
+	s.summary = summary
+}
+
+// ============ Stores ============
+
+// Store persists serialized agent state by agent ID.
+type Store interface {
+	// Save stores state under id, replacing any previous state.
+	Save(ctx context.Context, id string, state []byte) error
+	// Load returns the state stored under id, or ErrStateNotFound.
+	Load(ctx context.Context, id string) ([]byte, error)
+	// Delete removes the state stored under id.
+	Delete(ctx context.Context, id string) error
+}
+
+// CacheStore keeps agent state in a cache.Cache.
+type CacheStore struct {
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// NewCacheStore creates a store keeping state in c for ttl after each save.
+// A ttl of zero keeps it until it is deleted.
+func NewCacheStore(c cache.Cache, ttl time.Duration) *CacheStore {
+	return &CacheStore{cache: c, ttl: ttl}
+}
+
+func stateKey(id string) string { return "agents:state:" + id }
+
+// Save stores state under id.
+func (s *CacheStore) Save(ctx context.Context, id string, state []byte) error {
+	return s.cache.Set(ctx, stateKey(id), state, s.ttl)
+}
+
+// Load returns the state stored under id.
+func (s *CacheStore) Load(ctx context.Context, id string) ([]byte, error) {
+	data, err := s.cache.Get(ctx, stateKey(id))
+	if errors.Is(err, cache.ErrCacheMiss) {
+		return nil, ErrStateNotFound
+	}
+	return data, err
+}
+
+// Delete removes the state stored under id.
+func (s *CacheStore) Delete(ctx context.Context, id string) error {
+	return s.cache.Delete(ctx, stateKey(id))
+}
+
+// RedisStore keeps agent state in Redis/DragonflyDB without expiry.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore creates a store using client.
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Save stores state under id.
+func (s *RedisStore) Save(ctx context.Context, id string, state []byte) error {
+	return s.client.Set(ctx, "goflow:"+stateKey(id), state, 0).Err()
+}
+
+// Load returns the state stored under id.
+func (s *RedisStore) Load(ctx context.Context, id string) ([]byte, error) {
+	data, err := s.client.Get(ctx, "goflow:"+stateKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrStateNotFound
+	}
+	return data, err
+}
+
+// Delete removes the state stored under id.
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	return s.client.Del(ctx, "goflow:"+stateKey(id)).Err()
+}
@@ -0,0 +1,97 @@
+// Package agent_test provides tests for agent persistence.
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/cache"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestAgent_SaveAndLoadState(t *testing.T) {
+	first := &scriptedLLM{responses: []string{`{"action": "final_answer", "action_input": "Paris"}`}}
+	a := agent.New(first, tools.NewRegistry())
+	if _, err := a.Run(context.Background(), "capital of France?"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := a.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A new agent, as in another process, continues the conversation.
+	second := &scriptedLLM{responses: []string{`{"action": "final_answer", "action_input": "Berlin"}`}}
+	b := agent.New(second, tools.NewRegistry(), agent.WithHistory(10))
+	if err := b.LoadState(data); err != nil {
+		t.Fatal(err)
+	}
+	if b.Iterations() != 1 || len(b.GetMessages()) != len(a.GetMessages()) {
+		t.Errorf("Expected the iteration count and messages restored, got %d and %d messages", b.Iterations(), len(b.GetMessages()))
+	}
+	if _, err := b.Run(context.Background(), "and of Germany?"); err != nil {
+		t.Fatal(err)
+	}
+	sent := joined(second.calls[0])
+	if !strings.Contains(sent, "capital of France?") || !strings.Contains(sent, "Paris") {
+		t.Errorf("Expected the earlier turn in the prompt, got %s", sent)
+	}
+	if b.Iterations() != 2 {
+		t.Errorf("Expected 2 iterations, got %d", b.Iterations())
+	}
+}
+
+func TestAgent_LoadStateRejectsUnknownVersions(t *testing.T) {
+	a := agent.New(&scriptedLLM{}, tools.NewRegistry())
+	for _, data := range []string{`{}`, `{"version": 99}`} {
+		if err := a.LoadState([]byte(data)); !errors.Is(err, agent.ErrStateVersion) {
+			t.Errorf("%s: expected ErrStateVersion, got %v", data, err)
+		}
+	}
+	for _, data := range []string{"not json", `{"version": 1, "messages": 5}`} {
+		if err := a.LoadState([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", data)
+		}
+	}
+}
+
+func TestAgent_StateKeepsSummary(t *testing.T) {
+	llm := &summarizingLLM{}
+	mem := agent.NewSummaryMemory(llm, 2)
+	a := agent.New(llm, tools.NewRegistry(), agent.WithMemory(mem))
+	addMessages(mem, "m1", "m2", "m3")
+	data, err := a.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := agent.NewSummaryMemory(llm, 2)
+	b := agent.New(llm, tools.NewRegistry(), agent.WithMemory(restored))
+	if err := b.LoadState(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.GetSummary() != mem.GetSummary() || joined(restored.Get()) != "m2,m3" {
+		t.Errorf("Expected summary %q and m2,m3, got %q and %s", mem.GetSummary(), restored.GetSummary(), joined(restored.Get()))
+	}
+}
+
+func TestCacheStore(t *testing.T) {
+	ctx := context.Background()
+	store := agent.NewCacheStore(cache.NewMemoryCache(cache.Config{}), 0)
+	if _, err := store.Load(ctx, "a1"); !errors.Is(err, agent.ErrStateNotFound) {
+		t.Fatalf("Expected ErrStateNotFound, got %v", err)
+	}
+	if err := store.Save(ctx, "a1", []byte("state")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := store.Load(ctx, "a1"); err != nil || string(data) != "state" {
+		t.Errorf("Expected the saved state, got %q (%v)", data, err)
+	}
+	store.Delete(ctx, "a1")
+	if _, err := store.Load(ctx, "a1"); !errors.Is(err, agent.ErrStateNotFound) {
+		t.Errorf("Expected ErrStateNotFound after Delete, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+// Package agent provides token-level streaming of agent runs.
+package agent
+
+import (
+	"context"
+	"strings"
+
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+// StreamEventType identifies a streaming event.
+type StreamEventType string
+
+const (
+	// StreamToken carries a chunk of LLM output as it arrives.
+	StreamToken StreamEventType = "token"
+	// StreamAction is emitted once the LLM response is parsed into an action.
+	StreamAction StreamEventType = "action"
+	// StreamObservation carries the tool result for the preceding action.
+	StreamObservation StreamEventType = "observation"
+	// StreamDone ends a RunStream channel and carries the run result.
+	StreamDone StreamEventType = "done"
+)
+
+// StreamEvent is a single event emitted while an agent runs.
+type StreamEvent struct {
+	Type        StreamEventType `json:"type"`
+	Delta       string          `json:"delta,omitempty"`
+	Action      *AgentAction    `json:"action,omitempty"`
+	Observation string          `json:"observation,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Result      *RunResult      `json:"-"`
+}
+
+// StreamHandler receives stream events. Calls are made sequentially from
+// the goroutine running the agent and never after Run returns, so a handler
+// may write straight to an http.ResponseWriter.
+type StreamHandler func(StreamEvent)
+
+// WithStreamHandler streams LLM tokens, parsed actions and observations to h.
+// The agent uses StreamChat instead of GenerateChat, falling back to
+// GenerateChat when the LLM cannot stream.
+func WithStreamHandler(h StreamHandler) Option {
+	return func(a *Agent) {
+		a.stream = h
+	}
+}
+
+// RunStream runs the agent and returns its events on a channel. The last
+// event is StreamDone, carrying the RunResult, after which the channel is
+// closed. If ctx is cancelled the run stops mid-stream; events the caller
+// has not received by then may be dropped, but the channel is still closed.
+func (a *Agent) RunStream(ctx context.Context, task string) <-chan StreamEvent {
+	events := make(chan StreamEvent, 16)
+	send := func(ev StreamEvent) {
+		select {
+		case events <- ev:
+			return
+		default:
+		}
+		select {
+		case events <- ev:
+		case <-ctx.Done():
+		}
+	}
+
+	prev := a.stream
+	a.stream = send
+	go func() {
+		defer close(events)
+		defer func() { a.stream = prev }()
+
+		result, err := a.Run(ctx, task)
+		done := StreamEvent{Type: StreamDone, Result: result}
+		if err != nil {
+			done.Error = err.Error()
+		}
+		send(done)
+	}()
+	return events
+}
+
+func (a *Agent) emit(ev StreamEvent) {
+	if a.stream != nil {
+		a.stream(ev)
+	}
+}
+
+// generate returns the LLM response for the current conversation, streaming
+// tokens to the handler when one is set.
+func (a *Agent) generate(ctx context.Context, opts ...core.Option) (string, error) {
+	if a.stream == nil {
+		return a.llm.GenerateChat(ctx, a.messages, opts...)
+	}
+
+	events, err := a.streamEvents(ctx, opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		response, err := a.llm.GenerateChat(ctx, a.messages, opts...)
+		if err == nil {
+			a.emit(StreamEvent{Type: StreamToken, Delta: response})
+		}
+		return response, err
+	}
+
+	var sb strings.Builder
+	for {
+		select {
+		case <-ctx.Done():
+			// Unblock the producer so it can observe cancellation and exit.
+			go func() {
+				for range events {
+				}
+			}()
+			return "", ctx.Err()
+		case ev, ok := <-events:
+			if !ok || ev.Done {
+				if err := ctx.Err(); err != nil {
+					return "", err
+				}
+				return sb.String(), nil
+			}
+			if ev.Err != nil {
+				return "", ev.Err
+			}
+			sb.WriteString(ev.Content)
+			a.emit(StreamEvent{Type: StreamToken, Delta: ev.Content})
+		}
+	}
+}
+
+// streamEvents streams the conversation as events. Providers without
+// core.EventStreamer cannot report stream errors, so their chunks are
+// wrapped and a closed channel is taken as the end of the reply.
+func (a *Agent) streamEvents(ctx context.Context, opts []core.Option) (<-chan core.StreamEvent, error) {
+	if streamer, ok := a.llm.(core.EventStreamer); ok {
+		return streamer.StreamChatEvents(ctx, a.messages, opts...)
+	}
+
+	chunks, err := a.llm.StreamChat(ctx, a.messages, opts...)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan core.StreamEvent)
+	go func() {
+		defer close(events)
+		for chunk := range chunks {
+			events <- core.StreamEvent{Content: chunk}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,193 @@
+// Package agent_test provides tests for token-level agent streaming.
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+// streamingLLM streams each scripted response in fixed-size chunks.
+// A response of "" blocks until ctx is cancelled.
+type streamingLLM struct {
+	scriptedLLM
+	chunk int
+}
+
+func (s *streamingLLM) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	s.calls = append(s.calls, append([]core.Message{}, messages...))
+	response := s.responses[len(s.calls)-1]
+
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		if response == "" {
+			ch <- "thinking"
+			<-ctx.Done()
+			return
+		}
+		for len(response) > 0 {
+			n := min(s.chunk, len(response))
+			select {
+			case ch <- response[:n]:
+			case <-ctx.Done():
+				return
+			}
+			response = response[n:]
+		}
+	}()
+	return ch, nil
+}
+
+func collect(events <-chan agent.StreamEvent) (tokens string, types []agent.StreamEventType, done agent.StreamEvent) {
+	var sb strings.Builder
+	for ev := range events {
+		if ev.Type == agent.StreamToken {
+			sb.WriteString(ev.Delta)
+			continue
+		}
+		types = append(types, ev.Type)
+		done = ev
+	}
+	return sb.String(), types, done
+}
+
+func TestAgent_StreamHandler(t *testing.T) {
+	llm := &streamingLLM{scriptedLLM: scriptedLLM{responses: weatherScript}, chunk: 5}
+	var events []agent.StreamEvent
+	a := agent.New(llm, weatherRegistry(), agent.WithStreamHandler(func(ev agent.StreamEvent) {
+		events = append(events, ev)
+	}))
+
+	result, err := a.Run(context.Background(), "weather?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Output != "sunny" {
+		t.Errorf("Expected output 'sunny', got %q", result.Output)
+	}
+
+	var tokens strings.Builder
+	var kinds []agent.StreamEventType
+	deltas := 0
+	for _, ev := range events {
+		if ev.Type == agent.StreamToken {
+			tokens.WriteString(ev.Delta)
+			deltas++
+			continue
+		}
+		kinds = append(kinds, ev.Type)
+	}
+	if tokens.String() != strings.Join(weatherScript, "") {
+		t.Errorf("Tokens do not reassemble the responses: %q", tokens.String())
+	}
+	if deltas < 10 {
+		t.Errorf("Expected many small deltas, got %d", deltas)
+	}
+
+	want := []agent.StreamEventType{agent.StreamAction, agent.StreamObservation, agent.StreamAction}
+	if len(kinds) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, kinds)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("Expected events %v, got %v", want, kinds)
+		}
+	}
+	for _, ev := range events {
+		if ev.Type == agent.StreamObservation && ev.Observation != "sunny" {
+			t.Errorf("Expected observation 'sunny', got %q", ev.Observation)
+		}
+		if ev.Type == agent.StreamAction && ev.Action.Action == "" {
+			t.Error("Action event without action")
+		}
+	}
+}
+
+func TestAgent_RunStream(t *testing.T) {
+	llm := &streamingLLM{scriptedLLM: scriptedLLM{responses: weatherScript}, chunk: 8}
+	a := agent.New(llm, weatherRegistry())
+
+	tokens, types, done := collect(a.RunStream(context.Background(), "weather?"))
+	if tokens != strings.Join(weatherScript, "") {
+		t.Errorf("Unexpected tokens: %q", tokens)
+	}
+	if types[len(types)-1] != agent.StreamDone || done.Result == nil || done.Result.Output != "sunny" {
+		t.Fatalf("Expected done event with result, got %+v", done)
+	}
+	if done.Error != "" {
+		t.Errorf("Unexpected error: %s", done.Error)
+	}
+}
+
+func TestAgent_StreamFallsBackToGenerate(t *testing.T) {
+	llm := &scriptedLLM{responses: weatherScript}
+	var tokens []string
+	a := agent.New(llm, weatherRegistry(), agent.WithStreamHandler(func(ev agent.StreamEvent) {
+		if ev.Type == agent.StreamToken {
+			tokens = append(tokens, ev.Delta)
+		}
+	}))
+
+	if _, err := a.Run(context.Background(), "weather?"); err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || tokens[0] != weatherScript[0] {
+		t.Errorf("Expected whole responses as single deltas, got %q", tokens)
+	}
+}
+
+func TestAgent_StreamCancellation(t *testing.T) {
+	llm := &streamingLLM{scriptedLLM: scriptedLLM{responses: []string{""}}, chunk: 4}
+	ctx, cancel := context.WithCancel(context.Background())
+	a := agent.New(llm, weatherRegistry(), agent.WithStreamHandler(func(ev agent.StreamEvent) {
+		if ev.Type == agent.StreamToken {
+			cancel()
+		}
+	}))
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := a.Run(ctx, "weather?")
+		errc <- err
+	}()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not stop after cancellation mid-stream")
+	}
+}
+
+// failingStreamLLM streams a partial reply and then reports an error.
+type failingStreamLLM struct {
+	scriptedLLM
+}
+
+func (f *failingStreamLLM) StreamChatEvents(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan core.StreamEvent, error) {
+	events := make(chan core.StreamEvent, 2)
+	events <- core.StreamEvent{Content: `{"thought": "let me`}
+	events <- core.StreamEvent{Err: errors.New("upstream overloaded")}
+	close(events)
+	return events, nil
+}
+
+func TestAgent_StreamError(t *testing.T) {
+	a := agent.New(&failingStreamLLM{}, weatherRegistry(), agent.WithMaxIterations(1))
+
+	_, _, done := collect(a.RunStream(context.Background(), "weather?"))
+	if done.Result == nil || len(done.Result.Steps) != 1 {
+		t.Fatalf("Expected one step, got %+v", done)
+	}
+	if err := done.Result.Steps[0].Error; err == nil || !strings.Contains(err.Error(), "upstream overloaded") {
+		t.Errorf("Expected the stream error to fail the step, got %v", err)
+	}
+}
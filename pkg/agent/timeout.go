@@ -0,0 +1,76 @@
+// Package agent provides per-step and per-run time limits.
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrStepTimeout is returned when an LLM call or tool execution exceeds its
+// timeout. Use errors.As with *StepTimeoutError for details.
+var ErrStepTimeout = errors.New("agent: step timed out")
+
+// StepTimeoutError describes an LLM call or tool execution that ran out of
+// time while the run itself had time left.
+type StepTimeoutError struct {
+	Tool  string // empty for the LLM call
+	After time.Duration
+	Err   error
+}
+
+func (e *StepTimeoutError) Error() string {
+	if e.Tool == "" {
+		return fmt.Sprintf("agent: LLM call timed out after %s", e.After)
+	}
+	return fmt.Sprintf("agent: tool %q timed out after %s", e.Tool, e.After)
+}
+
+// Unwrap returns the error the call failed with.
+func (e *StepTimeoutError) Unwrap() error { return e.Err }
+
+// Is reports whether target is ErrStepTimeout.
+func (e *StepTimeoutError) Is(target error) bool {
+	return target == ErrStepTimeout
+}
+
+// WithTimeout bounds each run, including all of its steps.
+func WithTimeout(d time.Duration) Option {
+	return func(a *Agent) {
+		a.config.Timeout = d
+	}
+}
+
+// WithStepTimeout bounds the LLM call of each step. A call that times out
+// fails the step, and the next iteration calls the LLM again.
+func WithStepTimeout(d time.Duration) Option {
+	return func(a *Agent) {
+		a.config.StepTimeout = d
+	}
+}
+
+// WithToolTimeout bounds each tool execution. A tool that times out
+// produces an observation saying so, and the run continues.
+func WithToolTimeout(d time.Duration) Option {
+	return func(a *Agent) {
+		a.config.ToolTimeout = d
+	}
+}
+
+// withTimeout returns ctx bounded by d, if d is positive, and a function
+// that reports err as a *StepTimeoutError when the bound was hit while ctx
+// was still live.
+func withTimeout(ctx context.Context, d time.Duration, tool string) (context.Context, context.CancelFunc, func(error) error) {
+	if d <= 0 {
+		return ctx, func() {}, func(err error) error { return err }
+	}
+	bounded, cancel := context.WithTimeout(ctx, d)
+	timedOut := func(err error) error {
+		if err != nil && ctx.Err() == nil && errors.Is(bounded.Err(), context.DeadlineExceeded) {
+			return &StepTimeoutError{Tool: tool, After: d, Err: err}
+		}
+		return err
+	}
+	return bounded, cancel, timedOut
+}
@@ -0,0 +1,127 @@
+// Package agent_test provides tests for agent time limits.
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// stallingLLM blocks until its context is done for the first stalls calls,
+// then replays responses.
+type stallingLLM struct {
+	scriptedLLM
+	stalls int
+}
+
+func (l *stallingLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	if l.stalls > 0 {
+		l.stalls--
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	return l.scriptedLLM.GenerateChat(ctx, messages, opts...)
+}
+
+func TestAgent_ToolTimeout(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(tools.QuickTool("slow", "slow", func(ctx context.Context, input string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}))
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "slow", "action_input": "x"}`,
+		`{"action": "final_answer", "action_input": "gave up on slow"}`,
+	}}
+	a := agent.New(llm, registry, agent.WithToolTimeout(20*time.Millisecond))
+
+	result, err := a.Run(context.Background(), "task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := result.Steps[0]
+	if !step.TimedOut || !errors.Is(step.Error, agent.ErrStepTimeout) || step.Observation != "Tool 'slow' timed out after 20ms" {
+		t.Errorf("Unexpected step: %+v", step)
+	}
+	if fmt.Sprint(result.TimedOut) != "[0]" {
+		t.Errorf("Expected step 0 marked as timed out, got %v", result.TimedOut)
+	}
+	if sent := joined(llm.calls[1]); !strings.Contains(sent, "timed out after 20ms") {
+		t.Errorf("Expected the timeout observation sent to the LLM, got %s", sent)
+	}
+}
+
+func TestAgent_StepTimeout(t *testing.T) {
+	llm := &stallingLLM{stalls: 1, scriptedLLM: scriptedLLM{responses: []string{
+		`{"action": "final_answer", "action_input": "done"}`,
+	}}}
+	a := agent.New(llm, tools.NewRegistry(), agent.WithStepTimeout(20*time.Millisecond))
+
+	result, err := a.Run(context.Background(), "task")
+	if err != nil || result.Output != "done" {
+		t.Fatalf("Expected the run to recover, got %q (%v)", result.Output, err)
+	}
+	var timeout *agent.StepTimeoutError
+	if !result.Steps[0].TimedOut || !errors.As(result.Steps[0].Error, &timeout) || timeout.Tool != "" || timeout.After != 20*time.Millisecond {
+		t.Errorf("Unexpected first step: %+v", result.Steps[0])
+	}
+	if fmt.Sprint(result.TimedOut) != "[0]" {
+		t.Errorf("Expected step 0 marked as timed out, got %v", result.TimedOut)
+	}
+}
+
+func TestAgent_RunTimeout(t *testing.T) {
+	llm := &stallingLLM{stalls: 100}
+	a := agent.New(llm, tools.NewRegistry(),
+		agent.WithTimeout(30*time.Millisecond),
+		agent.WithStepTimeout(time.Minute),
+	)
+
+	result, err := a.Run(context.Background(), "task")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the run deadline, got %v", err)
+	}
+	if len(result.TimedOut) != 0 || errors.Is(result.Steps[0].Error, agent.ErrStepTimeout) {
+		t.Errorf("The run deadline must not count as a step timeout: %+v", result.Steps)
+	}
+}
+
+// cancellingLLM cancels the run during its first call but still answers.
+type cancellingLLM struct {
+	scriptedLLM
+	cancel context.CancelFunc
+}
+
+func (l *cancellingLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	l.cancel()
+	return `{"action": "slow", "action_input": "x"}`, nil
+}
+
+func TestAgent_CancelledRunStartsNoTools(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := false
+	registry := tools.NewRegistry()
+	registry.Register(tools.QuickTool("slow", "slow", func(ctx context.Context, input string) (string, error) {
+		ran = true
+		return "done", nil
+	}))
+	a := agent.New(&cancellingLLM{cancel: cancel}, registry)
+
+	result, err := a.Run(ctx, "task")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the run cancelled, got %v", err)
+	}
+	if ran {
+		t.Error("Expected the tool not to run after cancellation")
+	}
+	if len(result.Steps) != 1 || result.Steps[0].Action.Action != "slow" {
+		t.Errorf("Expected the interrupted step in the result, got %+v", result.Steps)
+	}
+}
@@ -0,0 +1,120 @@
+// Package agent provides native tool calling for providers that support it.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+const toolCallingSystemPrompt = `You are a helpful AI assistant that can use tools to accomplish tasks.
+
+Call the provided tools when you need them. When you have the final answer
+and no more tools are needed, reply with the answer as plain text.
+
+Think step by step about what tools you need to use and in what order.`
+
+// toolCaller returns the LLM as a core.ToolCallingLLM when native tool
+// calling is available and enabled.
+func (a *Agent) toolCaller() (core.ToolCallingLLM, bool) {
+	if a.config.DisableToolCalling {
+		return nil, false
+	}
+	llm, ok := a.llm.(core.ToolCallingLLM)
+	return llm, ok
+}
+
+// stepWithTools performs a step using the provider's native tool calling.
+// A reply without tool calls is the final answer.
+func (a *Agent) stepWithTools(ctx context.Context, llm core.ToolCallingLLM, result StepResult) (StepResult, error) {
+	a.hookLLMRequest(ctx)
+	llmCtx, cancel, timedOut := withTimeout(ctx, a.config.StepTimeout, "")
+	resp, err := llm.GenerateWithTools(llmCtx, a.messages, a.registry().ToolDefinitions(), a.callOptions(ctx, &result.Usage)...)
+	cancel()
+	if err = timedOut(err); err != nil {
+		a.hookLLMResponse(ctx, "", result.Usage, err)
+		result.TimedOut = errors.Is(err, ErrStepTimeout)
+		result.Error = fmt.Errorf("LLM generation failed: %w", err)
+		return result, result.Error
+	}
+	a.hookLLMResponse(ctx, resp.Content, result.Usage, nil)
+	if resp.Content != "" {
+		a.emit(StreamEvent{Type: StreamToken, Delta: resp.Content})
+	}
+
+	msg := core.Message{Role: core.RoleAssistant, Content: resp.Content}
+	if len(resp.ToolCalls) == 0 {
+		a.messages = append(a.messages, msg)
+		a.memory.Add(msg)
+
+		input, _ := json.Marshal(resp.Content)
+		result.Action = AgentAction{Action: "final_answer", ActionInput: input, RawResponse: resp.Content}
+		a.emit(StreamEvent{Type: StreamAction, Action: &result.Action})
+		result.IsFinal = true
+		result.Observation = resp.Content
+		return result, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		// Cancelled during the call; leave the tool calls unanswered.
+		result.Error = err
+		return result, err
+	}
+	if len(resp.ToolCalls) > 1 {
+		return a.stepWithToolCalls(ctx, msg, resp.ToolCalls, result)
+	}
+
+	call := resp.ToolCalls[0]
+	if call.Arguments == "" {
+		call.Arguments = "{}"
+	}
+	msg.ToolCalls = []core.ToolCall{call}
+	a.messages = append(a.messages, msg)
+	a.memory.Add(msg)
+
+	result.ToolCallID = call.ID
+	result.Action = AgentAction{
+		Action:      call.Name,
+		ActionInput: json.RawMessage(call.Arguments),
+		Thought:     resp.Content,
+		RawResponse: resp.Content,
+	}
+	a.emit(StreamEvent{Type: StreamAction, Action: &result.Action})
+	a.hookThought(ctx, resp.Content)
+
+	a.observe(ctx, &result)
+	return result, nil
+}
+
+// stepWithToolCalls executes the tool calls of a reply requesting several.
+func (a *Agent) stepWithToolCalls(ctx context.Context, msg core.Message, calls []core.ToolCall, result StepResult) (StepResult, error) {
+	actions := make([]AgentAction, len(calls))
+	ids := make([]string, len(calls))
+	for i := range calls {
+		if calls[i].Arguments == "" {
+			calls[i].Arguments = "{}"
+		}
+		ids[i] = calls[i].ID
+		actions[i] = AgentAction{
+			Action:      calls[i].Name,
+			ActionInput: json.RawMessage(calls[i].Arguments),
+			Thought:     msg.Content,
+			RawResponse: msg.Content,
+		}
+	}
+	msg.ToolCalls = calls
+	a.messages = append(a.messages, msg)
+	a.memory.Add(msg)
+
+	result.Action = actions[0]
+	for i := range actions {
+		a.emit(StreamEvent{Type: StreamAction, Action: &actions[i]})
+	}
+	a.hookThought(ctx, msg.Content)
+
+	a.observeAll(ctx, &result, actions, ids)
+	return result, nil
+}
@@ -0,0 +1,111 @@
+// Package agent_test provides tests for native tool calling.
+package agent_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+// toolCallingLLM replays native tool calling responses. Its text methods
+// come from an empty scriptedLLM and fail if the agent falls back to them.
+type toolCallingLLM struct {
+	scriptedLLM
+	replies []core.Response
+	tools   [][]core.ToolDefinition
+}
+
+func (t *toolCallingLLM) SupportsToolMessages() bool { return true }
+
+func (t *toolCallingLLM) GenerateWithTools(ctx context.Context, messages []core.Message, tools []core.ToolDefinition, opts ...core.Option) (*core.Response, error) {
+	t.calls = append(t.calls, append([]core.Message{}, messages...))
+	t.tools = append(t.tools, tools)
+	if len(t.calls) > len(t.replies) {
+		return nil, fmt.Errorf("no more responses")
+	}
+	return &t.replies[len(t.calls)-1], nil
+}
+
+var weatherCalls = []core.Response{
+	{Content: "Checking.", ToolCalls: []core.ToolCall{{ID: "call_abc", Name: "weather", Arguments: `{"city":"Paris"}`}}},
+	{Content: "It is sunny."},
+}
+
+func TestAgent_ToolCalling(t *testing.T) {
+	llm := &toolCallingLLM{replies: weatherCalls}
+	a := agent.New(llm, weatherRegistry())
+
+	result, err := a.Run(context.Background(), "weather?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Output != "It is sunny." {
+		t.Errorf("Expected plain-text final answer, got %q", result.Output)
+	}
+	if len(result.Steps) != 2 || result.Steps[0].Action.Action != "weather" || result.Steps[0].Observation != "sunny" {
+		t.Fatalf("Unexpected steps: %+v", result.Steps)
+	}
+	if result.Steps[0].ToolCallID != "call_abc" {
+		t.Errorf("Expected provider tool call ID, got %q", result.Steps[0].ToolCallID)
+	}
+
+	if len(llm.tools[0]) != 1 || llm.tools[0][0].Name != "weather" {
+		t.Errorf("Expected tools passed natively, got %+v", llm.tools[0])
+	}
+
+	second := llm.calls[1]
+	call, obs := second[len(second)-2], second[len(second)-1]
+	if len(call.ToolCalls) != 1 || call.ToolCalls[0].ID != "call_abc" || call.Content != "Checking." {
+		t.Errorf("Expected assistant tool call, got %+v", call)
+	}
+	if obs.Role != core.RoleTool || obs.ToolCallID != "call_abc" || obs.Content != "sunny" {
+		t.Errorf("Expected tool message, got %+v", obs)
+	}
+}
+
+func TestAgent_ToolCallingAllCalls(t *testing.T) {
+	llm := &toolCallingLLM{replies: []core.Response{
+		{ToolCalls: []core.ToolCall{
+			{ID: "call_1", Name: "weather"},
+			{ID: "call_2", Name: "weather", Arguments: `{"city":"Rome"}`},
+		}},
+		{Content: "done"},
+	}}
+	a := agent.New(llm, weatherRegistry())
+
+	result, err := a.Run(context.Background(), "weather?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := result.Steps[0].Calls; len(calls) != 2 || calls[1].ToolCallID != "call_2" || calls[1].Observation != "sunny" {
+		t.Errorf("Expected both calls in the step, got %+v", calls)
+	}
+
+	// The call message is followed by one tool message per call.
+	second := llm.calls[1]
+	call := second[len(second)-3]
+	if len(call.ToolCalls) != 2 || call.ToolCalls[0].Arguments != "{}" {
+		t.Errorf("Expected both calls with empty arguments defaulted, got %+v", call.ToolCalls)
+	}
+	for i, obs := range second[len(second)-2:] {
+		if obs.Role != core.RoleTool || obs.ToolCallID != fmt.Sprintf("call_%d", i+1) || obs.Content != "sunny" {
+			t.Errorf("Unexpected tool message %d: %+v", i, obs)
+		}
+	}
+}
+
+func TestAgent_ToolCallingDisabled(t *testing.T) {
+	llm := &toolCallingLLM{scriptedLLM: scriptedLLM{responses: weatherScript}}
+	a := agent.New(llm, weatherRegistry(), agent.WithToolCalling(false))
+
+	result, err := a.Run(context.Background(), "weather?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Output != "sunny" || len(llm.tools) != 0 {
+		t.Errorf("Expected JSON prompt mode, got output %q and %d native calls", result.Output, len(llm.tools))
+	}
+}
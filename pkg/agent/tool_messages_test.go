@@ -0,0 +1,103 @@
+// Package agent_test provides tests for native tool message threading.
+package agent_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// toolMessageLLM is a scriptedLLM that accepts role=tool messages.
+type toolMessageLLM struct{ scriptedLLM }
+
+func (t *toolMessageLLM) SupportsToolMessages() bool { return true }
+
+func weatherRegistry() *tools.Registry {
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{
+		Name:        "weather",
+		Description: "weather",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			return "sunny", nil
+		},
+	})
+	return registry
+}
+
+var weatherScript = []string{
+	`{"action": "weather", "action_input": {"city": "Paris"}}`,
+	`{"action": "final_answer", "action_input": "sunny"}`,
+}
+
+func TestAgent_NativeToolMessages(t *testing.T) {
+	llm := &toolMessageLLM{scriptedLLM{responses: weatherScript}}
+	a := agent.New(llm, weatherRegistry())
+
+	result, err := a.Run(context.Background(), "weather?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Steps[0].ToolCallID != "call_1" {
+		t.Errorf("Expected tool call ID on step, got %q", result.Steps[0].ToolCallID)
+	}
+
+	second := llm.calls[1]
+	call, obs := second[len(second)-2], second[len(second)-1]
+	if call.Role != core.RoleAssistant || len(call.ToolCalls) != 1 {
+		t.Fatalf("Expected assistant message with tool call, got %+v", call)
+	}
+	if tc := call.ToolCalls[0]; tc.ID != "call_1" || tc.Name != "weather" || tc.Arguments != `{"city": "Paris"}` {
+		t.Errorf("Unexpected tool call: %+v", tc)
+	}
+	if obs.Role != core.RoleTool || obs.ToolCallID != "call_1" || obs.Name != "weather" || obs.Content != "sunny" {
+		t.Errorf("Expected tool message, got %+v", obs)
+	}
+}
+
+func TestAgent_ObservationFallback(t *testing.T) {
+	llm := &scriptedLLM{responses: weatherScript}
+	a := agent.New(llm, weatherRegistry())
+
+	if _, err := a.Run(context.Background(), "weather?"); err != nil {
+		t.Fatal(err)
+	}
+
+	second := llm.calls[1]
+	obs := second[len(second)-1]
+	if obs.Role != core.RoleUser || obs.Content != "Observation: sunny" {
+		t.Errorf("Expected user observation, got %+v", obs)
+	}
+	if len(second[len(second)-2].ToolCalls) != 0 {
+		t.Error("Tool calls must not be recorded without tool message support")
+	}
+}
+
+func TestContextWindowGuard_ToolMessages(t *testing.T) {
+	guard := &agent.ContextWindowGuard{MaxTokens: 30, Counter: wordCounter{}}
+	msgs := []core.Message{
+		{Role: core.RoleSystem, Content: "system"},
+		{Role: core.RoleUser, Content: "task"},
+		{Role: core.RoleAssistant, Content: "a1", ToolCalls: []core.ToolCall{{ID: "call_1", Name: "fetch"}}},
+		{Role: core.RoleTool, ToolCallID: "call_1", Content: "one two three four five six"},
+		{Role: core.RoleAssistant, Content: "a2", ToolCalls: []core.ToolCall{{ID: "call_2", Name: "fetch"}}},
+		{Role: core.RoleTool, ToolCallID: "call_2", Content: "latest"},
+	}
+
+	out, report, err := guard.Fit(context.Background(), msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, out, "system", "task", "a1", "a2", "latest")
+	if len(out[2].ToolCalls) != 0 {
+		t.Error("Tool call without a result must be removed")
+	}
+	if len(out[3].ToolCalls) != 1 || out[4].ToolCallID != "call_2" {
+		t.Error("Latest tool exchange must be preserved")
+	}
+	if report.Dropped != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
@@ -0,0 +1,106 @@
+// Package agent provides tool visibility policies and documentation
+// meta-tools for agents.
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// ErrToolNotPermitted is returned for calls to registered tools that the
+// agent's tool policy hides.
+var ErrToolNotPermitted = errors.New("agent: tool not permitted for this agent")
+
+// DefaultToolDocsThreshold is the number of visible tools above which
+// agents are given the describe_tool and list_tools meta-tools.
+const DefaultToolDocsThreshold = 12
+
+const toolDocsInstruction = `
+
+Before calling a tool you have not used yet, call describe_tool with its
+name to get its parameters and an example input. Use list_tools to search
+the available tools by keyword.`
+
+// WithToolPolicy restricts the tools the agent can see and call. An empty
+// allow list allows every registered tool; deny wins over allow. Hidden
+// tools are left out of the prompt, the tool definitions and the
+// documentation meta-tools, and calls to them fail with
+// ErrToolNotPermitted.
+func WithToolPolicy(allow, deny []string) Option {
+	return func(a *Agent) {
+		a.config.AllowedTools = allow
+		a.config.DeniedTools = deny
+	}
+}
+
+// WithAllowedTools limits the agent to the named tools of its registry.
+func WithAllowedTools(names ...string) Option {
+	return func(a *Agent) {
+		a.config.AllowedTools = append(a.config.AllowedTools, names...)
+	}
+}
+
+// WithDeniedTools hides the named tools from the agent.
+func WithDeniedTools(names ...string) Option {
+	return func(a *Agent) {
+		a.config.DeniedTools = append(a.config.DeniedTools, names...)
+	}
+}
+
+// checkTool reports why name cannot be called through the visible
+// registry, if it cannot.
+func (a *Agent) checkTool(visible *tools.Registry, name string) error {
+	if _, ok := visible.Get(name); ok {
+		return nil
+	}
+	if _, ok := a.tools.Get(name); ok {
+		return fmt.Errorf("%w: %s", ErrToolNotPermitted, name)
+	}
+	return fmt.Errorf("unknown tool: %s", name)
+}
+
+// toolAllowed reports whether the policy lets the agent use name.
+func (a *Agent) toolAllowed(name string) bool {
+	if slices.Contains(a.config.DeniedTools, name) {
+		return false
+	}
+	return len(a.config.AllowedTools) == 0 || slices.Contains(a.config.AllowedTools, name)
+}
+
+// registry returns the tools visible to the agent, with read_artifact when
+// observations are capped. When there are more than the docs threshold,
+// describe_tool and list_tools are added so the model can look up the
+// tools it needs.
+func (a *Agent) registry() *tools.Registry {
+	var names []string
+	for _, tool := range a.tools.List() {
+		if a.toolAllowed(tool.Name) {
+			names = append(names, tool.Name)
+		}
+	}
+	visible, _ := a.tools.Subset(names...)
+	if a.config.MaxObservationSize > 0 && a.toolAllowed(ReadArtifactToolName) {
+		visible.Register(a.readArtifactTool())
+	}
+
+	threshold := a.config.ToolDocsThreshold
+	if threshold == 0 {
+		threshold = DefaultToolDocsThreshold
+	}
+	if threshold < 0 || len(names) <= threshold {
+		return visible
+	}
+	for _, meta := range []*tools.Tool{
+		tools.DescribeTool(a.tools, a.toolAllowed),
+		tools.ListTools(a.tools, a.toolAllowed),
+	} {
+		if a.toolAllowed(meta.Name) {
+			// Register fails if the registry already has its own version.
+			visible.Register(meta)
+		}
+	}
+	return visible
+}
@@ -0,0 +1,111 @@
+// Package agent_test provides tests for tool policies and documentation
+// meta-tools.
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// largeRegistry holds n tools named tool_0..tool_n-1 plus admin_delete.
+func largeRegistry(n int) *tools.Registry {
+	registry := tools.NewRegistry()
+	for i := 0; i < n; i++ {
+		registry.Register(&tools.Tool{
+			Name:        fmt.Sprintf("tool_%d", i),
+			Description: fmt.Sprintf("tool number %d", i),
+			Execute:     func(ctx context.Context, input string) (string, error) { return "ok", nil },
+		})
+	}
+	registry.Register(&tools.Tool{
+		Name:        "admin_delete",
+		Description: "Delete everything",
+		Execute:     func(ctx context.Context, input string) (string, error) { return "deleted", nil },
+	})
+	return registry
+}
+
+func TestAgent_ToolPolicy(t *testing.T) {
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "admin_delete", "action_input": {}}`,
+		`{"action": "describe_tool", "action_input": {"name": "admin_delete"}}`,
+		`{"action": "list_tools", "action_input": {"query": "delete"}}`,
+		`{"action": "final_answer", "action_input": "done"}`,
+	}}
+	a := agent.New(llm, largeRegistry(agent.DefaultToolDocsThreshold+1), agent.WithToolPolicy(nil, []string{"admin_delete"}))
+
+	result, err := a.Run(context.Background(), "clean up")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	system := llm.calls[0][0].Content
+	if strings.Contains(system, "admin_delete") {
+		t.Error("Denied tool listed in the system prompt")
+	}
+	if !strings.Contains(system, "call describe_tool") || !strings.Contains(system, "- list_tools:") {
+		t.Errorf("Expected the meta-tools to be offered and explained:\n%s", system)
+	}
+
+	for i, step := range result.Steps[:2] {
+		if step.Error == nil || strings.Contains(step.Observation, "Delete everything") || strings.Contains(step.Observation, "deleted") {
+			t.Errorf("step %d: denied tool leaked: %q", i, step.Observation)
+		}
+	}
+	if !strings.Contains(result.Steps[2].Observation, "[]") {
+		t.Errorf("Expected an empty search result, got %q", result.Steps[2].Observation)
+	}
+}
+
+func TestAgent_ToolDocsThreshold(t *testing.T) {
+	llm := &toolCallingLLM{replies: []core.Response{{Content: "done"}}}
+	a := agent.New(llm, largeRegistry(3), agent.WithToolPolicy([]string{"tool_0", "tool_1", "admin_delete"}, []string{"admin_delete"}))
+	if _, err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, def := range llm.tools[0] {
+		names = append(names, def.Name)
+	}
+	if len(names) != 2 || strings.Contains(strings.Join(names, ","), "admin") || strings.Contains(strings.Join(names, ","), "describe") {
+		t.Errorf("Expected only the allowed tools and no meta-tools, got %v", names)
+	}
+	if strings.Contains(llm.calls[0][0].Content, "describe_tool") {
+		t.Error("Small registries should not mention describe_tool")
+	}
+}
+
+func TestAgent_ToolNotPermitted(t *testing.T) {
+	llm := &scriptedLLM{responses: []string{
+		`{"action": "admin_delete", "action_input": {}}`,
+		`[{"action": "tool_1", "action_input": {}}, {"action": "tool_0", "action_input": {}}]`,
+		`{"action": "final_answer", "action_input": "done"}`,
+	}}
+	a := agent.New(llm, largeRegistry(2),
+		agent.WithAllowedTools("tool_0", "admin_delete"),
+		agent.WithDeniedTools("admin_delete"),
+	)
+
+	result, err := a.Run(context.Background(), "clean up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if system := llm.calls[0][0].Content; strings.Contains(system, "admin_delete") || strings.Contains(system, "tool_1") {
+		t.Errorf("Hidden tools listed in the system prompt:\n%s", system)
+	}
+	if step := result.Steps[0]; !errors.Is(step.Error, agent.ErrToolNotPermitted) || !strings.Contains(step.Observation, "tool not permitted for this agent") {
+		t.Errorf("Unexpected step for a denied tool: %+v", step)
+	}
+	calls := result.Steps[1].Calls
+	if !errors.Is(calls[0].Error, agent.ErrToolNotPermitted) || calls[1].Error != nil || calls[1].Observation != "ok" {
+		t.Errorf("Expected only the allowed call to run, got %+v", calls)
+	}
+}
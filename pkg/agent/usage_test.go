@@ -0,0 +1,44 @@
+package agent_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+// usageLLM reports a fixed usage for every chat call.
+type usageLLM struct {
+	scriptedLLM
+	usage core.Usage
+}
+
+func (u *usageLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	core.ReportUsage(opts, u.usage)
+	return u.scriptedLLM.GenerateChat(ctx, messages, opts...)
+}
+
+func TestAgent_UsageTotals(t *testing.T) {
+	llm := &usageLLM{
+		scriptedLLM: scriptedLLM{responses: weatherScript},
+		usage:       core.Usage{Provider: "openai", Model: "gpt-4o", PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120},
+	}
+
+	result, err := agent.New(llm, weatherRegistry()).Run(context.Background(), "weather?")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := len(result.Steps)
+	if steps < 2 {
+		t.Fatalf("Expected a tool step and a final step, got %d", steps)
+	}
+	if result.TotalPromptTokens != 100*steps || result.TotalCompletionTokens != 20*steps {
+		t.Errorf("Unexpected totals: prompt=%d completion=%d over %d steps",
+			result.TotalPromptTokens, result.TotalCompletionTokens, steps)
+	}
+	if got := result.Steps[0].Usage; got != llm.usage {
+		t.Errorf("Expected step usage %+v, got %+v", llm.usage, got)
+	}
+}
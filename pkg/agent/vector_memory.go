@@ -0,0 +1,320 @@
+// Package agent provides semantic recall of past messages.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+// defaultRecall is the number of messages Recall and GetContext recall.
+const defaultRecall = 5
+
+// VectorRecord is a message stored with its embedding. Embedding is nil if
+// the message could not be embedded.
+type VectorRecord struct {
+	Message   core.Message `json:"message"`
+	Embedding []float32    `json:"embedding,omitempty"`
+}
+
+// VectorMatch is a record found by a similarity search.
+type VectorMatch struct {
+	VectorRecord
+	Score float64 `json:"score"`
+}
+
+// VectorStore stores embedded messages for VectorMemory.
+type VectorStore interface {
+	// Add appends a record.
+	Add(ctx context.Context, record VectorRecord) error
+	// Search returns up to k records most similar to query, best first.
+	// Records without an embedding are never returned.
+	Search(ctx context.Context, query []float32, k int) ([]VectorMatch, error)
+	// All returns every record in the order it was added.
+	All(ctx context.Context) ([]VectorRecord, error)
+	// Clear removes all records.
+	Clear(ctx context.Context) error
+}
+
+// VectorMemory embeds every message and recalls the past messages most
+// relevant to a query rather than the most recent ones.
+type VectorMemory struct {
+	mu       sync.RWMutex
+	embedder core.Embedder
+	store    VectorStore
+	recall   int
+	last     string // content of the latest message, the default query
+	err      error
+}
+
+// NewVectorMemory creates a memory that embeds messages with embedder and
+// keeps them in store. A nil store keeps them in memory.
+func NewVectorMemory(embedder core.Embedder, store VectorStore) *VectorMemory {
+	if store == nil {
+		store = NewMemoryVectorStore()
+	}
+	return &VectorMemory{embedder: embedder, store: store, recall: defaultRecall}
+}
+
+// SetRecall sets how many messages Recall and GetContext recall. The
+// default is 5.
+func (v *VectorMemory) SetRecall(k int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.recall = k
+}
+
+// Add embeds and stores a message. A message that cannot be embedded is
+// still stored, but is never recalled; the error is reported by Err.
+func (v *VectorMemory) Add(message core.Message) {
+	ctx := context.Background()
+	record := VectorRecord{Message: message}
+	embeddings, err := v.embedder.Embed(ctx, []string{message.Content})
+	if err == nil && len(embeddings) == 1 {
+		record.Embedding = embeddings[0]
+	} else if err == nil {
+		err = fmt.Errorf("agent: embedder returned %d embeddings for 1 message", len(embeddings))
+	}
+	if storeErr := v.store.Add(ctx, record); storeErr != nil {
+		err = storeErr
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.last = message.Content
+	if err != nil {
+		v.err = err
+	}
+}
+
+// Retrieve returns up to k stored messages most relevant to query, most
+// relevant first.
+func (v *VectorMemory) Retrieve(ctx context.Context, query string, k int) ([]core.Message, error) {
+	embedding, err := v.embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("agent: embedding query: %w", err)
+	}
+	matches, err := v.store.Search(ctx, embedding, k)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]core.Message, len(matches))
+	for i, m := range matches {
+		messages[i] = m.Message
+	}
+	return messages, nil
+}
+
+// Recall returns the messages most relevant to query, up to the count set
+// with SetRecall. Errors are also reported by Err.
+func (v *VectorMemory) Recall(ctx context.Context, query string) ([]core.Message, error) {
+	v.mu.RLock()
+	k := v.recall
+	v.mu.RUnlock()
+	messages, err := v.Retrieve(ctx, query, k)
+	if err != nil {
+		v.setErr(err)
+	}
+	return messages, err
+}
+
+// Get returns all stored messages in the order they were added.
+func (v *VectorMemory) Get() []core.Message {
+	records, err := v.store.All(context.Background())
+	if err != nil {
+		v.setErr(err)
+		return nil
+	}
+	messages := make([]core.Message, len(records))
+	for i, r := range records {
+		messages[i] = r.Message
+	}
+	return messages
+}
+
+// GetContext formats the messages most relevant to the latest message.
+func (v *VectorMemory) GetContext() string {
+	v.mu.RLock()
+	query := v.last
+	v.mu.RUnlock()
+	if query == "" {
+		return ""
+	}
+
+	messages, err := v.Recall(context.Background(), query)
+	if err != nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("Relevant past messages:\n")
+	for _, msg := range messages {
+		sb.WriteString(formatMessage(msg))
+	}
+	return sb.String()
+}
+
+// Clear removes all messages.
+func (v *VectorMemory) Clear() {
+	err := v.store.Clear(context.Background())
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.last = ""
+	v.err = err
+}
+
+// Err returns the last error from embedding or the store, if any.
+func (v *VectorMemory) Err() error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.err
+}
+
+func (v *VectorMemory) setErr(err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.err = err
+}
+
+// cosine returns the cosine similarity of a and b, or 0 if their lengths
+// differ or either is zero.
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// topK scores records against query and returns the best k.
+func topK(records []VectorRecord, query []float32, k int) []VectorMatch {
+	var matches []VectorMatch
+	for _, r := range records {
+		if r.Embedding == nil {
+			continue
+		}
+		matches = append(matches, VectorMatch{VectorRecord: r, Score: cosine(r.Embedding, query)})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if k >= 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches
+}
+
+// ============ Memory Store ============
+
+// MemoryVectorStore keeps records in memory and searches them by cosine
+// similarity.
+type MemoryVectorStore struct {
+	mu      sync.RWMutex
+	records []VectorRecord
+}
+
+// NewMemoryVectorStore creates an empty in-memory store.
+func NewMemoryVectorStore() *MemoryVectorStore {
+	return &MemoryVectorStore{}
+}
+
+// Add appends a record.
+func (s *MemoryVectorStore) Add(ctx context.Context, record VectorRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+// Search returns up to k records most similar to query.
+func (s *MemoryVectorStore) Search(ctx context.Context, query []float32, k int) ([]VectorMatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return topK(s.records, query, k), nil
+}
+
+// All returns every record in insertion order.
+func (s *MemoryVectorStore) All(ctx context.Context) ([]VectorRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]VectorRecord{}, s.records...), nil
+}
+
+// Clear removes all records.
+func (s *MemoryVectorStore) Clear(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = nil
+	return nil
+}
+
+// ============ Redis Store ============
+
+// RedisVectorStore keeps records in a Redis/DragonflyDB list, so memory
+// survives restarts and can be shared by processes. Search scores every
+// record client-side, which suits per-agent histories of up to tens of
+// thousands of messages.
+type RedisVectorStore struct {
+	client *redis.Client
+	key    string
+}
+
+// NewRedisVectorStore creates a store keeping records under key, such as
+// one key per agent or session. Use the cache's client, for example
+// cache.DragonflyCache.Client().
+func NewRedisVectorStore(client *redis.Client, key string) *RedisVectorStore {
+	return &RedisVectorStore{client: client, key: "goflow:memory:" + key}
+}
+
+// Add appends a record to the list.
+func (s *RedisVectorStore) Add(ctx context.Context, record VectorRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.client.RPush(ctx, s.key, data).Err()
+}
+
+// Search returns up to k records most similar to query.
+func (s *RedisVectorStore) Search(ctx context.Context, query []float32, k int) ([]VectorMatch, error) {
+	records, err := s.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return topK(records, query, k), nil
+}
+
+// All returns every record in insertion order.
+func (s *RedisVectorStore) All(ctx context.Context) ([]VectorRecord, error) {
+	items, err := s.client.LRange(ctx, s.key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]VectorRecord, 0, len(items))
+	for i, item := range items {
+		var r VectorRecord
+		if err := json.Unmarshal([]byte(item), &r); err != nil {
+			return nil, fmt.Errorf("agent: invalid memory record %d: %w", i, err)
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// Clear deletes the list.
+func (s *RedisVectorStore) Clear(ctx context.Context) error {
+	return s.client.Del(ctx, s.key).Err()
+}
@@ -0,0 +1,50 @@
+//go:build integration
+
+// Package agent_test runs the vector store against Redis. Set
+// GOFLOW_TEST_REDIS_ADDR and run with -tags integration.
+package agent_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+func TestRedisVectorStore(t *testing.T) {
+	addr := os.Getenv("GOFLOW_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("GOFLOW_TEST_REDIS_ADDR not set")
+	}
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	store := agent.NewRedisVectorStore(client, fmt.Sprintf("test-%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		store.Clear(ctx)
+		client.Close()
+	})
+
+	mem := agent.NewVectorMemory(topicEmbedder{}, store)
+	mem.Add(core.Message{Role: core.RoleUser, Content: "billing invoice"})
+	mem.Add(core.Message{Role: core.RoleUser, Content: "kubernetes deploy"})
+	if err := mem.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := mem.Retrieve(ctx, "deploy", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Content != "kubernetes deploy" {
+		t.Errorf("Unexpected recall: %+v", got)
+	}
+	if all := mem.Get(); len(all) != 2 || all[0].Content != "billing invoice" {
+		t.Errorf("Expected both messages in order, got %+v", all)
+	}
+}
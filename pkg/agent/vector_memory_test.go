@@ -0,0 +1,110 @@
+// Package agent_test provides tests for vector memory.
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// topicEmbedder embeds text as counts of a few topic words.
+type topicEmbedder struct{ fail string }
+
+var topics = []string{"billing", "invoice", "deploy", "kubernetes", "lunch"}
+
+func (e topicEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		if e.fail != "" && strings.Contains(text, e.fail) {
+			return nil, errors.New("embedding failed")
+		}
+		v, _ := e.EmbedQuery(ctx, text)
+		out[i] = v
+	}
+	return out, nil
+}
+
+func (e topicEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	v := make([]float32, len(topics))
+	for i, topic := range topics {
+		v[i] = float32(strings.Count(strings.ToLower(query), topic))
+	}
+	return v, nil
+}
+
+func TestVectorMemory_Retrieve(t *testing.T) {
+	mem := agent.NewVectorMemory(topicEmbedder{}, nil)
+	addMessages(mem,
+		"The billing invoice for March was wrong",
+		"Deploy the api to kubernetes",
+		"What's for lunch?",
+		"Kubernetes deploy failed again",
+		"Lunch was pizza",
+	)
+
+	got, err := mem.Retrieve(context.Background(), "resend the invoice for billing", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !strings.Contains(got[0].Content, "invoice") {
+		t.Errorf("Expected the billing message first, got %s", joined(got))
+	}
+
+	got, _ = mem.Retrieve(context.Background(), "kubernetes deploy", 2)
+	if joined(got) != "Deploy the api to kubernetes,Kubernetes deploy failed again" {
+		t.Errorf("Expected the deploy messages, got %s", joined(got))
+	}
+
+	if all := mem.Get(); len(all) != 5 || all[0].Content != "The billing invoice for March was wrong" {
+		t.Errorf("Expected every message in order, got %s", joined(all))
+	}
+	mem.SetRecall(2)
+	if ctx := mem.GetContext(); !strings.Contains(ctx, "What's for lunch?") || strings.Contains(ctx, "billing") {
+		t.Errorf("Expected the context recalled for the latest message, got %q", ctx)
+	}
+
+	mem.Clear()
+	if len(mem.Get()) != 0 || mem.GetContext() != "" {
+		t.Error("Expected Clear to remove all messages")
+	}
+}
+
+func TestVectorMemory_EmbeddingFailure(t *testing.T) {
+	mem := agent.NewVectorMemory(topicEmbedder{fail: "secret"}, agent.NewMemoryVectorStore())
+	mem.Add(core.Message{Role: core.RoleUser, Content: "deploy the secret build"})
+	mem.Add(core.Message{Role: core.RoleUser, Content: "deploy again"})
+
+	if mem.Err() == nil {
+		t.Error("Expected the embedding error to be reported")
+	}
+	if len(mem.Get()) != 2 {
+		t.Errorf("Expected the message stored anyway, got %s", joined(mem.Get()))
+	}
+	got, _ := mem.Retrieve(context.Background(), "deploy", 5)
+	if joined(got) != "deploy again" {
+		t.Errorf("Expected only embedded messages recalled, got %s", joined(got))
+	}
+}
+
+func TestVectorMemory_AgentRecallsForTask(t *testing.T) {
+	mem := agent.NewVectorMemory(topicEmbedder{}, nil)
+	addMessages(mem,
+		"The billing invoice for March was wrong",
+		"Deploy the api to kubernetes",
+		"What's for lunch?",
+		"Lunch was pizza",
+	)
+	mem.SetRecall(1)
+
+	llm := &scriptedLLM{responses: []string{`{"action": "final_answer", "action_input": "done"}`}}
+	a := agent.New(llm, tools.NewRegistry(), agent.WithMemory(mem))
+	if _, err := a.Run(context.Background(), "resend the billing invoice"); err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, llm.calls[0][1:], "The billing invoice for March was wrong", "resend the billing invoice")
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultSlowRequestThreshold is the latency above which requests are
+// logged at warn level.
+const DefaultSlowRequestThreshold = 2 * time.Second
+
+// AccessLogConfig configures per-request access logging.
+type AccessLogConfig struct {
+	// SlowThreshold is the latency above which a request is logged at warn
+	// level with extra detail. Zero uses DefaultSlowRequestThreshold.
+	SlowThreshold time.Duration
+	// SampledRoutes maps route templates (see Route) to the fraction of GET
+	// requests that are logged, for high-volume read routes. Failed and
+	// slow requests are always logged.
+	SampledRoutes map[string]float64
+	// ExcludePaths are never logged. The default excludes the health checks.
+	ExcludePaths []string
+}
+
+// DefaultAccessLogConfig returns the default access log configuration.
+func DefaultAccessLogConfig() *AccessLogConfig {
+	return &AccessLogConfig{
+		SlowThreshold: DefaultSlowRequestThreshold,
+		ExcludePaths:  []string{"/health", "/api/llm/health"},
+	}
+}
+
+// accessRecord collects request details that are only known to handlers.
+type accessRecord struct {
+	agentID string
+	runID   string
+}
+
+type accessRecordKey struct{}
+
+type identityKey struct{}
+
+type identity struct {
+	keyID  string
+	tenant string
+}
+
+// WithIdentity returns a context carrying the API key ID and tenant of the
+// caller. Authentication middleware in front of Server.Handler uses it so
+// access log records can be attributed.
+func WithIdentity(ctx context.Context, keyID, tenant string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity{keyID: keyID, tenant: tenant})
+}
+
+// annotateAgent records the agent a request operates on.
+func annotateAgent(ctx context.Context, agentID string) {
+	if rec, ok := ctx.Value(accessRecordKey{}).(*accessRecord); ok {
+		rec.agentID = agentID
+	}
+}
+
+// annotateRun records the run a request created.
+func annotateRun(ctx context.Context, runID string) {
+	if rec, ok := ctx.Value(accessRecordKey{}).(*accessRecord); ok {
+		rec.runID = runID
+	}
+}
+
+// statusRecorder captures the status code and body size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("api: response does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Route returns the route template mux matched for r, such as
+// "/api/agents/{path...}", or "unmatched".
+func Route(mux *http.ServeMux, r *http.Request) string {
+	_, pattern := mux.Handler(r)
+	switch {
+	case pattern == "":
+		return "unmatched"
+	case strings.HasSuffix(pattern, "/") && len(r.URL.Path) > len(pattern):
+		return pattern + "{path...}"
+	}
+	return pattern
+}
+
+// accessLog logs one record per request through the server's logger.
+// WebSocket connections log their own open and close records instead.
+func (s *Server) accessLog(mux *http.ServeMux, next http.Handler) http.Handler {
+	if s.logger == nil {
+		return next
+	}
+	cfg := s.logConfig
+	slow := cfg.SlowThreshold
+	if slow <= 0 {
+		slow = DefaultSlowRequestThreshold
+	}
+	excluded := make(map[string]bool, len(cfg.ExcludePaths))
+	for _, p := range cfg.ExcludePaths {
+		excluded[p] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if excluded[r.URL.Path] || r.URL.Path == "/ws" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		route := Route(mux, r)
+		record := &accessRecord{}
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), accessRecordKey{}, record)))
+		latency := time.Since(start)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		isSlow := latency > slow
+		if rate, ok := cfg.SampledRoutes[route]; ok && r.Method == "GET" && status < 400 && !isSlow && rand.Float64() >= rate {
+			return
+		}
+
+		args := []any{
+			"method", r.Method,
+			"route", route,
+			"status", status,
+			"latency_ms", latency.Milliseconds(),
+			"bytes", rec.bytes,
+		}
+		if id, ok := r.Context().Value(identityKey{}).(identity); ok {
+			args = append(args, "api_key_id", id.keyID, "tenant", id.tenant)
+		}
+		if record.agentID != "" {
+			args = append(args, "agent_id", record.agentID)
+		}
+		if record.runID != "" {
+			args = append(args, "run_id", record.runID)
+		}
+		if class := errorClass(status); class != "" {
+			args = append(args, "error_class", class)
+		}
+
+		switch {
+		case status >= 500:
+			s.logger.Error("http request", args...)
+		case isSlow:
+			args = append(args,
+				"path", r.URL.Path,
+				"query", r.URL.RawQuery,
+				"remote_addr", r.RemoteAddr,
+				"user_agent", r.UserAgent(),
+				"request_bytes", r.ContentLength,
+				"slow_threshold_ms", slow.Milliseconds(),
+			)
+			s.logger.Warn("slow http request", args...)
+		default:
+			s.logger.Info("http request", args...)
+		}
+	})
+}
+
+// errorClass buckets failed responses so they can be counted by cause.
+func errorClass(status int) string {
+	switch {
+	case status < 400:
+		return ""
+	case status == http.StatusBadRequest, status == http.StatusUnprocessableEntity:
+		return "invalid_request"
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return "auth"
+	case status == http.StatusNotFound:
+		return "not_found"
+	case status == http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case status == http.StatusConflict:
+		return "conflict"
+	case status == http.StatusRequestEntityTooLarge:
+		return "too_large"
+	case status == http.StatusTooManyRequests:
+		return "rate_limited"
+	case status == http.StatusServiceUnavailable:
+		return "unavailable"
+	case status == http.StatusGatewayTimeout:
+		return "timeout"
+	case status >= 500:
+		return "internal"
+	}
+	return "client_error"
+}
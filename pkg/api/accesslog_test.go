@@ -0,0 +1,139 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// logRecords decodes the JSON lines written by a slog.JSONHandler.
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("Invalid log line %q: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	buf.Reset()
+	return records
+}
+
+func newLoggedServer(cfg api.Config) (http.Handler, *bytes.Buffer) {
+	var buf bytes.Buffer
+	cfg.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	return api.NewServer(cfg).Handler(), &buf
+}
+
+func TestAccessLog_Fields(t *testing.T) {
+	h, buf := newLoggedServer(api.Config{})
+
+	req := httptest.NewRequest("POST", "/api/agents", strings.NewReader(`{"id": "support"}`))
+	req = req.WithContext(api.WithIdentity(req.Context(), "key-1", "acme"))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", rec.Code)
+	}
+
+	records := logRecords(t, buf)
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %v", records)
+	}
+	r := records[0]
+	for _, field := range []string{"time", "latency_ms", "bytes"} {
+		if _, ok := r[field]; !ok {
+			t.Errorf("Missing field %q in %v", field, r)
+		}
+	}
+	want := map[string]any{
+		"level": "INFO", "msg": "http request", "method": "POST", "route": "/api/agents",
+		"status": float64(201), "api_key_id": "key-1", "tenant": "acme", "agent_id": "support",
+	}
+	for k, v := range want {
+		if r[k] != v {
+			t.Errorf("%s = %v, want %v", k, r[k], v)
+		}
+	}
+	if r["bytes"].(float64) != float64(rec.Body.Len()) {
+		t.Errorf("bytes = %v, body was %d", r["bytes"], rec.Body.Len())
+	}
+	if _, ok := r["error_class"]; ok {
+		t.Errorf("Unexpected error_class on success: %v", r)
+	}
+
+	do(t, h, "GET", "/api/agents/missing/bogus", nil, nil)
+	r = logRecords(t, buf)[0]
+	if r["route"] != "/api/agents/{path...}" || r["error_class"] != "not_found" || r["agent_id"] != "missing" {
+		t.Errorf("Unexpected record for unknown action: %v", r)
+	}
+}
+
+func TestAccessLog_RunCorrelation(t *testing.T) {
+	engine := workflow.NewEngine(nil)
+	engine.Register(workflow.New("noop").
+		Step("one", func(ctx context.Context, state *workflow.State) (any, error) { return nil, nil }).Then().
+		Build())
+	h, buf := newLoggedServer(api.Config{Engine: engine})
+
+	rec := do(t, h, "POST", "/api/workflows/noop/run", nil, nil)
+	var body map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &body)
+
+	r := logRecords(t, buf)[0]
+	if r["run_id"] == nil || r["run_id"] != body["state_id"] {
+		t.Errorf("run_id = %v, want %q", r["run_id"], body["state_id"])
+	}
+}
+
+func TestAccessLog_ExclusionsAndSampling(t *testing.T) {
+	h, buf := newLoggedServer(api.Config{
+		AccessLog: &api.AccessLogConfig{
+			ExcludePaths:  []string{"/health"},
+			SampledRoutes: map[string]float64{"/api/agents": 0},
+		},
+	})
+
+	do(t, h, "GET", "/health", nil, nil)
+	do(t, h, "GET", "/api/agents", nil, nil)
+	if records := logRecords(t, buf); len(records) != 0 {
+		t.Fatalf("Expected excluded and unsampled requests to be skipped, got %v", records)
+	}
+
+	// Writes and failures on a sampled route are always logged.
+	do(t, h, "POST", "/api/agents", map[string]string{"id": "a"}, nil)
+	do(t, h, "POST", "/api/agents", map[string]string{"id": "a"}, nil)
+	records := logRecords(t, buf)
+	if len(records) != 2 || records[1]["error_class"] != "conflict" {
+		t.Errorf("Unexpected records: %v", records)
+	}
+}
+
+func TestAccessLog_SlowRequest(t *testing.T) {
+	h, buf := newLoggedServer(api.Config{
+		AccessLog: &api.AccessLogConfig{SlowThreshold: time.Nanosecond},
+	})
+
+	do(t, h, "GET", "/api/settings?verbose=1", nil, map[string]string{"User-Agent": "probe"})
+	r := logRecords(t, buf)[0]
+	if r["level"] != "WARN" || r["msg"] != "slow http request" {
+		t.Fatalf("Expected warn record, got %v", r)
+	}
+	if r["query"] != "verbose=1" || r["user_agent"] != "probe" || r["slow_threshold_ms"] == nil {
+		t.Errorf("Missing slow request detail: %v", r)
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/nuulab/goflow/pkg/metrics/alerts"
+)
+
+// handleAlerts handles GET/POST /api/alerts.
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if s.alerts == nil {
+		writeError(w, http.StatusServiceUnavailable, "alerts not configured")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		rules := s.alerts.List()
+		writeJSON(w, http.StatusOK, map[string]any{
+			"alerts": rules,
+			"count":  len(rules),
+		})
+	case "POST":
+		var rule alerts.Rule
+		if !s.decodeJSON(w, r, &rule) {
+			return
+		}
+		if err := s.alerts.Add(rule); err != nil {
+			writeAlertError(w, err)
+			return
+		}
+		status, _ := s.alerts.Get(rule.Name)
+		writeJSON(w, http.StatusCreated, status)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAlert handles GET/PUT/DELETE /api/alerts/:name.
+func (s *Server) handleAlert(w http.ResponseWriter, r *http.Request) {
+	if s.alerts == nil {
+		writeError(w, http.StatusServiceUnavailable, "alerts not configured")
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/api/alerts/")
+	if name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound, "unknown action")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		status, ok := s.alerts.Get(name)
+		if !ok {
+			writeError(w, http.StatusNotFound, "alert rule not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	case "PUT":
+		var rule alerts.Rule
+		if !s.decodeJSON(w, r, &rule) {
+			return
+		}
+		if rule.Name == "" {
+			rule.Name = name
+		}
+		if rule.Name != name {
+			writeError(w, http.StatusBadRequest, "rule name does not match the URL")
+			return
+		}
+		if err := s.alerts.Put(rule); err != nil {
+			writeAlertError(w, err)
+			return
+		}
+		status, _ := s.alerts.Get(name)
+		writeJSON(w, http.StatusOK, status)
+	case "DELETE":
+		if err := s.alerts.Delete(name); err != nil {
+			writeAlertError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"deleted": name})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func writeAlertError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, alerts.ErrRuleNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, alerts.ErrRuleExists):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,70 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/metrics"
+	"github.com/nuulab/goflow/pkg/metrics/alerts"
+)
+
+func TestAlertsAPI_CRUD(t *testing.T) {
+	manager := alerts.NewManager(metrics.NewMetrics(), nil)
+	h := api.NewServer(api.Config{Alerts: manager}).Handler()
+
+	rule := map[string]any{
+		"name":        "dlq",
+		"metric":      "goflow_jobs_dlq_total",
+		"aggregation": "increase",
+		"window":      "5m",
+		"op":          ">",
+		"threshold":   100,
+	}
+	if rec := do(t, h, "POST", "/api/alerts", rule, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(t, h, "POST", "/api/alerts", rule, nil); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a duplicate, got %d", rec.Code)
+	}
+	bad := map[string]any{"name": "bad", "metric": "nope", "op": ">"}
+	if rec := do(t, h, "POST", "/api/alerts", bad, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown metric, got %d", rec.Code)
+	}
+
+	rule["threshold"] = 50
+	rec := do(t, h, "PUT", "/api/alerts/dlq", rule, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var status alerts.RuleStatus
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	if status.Rule.Threshold != 50 || status.State != alerts.StateInactive {
+		t.Errorf("Unexpected status after update: %+v", status)
+	}
+
+	rec = do(t, h, "GET", "/api/alerts", nil, nil)
+	var list struct {
+		Alerts []alerts.RuleStatus `json:"alerts"`
+		Count  int                 `json:"count"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if list.Count != 1 || list.Alerts[0].Rule.Name != "dlq" {
+		t.Errorf("Unexpected list: %s", rec.Body.String())
+	}
+
+	if rec := do(t, h, "DELETE", "/api/alerts/dlq", nil, nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", rec.Code)
+	}
+	if rec := do(t, h, "GET", "/api/alerts/dlq", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 after delete, got %d", rec.Code)
+	}
+}
+
+func TestAlertsAPI_NotConfigured(t *testing.T) {
+	h := api.NewServer(api.Config{}).Handler()
+	if rec := do(t, h, "GET", "/api/alerts", nil, nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", rec.Code)
+	}
+}
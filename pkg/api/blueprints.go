@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/nuulab/goflow/pkg/blueprint"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// InstantiateRequest is the request body for instantiating a blueprint.
+type InstantiateRequest struct {
+	Parameters map[string]any `json:"parameters"`
+}
+
+// handleBlueprints handles GET /api/blueprints.
+func (s *Server) handleBlueprints(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	list := s.blueprints.List()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"blueprints": list,
+		"count":      len(list),
+	})
+}
+
+// handleBlueprint handles GET /api/blueprints/:name and
+// POST /api/blueprints/:name/instantiate.
+func (s *Server) handleBlueprint(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/blueprints/"), "/")
+	if parts[0] == "" {
+		writeError(w, http.StatusBadRequest, "blueprint name required")
+		return
+	}
+	name := parts[0]
+
+	if len(parts) == 1 {
+		if r.Method != "GET" {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		bp, ok := s.blueprints.Get(name)
+		if !ok {
+			writeError(w, http.StatusNotFound, "blueprint not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, bp)
+		return
+	}
+
+	if parts[1] != "instantiate" {
+		writeError(w, http.StatusNotFound, "unknown action")
+		return
+	}
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.instantiateBlueprint(w, r, name)
+}
+
+// instantiateBlueprint registers a workflow with the engine or creates a
+// managed agent from the blueprint.
+func (s *Server) instantiateBlueprint(w http.ResponseWriter, r *http.Request, name string) {
+	var req InstantiateRequest
+	if r.ContentLength != 0 {
+		if !s.decodeJSON(w, r, &req) {
+			return
+		}
+	}
+
+	inst, err := s.blueprints.Instantiate(name, req.Parameters)
+	if err != nil {
+		var invalid *tools.ValidationError
+		switch {
+		case errors.Is(err, blueprint.ErrNotFound):
+			writeError(w, http.StatusNotFound, "blueprint not found")
+		case errors.As(err, &invalid):
+			writeJSON(w, http.StatusBadRequest, map[string]any{
+				"error":    err.Error(),
+				"problems": invalid.Problems,
+			})
+		default:
+			writeError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	switch inst.Kind {
+	case blueprint.KindWorkflow:
+		if s.engine == nil {
+			writeError(w, http.StatusServiceUnavailable, "workflow engine not configured")
+			return
+		}
+		wf, err := inst.Workflow.Build(s.llm)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.engine.Register(wf)
+		s.mu.Lock()
+		s.specs[wf.Name] = inst.Workflow
+		s.mu.Unlock()
+
+	case blueprint.KindAgent:
+		id := inst.Name()
+		if _, exists := s.GetAgent(id); exists {
+			writeError(w, http.StatusConflict, "agent already exists")
+			return
+		}
+		a, err := inst.Agent.Build(s.llm, s.registry, s.agentOptions(id)...)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.addAgent(AgentDefinition{
+			ID:            id,
+			SystemPrompt:  inst.Agent.SystemPrompt,
+			MaxIterations: inst.Agent.MaxIterations,
+			AllowedTools:  inst.Agent.Tools,
+		}, a)
+		annotateAgent(r.Context(), id)
+	}
+
+	writeJSON(w, http.StatusCreated, inst)
+}
@@ -0,0 +1,94 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func TestBlueprints_List(t *testing.T) {
+	h := api.NewServer(api.Config{}).Handler()
+
+	rec := do(t, h, "GET", "/api/blueprints", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var resp struct {
+		Blueprints []struct {
+			Name       string `json:"name"`
+			Kind       string `json:"kind"`
+			Parameters struct {
+				Required []string `json:"required"`
+			} `json:"parameters"`
+		} `json:"blueprints"`
+		Count int `json:"count"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Count != 3 || resp.Blueprints[0].Name != "deploy-with-approval" || resp.Blueprints[0].Kind != "workflow" {
+		t.Fatalf("Unexpected blueprints: %s", rec.Body)
+	}
+	if len(resp.Blueprints[0].Parameters.Required) != 2 {
+		t.Errorf("Expected parameter schema in listing, got %s", rec.Body)
+	}
+}
+
+func TestBlueprints_InstantiateAgent(t *testing.T) {
+	h := api.NewServer(api.Config{}).Handler()
+	body := map[string]any{"parameters": map[string]any{"topic": "Edge caching"}}
+
+	rec := do(t, h, "POST", "/api/blueprints/research-report/instantiate", body, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, h, "GET", "/api/agents/research-edge-caching", nil, nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected instantiated agent to exist, got %d", rec.Code)
+	}
+
+	rec = do(t, h, "POST", "/api/blueprints/research-report/instantiate", body, nil)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for duplicate agent, got %d", rec.Code)
+	}
+}
+
+func TestBlueprints_InstantiateWorkflow(t *testing.T) {
+	body := map[string]any{"parameters": map[string]any{"service": "web", "approvers": []string{"alice"}}}
+
+	h := api.NewServer(api.Config{}).Handler()
+	if rec := do(t, h, "POST", "/api/blueprints/deploy-with-approval/instantiate", body, nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without engine, got %d", rec.Code)
+	}
+
+	engine := workflow.NewEngine(nil)
+	h = api.NewServer(api.Config{Engine: engine}).Handler()
+	rec := do(t, h, "POST", "/api/blueprints/deploy-with-approval/instantiate", body, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if _, ok := engine.Workflow("deploy-web-staging"); !ok {
+		t.Error("Expected workflow to be registered")
+	}
+}
+
+func TestBlueprints_InstantiateErrors(t *testing.T) {
+	h := api.NewServer(api.Config{}).Handler()
+
+	rec := do(t, h, "POST", "/api/blueprints/deploy-with-approval/instantiate",
+		map[string]any{"parameters": map[string]any{"environment": "moon"}}, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", rec.Code)
+	}
+	var resp struct {
+		Problems []string `json:"problems"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if len(resp.Problems) != 3 {
+		t.Errorf("Expected problems listed, got %s", rec.Body)
+	}
+
+	if rec := do(t, h, "POST", "/api/blueprints/nope/instantiate", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/agent"
+)
+
+// PendingConfirmation is a tool call waiting for approval. Runs pause until
+// it is resolved with POST /api/agents/:id/confirm or the run times out.
+type PendingConfirmation struct {
+	ID        string          `json:"id"`
+	AgentID   string          `json:"agent_id"`
+	Tool      string          `json:"tool"`
+	Input     json.RawMessage `json:"input"`
+	Thought   string          `json:"thought,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+
+	reply chan agent.Confirmation
+}
+
+// ConfirmRequest resolves a pending confirmation. ID is optional; when set
+// it must match the pending confirmation, which guards against approving a
+// call other than the one that was reviewed.
+type ConfirmRequest struct {
+	ID string `json:"id,omitempty"`
+	agent.Confirmation
+}
+
+// confirmationHandler publishes tool calls that need approval as
+// agent.confirmation_required events and waits for them to be resolved.
+func (s *Server) confirmationHandler(agentID string) agent.ConfirmationHandler {
+	return func(ctx context.Context, action agent.AgentAction) (agent.Confirmation, error) {
+		pending := &PendingConfirmation{
+			ID:        fmt.Sprintf("cf-%d", time.Now().UnixNano()),
+			AgentID:   agentID,
+			Tool:      action.Action,
+			Input:     action.ActionInput,
+			Thought:   action.Thought,
+			CreatedAt: time.Now(),
+			reply:     make(chan agent.Confirmation, 1),
+		}
+
+		s.mu.Lock()
+		s.pending[agentID] = pending
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			if s.pending[agentID] == pending {
+				delete(s.pending, agentID)
+			}
+			s.mu.Unlock()
+		}()
+
+		s.emit(ctx, Event{Type: "agent.confirmation_required", AgentID: agentID, Data: pending}, false)
+
+		select {
+		case c := <-pending.reply:
+			s.emit(ctx, Event{
+				Type:    "agent.confirmation_resolved",
+				AgentID: agentID,
+				Data:    map[string]any{"id": pending.ID, "tool": pending.Tool, "decision": c.Decision},
+			}, false)
+			return c, nil
+		case <-ctx.Done():
+			return agent.Confirmation{}, ctx.Err()
+		}
+	}
+}
+
+// handleAgentConfirm handles GET and POST /api/agents/:id/confirm.
+func (s *Server) handleAgentConfirm(w http.ResponseWriter, r *http.Request, agentID string) {
+	switch r.Method {
+	case "GET":
+		s.mu.RLock()
+		pending, ok := s.pending[agentID]
+		s.mu.RUnlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "no pending confirmation")
+			return
+		}
+		writeJSON(w, http.StatusOK, pending)
+
+	case "POST":
+		var req ConfirmRequest
+		if !s.decodeJSON(w, r, &req) {
+			return
+		}
+		switch req.Decision {
+		case agent.Approve, agent.Deny:
+		case agent.Modify:
+			if !json.Valid(req.Input) {
+				writeError(w, http.StatusBadRequest, "modify requires a JSON input")
+				return
+			}
+		default:
+			writeError(w, http.StatusBadRequest, "decision must be approve, deny or modify")
+			return
+		}
+
+		s.mu.Lock()
+		pending, ok := s.pending[agentID]
+		if ok && (req.ID == "" || req.ID == pending.ID) {
+			delete(s.pending, agentID)
+		}
+		s.mu.Unlock()
+		switch {
+		case !ok:
+			writeError(w, http.StatusNotFound, "no pending confirmation")
+			return
+		case req.ID != "" && req.ID != pending.ID:
+			writeError(w, http.StatusConflict, "confirmation "+req.ID+" is no longer pending")
+			return
+		}
+
+		pending.reply <- req.Confirmation
+		writeJSON(w, http.StatusOK, map[string]any{"id": pending.ID, "decision": req.Decision})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
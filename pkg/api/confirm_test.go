@@ -0,0 +1,102 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// deployLLM asks to deploy once, then answers with the last observation.
+type deployLLM struct{ loopingLLM }
+
+func (l *deployLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	l.calls++
+	if l.calls == 1 {
+		return `{"action": "deploy", "action_input": {"env": "prod"}}`, nil
+	}
+	last, _ := json.Marshal(messages[len(messages)-1].Content)
+	return `{"action": "final_answer", "action_input": ` + string(last) + `}`, nil
+}
+
+func waitForConfirmation(t *testing.T, h http.Handler, agentID string) api.PendingConfirmation {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		rec := do(t, h, "GET", "/api/agents/"+agentID+"/confirm", nil, nil)
+		if rec.Code == http.StatusOK {
+			var pending api.PendingConfirmation
+			json.Unmarshal(rec.Body.Bytes(), &pending)
+			return pending
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for a pending confirmation")
+	return api.PendingConfirmation{}
+}
+
+func TestAgentConfirm(t *testing.T) {
+	registry := tools.NewRegistry()
+	deployed := 0
+	registry.Register(tools.Build("deploy").
+		Description("deploy a service").
+		RequiresConfirmation().
+		Handler(func(ctx context.Context, input string) (string, error) {
+			deployed++
+			return "deployed", nil
+		}).
+		Create())
+	h := api.NewServer(api.Config{LLM: &deployLLM{}, Registry: registry}).Handler()
+
+	if rec := do(t, h, "POST", "/api/agents/ops/confirm", map[string]any{"decision": "approve"}, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 with nothing pending, got %d", rec.Code)
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- do(t, h, "POST", "/api/agents/ops/run", api.RunRequest{Task: "ship it"}, nil)
+	}()
+
+	pending := waitForConfirmation(t, h, "ops")
+	if pending.Tool != "deploy" || !strings.Contains(string(pending.Input), "prod") {
+		t.Fatalf("Unexpected pending confirmation: %+v", pending)
+	}
+
+	for _, c := range []struct {
+		body string
+		want int
+	}{
+		{`{"decision":"maybe"}`, http.StatusBadRequest},
+		{`{"decision":"modify"}`, http.StatusBadRequest},
+		{`{"id":"cf-0","decision":"approve"}`, http.StatusConflict},
+	} {
+		if rec := do(t, h, "POST", "/api/agents/ops/confirm", []byte(c.body), nil); rec.Code != c.want {
+			t.Errorf("POST %s: expected %d, got %d", c.body, c.want, rec.Code)
+		}
+	}
+
+	deny := map[string]any{"id": pending.ID, "decision": "deny", "reason": "change freeze"}
+	if rec := do(t, h, "POST", "/api/agents/ops/confirm", deny, nil); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec := <-done
+	var resp api.RunResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if !resp.Success || !strings.Contains(resp.Output, "change freeze") {
+		t.Errorf("Expected the denial to reach the agent, got %+v", resp)
+	}
+	if deployed != 0 {
+		t.Error("Denied tool call ran")
+	}
+	if rec := do(t, h, "GET", "/api/agents/ops/confirm", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected the confirmation to be cleared, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,658 @@
+// Package api provides export and import of the server's declarative
+// configuration for promoting it between environments.
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nuulab/goflow/pkg/blueprint"
+	"github.com/nuulab/goflow/pkg/metrics/alerts"
+	"github.com/nuulab/goflow/pkg/webhook"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// BundleVersion is the format version of exported bundles.
+const BundleVersion = 1
+
+var (
+	// ErrImportInvalid is returned when a bundle has definitions that
+	// cannot be applied.
+	ErrImportInvalid = errors.New("api: import bundle is invalid")
+	// ErrImportConflict is returned when a bundle would replace existing
+	// definitions and overwriting was not requested.
+	ErrImportConflict = errors.New("api: import conflicts with existing definitions")
+)
+
+// Bundle is the declarative configuration of a server. It holds no runtime
+// state, and webhook secrets are referenced by name instead of embedded.
+type Bundle struct {
+	Version   int                       `json:"version"`
+	Agents    []AgentDefinition         `json:"agents"`
+	Workflows []*blueprint.WorkflowSpec `json:"workflows"`
+	Schedules []ScheduleDefinition      `json:"schedules"`
+	Webhooks  []WebhookDefinition       `json:"webhooks"`
+	Alerts    []alerts.Rule             `json:"alerts"`
+}
+
+// AgentDefinition describes a managed agent. AllowedTools and DeniedTools
+// are its tool policy (see agent.WithToolPolicy).
+type AgentDefinition struct {
+	ID            string `json:"id"`
+	SystemPrompt  string `json:"system_prompt,omitempty"`
+	MaxIterations int    `json:"max_iterations,omitempty"`
+	// Tools is another name for AllowedTools.
+	//
+	// Deprecated: Use AllowedTools. Agents are created with Tools merged
+	// into AllowedTools, so exported definitions never set it.
+	Tools        []string `json:"tools,omitempty"`
+	AllowedTools []string `json:"allowed_tools,omitempty"`
+	DeniedTools  []string `json:"denied_tools,omitempty"`
+	History      int      `json:"history,omitempty"`
+}
+
+// normalize merges the deprecated Tools into AllowedTools.
+func (def AgentDefinition) normalize() AgentDefinition {
+	if len(def.Tools) > 0 {
+		def.AllowedTools = append(append([]string{}, def.Tools...), def.AllowedTools...)
+		def.Tools = nil
+	}
+	return def
+}
+
+// ScheduleDefinition describes a cron schedule that starts Workflow or
+// enqueues a job of JobType. Overlap defaults to workflow.SkipIfRunning.
+type ScheduleDefinition struct {
+	ID         string                 `json:"id"`
+	Workflow   string                 `json:"workflow,omitempty"`
+	JobType    string                 `json:"job_type,omitempty"`
+	Expression string                 `json:"expression"`
+	Input      map[string]any         `json:"input,omitempty"`
+	Template   bool                   `json:"template,omitempty"`
+	Enabled    bool                   `json:"enabled"`
+	Overlap    workflow.OverlapPolicy `json:"overlap,omitempty"`
+	CatchUp    int                    `json:"catch_up,omitempty"`
+}
+
+// options returns the schedule options def describes.
+func (def ScheduleDefinition) options() []workflow.ScheduleOption {
+	opts := []workflow.ScheduleOption{workflow.CatchUp(def.CatchUp)}
+	if def.Overlap != "" {
+		opts = append(opts, workflow.WithOverlap(def.Overlap))
+	}
+	if def.Template {
+		opts = append(opts, workflow.WithTemplate())
+	}
+	return opts
+}
+
+// WebhookDefinition describes a webhook. Its signing secret is resolved
+// from SecretRef with Config.Secrets on import.
+type WebhookDefinition struct {
+	ID              string                `json:"id"`
+	Name            string                `json:"name"`
+	Path            string                `json:"path"`
+	Action          webhook.WebhookAction `json:"action"`
+	JobType         string                `json:"job_type,omitempty"`
+	WorkflowID      string                `json:"workflow_id,omitempty"`
+	SecretRef       string                `json:"secret_ref,omitempty"`
+	Transform       string                `json:"transform,omitempty"`
+	PayloadTemplate map[string]any        `json:"payload_template,omitempty"`
+	Enabled         bool                  `json:"enabled"`
+}
+
+// Import actions reported for each definition.
+const (
+	ImportCreate    = "create"
+	ImportUpdate    = "update"
+	ImportUnchanged = "unchanged"
+	ImportConflict  = "conflict"
+	ImportInvalid   = "invalid"
+)
+
+// ImportOptions controls Import.
+type ImportOptions struct {
+	// DryRun plans the import without applying it.
+	DryRun bool
+	// Overwrite replaces existing definitions that differ from the
+	// bundle. Without it they are reported as conflicts.
+	Overwrite bool
+}
+
+// ImportChange is the planned action for one definition.
+type ImportChange struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+
+	// apply makes the change; undo restores what it replaced.
+	apply, undo func(ctx context.Context) error
+}
+
+// ImportReport lists the planned or applied changes of an import.
+type ImportReport struct {
+	DryRun  bool           `json:"dry_run"`
+	Applied bool           `json:"applied"`
+	Summary map[string]int `json:"summary"`
+	Changes []ImportChange `json:"changes"`
+}
+
+// ============ Export ============
+
+// Export returns the server's declarative configuration. Workflows and
+// webhooks defined in code, rather than through the API, are not included.
+func (s *Server) Export() *Bundle {
+	bundle := &Bundle{
+		Version:   BundleVersion,
+		Agents:    make([]AgentDefinition, 0),
+		Workflows: make([]*blueprint.WorkflowSpec, 0),
+		Schedules: make([]ScheduleDefinition, 0),
+		Webhooks:  make([]WebhookDefinition, 0),
+		Alerts:    make([]alerts.Rule, 0),
+	}
+
+	s.mu.RLock()
+	for _, managed := range s.agents {
+		bundle.Agents = append(bundle.Agents, managed.Definition)
+	}
+	for _, spec := range s.specs {
+		bundle.Workflows = append(bundle.Workflows, spec)
+	}
+	s.mu.RUnlock()
+	sort.Slice(bundle.Agents, func(i, j int) bool { return bundle.Agents[i].ID < bundle.Agents[j].ID })
+	sort.Slice(bundle.Workflows, func(i, j int) bool { return bundle.Workflows[i].Name < bundle.Workflows[j].Name })
+
+	if s.cron != nil {
+		for _, schedule := range s.cron.List() {
+			bundle.Schedules = append(bundle.Schedules, scheduleDefinition(schedule))
+		}
+		sort.Slice(bundle.Schedules, func(i, j int) bool { return bundle.Schedules[i].ID < bundle.Schedules[j].ID })
+	}
+
+	if s.webhooks != nil {
+		for _, cfg := range s.webhooks.List() {
+			if cfg.Transform != nil {
+				continue // a Go function cannot be exported
+			}
+			bundle.Webhooks = append(bundle.Webhooks, webhookDefinition(cfg))
+		}
+		sort.Slice(bundle.Webhooks, func(i, j int) bool { return bundle.Webhooks[i].ID < bundle.Webhooks[j].ID })
+	}
+
+	if s.alerts != nil {
+		for _, status := range s.alerts.List() {
+			bundle.Alerts = append(bundle.Alerts, status.Rule)
+		}
+	}
+	return bundle
+}
+
+// scheduleDefinition exports schedule. A location set with
+// Cron.AddWithLocation becomes a CRON_TZ prefix on the expression.
+func scheduleDefinition(schedule *workflow.Schedule) ScheduleDefinition {
+	expression := schedule.Expression
+	if loc := schedule.Location; loc != nil && !strings.HasPrefix(expression, "CRON_TZ=") {
+		expression = "CRON_TZ=" + loc.String() + " " + expression
+	}
+	overlap := schedule.Overlap
+	if overlap == workflow.SkipIfRunning {
+		overlap = ""
+	}
+	return ScheduleDefinition{
+		ID:         schedule.ID,
+		Workflow:   schedule.WorkflowName,
+		JobType:    schedule.JobType,
+		Expression: expression,
+		Input:      schedule.Input,
+		Template:   schedule.Templated,
+		Enabled:    schedule.Enabled,
+		Overlap:    overlap,
+		CatchUp:    schedule.MaxMissed,
+	}
+}
+
+// webhookDefinition exports cfg. A secret set without a reference is
+// exported under a name derived from the webhook ID, which must be
+// provided in the target environment.
+func webhookDefinition(cfg *webhook.WebhookConfig) WebhookDefinition {
+	ref := cfg.SecretRef
+	if ref == "" && cfg.Secret != "" {
+		ref = "WEBHOOK_" + strings.ToUpper(nonSecretName.ReplaceAllString(cfg.ID, "_")) + "_SECRET"
+	}
+	return WebhookDefinition{
+		ID:              cfg.ID,
+		Name:            cfg.Name,
+		Path:            cfg.Path,
+		Action:          cfg.Action,
+		JobType:         cfg.JobType,
+		WorkflowID:      cfg.WorkflowID,
+		SecretRef:       ref,
+		Transform:       cfg.TransformExpr,
+		PayloadTemplate: cfg.PayloadTemplate,
+		Enabled:         cfg.Enabled,
+	}
+}
+
+var nonSecretName = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// ============ Import ============
+
+// Import applies bundle. Every definition is checked before anything is
+// applied: invalid definitions fail the import with ErrImportInvalid, and
+// definitions that would replace different existing ones fail it with
+// ErrImportConflict unless opts.Overwrite is set. If applying a change
+// still fails, the changes already applied are rolled back. A dry run only
+// reports the plan.
+func (s *Server) Import(ctx context.Context, bundle *Bundle, opts ImportOptions) (*ImportReport, error) {
+	if bundle.Version != BundleVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrImportInvalid, bundle.Version)
+	}
+
+	changes := s.planImport(bundle, opts)
+	report := &ImportReport{DryRun: opts.DryRun, Summary: make(map[string]int), Changes: changes}
+	for _, c := range changes {
+		report.Summary[c.Action]++
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+	if report.Summary[ImportInvalid] > 0 {
+		return report, ErrImportInvalid
+	}
+	if report.Summary[ImportConflict] > 0 {
+		return report, ErrImportConflict
+	}
+
+	var applied []ImportChange
+	for _, c := range changes {
+		if c.apply == nil {
+			continue
+		}
+		// A failed change may be half applied, so it is undone too.
+		applied = append(applied, c)
+		if err := c.apply(ctx); err != nil {
+			err = fmt.Errorf("api: import %s %s: %w", c.Kind, c.ID, err)
+			return report, errors.Join(err, rollback(ctx, applied))
+		}
+	}
+	report.Applied = true
+	return report, nil
+}
+
+// rollback undoes applied in reverse order.
+func rollback(ctx context.Context, applied []ImportChange) error {
+	var errs []error
+	for i := len(applied) - 1; i >= 0; i-- {
+		c := applied[i]
+		if err := c.undo(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("api: roll back %s %s: %w", c.Kind, c.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// planImport returns a change for every definition in bundle, in the order
+// they are applied: workflows before the schedules and webhooks that start
+// them.
+func (s *Server) planImport(bundle *Bundle, opts ImportOptions) []ImportChange {
+	p := &importPlan{overwrite: opts.Overwrite, seen: make(map[string]bool)}
+	for _, spec := range bundle.Workflows {
+		s.planWorkflow(p, spec)
+	}
+	for _, def := range bundle.Agents {
+		s.planAgent(p, def)
+	}
+	for _, rule := range bundle.Alerts {
+		s.planAlert(p, rule)
+	}
+	for _, def := range bundle.Schedules {
+		s.planSchedule(p, def)
+	}
+	for _, def := range bundle.Webhooks {
+		s.planWebhook(p, def)
+	}
+	return p.changes
+}
+
+type importPlan struct {
+	overwrite bool
+	seen      map[string]bool
+	changes   []ImportChange
+}
+
+// add records the change for kind/id. exists and current describe the
+// existing definition, if any, and undo restores it after apply.
+func (p *importPlan) add(kind, id string, exists bool, current, next any, apply, undo func(ctx context.Context) error) {
+	change := ImportChange{Kind: kind, ID: id, apply: apply, undo: undo}
+	switch {
+	case !exists:
+		change.Action = ImportCreate
+	case sameDefinition(current, next):
+		change.Action = ImportUnchanged
+		change.apply = nil
+	case p.overwrite:
+		change.Action = ImportUpdate
+	default:
+		change.Action = ImportConflict
+		change.Reason = kind + " already exists with a different definition"
+		change.apply = nil
+	}
+	p.changes = append(p.changes, change)
+}
+
+// reject records a definition that cannot be applied.
+func (p *importPlan) reject(kind, id, action, reason string) {
+	p.changes = append(p.changes, ImportChange{Kind: kind, ID: id, Action: action, Reason: reason})
+}
+
+// checkID rejects empty and repeated IDs.
+func (p *importPlan) checkID(kind, id string) bool {
+	switch {
+	case id == "":
+		p.reject(kind, id, ImportInvalid, "id is required")
+		return false
+	case p.seen[kind+"/"+id]:
+		p.reject(kind, id, ImportInvalid, "duplicate "+kind)
+		return false
+	}
+	p.seen[kind+"/"+id] = true
+	return true
+}
+
+func sameDefinition(a, b any) bool {
+	x, err1 := json.Marshal(a)
+	y, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && bytes.Equal(x, y)
+}
+
+func (s *Server) planWorkflow(p *importPlan, spec *blueprint.WorkflowSpec) {
+	const kind = "workflow"
+	if spec == nil {
+		p.reject(kind, "", ImportInvalid, "empty definition")
+		return
+	}
+	if !p.checkID(kind, spec.Name) {
+		return
+	}
+	if s.engine == nil {
+		p.reject(kind, spec.Name, ImportInvalid, "workflow engine not configured")
+		return
+	}
+	wf, err := spec.Build(s.llm)
+	if err != nil {
+		p.reject(kind, spec.Name, ImportInvalid, err.Error())
+		return
+	}
+
+	s.mu.RLock()
+	current, defined := s.specs[spec.Name]
+	s.mu.RUnlock()
+	previous, registered := s.engine.Workflow(spec.Name)
+	if registered && !defined {
+		p.reject(kind, spec.Name, ImportConflict, "workflow is defined in code")
+		return
+	}
+	p.add(kind, spec.Name, defined, current, spec, func(ctx context.Context) error {
+		s.engine.Register(wf)
+		s.mu.Lock()
+		s.specs[spec.Name] = spec
+		s.mu.Unlock()
+		return nil
+	}, func(ctx context.Context) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !defined {
+			s.engine.Unregister(spec.Name)
+			delete(s.specs, spec.Name)
+			return nil
+		}
+		s.engine.Register(previous)
+		s.specs[spec.Name] = current
+		return nil
+	})
+}
+
+func (s *Server) planAgent(p *importPlan, def AgentDefinition) {
+	const kind = "agent"
+	def = def.normalize()
+	if !p.checkID(kind, def.ID) {
+		return
+	}
+	if _, err := s.buildAgent(def); err != nil {
+		p.reject(kind, def.ID, ImportInvalid, err.Error())
+		return
+	}
+
+	managed, exists := s.GetAgent(def.ID)
+	if exists && managed.Status == AgentRunning && !sameDefinition(managed.Definition, def) {
+		p.reject(kind, def.ID, ImportConflict, "agent is running")
+		return
+	}
+	var current AgentDefinition
+	if exists {
+		current = managed.Definition
+	}
+	p.add(kind, def.ID, exists, current, def, func(ctx context.Context) error {
+		_, err := s.createAgentFrom(def)
+		return err
+	}, func(ctx context.Context) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if exists {
+			s.agents[def.ID] = managed
+		} else {
+			delete(s.agents, def.ID)
+		}
+		return nil
+	})
+}
+
+func (s *Server) planAlert(p *importPlan, rule alerts.Rule) {
+	const kind = "alert"
+	if !p.checkID(kind, rule.Name) {
+		return
+	}
+	if s.alerts == nil {
+		p.reject(kind, rule.Name, ImportInvalid, "alerts not configured")
+		return
+	}
+	if err := s.alerts.Validate(&rule); err != nil {
+		p.reject(kind, rule.Name, ImportInvalid, err.Error())
+		return
+	}
+
+	status, exists := s.alerts.Get(rule.Name)
+	p.add(kind, rule.Name, exists, status.Rule, rule, func(ctx context.Context) error {
+		return s.alerts.Put(rule)
+	}, func(ctx context.Context) error {
+		if exists {
+			return s.alerts.Put(status.Rule)
+		}
+		if err := s.alerts.Delete(rule.Name); err != nil && !errors.Is(err, alerts.ErrRuleNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+func (s *Server) planSchedule(p *importPlan, def ScheduleDefinition) {
+	const kind = "schedule"
+	if !p.checkID(kind, def.ID) {
+		return
+	}
+	if s.cron == nil {
+		p.reject(kind, def.ID, ImportInvalid, "cron scheduler not configured")
+		return
+	}
+	if (def.Workflow == "") == (def.JobType == "") {
+		p.reject(kind, def.ID, ImportInvalid, "exactly one of workflow and job_type is required")
+		return
+	}
+	if _, err := workflow.ParseCron(def.Expression); err != nil {
+		p.reject(kind, def.ID, ImportInvalid, err.Error())
+		return
+	}
+	if def.Template || def.JobType != "" {
+		if _, err := s.cron.CheckTemplate(def.JobType, def.Input); err != nil {
+			p.reject(kind, def.ID, ImportInvalid, err.Error())
+			return
+		}
+	}
+	switch def.Overlap {
+	case "", workflow.SkipIfRunning, workflow.AllowConcurrent, workflow.QueueBehind:
+	default:
+		p.reject(kind, def.ID, ImportInvalid, fmt.Sprintf("unknown overlap policy %q", def.Overlap))
+		return
+	}
+
+	var current ScheduleDefinition
+	existing, exists := s.cron.Get(def.ID)
+	if exists {
+		current = scheduleDefinition(existing)
+	}
+	p.add(kind, def.ID, exists, current, def, func(ctx context.Context) error {
+		return s.putSchedule(def)
+	}, func(ctx context.Context) error {
+		if exists {
+			return s.putSchedule(current)
+		}
+		return s.cron.Remove(def.ID)
+	})
+}
+
+// putSchedule adds the schedule described by def, replacing the one with
+// the same ID.
+func (s *Server) putSchedule(def ScheduleDefinition) error {
+	opts := def.options()
+	var err error
+	if def.JobType != "" {
+		_, err = s.cron.AddJob(def.ID, def.JobType, def.Expression, def.Input, opts...)
+	} else {
+		err = s.cron.Add(def.ID, def.Workflow, def.Expression, def.Input, opts...)
+	}
+	if err == nil && !def.Enabled {
+		err = s.cron.Disable(def.ID)
+	}
+	return err
+}
+
+func (s *Server) planWebhook(p *importPlan, def WebhookDefinition) {
+	const kind = "webhook"
+	if !p.checkID(kind, def.ID) {
+		return
+	}
+	if s.webhooks == nil {
+		p.reject(kind, def.ID, ImportInvalid, "webhooks not configured")
+		return
+	}
+
+	cfg := &webhook.WebhookConfig{
+		ID:              def.ID,
+		Name:            def.Name,
+		Path:            def.Path,
+		Action:          def.Action,
+		JobType:         def.JobType,
+		WorkflowID:      def.WorkflowID,
+		SecretRef:       def.SecretRef,
+		TransformExpr:   def.Transform,
+		PayloadTemplate: def.PayloadTemplate,
+		Enabled:         def.Enabled,
+	}
+	if err := s.webhooks.Check(cfg); err != nil {
+		p.reject(kind, def.ID, ImportInvalid, err.Error())
+		return
+	}
+	if def.SecretRef != "" {
+		secret, err := s.resolveSecret(def.SecretRef)
+		if err != nil {
+			p.reject(kind, def.ID, ImportInvalid, err.Error())
+			return
+		}
+		cfg.Secret = secret
+	}
+	for _, other := range s.webhooks.List() {
+		if other.Path == def.Path && other.ID != def.ID {
+			p.reject(kind, def.ID, ImportConflict, "path "+def.Path+" is used by webhook "+other.ID)
+			return
+		}
+	}
+
+	var current WebhookDefinition
+	existing, exists := s.webhooks.Get(def.ID)
+	if exists {
+		current = webhookDefinition(existing)
+	}
+	p.add(kind, def.ID, exists, current, def, func(ctx context.Context) error {
+		return s.webhooks.Put(ctx, cfg)
+	}, func(ctx context.Context) error {
+		if exists {
+			return s.webhooks.Put(ctx, existing)
+		}
+		if err := s.webhooks.Delete(ctx, def.ID); err != nil && !errors.Is(err, webhook.ErrNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+// ============ Handlers ============
+
+// handleExport handles GET /api/export.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="goflow-export.json"`)
+	writeJSON(w, http.StatusOK, s.Export())
+}
+
+// handleImport handles POST /api/import. The dry_run and overwrite query
+// parameters set the ImportOptions.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var opts ImportOptions
+	for name, target := range map[string]*bool{"dry_run": &opts.DryRun, "overwrite": &opts.Overwrite} {
+		if v := r.URL.Query().Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid "+name)
+				return
+			}
+			*target = b
+		}
+	}
+
+	var bundle Bundle
+	if !s.decodeJSON(w, r, &bundle) {
+		return
+	}
+
+	report, err := s.Import(r.Context(), &bundle, opts)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, report)
+	case report == nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrImportInvalid):
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error(), "report": report})
+	case errors.Is(err, ErrImportConflict):
+		writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "report": report})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error(), "report": report})
+	}
+}
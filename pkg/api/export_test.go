@@ -0,0 +1,264 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/cache"
+	"github.com/nuulab/goflow/pkg/metrics"
+	"github.com/nuulab/goflow/pkg/metrics/alerts"
+	"github.com/nuulab/goflow/pkg/queue"
+	"github.com/nuulab/goflow/pkg/tools"
+	"github.com/nuulab/goflow/pkg/webhook"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+type environment struct {
+	handler http.Handler
+	cron    *workflow.Cron
+	hooks   *webhook.WebhookHandler
+}
+
+func newEnvironment(secrets map[string]string) *environment {
+	registry := tools.NewRegistry()
+	registry.Register(tools.CalculatorTool())
+	engine := workflow.NewEngine(nil)
+	env := &environment{
+		cron:  workflow.NewCron(engine),
+		hooks: webhook.NewWebhookHandler(&recordingQueue{}, engine),
+	}
+	env.handler = api.NewServer(api.Config{
+		Registry: registry,
+		Engine:   engine,
+		Webhooks: env.hooks,
+		Cron:     env.cron,
+		Alerts:   alerts.NewManager(metrics.NewMetrics(), nil),
+		Secrets: func(name string) (string, bool) {
+			v, ok := secrets[name]
+			return v, ok
+		},
+	}).Handler()
+	return env
+}
+
+func export(t *testing.T, h http.Handler) []byte {
+	t.Helper()
+	rec := do(t, h, "GET", "/api/export", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Export failed with %d: %s", rec.Code, rec.Body)
+	}
+	return rec.Body.Bytes()
+}
+
+func importReport(t *testing.T, h http.Handler, path string, bundle []byte, wantStatus int) api.ImportReport {
+	t.Helper()
+	rec := do(t, h, "POST", path, bundle, nil)
+	if rec.Code != wantStatus {
+		t.Fatalf("POST %s: expected %d, got %d: %s", path, wantStatus, rec.Code, rec.Body)
+	}
+	var report api.ImportReport
+	if wantStatus == http.StatusOK {
+		json.Unmarshal(rec.Body.Bytes(), &report)
+	} else {
+		var resp struct {
+			Report api.ImportReport `json:"report"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		report = resp.Report
+	}
+	return report
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	staging := newEnvironment(nil)
+	h := staging.handler
+
+	for _, req := range []struct {
+		path string
+		body any
+	}{
+		{"/api/agents", map[string]any{"id": "support", "system_prompt": "Be brief.", "max_iterations": 4, "allowed_tools": []string{"calculator"}}},
+		{"/api/blueprints/deploy-with-approval/instantiate", map[string]any{"parameters": map[string]any{"service": "web", "approvers": []string{"alice"}}}},
+		{"/api/webhooks", api.WebhookRequest{Path: "/github", Action: webhook.ActionEnqueueJob, JobType: "build", Secret: "s3cret"}},
+		{"/api/alerts", map[string]any{"name": "backlog", "metric": "goflow_queue_depth", "op": ">", "threshold": 100, "for": "1m"}},
+	} {
+		if rec := do(t, h, "POST", req.path, req.body, nil); rec.Code != http.StatusCreated {
+			t.Fatalf("POST %s: expected 201, got %d: %s", req.path, rec.Code, rec.Body)
+		}
+	}
+	if err := staging.cron.AddWithLocation("nightly", "deploy-web", workflow.Daily(), map[string]any{"token": "{{ secrets.DEPLOY_TOKEN }}"}, time.UTC,
+		workflow.WithOverlap(workflow.QueueBehind), workflow.CatchUp(2), workflow.WithTemplate()); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := export(t, h)
+	if bytes.Contains(bundle, []byte("s3cret")) {
+		t.Fatalf("Secret embedded in bundle: %s", bundle)
+	}
+	var parsed api.Bundle
+	json.Unmarshal(bundle, &parsed)
+	if parsed.Version != api.BundleVersion || len(parsed.Agents) != 1 || len(parsed.Workflows) != 1 ||
+		len(parsed.Schedules) != 1 || len(parsed.Webhooks) != 1 || len(parsed.Alerts) != 1 {
+		t.Fatalf("Unexpected bundle: %s", bundle)
+	}
+	if s := parsed.Schedules[0]; s.Expression != "CRON_TZ=UTC @daily" || s.Overlap != workflow.QueueBehind || s.CatchUp != 2 || !s.Template {
+		t.Errorf("Expected the location as a CRON_TZ prefix and the overlap options, got %+v", s)
+	}
+	ref := parsed.Webhooks[0].SecretRef
+	if ref == "" {
+		t.Fatalf("Expected a secret reference: %s", bundle)
+	}
+
+	prod := newEnvironment(map[string]string{ref: "prod-secret"})
+	report := importReport(t, prod.handler, "/api/import?dry_run=true", bundle, http.StatusOK)
+	if report.Applied || report.Summary[api.ImportCreate] != 5 {
+		t.Fatalf("Unexpected dry run: %+v", report)
+	}
+	if again := export(t, prod.handler); bytes.Contains(again, []byte("support")) {
+		t.Fatalf("Dry run applied changes: %s", again)
+	}
+
+	report = importReport(t, prod.handler, "/api/import", bundle, http.StatusOK)
+	if !report.Applied || report.Summary[api.ImportCreate] != 5 {
+		t.Fatalf("Unexpected import: %+v", report)
+	}
+	if got := export(t, prod.handler); !bytes.Equal(got, bundle) {
+		t.Errorf("Round trip changed the bundle:\n%s\n%s", bundle, got)
+	}
+	if cfg, _ := prod.hooks.Get(parsed.Webhooks[0].ID); cfg.Secret != "prod-secret" {
+		t.Errorf("Expected the referenced secret, got %q", cfg.Secret)
+	}
+
+	report = importReport(t, prod.handler, "/api/import", bundle, http.StatusOK)
+	if report.Summary[api.ImportUnchanged] != 5 {
+		t.Errorf("Expected a repeated import to change nothing: %+v", report)
+	}
+}
+
+func TestImport_ScheduleConflict(t *testing.T) {
+	env := newEnvironment(nil)
+	if err := env.cron.Add("nightly", "cleanup", workflow.Daily(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, _ := json.Marshal(api.Bundle{
+		Version: api.BundleVersion,
+		Schedules: []api.ScheduleDefinition{
+			{ID: "nightly", Workflow: "report", Expression: workflow.At(6, 0), Enabled: true},
+			{ID: "hourly", Workflow: "report", Expression: workflow.Hourly(), Enabled: false},
+		},
+	})
+
+	report := importReport(t, env.handler, "/api/import?dry_run=true", bundle, http.StatusOK)
+	if len(report.Changes) != 2 || report.Changes[0].Action != api.ImportConflict || report.Changes[1].Action != api.ImportCreate {
+		t.Fatalf("Unexpected plan: %+v", report.Changes)
+	}
+
+	report = importReport(t, env.handler, "/api/import", bundle, http.StatusConflict)
+	if report.Applied {
+		t.Error("Expected nothing applied")
+	}
+	if s, _ := env.cron.Get("nightly"); s.WorkflowName != "cleanup" {
+		t.Errorf("Conflicting schedule was replaced: %+v", s)
+	}
+	if _, ok := env.cron.Get("hourly"); ok {
+		t.Error("Expected no schedules created when the import conflicts")
+	}
+
+	report = importReport(t, env.handler, "/api/import?overwrite=true", bundle, http.StatusOK)
+	if report.Changes[0].Action != api.ImportUpdate {
+		t.Fatalf("Expected an update, got %+v", report.Changes[0])
+	}
+	if s, _ := env.cron.Get("nightly"); s.WorkflowName != "report" || s.Expression != workflow.At(6, 0) {
+		t.Errorf("Schedule not replaced: %+v", s)
+	}
+	if s, _ := env.cron.Get("hourly"); s == nil || s.Enabled {
+		t.Errorf("Expected a disabled schedule, got %+v", s)
+	}
+}
+
+func TestImport_Invalid(t *testing.T) {
+	env := newEnvironment(nil)
+	bundle, _ := json.Marshal(api.Bundle{
+		Version:  api.BundleVersion,
+		Agents:   []api.AgentDefinition{{ID: "a", AllowedTools: []string{"nope"}}},
+		Webhooks: []api.WebhookDefinition{{ID: "wh", Path: "/x", Action: webhook.ActionEnqueueJob, JobType: "x", SecretRef: "MISSING"}},
+	})
+	report := importReport(t, env.handler, "/api/import", bundle, http.StatusBadRequest)
+	if report.Summary[api.ImportInvalid] != 2 {
+		t.Errorf("Expected two invalid definitions: %+v", report.Changes)
+	}
+
+	if rec := do(t, env.handler, "POST", "/api/import", []byte(`{"version":99}`), nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown version, got %d", rec.Code)
+	}
+}
+
+// failingCache rejects every write.
+type failingCache struct{ cache.Cache }
+
+func (failingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return errors.New("store unavailable")
+}
+
+func TestImport_RollsBackOnFailure(t *testing.T) {
+	env := newEnvironment(nil)
+	if err := env.cron.Add("nightly", "cleanup", workflow.Daily(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.hooks.SetStore(context.Background(), failingCache{cache.NewMemoryCache(cache.Config{})}); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, _ := json.Marshal(api.Bundle{
+		Version:   api.BundleVersion,
+		Agents:    []api.AgentDefinition{{ID: "support"}},
+		Alerts:    []alerts.Rule{{Name: "backlog", Metric: "goflow_queue_depth", Op: ">", Threshold: 100}},
+		Schedules: []api.ScheduleDefinition{{ID: "nightly", Workflow: "report", Expression: workflow.At(6, 0), Enabled: true}},
+		Webhooks:  []api.WebhookDefinition{{ID: "wh", Path: "/x", Action: webhook.ActionEnqueueJob, JobType: "x", Enabled: true}},
+	})
+	report := importReport(t, env.handler, "/api/import?overwrite=true", bundle, http.StatusInternalServerError)
+	if report.Applied {
+		t.Error("Expected the import not to be applied")
+	}
+
+	if s, _ := env.cron.Get("nightly"); s.WorkflowName != "cleanup" || s.Expression != workflow.Daily() {
+		t.Errorf("Expected the replaced schedule to be restored, got %+v", s)
+	}
+	if _, ok := env.hooks.Get("wh"); ok {
+		t.Error("Expected the failed webhook to be removed")
+	}
+	if got := export(t, env.handler); bytes.Contains(got, []byte("support")) || bytes.Contains(got, []byte("backlog")) {
+		t.Errorf("Expected earlier changes to be rolled back: %s", got)
+	}
+}
+
+func TestImport_ChecksJobSchemas(t *testing.T) {
+	env := newEnvironment(nil)
+	schemas := queue.NewJobSchemas()
+	schemas.Register("email", tools.Schema{
+		Type:       "object",
+		Properties: map[string]tools.Property{"to": {Type: "string"}},
+		Required:   []string{"to"},
+	})
+	env.cron.SetQueue(&recordingQueue{}, schemas)
+
+	bundle, _ := json.Marshal(api.Bundle{
+		Version:   api.BundleVersion,
+		Agents:    []api.AgentDefinition{{ID: "support"}},
+		Schedules: []api.ScheduleDefinition{{ID: "digest", JobType: "email", Expression: workflow.Daily(), Input: map[string]any{"subject": "hi"}, Enabled: true}},
+	})
+	report := importReport(t, env.handler, "/api/import", bundle, http.StatusBadRequest)
+	if len(report.Changes) != 2 || report.Changes[1].Action != api.ImportInvalid {
+		t.Fatalf("Expected the schedule to be invalid: %+v", report.Changes)
+	}
+	if got := export(t, env.handler); bytes.Contains(got, []byte("support")) {
+		t.Errorf("Expected nothing applied: %s", got)
+	}
+}
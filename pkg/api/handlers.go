@@ -3,10 +3,15 @@ package api
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
 )
 
 // handleAgents handles /api/agents
@@ -30,6 +35,7 @@ func (s *Server) listAgents(w http.ResponseWriter, r *http.Request) {
 	for _, a := range s.agents {
 		agents = append(agents, &AgentInfo{
 			ID:        a.ID,
+			RunID:     a.RunID,
 			Status:    a.Status,
 			CreatedAt: a.CreatedAt,
 			LastRunAt: a.LastRunAt,
@@ -42,22 +48,19 @@ func (s *Server) listAgents(w http.ResponseWriter, r *http.Request) {
 // AgentInfo is a serializable agent representation.
 type AgentInfo struct {
 	ID        string      `json:"id"`
+	RunID     string      `json:"run_id,omitempty"`
 	Status    AgentStatus `json:"status"`
 	CreatedAt time.Time   `json:"created_at"`
 	LastRunAt time.Time   `json:"last_run_at,omitempty"`
 }
 
 // CreateAgentRequest is the request body for creating an agent.
-type CreateAgentRequest struct {
-	ID            string `json:"id"`
-	MaxIterations int    `json:"max_iterations,omitempty"`
-}
+type CreateAgentRequest = AgentDefinition
 
 // createAgentHandler creates a new agent.
 func (s *Server) createAgentHandler(w http.ResponseWriter, r *http.Request) {
 	var req CreateAgentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if !s.decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -70,7 +73,13 @@ func (s *Server) createAgentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	managed := s.CreateAgent(req.ID)
+	managed, err := s.createAgentFrom(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	annotateAgent(r.Context(), managed.ID)
+	s.persistAgent(r.Context(), managed)
 
 	writeJSON(w, http.StatusCreated, map[string]any{
 		"id":         managed.ID,
@@ -91,6 +100,11 @@ func (s *Server) handleAgent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	agentID := parts[0]
+	annotateAgent(r.Context(), agentID)
+	if _, err := s.restoreAgent(r.Context(), agentID); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	action := ""
 	if len(parts) > 1 {
 		action = parts[1]
@@ -105,6 +119,19 @@ func (s *Server) handleAgent(w http.ResponseWriter, r *http.Request) {
 		s.handleAgentStop(w, r, agentID)
 	case "reset":
 		s.handleAgentReset(w, r, agentID)
+	case "confirm":
+		s.handleAgentConfirm(w, r, agentID)
+	case "runs":
+		// /api/agents/:id/runs/:run/events
+		if len(parts) != 4 || parts[2] == "" || parts[3] != "events" {
+			writeError(w, http.StatusNotFound, "unknown action")
+			return
+		}
+		if _, ok := s.GetAgent(agentID); !ok {
+			writeError(w, http.StatusNotFound, "agent not found")
+			return
+		}
+		s.serveRunEvents(w, r, agentRunKey(agentID, parts[2]))
 	default:
 		writeError(w, http.StatusNotFound, "unknown action")
 	}
@@ -121,6 +148,7 @@ func (s *Server) handleAgentInfo(w http.ResponseWriter, r *http.Request, agentID
 		s.mu.Lock()
 		delete(s.agents, agentID)
 		s.mu.Unlock()
+		s.forgetAgent(r.Context(), agentID)
 		writeJSON(w, http.StatusOK, map[string]string{"deleted": agentID})
 		return
 	}
@@ -133,6 +161,7 @@ func (s *Server) handleAgentInfo(w http.ResponseWriter, r *http.Request, agentID
 
 	writeJSON(w, http.StatusOK, &AgentInfo{
 		ID:        managed.ID,
+		RunID:     managed.RunID,
 		Status:    managed.Status,
 		CreatedAt: managed.CreatedAt,
 		LastRunAt: managed.LastRunAt,
@@ -143,14 +172,51 @@ func (s *Server) handleAgentInfo(w http.ResponseWriter, r *http.Request, agentID
 type RunRequest struct {
 	Task    string `json:"task"`
 	Timeout int    `json:"timeout,omitempty"` // seconds
+	// RunID names the run, so clients can follow its events before the
+	// run returns. Generated when empty.
+	RunID string `json:"run_id,omitempty"`
 }
 
 // RunResponse is the response from running an agent.
 type RunResponse struct {
+	RunID      string `json:"run_id"`
 	Output     string `json:"output"`
 	Iterations int    `json:"iterations"`
 	Success    bool   `json:"success"`
 	Error      string `json:"error,omitempty"`
+
+	// Set for ?preview=N runs.
+	Preview       bool `json:"preview,omitempty"`
+	WouldContinue bool `json:"would_continue,omitempty"`
+
+	// Cancelled is set for runs stopped through /stop.
+	Cancelled bool `json:"cancelled,omitempty"`
+	// Transcript is the conversation of preview and cancelled runs.
+	Transcript []core.Message `json:"transcript,omitempty"`
+}
+
+// StopResponse is the response from stopping an agent.
+type StopResponse struct {
+	Status AgentStatus `json:"status"`
+	// Run is the partial result of the run that was stopped, if any.
+	Run *RunResponse `json:"run,omitempty"`
+}
+
+// errRunStopped is the cancellation cause of runs stopped through /stop.
+var errRunStopped = errors.New("run stopped")
+
+// previewParam parses the ?preview=N query parameter. It returns 0 when
+// the parameter is absent.
+func previewParam(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("preview")
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("preview must be a positive integer")
+	}
+	return n, nil
 }
 
 // handleAgentRun runs a task on an agent.
@@ -171,9 +237,14 @@ func (s *Server) handleAgentRun(w http.ResponseWriter, r *http.Request, agentID
 		return
 	}
 
+	preview, err := previewParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	var req RunRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	if !s.decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -181,6 +252,15 @@ func (s *Server) handleAgentRun(w http.ResponseWriter, r *http.Request, agentID
 		writeError(w, http.StatusBadRequest, "task is required")
 		return
 	}
+	if req.RunID == "" {
+		req.RunID = fmt.Sprintf("%s-%d", agentID, time.Now().UnixNano())
+	} else if strings.Contains(req.RunID, "/") {
+		writeError(w, http.StatusBadRequest, "run_id cannot contain '/'")
+		return
+	} else if events, _ := s.runs.store.Since(r.Context(), agentRunKey(agentID, req.RunID), 0); len(events) > 0 {
+		writeError(w, http.StatusConflict, "run "+req.RunID+" already exists")
+		return
+	}
 
 	// Set timeout
 	timeout := time.Duration(req.Timeout) * time.Second
@@ -192,36 +272,67 @@ func (s *Server) handleAgentRun(w http.ResponseWriter, r *http.Request, agentID
 
 	ctx, cancel := context.WithTimeout(r.Context(), timeout)
 	defer cancel()
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	ctx = withRun(ctx, agentRunKey(agentID, req.RunID), req.RunID)
 
 	// Update status
 	s.mu.Lock()
 	managed.Status = AgentRunning
+	managed.RunID = req.RunID
 	managed.LastRunAt = time.Now()
+	managed.cancel = stop
+	managed.done = make(chan struct{})
 	s.mu.Unlock()
 
 	// Run agent
-	result, err := managed.Agent.Run(ctx, req.Task)
+	var result *agent.RunResult
+	if preview > 0 {
+		result, err = managed.Agent.PreviewRun(ctx, req.Task, preview)
+	} else {
+		result, err = managed.Agent.Run(ctx, req.Task)
+	}
 
-	// Update status
-	s.mu.Lock()
-	managed.Status = AgentIdle
-	s.mu.Unlock()
+	stopped := err != nil && errors.Is(context.Cause(ctx), errRunStopped)
+	if preview == 0 {
+		s.persistAgent(ctx, managed)
+	}
 
 	response := RunResponse{
-		Iterations: result.Iterations,
-		Success:    err == nil,
+		RunID:         req.RunID,
+		Iterations:    result.Iterations,
+		Success:       err == nil,
+		Preview:       result.Preview,
+		WouldContinue: result.WouldContinue,
+		Transcript:    result.Transcript,
 	}
 
-	if err != nil {
+	if stopped {
+		response.Error = errRunStopped.Error()
+		response.Cancelled = true
+		response.Transcript = managed.Agent.GetMessages()
+	} else if err != nil {
 		response.Error = err.Error()
 	} else {
 		response.Output = result.Output
 	}
 
+	// Update status
+	s.mu.Lock()
+	managed.Status = AgentIdle
+	if stopped {
+		managed.Status = AgentCancelled
+	}
+	managed.cancel = nil
+	managed.last = &response
+	close(managed.done)
+	s.mu.Unlock()
+
 	writeJSON(w, http.StatusOK, response)
 }
 
-// handleAgentStop stops a running agent.
+// handleAgentStop cancels an agent's in-flight run and returns what the run
+// produced so far. An idle agent is marked stopped.
 func (s *Server) handleAgentStop(w http.ResponseWriter, r *http.Request, agentID string) {
 	if r.Method != "POST" {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
@@ -235,10 +346,28 @@ func (s *Server) handleAgentStop(w http.ResponseWriter, r *http.Request, agentID
 	}
 
 	s.mu.Lock()
-	managed.Status = AgentStopped
+	cancel, done := managed.cancel, managed.done
+	if cancel == nil {
+		managed.Status = AgentStopped
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, StopResponse{Status: AgentStopped})
+		return
+	}
 	s.mu.Unlock()
 
-	writeJSON(w, http.StatusOK, map[string]string{"status": "stopped"})
+	// The run returns once the current LLM call or tool observes the
+	// cancellation.
+	cancel(errRunStopped)
+	select {
+	case <-done:
+	case <-r.Context().Done():
+		return
+	}
+
+	s.mu.RLock()
+	response := StopResponse{Status: managed.Status, Run: managed.last}
+	s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, response)
 }
 
 // handleAgentReset resets an agent's state.
@@ -259,6 +388,7 @@ func (s *Server) handleAgentReset(w http.ResponseWriter, r *http.Request, agentI
 	s.mu.Lock()
 	managed.Status = AgentIdle
 	s.mu.Unlock()
+	s.persistAgent(r.Context(), managed)
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "reset"})
 }
@@ -273,8 +403,7 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 
 	case "PUT":
 		var update Settings
-		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
-			writeError(w, http.StatusBadRequest, "invalid request body")
+		if !s.decodeJSON(w, r, &update) {
 			return
 		}
 
@@ -289,6 +418,8 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 		if len(update.AllowedOrigins) > 0 {
 			s.settings.AllowedOrigins = update.AllowedOrigins
 		}
+		// The request limits are left alone: a request must not be able
+		// to raise them.
 		s.settings.mu.Unlock()
 
 		writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
@@ -308,8 +439,7 @@ func (s *Server) handleChannels(w http.ResponseWriter, r *http.Request) {
 			Topic   string `json:"topic"`
 			Data    any    `json:"data"`
 		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeError(w, http.StatusBadRequest, "invalid request body")
+		if !s.decodeJSON(w, r, &req) {
 			return
 		}
 
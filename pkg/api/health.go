@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+// defaultHealthTimeout bounds a single provider ping.
+const defaultHealthTimeout = 10 * time.Second
+
+// LLMHealthReport is the response of GET /api/llm/health.
+type LLMHealthReport struct {
+	Status    core.HealthState    `json:"status"`
+	Providers []core.HealthStatus `json:"providers"`
+}
+
+// healthTracker remembers the last successful check per backend.
+type healthTracker struct {
+	lastSuccess map[string]time.Time
+	mu          sync.Mutex
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{lastSuccess: make(map[string]time.Time)}
+}
+
+// CheckLLMHealth pings every configured LLM backend and returns a report.
+// Composite LLMs implementing core.BackendProvider are expanded so each
+// backend is reported individually.
+func (s *Server) CheckLLMHealth(ctx context.Context) LLMHealthReport {
+	report := LLMHealthReport{Status: core.HealthOK}
+	if s.llm == nil {
+		report.Status = core.HealthUnknown
+		return report
+	}
+
+	for _, backend := range expandBackends(s.llm) {
+		status := s.checkBackend(ctx, backend)
+		switch {
+		case status.Status == core.HealthError:
+			report.Status = core.HealthError
+		case status.Status == core.HealthUnknown && report.Status == core.HealthOK:
+			report.Status = core.HealthUnknown
+		}
+		report.Providers = append(report.Providers, status)
+	}
+	return report
+}
+
+func (s *Server) checkBackend(ctx context.Context, llm core.LLM) core.HealthStatus {
+	status := core.HealthStatus{
+		Provider:  fmt.Sprintf("%T", llm),
+		Status:    core.HealthUnknown,
+		CheckedAt: time.Now(),
+	}
+	if info, ok := llm.(core.ModelInfo); ok {
+		status.Provider = info.Provider()
+		status.Model = info.Model()
+	}
+	key := status.Provider + "/" + status.Model
+
+	if checker, ok := llm.(core.LLMHealth); ok {
+		pingCtx, cancel := context.WithTimeout(ctx, defaultHealthTimeout)
+		err := checker.Ping(pingCtx)
+		cancel()
+
+		s.health.mu.Lock()
+		if err != nil {
+			status.Status = core.HealthError
+			status.Error = err.Error()
+		} else {
+			status.Status = core.HealthOK
+			s.health.lastSuccess[key] = status.CheckedAt
+		}
+		s.health.mu.Unlock()
+	}
+
+	s.health.mu.Lock()
+	status.LastSuccess = s.health.lastSuccess[key]
+	s.health.mu.Unlock()
+
+	return status
+}
+
+// expandBackends flattens composite LLMs into their backends.
+func expandBackends(llm core.LLM) []core.LLM {
+	composite, ok := llm.(core.BackendProvider)
+	if !ok {
+		return []core.LLM{llm}
+	}
+	var out []core.LLM
+	for _, b := range composite.Backends() {
+		out = append(out, expandBackends(b)...)
+	}
+	return out
+}
+
+// handleLLMHealth handles GET /api/llm/health.
+func (s *Server) handleLLMHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.CheckLLMHealth(r.Context()))
+}
+
+// handleHealth handles GET /health. With ?llm=true the LLM report is
+// included and the endpoint returns 503 if any backend is unhealthy.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.URL.Query().Get("llm") != "true" {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	}
+
+	report := s.CheckLLMHealth(r.Context())
+	status := http.StatusOK
+	if report.Status == core.HealthError {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, map[string]any{
+		"status": "ok",
+		"llm":    report,
+	})
+}
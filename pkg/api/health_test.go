@@ -0,0 +1,146 @@
+// Package api_test provides tests for the API server.
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/llm/anthropic"
+	"github.com/nuulab/goflow/pkg/llm/openai"
+)
+
+// providerBackend returns a fake provider API answering model lookups.
+func providerBackend(t *testing.T, status int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected GET, got %s", r.Method)
+		}
+		w.WriteHeader(status)
+		if status >= 400 {
+			w.Write([]byte(`{"error": {"message": "invalid api key"}}`))
+			return
+		}
+		w.Write([]byte(`{"id": "model"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// multiLLM is a composite LLM fronting several backends.
+type multiLLM struct {
+	core.LLM
+	backends []core.LLM
+}
+
+func (m *multiLLM) Backends() []core.LLM { return m.backends }
+
+func TestCheckLLMHealth_Healthy(t *testing.T) {
+	backend := providerBackend(t, http.StatusOK)
+	llm := openai.New("good-key", openai.WithBaseURL(backend.URL), openai.WithModel("gpt-test"))
+
+	server := api.NewServer(api.Config{LLM: llm})
+	report := server.CheckLLMHealth(context.Background())
+
+	if report.Status != core.HealthOK {
+		t.Fatalf("Expected ok, got %s", report.Status)
+	}
+	if len(report.Providers) != 1 {
+		t.Fatalf("Expected 1 provider, got %d", len(report.Providers))
+	}
+	p := report.Providers[0]
+	if p.Provider != "openai" || p.Model != "gpt-test" {
+		t.Errorf("Unexpected provider info: %+v", p)
+	}
+	if p.LastSuccess.IsZero() {
+		t.Error("Expected last success timestamp")
+	}
+}
+
+func TestCheckLLMHealth_Unauthorized(t *testing.T) {
+	backend := providerBackend(t, http.StatusUnauthorized)
+	llm := anthropic.New("bad-key", anthropic.WithBaseURL(backend.URL))
+
+	server := api.NewServer(api.Config{LLM: llm})
+	report := server.CheckLLMHealth(context.Background())
+
+	if report.Status != core.HealthError {
+		t.Fatalf("Expected error, got %s", report.Status)
+	}
+	p := report.Providers[0]
+	if p.Error == "" {
+		t.Error("Expected error message")
+	}
+	if !p.LastSuccess.IsZero() {
+		t.Error("Expected no last success")
+	}
+}
+
+func TestCheckLLMHealth_PerBackend(t *testing.T) {
+	good := providerBackend(t, http.StatusOK)
+	bad := providerBackend(t, http.StatusUnauthorized)
+
+	llm := &multiLLM{backends: []core.LLM{
+		anthropic.New("bad-key", anthropic.WithBaseURL(bad.URL)),
+		openai.New("good-key", openai.WithBaseURL(good.URL)),
+	}}
+
+	server := api.NewServer(api.Config{LLM: llm})
+	report := server.CheckLLMHealth(context.Background())
+
+	if len(report.Providers) != 2 {
+		t.Fatalf("Expected 2 backends, got %d", len(report.Providers))
+	}
+	if report.Providers[0].Provider != "anthropic" || report.Providers[0].Status != core.HealthError {
+		t.Errorf("Expected anthropic unhealthy, got %+v", report.Providers[0])
+	}
+	if report.Providers[1].Provider != "openai" || report.Providers[1].Status != core.HealthOK {
+		t.Errorf("Expected openai healthy, got %+v", report.Providers[1])
+	}
+	if report.Status != core.HealthError {
+		t.Errorf("Expected overall error, got %s", report.Status)
+	}
+}
+
+func TestLLMHealthEndpoint(t *testing.T) {
+	backend := providerBackend(t, http.StatusOK)
+	llm := openai.New("good-key", openai.WithBaseURL(backend.URL))
+	server := api.NewServer(api.Config{LLM: llm})
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/llm/health", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var report api.LLMHealthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if report.Status != core.HealthOK || len(report.Providers) != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestReadinessIncludesLLM(t *testing.T) {
+	backend := providerBackend(t, http.StatusUnauthorized)
+	llm := openai.New("bad-key", openai.WithBaseURL(backend.URL))
+	server := api.NewServer(api.Config{LLM: llm})
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Plain health check should not probe the LLM, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/health?llm=true", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with failing LLM, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/nuulab/goflow/pkg/integrations/sessions"
+)
+
+// handleSessions handles GET /api/integrations/sessions.
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if s.sessions == nil {
+		writeError(w, http.StatusServiceUnavailable, "session tracking not configured")
+		return
+	}
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	list := s.sessions.Sessions()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"sessions": list,
+		"count":    len(list),
+	})
+}
+
+// handleSession handles POST /api/integrations/sessions/:provider/:id/kill.
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	if s.sessions == nil {
+		writeError(w, http.StatusServiceUnavailable, "session tracking not configured")
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/integrations/sessions/"), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] != "kill" {
+		writeError(w, http.StatusNotFound, "unknown action")
+		return
+	}
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if err := s.sessions.Kill(r.Context(), parts[0], parts[1]); err != nil {
+		if errors.Is(err, sessions.ErrNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"killed": parts[1]})
+}
@@ -0,0 +1,65 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/integrations/e2b"
+	"github.com/nuulab/goflow/pkg/integrations/sessions"
+)
+
+func TestSessionsAPI_ListAndKill(t *testing.T) {
+	var deleted []string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			w.Write([]byte(`{"sandboxId": "sb-1"}`))
+		case "DELETE":
+			deleted = append(deleted, r.URL.Path)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer provider.Close()
+
+	tracker := sessions.NewTracker()
+	client := e2b.New("key").WithBaseURL(provider.URL).WithTracker(tracker)
+	ctx := sessions.WithOwner(context.Background(), sessions.Owner{Tenant: "acme", RunID: "run-1"})
+	if _, err := client.CreateSandbox(ctx, e2b.CreateSandboxOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	h := api.NewServer(api.Config{Sessions: tracker}).Handler()
+
+	rec := do(t, h, "GET", "/api/integrations/sessions", nil, nil)
+	var list struct {
+		Sessions []sessions.Session `json:"sessions"`
+		Count    int                `json:"count"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if rec.Code != http.StatusOK || list.Count != 1 {
+		t.Fatalf("Expected one session, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if s := list.Sessions[0]; s.ID != "sb-1" || s.Provider != "e2b" || s.Tenant != "acme" || s.RunID != "run-1" {
+		t.Errorf("Unexpected session: %+v", s)
+	}
+
+	if rec := do(t, h, "POST", "/api/integrations/sessions/e2b/sb-1/kill", nil, nil); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(deleted) != 1 || deleted[0] != "/sandboxes/sb-1" || len(tracker.Sessions()) != 0 {
+		t.Errorf("deleted = %v, tracked = %+v", deleted, tracker.Sessions())
+	}
+	if rec := do(t, h, "POST", "/api/integrations/sessions/e2b/sb-1/kill", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an untracked session, got %d", rec.Code)
+	}
+}
+
+func TestSessionsAPI_NotConfigured(t *testing.T) {
+	h := api.NewServer(api.Config{}).Handler()
+	if rec := do(t, h, "GET", "/api/integrations/sessions", nil, nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", rec.Code)
+	}
+}
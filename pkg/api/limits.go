@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Default request limits.
+const (
+	DefaultMaxBodyBytes          = 1 << 20  // JSON API requests
+	DefaultMaxUploadBytes        = 32 << 20 // multipart uploads
+	DefaultMaxStreamMessageBytes = 64 << 10 // each WebSocket message
+	DefaultMaxJSONDepth          = 64
+)
+
+// bodyLimits returns the configured limits, falling back to the defaults
+// for unset values.
+func (s *Server) bodyLimits() (body, upload, stream int64, depth int) {
+	s.settings.mu.RLock()
+	defer s.settings.mu.RUnlock()
+	body, upload, stream, depth = s.settings.MaxBodyBytes, s.settings.MaxUploadBytes, s.settings.MaxStreamMessageBytes, s.settings.MaxJSONDepth
+	if body <= 0 {
+		body = DefaultMaxBodyBytes
+	}
+	if upload <= 0 {
+		upload = DefaultMaxUploadBytes
+	}
+	if stream <= 0 {
+		stream = DefaultMaxStreamMessageBytes
+	}
+	if depth <= 0 {
+		depth = DefaultMaxJSONDepth
+	}
+	return body, upload, stream, depth
+}
+
+// limitBody caps request bodies. Multipart requests get the upload limit,
+// everything else the body limit. WebSocket connections are limited per
+// message instead. Bodies whose declared length exceeds the limit are
+// rejected up front; others fail with 413 when read past the limit.
+func (s *Server) limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ws" || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit, upload, _, _ := s.bodyLimits()
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); strings.HasPrefix(mediaType, "multipart/") {
+			limit = upload
+		}
+		if r.ContentLength > limit {
+			writeBodyTooLarge(w, limit)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	w.Header().Set("Content-Type", "application/json")
+	writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", limit))
+}
+
+// decodeJSON decodes the request body into v. It writes a 413 response for
+// bodies over the limit and a 400 response for invalid or too deeply nested
+// JSON, and reports whether decoding succeeded.
+func (s *Server) decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeBodyTooLarge(w, tooLarge.Limit)
+			return false
+		}
+		writeError(w, http.StatusBadRequest, "failed to read request body")
+		return false
+	}
+
+	_, _, _, maxDepth := s.bodyLimits()
+	if err := checkJSONDepth(data, maxDepth); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return false
+	}
+	return true
+}
+
+// checkJSONDepth fails if objects and arrays in data nest deeper than max.
+// It only tracks brackets outside strings and leaves validation to the decoder.
+func checkJSONDepth(data []byte, max int) error {
+	depth := 0
+	inString, escaped := false, false
+	for _, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > max {
+				return fmt.Errorf("JSON nesting exceeds maximum depth of %d", max)
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return nil
+}
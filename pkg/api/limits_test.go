@@ -0,0 +1,154 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/webhook"
+)
+
+// chunked hides the body length so the limit is only hit while reading.
+type chunked struct{ io.Reader }
+
+func postUnsized(h http.Handler, path string, body []byte, contentType string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, chunked{bytes.NewReader(body)})
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func errorMessage(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid JSON error response %q: %v", rec.Body, err)
+	}
+	return body.Error
+}
+
+func TestBodyLimit_DeclaredLength(t *testing.T) {
+	h := api.NewServer(api.Config{}).Handler()
+	body := []byte(`{"id": "` + strings.Repeat("a", api.DefaultMaxBodyBytes) + `"}`)
+
+	rec := do(t, h, "POST", "/api/agents", body, map[string]string{"Content-Type": "application/json"})
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected 413, got %d", rec.Code)
+	}
+	if msg := errorMessage(t, rec); !strings.Contains(msg, "exceeds") {
+		t.Errorf("Unexpected error: %q", msg)
+	}
+}
+
+func TestBodyLimit_UnsizedBody(t *testing.T) {
+	h := api.NewServer(api.Config{}).Handler()
+	body := []byte(`{"task": "` + strings.Repeat("a", 2*api.DefaultMaxBodyBytes) + `"}`)
+
+	rec := postUnsized(h, "/api/agents", body, "application/json")
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected 413, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestBodyLimit_SmallPayloadUnaffected(t *testing.T) {
+	h := api.NewServer(api.Config{}).Handler()
+
+	rec := do(t, h, "POST", "/api/agents", map[string]any{"id": "small"}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestBodyLimit_ConfiguredLimit(t *testing.T) {
+	settings := api.DefaultSettings()
+	settings.MaxBodyBytes = 64
+	h := api.NewServer(api.Config{Settings: settings}).Handler()
+
+	rec := do(t, h, "POST", "/api/agents", map[string]any{"id": strings.Repeat("x", 100)}, nil)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413, got %d", rec.Code)
+	}
+}
+
+func TestBodyLimit_MultipartUsesUploadLimit(t *testing.T) {
+	h := api.NewServer(api.Config{}).Handler()
+	body := bytes.Repeat([]byte("x"), 2*api.DefaultMaxBodyBytes)
+
+	rec := do(t, h, "POST", "/api/agents", body, map[string]string{"Content-Type": "multipart/form-data; boundary=b"})
+	if rec.Code == http.StatusRequestEntityTooLarge {
+		t.Error("Multipart body under the upload limit was rejected as too large")
+	}
+
+	body = bytes.Repeat([]byte("x"), api.DefaultMaxUploadBytes+1)
+	rec = do(t, h, "POST", "/api/agents", body, map[string]string{"Content-Type": "multipart/form-data; boundary=b"})
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 over the upload limit, got %d", rec.Code)
+	}
+}
+
+func TestJSONDepthLimit(t *testing.T) {
+	h := api.NewServer(api.Config{}).Handler()
+	deep := strings.Repeat("[", 10000) + strings.Repeat("]", 10000)
+	body := []byte(`{"id": "deep", "max_iterations": ` + deep + `}`)
+
+	rec := do(t, h, "POST", "/api/agents", body, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", rec.Code)
+	}
+	if msg := errorMessage(t, rec); !strings.Contains(msg, "depth") {
+		t.Errorf("Unexpected error: %q", msg)
+	}
+
+	// Brackets inside strings do not count.
+	rec = do(t, h, "POST", "/api/agents", map[string]any{"id": strings.Repeat("[", 100)}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestBodyLimit_WebhookDelivery(t *testing.T) {
+	hooks := webhook.NewWebhookHandler(&recordingQueue{}, nil)
+	h := api.NewServer(api.Config{Webhooks: hooks}).Handler()
+
+	rec := do(t, h, "POST", "/api/webhooks", api.WebhookRequest{
+		Path:    "/big",
+		Action:  webhook.ActionEnqueueJob,
+		JobType: "big",
+	}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+
+	body := []byte(`{"data": "` + strings.Repeat("a", 2*api.DefaultMaxBodyBytes) + `"}`)
+	rec = postUnsized(h, "/webhooks/big", body, "application/json")
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413, got %d", rec.Code)
+	}
+}
+
+func TestBodyLimit_NotRaisedThroughSettings(t *testing.T) {
+	settings := api.DefaultSettings()
+	settings.MaxBodyBytes = 64
+	h := api.NewServer(api.Config{Settings: settings}).Handler()
+
+	rec := do(t, h, "PUT", "/api/settings", map[string]any{"max_body_bytes": 1 << 30, "max_json_depth": 1000}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if settings.MaxBodyBytes != 64 || settings.MaxJSONDepth != api.DefaultMaxJSONDepth {
+		t.Errorf("Expected the limits unchanged, got %d bytes and depth %d", settings.MaxBodyBytes, settings.MaxJSONDepth)
+	}
+	rec = do(t, h, "POST", "/api/agents", map[string]any{"id": strings.Repeat("x", 100)}, nil)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/agent"
+)
+
+// persistedAgent is what the server stores for a managed agent: enough to
+// rebuild it, plus its conversation from agent.SaveState.
+type persistedAgent struct {
+	Definition AgentDefinition `json:"definition"`
+	CreatedAt  time.Time       `json:"created_at"`
+	LastRunAt  time.Time       `json:"last_run_at,omitempty"`
+	State      json.RawMessage `json:"state"`
+}
+
+// persistAgent saves managed to the agent store, if one is configured.
+// Failures are logged; the in-memory agent stays authoritative.
+func (s *Server) persistAgent(ctx context.Context, managed *ManagedAgent) {
+	if s.agentStore == nil {
+		return
+	}
+	state, err := managed.Agent.SaveState()
+	if err == nil {
+		s.mu.RLock()
+		record := persistedAgent{
+			Definition: managed.Definition,
+			CreatedAt:  managed.CreatedAt,
+			LastRunAt:  managed.LastRunAt,
+			State:      state,
+		}
+		s.mu.RUnlock()
+
+		var data []byte
+		if data, err = json.Marshal(record); err == nil {
+			err = s.agentStore.Save(context.WithoutCancel(ctx), managed.ID, data)
+		}
+	}
+	if err != nil && s.logger != nil {
+		s.logger.Error("agent state not saved", "agent_id", managed.ID, "error", err)
+	}
+}
+
+// restoreAgent loads the agent with id from the agent store when it is not
+// already managed by this server.
+func (s *Server) restoreAgent(ctx context.Context, id string) (*ManagedAgent, error) {
+	if managed, ok := s.GetAgent(id); ok || s.agentStore == nil {
+		return managed, nil
+	}
+
+	data, err := s.agentStore.Load(ctx, id)
+	if errors.Is(err, agent.ErrStateNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var record persistedAgent
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("agent %s: invalid stored state: %w", id, err)
+	}
+	record.Definition.ID = id
+	a, err := s.buildAgent(record.Definition)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.LoadState(record.State); err != nil {
+		return nil, fmt.Errorf("agent %s: %w", id, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if managed, ok := s.agents[id]; ok {
+		return managed, nil // restored by a concurrent request
+	}
+	managed := &ManagedAgent{
+		ID:         id,
+		Agent:      a,
+		Definition: record.Definition,
+		Status:     AgentIdle,
+		CreatedAt:  record.CreatedAt,
+		LastRunAt:  record.LastRunAt,
+	}
+	s.agents[id] = managed
+	return managed, nil
+}
+
+// forgetAgent removes the agent with id from the agent store.
+func (s *Server) forgetAgent(ctx context.Context, id string) {
+	if s.agentStore == nil {
+		return
+	}
+	if err := s.agentStore.Delete(ctx, id); err != nil && s.logger != nil {
+		s.logger.Error("agent state not deleted", "agent_id", id, "error", err)
+	}
+}
@@ -0,0 +1,60 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/cache"
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+// answeringLLM answers every task at once and records the conversation it
+// was sent.
+type answeringLLM struct {
+	loopingLLM
+	sent [][]core.Message
+}
+
+func (l *answeringLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	l.sent = append(l.sent, messages)
+	return `{"action": "final_answer", "action_input": "noted"}`, nil
+}
+
+func TestAgentStore_ContinuesAcrossServers(t *testing.T) {
+	store := agent.NewCacheStore(cache.NewMemoryCache(cache.Config{}), 0)
+
+	first := api.NewServer(api.Config{LLM: &answeringLLM{}, AgentStore: store}).Handler()
+	do(t, first, "POST", "/api/agents", map[string]any{"id": "a1", "system_prompt": "You are a note taker.", "history": 10}, nil)
+	if rec := do(t, first, "POST", "/api/agents/a1/run", api.RunRequest{Task: "remember the code 4711"}, nil); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// A new process sharing the store continues the conversation.
+	llm := &answeringLLM{}
+	second := api.NewServer(api.Config{LLM: llm, AgentStore: store}).Handler()
+	rec := do(t, second, "POST", "/api/agents/a1/run", api.RunRequest{Task: "what was the code?"}, nil)
+	var run api.RunResponse
+	json.Unmarshal(rec.Body.Bytes(), &run)
+	if !run.Success || len(llm.sent) != 1 {
+		t.Fatalf("Unexpected run: %s", rec.Body)
+	}
+	var sent []string
+	for _, m := range llm.sent[0] {
+		sent = append(sent, m.Content)
+	}
+	if all := strings.Join(sent, "\n"); !strings.Contains(all, "You are a note taker.") || !strings.Contains(all, "remember the code 4711") {
+		t.Errorf("Expected the restored prompt and earlier task, got:\n%s", all)
+	}
+
+	// Deleting an agent removes its stored state.
+	do(t, second, "DELETE", "/api/agents/a1", nil, nil)
+	third := api.NewServer(api.Config{LLM: llm, AgentStore: store}).Handler()
+	if rec := do(t, third, "GET", "/api/agents/a1", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 after delete, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,97 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// loopingLLM never gives a final answer.
+type loopingLLM struct{ calls int }
+
+func (l *loopingLLM) Generate(ctx context.Context, prompt string, opts ...core.Option) (string, error) {
+	return "", nil
+}
+
+func (l *loopingLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	l.calls++
+	return `{"action": "echo", "action_input": "again"}`, nil
+}
+
+func (l *loopingLLM) Stream(ctx context.Context, prompt string, opts ...core.Option) (<-chan string, error) {
+	return nil, nil
+}
+
+func (l *loopingLLM) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	return nil, nil
+}
+
+func TestAgentRun_Preview(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(tools.QuickTool("echo", "echo", func(ctx context.Context, input string) (string, error) {
+		return input, nil
+	}))
+	llm := &loopingLLM{}
+	h := api.NewServer(api.Config{LLM: llm, Registry: registry}).Handler()
+
+	rec := do(t, h, "POST", "/api/agents/a1/run?preview=2", api.RunRequest{Task: "loop"}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp api.RunResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if !resp.Success || !resp.Preview || !resp.WouldContinue || resp.Iterations != 2 {
+		t.Errorf("Expected successful preview that would continue, got %+v", resp)
+	}
+	if llm.calls != 2 || len(resp.Transcript) != 6 {
+		t.Errorf("Expected 2 LLM calls and a 6 message transcript, got %d and %d", llm.calls, len(resp.Transcript))
+	}
+
+	rec = do(t, h, "POST", "/api/agents/a1/run?preview=zero", api.RunRequest{Task: "loop"}, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid preview, got %d", rec.Code)
+	}
+}
+
+func TestWorkflowRun_Preview(t *testing.T) {
+	engine := workflow.NewEngine(nil)
+	ran := 0
+	step := func(ctx context.Context, state *workflow.State) (any, error) {
+		ran++
+		return ran, nil
+	}
+	engine.Register(workflow.New("three").
+		Step("one", step).Then().
+		Step("two", step).Then().
+		Step("three", step).Then().
+		Build())
+	h := api.NewServer(api.Config{Engine: engine}).Handler()
+
+	rec := do(t, h, "POST", "/api/workflows/three/run?preview=1", map[string]any{"input": map[string]any{"k": "v"}}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		State *workflow.State `json:"state"`
+		Error string          `json:"error"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if ran != 1 || !resp.State.Preview || !resp.State.WouldContinue || resp.State.Data["k"] != "v" {
+		t.Errorf("Expected one-step preview, ran %d steps: %+v", ran, resp.State)
+	}
+
+	rec = do(t, h, "POST", "/api/workflows/missing/run?preview=1", nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown workflow, got %d", rec.Code)
+	}
+	rec = do(t, h, "GET", "/api/workflows/three/run", nil, nil)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,421 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultHeartbeat is how often idle event streams send a comment.
+	DefaultHeartbeat = 15 * time.Second
+	// MaxEventWait caps the wait of a long-poll request.
+	MaxEventWait = time.Minute
+
+	defaultMaxRunEvents = 1000
+	defaultMaxRuns      = 256
+	redisRunEventsTTL   = 24 * time.Hour
+)
+
+// RunEvent is an event of an agent or workflow run. IDs start at 1 and
+// increase by one per run, so a client that saw event N can ask for the
+// events after N.
+type RunEvent struct {
+	ID    int64  `json:"id"`
+	RunID string `json:"run_id"`
+	// Final is set on the last event of a run.
+	Final bool `json:"final,omitempty"`
+	Event
+}
+
+// RunEventStore buffers the recent events of runs.
+type RunEventStore interface {
+	// Append stores ev under key. Events are appended in ID order.
+	Append(ctx context.Context, key string, ev RunEvent) error
+	// Since returns the buffered events under key with an ID above after.
+	Since(ctx context.Context, key string, after int64) ([]RunEvent, error)
+}
+
+// ============ Memory Store ============
+
+// MemoryRunEventStore keeps the last events of the most recent runs in
+// memory.
+type MemoryRunEventStore struct {
+	max     int
+	maxRuns int
+	runs    map[string][]RunEvent
+	order   []string // keys, oldest run first
+	mu      sync.Mutex
+}
+
+// NewMemoryRunEventStore creates a store keeping up to max events per run
+// for the last 256 runs. A max of zero uses the default of 1000.
+func NewMemoryRunEventStore(max int) *MemoryRunEventStore {
+	if max <= 0 {
+		max = defaultMaxRunEvents
+	}
+	return &MemoryRunEventStore{
+		max:     max,
+		maxRuns: defaultMaxRuns,
+		runs:    make(map[string][]RunEvent),
+	}
+}
+
+// Append stores ev, dropping the oldest events beyond the cap and the
+// oldest runs beyond the run limit.
+func (s *MemoryRunEventStore) Append(ctx context.Context, key string, ev RunEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, ok := s.runs[key]
+	if !ok {
+		s.order = append(s.order, key)
+		if len(s.order) > s.maxRuns {
+			delete(s.runs, s.order[0])
+			s.order = s.order[1:]
+		}
+	}
+	list = append(list, ev)
+	if len(list) > s.max {
+		list = list[len(list)-s.max:]
+	}
+	s.runs[key] = list
+	return nil
+}
+
+// Since returns the events under key with an ID above after.
+func (s *MemoryRunEventStore) Since(ctx context.Context, key string, after int64) ([]RunEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []RunEvent
+	for _, ev := range s.runs[key] {
+		if ev.ID > after {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+// ============ Redis Store ============
+
+// RedisRunEventStore keeps the events of each run in a capped Redis stream
+// (Redis/DragonflyDB) that expires a day after the run's last event, so
+// reconnecting clients can catch up across server restarts. Stream entry
+// IDs are 0-<event ID>, so Since reads from the client's position.
+type RedisRunEventStore struct {
+	client *redis.Client
+	prefix string
+	max    int64
+}
+
+// NewRedisRunEventStore creates a store keeping about max events per run.
+// A max of zero uses the default of 1000.
+func NewRedisRunEventStore(client *redis.Client, max int64) *RedisRunEventStore {
+	if max <= 0 {
+		max = defaultMaxRunEvents
+	}
+	return &RedisRunEventStore{client: client, prefix: "goflow:runs:", max: max}
+}
+
+// Append adds ev to the run's stream, trimming it to about the cap.
+func (s *RedisRunEventStore) Append(ctx context.Context, key string, ev RunEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.prefix + key,
+		ID:     fmt.Sprintf("0-%d", ev.ID),
+		MaxLen: s.max,
+		Approx: true,
+		Values: map[string]any{"event": data},
+	})
+	pipe.Expire(ctx, s.prefix+key, redisRunEventsTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Since returns up to the cap of events under key with an ID above after,
+// reading the stream from there.
+func (s *RedisRunEventStore) Since(ctx context.Context, key string, after int64) ([]RunEvent, error) {
+	start := fmt.Sprintf("0-%d", max(after, 0)+1)
+	msgs, err := s.client.XRangeN(ctx, s.prefix+key, start, "+", s.max).Result()
+	if err != nil {
+		return nil, err
+	}
+	var events []RunEvent
+	for _, msg := range msgs {
+		data, _ := msg.Values["event"].(string)
+		var ev RunEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return nil, fmt.Errorf("api: invalid run event %s: %w", msg.ID, err)
+		}
+		if ev.ID > after {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+// ============ Feed ============
+
+// runFeed numbers run events, stores them and wakes the clients waiting
+// for them.
+type runFeed struct {
+	store   RunEventStore
+	streams map[string]*runStream
+	mu      sync.Mutex
+}
+
+// runStream is the state of a run with publishers or watchers.
+type runStream struct {
+	seq     int64
+	loaded  bool          // seq was resumed from the store
+	done    bool          // the final event was published
+	changed chan struct{} // closed and replaced on every event
+	refs    int
+	mu      sync.Mutex // serializes appends
+}
+
+func newRunFeed(store RunEventStore) *runFeed {
+	return &runFeed{store: store, streams: make(map[string]*runStream)}
+}
+
+func (f *runFeed) acquire(key string) *runStream {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st, ok := f.streams[key]
+	if !ok {
+		st = &runStream{changed: make(chan struct{})}
+		f.streams[key] = st
+	}
+	st.refs++
+	return st
+}
+
+// release drops a reference to st. Streams are forgotten once nobody uses
+// them and the run has finished or never published anything.
+func (f *runFeed) release(key string, st *runStream) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st.refs--
+	if st.refs == 0 && (st.done || st.seq == 0) && f.streams[key] == st {
+		delete(f.streams, key)
+	}
+}
+
+// changed returns a channel closed by the next event of st.
+func (f *runFeed) changed(st *runStream) <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return st.changed
+}
+
+// publish appends e to the events of the run stored under key.
+func (f *runFeed) publish(ctx context.Context, key, runID string, e Event, final bool) error {
+	st := f.acquire(key)
+	defer f.release(key, st)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !st.loaded {
+		// Continue the numbering of a run resumed by another process.
+		if prev, err := f.store.Since(ctx, key, 0); err == nil && len(prev) > 0 {
+			st.seq = prev[len(prev)-1].ID
+		}
+		st.loaded = true
+	}
+
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	ev := RunEvent{ID: st.seq + 1, RunID: runID, Final: final, Event: e}
+	if err := f.store.Append(ctx, key, ev); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	st.seq = ev.ID
+	st.done = final
+	close(st.changed)
+	st.changed = make(chan struct{})
+	f.mu.Unlock()
+	return nil
+}
+
+// ============ Run Context ============
+
+type runContextKey struct{}
+
+type runRef struct {
+	key string
+	id  string
+}
+
+// withRun marks ctx as belonging to the run stored under key.
+func withRun(ctx context.Context, key, runID string) context.Context {
+	return context.WithValue(ctx, runContextKey{}, runRef{key: key, id: runID})
+}
+
+func agentRunKey(agentID, runID string) string { return "agent:" + agentID + ":" + runID }
+
+func workflowRunKey(runID string) string { return "workflow:" + runID }
+
+// emit broadcasts e to WebSocket clients and, within a run, adds it to the
+// run's events.
+func (s *Server) emit(ctx context.Context, e Event, final bool) {
+	s.hub.Broadcast(e)
+	if ref, ok := ctx.Value(runContextKey{}).(runRef); ok {
+		if err := s.runs.publish(context.WithoutCancel(ctx), ref.key, ref.id, e, final); err != nil && s.logger != nil {
+			s.logger.Error("run event not stored", "run_id", ref.id, "error", err)
+		}
+	}
+}
+
+// ============ Handlers ============
+
+// EventsResponse is the response of a long-poll events request.
+type EventsResponse struct {
+	Events []RunEvent `json:"events"`
+	// Next is the ID to pass as ?after= in the next request.
+	Next int64 `json:"next"`
+	// Done is set once the run's final event has been returned.
+	Done bool `json:"done"`
+}
+
+// serveRunEvents streams the events of the run stored under key as
+// server-sent events, or answers a long-poll request when the client does
+// not accept text/event-stream.
+//
+// Streams resume after the Last-Event-ID header or ?after=, replaying the
+// buffered events first. Long-poll requests wait up to ?wait= for events
+// after ?after=.
+func (s *Server) serveRunEvents(w http.ResponseWriter, r *http.Request, key string) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	query := r.URL.Query()
+	after, err := eventIDParam(r.Header.Get("Last-Event-ID"), query.Get("after"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	st := s.runs.acquire(key)
+	defer s.runs.release(key, st)
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		wait, err := time.ParseDuration(query.Get("wait"))
+		if query.Get("wait") == "" {
+			wait, err = 0, nil
+		}
+		if err != nil || wait < 0 {
+			writeError(w, http.StatusBadRequest, "wait must be a duration such as 30s")
+			return
+		}
+		s.pollRunEvents(w, r, key, st, after, min(wait, MaxEventWait))
+		return
+	}
+	s.streamRunEvents(w, r, key, st, after)
+}
+
+// eventIDParam returns the last event ID a client has seen.
+func eventIDParam(values ...string) (int64, error) {
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			return 0, fmt.Errorf("invalid event ID %q", v)
+		}
+		return id, nil
+	}
+	return 0, nil
+}
+
+func (s *Server) pollRunEvents(w http.ResponseWriter, r *http.Request, key string, st *runStream, after int64, wait time.Duration) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		changed := s.runs.changed(st)
+		events, err := s.runs.store.Since(r.Context(), key, after)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(events) > 0 || wait == 0 {
+			resp := EventsResponse{Events: events, Next: after}
+			if events == nil {
+				resp.Events = []RunEvent{}
+			}
+			if n := len(events); n > 0 {
+				resp.Next = events[n-1].ID
+				resp.Done = events[n-1].Final
+			}
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			wait = 0
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) streamRunEvents(w http.ResponseWriter, r *http.Request, key string, st *runStream, after int64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(s.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		changed := s.runs.changed(st)
+		events, err := s.runs.store.Since(r.Context(), key, after)
+		if err != nil {
+			return
+		}
+		for _, ev := range events {
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+			after = ev.ID
+			if ev.Final {
+				flusher.Flush()
+				return
+			}
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,49 @@
+//go:build integration
+
+// Package api_test runs the run event store against Redis. Set
+// GOFLOW_TEST_REDIS_ADDR and run with -tags integration.
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/nuulab/goflow/pkg/api"
+)
+
+func TestRedisRunEventStore(t *testing.T) {
+	addr := os.Getenv("GOFLOW_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("GOFLOW_TEST_REDIS_ADDR not set")
+	}
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	key := fmt.Sprintf("run-test-%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		client.Del(ctx, "goflow:runs:"+key)
+		client.Close()
+	})
+
+	store := api.NewRedisRunEventStore(client, 3)
+	for id := int64(1); id <= 5; id++ {
+		if err := store.Append(ctx, key, api.RunEvent{ID: id, RunID: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := store.Since(ctx, key, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].ID != 4 || events[1].ID != 5 {
+		t.Errorf("Expected events 4 and 5, got %+v", events)
+	}
+	if events, _ := store.Since(ctx, key, 5); len(events) != 0 {
+		t.Errorf("Expected no events after the last, got %+v", events)
+	}
+}
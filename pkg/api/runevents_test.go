@@ -0,0 +1,201 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/internal/sse"
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// gatedLLM calls echo until steps calls have been made, then answers. Each
+// call waits for a value on gate.
+type gatedLLM struct {
+	loopingLLM
+	gate  chan struct{}
+	steps int
+}
+
+func (l *gatedLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	select {
+	case <-l.gate:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	l.calls++
+	if l.calls > l.steps {
+		return `{"action": "final_answer", "action_input": "done"}`, nil
+	}
+	return `{"action": "echo", "action_input": "again"}`, nil
+}
+
+func openEvents(t *testing.T, url, lastEventID string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return resp
+}
+
+func readEvent(t *testing.T, reader *sse.Reader) api.RunEvent {
+	t.Helper()
+	raw, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Reading event: %v", err)
+	}
+	var ev api.RunEvent
+	if err := json.Unmarshal([]byte(raw.Data), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if raw.ID != strconv.FormatInt(ev.ID, 10) || raw.Event != ev.Type {
+		t.Errorf("SSE fields %q/%q do not match event %d/%s", raw.ID, raw.Event, ev.ID, ev.Type)
+	}
+	return ev
+}
+
+func TestRunEvents_ResumeWithLastEventID(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(tools.QuickTool("echo", "echo", func(ctx context.Context, input string) (string, error) {
+		return input, nil
+	}))
+	llm := &gatedLLM{gate: make(chan struct{}), steps: 3}
+	h := api.NewServer(api.Config{LLM: llm, Registry: registry, Heartbeat: 10 * time.Millisecond}).Handler()
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	do(t, h, "POST", "/api/agents", map[string]any{"id": "a1"}, nil)
+	url := srv.URL + "/api/agents/a1/runs/r1/events"
+
+	// Subscribe before the run starts; idle streams get heartbeats.
+	resp := openEvents(t, url, "")
+	buffered := bufio.NewReader(resp.Body)
+	if line, err := buffered.ReadString('\n'); err != nil || line != ": heartbeat\n" {
+		t.Fatalf("Expected a heartbeat, got %q (%v)", line, err)
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- do(t, h, "POST", "/api/agents/a1/run", api.RunRequest{Task: "loop", RunID: "r1"}, nil)
+	}()
+
+	// Started, tool call and step of the first iteration, then disconnect.
+	llm.gate <- struct{}{}
+	reader := sse.NewReader(buffered)
+	var seen []api.RunEvent
+	for len(seen) < 3 {
+		seen = append(seen, readEvent(t, reader))
+	}
+	resp.Body.Close()
+
+	// Two more iterations while disconnected.
+	llm.gate <- struct{}{}
+	llm.gate <- struct{}{}
+
+	resp = openEvents(t, url, "3")
+	defer resp.Body.Close()
+	go func() { llm.gate <- struct{}{} }()
+	reader = sse.NewReader(resp.Body)
+	for !seen[len(seen)-1].Final {
+		seen = append(seen, readEvent(t, reader))
+	}
+	if _, err := reader.Next(); err == nil {
+		t.Error("Expected the stream to end after the final event")
+	}
+
+	for i, ev := range seen {
+		if ev.ID != int64(i+1) || ev.RunID != "r1" {
+			t.Fatalf("Event %d has ID %d and run %q; events: %+v", i, ev.ID, ev.RunID, seen)
+		}
+	}
+	if len(seen) != 9 || seen[0].Type != "agent.started" || seen[8].Type != "agent.completed" {
+		t.Errorf("Unexpected events: %+v", seen)
+	}
+
+	var run api.RunResponse
+	json.Unmarshal((<-done).Body.Bytes(), &run)
+	if !run.Success || run.RunID != "r1" {
+		t.Errorf("Unexpected run response: %+v", run)
+	}
+	if rec := do(t, h, "POST", "/api/agents/a1/run", api.RunRequest{Task: "loop", RunID: "r1"}, nil); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a reused run ID, got %d", rec.Code)
+	}
+	if rec := do(t, h, "GET", "/api/agents/a1/runs/r1/events?after=x", nil, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid event ID, got %d", rec.Code)
+	}
+}
+
+func TestRunEvents_LongPoll(t *testing.T) {
+	engine := workflow.NewEngine(nil)
+	release := make(chan struct{})
+	engine.Register(workflow.New("slow").
+		Step("wait", func(ctx context.Context, state *workflow.State) (any, error) {
+			<-release
+			return "ok", nil
+		}).Then().
+		Build())
+	h := api.NewServer(api.Config{Engine: engine}).Handler()
+
+	poll := func(query string) api.EventsResponse {
+		t.Helper()
+		rec := do(t, h, "GET", "/api/workflows/runs/"+query, nil, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d: %s", query, rec.Code, rec.Body)
+		}
+		var resp api.EventsResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp
+	}
+
+	if rec := do(t, h, "GET", "/api/workflows/runs/missing/events", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown run, got %d", rec.Code)
+	}
+
+	rec := do(t, h, "POST", "/api/workflows/slow/run", nil, nil)
+	var started map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &started)
+	id := started["state_id"]
+
+	first := poll(id + "/events?wait=2s")
+	if len(first.Events) != 1 || first.Events[0].Type != workflow.EventRunStarted || first.Done {
+		t.Fatalf("Expected the started event, got %+v", first)
+	}
+
+	// A waiting request returns as soon as the step completes.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	next := poll(id + "/events?after=1&wait=5s")
+	if len(next.Events) == 0 || next.Events[0].ID != 2 || next.Events[0].Type != workflow.EventStepExecuted {
+		t.Fatalf("Expected the step event, got %+v", next)
+	}
+
+	rest := next
+	if !rest.Done {
+		rest = poll(id + "/events?wait=5s&after=" + strconv.FormatInt(next.Next, 10))
+	}
+	if !rest.Done || rest.Events[len(rest.Events)-1].Type != workflow.EventRunCompleted {
+		t.Errorf("Expected the completed event, got %+v", rest)
+	}
+
+	if empty := poll(id + "/events?after=99"); len(empty.Events) != 0 || empty.Next != 99 {
+		t.Errorf("Expected no events without waiting, got %+v", empty)
+	}
+}
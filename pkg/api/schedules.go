@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// maxNextRuns caps the next query parameter of the schedule endpoints.
+const maxNextRuns = 100
+
+// ScheduleStatus is a cron schedule with its run state. NextRuns holds
+// the fire times requested with ?next=N.
+type ScheduleStatus struct {
+	ScheduleDefinition
+	LastRun       time.Time    `json:"last_run,omitempty"`
+	NextRun       time.Time    `json:"next_run,omitempty"`
+	NextRuns      []time.Time  `json:"next_runs,omitempty"`
+	LastError     string       `json:"last_error,omitempty"`
+	Skipped       int          `json:"skipped,omitempty"`
+	LastRunStatus *ScheduleRun `json:"last_run_status,omitempty"`
+}
+
+// ScheduleRun is the last fire of a schedule from its run log. Status is
+// the status of the workflow run it started, "enqueued" for a job, or
+// "failed" if the fire itself failed.
+type ScheduleRun struct {
+	workflow.CronRun
+	Status string `json:"status,omitempty"`
+}
+
+// handleSchedules handles GET/POST /api/schedules.
+func (s *Server) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	if s.cron == nil {
+		writeError(w, http.StatusServiceUnavailable, "cron scheduler not configured")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		next, ok := nextParam(w, r, 0)
+		if !ok {
+			return
+		}
+		list := s.cron.List()
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		schedules := make([]ScheduleStatus, 0, len(list))
+		for _, schedule := range list {
+			schedules = append(schedules, s.scheduleStatus(r.Context(), schedule, next))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"schedules": schedules,
+			"count":     len(schedules),
+		})
+	case "POST":
+		var def ScheduleDefinition
+		if !s.decodeJSON(w, r, &def) {
+			return
+		}
+		if def.ID == "" {
+			writeError(w, http.StatusBadRequest, "id is required")
+			return
+		}
+		if (def.Workflow == "") == (def.JobType == "") {
+			writeError(w, http.StatusBadRequest, "exactly one of workflow and job_type is required")
+			return
+		}
+		if _, exists := s.cron.Get(def.ID); exists {
+			writeError(w, http.StatusConflict, fmt.Sprintf("schedule %q already exists", def.ID))
+			return
+		}
+
+		opts := def.options()
+		var warnings []string
+		var err error
+		if def.JobType != "" {
+			warnings, err = s.cron.AddJob(def.ID, def.JobType, def.Expression, def.Input, opts...)
+		} else {
+			err = s.cron.Add(def.ID, def.Workflow, def.Expression, def.Input, opts...)
+		}
+		if err == nil && !def.Enabled {
+			err = s.cron.Disable(def.ID)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error(), "warnings": warnings})
+			return
+		}
+
+		schedule, _ := s.cron.Get(def.ID)
+		writeJSON(w, http.StatusCreated, map[string]any{
+			"schedule": s.scheduleStatus(r.Context(), schedule, 0),
+			"warnings": warnings,
+		})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleSchedule handles GET/DELETE /api/schedules/:id and
+// POST /api/schedules/:id/trigger|pause|resume.
+func (s *Server) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	if s.cron == nil {
+		writeError(w, http.StatusServiceUnavailable, "cron scheduler not configured")
+		return
+	}
+
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")
+	if id == "" || strings.Contains(action, "/") {
+		writeError(w, http.StatusNotFound, "unknown action")
+		return
+	}
+	schedule, ok := s.cron.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "schedule not found")
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == "GET":
+		next, ok := nextParam(w, r, 5)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, s.scheduleStatus(r.Context(), schedule, next))
+	case action == "" && r.Method == "DELETE":
+		if err := s.cron.Remove(id); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"deleted": id})
+	case action == "trigger" && r.Method == "POST":
+		runID, err := s.cron.TriggerNow(context.WithoutCancel(r.Context()), id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"schedule_id": id, "state_id": runID})
+	case (action == "pause" || action == "resume") && r.Method == "POST":
+		var err error
+		if action == "pause" {
+			err = s.cron.Disable(id)
+		} else {
+			err = s.cron.Enable(id)
+		}
+		if errors.Is(err, workflow.ErrScheduleNotFound) {
+			writeError(w, http.StatusNotFound, "schedule not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if schedule, ok = s.cron.Get(id); !ok {
+			writeError(w, http.StatusNotFound, "schedule not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, s.scheduleStatus(r.Context(), schedule, 0))
+	case action == "" || action == "trigger" || action == "pause" || action == "resume":
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	default:
+		writeError(w, http.StatusNotFound, "unknown action")
+	}
+}
+
+// nextParam parses ?next=N, defaulting to def.
+func nextParam(w http.ResponseWriter, r *http.Request, def int) (int, bool) {
+	v := r.URL.Query().Get("next")
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > maxNextRuns {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("next must be between 0 and %d", maxNextRuns))
+		return 0, false
+	}
+	return n, true
+}
+
+// scheduleStatus reports schedule with its next n fire times and the last
+// run from its run log.
+func (s *Server) scheduleStatus(ctx context.Context, schedule *workflow.Schedule, n int) ScheduleStatus {
+	status := ScheduleStatus{
+		ScheduleDefinition: scheduleDefinition(schedule),
+		LastRun:            schedule.LastRun,
+		NextRun:            schedule.NextRun,
+		LastError:          schedule.LastError,
+		Skipped:            schedule.Skipped,
+	}
+	if n > 0 {
+		status.NextRuns, _ = s.cron.NextRuns(schedule.ID, n)
+	}
+
+	runs, err := s.cron.Runs(ctx, schedule.ID, 1)
+	if err != nil || len(runs) == 0 {
+		return status
+	}
+	last := &ScheduleRun{CronRun: runs[0]}
+	switch {
+	case last.Error != "":
+		last.Status = string(workflow.StatusFailed)
+	case last.RunID == "":
+		last.Status = "enqueued"
+	case s.engine != nil:
+		if state, err := s.engine.LoadState(ctx, last.RunID); err == nil {
+			last.Status = string(state.Status)
+		}
+	}
+	status.LastRunStatus = last
+	return status
+}
@@ -0,0 +1,103 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func TestScheduleEndpoints(t *testing.T) {
+	engine := workflow.NewEngine(workflow.NewMemoryPersistence())
+	engine.Register(workflow.New("report").Checkpoint("done").Build())
+	cron := workflow.NewCron(engine)
+	h := api.NewServer(api.Config{Engine: engine, Cron: cron}).Handler()
+
+	def := api.ScheduleDefinition{ID: "nightly", Workflow: "report", Expression: "CRON_TZ=UTC 0 2 * * *", Enabled: true}
+	if rec := do(t, h, "POST", "/api/schedules", def, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, h, "POST", "/api/schedules", def, nil); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a duplicate, got %d", rec.Code)
+	}
+	bad := api.ScheduleDefinition{ID: "bad", Workflow: "report", Expression: "every day"}
+	if rec := do(t, h, "POST", "/api/schedules", bad, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad expression, got %d", rec.Code)
+	}
+
+	rec := do(t, h, "GET", "/api/schedules/nightly?next=3", nil, nil)
+	var status api.ScheduleStatus
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	if rec.Code != http.StatusOK || len(status.NextRuns) != 3 || status.LastRunStatus != nil {
+		t.Fatalf("Unexpected schedule: %d %s", rec.Code, rec.Body)
+	}
+	for i, at := range status.NextRuns {
+		if at.UTC().Hour() != 2 || (i > 0 && at.Sub(status.NextRuns[i-1]) != 24*time.Hour) {
+			t.Errorf("Unexpected fire times: %v", status.NextRuns)
+		}
+	}
+	if rec := do(t, h, "GET", "/api/schedules/nightly?next=-1", nil, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad next, got %d", rec.Code)
+	}
+
+	rec = do(t, h, "POST", "/api/schedules/nightly/trigger", nil, nil)
+	var triggered map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &triggered)
+	if rec.Code != http.StatusAccepted || triggered["state_id"] == "" {
+		t.Fatalf("Expected 202 with a run, got %d: %s", rec.Code, rec.Body)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		rec = do(t, h, "GET", "/api/schedules", nil, nil)
+		var list struct {
+			Schedules []api.ScheduleStatus `json:"schedules"`
+			Count     int                  `json:"count"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &list)
+		if list.Count != 1 {
+			t.Fatalf("Expected 1 schedule, got %s", rec.Body)
+		}
+		last := list.Schedules[0].LastRunStatus
+		if last != nil && last.RunID == triggered["state_id"] && last.Status == string(workflow.StatusCompleted) {
+			if !list.Schedules[0].LastRun.IsZero() {
+				t.Error("Expected TriggerNow to leave LastRun alone")
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the completed run as the last run status, got %s", rec.Body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rec = do(t, h, "POST", "/api/schedules/nightly/pause", nil, nil)
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	if rec.Code != http.StatusOK || status.Enabled {
+		t.Errorf("Expected the schedule paused, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = do(t, h, "POST", "/api/schedules/nightly/resume", nil, nil)
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	if rec.Code != http.StatusOK || !status.Enabled {
+		t.Errorf("Expected the schedule resumed, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, h, "GET", "/api/schedules/nightly/trigger", nil, nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", rec.Code)
+	}
+
+	if rec := do(t, h, "DELETE", "/api/schedules/nightly", nil, nil); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if rec := do(t, h, "GET", "/api/schedules/nightly", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 after delete, got %d", rec.Code)
+	}
+}
+
+func TestScheduleEndpoints_NoCron(t *testing.T) {
+	h := api.NewServer(api.Config{}).Handler()
+	if rec := do(t, h, "GET", "/api/schedules", nil, nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", rec.Code)
+	}
+}
@@ -10,8 +10,13 @@ import (
 	"time"
 
 	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/blueprint"
 	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/integrations/sessions"
+	"github.com/nuulab/goflow/pkg/metrics/alerts"
 	"github.com/nuulab/goflow/pkg/tools"
+	"github.com/nuulab/goflow/pkg/webhook"
+	"github.com/nuulab/goflow/pkg/workflow"
 )
 
 // Server is the GoFlow API server.
@@ -19,19 +24,41 @@ type Server struct {
 	llm        core.LLM
 	registry   *tools.Registry
 	agents     map[string]*ManagedAgent
+	agentStore agent.Store
+	pending    map[string]*PendingConfirmation // by agent ID
 	settings   *Settings
 	hub        *WebSocketHub
+	runs       *runFeed
+	heartbeat  time.Duration
+	health     *healthTracker
+	engine     *workflow.Engine
+	webhooks   *webhook.WebhookHandler
+	blueprints *blueprint.Catalog
+	sessions   *sessions.Tracker
+	alerts     *alerts.Manager
+	cron       *workflow.Cron
+	secrets    func(name string) (string, bool)
+	specs      map[string]*blueprint.WorkflowSpec // workflows defined through the API
+	logger     core.Logger
+	logConfig  *AccessLogConfig
 	mu         sync.RWMutex
 	httpServer *http.Server
 }
 
 // ManagedAgent wraps an agent with metadata.
 type ManagedAgent struct {
-	ID        string       `json:"id"`
-	Agent     *agent.Agent `json:"-"`
-	Status    AgentStatus  `json:"status"`
-	CreatedAt time.Time    `json:"created_at"`
-	LastRunAt time.Time    `json:"last_run_at,omitempty"`
+	ID         string          `json:"id"`
+	RunID      string          `json:"run_id,omitempty"` // current or last run
+	Agent      *agent.Agent    `json:"-"`
+	Definition AgentDefinition `json:"-"`
+	Status     AgentStatus     `json:"status"`
+	CreatedAt  time.Time       `json:"created_at"`
+	LastRunAt  time.Time       `json:"last_run_at,omitempty"`
+
+	// Set while a run is in flight; done is closed once last is set.
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+	last   *RunResponse
 }
 
 // AgentStatus represents the current state of an agent.
@@ -41,6 +68,8 @@ const (
 	AgentIdle    AgentStatus = "idle"
 	AgentRunning AgentStatus = "running"
 	AgentStopped AgentStatus = "stopped"
+	// AgentCancelled marks an agent whose last run was stopped by request.
+	AgentCancelled AgentStatus = "cancelled"
 )
 
 // Settings holds configurable server settings.
@@ -49,6 +78,14 @@ type Settings struct {
 	DefaultTimeout  time.Duration `json:"default_timeout"`
 	VerboseLogging  bool          `json:"verbose_logging"`
 	AllowedOrigins  []string      `json:"allowed_origins"`
+
+	// Request limits; zero uses the Default* constants. They are fixed when
+	// the server is created and cannot be changed through the API.
+	MaxBodyBytes          int64 `json:"max_body_bytes"`           // JSON request bodies
+	MaxUploadBytes        int64 `json:"max_upload_bytes"`         // multipart request bodies
+	MaxStreamMessageBytes int64 `json:"max_stream_message_bytes"` // each WebSocket message
+	MaxJSONDepth          int   `json:"max_json_depth"`
+
 	mu              sync.RWMutex
 }
 
@@ -59,15 +96,34 @@ func DefaultSettings() *Settings {
 		DefaultTimeout: 5 * time.Minute,
 		VerboseLogging: false,
 		AllowedOrigins: []string{"*"},
+
+		MaxBodyBytes:          DefaultMaxBodyBytes,
+		MaxUploadBytes:        DefaultMaxUploadBytes,
+		MaxStreamMessageBytes: DefaultMaxStreamMessageBytes,
+		MaxJSONDepth:          DefaultMaxJSONDepth,
 	}
 }
 
 // Config holds server configuration.
 type Config struct {
-	Port     int
-	LLM      core.LLM
-	Registry *tools.Registry
-	Settings *Settings
+	Port       int
+	LLM        core.LLM
+	Registry   *tools.Registry
+	Settings   *Settings
+	Engine     *workflow.Engine        // optional, enables workflow endpoints
+	Webhooks   *webhook.WebhookHandler // optional, enables webhook endpoints
+	Blueprints *blueprint.Catalog      // optional, defaults to the built-in blueprints
+	Sessions   *sessions.Tracker       // optional, enables integration session endpoints
+	Alerts     *alerts.Manager         // optional, enables alert rule endpoints and events
+	Cron       *workflow.Cron          // optional, enables schedule endpoints and includes schedules in exports
+	RunEvents  RunEventStore           // optional, defaults to an in-memory buffer
+	AgentStore agent.Store             // optional, persists agents across restarts
+	Heartbeat  time.Duration           // optional, defaults to DefaultHeartbeat
+	Logger     core.Logger             // optional, enables access logging
+	AccessLog  *AccessLogConfig        // optional, defaults to DefaultAccessLogConfig
+
+	// Secrets resolves the secret references of imported webhooks.
+	Secrets func(name string) (string, bool)
 }
 
 // NewServer creates a new API server.
@@ -78,13 +134,52 @@ func NewServer(cfg Config) *Server {
 	if cfg.Registry == nil {
 		cfg.Registry = tools.NewRegistry()
 	}
+	if cfg.Blueprints == nil {
+		cfg.Blueprints = blueprint.Builtin()
+	}
+	if cfg.AccessLog == nil {
+		cfg.AccessLog = DefaultAccessLogConfig()
+	}
+	if cfg.RunEvents == nil {
+		cfg.RunEvents = NewMemoryRunEventStore(0)
+	}
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = DefaultHeartbeat
+	}
 
 	s := &Server{
-		llm:      cfg.LLM,
-		registry: cfg.Registry,
-		agents:   make(map[string]*ManagedAgent),
-		settings: cfg.Settings,
-		hub:      NewWebSocketHub(),
+		llm:        cfg.LLM,
+		registry:   cfg.Registry,
+		agents:     make(map[string]*ManagedAgent),
+		agentStore: cfg.AgentStore,
+		pending:    make(map[string]*PendingConfirmation),
+		settings:   cfg.Settings,
+		hub:        NewWebSocketHub(),
+		runs:       newRunFeed(cfg.RunEvents),
+		heartbeat:  cfg.Heartbeat,
+		health:     newHealthTracker(),
+		engine:     cfg.Engine,
+		webhooks:   cfg.Webhooks,
+		blueprints: cfg.Blueprints,
+		sessions:   cfg.Sessions,
+		alerts:     cfg.Alerts,
+		cron:       cfg.Cron,
+		secrets:    cfg.Secrets,
+		specs:      make(map[string]*blueprint.WorkflowSpec),
+		logger:     cfg.Logger,
+		logConfig:  cfg.AccessLog,
+	}
+
+	if s.alerts != nil {
+		s.alerts.Subscribe(func(ctx context.Context, a alerts.Alert) {
+			s.hub.Broadcast(Event{Type: a.Event, Data: a})
+		})
+	}
+	if s.engine != nil {
+		s.engine.Subscribe(func(ctx context.Context, ev workflow.RunEvent) {
+			ctx = withRun(ctx, workflowRunKey(ev.RunID), ev.RunID)
+			s.emit(ctx, Event{Type: ev.Type, Data: ev, Timestamp: ev.Timestamp}, ev.Final())
+		})
 	}
 
 	return s
@@ -92,6 +187,20 @@ func NewServer(cfg Config) *Server {
 
 // Start starts the HTTP server.
 func (s *Server) Start(port int) error {
+	s.httpServer = &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: s.Handler(),
+	}
+
+	// Start WebSocket hub
+	go s.hub.Run()
+
+	fmt.Printf("🚀 GoFlow API server starting on http://localhost:%d\n", port)
+	return s.httpServer.ListenAndServe()
+}
+
+// Handler returns the HTTP handler with all routes registered.
+func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 
 	// API routes
@@ -99,25 +208,38 @@ func (s *Server) Start(port int) error {
 	mux.HandleFunc("/api/agents/", s.corsMiddleware(s.handleAgent))
 	mux.HandleFunc("/api/settings", s.corsMiddleware(s.handleSettings))
 	mux.HandleFunc("/api/channels", s.corsMiddleware(s.handleChannels))
+	mux.HandleFunc("/api/llm/health", s.corsMiddleware(s.handleLLMHealth))
+	mux.HandleFunc("/api/tools/stats", s.corsMiddleware(s.handleToolStats))
+	mux.HandleFunc("/api/workflows", s.corsMiddleware(s.handleWorkflows))
+	mux.HandleFunc("/api/workflows/awaiting", s.corsMiddleware(s.handleAwaiting))
+	mux.HandleFunc("/api/workflows/stuck", s.corsMiddleware(s.handleStuck))
+	mux.HandleFunc("/api/workflows/runs/", s.corsMiddleware(s.handleWorkflowRun))
+	mux.HandleFunc("/api/workflows/", s.corsMiddleware(s.handleWorkflow))
+	mux.HandleFunc("/api/webhooks", s.corsMiddleware(s.handleWebhooks))
+	mux.HandleFunc("/api/webhooks/", s.corsMiddleware(s.handleWebhook))
+	mux.HandleFunc("/api/blueprints", s.corsMiddleware(s.handleBlueprints))
+	mux.HandleFunc("/api/blueprints/", s.corsMiddleware(s.handleBlueprint))
+	mux.HandleFunc("/api/integrations/sessions", s.corsMiddleware(s.handleSessions))
+	mux.HandleFunc("/api/integrations/sessions/", s.corsMiddleware(s.handleSession))
+	mux.HandleFunc("/api/alerts", s.corsMiddleware(s.handleAlerts))
+	mux.HandleFunc("/api/alerts/", s.corsMiddleware(s.handleAlert))
+	mux.HandleFunc("/api/schedules", s.corsMiddleware(s.handleSchedules))
+	mux.HandleFunc("/api/schedules/", s.corsMiddleware(s.handleSchedule))
+	mux.HandleFunc("/api/export", s.corsMiddleware(s.handleExport))
+	mux.HandleFunc("/api/import", s.corsMiddleware(s.handleImport))
+
+	// Webhook deliveries
+	if s.webhooks != nil {
+		mux.Handle("/webhooks/", s.webhooks.Handler())
+	}
 
 	// WebSocket
 	mux.HandleFunc("/ws", s.handleWebSocket)
 
 	// Health check
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-	})
+	mux.HandleFunc("/health", s.handleHealth)
 
-	s.httpServer = &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: mux,
-	}
-
-	// Start WebSocket hub
-	go s.hub.Run()
-
-	fmt.Printf("🚀 GoFlow API server starting on http://localhost:%d\n", port)
-	return s.httpServer.ListenAndServe()
+	return s.accessLog(mux, s.limitBody(mux))
 }
 
 // Stop gracefully stops the server.
@@ -178,9 +300,48 @@ func (s *Server) GetAgent(id string) (*ManagedAgent, bool) {
 
 // CreateAgent creates a new managed agent.
 func (s *Server) CreateAgent(id string) *ManagedAgent {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	return s.addAgent(AgentDefinition{ID: id}, agent.New(s.llm, s.registry, s.agentOptions(id)...))
+}
+
+// createAgentFrom builds and registers a managed agent from def, replacing
+// any agent with the same ID.
+func (s *Server) createAgentFrom(def AgentDefinition) (*ManagedAgent, error) {
+	def = def.normalize()
+	a, err := s.buildAgent(def)
+	if err != nil {
+		return nil, err
+	}
+	return s.addAgent(def, a), nil
+}
 
+// buildAgent creates the agent described by def. Allowed and denied tools
+// must be registered.
+func (s *Server) buildAgent(def AgentDefinition) (*agent.Agent, error) {
+	def = def.normalize()
+	for _, name := range append(append([]string{}, def.AllowedTools...), def.DeniedTools...) {
+		if _, ok := s.registry.Get(name); !ok {
+			return nil, fmt.Errorf("agent %s: unknown tool %q", def.ID, name)
+		}
+	}
+
+	opts := s.agentOptions(def.ID)
+	if def.MaxIterations > 0 {
+		opts = append(opts, agent.WithMaxIterations(def.MaxIterations))
+	}
+	if def.SystemPrompt != "" {
+		opts = append(opts, agent.WithSystemPrompt(def.SystemPrompt))
+	}
+	if len(def.AllowedTools) > 0 || len(def.DeniedTools) > 0 {
+		opts = append(opts, agent.WithToolPolicy(def.AllowedTools, def.DeniedTools))
+	}
+	if def.History > 0 {
+		opts = append(opts, agent.WithHistory(def.History))
+	}
+	return agent.New(s.llm, s.registry, opts...), nil
+}
+
+// agentOptions returns the options applied to every managed agent.
+func (s *Server) agentOptions(id string) []agent.Option {
 	s.settings.mu.RLock()
 	maxIter := s.settings.MaxIterations
 	verbose := s.settings.VerboseLogging
@@ -189,35 +350,45 @@ func (s *Server) CreateAgent(id string) *ManagedAgent {
 	// Create agent with hooks for WebSocket events
 	hooks := s.createAgentHooks(id)
 
-	a := agent.New(s.llm, s.registry,
+	return []agent.Option{
 		agent.WithMaxIterations(maxIter),
 		agent.WithVerbose(verbose),
 		agent.WithHooks(hooks),
-	)
+		agent.WithContextWindowGuard(agent.NewContextWindowGuard("")),
+		agent.WithConfirmationHandler(s.confirmationHandler(id)),
+	}
+}
+
+// addAgent registers a as a managed agent created from def.
+func (s *Server) addAgent(def AgentDefinition, a *agent.Agent) *ManagedAgent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	managed := &ManagedAgent{
-		ID:        id,
-		Agent:     a,
-		Status:    AgentIdle,
-		CreatedAt: time.Now(),
+		ID:         def.ID,
+		Agent:      a,
+		Definition: def,
+		Status:     AgentIdle,
+		CreatedAt:  time.Now(),
 	}
 
-	s.agents[id] = managed
+	s.agents[def.ID] = managed
 	return managed
 }
 
-// createAgentHooks creates hooks that broadcast events via WebSocket.
+// createAgentHooks creates hooks that broadcast events via WebSocket and
+// record them as run events.
 func (s *Server) createAgentHooks(agentID string) agent.Hooks {
 	return agent.NewHooks().
 		OnStart(func(ctx context.Context, task string) {
-			s.hub.Broadcast(Event{
+			s.emit(ctx, Event{
 				Type:    "agent.started",
 				AgentID: agentID,
 				Data:    map[string]string{"task": task},
-			})
+			}, false)
 		}).
 		OnAfterStep(func(ctx context.Context, step agent.StepResult) {
-			s.hub.Broadcast(Event{
+			s.emit(ctx, Event{
 				Type:    "agent.step",
 				AgentID: agentID,
 				Data: map[string]any{
@@ -225,27 +396,31 @@ func (s *Server) createAgentHooks(agentID string) agent.Hooks {
 					"observation": step.Observation,
 					"is_final":    step.IsFinal,
 				},
-			})
+			}, false)
 		}).
 		OnToolCall(func(ctx context.Context, toolName string, input string) {
-			s.hub.Broadcast(Event{
+			s.emit(ctx, Event{
 				Type:    "agent.tool_call",
 				AgentID: agentID,
 				Data: map[string]string{
 					"tool":  toolName,
 					"input": input,
 				},
-			})
+			}, false)
 		}).
 		OnComplete(func(ctx context.Context, result *agent.RunResult) {
-			s.hub.Broadcast(Event{
+			data := map[string]any{
+				"output":     result.Output,
+				"iterations": result.Iterations,
+			}
+			if result.Error != nil {
+				data["error"] = result.Error.Error()
+			}
+			s.emit(ctx, Event{
 				Type:    "agent.completed",
 				AgentID: agentID,
-				Data: map[string]any{
-					"output":     result.Output,
-					"iterations": result.Iterations,
-				},
-			})
+				Data:    data,
+			}, true)
 		}).
 		Build()
 }
@@ -0,0 +1,56 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestAgentStop_CancelsRun(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(tools.QuickTool("echo", "echo", func(ctx context.Context, input string) (string, error) {
+		return input, nil
+	}))
+	llm := &gatedLLM{gate: make(chan struct{}), steps: 100}
+	h := api.NewServer(api.Config{LLM: llm, Registry: registry}).Handler()
+	do(t, h, "POST", "/api/agents", map[string]any{"id": "a1"}, nil)
+
+	done := make(chan api.RunResponse)
+	go func() {
+		var run api.RunResponse
+		rec := do(t, h, "POST", "/api/agents/a1/run", api.RunRequest{Task: "loop"}, nil)
+		json.Unmarshal(rec.Body.Bytes(), &run)
+		done <- run
+	}()
+	llm.gate <- struct{}{} // first iteration; the second waits on the gate
+
+	rec := do(t, h, "POST", "/api/agents/a1/stop", nil, nil)
+	var stopped api.StopResponse
+	json.Unmarshal(rec.Body.Bytes(), &stopped)
+	if rec.Code != http.StatusOK || stopped.Status != api.AgentCancelled || stopped.Run == nil {
+		t.Fatalf("Unexpected stop response %d: %s", rec.Code, rec.Body)
+	}
+	if !stopped.Run.Cancelled || stopped.Run.Success || stopped.Run.Iterations != 2 || len(stopped.Run.Transcript) == 0 {
+		t.Errorf("Expected the partial run, got %+v", stopped.Run)
+	}
+	if run := <-done; !run.Cancelled || run.RunID != stopped.Run.RunID {
+		t.Errorf("Expected the run request to report the cancellation, got %+v", run)
+	}
+
+	var info api.AgentInfo
+	json.Unmarshal(do(t, h, "GET", "/api/agents/a1", nil, nil).Body.Bytes(), &info)
+	if info.Status != api.AgentCancelled {
+		t.Errorf("Expected status cancelled, got %q", info.Status)
+	}
+
+	// Stopping an idle agent just marks it stopped.
+	var idle api.StopResponse
+	json.Unmarshal(do(t, h, "POST", "/api/agents/a1/stop", nil, nil).Body.Bytes(), &idle)
+	if idle.Status != api.AgentStopped || idle.Run != nil {
+		t.Errorf("Unexpected response for an idle agent: %+v", idle)
+	}
+}
@@ -0,0 +1,43 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestCreateAgent_Tools(t *testing.T) {
+	registry := tools.NewRegistry()
+	deployed := false
+	registry.Register(tools.QuickTool("deploy", "deploy a service", func(ctx context.Context, input string) (string, error) {
+		deployed = true
+		return "deployed", nil
+	}))
+	registry.Register(tools.QuickTool("status", "service status", func(ctx context.Context, input string) (string, error) {
+		return "up", nil
+	}))
+	h := api.NewServer(api.Config{LLM: &deployLLM{}, Registry: registry}).Handler()
+
+	if rec := do(t, h, "POST", "/api/agents", map[string]any{"id": "bad", "tools": []string{"nope"}}, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown tool, got %d", rec.Code)
+	}
+	if rec := do(t, h, "POST", "/api/agents", map[string]any{"id": "viewer", "tools": []string{"status"}}, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("Create failed: %d %s", rec.Code, rec.Body)
+	}
+
+	// The deprecated tools field is exported as allowed_tools.
+	if bundle := string(export(t, h)); !strings.Contains(bundle, `"allowed_tools":["status"]`) || strings.Contains(bundle, `"tools":`) {
+		t.Errorf("Expected tools merged into allowed_tools: %s", bundle)
+	}
+
+	var run api.RunResponse
+	json.Unmarshal(do(t, h, "POST", "/api/agents/viewer/run", api.RunRequest{Task: "ship it"}, nil).Body.Bytes(), &run)
+	if deployed || !strings.Contains(run.Output, "tool not permitted for this agent") {
+		t.Errorf("Expected the deploy call refused, got %q (deployed: %v)", run.Output, deployed)
+	}
+}
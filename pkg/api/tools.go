@@ -0,0 +1,16 @@
+package api
+
+import "net/http"
+
+// handleToolStats handles GET /api/tools/stats.
+func (s *Server) handleToolStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	stats := s.registry.Stats()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"tools": stats,
+		"count": len(stats),
+	})
+}
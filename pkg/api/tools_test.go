@@ -0,0 +1,43 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestToolStats(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(tools.QuickTool("status", "service status", func(ctx context.Context, input string) (string, error) {
+		if input == "down" {
+			return "", errors.New("unreachable")
+		}
+		return "up", nil
+	}))
+	h := api.NewServer(api.Config{LLM: &deployLLM{}, Registry: registry}).Handler()
+
+	registry.Execute(context.Background(), "status", "{}")
+	registry.Execute(context.Background(), "status", "down")
+
+	rec := do(t, h, "GET", "/api/tools/stats", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Tools map[string]tools.ToolStats `json:"tools"`
+		Count int                        `json:"count"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if got := body.Tools["status"]; body.Count != 1 || got.Calls != 2 || got.Errors != 1 || got.LastCalled.IsZero() {
+		t.Errorf("Unexpected stats: %+v", body)
+	}
+
+	if rec := do(t, h, "POST", "/api/tools/stats", nil, nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
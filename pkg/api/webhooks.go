@@ -0,0 +1,253 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nuulab/goflow/pkg/webhook"
+)
+
+// WebhookRequest is the request body for creating a webhook.
+type WebhookRequest struct {
+	Name            string                `json:"name"`
+	Path            string                `json:"path"`
+	Action          webhook.WebhookAction `json:"action"`
+	JobType         string                `json:"job_type,omitempty"`
+	WorkflowID      string                `json:"workflow_id,omitempty"`
+	Secret          string                `json:"secret,omitempty"`
+	SecretRef       string                `json:"secret_ref,omitempty"` // resolved with Config.Secrets
+	Transform       string                `json:"transform,omitempty"`
+	PayloadTemplate map[string]any        `json:"payload_template,omitempty"`
+}
+
+// WebhookInfo is a webhook config as returned by the API. Secrets are never echoed.
+type WebhookInfo struct {
+	webhook.WebhookConfig
+	Secret    string `json:"secret,omitempty"`
+	HasSecret bool   `json:"has_secret"`
+	URL       string `json:"url"`
+}
+
+func webhookInfo(cfg *webhook.WebhookConfig) WebhookInfo {
+	return WebhookInfo{
+		WebhookConfig: *cfg,
+		HasSecret:     cfg.Secret != "",
+		URL:           "/webhooks" + cfg.Path,
+	}
+}
+
+// handleWebhooks handles GET/POST /api/webhooks.
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		writeError(w, http.StatusServiceUnavailable, "webhooks not configured")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		configs := s.webhooks.List()
+		infos := make([]WebhookInfo, 0, len(configs))
+		for _, cfg := range configs {
+			infos = append(infos, webhookInfo(cfg))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"webhooks": infos,
+			"count":    len(infos),
+		})
+	case "POST":
+		var req WebhookRequest
+		if !s.decodeJSON(w, r, &req) {
+			return
+		}
+		cfg := &webhook.WebhookConfig{
+			Name:            req.Name,
+			Path:            req.Path,
+			Action:          req.Action,
+			JobType:         req.JobType,
+			WorkflowID:      req.WorkflowID,
+			Secret:          req.Secret,
+			SecretRef:       req.SecretRef,
+			TransformExpr:   req.Transform,
+			PayloadTemplate: req.PayloadTemplate,
+		}
+		if req.SecretRef != "" {
+			secret, err := s.resolveSecret(req.SecretRef)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			cfg.Secret = secret
+		}
+		if err := s.webhooks.Create(r.Context(), cfg); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, webhook.ErrPathConflict) {
+				status = http.StatusConflict
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, webhookInfo(cfg))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleWebhook handles /api/webhooks/:id, /api/webhooks/:id/test and
+// /api/webhooks/:id/deliveries.
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		writeError(w, http.StatusServiceUnavailable, "webhooks not configured")
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/webhooks/"), "/")
+	if parts[0] == "" {
+		writeError(w, http.StatusBadRequest, "webhook ID required")
+		return
+	}
+	id := parts[0]
+
+	if len(parts) > 1 {
+		switch {
+		case parts[1] == "test" && len(parts) == 2:
+			s.handleWebhookTest(w, r, id)
+		case parts[1] == "deliveries" && len(parts) == 2:
+			s.handleWebhookDeliveries(w, r, id)
+		case parts[1] == "deliveries" && len(parts) == 4 && parts[3] == "replay":
+			s.handleWebhookReplay(w, r, id, parts[2])
+		default:
+			writeError(w, http.StatusNotFound, "unknown action")
+		}
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		cfg, ok := s.webhooks.Get(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "webhook not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, webhookInfo(cfg))
+	case "DELETE":
+		if err := s.webhooks.Delete(r.Context(), id); err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"deleted": id})
+	case "PATCH":
+		var req struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if !s.decodeJSON(w, r, &req) {
+			return
+		}
+		if req.Enabled == nil {
+			writeError(w, http.StatusBadRequest, "enabled is required")
+			return
+		}
+		cfg, err := s.webhooks.SetEnabled(r.Context(), id, *req.Enabled)
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, webhookInfo(cfg))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleWebhookTest handles POST /api/webhooks/:id/test. The optional body
+// is a webhook payload; a sample payload is synthesized otherwise.
+func (s *Server) handleWebhookTest(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var payload *webhook.WebhookPayload
+	if r.ContentLength != 0 {
+		payload = &webhook.WebhookPayload{}
+		if !s.decodeJSON(w, r, payload) {
+			return
+		}
+	}
+
+	result, err := s.webhooks.DryRun(r.Context(), id, payload)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"dry_run": true,
+		"result":  result,
+	})
+}
+
+// handleWebhookDeliveries handles GET /api/webhooks/:id/deliveries. The
+// limit query parameter defaults to 50.
+func (s *Server) handleWebhookDeliveries(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	receipts, err := s.webhooks.Deliveries(r.Context(), id, limit)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"deliveries": receipts,
+		"count":      len(receipts),
+	})
+}
+
+// handleWebhookReplay handles POST /api/webhooks/:id/deliveries/:rid/replay.
+func (s *Server) handleWebhookReplay(w http.ResponseWriter, r *http.Request, id, receiptID string) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	receipt, err := s.webhooks.Replay(r.Context(), id, receiptID)
+	if receipt != nil {
+		// A failed action is reported on the receipt.
+		writeJSON(w, http.StatusOK, receipt)
+		return
+	}
+	writeWebhookError(w, err)
+}
+
+// resolveSecret looks up a webhook secret reference.
+func (s *Server) resolveSecret(name string) (string, error) {
+	if s.secrets == nil {
+		return "", fmt.Errorf("secret %s: no secret resolver configured", name)
+	}
+	secret, ok := s.secrets(name)
+	if !ok || secret == "" {
+		return "", fmt.Errorf("secret %s is not set", name)
+	}
+	return secret, nil
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	if errors.Is(err, webhook.ErrNotFound) || errors.Is(err, webhook.ErrReceiptNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeError(w, http.StatusBadRequest, err.Error())
+}
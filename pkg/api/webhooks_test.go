@@ -0,0 +1,355 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/cache"
+	"github.com/nuulab/goflow/pkg/queue"
+	"github.com/nuulab/goflow/pkg/tools"
+	"github.com/nuulab/goflow/pkg/webhook"
+)
+
+// recordingQueue captures enqueued jobs.
+type recordingQueue struct {
+	jobs []*queue.Job
+}
+
+func (q *recordingQueue) Enqueue(ctx context.Context, job *queue.Job) error {
+	q.jobs = append(q.jobs, job)
+	return nil
+}
+
+func (q *recordingQueue) Dequeue(ctx context.Context, timeout time.Duration) (*queue.Job, error) {
+	return nil, nil
+}
+
+func (q *recordingQueue) Peek(ctx context.Context) (*queue.Job, error) { return nil, nil }
+
+func (q *recordingQueue) Len(ctx context.Context) (int64, error) { return int64(len(q.jobs)), nil }
+
+func (q *recordingQueue) Close() error { return nil }
+
+func do(t *testing.T, h http.Handler, method, path string, body any, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf []byte
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		buf = b
+	default:
+		buf, _ = json.Marshal(b)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(buf))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookAPI_CreateAndDeliver(t *testing.T) {
+	q := &recordingQueue{}
+	hooks := webhook.NewWebhookHandler(q, nil)
+	handler := api.NewServer(api.Config{Webhooks: hooks}).Handler()
+
+	rec := do(t, handler, "POST", "/api/webhooks", api.WebhookRequest{
+		Path:      "/github",
+		Action:    webhook.ActionEnqueueJob,
+		JobType:   "github_push",
+		Secret:    "s3cret",
+		Transform: `{repo: data.repository, commits: len(data.commits)}`,
+	}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created api.WebhookInfo
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if created.ID == "" || created.Secret != "" || !created.HasSecret {
+		t.Errorf("Unexpected create response: %s", rec.Body.String())
+	}
+
+	body := []byte(`{"event": "push", "data": {"repository": "goflow", "commits": [1, 2, 3]}}`)
+
+	// Unsigned deliveries are rejected
+	rec = do(t, handler, "POST", "/webhooks/github", body, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 for unsigned delivery, got %d", rec.Code)
+	}
+
+	rec = do(t, handler, "POST", "/webhooks/github", body, map[string]string{
+		"X-Webhook-Signature": sign(body, "s3cret"),
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if len(q.jobs) != 1 {
+		t.Fatalf("Expected 1 enqueued job, got %d", len(q.jobs))
+	}
+	job := q.jobs[0]
+	if job.Type != "github_push" || job.Metadata["webhook_id"] != created.ID {
+		t.Errorf("Unexpected job: %+v", job)
+	}
+	var payload map[string]any
+	json.Unmarshal(job.Payload, &payload)
+	if payload["repo"] != "goflow" || payload["commits"] != float64(3) {
+		t.Errorf("Transform not applied: %s", job.Payload)
+	}
+}
+
+func TestWebhookAPI_PathCollision(t *testing.T) {
+	handler := api.NewServer(api.Config{Webhooks: webhook.NewWebhookHandler(&recordingQueue{}, nil)}).Handler()
+	req := api.WebhookRequest{Path: "/stripe", Action: webhook.ActionEnqueueJob, JobType: "payment"}
+
+	if rec := do(t, handler, "POST", "/api/webhooks", req, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", rec.Code)
+	}
+	if rec := do(t, handler, "POST", "/api/webhooks", req, nil); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for duplicate path, got %d", rec.Code)
+	}
+}
+
+func TestWebhookAPI_DisableDeleteAndTest(t *testing.T) {
+	q := &recordingQueue{}
+	handler := api.NewServer(api.Config{Webhooks: webhook.NewWebhookHandler(q, nil)}).Handler()
+
+	rec := do(t, handler, "POST", "/api/webhooks", api.WebhookRequest{
+		Path: "/orders", Action: webhook.ActionEnqueueJob, JobType: "order", Transform: `{order: data.id}`,
+	}, nil)
+	var created api.WebhookInfo
+	json.Unmarshal(rec.Body.Bytes(), &created)
+
+	// Dry run reports the job without enqueuing it
+	rec = do(t, handler, "POST", "/api/webhooks/"+created.ID+"/test", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var test struct {
+		DryRun bool                 `json:"dry_run"`
+		Result webhook.DryRunResult `json:"result"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &test)
+	if !test.DryRun || test.Result.JobType != "order" || test.Result.Payload.(map[string]any)["order"] != "sample-1" {
+		t.Errorf("Unexpected dry run: %s", rec.Body.String())
+	}
+	if len(q.jobs) != 0 {
+		t.Error("Dry run must not enqueue jobs")
+	}
+
+	rec = do(t, handler, "PATCH", "/api/webhooks/"+created.ID, map[string]bool{"enabled": false}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if rec = do(t, handler, "POST", "/webhooks/orders", []byte(`{}`), nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected disabled webhook to return 503, got %d", rec.Code)
+	}
+
+	if rec = do(t, handler, "DELETE", "/api/webhooks/"+created.ID, nil, nil); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if rec = do(t, handler, "GET", "/api/webhooks/"+created.ID, nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 after delete, got %d", rec.Code)
+	}
+}
+
+func TestWebhookAPI_PayloadTemplate(t *testing.T) {
+	q := &recordingQueue{}
+	hooks := webhook.NewWebhookHandler(q, nil)
+	schemas := queue.NewJobSchemas()
+	schemas.Register("report", tools.Schema{
+		Properties: map[string]tools.Property{
+			"region": {Type: "string"},
+			"date":   {Type: "string"},
+		},
+		Required: []string{"region"},
+	})
+	hooks.SetJobSchemas(schemas)
+	handler := api.NewServer(api.Config{Webhooks: hooks}).Handler()
+
+	rec := do(t, handler, "POST", "/api/webhooks", api.WebhookRequest{
+		Path: "/reports", Action: webhook.ActionEnqueueJob, JobType: "report",
+		PayloadTemplate: map[string]any{"region": "{{data.region}}", "date": "{{payload.when | default(today)}}"},
+	}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created api.WebhookInfo
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if len(created.Warnings) != 1 || created.Warnings[0] != `unknown field "payload.when"` {
+		t.Errorf("Expected a warning for payload.when, got %q", created.Warnings)
+	}
+
+	rec = do(t, handler, "POST", "/webhooks/reports", []byte(`{"event": "run", "data": {"region": "eu"}}`), nil)
+	if rec.Code != http.StatusOK || len(q.jobs) != 1 {
+		t.Fatalf("Expected a job, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var payload map[string]any
+	json.Unmarshal(q.jobs[0].Payload, &payload)
+	if payload["region"] != "eu" || payload["date"] != time.Now().Format(time.DateOnly) {
+		t.Errorf("Template not rendered: %s", q.jobs[0].Payload)
+	}
+
+	// A delivery whose rendered payload fails the schema is not enqueued.
+	rec = do(t, handler, "POST", "/webhooks/reports", []byte(`{"event": "run", "data": {"region": 5}}`), nil)
+	if rec.Code != http.StatusUnprocessableEntity || len(q.jobs) != 1 {
+		t.Errorf("Expected 422 without a job, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Templates that can never satisfy the schema are rejected up front.
+	rec = do(t, handler, "POST", "/api/webhooks", api.WebhookRequest{
+		Path: "/broken", Action: webhook.ActionEnqueueJob, JobType: "report",
+		PayloadTemplate: map[string]any{"date": "{{today}}"},
+	}, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a template missing a required field, got %d", rec.Code)
+	}
+}
+
+func TestWebhookAPI_Persistence(t *testing.T) {
+	store := cache.NewMemoryCache(cache.Config{})
+	ctx := context.Background()
+
+	first := webhook.NewWebhookHandler(&recordingQueue{}, nil)
+	if err := first.SetStore(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Create(ctx, &webhook.WebhookConfig{
+		Path: "/jira", Action: webhook.ActionEnqueueJob, JobType: "ticket", TransformExpr: `data`,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new handler (e.g. after restart) sees the saved config
+	q := &recordingQueue{}
+	second := webhook.NewWebhookHandler(q, nil)
+	if err := second.SetStore(ctx, store); err != nil {
+		t.Fatal(err)
+	}
+	handler := api.NewServer(api.Config{Webhooks: second}).Handler()
+
+	rec := do(t, handler, "GET", "/api/webhooks", nil, nil)
+	var list struct {
+		Count int `json:"count"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if list.Count != 1 {
+		t.Fatalf("Expected 1 persisted webhook, got %d", list.Count)
+	}
+
+	rec = do(t, handler, "POST", "/webhooks/jira", []byte(`{"event": "created", "data": {"key": "GF-1"}}`), nil)
+	if rec.Code != http.StatusOK || len(q.jobs) != 1 {
+		t.Fatalf("Expected delivery to persisted webhook, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestWebhookAPI_DuplicateDeliveries(t *testing.T) {
+	q := &recordingQueue{}
+	hooks := webhook.NewWebhookHandler(q, nil)
+	hooks.SetReceiptStore(webhook.NewMemoryReceiptStore(0))
+	handler := api.NewServer(api.Config{Webhooks: hooks}).Handler()
+
+	rec := do(t, handler, "POST", "/api/webhooks", api.WebhookRequest{
+		Path: "/github", Action: webhook.ActionEnqueueJob, JobType: "github_push", Secret: "s3cret",
+	}, nil)
+	var created api.WebhookInfo
+	json.Unmarshal(rec.Body.Bytes(), &created)
+
+	body := []byte(`{"event": "push", "data": {"repository": "goflow"}}`)
+	headers := map[string]string{"X-Webhook-Signature": sign(body, "s3cret"), "X-GitHub-Delivery": "d-1"}
+	for i := 0; i < 2; i++ {
+		if rec := do(t, handler, "POST", "/webhooks/github", body, headers); rec.Code != http.StatusOK {
+			t.Fatalf("Delivery %d: expected 200, got %d: %s", i+1, rec.Code, rec.Body.String())
+		}
+	}
+	if len(q.jobs) != 1 {
+		t.Fatalf("Expected 1 job for a duplicated delivery, got %d", len(q.jobs))
+	}
+
+	// Without a delivery ID the body hash identifies the duplicate
+	headers = map[string]string{"X-Webhook-Signature": sign(body, "s3cret")}
+	do(t, handler, "POST", "/webhooks/github", body, headers)
+	do(t, handler, "POST", "/webhooks/github", body, headers)
+	if len(q.jobs) != 2 {
+		t.Fatalf("Expected 2 jobs, got %d", len(q.jobs))
+	}
+
+	rec = do(t, handler, "GET", "/api/webhooks/"+created.ID+"/deliveries", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list struct {
+		Deliveries []webhook.Receipt `json:"deliveries"`
+		Count      int               `json:"count"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if list.Count != 4 {
+		t.Fatalf("Expected 4 receipts, got %d", list.Count)
+	}
+	first, dup := list.Deliveries[3], list.Deliveries[2]
+	if first.Outcome != webhook.OutcomeProcessed || first.Result["job_id"] != q.jobs[0].ID || first.DeliveryID != "d-1" {
+		t.Errorf("Unexpected first receipt: %+v", first)
+	}
+	if dup.Outcome != webhook.OutcomeDuplicate || dup.DuplicateOf != first.ID {
+		t.Errorf("Unexpected duplicate receipt: %+v", dup)
+	}
+	if _, ok := first.Headers["X-Webhook-Signature"]; ok {
+		t.Error("Signatures must not be recorded")
+	}
+
+	// Replay re-processes a delivery regardless of duplicate detection
+	rec = do(t, handler, "POST", "/api/webhooks/"+created.ID+"/deliveries/"+first.ID+"/replay", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var replay webhook.Receipt
+	json.Unmarshal(rec.Body.Bytes(), &replay)
+	if replay.ReplayOf != first.ID || replay.Outcome != webhook.OutcomeProcessed || len(q.jobs) != 3 {
+		t.Errorf("Unexpected replay: %+v with %d jobs", replay, len(q.jobs))
+	}
+
+	rec = do(t, handler, "POST", "/api/webhooks/"+created.ID+"/deliveries/rcpt-missing/replay", nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown receipt, got %d", rec.Code)
+	}
+}
+
+func TestWebhookAPI_FailedDeliveryIsRetried(t *testing.T) {
+	hooks := webhook.NewWebhookHandler(nil, nil)
+	hooks.SetReceiptStore(webhook.NewMemoryReceiptStore(0))
+	handler := api.NewServer(api.Config{Webhooks: hooks}).Handler()
+	do(t, handler, "POST", "/api/webhooks", api.WebhookRequest{Path: "/jobs", Action: webhook.ActionEnqueueJob, JobType: "job"}, nil)
+
+	// No queue is configured, so the action fails and the retry is not a duplicate
+	body := []byte(`{"event": "created"}`)
+	for i := 0; i < 2; i++ {
+		rec := do(t, handler, "POST", "/webhooks/jobs", body, nil)
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("Delivery %d: expected 500, got %d", i+1, rec.Code)
+		}
+	}
+
+	receipts, err := hooks.Deliveries(context.Background(), hooks.List()[0].ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipts) != 2 || receipts[0].Outcome != webhook.OutcomeFailed || receipts[1].Outcome != webhook.OutcomeFailed {
+		t.Errorf("Expected two failed receipts, got %+v", receipts)
+	}
+}
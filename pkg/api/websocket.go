@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/websocket"
@@ -34,6 +35,8 @@ type WebSocketClient struct {
 	conn       *websocket.Conn
 	send       chan Event
 	subscriptions map[string]bool // topic -> subscribed
+	sent       atomic.Int64 // events written to the connection
+	received   atomic.Int64 // messages read from the connection
 	mu         sync.RWMutex
 }
 
@@ -112,7 +115,9 @@ func (h *WebSocketHub) BroadcastToAgent(agentID string, event Event) {
 
 // handleWebSocket handles WebSocket connections.
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	_, _, maxMessage, _ := s.bodyLimits()
 	websocket.Handler(func(conn *websocket.Conn) {
+		conn.MaxPayloadBytes = int(maxMessage)
 		client := &WebSocketClient{
 			hub:           s.hub,
 			conn:          conn,
@@ -121,6 +126,8 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		}
 
 		s.hub.register <- client
+		start := time.Now()
+		s.logStream("stream opened", r)
 
 		// Send welcome message
 		client.send <- Event{
@@ -133,9 +140,27 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 		// Reader loop
 		client.readPump(s)
+
+		s.logStream("stream closed", r,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"events_sent", client.sent.Load(),
+			"events_received", client.received.Load(),
+		)
 	}).ServeHTTP(w, r)
 }
 
+// logStream logs a WebSocket connection lifecycle record.
+func (s *Server) logStream(msg string, r *http.Request, args ...any) {
+	if s.logger == nil {
+		return
+	}
+	args = append([]any{"route", "/ws", "remote_addr", r.RemoteAddr}, args...)
+	if id, ok := r.Context().Value(identityKey{}).(identity); ok {
+		args = append(args, "api_key_id", id.keyID, "tenant", id.tenant)
+	}
+	s.logger.Info(msg, args...)
+}
+
 // writePump sends events to the client.
 func (c *WebSocketClient) writePump() {
 	defer func() {
@@ -151,6 +176,7 @@ func (c *WebSocketClient) writePump() {
 		if _, err := c.conn.Write(data); err != nil {
 			return
 		}
+		c.sent.Add(1)
 	}
 }
 
@@ -184,6 +210,7 @@ func (c *WebSocketClient) readPump(s *Server) {
 		if err := websocket.JSON.Receive(c.conn, &msg); err != nil {
 			return
 		}
+		c.received.Add(1)
 
 		switch msg.Type {
 		case "subscribe":
@@ -0,0 +1,308 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// handleWorkflows handles GET /api/workflows, listing run summaries. The
+// workflow, status, since and until (RFC 3339) query parameters filter the
+// runs; offset and limit page through them, limit defaulting to 50.
+func (s *Server) handleWorkflows(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.engine == nil {
+		writeError(w, http.StatusServiceUnavailable, "workflow engine not configured")
+		return
+	}
+
+	q := r.URL.Query()
+	filter := workflow.ListFilter{
+		Workflow: q.Get("workflow"),
+		Status:   workflow.Status(q.Get("status")),
+		Limit:    50,
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid "+p.name)
+				return
+			}
+			*p.dst = t
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"offset", &filter.Offset}, {"limit", &filter.Limit}} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, "invalid "+p.name)
+				return
+			}
+			*p.dst = n
+		}
+	}
+
+	states, err := s.engine.List(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	runs := make([]workflow.RunSummary, 0, len(states))
+	for _, state := range states {
+		runs = append(runs, state.Summary())
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"executions": runs,
+		"count":      len(runs),
+		"offset":     filter.Offset,
+		"limit":      filter.Limit,
+	})
+}
+
+// handleAwaiting handles GET /api/workflows/awaiting.
+func (s *Server) handleAwaiting(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.engine == nil {
+		writeError(w, http.StatusServiceUnavailable, "workflow engine not configured")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.engine.AwaitingSummary())
+}
+
+// handleStuck handles GET /api/workflows/stuck.
+func (s *Server) handleStuck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.engine == nil {
+		writeError(w, http.StatusServiceUnavailable, "workflow engine not configured")
+		return
+	}
+	stuck := s.engine.Stuck()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"executions": stuck,
+		"count":      len(stuck),
+	})
+}
+
+// handleWorkflowRun handles GET /api/workflows/runs/:id/state-at/:step,
+// GET /api/workflows/runs/:id/history and GET /api/workflows/runs/:id/events.
+func (s *Server) handleWorkflowRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.engine == nil {
+		writeError(w, http.StatusServiceUnavailable, "workflow engine not configured")
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/workflows/runs/"), "/")
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "events" {
+		annotateRun(r.Context(), parts[0])
+		s.handleWorkflowEvents(w, r, parts[0])
+		return
+	}
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "history" {
+		annotateRun(r.Context(), parts[0])
+		history, err := s.engine.GetHistory(r.Context(), parts[0])
+		if err != nil {
+			writeError(w, http.StatusNotFound, "workflow run not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"state_id": parts[0], "executions": history})
+		return
+	}
+	if len(parts) != 3 || parts[0] == "" || parts[1] != "state-at" || parts[2] == "" {
+		writeError(w, http.StatusNotFound, "unknown action")
+		return
+	}
+	annotateRun(r.Context(), parts[0])
+
+	state, err := s.engine.LoadState(r.Context(), parts[0])
+	if err != nil {
+		writeError(w, http.StatusNotFound, "workflow run not found")
+		return
+	}
+	snapshot, err := state.StateAt(parts[2])
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// handleWorkflowEvents serves the events of a workflow run. Runs that are
+// neither buffered nor known to the engine are not found.
+func (s *Server) handleWorkflowEvents(w http.ResponseWriter, r *http.Request, runID string) {
+	key := workflowRunKey(runID)
+	if events, _ := s.runs.store.Since(r.Context(), key, 0); len(events) == 0 {
+		if _, running := s.engine.GetState(runID); !running {
+			if _, err := s.engine.LoadState(r.Context(), runID); err != nil {
+				writeError(w, http.StatusNotFound, "workflow run not found")
+				return
+			}
+		}
+	}
+	s.serveRunEvents(w, r, key)
+}
+
+// WorkflowRunRequest is the request body for starting a workflow.
+type WorkflowRunRequest struct {
+	Input map[string]any `json:"input,omitempty"`
+}
+
+// handleWorkflow handles POST /api/workflows/:name/run,
+// GET /api/workflows/:name/graph and POST /api/workflows/:id/cancel.
+// Regular runs start in the background and return the state ID;
+// ?preview=N runs the first N steps synchronously and returns the
+// resulting state.
+func (s *Server) handleWorkflow(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/workflows/"), "/")
+	if len(parts) != 2 || parts[0] == "" || (parts[1] != "run" && parts[1] != "cancel" && parts[1] != "graph") {
+		writeError(w, http.StatusNotFound, "unknown action")
+		return
+	}
+	if parts[1] == "cancel" {
+		s.handleWorkflowCancel(w, r, parts[0])
+		return
+	}
+	if parts[1] == "graph" {
+		s.handleWorkflowGraph(w, r, parts[0])
+		return
+	}
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.engine == nil {
+		writeError(w, http.StatusServiceUnavailable, "workflow engine not configured")
+		return
+	}
+
+	wf, ok := s.engine.Workflow(parts[0])
+	if !ok {
+		writeError(w, http.StatusNotFound, "workflow not found")
+		return
+	}
+	preview, err := previewParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req WorkflowRunRequest
+	if r.ContentLength != 0 && !s.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if preview == 0 {
+		id, err := s.engine.Start(context.WithoutCancel(r.Context()), wf.Name, req.Input)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		annotateRun(r.Context(), id)
+		writeJSON(w, http.StatusAccepted, map[string]string{"state_id": id})
+		return
+	}
+
+	state, err := s.engine.ExecutePrefix(r.Context(), wf, req.Input, preview)
+	if state == nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	response := map[string]any{"state": state}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleWorkflowGraph handles GET /api/workflows/:name/graph, returning the
+// workflow's diagram as Mermaid, or as DOT with ?format=dot.
+func (s *Server) handleWorkflowGraph(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.engine == nil {
+		writeError(w, http.StatusServiceUnavailable, "workflow engine not configured")
+		return
+	}
+	wf, ok := s.engine.Workflow(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "workflow not found")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	var graph string
+	switch format {
+	case "", "mermaid":
+		format, graph = "mermaid", wf.ToMermaid()
+	case "dot":
+		graph = wf.ToDOT()
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q", format))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"workflow": wf.Name,
+		"format":   format,
+		"graph":    graph,
+	})
+}
+
+// WorkflowCancelRequest is the request body for cancelling a workflow run.
+type WorkflowCancelRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// handleWorkflowCancel cancels a run and waits for its compensations.
+func (s *Server) handleWorkflowCancel(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.engine == nil {
+		writeError(w, http.StatusServiceUnavailable, "workflow engine not configured")
+		return
+	}
+	annotateRun(r.Context(), id)
+
+	var req WorkflowCancelRequest
+	if r.ContentLength != 0 && !s.decodeJSON(w, r, &req) {
+		return
+	}
+	switch err := s.engine.Cancel(r.Context(), id, req.Reason); {
+	case errors.Is(err, workflow.ErrStateNotFound):
+		writeError(w, http.StatusNotFound, "workflow run not found")
+	case errors.Is(err, workflow.ErrRunFinished):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"state_id": id, "status": string(workflow.StatusCancelled)})
+	}
+}
@@ -0,0 +1,254 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/api"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func TestAwaitingEndpoint(t *testing.T) {
+	server := api.NewServer(api.Config{Engine: workflow.NewEngine(nil)})
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/workflows/awaiting", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	var summary workflow.AwaitingSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if summary.Total != 0 || len(summary.Buckets) != len(workflow.AwaitingBuckets) {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+}
+
+func TestAwaitingEndpoint_NoEngine(t *testing.T) {
+	server := api.NewServer(api.Config{})
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/workflows/awaiting", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", rec.Code)
+	}
+}
+
+func TestStateAtEndpoint(t *testing.T) {
+	engine := workflow.NewEngine(nil)
+	h := api.NewServer(api.Config{Engine: engine}).Handler()
+
+	wf := workflow.New("order").
+		Step("reserve", func(ctx context.Context, state *workflow.State) (any, error) {
+			state.Data["status"] = "reserved"
+			return "r-1", nil
+		}).Then().
+		Step("charge", func(ctx context.Context, state *workflow.State) (any, error) {
+			state.Data["status"] = "charged"
+			return "c-1", nil
+		}).Then().
+		AwaitSignal("ship", "shipped").Then().
+		Build()
+
+	done := make(chan struct{})
+	go func() {
+		engine.Execute(context.Background(), wf, nil)
+		close(done)
+	}()
+	defer func() {
+		engine.SendSignal(context.Background(), "shipped", nil)
+		<-done
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for engine.AwaitingSummary().Total == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Workflow never started awaiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	id := engine.AwaitingSummary().Executions[0].StateID
+
+	rec := do(t, h, "GET", "/api/workflows/runs/"+id+"/state-at/reserve", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var snap workflow.StateSnapshot
+	json.Unmarshal(rec.Body.Bytes(), &snap)
+	if snap.Data["status"] != "reserved" || snap.StepResults["reserve"] != "r-1" || snap.StepResults["charge"] != nil {
+		t.Errorf("Unexpected state at reserve: %+v", snap)
+	}
+
+	if rec := do(t, h, "GET", "/api/workflows/runs/"+id+"/state-at/ship", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a step that has not run, got %d", rec.Code)
+	}
+	if rec := do(t, h, "GET", "/api/workflows/runs/nope/state-at/reserve", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown run, got %d", rec.Code)
+	}
+
+	rec = do(t, h, "GET", "/api/workflows/runs/"+id+"/history", nil, nil)
+	var history struct {
+		Executions []workflow.StepExecution `json:"executions"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &history)
+	if rec.Code != http.StatusOK || len(history.Executions) != 2 || history.Executions[1].Result != `"c-1"` {
+		t.Errorf("Unexpected history: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, h, "GET", "/api/workflows/runs/nope/history", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown run, got %d", rec.Code)
+	}
+}
+
+func TestStuckEndpoint(t *testing.T) {
+	clock := workflow.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	engine := workflow.NewEngine(nil)
+	engine.SetClock(clock)
+	h := api.NewServer(api.Config{Engine: engine}).Handler()
+
+	started := make(chan struct{})
+	wf := workflow.New("sync").
+		StuckAfter(5*time.Minute).
+		Step("fetch", func(ctx context.Context, state *workflow.State) (any, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}).Then().
+		Build()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		engine.Execute(ctx, wf, nil)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	<-started
+
+	clock.Advance(6 * time.Minute)
+	rec := do(t, h, "GET", "/api/workflows/stuck", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Executions []workflow.StuckExecution `json:"executions"`
+		Count      int                       `json:"count"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if body.Count != 1 || body.Executions[0].Step != "fetch" || body.Executions[0].Workflow != "sync" {
+		t.Errorf("Unexpected response: %+v", body)
+	}
+
+	if rec := do(t, h, "POST", "/api/workflows/stuck", nil, nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", rec.Code)
+	}
+}
+
+func TestCancelEndpoint(t *testing.T) {
+	store := workflow.NewMemoryPersistence()
+	engine := workflow.NewEngine(store)
+	h := api.NewServer(api.Config{Engine: engine}).Handler()
+	engine.Register(workflow.New("order").AwaitSignal("ship", "shipped").Then().Build())
+
+	id, err := engine.Start(context.Background(), "order", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for engine.AwaitingSummary().Total == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Workflow never started awaiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rec := do(t, h, "POST", "/api/workflows/"+id+"/cancel", map[string]string{"reason": "duplicate order"}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	state, _ := store.Load(context.Background(), id)
+	if state.Status != workflow.StatusCancelled {
+		t.Errorf("Expected status cancelled, got %s", state.Status)
+	}
+
+	if rec := do(t, h, "POST", "/api/workflows/"+id+"/cancel", nil, nil); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a finished run, got %d", rec.Code)
+	}
+	if rec := do(t, h, "POST", "/api/workflows/nope/cancel", nil, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown run, got %d", rec.Code)
+	}
+}
+
+func TestWorkflowsEndpoint(t *testing.T) {
+	engine := workflow.NewEngine(workflow.NewMemoryPersistence())
+	h := api.NewServer(api.Config{Engine: engine}).Handler()
+	for _, name := range []string{"a", "b", "a"} {
+		if _, err := engine.Execute(context.Background(), workflow.New(name).Checkpoint("done").Build(), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var resp struct {
+		Executions []workflow.RunSummary `json:"executions"`
+		Count      int                   `json:"count"`
+	}
+	rec := do(t, h, "GET", "/api/workflows?workflow=a&status=completed", nil, nil)
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || resp.Count != 2 || resp.Executions[0].Workflow != "a" {
+		t.Errorf("Unexpected listing: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = do(t, h, "GET", "/api/workflows?limit=1&offset=2", nil, nil)
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Count != 1 {
+		t.Errorf("Expected one run on the last page, got %s", rec.Body)
+	}
+
+	for _, query := range []string{"since=yesterday", "limit=-1"} {
+		if rec := do(t, h, "GET", "/api/workflows?"+query, nil, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestWorkflowGraphEndpoint(t *testing.T) {
+	engine := workflow.NewEngine(nil)
+	engine.Register(workflow.New("report").Checkpoint("done").Build())
+	h := api.NewServer(api.Config{Engine: engine}).Handler()
+
+	var resp map[string]string
+	rec := do(t, h, "GET", "/api/workflows/report/graph", nil, nil)
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || resp["format"] != "mermaid" || !strings.HasPrefix(resp["graph"], "flowchart TD") || !strings.Contains(resp["graph"], "done<br/>checkpoint") {
+		t.Fatalf("Unexpected graph: %d %s", rec.Code, rec.Body)
+	}
+	rec = do(t, h, "GET", "/api/workflows/report/graph?format=dot", nil, nil)
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(resp["graph"], `digraph "report"`) {
+		t.Errorf("Unexpected DOT graph: %d %s", rec.Code, rec.Body)
+	}
+
+	for _, tt := range []struct {
+		method, path string
+		code         int
+	}{
+		{"GET", "/api/workflows/report/graph?format=svg", http.StatusBadRequest},
+		{"GET", "/api/workflows/missing/graph", http.StatusNotFound},
+		{"POST", "/api/workflows/report/graph", http.StatusMethodNotAllowed},
+	} {
+		if rec := do(t, h, tt.method, tt.path, nil, nil); rec.Code != tt.code {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.code, rec.Code)
+		}
+	}
+}
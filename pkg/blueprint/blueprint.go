@@ -0,0 +1,305 @@
+// Package blueprint provides parameterized workflow and agent definitions
+// that can be listed and instantiated at runtime.
+//
+// A blueprint is a YAML document with a parameter schema and a spec template.
+// Instantiating it validates the parameters, renders the spec with
+// text/template and decodes the result into a WorkflowSpec or AgentSpec.
+// Every value the template prints must pass through json or slug, so
+// parameters cannot change the structure of the YAML:
+//
+//	name: research-report
+//	kind: agent
+//	parameters:
+//	  type: object
+//	  properties:
+//	    topic: {type: string}
+//	  required: [topic]
+//	spec: |
+//	  name: research-{{ slug .topic }}
+//	  system_prompt: {{ json (printf "Research %s and write a report." .topic) }}
+package blueprint
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+//go:embed builtin/*.yaml
+var builtinFS embed.FS
+
+// ErrNotFound is returned when a blueprint does not exist.
+var ErrNotFound = errors.New("blueprint: not found")
+
+// Kind is the type of definition a blueprint produces.
+type Kind string
+
+const (
+	KindWorkflow Kind = "workflow"
+	KindAgent    Kind = "agent"
+)
+
+// Blueprint is a parameterized workflow or agent definition.
+type Blueprint struct {
+	Name        string         `yaml:"name" json:"name"`
+	Kind        Kind           `yaml:"kind" json:"kind"`
+	Description string         `yaml:"description" json:"description"`
+	Parameters  tools.Schema   `yaml:"parameters" json:"parameters"`
+	Defaults    map[string]any `yaml:"defaults" json:"defaults,omitempty"`
+	Spec        string         `yaml:"spec" json:"-"`
+
+	tmpl *template.Template
+}
+
+// Parse decodes and compiles a blueprint document.
+func Parse(data []byte) (*Blueprint, error) {
+	var bp Blueprint
+	if err := yaml.Unmarshal(data, &bp); err != nil {
+		return nil, fmt.Errorf("blueprint: %w", err)
+	}
+	if bp.Name == "" {
+		return nil, fmt.Errorf("blueprint: name is required")
+	}
+	if bp.Kind != KindWorkflow && bp.Kind != KindAgent {
+		return nil, fmt.Errorf("blueprint %s: unknown kind %q", bp.Name, bp.Kind)
+	}
+	if bp.Parameters.Type == "" {
+		bp.Parameters.Type = "object"
+	}
+
+	tmpl, err := template.New(bp.Name).Option("missingkey=error").Funcs(funcs).Parse(bp.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("blueprint %s: %w", bp.Name, err)
+	}
+	for _, t := range tmpl.Templates() {
+		if err := checkQuoted(t.Root); err != nil {
+			return nil, fmt.Errorf("blueprint %s: %w", bp.Name, err)
+		}
+	}
+	bp.tmpl = tmpl
+	return &bp, nil
+}
+
+// quoting are the template functions whose output is safe to print into
+// YAML.
+var quoting = map[string]bool{"json": true, "slug": true}
+
+// checkQuoted reports an action under node that prints a value without
+// passing it through json or slug last.
+func checkQuoted(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkQuoted(child); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return nil // assignments print nothing
+		}
+		last := n.Pipe.Cmds[len(n.Pipe.Cmds)-1]
+		if ident, ok := last.Args[0].(*parse.IdentifierNode); !ok || !quoting[ident.Ident] {
+			return fmt.Errorf("%s: printed values must pass through json or slug", n)
+		}
+	case *parse.IfNode:
+		return checkBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		return checkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return checkBranch(&n.BranchNode)
+	}
+	return nil
+}
+
+func checkBranch(n *parse.BranchNode) error {
+	if err := checkQuoted(n.List); err != nil {
+		return err
+	}
+	return checkQuoted(n.ElseList)
+}
+
+// funcs are available to spec templates.
+var funcs = template.FuncMap{
+	// json renders a value as JSON, which is also valid YAML.
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	// join joins the items of a list with sep.
+	"join": func(items []any, sep string) string {
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, sep)
+	},
+	// slug lowercases s and replaces runs of other characters with "-",
+	// for use in definition names.
+	"slug": func(s string) string {
+		return strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	},
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Instance is the result of instantiating a blueprint.
+type Instance struct {
+	Blueprint  string         `json:"blueprint"`
+	Kind       Kind           `json:"kind"`
+	Parameters map[string]any `json:"parameters"`
+	Workflow   *WorkflowSpec  `json:"workflow,omitempty"`
+	Agent      *AgentSpec     `json:"agent,omitempty"`
+}
+
+// Name returns the name of the produced definition.
+func (i *Instance) Name() string {
+	if i.Workflow != nil {
+		return i.Workflow.Name
+	}
+	return i.Agent.Name
+}
+
+// Instantiate validates params against the parameter schema, applies
+// defaults and renders the spec.
+func (bp *Blueprint) Instantiate(params map[string]any) (*Instance, error) {
+	values := make(map[string]any, len(bp.Defaults)+len(params))
+	for k, v := range bp.Defaults {
+		values[k] = v
+	}
+	for k, v := range params {
+		values[k] = v
+	}
+	if err := bp.Parameters.Validate(values); err != nil {
+		return nil, fmt.Errorf("blueprint %s: %w", bp.Name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := bp.tmpl.Execute(&buf, values); err != nil {
+		return nil, fmt.Errorf("blueprint %s: %w", bp.Name, err)
+	}
+
+	inst := &Instance{Blueprint: bp.Name, Kind: bp.Kind, Parameters: values}
+	var target any
+	if bp.Kind == KindWorkflow {
+		inst.Workflow = &WorkflowSpec{}
+		target = inst.Workflow
+	} else {
+		inst.Agent = &AgentSpec{}
+		target = inst.Agent
+	}
+	if err := yaml.Unmarshal(buf.Bytes(), target); err != nil {
+		return nil, fmt.Errorf("blueprint %s: rendered spec: %w", bp.Name, err)
+	}
+	if inst.Name() == "" {
+		return nil, fmt.Errorf("blueprint %s: rendered spec has no name", bp.Name)
+	}
+	return inst, nil
+}
+
+// ============ Catalog ============
+
+// Catalog holds blueprints by name.
+// It is safe for concurrent use.
+type Catalog struct {
+	blueprints map[string]*Blueprint
+	mu         sync.RWMutex
+}
+
+// NewCatalog creates an empty catalog.
+func NewCatalog() *Catalog {
+	return &Catalog{blueprints: make(map[string]*Blueprint)}
+}
+
+// Builtin returns a catalog of the blueprints shipped with GoFlow.
+func Builtin() *Catalog {
+	c := NewCatalog()
+	if err := c.LoadFS(builtinFS, "builtin"); err != nil {
+		panic(err) // embedded blueprints are validated by tests
+	}
+	return c
+}
+
+// Add adds or replaces a blueprint.
+func (c *Catalog) Add(bp *Blueprint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blueprints[bp.Name] = bp
+}
+
+// LoadFS loads every .yaml and .yml file in dir of fsys.
+func (c *Catalog) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("blueprint: %w", err)
+	}
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("blueprint: %w", err)
+		}
+		bp, err := Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		c.Add(bp)
+	}
+	return nil
+}
+
+// LoadDir loads blueprints from a directory on disk. Blueprints replace
+// existing ones with the same name.
+func (c *Catalog) LoadDir(dir string) error {
+	return c.LoadFS(os.DirFS(dir), ".")
+}
+
+// Get returns a blueprint by name.
+func (c *Catalog) Get(name string) (*Blueprint, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	bp, ok := c.blueprints[name]
+	return bp, ok
+}
+
+// List returns all blueprints sorted by name.
+func (c *Catalog) List() []*Blueprint {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	list := make([]*Blueprint, 0, len(c.blueprints))
+	for _, bp := range c.blueprints {
+		list = append(list, bp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Instantiate instantiates the named blueprint.
+func (c *Catalog) Instantiate(name string, params map[string]any) (*Instance, error) {
+	bp, ok := c.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return bp.Instantiate(params)
+}
@@ -0,0 +1,289 @@
+// Package blueprint_test provides tests for blueprint instantiation.
+package blueprint_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/blueprint"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// MockLLM replays scripted responses and records the conversations it receives.
+type MockLLM struct {
+	responses []string
+	calls     [][]core.Message
+	mu        sync.Mutex
+}
+
+func (m *MockLLM) Generate(ctx context.Context, prompt string, opts ...core.Option) (string, error) {
+	return m.GenerateChat(ctx, []core.Message{{Role: core.RoleUser, Content: prompt}}, opts...)
+}
+
+func (m *MockLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, messages)
+	if len(m.calls) > len(m.responses) {
+		return "", fmt.Errorf("no more responses")
+	}
+	return m.responses[len(m.calls)-1], nil
+}
+
+func (m *MockLLM) Stream(ctx context.Context, prompt string, opts ...core.Option) (<-chan string, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (m *MockLLM) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func final(answer string) string {
+	return fmt.Sprintf(`{"action": "final_answer", "action_input": %q}`, answer)
+}
+
+func TestBuiltin(t *testing.T) {
+	list := blueprint.Builtin().List()
+	var names []string
+	for _, bp := range list {
+		names = append(names, bp.Name)
+	}
+	if strings.Join(names, ",") != "deploy-with-approval,research-report,ticket-triage" {
+		t.Errorf("Unexpected builtin blueprints: %v", names)
+	}
+}
+
+func TestDeployWithApproval(t *testing.T) {
+	inst, err := blueprint.Builtin().Instantiate("deploy-with-approval", map[string]any{
+		"service":       "Billing API",
+		"environment":   "production",
+		"approvers":     []any{"alice"},
+		"attempts":      float64(2),
+		"retry_backoff": "1ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.Name() != "deploy-billing-api-production" {
+		t.Errorf("Unexpected name: %s", inst.Name())
+	}
+
+	llm := &MockLLM{responses: []string{final("1. deploy 2. verify")}}
+	wf, err := inst.Workflow.Build(llm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var inputs []string
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{
+		Name: "deploy",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			inputs = append(inputs, input)
+			if len(inputs) == 1 {
+				return "", errors.New("registry unavailable")
+			}
+			return "deployed", nil
+		},
+	})
+	engine := workflow.NewEngine(nil)
+	engine.SetTools(registry)
+
+	done := make(chan error, 1)
+	var state *workflow.State
+	go func() {
+		var err error
+		state, err = engine.Execute(context.Background(), wf, map[string]any{"change": "fix invoices"})
+		done <- err
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for engine.AwaitingSummary().Total == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Workflow never started awaiting approval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	awaiting := engine.AwaitingSummary().Executions[0]
+	if err := engine.Approve(context.Background(), awaiting.StateID, awaiting.Step, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Workflow did not finish")
+	}
+
+	if state.StepResults["plan"] != "1. deploy 2. verify" || state.StepResults["deploy"] != "deployed" {
+		t.Errorf("Unexpected results: %v", state.StepResults)
+	}
+	if len(inputs) != 2 || inputs[1] != `{"service": "Billing API", "environment": "production"}` {
+		t.Errorf("Expected a retried deploy with rendered input, got %q", inputs)
+	}
+	task := llm.calls[0][len(llm.calls[0])-1].Content
+	if !strings.Contains(task, "Billing API to production") || !strings.Contains(task, "fix invoices") {
+		t.Errorf("Task not rendered: %q", task)
+	}
+}
+
+func TestResearchReport(t *testing.T) {
+	inst, err := blueprint.Builtin().Instantiate("research-report", map[string]any{
+		"topic":    "Go generics",
+		"audience": "technical",
+		"tools":    []any{"search"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{
+		Name:        "search",
+		Description: "search the web",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			return "Generics landed in Go 1.18", nil
+		},
+	})
+	registry.Register(&tools.Tool{Name: "shell", Description: "run commands"})
+
+	llm := &MockLLM{responses: []string{
+		`{"action": "search", "action_input": {"query": "go generics"}}`,
+		final("Report: generics landed in Go 1.18."),
+	}}
+	a, err := inst.Agent.Build(llm, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := a.Run(context.Background(), "Write the report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Output != "Report: generics landed in Go 1.18." {
+		t.Errorf("Unexpected output: %q", result.Output)
+	}
+
+	system := llm.calls[0][0].Content
+	if !strings.Contains(system, `"Go generics"`) || !strings.Contains(system, "technical audience") {
+		t.Errorf("System prompt not rendered: %q", system)
+	}
+	if strings.Contains(system, "shell") {
+		t.Error("Agent must only see the declared tools")
+	}
+}
+
+func TestTicketTriage(t *testing.T) {
+	inst, err := blueprint.Builtin().Instantiate("ticket-triage", map[string]any{"product": "Acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	llm := &MockLLM{responses: []string{final(" Billing ")}}
+	wf, err := inst.Workflow.Build(llm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var routed string
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{
+		Name: "route_ticket",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			routed = input
+			return "ok", nil
+		},
+	})
+	engine := workflow.NewEngine(nil)
+	engine.SetTools(registry)
+
+	_, err = engine.Execute(context.Background(), wf, map[string]any{
+		"ticket":    "I was charged twice",
+		"ticket_id": "T-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if routed != `{"ticket": "T-1", "category": "billing"}` {
+		t.Errorf("Unexpected routed input: %s", routed)
+	}
+	if system := llm.calls[0][0].Content; !strings.Contains(system, "bug, billing, question, feature_request") {
+		t.Errorf("Categories not rendered: %q", system)
+	}
+}
+
+func TestInstantiate_Validation(t *testing.T) {
+	_, err := blueprint.Builtin().Instantiate("deploy-with-approval", map[string]any{
+		"environment": "moon",
+	})
+	var invalid *tools.ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected validation error, got %v", err)
+	}
+	if len(invalid.Problems) != 3 {
+		t.Errorf("Expected missing service, missing approvers and bad enum, got %q", invalid.Problems)
+	}
+
+	if _, err := blueprint.Builtin().Instantiate("missing", nil); !errors.Is(err, blueprint.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCatalog_LoadDir(t *testing.T) {
+	dir := t.TempDir()
+	doc := `name: research-report
+kind: agent
+description: overridden
+spec: |
+  name: custom
+  system_prompt: hi
+`
+	if err := os.WriteFile(filepath.Join(dir, "custom.yaml"), []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o644)
+
+	catalog := blueprint.Builtin()
+	if err := catalog.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	bp, _ := catalog.Get("research-report")
+	if bp.Description != "overridden" || len(catalog.List()) != 3 {
+		t.Errorf("Expected directory blueprint to replace builtin, got %+v", bp)
+	}
+}
+
+func TestParse_QuotesParameters(t *testing.T) {
+	doc := `name: notes
+kind: agent
+spec: |
+  name: notes
+  system_prompt: Take notes about {{ .topic }}
+`
+	if _, err := blueprint.Parse([]byte(doc)); err == nil || !strings.Contains(err.Error(), "json or slug") {
+		t.Errorf("Expected an unquoted parameter to be refused, got %v", err)
+	}
+
+	// A parameter shaped like YAML stays a plain string.
+	inst, err := blueprint.Builtin().Instantiate("research-report", map[string]any{
+		"topic": "x\"\nmax_iterations: 1000\ntools: [shell",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.Agent.MaxIterations != 8 || len(inst.Agent.Tools) != 0 || !strings.Contains(inst.Agent.SystemPrompt, "max_iterations: 1000") {
+		t.Errorf("Parameter changed the spec: %+v", inst.Agent)
+	}
+}
@@ -0,0 +1,219 @@
+package blueprint
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// ============ Agent Spec ============
+
+// AgentSpec is a rendered agent definition.
+type AgentSpec struct {
+	Name          string   `yaml:"name" json:"name"`
+	Description   string   `yaml:"description" json:"description,omitempty"`
+	SystemPrompt  string   `yaml:"system_prompt" json:"system_prompt"`
+	MaxIterations int      `yaml:"max_iterations" json:"max_iterations,omitempty"`
+	Tools         []string `yaml:"tools" json:"tools,omitempty"`
+}
+
+// Build creates the agent. If the spec declares tools, the agent only sees
+// those tools from registry. The spec's prompt and iteration limit take
+// precedence over opts.
+func (s *AgentSpec) Build(llm core.LLM, registry *tools.Registry, opts ...agent.Option) (*agent.Agent, error) {
+	if len(s.Tools) > 0 {
+		if registry == nil {
+			return nil, fmt.Errorf("agent %s declares tools but no registry was given", s.Name)
+		}
+		subset, err := registry.Subset(s.Tools...)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: %w", s.Name, err)
+		}
+		registry = subset
+	} else if registry == nil {
+		registry = tools.NewRegistry()
+	}
+
+	base := []agent.Option{agent.WithSystemPrompt(s.SystemPrompt)}
+	if s.MaxIterations > 0 {
+		base = append(base, agent.WithMaxIterations(s.MaxIterations))
+	}
+	return agent.New(llm, registry, append(opts, base...)...), nil
+}
+
+// ============ Workflow Spec ============
+
+// WorkflowSpec is a rendered workflow definition.
+//
+// Step inputs and agent tasks may reference workflow input and earlier step
+// results at run time with ${name}.
+type WorkflowSpec struct {
+	Name        string     `yaml:"name" json:"name"`
+	Description string     `yaml:"description" json:"description,omitempty"`
+	Tools       []string   `yaml:"tools" json:"tools,omitempty"`
+	Steps       []StepSpec `yaml:"steps" json:"steps"`
+}
+
+// StepSpec defines one workflow step. Exactly one of the step kinds is set.
+type StepSpec struct {
+	Name      string         `yaml:"name" json:"name"`
+	Agent     *AgentStepSpec `yaml:"agent" json:"agent,omitempty"`
+	Tool      *ToolStepSpec  `yaml:"tool" json:"tool,omitempty"`
+	Approval  *ApprovalSpec  `yaml:"approval" json:"approval,omitempty"`
+	Transform string         `yaml:"transform" json:"transform,omitempty"`
+	Sleep     string         `yaml:"sleep" json:"sleep,omitempty"`
+}
+
+// AgentStepSpec runs an agent with the workflow's tools.
+type AgentStepSpec struct {
+	SystemPrompt  string `yaml:"system_prompt" json:"system_prompt,omitempty"`
+	Task          string `yaml:"task" json:"task"`
+	MaxIterations int    `yaml:"max_iterations" json:"max_iterations,omitempty"`
+}
+
+// ToolStepSpec calls a tool, retrying up to Attempts times.
+type ToolStepSpec struct {
+	Name     string `yaml:"name" json:"name"`
+	Input    string `yaml:"input" json:"input,omitempty"`
+	Attempts int    `yaml:"attempts" json:"attempts,omitempty"`
+	Backoff  string `yaml:"backoff" json:"backoff,omitempty"`
+}
+
+// ApprovalSpec waits for one of the approvers.
+type ApprovalSpec struct {
+	Approvers []string `yaml:"approvers" json:"approvers"`
+	Timeout   string   `yaml:"timeout" json:"timeout,omitempty"`
+}
+
+// Build creates the workflow. Agent steps use llm.
+func (s *WorkflowSpec) Build(llm core.LLM) (*workflow.Workflow, error) {
+	b := workflow.New(s.Name)
+	if len(s.Tools) > 0 {
+		b.Tools(s.Tools...)
+	}
+
+	for i, step := range s.Steps {
+		if step.Name == "" {
+			return nil, fmt.Errorf("workflow %s: step %d has no name", s.Name, i+1)
+		}
+		if n := step.kinds(); n != 1 {
+			return nil, fmt.Errorf("workflow %s: step '%s' must define exactly one step kind, got %d", s.Name, step.Name, n)
+		}
+
+		switch {
+		case step.Agent != nil:
+			spec := &AgentSpec{
+				Name:          step.Name,
+				SystemPrompt:  step.Agent.SystemPrompt,
+				MaxIterations: step.Agent.MaxIterations,
+			}
+			task := step.Agent.Task
+			b.Agent(step.Name, func(registry *tools.Registry) *agent.Agent {
+				a, _ := spec.Build(llm, registry)
+				return a
+			}, func(state *workflow.State) string {
+				return expand(task, state)
+			})
+
+		case step.Tool != nil:
+			if err := addToolStep(b, step.Name, step.Tool); err != nil {
+				return nil, fmt.Errorf("workflow %s: step '%s': %w", s.Name, step.Name, err)
+			}
+
+		case step.Approval != nil:
+			ab := b.AwaitApproval(step.Name, step.Approval.Approvers)
+			if step.Approval.Timeout != "" {
+				d, err := time.ParseDuration(step.Approval.Timeout)
+				if err != nil {
+					return nil, fmt.Errorf("workflow %s: step '%s': %w", s.Name, step.Name, err)
+				}
+				ab.Timeout(d)
+			}
+
+		case step.Transform != "":
+			if _, err := workflow.CompileExpr(step.Transform); err != nil {
+				return nil, fmt.Errorf("workflow %s: step '%s': %w", s.Name, step.Name, err)
+			}
+			b.Transform(step.Name, step.Transform)
+
+		case step.Sleep != "":
+			d, err := time.ParseDuration(step.Sleep)
+			if err != nil {
+				return nil, fmt.Errorf("workflow %s: step '%s': %w", s.Name, step.Name, err)
+			}
+			b.Sleep(step.Name, d)
+		}
+	}
+
+	wf := b.Build()
+	if err := wf.Validate(); err != nil {
+		return nil, err
+	}
+	return wf, nil
+}
+
+func (s StepSpec) kinds() int {
+	n := 0
+	for _, set := range []bool{s.Agent != nil, s.Tool != nil, s.Approval != nil, s.Transform != "", s.Sleep != ""} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+func addToolStep(b *workflow.Builder, name string, spec *ToolStepSpec) error {
+	input := func(state *workflow.State) string {
+		if spec.Input == "" {
+			return "{}"
+		}
+		return expand(spec.Input, state)
+	}
+	if spec.Attempts <= 1 {
+		b.CallTool(name, spec.Name, input)
+		return nil
+	}
+
+	policy := workflow.NewRetryPolicy().Attempts(spec.Attempts)
+	if spec.Backoff != "" {
+		d, err := time.ParseDuration(spec.Backoff)
+		if err != nil {
+			return err
+		}
+		policy.Exponential(d, policy.MaxDelay)
+	}
+	b.Step(name, func(ctx context.Context, state *workflow.State) (any, error) {
+		registry, ok := workflow.ToolsFromContext(ctx)
+		if !ok {
+			return nil, fmt.Errorf("no tool registry available")
+		}
+		tool, ok := registry.Get(spec.Name)
+		if !ok {
+			return nil, fmt.Errorf("tool %q is not available to this workflow", spec.Name)
+		}
+		return tool.Execute(ctx, input(state))
+	}).Retry(policy)
+	return nil
+}
+
+var placeholder = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+
+// expand replaces ${name} with the workflow input or step result of that name.
+func expand(s string, state *workflow.State) string {
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		key := placeholder.FindStringSubmatch(m)[1]
+		if v, ok := state.Result(key); ok {
+			return fmt.Sprint(v)
+		}
+		if v, ok := state.Get(key); ok {
+			return fmt.Sprint(v)
+		}
+		return m
+	})
+}
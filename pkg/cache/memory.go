@@ -3,33 +3,58 @@ package cache
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// memoryShards is the number of independently locked maps a MemoryCache
+// spreads its keys over.
+const memoryShards = 32
+
 // MemoryCache implements Cache using an in-memory map.
 // Useful for testing and development without DragonflyDB.
+//
+// Keys are spread over shards, each with its own lock and hit/miss
+// counters, so lookups on different keys do not contend. Stats and Keys
+// lock every shard at once to read a single point-in-time view.
 type MemoryCache struct {
-	mu      sync.RWMutex
-	data    map[string]cacheEntry
+	shards  [memoryShards]memoryShard
 	config  Config
-	closed  bool
+	closed  atomic.Bool
 	cleanup *time.Ticker
 	done    chan struct{}
 }
 
+// memoryShard holds one slice of the key space. A plain mutex costs the
+// same as a read lock and lets Get bump the counters without atomics.
+type memoryShard struct {
+	mu     sync.Mutex
+	data   map[string]cacheEntry
+	hits   int64
+	misses int64
+}
+
 type cacheEntry struct {
 	value     []byte
 	expiresAt time.Time
 }
 
+func (e cacheEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
 // NewMemoryCache creates a new in-memory cache.
 func NewMemoryCache(cfg Config) *MemoryCache {
 	mc := &MemoryCache{
-		data:   make(map[string]cacheEntry),
 		config: cfg,
 		done:   make(chan struct{}),
 	}
+	for i := range mc.shards {
+		mc.shards[i].data = make(map[string]cacheEntry)
+	}
 
 	// Start background cleanup goroutine
 	mc.cleanup = time.NewTicker(1 * time.Minute)
@@ -50,15 +75,41 @@ func (mc *MemoryCache) cleanupLoop() {
 	}
 }
 
-// removeExpired removes all expired entries.
+// removeExpired removes all expired entries, one shard at a time.
 func (mc *MemoryCache) removeExpired() {
-	mc.mu.Lock()
-	defer mc.mu.Unlock()
-
 	now := time.Now()
-	for key, entry := range mc.data {
-		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
-			delete(mc.data, key)
+	for i := range mc.shards {
+		sh := &mc.shards[i]
+		sh.mu.Lock()
+		for key, entry := range sh.data {
+			if entry.expired(now) {
+				delete(sh.data, key)
+			}
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// shard returns the shard that holds a prefixed key, picked by an inlined
+// FNV-1a hash, which is cheaper than hash/maphash for short keys.
+func (mc *MemoryCache) shard(key string) *memoryShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &mc.shards[h%memoryShards]
+}
+
+// lockAll locks every shard in index order and returns a function
+// that releases them.
+func (mc *MemoryCache) lockAll() func() {
+	for i := range mc.shards {
+		mc.shards[i].mu.Lock()
+	}
+	return func() {
+		for i := range mc.shards {
+			mc.shards[i].mu.Unlock()
 		}
 	}
 }
@@ -73,22 +124,21 @@ func (mc *MemoryCache) prefixKey(key string) string {
 
 // Get retrieves a value from memory.
 func (mc *MemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
-	mc.mu.RLock()
-	defer mc.mu.RUnlock()
-
-	if mc.closed {
+	if mc.closed.Load() {
 		return nil, ErrCacheMiss
 	}
 
-	entry, ok := mc.data[mc.prefixKey(key)]
-	if !ok {
-		return nil, ErrCacheMiss
-	}
-
-	// Check expiration
-	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+	key = mc.prefixKey(key)
+	sh := mc.shard(key)
+	sh.mu.Lock()
+	entry, ok := sh.data[key]
+	if !ok || (!entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt)) {
+		sh.misses++
+		sh.mu.Unlock()
 		return nil, ErrCacheMiss
 	}
+	sh.hits++
+	sh.mu.Unlock()
 
 	// Return a copy to prevent mutation
 	result := make([]byte, len(entry.value))
@@ -98,10 +148,7 @@ func (mc *MemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
 
 // Set stores a value in memory.
 func (mc *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
-	mc.mu.Lock()
-	defer mc.mu.Unlock()
-
-	if mc.closed {
+	if mc.closed.Load() {
 		return ErrCacheMiss
 	}
 
@@ -118,29 +165,35 @@ func (mc *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl ti
 	valueCopy := make([]byte, len(value))
 	copy(valueCopy, value)
 
-	mc.data[mc.prefixKey(key)] = cacheEntry{
+	key = mc.prefixKey(key)
+	sh := mc.shard(key)
+	sh.mu.Lock()
+	sh.data[key] = cacheEntry{
 		value:     valueCopy,
 		expiresAt: expiresAt,
 	}
+	sh.mu.Unlock()
 
 	return nil
 }
 
 // Delete removes a key from memory.
 func (mc *MemoryCache) Delete(ctx context.Context, key string) error {
-	mc.mu.Lock()
-	defer mc.mu.Unlock()
-
-	delete(mc.data, mc.prefixKey(key))
+	key = mc.prefixKey(key)
+	sh := mc.shard(key)
+	sh.mu.Lock()
+	delete(sh.data, key)
+	sh.mu.Unlock()
 	return nil
 }
 
 // Exists checks if a key exists and is not expired.
 func (mc *MemoryCache) Exists(ctx context.Context, key string) (bool, error) {
-	mc.mu.RLock()
-	defer mc.mu.RUnlock()
-
-	entry, ok := mc.data[mc.prefixKey(key)]
+	key = mc.prefixKey(key)
+	sh := mc.shard(key)
+	sh.mu.Lock()
+	entry, ok := sh.data[key]
+	sh.mu.Unlock()
 	if !ok {
 		return false, nil
 	}
@@ -154,20 +207,17 @@ func (mc *MemoryCache) Exists(ctx context.Context, key string) (bool, error) {
 
 // Clear removes all keys.
 func (mc *MemoryCache) Clear(ctx context.Context) error {
-	mc.mu.Lock()
-	defer mc.mu.Unlock()
+	defer mc.lockAll()()
 
-	mc.data = make(map[string]cacheEntry)
+	for i := range mc.shards {
+		mc.shards[i].data = make(map[string]cacheEntry)
+	}
 	return nil
 }
 
 // Close stops the cleanup goroutine.
 func (mc *MemoryCache) Close() error {
-	mc.mu.Lock()
-	defer mc.mu.Unlock()
-
-	if !mc.closed {
-		mc.closed = true
+	if mc.closed.CompareAndSwap(false, true) {
 		mc.cleanup.Stop()
 		close(mc.done)
 	}
@@ -175,12 +225,48 @@ func (mc *MemoryCache) Close() error {
 	return nil
 }
 
-// Stats returns cache statistics.
+// Stats returns cache statistics as of a single instant. Expired entries
+// that have not been cleaned up yet are not counted.
 func (mc *MemoryCache) Stats(ctx context.Context) (CacheStats, error) {
-	mc.mu.RLock()
-	defer mc.mu.RUnlock()
+	defer mc.lockAll()()
+
+	now := time.Now()
+	var stats CacheStats
+	for i := range mc.shards {
+		sh := &mc.shards[i]
+		stats.Hits += sh.hits
+		stats.Misses += sh.misses
+		for key, entry := range sh.data {
+			if entry.expired(now) {
+				continue
+			}
+			stats.KeyCount++
+			stats.MemoryUsed += int64(len(key) + len(entry.value))
+		}
+	}
+	return stats, nil
+}
 
-	return CacheStats{
-		KeyCount: int64(len(mc.data)),
-	}, nil
+// Keys returns the live keys, without the configured prefix, in sorted
+// order.
+func (mc *MemoryCache) Keys(ctx context.Context) ([]string, error) {
+	unlock := mc.lockAll()
+	now := time.Now()
+	var keys []string
+	for i := range mc.shards {
+		for key, entry := range mc.shards[i].data {
+			if !entry.expired(now) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	unlock()
+
+	if mc.config.Prefix != "" {
+		for i, key := range keys {
+			keys[i] = strings.TrimPrefix(key, mc.config.Prefix+":")
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
 }
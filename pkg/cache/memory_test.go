@@ -0,0 +1,182 @@
+// Package cache_test provides tests for the in-memory cache.
+package cache_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/cache"
+)
+
+func TestMemoryCache_StatsAndKeys(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemoryCache(cache.Config{Prefix: "app"})
+	defer c.Close()
+
+	c.Set(ctx, "b", []byte("22"), 0)
+	c.Set(ctx, "a", []byte("1"), 0)
+	c.Set(ctx, "gone", []byte("x"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	c.Get(ctx, "a")
+	c.Get(ctx, "gone")
+	c.Get(ctx, "missing")
+
+	keys, err := c.Keys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(keys, ","); got != "a,b" {
+		t.Errorf("Keys = %s, want live keys without the prefix", got)
+	}
+
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := cache.CacheStats{Hits: 1, Misses: 2, KeyCount: 2, MemoryUsed: int64(len("app:a1") + len("app:b22"))}
+	if stats != want {
+		t.Errorf("Stats = %+v, want %+v", stats, want)
+	}
+}
+
+// TestMemoryCache_ConcurrentSnapshots runs readers against writers that
+// churn keys continuously. Run with -race.
+func TestMemoryCache_ConcurrentSnapshots(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemoryCache(cache.Config{})
+	defer c.Close()
+
+	const stable = 20
+	for i := 0; i < stable; i++ {
+		c.Set(ctx, "stable-"+strconv.Itoa(i), []byte("v"), 0)
+	}
+
+	stop := make(chan struct{})
+	var writers sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				key := "churn-" + strconv.Itoa(w) + "-" + strconv.Itoa(i%50)
+				c.Set(ctx, key, []byte("v"), 0)
+				c.Get(ctx, key)
+				c.Delete(ctx, key)
+			}
+		}(w)
+	}
+
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			var lastLookups int64
+			for i := 0; i < 200; i++ {
+				stats, _ := c.Stats(ctx)
+				if stats.KeyCount < stable || stats.KeyCount > stable+4 {
+					t.Errorf("Torn key count: %d", stats.KeyCount)
+					return
+				}
+				if lookups := stats.Hits + stats.Misses; lookups < lastLookups {
+					t.Errorf("Lookup counters went backwards: %d < %d", lookups, lastLookups)
+					return
+				} else {
+					lastLookups = lookups
+				}
+
+				keys, _ := c.Keys(ctx)
+				n := 0
+				for _, key := range keys {
+					if strings.HasPrefix(key, "stable-") {
+						n++
+					}
+				}
+				if n != stable {
+					t.Errorf("Keys saw %d of %d stable keys", n, stable)
+					return
+				}
+			}
+		}()
+	}
+
+	readers.Wait()
+	close(stop)
+	writers.Wait()
+}
+
+func BenchmarkMemoryCache_SetGet(b *testing.B) {
+	ctx := context.Background()
+	c := cache.NewMemoryCache(cache.Config{})
+	defer c.Close()
+	value := []byte("value")
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := keys[i%len(keys)]
+		c.Set(ctx, k, value, 0)
+		c.Get(ctx, k)
+	}
+}
+
+func BenchmarkMemoryCache_Get(b *testing.B) {
+	ctx := context.Background()
+	c := cache.NewMemoryCache(cache.Config{})
+	defer c.Close()
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+		c.Set(ctx, keys[i], []byte("value"), 0)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Get(ctx, keys[i%len(keys)])
+	}
+}
+
+func BenchmarkMemoryCache_GetParallel(b *testing.B) {
+	ctx := context.Background()
+	c := cache.NewMemoryCache(cache.Config{})
+	defer c.Close()
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+		c.Set(ctx, keys[i], []byte("value"), 0)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			c.Get(ctx, keys[i%len(keys)])
+		}
+	})
+}
+
+func BenchmarkMemoryCache_Stats(b *testing.B) {
+	ctx := context.Background()
+	c := cache.NewMemoryCache(cache.Config{})
+	defer c.Close()
+	for i := 0; i < 1000; i++ {
+		c.Set(ctx, "key"+strconv.Itoa(i), []byte("value"), 0)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Stats(ctx)
+	}
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// APIError is an error response from an LLM provider.
+type APIError struct {
+	// Provider is the provider name, as returned by ModelInfo.Provider.
+	Provider string
+	// StatusCode is the HTTP status, or zero for errors reported inside a
+	// stream.
+	StatusCode int
+	// Type is the provider's error type, such as "overloaded_error".
+	Type    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s API error: %s", e.Provider, e.Message)
+	}
+	return fmt.Sprintf("%s API error (%d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the request may succeed if repeated: rate
+// limits, server errors and overload.
+func (e *APIError) Retryable() bool {
+	switch e.Type {
+	case "overloaded_error", "rate_limit_error", "server_error", "api_error":
+		return true
+	}
+	return e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= 500
+}
+
+// IsRetryable reports whether err is a transient provider failure: a
+// retryable APIError, a timeout, or a dropped connection. Cancellation by
+// the caller is not retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
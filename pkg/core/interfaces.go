@@ -2,7 +2,10 @@
 // These interfaces allow swapping providers (OpenAI, Anthropic, Ollama, etc.) seamlessly.
 package core
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Option represents a configuration option for LLM calls.
 // Use functional options pattern for extensibility.
@@ -16,8 +19,26 @@ type CallOptions struct {
 	StopSequences    []string
 	PresencePenalty  float64
 	FrequencyPenalty float64
+	// ModelTier hints which class of model should serve the call. Plain
+	// providers ignore it; routers use it to pick a backend.
+	ModelTier ModelTier
+	// OnUsage receives the token usage of the call once it is known.
+	OnUsage func(Usage)
+	// JSONResponse asks the provider to constrain the reply to a JSON
+	// object. Providers without a JSON mode ignore it.
+	JSONResponse bool
 }
 
+// ModelTier classifies models by capability and cost.
+type ModelTier string
+
+const (
+	// TierFast selects a cheap, low-latency model.
+	TierFast ModelTier = "fast"
+	// TierSmart selects the most capable model.
+	TierSmart ModelTier = "smart"
+)
+
 // WithTemperature sets the temperature for generation.
 func WithTemperature(t float64) Option {
 	return func(o *CallOptions) {
@@ -46,10 +67,111 @@ func WithStopSequences(seqs ...string) Option {
 	}
 }
 
+// WithModelTier hints the model tier that should serve the call.
+func WithModelTier(tier ModelTier) Option {
+	return func(o *CallOptions) {
+		o.ModelTier = tier
+	}
+}
+
+// WithJSONResponse asks for a reply that is a single JSON object, using the
+// provider's JSON mode where it has one. The prompt should still describe
+// the expected object.
+func WithJSONResponse() Option {
+	return func(o *CallOptions) {
+		o.JSONResponse = true
+	}
+}
+
+// WithUsageCallback registers fn to receive the token usage of the call.
+// Streaming calls report usage from the final chunk when the provider sends
+// it; fn is not called when usage is unavailable.
+func WithUsageCallback(fn func(Usage)) Option {
+	return func(o *CallOptions) {
+		o.OnUsage = fn
+	}
+}
+
+// ReportUsage passes u to the usage callback set in opts, if any.
+func ReportUsage(opts []Option, u Usage) {
+	var options CallOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.OnUsage != nil {
+		options.OnUsage(u)
+	}
+}
+
+// Usage is the number of tokens consumed by an LLM call, attributed to the
+// provider and model that served it.
+type Usage struct {
+	Provider         string `json:"provider,omitempty"`
+	Model            string `json:"model,omitempty"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+}
+
+// Add accumulates the token counts of other into u.
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
 // Message represents a chat message with a role and content.
 type Message struct {
-	Role    Role
-	Content string
+	Role    Role   `json:"role"`
+	Content string `json:"content"`
+	// Name is the tool name of a RoleTool message.
+	Name string `json:"name,omitempty"`
+	// ToolCallID links a RoleTool message to the tool call it answers.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// ToolCalls lists the tools requested by a RoleAssistant message.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ToolCall is a tool invocation requested by the model.
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON-encoded arguments
+}
+
+// ToolMessageSupport is implemented by providers that accept native tool
+// messages (RoleTool with ToolCallID, assistant ToolCalls). Agents fall back
+// to plain "Observation:" user messages for other providers.
+type ToolMessageSupport interface {
+	SupportsToolMessages() bool
+}
+
+// ToolDefinition describes a tool offered to a provider's native tool
+// calling API.
+type ToolDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Parameters is the JSON schema of the tool input.
+	Parameters any `json:"parameters"`
+}
+
+// Response is a model reply that may request tool calls instead of, or in
+// addition to, returning text.
+type Response struct {
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// StopReason is the provider's reason for ending the reply, such as
+	// "tool_use" or "tool_calls".
+	StopReason string `json:"stop_reason,omitempty"`
+	// Usage is the token usage of the reply, if the provider reported it.
+	Usage Usage `json:"usage"`
+}
+
+// ToolCallingLLM is implemented by providers with native tool calling.
+// Agents pass tool definitions to it and read structured tool calls back
+// instead of parsing actions out of the response text.
+type ToolCallingLLM interface {
+	GenerateWithTools(ctx context.Context, messages []Message, tools []ToolDefinition, opts ...Option) (*Response, error)
 }
 
 // Role represents the role of a message sender.
@@ -106,8 +228,81 @@ type StreamEvent struct {
 	Done bool
 }
 
+// EventStreamer is implemented by providers that report stream failures.
+// StreamChat closes its channel on a mid-stream error just as on success;
+// StreamChatEvents delivers the error as a final event with Err set. A
+// stream that completes normally ends with a Done event.
+type EventStreamer interface {
+	StreamChatEvents(ctx context.Context, messages []Message, opts ...Option) (<-chan StreamEvent, error)
+}
+
+// StreamText forwards the content of events as text chunks, dropping
+// errors. It is used to implement StreamChat on top of StreamChatEvents.
+func StreamText(ctx context.Context, events <-chan StreamEvent) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for ev := range events {
+			if ev.Content == "" {
+				continue
+			}
+			select {
+			case ch <- ev.Content:
+			case <-ctx.Done():
+				// Let the producer observe cancellation and close events.
+				for range events {
+				}
+				return
+			}
+		}
+	}()
+	return ch
+}
+
 // TokenCounter provides token counting functionality for LLM inputs.
 type TokenCounter interface {
 	// CountTokens returns the number of tokens in the given text.
 	CountTokens(ctx context.Context, text string) (int, error)
 }
+
+// LLMHealth is implemented by providers that can cheaply verify their
+// credentials and model availability without generating text.
+type LLMHealth interface {
+	// Ping performs a minimal request against the provider, such as
+	// looking up the configured model. It returns nil if the provider is usable.
+	Ping(ctx context.Context) error
+}
+
+// ModelInfo describes the provider and model behind an LLM.
+type ModelInfo interface {
+	// Provider returns the provider name (e.g. "openai").
+	Provider() string
+	// Model returns the configured model identifier.
+	Model() string
+}
+
+// BackendProvider is implemented by composite LLMs (fallback chains, routers)
+// so that the health of each backend can be reported separately.
+type BackendProvider interface {
+	// Backends returns the underlying LLMs in priority order.
+	Backends() []LLM
+}
+
+// HealthState is the result of an LLM health check.
+type HealthState string
+
+const (
+	HealthOK      HealthState = "ok"
+	HealthError   HealthState = "error"
+	HealthUnknown HealthState = "unknown"
+)
+
+// HealthStatus reports the health of a single LLM backend.
+type HealthStatus struct {
+	Provider    string      `json:"provider"`
+	Model       string      `json:"model"`
+	Status      HealthState `json:"status"`
+	Error       string      `json:"error,omitempty"`
+	LastSuccess time.Time   `json:"last_success,omitempty"`
+	CheckedAt   time.Time   `json:"checked_at"`
+}
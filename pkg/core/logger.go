@@ -0,0 +1,11 @@
+package core
+
+// Logger is the structured logging interface used by GoFlow components.
+// Arguments are alternating keys and values, as in log/slog; a
+// *slog.Logger satisfies it directly.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
@@ -0,0 +1,181 @@
+// Package egress provides an outbound HTTP allowlist for untrusted agent
+// workloads.
+//
+// A process-wide Policy is enforced by the RoundTripper returned from
+// Transport. The web toolkit, the webhook tool and the integration clients
+// build their HTTP clients with NewClient, so a single SetPolicy call covers
+// every request they make, including redirects.
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/metrics"
+)
+
+// ErrEgressDenied is returned for requests to hosts the policy does not allow.
+// Use errors.As with *DeniedError to get the host.
+var ErrEgressDenied = errors.New("egress: host not allowed")
+
+// DeniedError reports a request blocked by the egress policy.
+type DeniedError struct {
+	Host string
+	URL  string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("egress: host %q not allowed by policy", e.Host)
+}
+
+// Unwrap returns ErrEgressDenied.
+func (e *DeniedError) Unwrap() error { return ErrEgressDenied }
+
+// ============ Policy ============
+
+// Policy restricts which hosts outbound requests may reach.
+type Policy struct {
+	// Allow lists host patterns: "api.example.com" matches that host,
+	// "*.example.com" matches its subdomains and "*" matches any host.
+	// A pattern with a port, such as "localhost:8080", matches only that port.
+	Allow []string `json:"allow"`
+	// DenyByDefault blocks every host not matched by Allow. When false the
+	// policy allows all requests.
+	DenyByDefault bool `json:"deny_by_default"`
+}
+
+// Allows reports whether requests to u are allowed.
+func (p Policy) Allows(u *url.URL) bool {
+	if !p.DenyByDefault {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http", "ws":
+			port = "80"
+		case "https", "wss":
+			port = "443"
+		}
+	}
+
+	for _, pattern := range p.Allow {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		patternHost, patternPort := pattern, ""
+		if h, p, err := net.SplitHostPort(pattern); err == nil {
+			patternHost, patternPort = h, p
+		}
+		if patternPort != "" && patternPort != port {
+			continue
+		}
+		if matchHost(patternHost, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchHost(pattern, host string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(host, pattern[1:])
+	default:
+		return pattern == host
+	}
+}
+
+// Check returns a *DeniedError if the policy does not allow u.
+func (p Policy) Check(u *url.URL) error {
+	if p.Allows(u) {
+		return nil
+	}
+	return &DeniedError{Host: u.Host, URL: u.String()}
+}
+
+// ============ Global Policy ============
+
+var current atomic.Pointer[Policy]
+
+// SetPolicy installs the process-wide egress policy.
+func SetPolicy(p Policy) {
+	p.Allow = append([]string(nil), p.Allow...)
+	current.Store(&p)
+}
+
+// CurrentPolicy returns the process-wide egress policy.
+// The zero Policy, which allows everything, is the default.
+func CurrentPolicy() Policy {
+	if p := current.Load(); p != nil {
+		return *p
+	}
+	return Policy{}
+}
+
+// Check validates rawURL against the process-wide policy. It is meant for
+// tools that hand URLs to a remote service instead of fetching them.
+func Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("egress: invalid URL: %w", err)
+	}
+	if err := CurrentPolicy().Check(u); err != nil {
+		metrics.EgressDenied()
+		return err
+	}
+	return nil
+}
+
+// ============ Transport ============
+
+// RoundTripper enforces the egress policy before delegating to Base.
+type RoundTripper struct {
+	// Base performs allowed requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+	// Policy overrides the process-wide policy when set.
+	Policy *Policy
+}
+
+// Transport wraps base with the process-wide egress policy.
+// The policy is read on every request, so clients created before
+// SetPolicy is called are covered too.
+func Transport(base http.RoundTripper) *RoundTripper {
+	return &RoundTripper{Base: base}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	policy := CurrentPolicy()
+	if t.Policy != nil {
+		policy = *t.Policy
+	}
+	if err := policy.Check(req.URL); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		metrics.EgressDenied()
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// NewClient creates an HTTP client governed by the process-wide policy.
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: Transport(nil),
+	}
+}
@@ -0,0 +1,212 @@
+// Package egress_test provides tests for the egress policy and its consumers.
+package egress_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/egress"
+	"github.com/nuulab/goflow/pkg/integrations/browserbase"
+	"github.com/nuulab/goflow/pkg/integrations/e2b"
+	"github.com/nuulab/goflow/pkg/metrics"
+	"github.com/nuulab/goflow/pkg/tools"
+	"github.com/nuulab/goflow/pkg/webhook"
+)
+
+func setPolicy(t *testing.T, allow ...string) {
+	t.Helper()
+	egress.SetPolicy(egress.Policy{Allow: allow, DenyByDefault: true})
+	t.Cleanup(func() { egress.SetPolicy(egress.Policy{}) })
+}
+
+func assertDenied(t *testing.T, err error, host string) {
+	t.Helper()
+	if !errors.Is(err, egress.ErrEgressDenied) {
+		t.Fatalf("Expected ErrEgressDenied, got %v", err)
+	}
+	var denied *egress.DeniedError
+	if !errors.As(err, &denied) || denied.Host != host {
+		t.Errorf("Expected denial for %s, got %+v", host, denied)
+	}
+}
+
+// stubTransport answers every request with body.
+type stubTransport struct {
+	body     string
+	requests []*http.Request
+}
+
+func (s *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.requests = append(s.requests, req)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(s.body)),
+		Request:    req,
+	}, nil
+}
+
+func TestPolicy_Allows(t *testing.T) {
+	policy := egress.Policy{
+		Allow:         []string{"api.example.com", "*.internal.dev", "localhost:8080"},
+		DenyByDefault: true,
+	}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://api.example.com/v1", true},
+		{"https://API.example.com/v1", true},
+		{"https://example.com", false},
+		{"https://evil-api.example.com", false},
+		{"https://a.internal.dev", true},
+		{"https://a.b.internal.dev", true},
+		{"https://internal.dev", false},
+		{"http://localhost:8080/x", true},
+		{"http://localhost:9090/x", false},
+		{"http://localhost/x", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := policy.Allows(u); got != tt.want {
+			t.Errorf("Allows(%s) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+
+	u, _ := url.Parse("https://anything.test")
+	if !(egress.Policy{}).Allows(u) {
+		t.Error("Zero policy should allow all hosts")
+	}
+	if !(egress.Policy{Allow: []string{"*"}, DenyByDefault: true}).Allows(u) {
+		t.Error("Wildcard should allow all hosts")
+	}
+}
+
+func TestTransport_Redirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://blocked.test/", http.StatusFound)
+	}))
+	defer srv.Close()
+	setPolicy(t, "127.0.0.1")
+
+	_, err := egress.NewClient(0).Get(srv.URL)
+	assertDenied(t, err, "blocked.test")
+}
+
+func TestTransport_Metrics(t *testing.T) {
+	setPolicy(t)
+	before := metrics.DefaultMetrics.EgressDenied.Value()
+
+	egress.NewClient(0).Get("http://blocked.test/")
+	if err := egress.Check("https://also-blocked.test/page"); err == nil {
+		t.Error("Expected Check to deny")
+	}
+
+	if got := metrics.DefaultMetrics.EgressDenied.Value() - before; got != 2 {
+		t.Errorf("Expected 2 denials counted, got %v", got)
+	}
+}
+
+func TestTransport_FixedPolicy(t *testing.T) {
+	stub := &stubTransport{body: "ok"}
+	client := &http.Client{Transport: &egress.RoundTripper{
+		Base:   stub,
+		Policy: &egress.Policy{Allow: []string{"fixed.test"}, DenyByDefault: true},
+	}}
+
+	if _, err := client.Get("http://fixed.test/"); err != nil {
+		t.Fatalf("Fixed policy should allow fixed.test: %v", err)
+	}
+	_, err := client.Get("http://other.test/")
+	assertDenied(t, err, "other.test")
+}
+
+func TestWebToolkit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	registry := tools.NewRegistry()
+	tools.WebToolkit().RegisterTo(registry)
+	tool, _ := registry.Get("http_get")
+
+	setPolicy(t, "127.0.0.1")
+	out, err := tool.Execute(context.Background(), `{"url": "`+srv.URL+`"}`)
+	if err != nil || !strings.Contains(out, "ok") {
+		t.Fatalf("Allowed request failed: %q, %v", out, err)
+	}
+
+	setPolicy(t, "api.example.com")
+	_, err = tool.Execute(context.Background(), `{"url": "`+srv.URL+`"}`)
+	assertDenied(t, err, host)
+
+	// Custom tools share the toolkit's client factory.
+	_, err = tools.NewHTTPClient(time.Second).Get(srv.URL)
+	assertDenied(t, err, host)
+}
+
+func TestWebhookTool(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("received"))
+	}))
+	defer srv.Close()
+	wt := webhook.NewWebhookTool(nil)
+	input := webhook.SendWebhookInput{URL: srv.URL, Event: "test"}
+
+	setPolicy(t, "127.0.0.1")
+	if _, err := wt.SendWebhook(context.Background(), input); err != nil {
+		t.Fatalf("Allowed webhook failed: %v", err)
+	}
+
+	setPolicy(t, "hooks.example.com")
+	_, err := wt.SendWebhook(context.Background(), input)
+	assertDenied(t, err, strings.TrimPrefix(srv.URL, "http://"))
+}
+
+func TestE2BClient(t *testing.T) {
+	setPolicy(t, "hooks.example.com")
+	_, err := e2b.New("key").CreateSandbox(context.Background(), e2b.CreateSandboxOptions{})
+	assertDenied(t, err, "api.e2b.dev")
+
+	setPolicy(t, "api.e2b.dev")
+	stub := &stubTransport{body: `{"sandboxId": "sb-1"}`}
+	client := e2b.New("key").WithHTTPClient(&http.Client{Transport: egress.Transport(stub)})
+	sandbox, err := client.CreateSandbox(context.Background(), e2b.CreateSandboxOptions{})
+	if err != nil || sandbox.ID != "sb-1" {
+		t.Fatalf("Allowed request failed: %+v, %v", sandbox, err)
+	}
+}
+
+func TestBrowserbaseClient(t *testing.T) {
+	setPolicy(t)
+	_, err := browserbase.New("key", "project").CreateSession(context.Background(), nil)
+	assertDenied(t, err, "www.browserbase.com")
+
+	setPolicy(t, "www.browserbase.com", "docs.example.com")
+	stub := &stubTransport{body: `{"id": "s-1", "success": true}`}
+	client := browserbase.New("key", "project").WithHTTPClient(&http.Client{Transport: egress.Transport(stub)})
+	session, err := client.CreateSession(context.Background(), nil)
+	if err != nil || session.ID != "s-1" {
+		t.Fatalf("Allowed request failed: %+v, %v", session, err)
+	}
+
+	if _, err := session.Navigate(context.Background(), "https://docs.example.com/page"); err != nil {
+		t.Errorf("Allowed navigation failed: %v", err)
+	}
+	sent := len(stub.requests)
+	_, err = session.Navigate(context.Background(), "https://blocked.test/page")
+	assertDenied(t, err, "blocked.test")
+	if len(stub.requests) != sent {
+		t.Error("Denied navigation must not reach Browserbase")
+	}
+}
@@ -0,0 +1,187 @@
+// Package eval provides offline evaluation of agents against test suites.
+// A suite is a list of cases with expected answers; running it produces a
+// Report with accuracy, iteration and tool error metrics that gates can check.
+package eval
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"go.yaml.in/yaml/v3"
+)
+
+// Case is a single evaluation case.
+type Case struct {
+	// Name identifies the case in reports.
+	Name string `yaml:"name" json:"name"`
+	// Input is the task given to the agent.
+	Input string `yaml:"input" json:"input"`
+	// Expected, if set, must appear in the output (case-insensitive).
+	Expected string `yaml:"expected" json:"expected,omitempty"`
+	// Contains lists additional substrings that must all appear in the output.
+	Contains []string `yaml:"contains" json:"contains,omitempty"`
+}
+
+// Suite is a named collection of cases.
+type Suite struct {
+	Name  string `yaml:"name" json:"name"`
+	Cases []Case `yaml:"cases" json:"cases"`
+}
+
+// LoadSuite reads a suite from a YAML or JSON file.
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("eval: read suite: %w", err)
+	}
+	var suite Suite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("eval: parse suite %s: %w", path, err)
+	}
+	if len(suite.Cases) == 0 {
+		return nil, fmt.Errorf("eval: suite %s has no cases", path)
+	}
+	for i := range suite.Cases {
+		if suite.Cases[i].Name == "" {
+			suite.Cases[i].Name = fmt.Sprintf("case-%d", i+1)
+		}
+	}
+	return &suite, nil
+}
+
+// AgentFactory creates a fresh agent for each case.
+type AgentFactory func() *agent.Agent
+
+// CostFunc computes the cost of a single run (e.g. from token usage).
+type CostFunc func(result *agent.RunResult) float64
+
+// CaseResult is the outcome of a single case.
+type CaseResult struct {
+	Name       string        `json:"name"`
+	Output     string        `json:"output"`
+	Passed     bool          `json:"passed"`
+	Iterations int           `json:"iterations"`
+	ToolCalls  int           `json:"tool_calls"`
+	ToolErrors int           `json:"tool_errors"`
+	Cost       float64       `json:"cost"`
+	Duration   time.Duration `json:"duration"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Report aggregates the results of a suite run.
+type Report struct {
+	Suite          string       `json:"suite"`
+	Cases          []CaseResult `json:"cases"`
+	Total          int          `json:"total"`
+	Passed         int          `json:"passed"`
+	Accuracy       float64      `json:"accuracy"`
+	MeanIterations float64      `json:"mean_iterations"`
+	ToolErrorRate  float64      `json:"tool_error_rate"`
+	CostPerCase    float64      `json:"cost_per_case"`
+}
+
+// Option configures a suite run.
+type Option func(*runner)
+
+type runner struct {
+	cost CostFunc
+}
+
+// WithCost sets the function used to compute per-case cost.
+func WithCost(fn CostFunc) Option {
+	return func(r *runner) {
+		r.cost = fn
+	}
+}
+
+// Run executes every case in the suite and returns the aggregated report.
+func Run(ctx context.Context, suite *Suite, factory AgentFactory, opts ...Option) (*Report, error) {
+	r := &runner{}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	report := &Report{Suite: suite.Name}
+	for _, c := range suite.Cases {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		report.Cases = append(report.Cases, r.runCase(ctx, c, factory))
+	}
+	report.summarize()
+	return report, nil
+}
+
+func (r *runner) runCase(ctx context.Context, c Case, factory AgentFactory) CaseResult {
+	cr := CaseResult{Name: c.Name}
+	start := time.Now()
+
+	result, err := factory().Run(ctx, c.Input)
+	cr.Duration = time.Since(start)
+	if err != nil {
+		cr.Error = err.Error()
+	}
+	if result == nil {
+		return cr
+	}
+
+	cr.Output = result.Output
+	cr.Iterations = result.Iterations
+	for _, step := range result.Steps {
+		if step.IsFinal || step.Action.Action == "" {
+			continue
+		}
+		cr.ToolCalls++
+		if step.Error != nil {
+			cr.ToolErrors++
+		}
+	}
+	if r.cost != nil {
+		cr.Cost = r.cost(result)
+	}
+	cr.Passed = err == nil && matches(c, result.Output)
+	return cr
+}
+
+func matches(c Case, output string) bool {
+	lower := strings.ToLower(output)
+	if c.Expected != "" && !strings.Contains(lower, strings.ToLower(c.Expected)) {
+		return false
+	}
+	for _, s := range c.Contains {
+		if !strings.Contains(lower, strings.ToLower(s)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) summarize() {
+	r.Total = len(r.Cases)
+	if r.Total == 0 {
+		return
+	}
+
+	var iterations, toolCalls, toolErrors int
+	var cost float64
+	for _, c := range r.Cases {
+		if c.Passed {
+			r.Passed++
+		}
+		iterations += c.Iterations
+		toolCalls += c.ToolCalls
+		toolErrors += c.ToolErrors
+		cost += c.Cost
+	}
+
+	r.Accuracy = float64(r.Passed) / float64(r.Total)
+	r.MeanIterations = float64(iterations) / float64(r.Total)
+	r.CostPerCase = cost / float64(r.Total)
+	if toolCalls > 0 {
+		r.ToolErrorRate = float64(toolErrors) / float64(toolCalls)
+	}
+}
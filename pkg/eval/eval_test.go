@@ -0,0 +1,143 @@
+// Package eval_test provides tests for agent evaluation and gates.
+package eval_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/eval"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// scriptedLLM answers each case with a fixed number of "think" steps
+// before giving the final answer for the task.
+type scriptedLLM struct {
+	answers    map[string]string
+	extraSteps int
+	mu         sync.Mutex
+	calls      int
+}
+
+func (s *scriptedLLM) Generate(ctx context.Context, prompt string, opts ...core.Option) (string, error) {
+	return s.GenerateChat(ctx, []core.Message{{Role: core.RoleUser, Content: prompt}}, opts...)
+}
+
+func (s *scriptedLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if s.calls <= s.extraSteps {
+		return `{"action": "think", "action_input": {"thought": "checking"}}`, nil
+	}
+
+	task := messages[1].Content
+	return fmt.Sprintf(`{"action": "final_answer", "action_input": %q}`, s.answers[task]), nil
+}
+
+func (s *scriptedLLM) Stream(ctx context.Context, prompt string, opts ...core.Option) (<-chan string, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (s *scriptedLLM) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+var answers = map[string]string{
+	"How do I get a refund?": "Please fill in the refund form.",
+	"When are you open?":     "We are open from 9am to 5pm.",
+}
+
+func factory(extraSteps int) eval.AgentFactory {
+	return func() *agent.Agent {
+		llm := &scriptedLLM{answers: answers, extraSteps: extraSteps}
+		return agent.New(llm, tools.BuiltinTools(), agent.WithMaxIterations(10))
+	}
+}
+
+func TestRun_Report(t *testing.T) {
+	suite, err := eval.LoadSuite("testdata/suite.yaml")
+	if err != nil {
+		t.Fatalf("LoadSuite failed: %v", err)
+	}
+
+	report, err := eval.Run(context.Background(), suite, factory(0),
+		eval.WithCost(func(r *agent.RunResult) float64 { return 0.01 }))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if report.Total != 2 || report.Passed != 2 {
+		t.Errorf("Expected 2/2 passed, got %d/%d", report.Passed, report.Total)
+	}
+	if report.Accuracy != 1 {
+		t.Errorf("Expected accuracy 1, got %v", report.Accuracy)
+	}
+	if report.MeanIterations != 1 {
+		t.Errorf("Expected mean iterations 1, got %v", report.MeanIterations)
+	}
+	if report.CostPerCase != 0.01 {
+		t.Errorf("Expected cost per case 0.01, got %v", report.CostPerCase)
+	}
+}
+
+func TestGate_IterationRegression(t *testing.T) {
+	suite, err := eval.LoadSuite("testdata/suite.yaml")
+	if err != nil {
+		t.Fatalf("LoadSuite failed: %v", err)
+	}
+	gate, err := eval.LoadGate("testdata/gate.yaml")
+	if err != nil {
+		t.Fatalf("LoadGate failed: %v", err)
+	}
+
+	// Baseline passes.
+	baseline, _ := eval.Run(context.Background(), suite, factory(0))
+	if result := gate.Evaluate(baseline); !result.Passed {
+		t.Fatalf("Expected baseline to pass, got %s", result)
+	}
+
+	// Regression: each case now takes three extra think steps.
+	regressed, _ := eval.Run(context.Background(), suite, factory(3))
+	result := gate.Evaluate(regressed)
+	if result.Passed {
+		t.Fatal("Expected gate to fail on iteration regression")
+	}
+	if len(result.Violations) != 1 {
+		t.Fatalf("Expected exactly one violation, got %v", result.Violations)
+	}
+	v := result.Violations[0]
+	if v.Metric != "mean_iterations" || v.Actual != 4 || v.Threshold != 2 {
+		t.Errorf("Unexpected violation: %+v", v)
+	}
+	if regressed.Accuracy != 1 {
+		t.Errorf("Accuracy should still pass, got %v", regressed.Accuracy)
+	}
+	if !strings.Contains(result.String(), "mean_iterations") {
+		t.Errorf("Expected violation in message, got %q", result.String())
+	}
+}
+
+func TestGate_Accuracy(t *testing.T) {
+	gate := eval.Gate{MinAccuracy: eval.Threshold(0.9)}
+	result := gate.Evaluate(&eval.Report{Accuracy: 0.5})
+	if result.Passed || result.Violations[0].Metric != "accuracy" {
+		t.Errorf("Expected accuracy violation, got %s", result)
+	}
+}
+
+func TestRunGate(t *testing.T) {
+	gate := eval.Gate{
+		MinAccuracy:       eval.Threshold(1),
+		MaxMeanIterations: eval.Threshold(1),
+	}
+	report := eval.RunGate(t, "testdata/suite.yaml", factory(0), gate)
+	if report.Passed != 2 {
+		t.Errorf("Expected 2 passed, got %d", report.Passed)
+	}
+}
@@ -0,0 +1,106 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Gate declares thresholds a Report must satisfy. Nil thresholds are not checked.
+type Gate struct {
+	MinAccuracy       *float64 `yaml:"min_accuracy" json:"min_accuracy,omitempty"`
+	MaxMeanIterations *float64 `yaml:"max_mean_iterations" json:"max_mean_iterations,omitempty"`
+	MaxToolErrorRate  *float64 `yaml:"max_tool_error_rate" json:"max_tool_error_rate,omitempty"`
+	MaxCostPerCase    *float64 `yaml:"max_cost_per_case" json:"max_cost_per_case,omitempty"`
+}
+
+// Threshold returns a pointer to v, for building gates in code.
+func Threshold(v float64) *float64 {
+	return &v
+}
+
+// LoadGate reads a gate from a YAML or JSON file.
+func LoadGate(path string) (*Gate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("eval: read gate: %w", err)
+	}
+	var gate Gate
+	if err := yaml.Unmarshal(data, &gate); err != nil {
+		return nil, fmt.Errorf("eval: parse gate %s: %w", path, err)
+	}
+	return &gate, nil
+}
+
+// Violation describes a threshold the report failed to meet.
+type Violation struct {
+	Metric    string  `json:"metric"`
+	Threshold float64 `json:"threshold"`
+	Actual    float64 `json:"actual"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %.4g (threshold %.4g)", v.Metric, v.Actual, v.Threshold)
+}
+
+// GateResult is the outcome of checking a report against a gate.
+type GateResult struct {
+	Passed     bool        `json:"passed"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+func (r GateResult) String() string {
+	if r.Passed {
+		return "gate passed"
+	}
+	parts := make([]string, len(r.Violations))
+	for i, v := range r.Violations {
+		parts[i] = v.String()
+	}
+	return "gate failed: " + strings.Join(parts, "; ")
+}
+
+// Evaluate checks the report against every configured threshold.
+func (g Gate) Evaluate(report *Report) GateResult {
+	var violations []Violation
+
+	if g.MinAccuracy != nil && report.Accuracy < *g.MinAccuracy {
+		violations = append(violations, Violation{"accuracy", *g.MinAccuracy, report.Accuracy})
+	}
+	if g.MaxMeanIterations != nil && report.MeanIterations > *g.MaxMeanIterations {
+		violations = append(violations, Violation{"mean_iterations", *g.MaxMeanIterations, report.MeanIterations})
+	}
+	if g.MaxToolErrorRate != nil && report.ToolErrorRate > *g.MaxToolErrorRate {
+		violations = append(violations, Violation{"tool_error_rate", *g.MaxToolErrorRate, report.ToolErrorRate})
+	}
+	if g.MaxCostPerCase != nil && report.CostPerCase > *g.MaxCostPerCase {
+		violations = append(violations, Violation{"cost_per_case", *g.MaxCostPerCase, report.CostPerCase})
+	}
+
+	return GateResult{Passed: len(violations) == 0, Violations: violations}
+}
+
+// RunGate loads a suite, runs it and fails the test if the gate is violated.
+// It is intended for use in Go tests guarding agent quality in CI.
+func RunGate(t testing.TB, suitePath string, factory AgentFactory, gate Gate, opts ...Option) *Report {
+	t.Helper()
+
+	suite, err := LoadSuite(suitePath)
+	if err != nil {
+		t.Fatalf("eval: %v", err)
+	}
+
+	report, err := Run(context.Background(), suite, factory, opts...)
+	if err != nil {
+		t.Fatalf("eval: run suite: %v", err)
+	}
+
+	if result := gate.Evaluate(report); !result.Passed {
+		t.Errorf("eval: suite %s: %s", suite.Name, result)
+	}
+	return report
+}
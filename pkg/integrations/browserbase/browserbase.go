@@ -10,15 +10,23 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/nuulab/goflow/pkg/egress"
+	"github.com/nuulab/goflow/pkg/integrations/sessions"
 )
 
 const baseURL = "https://www.browserbase.com/v1"
 
+// ProviderName identifies Browserbase sessions in a sessions.Tracker.
+const ProviderName = "browserbase"
+
 // Client provides access to Browserbase browser automation.
 type Client struct {
 	apiKey     string
 	projectID  string
+	baseURL    string
 	httpClient *http.Client
+	tracker    *sessions.Tracker
 }
 
 // New creates a new Browserbase client.
@@ -26,12 +34,35 @@ func New(apiKey, projectID string) *Client {
 	return &Client{
 		apiKey:    apiKey,
 		projectID: projectID,
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
+		baseURL:   baseURL,
+		httpClient: egress.NewClient(120 * time.Second),
 	}
 }
 
+// WithBaseURL sets the Browserbase API endpoint.
+func (c *Client) WithBaseURL(url string) *Client {
+	c.baseURL = url
+	return c
+}
+
+// WithTracker records browser sessions in t, which enforces tenant quotas
+// when sessions are created and reaps sessions left behind by crashed or
+// finished runs. Sessions belong to the sessions.Owner in the creating
+// context.
+func (c *Client) WithTracker(t *sessions.Tracker) *Client {
+	c.tracker = t
+	t.Register(provider{c})
+	return c
+}
+
+// WithHTTPClient replaces the HTTP client. The default client follows the
+// egress policy; a custom client bypasses it unless its transport wraps
+// egress.Transport.
+func (c *Client) WithHTTPClient(hc *http.Client) *Client {
+	c.httpClient = hc
+	return c
+}
+
 // Session represents a browser session.
 type Session struct {
 	client    *Client
@@ -40,6 +71,7 @@ type Session struct {
 	ProjectID string `json:"projectId"`
 	CreatedAt string `json:"createdAt"`
 	ConnectURL string `json:"connectUrl,omitempty"`
+	Metadata  map[string]string `json:"userMetadata,omitempty"`
 }
 
 // CreateSessionOptions configures session creation.
@@ -52,6 +84,8 @@ type CreateSessionOptions struct {
 	Timeout int
 	// KeepAlive keep session alive after disconnect
 	KeepAlive bool
+	// Metadata is attached to the session as user metadata
+	Metadata map[string]string
 }
 
 // Fingerprint controls browser fingerprinting.
@@ -70,12 +104,43 @@ type Proxy struct {
 	Password string `json:"password,omitempty"`
 }
 
-// CreateSession creates a new browser session.
+// CreateSession creates a new browser session. With a tracker it fails
+// with sessions.ErrQuotaExceeded when the owner's tenant is at its limit.
 func (c *Client) CreateSession(ctx context.Context, opts *CreateSessionOptions) (*Session, error) {
 	body := map[string]any{
 		"projectId": c.projectID,
 	}
-	
+
+	var metadata map[string]string
+	if opts != nil {
+		metadata = opts.Metadata
+	}
+	var reservation *sessions.Reservation
+	if c.tracker != nil {
+		var ttl time.Duration
+		if opts != nil {
+			ttl = time.Duration(opts.Timeout) * time.Second
+		}
+		var err error
+		reservation, err = c.tracker.Reserve(ProviderName, sessions.OwnerFromContext(ctx), ttl)
+		if err != nil {
+			return nil, err
+		}
+		defer reservation.Cancel()
+
+		tagged := make(map[string]string, len(metadata)+3)
+		for k, v := range metadata {
+			tagged[k] = v
+		}
+		for k, v := range reservation.Metadata() {
+			tagged[k] = v
+		}
+		metadata = tagged
+	}
+	if metadata != nil {
+		body["userMetadata"] = metadata
+	}
+
 	if opts != nil {
 		if opts.Fingerprint != nil {
 			body["fingerprint"] = opts.Fingerprint
@@ -101,6 +166,9 @@ func (c *Client) CreateSession(ctx context.Context, opts *CreateSessionOptions)
 		return nil, err
 	}
 	session.client = c
+	if reservation != nil {
+		reservation.Commit(session.ID)
+	}
 	return &session, nil
 }
 
@@ -158,8 +226,12 @@ type ActionResult struct {
 	Error      string `json:"error,omitempty"`
 }
 
-// Navigate navigates to a URL.
+// Navigate navigates to a URL. The URL is checked against the egress
+// policy since the remote browser fetches it on the agent's behalf.
 func (s *Session) Navigate(ctx context.Context, url string) (*ActionResult, error) {
+	if err := egress.Check(url); err != nil {
+		return nil, err
+	}
 	return s.Execute(ctx, Action{Type: "navigate", Value: url})
 }
 
@@ -231,6 +303,9 @@ func (s *Session) Execute(ctx context.Context, action Action) (*ActionResult, er
 // Close closes the browser session.
 func (s *Session) Close(ctx context.Context) error {
 	_, err := s.client.delete(ctx, "/sessions/"+s.ID)
+	if err == nil && s.client.tracker != nil {
+		s.client.tracker.Forget(ProviderName, s.ID)
+	}
 	return err
 }
 
@@ -249,10 +324,42 @@ func (s *Session) GetDebugURL(ctx context.Context) (string, error) {
 	return result.DebuggerURL, nil
 }
 
+// ============ Session Tracking ============
+
+// provider exposes browser sessions to a sessions.Tracker.
+type provider struct {
+	client *Client
+}
+
+func (p provider) Name() string { return ProviderName }
+
+// List returns running sessions; ListSessions also includes completed ones.
+func (p provider) List(ctx context.Context) ([]sessions.Session, error) {
+	all, err := p.client.ListSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []sessions.Session
+	for _, bs := range all {
+		if bs.Status != "" && bs.Status != "RUNNING" {
+			continue
+		}
+		if s, ok := sessions.FromMetadata(bs.ID, bs.Metadata); ok {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (p provider) Kill(ctx context.Context, id string) error {
+	_, err := p.client.delete(ctx, "/sessions/"+id)
+	return err
+}
+
 // ============ HTTP Helpers ============
 
 func (c *Client) get(ctx context.Context, path string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+path, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -264,7 +371,7 @@ func (c *Client) post(ctx context.Context, path string, body any) ([]byte, error
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+path, bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
@@ -273,7 +380,7 @@ func (c *Client) post(ctx context.Context, path string, body any) ([]byte, error
 }
 
 func (c *Client) delete(ctx context.Context, path string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "DELETE", baseURL+path, nil)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.baseURL+path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -315,6 +422,12 @@ func NewTool(apiKey, projectID string) *Tool {
 	}
 }
 
+// WithTracker records the tool's browser sessions in tr (see Client.WithTracker).
+func (t *Tool) WithTracker(tr *sessions.Tracker) *Tool {
+	t.client.WithTracker(tr)
+	return t
+}
+
 // Name returns the tool name.
 func (t *Tool) Name() string { return "browserbase" }
 
@@ -0,0 +1,319 @@
+package browserbase
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// DefaultIdleTimeout is how long a pooled session may go unused before it
+// is closed.
+const DefaultIdleTimeout = 5 * time.Minute
+
+// SessionPool keeps browser sessions open across tool calls, so an agent
+// can navigate, then click, then extract on the same page. Sessions are
+// closed after going unused for the idle timeout, or by Close.
+type SessionPool struct {
+	client *Client
+	idle   time.Duration
+	opts   *CreateSessionOptions
+
+	mu       sync.Mutex
+	sessions map[string]*pooledSession
+}
+
+type pooledSession struct {
+	session  *Session
+	lastUsed time.Time
+	busy     int // actions in flight; a busy session never expires
+	timer    *time.Timer
+}
+
+// NewSessionPool creates a pool of sessions created by client with opts,
+// which may be nil. idleTimeout <= 0 uses DefaultIdleTimeout.
+func NewSessionPool(client *Client, idleTimeout time.Duration, opts *CreateSessionOptions) *SessionPool {
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	return &SessionPool{
+		client:   client,
+		idle:     idleTimeout,
+		opts:     opts,
+		sessions: make(map[string]*pooledSession),
+	}
+}
+
+// Create starts a session and adds it to the pool.
+func (p *SessionPool) Create(ctx context.Context) (*Session, error) {
+	session, err := p.client.CreateSession(ctx, p.opts)
+	if err != nil {
+		return nil, err
+	}
+	ps := &pooledSession{session: session, lastUsed: time.Now()}
+	p.mu.Lock()
+	p.sessions[session.ID] = ps
+	ps.timer = time.AfterFunc(p.idle, func() { p.expire(session.ID) })
+	p.mu.Unlock()
+	return session, nil
+}
+
+// Use runs fn with the pooled session id. The session does not expire
+// while fn runs, and its idle timer restarts when fn returns.
+func (p *SessionPool) Use(id string, fn func(*Session) error) error {
+	p.mu.Lock()
+	ps, ok := p.sessions[id]
+	if !ok {
+		p.mu.Unlock()
+		return fmt.Errorf("browser session %q is unknown or expired; start a new one with browser_navigate", id)
+	}
+	ps.busy++
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		ps.busy--
+		ps.lastUsed = time.Now()
+		ps.timer.Reset(p.idle)
+		p.mu.Unlock()
+	}()
+	return fn(ps.session)
+}
+
+// Len returns the number of open sessions.
+func (p *SessionPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.sessions)
+}
+
+// Release closes the session id and removes it from the pool.
+func (p *SessionPool) Release(ctx context.Context, id string) error {
+	p.mu.Lock()
+	ps, ok := p.sessions[id]
+	if ok {
+		ps.timer.Stop()
+		delete(p.sessions, id)
+	}
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return ps.session.Close(ctx)
+}
+
+// Close closes every session in the pool. Call it when the agent is done
+// or reset, e.g. from an agent OnReset hook.
+func (p *SessionPool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	open := p.sessions
+	p.sessions = make(map[string]*pooledSession)
+	p.mu.Unlock()
+
+	var errs []error
+	for _, ps := range open {
+		ps.timer.Stop()
+		if err := ps.session.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("close session %s: %w", ps.session.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expire closes the session id if it has been idle for the timeout.
+func (p *SessionPool) expire(id string) {
+	p.mu.Lock()
+	ps, ok := p.sessions[id]
+	// The timer may fire just as the session is used again; the next
+	// timer takes over then.
+	if !ok || ps.busy > 0 || time.Since(ps.lastUsed) < p.idle {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.sessions, id)
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ps.session.Close(ctx)
+}
+
+// ============ Toolkit ============
+
+// Toolkit returns browser tools that share the pool's sessions:
+// browser_navigate opens a session when no session_id is given and returns
+// its ID, which the other tools take to act on the same page.
+func (p *SessionPool) Toolkit() *tools.Toolkit {
+	return &tools.Toolkit{
+		Name:        "browser",
+		Description: "Tools for driving a web browser across several steps",
+		Tools: []*tools.Tool{
+			p.navigateTool(),
+			p.clickTool(),
+			p.typeTool(),
+			p.extractTool(),
+			p.screenshotTool(),
+		},
+	}
+}
+
+// browserInput holds the parameters of the browser tools.
+type browserInput struct {
+	SessionID string `json:"session_id"`
+	URL       string `json:"url"`
+	Selector  string `json:"selector"`
+	Text      string `json:"text"`
+	Format    string `json:"format"`
+}
+
+// run decodes input and runs fn on the session it names.
+func (p *SessionPool) run(input string, fn func(*Session, browserInput) (map[string]any, error)) (string, error) {
+	var in browserInput
+	if err := json.Unmarshal([]byte(input), &in); err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+	var out map[string]any
+	err := p.Use(in.SessionID, func(s *Session) error {
+		var err error
+		out, err = fn(s, in)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	out["session_id"] = in.SessionID
+	data, err := json.Marshal(out)
+	return string(data), err
+}
+
+// actionError reports a browser action that ran but failed.
+func actionError(action string, result *ActionResult) error {
+	if result.Error != "" {
+		return fmt.Errorf("%s failed: %s", action, result.Error)
+	}
+	return nil
+}
+
+func (p *SessionPool) navigateTool() *tools.Tool {
+	return tools.Build("browser_navigate").
+		Description("Open a URL in the browser. Without session_id a new browser session is started; pass the returned session_id to the other browser tools").
+		Param("url", "string", "The URL to open").
+		OptionalParam("session_id", "string", "Session to navigate; omit to start a new one").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var in browserInput
+			if err := json.Unmarshal([]byte(input), &in); err != nil {
+				return "", fmt.Errorf("invalid input: %w", err)
+			}
+			if in.SessionID == "" {
+				session, err := p.Create(ctx)
+				if err != nil {
+					return "", err
+				}
+				data, _ := json.Marshal(browserInput{SessionID: session.ID, URL: in.URL})
+				input = string(data)
+			}
+			return p.run(input, func(s *Session, in browserInput) (map[string]any, error) {
+				result, err := s.Navigate(ctx, in.URL)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{"url": in.URL}, actionError("navigate", result)
+			})
+		}).
+		Create()
+}
+
+func (p *SessionPool) clickTool() *tools.Tool {
+	return tools.Build("browser_click").
+		Description("Click an element on the current page").
+		Param("session_id", "string", "Session from browser_navigate").
+		Param("selector", "string", "CSS selector of the element").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			return p.run(input, func(s *Session, in browserInput) (map[string]any, error) {
+				result, err := s.Click(ctx, in.Selector)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{"clicked": in.Selector}, actionError("click", result)
+			})
+		}).
+		Create()
+}
+
+func (p *SessionPool) typeTool() *tools.Tool {
+	return tools.Build("browser_type").
+		Description("Type text into an input on the current page").
+		Param("session_id", "string", "Session from browser_navigate").
+		Param("selector", "string", "CSS selector of the input").
+		Param("text", "string", "The text to type").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			return p.run(input, func(s *Session, in browserInput) (map[string]any, error) {
+				result, err := s.Type(ctx, in.Selector, in.Text)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{"typed": in.Selector}, actionError("type", result)
+			})
+		}).
+		Create()
+}
+
+func (p *SessionPool) extractTool() *tools.Tool {
+	return tools.Build("browser_extract").
+		Description("Extract the text or HTML of the current page or of an element").
+		Param("session_id", "string", "Session from browser_navigate").
+		OptionalParam("selector", "string", "CSS selector of the element (default body)").
+		OptionalEnumParam("format", "Whether to extract text or HTML (default text)", "text", "html").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			return p.run(input, func(s *Session, in browserInput) (map[string]any, error) {
+				selector := in.Selector
+				if selector == "" {
+					selector = "body"
+				}
+				extract := s.ExtractText
+				if in.Format == "html" {
+					extract = s.ExtractHTML
+				}
+				content, err := extract(ctx, selector)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{"content": content}, nil
+			})
+		}).
+		Create()
+}
+
+func (p *SessionPool) screenshotTool() *tools.Tool {
+	return tools.Build("browser_screenshot").
+		Description("Take a screenshot of the current page, returned as base64 image data").
+		Param("session_id", "string", "Session from browser_navigate").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			// With a single parameter the builder passes the session ID
+			// itself rather than JSON.
+			in := browserInput{SessionID: strings.TrimSpace(input)}
+			if json.Unmarshal([]byte(input), &in) != nil {
+				data, _ := json.Marshal(in)
+				input = string(data)
+			}
+			return p.run(input, func(s *Session, in browserInput) (map[string]any, error) {
+				shot, err := s.Screenshot(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{
+					"image":     base64.StdEncoding.EncodeToString(shot),
+					"mime_type": http.DetectContentType(shot),
+					"bytes":     len(shot),
+				}, nil
+			})
+		}).
+		Create()
+}
@@ -0,0 +1,169 @@
+package browserbase_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/integrations/browserbase"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// pngHeader stands in for a screenshot.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+// fakeBrowserbase serves sessions whose pages remember the last URL.
+type fakeBrowserbase struct {
+	mu      sync.Mutex
+	created int
+	pages   map[string]string // session ID to URL
+	closed  []string
+}
+
+func (f *fakeBrowserbase) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/sessions")
+	switch {
+	case r.Method == "POST" && path == "":
+		f.created++
+		id := fmt.Sprintf("s%d", f.created)
+		f.pages[id] = "about:blank"
+		fmt.Fprintf(w, `{"id": %q, "status": "RUNNING"}`, id)
+	case r.Method == "DELETE":
+		f.closed = append(f.closed, strings.TrimPrefix(path, "/"))
+		w.Write([]byte(`{}`))
+	case r.Method == "POST" && strings.HasSuffix(path, "/actions"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/actions")
+		var action browserbase.Action
+		json.NewDecoder(r.Body).Decode(&action)
+		switch action.Type {
+		case "navigate":
+			f.pages[id] = action.Value
+			w.Write([]byte(`{"success": true}`))
+		case "click":
+			if action.Selector == "#missing" {
+				w.Write([]byte(`{"success": false, "error": "no element matches #missing"}`))
+				return
+			}
+			w.Write([]byte(`{"success": true}`))
+		case "extract":
+			fmt.Fprintf(w, `{"success": true, "data": "page %s"}`, f.pages[id])
+		case "screenshot":
+			json.NewEncoder(w).Encode(map[string]any{"success": true, "screenshot": pngHeader})
+		default:
+			w.Write([]byte(`{"success": true}`))
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeBrowserbase) closedSessions() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.closed...)
+}
+
+func newPool(t *testing.T, idle time.Duration) (*browserbase.SessionPool, *fakeBrowserbase, *tools.Registry) {
+	t.Helper()
+	fake := &fakeBrowserbase{pages: map[string]string{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	client := browserbase.New("key", "project").WithBaseURL(srv.URL).WithHTTPClient(srv.Client())
+	pool := browserbase.NewSessionPool(client, idle, nil)
+	registry := tools.NewRegistry()
+	if err := pool.Toolkit().RegisterTo(registry); err != nil {
+		t.Fatal(err)
+	}
+	return pool, fake, registry
+}
+
+func TestSessionPool_Toolkit(t *testing.T) {
+	pool, fake, registry := newPool(t, time.Minute)
+	ctx := context.Background()
+
+	out, err := registry.Execute(ctx, "browser_navigate", `{"url": "https://example.com"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nav struct {
+		SessionID string `json:"session_id"`
+	}
+	json.Unmarshal([]byte(out), &nav)
+	if nav.SessionID != "s1" {
+		t.Fatalf("Expected a new session, got %s", out)
+	}
+
+	// Later steps act on the same page.
+	steps := []struct {
+		tool, input, want string
+	}{
+		{"browser_type", `{"session_id": "s1", "selector": "#q", "text": "goflow"}`, `"typed":"#q"`},
+		{"browser_click", `{"session_id": "s1", "selector": "#go"}`, `"clicked":"#go"`},
+		{"browser_extract", `{"session_id": "s1"}`, `"content":"page https://example.com"`},
+		{"browser_screenshot", `{"session_id": "s1"}`, `"image":"` + base64.StdEncoding.EncodeToString(pngHeader) + `","mime_type":"image/png"`},
+	}
+	for _, step := range steps {
+		out, err := registry.Execute(ctx, step.tool, step.input)
+		if err != nil || !strings.Contains(out, step.want) {
+			t.Errorf("%s: got %s (%v), want %s", step.tool, out, err, step.want)
+		}
+	}
+	if fake.created != 1 || pool.Len() != 1 {
+		t.Errorf("Expected one session, created %d, pooled %d", fake.created, pool.Len())
+	}
+
+	if _, err := registry.Execute(ctx, "browser_click", `{"session_id": "s1", "selector": "#missing"}`); err == nil || !strings.Contains(err.Error(), "no element matches") {
+		t.Errorf("Expected the action error, got %v", err)
+	}
+	if _, err := registry.Execute(ctx, "browser_extract", `{"session_id": "s9"}`); err == nil || !strings.Contains(err.Error(), "unknown or expired") {
+		t.Errorf("Expected an unknown session error, got %v", err)
+	}
+
+	if err := pool.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if closed := fake.closedSessions(); pool.Len() != 0 || len(closed) != 1 || closed[0] != "s1" {
+		t.Errorf("Expected s1 closed, got %v", closed)
+	}
+}
+
+func TestSessionPool_IdleTimeout(t *testing.T) {
+	pool, fake, _ := newPool(t, 50*time.Millisecond)
+	ctx := context.Background()
+	session, err := pool.Create(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A session in use does not expire.
+	err = pool.Use(session.ID, func(*browserbase.Session) error {
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+	if err != nil || pool.Len() != 1 {
+		t.Fatalf("Expected the session to survive a long action, got %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for pool.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for len(fake.closedSessions()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pool.Len() != 0 || len(fake.closedSessions()) != 1 {
+		t.Errorf("Expected the idle session closed, pooled %d, closed %v", pool.Len(), fake.closedSessions())
+	}
+	if err := pool.Use(session.ID, func(*browserbase.Session) error { return nil }); err == nil {
+		t.Error("Expected an expired session error")
+	}
+}
@@ -10,32 +10,62 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/nuulab/goflow/pkg/egress"
+	"github.com/nuulab/goflow/pkg/integrations/sessions"
 )
 
 const baseURL = "https://api.e2b.dev"
 
+// ProviderName identifies E2B sandboxes in a sessions.Tracker.
+const ProviderName = "e2b"
+
 // Client provides access to E2B sandboxes.
 type Client struct {
 	apiKey     string
+	baseURL    string
 	httpClient *http.Client
+	tracker    *sessions.Tracker
 }
 
 // New creates a new E2B client.
 func New(apiKey string) *Client {
 	return &Client{
 		apiKey: apiKey,
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
+		baseURL: baseURL,
+		httpClient: egress.NewClient(120 * time.Second),
 	}
 }
 
+// WithBaseURL sets the E2B API endpoint.
+func (c *Client) WithBaseURL(url string) *Client {
+	c.baseURL = url
+	return c
+}
+
+// WithTracker records sandboxes in t, enforcing its tenant quotas at
+// creation, and registers the client with t's janitor. Sandboxes are
+// attributed to the sessions.Owner in the creating context.
+func (c *Client) WithTracker(t *sessions.Tracker) *Client {
+	c.tracker = t
+	t.Register(provider{c})
+	return c
+}
+
+// WithHTTPClient sets the client used for E2B API calls. Clients not built
+// with egress.NewClient or egress.Transport skip the egress policy.
+func (c *Client) WithHTTPClient(hc *http.Client) *Client {
+	c.httpClient = hc
+	return c
+}
+
 // Sandbox represents an E2B sandbox instance.
 type Sandbox struct {
 	client    *Client
 	ID        string `json:"sandboxId"`
 	Template  string `json:"template"`
 	ClientID  string `json:"clientId"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
 }
 
 // CreateSandboxOptions configures sandbox creation.
@@ -45,21 +75,41 @@ type CreateSandboxOptions struct {
 	Metadata map[string]string // Custom metadata
 }
 
-// CreateSandbox creates a new sandbox.
+// CreateSandbox creates a new sandbox. With a tracker it fails with
+// sessions.ErrQuotaExceeded when the owner's tenant is at its limit.
 func (c *Client) CreateSandbox(ctx context.Context, opts CreateSandboxOptions) (*Sandbox, error) {
 	template := opts.Template
 	if template == "" {
 		template = "base"
 	}
 
+	metadata := opts.Metadata
+	var reservation *sessions.Reservation
+	if c.tracker != nil {
+		var err error
+		reservation, err = c.tracker.Reserve(ProviderName, sessions.OwnerFromContext(ctx), opts.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		defer reservation.Cancel()
+
+		metadata = make(map[string]string, len(opts.Metadata)+3)
+		for k, v := range opts.Metadata {
+			metadata[k] = v
+		}
+		for k, v := range reservation.Metadata() {
+			metadata[k] = v
+		}
+	}
+
 	body := map[string]any{
 		"template": template,
 	}
 	if opts.Timeout > 0 {
 		body["timeout"] = int(opts.Timeout.Seconds())
 	}
-	if opts.Metadata != nil {
-		body["metadata"] = opts.Metadata
+	if metadata != nil {
+		body["metadata"] = metadata
 	}
 
 	resp, err := c.post(ctx, "/sandboxes", body)
@@ -72,9 +122,29 @@ func (c *Client) CreateSandbox(ctx context.Context, opts CreateSandboxOptions) (
 		return nil, err
 	}
 	sandbox.client = c
+	if reservation != nil {
+		reservation.Commit(sandbox.ID)
+	}
 	return &sandbox, nil
 }
 
+// ListSandboxes lists the running sandboxes.
+func (c *Client) ListSandboxes(ctx context.Context) ([]Sandbox, error) {
+	resp, err := c.get(ctx, "/sandboxes")
+	if err != nil {
+		return nil, err
+	}
+
+	var sandboxes []Sandbox
+	if err := json.Unmarshal(resp, &sandboxes); err != nil {
+		return nil, err
+	}
+	for i := range sandboxes {
+		sandboxes[i].client = c
+	}
+	return sandboxes, nil
+}
+
 // GetSandbox retrieves an existing sandbox.
 func (c *Client) GetSandbox(ctx context.Context, sandboxID string) (*Sandbox, error) {
 	resp, err := c.get(ctx, "/sandboxes/"+sandboxID)
@@ -200,13 +270,44 @@ func (s *Sandbox) InstallPackage(ctx context.Context, packageManager, packageNam
 // Kill terminates the sandbox.
 func (s *Sandbox) Kill(ctx context.Context) error {
 	_, err := s.client.delete(ctx, "/sandboxes/"+s.ID)
+	if err == nil && s.client.tracker != nil {
+		s.client.tracker.Forget(ProviderName, s.ID)
+	}
+	return err
+}
+
+// ============ Session Tracking ============
+
+// provider exposes sandboxes to a sessions.Tracker.
+type provider struct {
+	client *Client
+}
+
+func (p provider) Name() string { return ProviderName }
+
+func (p provider) List(ctx context.Context) ([]sessions.Session, error) {
+	sandboxes, err := p.client.ListSandboxes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []sessions.Session
+	for _, sb := range sandboxes {
+		if s, ok := sessions.FromMetadata(sb.ID, sb.Metadata); ok {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (p provider) Kill(ctx context.Context, id string) error {
+	_, err := p.client.delete(ctx, "/sandboxes/"+id)
 	return err
 }
 
 // ============ HTTP Helpers ============
 
 func (c *Client) get(ctx context.Context, path string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+path, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -218,7 +319,7 @@ func (c *Client) post(ctx context.Context, path string, body any) ([]byte, error
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequestWithContext(ctx, "POST", baseURL+path, bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
@@ -227,7 +328,7 @@ func (c *Client) post(ctx context.Context, path string, body any) ([]byte, error
 }
 
 func (c *Client) delete(ctx context.Context, path string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "DELETE", baseURL+path, nil)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.baseURL+path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -271,6 +372,12 @@ func NewTool(apiKey, template string) *Tool {
 	}
 }
 
+// WithTracker records the tool's sandboxes in tr (see Client.WithTracker).
+func (t *Tool) WithTracker(tr *sessions.Tracker) *Tool {
+	t.client.WithTracker(tr)
+	return t
+}
+
 // Name returns the tool name.
 func (t *Tool) Name() string { return "e2b_sandbox" }
 
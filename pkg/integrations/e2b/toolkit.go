@@ -0,0 +1,277 @@
+package e2b
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// DefaultIdleTTL is how long a toolkit's sandbox may go unused before it
+// is killed.
+const DefaultIdleTTL = 10 * time.Minute
+
+// SandboxToolkit is a set of tools sharing one sandbox, so packages
+// installed and files written in one step are there in the next. The
+// sandbox is created on the first call and killed by Close or after going
+// unused for the idle TTL; a later call then starts a fresh one.
+type SandboxToolkit struct {
+	*tools.Toolkit
+	client   *Client
+	template string
+	idle     time.Duration
+
+	mu       sync.Mutex
+	sandbox  *Sandbox
+	busy     int // calls in flight; a busy sandbox never expires
+	lastUsed time.Time
+	timer    *time.Timer
+}
+
+// Toolkit returns run_code, write_file, read_file, install_package and
+// list_files tools that share a sandbox created from template.
+func Toolkit(apiKey, template string) *SandboxToolkit {
+	return New(apiKey).Toolkit(template)
+}
+
+// Toolkit returns a SandboxToolkit whose sandbox is created by c.
+func (c *Client) Toolkit(template string) *SandboxToolkit {
+	tk := &SandboxToolkit{client: c, template: template, idle: DefaultIdleTTL}
+	tk.Toolkit = &tools.Toolkit{
+		Name:        "e2b",
+		Description: "Tools for running code and managing files in a persistent cloud sandbox",
+		Tools: []*tools.Tool{
+			tk.runCodeTool(),
+			tk.writeFileTool(),
+			tk.readFileTool(),
+			tk.installPackageTool(),
+			tk.listFilesTool(),
+		},
+	}
+	return tk
+}
+
+// WithIdleTTL sets how long the sandbox may go unused before it is killed.
+func (tk *SandboxToolkit) WithIdleTTL(ttl time.Duration) *SandboxToolkit {
+	tk.idle = ttl
+	return tk
+}
+
+// SandboxID returns the ID of the running sandbox, or "" before the first
+// call and after Close.
+func (tk *SandboxToolkit) SandboxID() string {
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	if tk.sandbox == nil {
+		return ""
+	}
+	return tk.sandbox.ID
+}
+
+// Close kills the sandbox. Call it when the agent run ends.
+func (tk *SandboxToolkit) Close(ctx context.Context) error {
+	tk.mu.Lock()
+	sandbox := tk.sandbox
+	tk.sandbox = nil
+	if tk.timer != nil {
+		tk.timer.Stop()
+	}
+	tk.mu.Unlock()
+	if sandbox == nil {
+		return nil
+	}
+	return sandbox.Kill(ctx)
+}
+
+// use runs fn with the sandbox, creating it first if needed.
+func (tk *SandboxToolkit) use(ctx context.Context, fn func(*Sandbox) (any, error)) (string, error) {
+	tk.mu.Lock()
+	if tk.sandbox == nil {
+		sandbox, err := tk.client.CreateSandbox(ctx, CreateSandboxOptions{Template: tk.template})
+		if err != nil {
+			tk.mu.Unlock()
+			return "", err
+		}
+		tk.sandbox = sandbox
+		tk.timer = time.AfterFunc(tk.idle, func() { tk.expire(sandbox) })
+	}
+	sandbox := tk.sandbox
+	tk.busy++
+	tk.mu.Unlock()
+
+	defer func() {
+		tk.mu.Lock()
+		tk.busy--
+		tk.lastUsed = time.Now()
+		if tk.sandbox == sandbox {
+			tk.timer.Reset(tk.idle)
+		}
+		tk.mu.Unlock()
+	}()
+	out, err := fn(sandbox)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(out)
+	return string(data), err
+}
+
+// expire kills sandbox if it is still current and has been idle for the TTL.
+func (tk *SandboxToolkit) expire(sandbox *Sandbox) {
+	tk.mu.Lock()
+	if tk.sandbox != sandbox || tk.busy > 0 || time.Since(tk.lastUsed) < tk.idle {
+		tk.mu.Unlock()
+		return
+	}
+	tk.sandbox = nil
+	tk.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	sandbox.Kill(ctx)
+}
+
+// runOutput is the JSON result of run_code and install_package.
+type runOutput struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+func newRunOutput(r *ExecutionResult) runOutput {
+	return runOutput{Stdout: r.Stdout, Stderr: r.Stderr, ExitCode: r.ExitCode, Error: r.Error}
+}
+
+func (tk *SandboxToolkit) runCodeTool() *tools.Tool {
+	return tools.Build("run_code").
+		Description("Run code in the sandbox. Files and installed packages persist between calls. Returns stdout, stderr and the exit code").
+		Param("code", "string", "The code to run").
+		OptionalEnumParam("language", "Language of the code (default python)", "python", "javascript", "bash").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Code     string `json:"code"`
+				Language string `json:"language"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", fmt.Errorf("invalid input: %w", err)
+			}
+			if params.Language == "" {
+				params.Language = "python"
+			}
+			return tk.use(ctx, func(s *Sandbox) (any, error) {
+				result, err := s.RunCode(ctx, params.Code, params.Language)
+				if err != nil {
+					return nil, err
+				}
+				return newRunOutput(result), nil
+			})
+		}).
+		Create()
+}
+
+func (tk *SandboxToolkit) writeFileTool() *tools.Tool {
+	return tools.Build("write_file").
+		Description("Write a file in the sandbox, replacing any existing file").
+		Param("path", "string", "Path of the file").
+		Param("content", "string", "The file contents").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Path    string `json:"path"`
+				Content string `json:"content"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", fmt.Errorf("invalid input: %w", err)
+			}
+			return tk.use(ctx, func(s *Sandbox) (any, error) {
+				if err := s.WriteFile(ctx, params.Path, []byte(params.Content)); err != nil {
+					return nil, err
+				}
+				return map[string]any{"path": params.Path, "bytes": len(params.Content)}, nil
+			})
+		}).
+		Create()
+}
+
+// pathParam reads the path of a single-parameter tool: the builder passes
+// the path itself, or the raw JSON when it is missing.
+func pathParam(input, def string) string {
+	var params struct {
+		Path string `json:"path"`
+	}
+	path := input
+	if json.Unmarshal([]byte(input), &params) == nil {
+		path = params.Path
+	}
+	if path == "" {
+		return def
+	}
+	return path
+}
+
+func (tk *SandboxToolkit) readFileTool() *tools.Tool {
+	return tools.Build("read_file").
+		Description("Read a file from the sandbox").
+		Param("path", "string", "Path of the file").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			path := pathParam(input, "")
+			return tk.use(ctx, func(s *Sandbox) (any, error) {
+				content, err := s.ReadFile(ctx, path)
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{"path": path, "content": string(content)}, nil
+			})
+		}).
+		Create()
+}
+
+func (tk *SandboxToolkit) installPackageTool() *tools.Tool {
+	return tools.Build("install_package").
+		Description("Install a package in the sandbox for later run_code calls").
+		Param("package", "string", "Package name, optionally with a version").
+		OptionalEnumParam("manager", "Package manager (default pip)", "pip", "npm").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Package string `json:"package"`
+				Manager string `json:"manager"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", fmt.Errorf("invalid input: %w", err)
+			}
+			if params.Manager == "" {
+				params.Manager = "pip"
+			}
+			return tk.use(ctx, func(s *Sandbox) (any, error) {
+				result, err := s.InstallPackage(ctx, params.Manager, params.Package)
+				if err != nil {
+					return nil, err
+				}
+				return newRunOutput(result), nil
+			})
+		}).
+		Create()
+}
+
+func (tk *SandboxToolkit) listFilesTool() *tools.Tool {
+	return tools.Build("list_files").
+		Description("List the files in a sandbox directory").
+		OptionalParam("path", "string", "Directory to list (default /home/user)").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			path := pathParam(input, "/home/user")
+			return tk.use(ctx, func(s *Sandbox) (any, error) {
+				files, err := s.ListFiles(ctx, path)
+				if err != nil {
+					return nil, err
+				}
+				if files == nil {
+					files = []FileInfo{}
+				}
+				return map[string]any{"path": path, "files": files}, nil
+			})
+		}).
+		Create()
+}
@@ -0,0 +1,147 @@
+package e2b_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/integrations/e2b"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// fakeE2B serves sandboxes with an in-memory file system.
+type fakeE2B struct {
+	mu      sync.Mutex
+	created int
+	killed  []string
+	files   map[string]map[string]string // sandbox ID to path to content
+}
+
+func (f *fakeE2B) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/sandboxes"), "/")
+	var body map[string]string
+	json.NewDecoder(r.Body).Decode(&body)
+	switch {
+	case r.Method == "POST" && len(parts) == 1:
+		f.created++
+		id := fmt.Sprintf("sb%d", f.created)
+		f.files[id] = map[string]string{}
+		fmt.Fprintf(w, `{"sandboxId": %q}`, id)
+	case r.Method == "DELETE":
+		f.killed = append(f.killed, parts[1])
+		w.Write([]byte(`{}`))
+	case r.URL.Path == "/sandboxes/"+parts[1]+"/code/run":
+		if body["language"] == "bash" {
+			fmt.Fprintf(w, `{"stdout": "installed\n", "stderr": "", "exitCode": 0}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"stdout":   "files: " + fmt.Sprint(len(f.files[parts[1]])),
+			"stderr":   "warning: deprecated",
+			"exitCode": 1,
+		})
+	case r.Method == "POST" && r.URL.Path == "/sandboxes/"+parts[1]+"/files":
+		f.files[parts[1]][body["path"]] = body["content"]
+		w.Write([]byte(`{}`))
+	case r.Method == "GET" && r.URL.Path == "/sandboxes/"+parts[1]+"/files":
+		json.NewEncoder(w).Encode(map[string]string{"content": f.files[parts[1]][r.URL.Query().Get("path")]})
+	case r.URL.Path == "/sandboxes/"+parts[1]+"/files/list":
+		var list []e2b.FileInfo
+		for path := range f.files[parts[1]] {
+			list = append(list, e2b.FileInfo{Name: path, Path: path})
+		}
+		json.NewEncoder(w).Encode(list)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeE2B) stats() (int, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.created, append([]string(nil), f.killed...)
+}
+
+func newToolkit(t *testing.T) (*e2b.SandboxToolkit, *fakeE2B, *tools.Registry) {
+	t.Helper()
+	fake := &fakeE2B{files: map[string]map[string]string{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	tk := e2b.New("key").WithBaseURL(srv.URL).WithHTTPClient(srv.Client()).Toolkit("python")
+	registry := tools.NewRegistry()
+	if err := tk.RegisterTo(registry); err != nil {
+		t.Fatal(err)
+	}
+	return tk, fake, registry
+}
+
+func TestSandboxToolkit_SharesSandbox(t *testing.T) {
+	tk, fake, registry := newToolkit(t)
+	ctx := context.Background()
+	if tk.SandboxID() != "" {
+		t.Fatal("Expected no sandbox before the first call")
+	}
+
+	steps := []struct {
+		tool, input, want string
+	}{
+		{"write_file", `{"path": "data.csv", "content": "a,b"}`, `{"bytes":3,"path":"data.csv"}`},
+		{"read_file", `{"path": "data.csv"}`, `{"content":"a,b","path":"data.csv"}`},
+		{"install_package", `{"package": "pandas"}`, `{"stdout":"installed\n","stderr":"","exit_code":0}`},
+		{"run_code", `{"code": "print(len(files))"}`, `{"stdout":"files: 1","stderr":"warning: deprecated","exit_code":1}`},
+		{"list_files", `{}`, `{"files":[{"name":"data.csv","path":"data.csv","size":0,"isDir":false}],"path":"/home/user"}`},
+	}
+	for _, step := range steps {
+		out, err := registry.Execute(ctx, step.tool, step.input)
+		if err != nil || out != step.want {
+			t.Errorf("%s: got %s (%v), want %s", step.tool, out, err, step.want)
+		}
+	}
+	if created, _ := fake.stats(); created != 1 || tk.SandboxID() != "sb1" {
+		t.Errorf("Expected one shared sandbox, created %d", created)
+	}
+
+	if err := tk.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, killed := fake.stats(); len(killed) != 1 || killed[0] != "sb1" || tk.SandboxID() != "" {
+		t.Errorf("Expected sb1 killed, got %v", killed)
+	}
+}
+
+func TestSandboxToolkit_IdleTTL(t *testing.T) {
+	tk, fake, registry := newToolkit(t)
+	tk.WithIdleTTL(30 * time.Millisecond)
+	ctx := context.Background()
+
+	if _, err := registry.Execute(ctx, "run_code", `{"code": "1"}`); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for tk.SandboxID() != "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, killed := fake.stats(); len(killed) == 0 && time.Now().Before(deadline); _, killed = fake.stats() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, killed := fake.stats(); len(killed) != 1 {
+		t.Fatalf("Expected the idle sandbox killed, got %v", killed)
+	}
+
+	// The next call starts a fresh sandbox.
+	if _, err := registry.Execute(ctx, "list_files", `{"path": "/tmp"}`); err != nil {
+		t.Fatal(err)
+	}
+	if created, _ := fake.stats(); created != 2 || tk.SandboxID() != "sb2" {
+		t.Errorf("Expected a second sandbox, created %d", created)
+	}
+	tk.Close(ctx)
+}
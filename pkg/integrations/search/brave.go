@@ -0,0 +1,78 @@
+package search
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+const braveURL = "https://api.search.brave.com/res/v1"
+
+// braveFreshness maps freshness to Brave's codes.
+var braveFreshness = map[string]string{
+	"day":   "pd",
+	"week":  "pw",
+	"month": "pm",
+	"year":  "py",
+}
+
+// tags matches the highlighting markup in Brave descriptions.
+var tags = regexp.MustCompile(`<[^>]*>`)
+
+// Brave searches with the Brave Search API.
+type Brave struct {
+	client
+}
+
+// NewBrave creates a Brave Search provider.
+// If apiKey is empty, it reads from BRAVE_API_KEY environment variable.
+func NewBrave(apiKey string, opts ...Option) *Brave {
+	if apiKey == "" {
+		apiKey = os.Getenv("BRAVE_API_KEY")
+	}
+	return &Brave{newClient("brave", apiKey, "BRAVE_API_KEY", braveURL, opts)}
+}
+
+// Name returns "brave".
+func (b *Brave) Name() string { return b.name }
+
+// Search runs a Brave web search.
+func (b *Brave) Search(ctx context.Context, req Request) ([]Result, error) {
+	q := url.Values{}
+	q.Set("q", req.Query)
+	q.Set("count", strconv.Itoa(numResults(req.NumResults, 5, 20)))
+	if f, ok := braveFreshness[req.Freshness]; ok {
+		q.Set("freshness", f)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", b.baseURL+"/web/search?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("X-Subscription-Token", b.apiKey)
+
+	var resp struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := b.do(httpReq, &resp); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(resp.Web.Results))
+	for i, r := range resp.Web.Results {
+		results[i] = Result{
+			Title:   html.UnescapeString(tags.ReplaceAllString(r.Title, "")),
+			URL:     r.URL,
+			Snippet: html.UnescapeString(tags.ReplaceAllString(r.Description, "")),
+		}
+	}
+	return results, nil
+}
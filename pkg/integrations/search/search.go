@@ -0,0 +1,149 @@
+// Package search provides web search for agents through search APIs such
+// as Tavily and Brave.
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/egress"
+)
+
+// Request is a search query.
+type Request struct {
+	Query string
+	// NumResults is the number of results wanted. Providers cap it.
+	NumResults int
+	// Freshness limits results to those from the past "day", "week",
+	// "month" or "year". Empty means any time.
+	Freshness string
+}
+
+// Result is one search result.
+type Result struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// Provider searches the web.
+type Provider interface {
+	// Name identifies the provider in errors.
+	Name() string
+	Search(ctx context.Context, req Request) ([]Result, error)
+}
+
+// APIError is an error response from a search API.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error (%d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Option configures a provider.
+type Option func(*client)
+
+// WithBaseURL sets the API endpoint.
+func WithBaseURL(url string) Option {
+	return func(c *client) { c.baseURL = url }
+}
+
+// WithHTTPClient replaces the HTTP client. The default client follows the
+// egress policy; a custom client bypasses it unless its transport wraps
+// egress.Transport.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *client) { c.httpClient = hc }
+}
+
+// client holds the connection settings shared by the providers.
+type client struct {
+	name       string
+	apiKey     string
+	keyEnv     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+func newClient(name, apiKey, keyEnv, baseURL string, opts []Option) client {
+	c := client{
+		name:       name,
+		apiKey:     apiKey,
+		keyEnv:     keyEnv,
+		baseURL:    baseURL,
+		httpClient: egress.NewClient(30 * time.Second),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// do sends req and decodes a successful JSON response into v.
+func (c *client) do(req *http.Request, v any) error {
+	if c.apiKey == "" {
+		return fmt.Errorf("%s: no API key; pass one or set %s", c.name, c.keyEnv)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.name, err)
+	}
+	if resp.StatusCode >= 400 {
+		return &APIError{Provider: c.name, StatusCode: resp.StatusCode, Message: errorMessage(body)}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%s: invalid response: %w", c.name, err)
+	}
+	return nil
+}
+
+// errorMessage extracts the message from an error response body.
+func errorMessage(body []byte) string {
+	var e struct {
+		Detail any `json:"detail"`
+		Error  any `json:"error"`
+	}
+	if json.Unmarshal(body, &e) == nil {
+		for _, v := range []any{e.Detail, e.Error} {
+			switch v := v.(type) {
+			case string:
+				return v
+			case map[string]any:
+				for _, key := range []string{"error", "detail", "message"} {
+					if s, ok := v[key].(string); ok && s != "" {
+						return s
+					}
+				}
+			}
+		}
+	}
+	if len(body) > 200 {
+		body = body[:200]
+	}
+	return string(body)
+}
+
+// numResults clamps n to [1, max], defaulting to def.
+func numResults(n, def, max int) int {
+	switch {
+	case n <= 0:
+		return def
+	case n > max:
+		return max
+	}
+	return n
+}
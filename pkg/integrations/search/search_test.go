@@ -0,0 +1,121 @@
+package search_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/integrations/search"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestTavily_Search(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" || r.Header.Get("Authorization") != "Bearer tvly-key" {
+			t.Errorf("Unexpected request %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		io.WriteString(w, `{"results": [{"title": "Go 1.24", "url": "https://go.dev/doc/go1.24", "content": "Release notes", "score": 0.9}]}`)
+	}))
+	defer srv.Close()
+
+	p := search.NewTavily("tvly-key", search.WithBaseURL(srv.URL), search.WithHTTPClient(srv.Client()))
+	results, err := p.Search(context.Background(), search.Request{Query: "go release", NumResults: 50, Freshness: "week"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0] != (search.Result{Title: "Go 1.24", URL: "https://go.dev/doc/go1.24", Snippet: "Release notes"}) {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+	if got["query"] != "go release" || got["max_results"] != float64(20) || got["time_range"] != "week" {
+		t.Errorf("Unexpected request body: %v", got)
+	}
+}
+
+func TestBrave_Search(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.Header.Get("X-Subscription-Token") != "brave-key" || q.Get("q") != "goflow" || q.Get("count") != "5" || q.Get("freshness") != "pd" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		io.WriteString(w, `{"web": {"results": [{"title": "GoFlow", "url": "https://example.com", "description": "Agents in <strong>Go</strong> &amp; more"}]}}`)
+	}))
+	defer srv.Close()
+
+	p := search.NewBrave("brave-key", search.WithBaseURL(srv.URL), search.WithHTTPClient(srv.Client()))
+	results, err := p.Search(context.Background(), search.Request{Query: "goflow", Freshness: "day"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Snippet != "Agents in Go & more" {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+}
+
+func TestProvider_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"detail": {"error": "Unauthorized: missing or invalid API key."}}`)
+	}))
+	defer srv.Close()
+
+	registry := tools.NewRegistry()
+	registry.Register(search.NewTool(search.NewTavily("bad", search.WithBaseURL(srv.URL)), 0))
+	_, err := registry.Execute(context.Background(), "web_search", `{"query": "x"}`)
+	var apiErr *search.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 || !strings.Contains(err.Error(), "tavily API error (401): Unauthorized") {
+		t.Errorf("Expected the provider's error, got %v", err)
+	}
+
+	t.Setenv("BRAVE_API_KEY", "")
+	_, err = search.NewBrave("").Search(context.Background(), search.Request{Query: "x"})
+	if err == nil || !strings.Contains(err.Error(), "set BRAVE_API_KEY") {
+		t.Errorf("Expected a missing key error, got %v", err)
+	}
+}
+
+type fakeProvider []search.Result
+
+func (f fakeProvider) Name() string { return "fake" }
+func (f fakeProvider) Search(ctx context.Context, req search.Request) ([]search.Result, error) {
+	return f, nil
+}
+
+func TestNewTool_Truncates(t *testing.T) {
+	results := fakeProvider{
+		{Title: "One", URL: "https://a.example", Snippet: strings.Repeat("é", 100)},
+		{Title: "Two", URL: "https://b.example", Snippet: "short"},
+	}
+	for _, tt := range []struct {
+		maxBytes  int
+		results   int
+		truncated bool
+	}{
+		{maxBytes: 4096, results: 2},
+		{maxBytes: 250, results: 1, truncated: true},
+		{maxBytes: 120, results: 1, truncated: true},
+	} {
+		registry := tools.NewRegistry()
+		registry.Register(search.NewTool(results, tt.maxBytes))
+		out, err := registry.Execute(context.Background(), "web_search", `{"query": "q", "freshness": "month"}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got struct {
+			Results   []search.Result `json:"results"`
+			Truncated bool            `json:"truncated"`
+		}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("%d: invalid JSON %q: %v", tt.maxBytes, out, err)
+		}
+		if len(out) > tt.maxBytes || len(got.Results) != tt.results || got.Truncated != tt.truncated {
+			t.Errorf("%d: got %d bytes: %s", tt.maxBytes, len(out), out)
+		}
+	}
+}
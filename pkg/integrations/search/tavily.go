@@ -0,0 +1,66 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+)
+
+const tavilyURL = "https://api.tavily.com"
+
+// Tavily searches with the Tavily API, which is built for agents and
+// returns page content excerpts as snippets.
+type Tavily struct {
+	client
+}
+
+// NewTavily creates a Tavily provider.
+// If apiKey is empty, it reads from TAVILY_API_KEY environment variable.
+func NewTavily(apiKey string, opts ...Option) *Tavily {
+	if apiKey == "" {
+		apiKey = os.Getenv("TAVILY_API_KEY")
+	}
+	return &Tavily{newClient("tavily", apiKey, "TAVILY_API_KEY", tavilyURL, opts)}
+}
+
+// Name returns "tavily".
+func (t *Tavily) Name() string { return t.name }
+
+// Search runs a Tavily search.
+func (t *Tavily) Search(ctx context.Context, req Request) ([]Result, error) {
+	body := map[string]any{
+		"query":       req.Query,
+		"max_results": numResults(req.NumResults, 5, 20),
+	}
+	if req.Freshness != "" {
+		body["time_range"] = req.Freshness
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", t.baseURL+"/search", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+t.apiKey)
+
+	var resp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := t.do(httpReq, &resp); err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(resp.Results))
+	for i, r := range resp.Results {
+		results[i] = Result{Title: r.Title, URL: r.URL, Snippet: r.Content}
+	}
+	return results, nil
+}
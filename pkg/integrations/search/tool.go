@@ -0,0 +1,84 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// DefaultMaxBytes is the output budget of NewTool when none is given.
+const DefaultMaxBytes = 8192
+
+// toolOutput is the JSON result of the web_search tool.
+type toolOutput struct {
+	Query     string   `json:"query"`
+	Results   []Result `json:"results"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+// NewTool creates a web_search tool backed by p. Its output is kept
+// within maxBytes by dropping the last results and then shortening
+// snippets; maxBytes <= 0 uses DefaultMaxBytes.
+func NewTool(p Provider, maxBytes int) *tools.Tool {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	return tools.Build("web_search").
+		Description("Search the web. Returns a JSON list of results with title, url and snippet").
+		Param("query", "string", "The search query").
+		OptionalParam("num_results", "integer", "Number of results (default 5, at most 20)").
+		OptionalEnumParam("freshness", "Only return results from the past day, week, month or year", "day", "week", "month", "year").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Query      string `json:"query"`
+				NumResults int    `json:"num_results"`
+				Freshness  string `json:"freshness"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			results, err := p.Search(ctx, Request{
+				Query:      params.Query,
+				NumResults: params.NumResults,
+				Freshness:  params.Freshness,
+			})
+			if err != nil {
+				return "", err
+			}
+			out, err := fit(toolOutput{Query: params.Query, Results: results}, maxBytes)
+			return string(out), err
+		}).
+		Create()
+}
+
+// fit marshals out within maxBytes where it can.
+func fit(out toolOutput, maxBytes int) ([]byte, error) {
+	for {
+		data, err := json.Marshal(out)
+		if err != nil || len(data) <= maxBytes || len(out.Results) == 0 {
+			return data, err
+		}
+		out.Truncated = true
+		last := &out.Results[len(out.Results)-1]
+		if len(out.Results) > 1 {
+			out.Results = out.Results[:len(out.Results)-1]
+			continue
+		}
+		if last.Snippet == "" {
+			return data, nil
+		}
+		// JSON escaping can make the snippet longer than its share, so
+		// trim by the overflow and try again.
+		n := max(len(last.Snippet)-(len(data)-maxBytes)-len("..."), 0)
+		for n > 0 && !utf8.RuneStart(last.Snippet[n]) {
+			n--
+		}
+		last.Snippet = strings.TrimSuffix(last.Snippet[:n], "...")
+		if n > 0 {
+			last.Snippet += "..."
+		}
+	}
+}
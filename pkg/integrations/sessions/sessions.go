@@ -0,0 +1,414 @@
+// Package sessions provides a tracker for billable cloud sessions, such as
+// E2B sandboxes and Browserbase browsers, with per-tenant quotas and a
+// janitor that reaps sessions leaked by crashed or finished runs.
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long a session may live when neither the caller nor the
+// tracker sets a TTL.
+const DefaultTTL = 30 * time.Minute
+
+// maxFinishedRuns caps the finished runs remembered between sweeps.
+const maxFinishedRuns = 10000
+
+// Metadata keys stamped on provider-side sessions so the janitor can
+// recognise them after the process that created them is gone.
+const (
+	MetaRunID     = "goflow_run_id"
+	MetaTenant    = "goflow_tenant"
+	MetaExpiresAt = "goflow_expires_at"
+)
+
+// ErrQuotaExceeded is returned when a tenant is at its concurrent session
+// limit.
+var ErrQuotaExceeded = errors.New("sessions: quota exceeded")
+
+// ErrNotFound is returned when a session is not tracked.
+var ErrNotFound = errors.New("sessions: session not found")
+
+// QuotaError reports the tenant and limit behind an ErrQuotaExceeded.
+type QuotaError struct {
+	Tenant string
+	Limit  int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("sessions: tenant %q is at its limit of %d concurrent sessions", e.Tenant, e.Limit)
+}
+
+// Unwrap makes errors.Is(err, ErrQuotaExceeded) hold.
+func (e *QuotaError) Unwrap() error { return ErrQuotaExceeded }
+
+// Owner identifies who a session is billed to.
+type Owner struct {
+	Tenant string
+	RunID  string
+}
+
+type ownerKey struct{}
+
+// WithOwner returns a context whose sessions are attributed to owner.
+func WithOwner(ctx context.Context, owner Owner) context.Context {
+	return context.WithValue(ctx, ownerKey{}, owner)
+}
+
+// OwnerFromContext returns the owner set by WithOwner.
+func OwnerFromContext(ctx context.Context) Owner {
+	owner, _ := ctx.Value(ownerKey{}).(Owner)
+	return owner
+}
+
+// Session is a live provider-side session.
+type Session struct {
+	ID        string    `json:"id"`
+	Provider  string    `json:"provider"`
+	Tenant    string    `json:"tenant,omitempty"`
+	RunID     string    `json:"run_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Provider lists and kills sessions on a provider's side.
+type Provider interface {
+	// Name identifies the provider, e.g. "e2b".
+	Name() string
+	// List returns the live sessions carrying tracker metadata. Sessions
+	// created outside GoFlow are left out.
+	List(ctx context.Context) ([]Session, error)
+	// Kill terminates a session.
+	Kill(ctx context.Context, id string) error
+}
+
+// Tracker records the sessions GoFlow creates, enforces per-tenant
+// concurrency quotas and reaps sessions that outlive their TTL or run.
+type Tracker struct {
+	mu           sync.Mutex
+	providers    map[string]Provider
+	sessions     map[string]*Session  // provider/id -> session
+	pending      map[string]int       // tenant -> reservations in flight
+	finished     map[string]time.Time // run ID -> when it finished
+	order        []string             // finished run IDs, oldest first
+	quotas       map[string]int
+	defaultQuota int
+	ttl          time.Duration
+	longest      time.Duration // longest TTL reserved
+	runFinished  func(ctx context.Context, runID string) bool
+	now          func() time.Time
+}
+
+// Option configures a Tracker.
+type Option func(*Tracker)
+
+// WithTTL sets the TTL for sessions created without one.
+func WithTTL(ttl time.Duration) Option {
+	return func(t *Tracker) {
+		t.ttl = ttl
+	}
+}
+
+// WithQuota sets the default concurrent session limit per tenant. Zero
+// means unlimited.
+func WithQuota(n int) Option {
+	return func(t *Tracker) {
+		t.defaultQuota = n
+	}
+}
+
+// WithTenantQuota sets the concurrent session limit for one tenant,
+// overriding WithQuota.
+func WithTenantQuota(tenant string, n int) Option {
+	return func(t *Tracker) {
+		t.quotas[tenant] = n
+	}
+}
+
+// WithRunStatus lets the janitor ask whether a run has finished, for runs
+// tracked elsewhere such as the workflow engine.
+func WithRunStatus(fn func(ctx context.Context, runID string) bool) Option {
+	return func(t *Tracker) {
+		t.runFinished = fn
+	}
+}
+
+// NewTracker creates a session tracker.
+func NewTracker(opts ...Option) *Tracker {
+	t := &Tracker{
+		providers: make(map[string]Provider),
+		sessions:  make(map[string]*Session),
+		pending:   make(map[string]int),
+		finished:  make(map[string]time.Time),
+		quotas:    make(map[string]int),
+		ttl:       DefaultTTL,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.longest = t.ttl
+	return t
+}
+
+// Register adds a provider for the janitor to sweep.
+func (t *Tracker) Register(p Provider) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.providers[p.Name()] = p
+}
+
+// ============ Creation ============
+
+// Reservation holds a quota slot while a session is being created.
+type Reservation struct {
+	tracker   *Tracker
+	provider  string
+	owner     Owner
+	expiresAt time.Time
+	done      bool
+}
+
+// Reserve claims a quota slot for owner's tenant before a session is
+// created on provider. A zero ttl uses the tracker's TTL. Callers must
+// Commit or Cancel the reservation.
+func (t *Tracker) Reserve(provider string, owner Owner, ttl time.Duration) (*Reservation, error) {
+	if ttl <= 0 {
+		ttl = t.ttl
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	limit, ok := t.quotas[owner.Tenant]
+	if !ok {
+		limit = t.defaultQuota
+	}
+	if limit > 0 && t.activeLocked(owner.Tenant)+t.pending[owner.Tenant] >= limit {
+		return nil, &QuotaError{Tenant: owner.Tenant, Limit: limit}
+	}
+	t.pending[owner.Tenant]++
+	t.longest = max(t.longest, ttl)
+
+	return &Reservation{
+		tracker:   t,
+		provider:  provider,
+		owner:     owner,
+		expiresAt: t.now().Add(ttl),
+	}, nil
+}
+
+// Metadata returns the tags to attach to the provider-side session.
+func (r *Reservation) Metadata() map[string]string {
+	return map[string]string{
+		MetaRunID:     r.owner.RunID,
+		MetaTenant:    r.owner.Tenant,
+		MetaExpiresAt: r.expiresAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// ExpiresAt returns when the session will be reaped.
+func (r *Reservation) ExpiresAt() time.Time { return r.expiresAt }
+
+// Commit records the created session and releases the reservation.
+func (r *Reservation) Commit(id string) {
+	t := r.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r.done {
+		return
+	}
+	r.done = true
+	t.pending[r.owner.Tenant]--
+	t.sessions[key(r.provider, id)] = &Session{
+		ID:        id,
+		Provider:  r.provider,
+		Tenant:    r.owner.Tenant,
+		RunID:     r.owner.RunID,
+		CreatedAt: t.now(),
+		ExpiresAt: r.expiresAt,
+	}
+}
+
+// Cancel releases the reservation if it was not committed.
+func (r *Reservation) Cancel() {
+	t := r.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r.done {
+		return
+	}
+	r.done = true
+	t.pending[r.owner.Tenant]--
+}
+
+// Forget stops tracking a session that was closed normally.
+func (t *Tracker) Forget(provider, id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.sessions, key(provider, id))
+}
+
+// ============ Inspection ============
+
+// Sessions returns the tracked sessions, oldest first.
+func (t *Tracker) Sessions() []Session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Session, 0, len(t.sessions))
+	for _, s := range t.sessions {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// Kill terminates a tracked session on its provider and forgets it.
+func (t *Tracker) Kill(ctx context.Context, provider, id string) error {
+	t.mu.Lock()
+	_, tracked := t.sessions[key(provider, id)]
+	p := t.providers[provider]
+	t.mu.Unlock()
+	if !tracked || p == nil {
+		return ErrNotFound
+	}
+	if err := p.Kill(ctx, id); err != nil {
+		return err
+	}
+	t.Forget(provider, id)
+	return nil
+}
+
+// FinishRun marks a run as finished so the janitor reaps its sessions.
+// Finished runs are forgotten once every session they could own is past
+// its TTL, and the oldest are dropped beyond ten thousand.
+func (t *Tracker) FinishRun(runID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.finished[runID]; !ok {
+		t.finished[runID] = t.now()
+		t.order = append(t.order, runID)
+	}
+	t.evictLocked()
+}
+
+// evictLocked forgets finished runs older than the longest TTL, whose
+// sessions are reaped as expired anyway, and the oldest past the cap.
+func (t *Tracker) evictLocked() {
+	cutoff := t.now().Add(-t.longest)
+	for len(t.order) > 0 && (len(t.order) > maxFinishedRuns || t.finished[t.order[0]].Before(cutoff)) {
+		delete(t.finished, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// ============ Janitor ============
+
+// Reap lists every provider's sessions and kills those past their TTL or
+// owned by a finished run, including sessions this tracker never saw
+// because the process that created them crashed. Tracked sessions the
+// provider no longer lists are forgotten. It returns the sessions killed.
+func (t *Tracker) Reap(ctx context.Context) ([]Session, error) {
+	t.mu.Lock()
+	t.evictLocked()
+	providers := make([]Provider, 0, len(t.providers))
+	for _, p := range t.providers {
+		providers = append(providers, p)
+	}
+	t.mu.Unlock()
+
+	var reaped []Session
+	var errs []error
+	for _, p := range providers {
+		live, err := p.List(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sessions: listing %s: %w", p.Name(), err))
+			continue
+		}
+
+		seen := make(map[string]bool, len(live))
+		for _, s := range live {
+			s.Provider = p.Name()
+			seen[key(s.Provider, s.ID)] = true
+			if !t.expired(ctx, s) {
+				continue
+			}
+			if err := p.Kill(ctx, s.ID); err != nil {
+				errs = append(errs, fmt.Errorf("sessions: killing %s session %s: %w", s.Provider, s.ID, err))
+				continue
+			}
+			t.Forget(s.Provider, s.ID)
+			reaped = append(reaped, s)
+		}
+
+		t.mu.Lock()
+		for k, s := range t.sessions {
+			if s.Provider == p.Name() && !seen[k] {
+				delete(t.sessions, k)
+			}
+		}
+		t.mu.Unlock()
+	}
+	return reaped, errors.Join(errs...)
+}
+
+// StartJanitor reaps sessions every interval until ctx is done.
+func (t *Tracker) StartJanitor(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.Reap(ctx)
+			}
+		}
+	}()
+}
+
+// expired reports whether s is past its TTL or belongs to a finished run.
+func (t *Tracker) expired(ctx context.Context, s Session) bool {
+	if !s.ExpiresAt.IsZero() && t.now().After(s.ExpiresAt) {
+		return true
+	}
+	if s.RunID == "" {
+		return false
+	}
+	t.mu.Lock()
+	_, finished := t.finished[s.RunID]
+	t.mu.Unlock()
+	return finished || (t.runFinished != nil && t.runFinished(ctx, s.RunID))
+}
+
+func (t *Tracker) activeLocked(tenant string) int {
+	n := 0
+	for _, s := range t.sessions {
+		if s.Tenant == tenant {
+			n++
+		}
+	}
+	return n
+}
+
+// FromMetadata builds a Session from the tags set by Reservation.Metadata.
+// ok is false when the metadata does not carry tracker tags.
+func FromMetadata(id string, meta map[string]string) (s Session, ok bool) {
+	expires, hasExpiry := meta[MetaExpiresAt]
+	if !hasExpiry {
+		return Session{}, false
+	}
+	s = Session{ID: id, Tenant: meta[MetaTenant], RunID: meta[MetaRunID]}
+	s.ExpiresAt, _ = time.Parse(time.RFC3339, expires)
+	return s, true
+}
+
+func key(provider, id string) string {
+	return provider + "/" + id
+}
@@ -0,0 +1,218 @@
+package sessions_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/integrations/browserbase"
+	"github.com/nuulab/goflow/pkg/integrations/e2b"
+	"github.com/nuulab/goflow/pkg/integrations/sessions"
+)
+
+// stubProvider fakes a provider API that creates, lists and deletes
+// sessions under prefix, keeping the metadata sent at creation.
+type stubProvider struct {
+	mu      sync.Mutex
+	prefix  string // "/sandboxes" or "/sessions"
+	metaKey string // "metadata" or "userMetadata"
+	live    map[string]map[string]string
+	creates int
+	killed  []string
+	nextID  int
+}
+
+func newStub(t *testing.T, prefix, metaKey string) (*stubProvider, *httptest.Server) {
+	stub := &stubProvider{prefix: prefix, metaKey: metaKey, live: make(map[string]map[string]string)}
+	srv := httptest.NewServer(http.HandlerFunc(stub.serve))
+	t.Cleanup(srv.Close)
+	return stub, srv
+}
+
+func (s *stubProvider) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == "POST" && r.URL.Path == s.prefix:
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		meta := map[string]string{}
+		if m, ok := body[s.metaKey].(map[string]any); ok {
+			for k, v := range m {
+				meta[k] = v.(string)
+			}
+		}
+		s.nextID++
+		s.creates++
+		id := fmt.Sprintf("s-%d", s.nextID)
+		s.live[id] = meta
+		json.NewEncoder(w).Encode(map[string]any{"id": id, "sandboxId": id, "status": "RUNNING", s.metaKey: meta})
+	case r.Method == "GET" && r.URL.Path == s.prefix:
+		var list []map[string]any
+		for id, meta := range s.live {
+			list = append(list, map[string]any{"id": id, "sandboxId": id, "status": "RUNNING", s.metaKey: meta})
+		}
+		json.NewEncoder(w).Encode(list)
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, s.prefix+"/"):
+		id := strings.TrimPrefix(r.URL.Path, s.prefix+"/")
+		delete(s.live, id)
+		s.killed = append(s.killed, id)
+		w.Write([]byte(`{}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// seed adds a session created outside this tracker.
+func (s *stubProvider) seed(id string, meta map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.live[id] = meta
+}
+
+func TestJanitor_ReapsLeakedSandbox(t *testing.T) {
+	stub, srv := newStub(t, "/sandboxes", "metadata")
+	tracker := sessions.NewTracker()
+	client := e2b.New("key").WithBaseURL(srv.URL).WithTracker(tracker)
+	ctx := context.Background()
+
+	// A sandbox leaked by a crashed process, and one GoFlow did not create.
+	stub.seed("leaked", map[string]string{
+		sessions.MetaRunID:     "run-crashed",
+		sessions.MetaExpiresAt: time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+	})
+	stub.seed("foreign", map[string]string{"team": "data"})
+
+	sandbox, err := client.CreateSandbox(sessions.WithOwner(ctx, sessions.Owner{Tenant: "acme", RunID: "run-1"}), e2b.CreateSandboxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta := stub.live[sandbox.ID]; meta[sessions.MetaRunID] != "run-1" || meta[sessions.MetaTenant] != "acme" {
+		t.Errorf("Expected tracker metadata on the sandbox, got %v", meta)
+	}
+
+	reaped, err := tracker.Reap(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reaped) != 1 || reaped[0].ID != "leaked" || reaped[0].Provider != e2b.ProviderName {
+		t.Fatalf("Expected the leaked sandbox to be reaped, got %+v", reaped)
+	}
+	if _, ok := stub.live["foreign"]; !ok {
+		t.Error("Janitor killed a sandbox it did not create")
+	}
+	if got := tracker.Sessions(); len(got) != 1 || got[0].ID != sandbox.ID {
+		t.Errorf("Expected the live sandbox to stay tracked, got %+v", got)
+	}
+}
+
+func TestJanitor_ReapsFinishedRun(t *testing.T) {
+	stub, srv := newStub(t, "/sessions", "userMetadata")
+	tracker := sessions.NewTracker()
+	client := browserbase.New("key", "proj").WithBaseURL(srv.URL).WithTracker(tracker)
+	ctx := sessions.WithOwner(context.Background(), sessions.Owner{RunID: "run-1"})
+
+	session, err := client.CreateSession(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reaped, _ := tracker.Reap(ctx); len(reaped) != 0 {
+		t.Fatalf("Reaped a session of a running run: %+v", reaped)
+	}
+
+	tracker.FinishRun("run-1")
+	reaped, err := tracker.Reap(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reaped) != 1 || reaped[0].ID != session.ID {
+		t.Fatalf("Expected the finished run's session to be reaped, got %+v", reaped)
+	}
+	if len(stub.killed) != 1 || len(tracker.Sessions()) != 0 {
+		t.Errorf("killed = %v, tracked = %+v", stub.killed, tracker.Sessions())
+	}
+}
+
+func TestTracker_Quota(t *testing.T) {
+	stub, srv := newStub(t, "/sandboxes", "metadata")
+	tracker := sessions.NewTracker(sessions.WithTenantQuota("acme", 1))
+	client := e2b.New("key").WithBaseURL(srv.URL).WithTracker(tracker)
+	acme := sessions.WithOwner(context.Background(), sessions.Owner{Tenant: "acme"})
+
+	first, err := client.CreateSandbox(acme, e2b.CreateSandboxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.CreateSandbox(acme, e2b.CreateSandboxOptions{})
+	if !errors.Is(err, sessions.ErrQuotaExceeded) {
+		t.Fatalf("Expected ErrQuotaExceeded, got %v", err)
+	}
+	var quotaErr *sessions.QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Tenant != "acme" || quotaErr.Limit != 1 {
+		t.Errorf("Expected a QuotaError for acme, got %#v", err)
+	}
+	if stub.creates != 1 {
+		t.Errorf("Over-limit creation reached the provider: %d creates", stub.creates)
+	}
+
+	other := sessions.WithOwner(context.Background(), sessions.Owner{Tenant: "globex"})
+	if _, err := client.CreateSandbox(other, e2b.CreateSandboxOptions{}); err != nil {
+		t.Errorf("Other tenants should be unaffected: %v", err)
+	}
+
+	if err := first.Kill(acme); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateSandbox(acme, e2b.CreateSandboxOptions{}); err != nil {
+		t.Errorf("Expected a free slot after Kill: %v", err)
+	}
+}
+
+func TestTracker_QuotaReleasedOnFailedCreate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	tracker := sessions.NewTracker(sessions.WithQuota(1))
+	client := e2b.New("key").WithBaseURL(srv.URL).WithTracker(tracker)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.CreateSandbox(context.Background(), e2b.CreateSandboxOptions{})
+		if err == nil || errors.Is(err, sessions.ErrQuotaExceeded) {
+			t.Fatalf("attempt %d: expected the provider error, got %v", i, err)
+		}
+	}
+}
+
+func TestTracker_ForgetsOldestFinishedRuns(t *testing.T) {
+	_, srv := newStub(t, "/sessions", "userMetadata")
+	tracker := sessions.NewTracker()
+	client := browserbase.New("key", "proj").WithBaseURL(srv.URL).WithTracker(tracker)
+	ctx := sessions.WithOwner(context.Background(), sessions.Owner{RunID: "run-0"})
+	if _, err := client.CreateSession(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Past the cap the oldest finished run is forgotten, so its session is
+	// left to its TTL.
+	for i := 0; i <= 10000; i++ {
+		tracker.FinishRun(fmt.Sprintf("run-%d", i))
+	}
+	if reaped, err := tracker.Reap(ctx); err != nil || len(reaped) != 0 {
+		t.Errorf("Expected the forgotten run's session to be kept, got %+v, %v", reaped, err)
+	}
+
+	tracker.FinishRun("run-0")
+	if reaped, err := tracker.Reap(ctx); err != nil || len(reaped) != 1 {
+		t.Errorf("Expected the session to be reaped once its run finishes again, got %+v, %v", reaped, err)
+	}
+}
@@ -5,15 +5,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/nuulab/goflow/internal/httpclient"
+	"github.com/nuulab/goflow/internal/sse"
 	"github.com/nuulab/goflow/pkg/core"
 )
 
+// defaultMaxRetries is how many times failed requests are retried.
+const defaultMaxRetries = 2
+
 const defaultBaseURL = "https://api.anthropic.com/v1"
 const apiVersion = "2023-06-01"
 
@@ -23,6 +30,7 @@ type Client struct {
 	baseURL    string
 	model      string
 	httpClient *http.Client
+	retry      httpclient.Config
 }
 
 // Option configures the Anthropic client.
@@ -42,6 +50,13 @@ func New(apiKey string, opts ...Option) *Client {
 		httpClient: &http.Client{
 			Timeout: 120 * time.Second,
 		},
+		retry: httpclient.Config{
+			MaxRetries:           defaultMaxRetries,
+			BaseDelay:            500 * time.Millisecond,
+			MaxDelay:             30 * time.Second,
+			MaxRetryAfter:        httpclient.DefaultMaxRetryAfter,
+			RetryableStatusCodes: httpclient.DefaultConfig().RetryableStatusCodes,
+		},
 	}
 
 	for _, opt := range opts {
@@ -72,6 +87,32 @@ func WithTimeout(d time.Duration) Option {
 	}
 }
 
+// WithMaxRetries sets how many times a request failing with a rate limit,
+// server error or dropped connection is retried. Zero disables retries.
+func WithMaxRetries(n int) Option {
+	return func(c *Client) {
+		c.retry.MaxRetries = n
+	}
+}
+
+// WithRetryBackoff sets the delay before the first retry, doubled on each
+// further retry up to max. A longer Retry-After from the server wins, up
+// to the limit set with WithMaxRetryAfter.
+func WithRetryBackoff(initial, max time.Duration) Option {
+	return func(c *Client) {
+		c.retry.BaseDelay = initial
+		c.retry.MaxDelay = max
+	}
+}
+
+// WithMaxRetryAfter caps how long a server's Retry-After can make a retry
+// wait (default 1m). A longer Retry-After is cut to d.
+func WithMaxRetryAfter(d time.Duration) Option {
+	return func(c *Client) {
+		c.retry.MaxRetryAfter = d
+	}
+}
+
 // ============ Request/Response Types ============
 
 type messagesRequest struct {
@@ -83,28 +124,44 @@ type messagesRequest struct {
 	TopP        *float64         `json:"top_p,omitempty"`
 	StopSequences []string       `json:"stop_sequences,omitempty"`
 	Stream      bool             `json:"stream,omitempty"`
+	Tools       []toolDefinition `json:"tools,omitempty"`
+}
+
+type toolDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
 }
 
 type messageContent struct {
 	Role    string `json:"role"`
-	Content string `json:"content"`
+	Content any    `json:"content"` // string or []contentBlock
+}
+
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
 }
 
 type messagesResponse struct {
-	ID           string `json:"id"`
-	Type         string `json:"type"`
-	Role         string `json:"role"`
-	Content      []struct {
-		Type string `json:"type"`
-		Text string `json:"text"`
-	} `json:"content"`
-	Model        string `json:"model"`
-	StopReason   string `json:"stop_reason"`
-	StopSequence string `json:"stop_sequence,omitempty"`
-	Usage        struct {
-		InputTokens  int `json:"input_tokens"`
-		OutputTokens int `json:"output_tokens"`
-	} `json:"usage"`
+	ID           string         `json:"id"`
+	Type         string         `json:"type"`
+	Role         string         `json:"role"`
+	Content      []contentBlock `json:"content"`
+	Model        string         `json:"model"`
+	StopReason   string         `json:"stop_reason"`
+	StopSequence string         `json:"stop_sequence,omitempty"`
+	Usage        messageUsage   `json:"usage"`
+}
+
+type messageUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
 }
 
 type streamEvent struct {
@@ -118,6 +175,17 @@ type streamEvent struct {
 		Type string `json:"type"`
 		Text string `json:"text"`
 	} `json:"content_block,omitempty"`
+	// Message carries the input token count on message_start.
+	Message *struct {
+		Usage messageUsage `json:"usage"`
+	} `json:"message,omitempty"`
+	// Usage carries the output token count on message_delta.
+	Usage *messageUsage `json:"usage,omitempty"`
+	// Error describes an error event.
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
 }
 
 type errorResponse struct {
@@ -139,29 +207,74 @@ func (c *Client) Generate(ctx context.Context, prompt string, opts ...core.Optio
 
 // GenerateChat produces a completion for a conversation.
 func (c *Client) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
-	options := &core.CallOptions{}
-	for _, opt := range opts {
-		opt(options)
+	msgResp, err := c.complete(ctx, c.newMessagesRequest(messages, opts))
+	if err != nil {
+		return "", err
 	}
+	core.ReportUsage(opts, c.usage(msgResp.Usage))
+	return msgResp.Content[0].Text, nil
+}
 
-	// Extract system message if present
-	var systemPrompt string
-	var chatMessages []messageContent
+// GenerateWithTools produces a completion with tools declared natively.
+// Every tool_use block of the reply becomes a tool call, in order; text
+// blocks are joined into the content. Tool results are sent back as
+// RoleTool messages, which become tool_result blocks.
+func (c *Client) GenerateWithTools(ctx context.Context, messages []core.Message, tools []core.ToolDefinition, opts ...core.Option) (*core.Response, error) {
+	req := c.newMessagesRequest(messages, opts)
+	for _, tool := range tools {
+		req.Tools = append(req.Tools, toolDefinition{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.Parameters,
+		})
+	}
 
-	for _, msg := range messages {
-		if msg.Role == core.RoleSystem {
-			systemPrompt = msg.Content
-		} else {
-			role := string(msg.Role)
-			if role == "tool" {
-				role = "user" // Anthropic doesn't have tool role
+	msgResp, err := c.complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &core.Response{StopReason: msgResp.StopReason, Usage: c.usage(msgResp.Usage)}
+	var text []string
+	for _, block := range msgResp.Content {
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "tool_use":
+			args := string(block.Input)
+			if args == "" {
+				args = "{}"
 			}
-			chatMessages = append(chatMessages, messageContent{
-				Role:    role,
-				Content: msg.Content,
-			})
+			result.ToolCalls = append(result.ToolCalls, core.ToolCall{ID: block.ID, Name: block.Name, Arguments: args})
 		}
 	}
+	result.Content = strings.Join(text, "\n")
+
+	if msgResp.StopReason == "tool_use" && len(result.ToolCalls) == 0 {
+		return nil, fmt.Errorf("anthropic: stop_reason tool_use without tool_use blocks")
+	}
+	core.ReportUsage(opts, result.Usage)
+	return result, nil
+}
+
+// usage attributes reported token counts to this client's model.
+func (c *Client) usage(u messageUsage) core.Usage {
+	return core.Usage{
+		Provider:         c.Provider(),
+		Model:            c.model,
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+func (c *Client) newMessagesRequest(messages []core.Message, opts []core.Option) messagesRequest {
+	options := &core.CallOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	systemPrompt, chatMessages := convertMessages(messages)
 
 	maxTokens := options.MaxTokens
 	if maxTokens == 0 {
@@ -184,48 +297,59 @@ func (c *Client) GenerateChat(ctx context.Context, messages []core.Message, opts
 	if len(options.StopSequences) > 0 {
 		req.StopSequences = options.StopSequences
 	}
+	return req
+}
+
+// do sends a request through the retrying client. Overloaded (529) and
+// rate limited responses are retried before their body is read, so a
+// stream is never restarted once events have arrived.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	return httpclient.NewWithHTTPClient(c.retry, c.httpClient).Do(req.Context(), req)
+}
 
+// complete sends a non-streaming messages request.
+func (c *Client) complete(ctx context.Context, req messagesRequest) (*messagesResponse, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/messages", bytes.NewReader(body))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("x-api-key", c.apiKey)
 	httpReq.Header.Set("anthropic-version", apiVersion)
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if resp.StatusCode >= 400 {
 		var errResp errorResponse
 		json.Unmarshal(respBody, &errResp)
-		return "", fmt.Errorf("Anthropic API error (%d): %s", resp.StatusCode, errResp.Error.Message)
+		return nil, &core.APIError{Provider: "anthropic", StatusCode: resp.StatusCode, Type: errResp.Error.Type, Message: errResp.Error.Message}
 	}
 
 	var msgResp messagesResponse
 	if err := json.Unmarshal(respBody, &msgResp); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(msgResp.Content) == 0 {
-		return "", fmt.Errorf("no content returned")
+		return nil, fmt.Errorf("no content returned")
 	}
 
-	return msgResp.Content[0].Text, nil
+	return &msgResp, nil
 }
 
 // Stream produces a streaming completion for the given prompt.
@@ -235,24 +359,47 @@ func (c *Client) Stream(ctx context.Context, prompt string, opts ...core.Option)
 	}, opts...)
 }
 
-// StreamChat produces a streaming completion for a conversation.
-func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
-	options := &core.CallOptions{}
-	for _, opt := range opts {
-		opt(options)
-	}
-
-	// Extract system message if present
+// convertMessages extracts the system prompt and maps the remaining messages
+// to Anthropic messages. Assistant tool calls become tool_use blocks and tool
+// results become tool_result blocks in a user message, merging consecutive
+// results since Anthropic requires alternating roles.
+func convertMessages(messages []core.Message) (string, []messageContent) {
 	var systemPrompt string
 	var chatMessages []messageContent
 
 	for _, msg := range messages {
-		if msg.Role == core.RoleSystem {
+		switch {
+		case msg.Role == core.RoleSystem:
 			systemPrompt = msg.Content
-		} else {
+
+		case msg.Role == core.RoleTool && msg.ToolCallID != "":
+			block := contentBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content}
+			if n := len(chatMessages); n > 0 && chatMessages[n-1].Role == "user" {
+				if blocks, ok := chatMessages[n-1].Content.([]contentBlock); ok {
+					chatMessages[n-1].Content = append(blocks, block)
+					continue
+				}
+			}
+			chatMessages = append(chatMessages, messageContent{Role: "user", Content: []contentBlock{block}})
+
+		case msg.Role == core.RoleAssistant && len(msg.ToolCalls) > 0:
+			var blocks []contentBlock
+			if msg.Content != "" {
+				blocks = append(blocks, contentBlock{Type: "text", Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				input := json.RawMessage(tc.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, contentBlock{Type: "tool_use", ID: tc.ID, Name: tc.Name, Input: input})
+			}
+			chatMessages = append(chatMessages, messageContent{Role: "assistant", Content: blocks})
+
+		default:
 			role := string(msg.Role)
 			if role == "tool" {
-				role = "user"
+				role = "user" // Anthropic doesn't have tool role
 			}
 			chatMessages = append(chatMessages, messageContent{
 				Role:    role,
@@ -260,6 +407,30 @@ func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts .
 			})
 		}
 	}
+	return systemPrompt, chatMessages
+}
+
+// StreamChat produces a streaming completion for a conversation. The
+// channel closes at the end of the stream or on error; use
+// StreamChatEvents to observe errors.
+func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	events, err := c.StreamChatEvents(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return core.StreamText(ctx, events), nil
+}
+
+// StreamChatEvents streams a completion as events. API errors sent as
+// "error" events, malformed events and streams cut off before message_stop
+// end the stream with an error event.
+func (c *Client) StreamChatEvents(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan core.StreamEvent, error) {
+	options := &core.CallOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	systemPrompt, chatMessages := convertMessages(messages)
 
 	maxTokens := options.MaxTokens
 	if maxTokens == 0 {
@@ -292,7 +463,7 @@ func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts .
 	httpReq.Header.Set("x-api-key", c.apiKey)
 	httpReq.Header.Set("anthropic-version", apiVersion)
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, err
 	}
@@ -302,59 +473,113 @@ func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts .
 		respBody, _ := io.ReadAll(resp.Body)
 		var errResp errorResponse
 		json.Unmarshal(respBody, &errResp)
-		return nil, fmt.Errorf("Anthropic API error (%d): %s", resp.StatusCode, errResp.Error.Message)
+		return nil, &core.APIError{Provider: "anthropic", StatusCode: resp.StatusCode, Type: errResp.Error.Type, Message: errResp.Error.Message}
 	}
 
-	ch := make(chan string)
+	events := make(chan core.StreamEvent)
 
 	go func() {
-		defer close(ch)
+		defer close(events)
 		defer resp.Body.Close()
 
-		for {
+		send := func(ev core.StreamEvent) bool {
 			select {
+			case events <- ev:
+				return true
 			case <-ctx.Done():
-				return
-			default:
+				return false
+			}
+		}
+		fail := func(err error) {
+			if ctx.Err() == nil {
+				send(core.StreamEvent{Err: err})
 			}
+		}
 
-			// Read SSE line
-			var line []byte
-			for {
-				b := make([]byte, 1)
-				_, err := resp.Body.Read(b)
-				if err != nil {
-					return
-				}
-				if b[0] == '\n' {
-					break
-				}
-				line = append(line, b[0])
+		var usage messageUsage
+		reader := sse.NewReader(resp.Body)
+		for {
+			ev, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				fail(fmt.Errorf("anthropic: stream ended before message_stop: %w", io.ErrUnexpectedEOF))
+				return
+			}
+			if err != nil {
+				fail(fmt.Errorf("anthropic: reading stream: %w", err))
+				return
 			}
 
-			lineStr := string(line)
-			if lineStr == "" {
-				continue
+			var event streamEvent
+			if err := json.Unmarshal([]byte(ev.Data), &event); err != nil {
+				fail(fmt.Errorf("anthropic: invalid stream event: %w", err))
+				return
 			}
 
-			// Parse SSE data
-			if len(lineStr) > 6 && lineStr[:6] == "data: " {
-				var event streamEvent
-				if err := json.Unmarshal([]byte(lineStr[6:]), &event); err != nil {
-					continue
+			switch event.Type {
+			case "message_start":
+				if event.Message != nil {
+					usage.InputTokens = event.Message.Usage.InputTokens
 				}
-
-				switch event.Type {
-				case "content_block_delta":
-					if event.Delta != nil && event.Delta.Text != "" {
-						ch <- event.Delta.Text
+			case "content_block_delta":
+				if event.Delta != nil && event.Delta.Text != "" {
+					if !send(core.StreamEvent{Content: event.Delta.Text}) {
+						return
 					}
-				case "message_stop":
-					return
 				}
+			case "message_delta":
+				if event.Usage != nil {
+					usage.OutputTokens = event.Usage.OutputTokens
+				}
+			case "message_stop":
+				if options.OnUsage != nil {
+					options.OnUsage(c.usage(usage))
+				}
+				send(core.StreamEvent{Done: true})
+				return
+			case "error":
+				fail(&core.APIError{Provider: "anthropic", Type: event.Error.Type, Message: event.Error.Message})
+				return
 			}
 		}
 	}()
 
-	return ch, nil
+	return events, nil
+}
+
+// SupportsToolMessages reports false: Anthropic rejects tool_use history
+// unless the request also declares tools, which only GenerateWithTools does.
+// Tool messages built by callers are still mapped to tool_result blocks.
+func (c *Client) SupportsToolMessages() bool { return false }
+
+// ============ Health ============
+
+// Provider returns the provider name.
+func (c *Client) Provider() string { return "anthropic" }
+
+// Model returns the configured model.
+func (c *Client) Model() string { return c.model }
+
+// Ping verifies the API key and model by retrieving the model metadata.
+func (c *Client) Ping(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/models/"+c.model, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("x-api-key", c.apiKey)
+	httpReq.Header.Set("anthropic-version", apiVersion)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		var errResp errorResponse
+		json.Unmarshal(respBody, &errResp)
+		return &core.APIError{Provider: "anthropic", StatusCode: resp.StatusCode, Type: errResp.Error.Type, Message: errResp.Error.Message}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
 }
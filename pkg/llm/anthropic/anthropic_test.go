@@ -0,0 +1,323 @@
+// Package anthropic_test provides request fixture tests for the Anthropic provider.
+package anthropic_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/llm/anthropic"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestAnthropic_ToolMessages(t *testing.T) {
+	var captured []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"content":[{"type":"text","text":"It is sunny."}]}`))
+	}))
+	defer server.Close()
+
+	client := anthropic.New("test", anthropic.WithBaseURL(server.URL))
+	_, err := client.GenerateChat(context.Background(), []core.Message{
+		{Role: core.RoleSystem, Content: "sys"},
+		{Role: core.RoleUser, Content: "Weather in Paris and Rome?"},
+		{Role: core.RoleAssistant, Content: "Checking.", ToolCalls: []core.ToolCall{
+			{ID: "toolu_1", Name: "weather", Arguments: `{"city":"Paris"}`},
+			{ID: "toolu_2", Name: "weather", Arguments: `{"city":"Rome"}`},
+		}},
+		{Role: core.RoleTool, Name: "weather", ToolCallID: "toolu_1", Content: "sunny"},
+		{Role: core.RoleTool, Name: "weather", ToolCallID: "toolu_2", Content: "rainy"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type block struct {
+		Type      string          `json:"type"`
+		Text      string          `json:"text"`
+		ID        string          `json:"id"`
+		Name      string          `json:"name"`
+		Input     json.RawMessage `json:"input"`
+		ToolUseID string          `json:"tool_use_id"`
+		Content   string          `json:"content"`
+	}
+	var req struct {
+		System   string `json:"system"`
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(captured, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.System != "sys" || len(req.Messages) != 3 {
+		t.Fatalf("Expected system prompt and 3 messages, got %s", captured)
+	}
+
+	var use []block
+	if err := json.Unmarshal(req.Messages[1].Content, &use); err != nil {
+		t.Fatalf("Expected content blocks for tool use: %v", err)
+	}
+	if req.Messages[1].Role != "assistant" || len(use) != 3 || use[0].Text != "Checking." {
+		t.Fatalf("Unexpected assistant message: %s", req.Messages[1].Content)
+	}
+	if use[1].Type != "tool_use" || use[1].ID != "toolu_1" || use[1].Name != "weather" || string(use[1].Input) != `{"city":"Paris"}` {
+		t.Errorf("Unexpected tool_use block: %+v", use[1])
+	}
+
+	// Consecutive results are merged into one user message.
+	var results []block
+	if err := json.Unmarshal(req.Messages[2].Content, &results); err != nil {
+		t.Fatalf("Expected content blocks for tool results: %v", err)
+	}
+	if req.Messages[2].Role != "user" || len(results) != 2 {
+		t.Fatalf("Unexpected tool result message: %s", req.Messages[2].Content)
+	}
+	if results[0].Type != "tool_result" || results[0].ToolUseID != "toolu_1" || results[0].Content != "sunny" {
+		t.Errorf("Unexpected tool_result block: %+v", results[0])
+	}
+	if results[1].ToolUseID != "toolu_2" || results[1].Content != "rainy" {
+		t.Errorf("Unexpected tool_result block: %+v", results[1])
+	}
+}
+
+func TestAnthropic_GenerateWithTools(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&captured)
+		w.Write([]byte(`{"stop_reason":"tool_use","content":[
+			{"type":"text","text":"Checking both."},
+			{"type":"tool_use","id":"toolu_1","name":"weather","input":{"city":"Paris"}},
+			{"type":"tool_use","id":"toolu_2","name":"weather","input":{"city":"Rome"}}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := anthropic.New("test", anthropic.WithBaseURL(server.URL))
+	resp, err := client.GenerateWithTools(context.Background(), []core.Message{
+		{Role: core.RoleUser, Content: "Weather in Paris and Rome?"},
+	}, []core.ToolDefinition{{
+		Name:        "weather",
+		Description: "Current weather",
+		Parameters:  map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StopReason != "tool_use" || resp.Content != "Checking both." {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if len(resp.ToolCalls) != 2 {
+		t.Fatalf("Expected 2 tool calls, got %+v", resp.ToolCalls)
+	}
+	if tc := resp.ToolCalls[1]; tc.ID != "toolu_2" || tc.Name != "weather" || tc.Arguments != `{"city":"Rome"}` {
+		t.Errorf("Unexpected tool call: %+v", tc)
+	}
+
+	defs, _ := captured["tools"].([]any)
+	if len(defs) != 1 {
+		t.Fatalf("Expected tools in request, got %v", captured["tools"])
+	}
+	def := defs[0].(map[string]any)
+	if def["name"] != "weather" || def["input_schema"].(map[string]any)["type"] != "object" {
+		t.Errorf("Unexpected tool definition: %v", def)
+	}
+}
+
+func TestAnthropic_GenerateWithToolsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"stop_reason":"tool_use","content":[{"type":"text","text":"hmm"}]}`))
+	}))
+	defer server.Close()
+
+	client := anthropic.New("test", anthropic.WithBaseURL(server.URL))
+	_, err := client.GenerateWithTools(context.Background(), []core.Message{{Role: core.RoleUser, Content: "hi"}}, nil)
+	if err == nil {
+		t.Error("Expected error for tool_use stop without tool_use blocks")
+	}
+}
+
+func TestAnthropic_AgentToolCalling(t *testing.T) {
+	var requests []map[string]any
+	replies := []string{
+		`{"stop_reason":"tool_use","content":[{"type":"tool_use","id":"toolu_9","name":"weather","input":{"city":"Paris"}}]}`,
+		`{"stop_reason":"end_turn","content":[{"type":"text","text":"Sunny in Paris."}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		w.Write([]byte(replies[len(requests)-1]))
+	}))
+	defer server.Close()
+
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{
+		Name:        "weather",
+		Description: "Current weather",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			return "sunny", nil
+		},
+	})
+
+	a := agent.New(anthropic.New("test", anthropic.WithBaseURL(server.URL)), registry)
+	result, err := a.Run(context.Background(), "Weather in Paris?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Output != "Sunny in Paris." {
+		t.Errorf("Unexpected output: %q", result.Output)
+	}
+
+	msgs := requests[1]["messages"].([]any)
+	last := msgs[len(msgs)-1].(map[string]any)
+	blocks, _ := last["content"].([]any)
+	if last["role"] != "user" || len(blocks) != 1 {
+		t.Fatalf("Expected tool_result follow-up, got %v", last)
+	}
+	block := blocks[0].(map[string]any)
+	if block["type"] != "tool_result" || block["tool_use_id"] != "toolu_9" || block["content"] != "sunny" {
+		t.Errorf("Unexpected tool result: %v", block)
+	}
+	if requests[1]["tools"] == nil {
+		t.Error("Follow-up request must declare tools")
+	}
+}
+
+func TestAnthropic_Usage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Stream bool `json:"stream"`
+		}
+		json.Unmarshal(body, &req)
+		if !req.Stream {
+			w.Write([]byte(`{"content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn","usage":{"input_tokens":20,"output_tokens":4}}`))
+			return
+		}
+		w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":9,\"output_tokens\":1}}}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n" +
+			"event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":6}}\n\n" +
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+	}))
+	defer server.Close()
+
+	client := anthropic.New("test", anthropic.WithBaseURL(server.URL))
+	var usage []core.Usage
+	record := core.WithUsageCallback(func(u core.Usage) { usage = append(usage, u) })
+
+	if _, err := client.Generate(context.Background(), "hello", record); err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := client.Stream(context.Background(), "hello", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range chunks {
+	}
+
+	if len(usage) != 2 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	if u := usage[0]; u.Provider != "anthropic" || u.PromptTokens != 20 || u.CompletionTokens != 4 || u.TotalTokens != 24 {
+		t.Errorf("generate usage = %+v", u)
+	}
+	if u := usage[1]; u.PromptTokens != 9 || u.CompletionTokens != 6 || u.TotalTokens != 15 {
+		t.Errorf("stream usage = %+v", u)
+	}
+}
+
+func TestAnthropic_StreamErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   string
+	}{
+		{"api error", "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"par\"}}\n\nevent: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n", "Overloaded"},
+		{"cut off", "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"par\"}}\n\n", "before message_stop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.stream))
+			}))
+			defer server.Close()
+
+			client := anthropic.New("test", anthropic.WithBaseURL(server.URL))
+			events, err := client.StreamChatEvents(context.Background(), []core.Message{{Role: core.RoleUser, Content: "hi"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var content string
+			var last core.StreamEvent
+			for ev := range events {
+				content += ev.Content
+				last = ev
+			}
+			if content != "par" {
+				t.Errorf("Expected partial content %q, got %q", "par", content)
+			}
+			if last.Err == nil || !strings.Contains(last.Err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %+v", tt.want, last)
+			}
+		})
+	}
+}
+
+func TestAnthropic_StreamRetriesBeforeFirstByte(t *testing.T) {
+	var attempts int
+	stream := "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"hello\"}}\n\nevent: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(529)
+			w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+			return
+		}
+		w.Write([]byte(stream))
+	}))
+	defer server.Close()
+
+	client := anthropic.New("test", anthropic.WithBaseURL(server.URL), anthropic.WithRetryBackoff(time.Millisecond, time.Millisecond))
+	chunks, err := client.StreamChat(context.Background(), []core.Message{{Role: core.RoleUser, Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content string
+	for chunk := range chunks {
+		content += chunk
+	}
+	if content != "hello" || attempts != 2 {
+		t.Errorf("Expected the stream after one retry, got %q after %d attempts", content, attempts)
+	}
+}
+
+func TestAnthropic_MidStreamErrorNotRetried(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Write([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"par\"}}\n\nevent: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"))
+	}))
+	defer server.Close()
+
+	client := anthropic.New("test", anthropic.WithBaseURL(server.URL), anthropic.WithRetryBackoff(time.Millisecond, time.Millisecond))
+	events, err := client.StreamChatEvents(context.Background(), []core.Message{{Role: core.RoleUser, Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range events {
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a started stream not to be retried, got %d attempts", attempts)
+	}
+}
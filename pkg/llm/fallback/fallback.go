@@ -0,0 +1,338 @@
+// Package fallback provides an LLM that fails over across an ordered list
+// of providers.
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+const (
+	// defaultCooldown is how long a tripped provider is skipped.
+	defaultCooldown = 30 * time.Second
+	// defaultFailureThreshold is the number of consecutive retryable
+	// failures that trips a provider's circuit.
+	defaultFailureThreshold = 3
+)
+
+// ErrNoBackends is returned when a Fallback has no backend able to serve
+// the call.
+var ErrNoBackends = errors.New("fallback: no backends available")
+
+// Served records the backend that answered a call. Provider and Model are
+// empty when the backend does not implement core.ModelInfo.
+type Served struct {
+	// Index is the backend's position in the list passed to New.
+	Index    int
+	Provider string
+	Model    string
+	// Failovers counts the backends that failed before this one answered.
+	Failovers int
+}
+
+// Fallback implements core.LLM by trying its backends in order. A call
+// moves to the next backend when the current one returns a retryable error
+// (see core.IsRetryable); other errors are returned as is. A backend that
+// fails repeatedly is skipped for a cooldown window. When every backend is
+// cooling down they are all tried anyway rather than failing outright.
+type Fallback struct {
+	backends  []core.LLM
+	breakers  []breaker
+	cooldown  time.Duration
+	threshold int
+	retryable func(error) bool
+	onServe   func(ctx context.Context, s Served)
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+type breaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+// Option configures a Fallback.
+type Option func(*Fallback)
+
+// WithCooldown sets how long a tripped backend is skipped.
+func WithCooldown(d time.Duration) Option {
+	return func(f *Fallback) {
+		f.cooldown = d
+	}
+}
+
+// WithFailureThreshold sets the number of consecutive retryable failures
+// after which a backend is skipped for the cooldown.
+func WithFailureThreshold(n int) Option {
+	return func(f *Fallback) {
+		f.threshold = n
+	}
+}
+
+// WithRetryable replaces core.IsRetryable as the test for errors that
+// move a call to the next backend.
+func WithRetryable(fn func(error) bool) Option {
+	return func(f *Fallback) {
+		f.retryable = fn
+	}
+}
+
+// OnServe registers fn to be called with the backend that served each
+// successful call, so usage and cost can be attributed to it.
+func OnServe(fn func(ctx context.Context, s Served)) Option {
+	return func(f *Fallback) {
+		f.onServe = fn
+	}
+}
+
+// New creates a fallback chain over backends, in priority order.
+func New(backends []core.LLM, opts ...Option) *Fallback {
+	f := &Fallback{
+		backends:  backends,
+		breakers:  make([]breaker, len(backends)),
+		cooldown:  defaultCooldown,
+		threshold: defaultFailureThreshold,
+		retryable: core.IsRetryable,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Backends returns the backends in priority order.
+func (f *Fallback) Backends() []core.LLM {
+	return f.backends
+}
+
+// Generate produces a completion from the first backend that succeeds.
+func (f *Fallback) Generate(ctx context.Context, prompt string, opts ...core.Option) (string, error) {
+	var out string
+	err := f.try(ctx, func(llm core.LLM) (bool, error) {
+		var err error
+		out, err = llm.Generate(ctx, prompt, opts...)
+		return true, err
+	})
+	return out, err
+}
+
+// GenerateChat produces a chat completion from the first backend that
+// succeeds.
+func (f *Fallback) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	var out string
+	err := f.try(ctx, func(llm core.LLM) (bool, error) {
+		var err error
+		out, err = llm.GenerateChat(ctx, messages, opts...)
+		return true, err
+	})
+	return out, err
+}
+
+// GenerateWithTools runs a native tool calling request on the first
+// backend that implements core.ToolCallingLLM and succeeds.
+func (f *Fallback) GenerateWithTools(ctx context.Context, messages []core.Message, tools []core.ToolDefinition, opts ...core.Option) (*core.Response, error) {
+	var out *core.Response
+	err := f.try(ctx, func(llm core.LLM) (bool, error) {
+		caller, ok := llm.(core.ToolCallingLLM)
+		if !ok {
+			return false, nil
+		}
+		var err error
+		out, err = caller.GenerateWithTools(ctx, messages, tools, opts...)
+		return true, err
+	})
+	return out, err
+}
+
+// Stream fails over only while opening the stream. Once chunks flow, the
+// channel belongs to the backend that opened it.
+func (f *Fallback) Stream(ctx context.Context, prompt string, opts ...core.Option) (<-chan string, error) {
+	var out <-chan string
+	err := f.try(ctx, func(llm core.LLM) (bool, error) {
+		var err error
+		out, err = llm.Stream(ctx, prompt, opts...)
+		return true, err
+	})
+	return out, err
+}
+
+// StreamChat streams a chat completion. Backends that implement
+// core.EventStreamer can also fail over when the stream errors before its
+// first chunk; see StreamChatEvents.
+func (f *Fallback) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	events, err := f.StreamChatEvents(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return core.StreamText(ctx, events), nil
+}
+
+// StreamChatEvents streams a chat completion as events. A backend whose
+// stream fails with a retryable error before producing any content is
+// abandoned for the next one; errors after the first chunk end the stream.
+// Backends without core.EventStreamer fail over only while opening.
+func (f *Fallback) StreamChatEvents(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan core.StreamEvent, error) {
+	var out <-chan core.StreamEvent
+	err := f.try(ctx, func(llm core.LLM) (bool, error) {
+		streamer, ok := llm.(core.EventStreamer)
+		if !ok {
+			chunks, err := llm.StreamChat(ctx, messages, opts...)
+			if err != nil {
+				return true, err
+			}
+			out = textEvents(ctx, chunks)
+			return true, nil
+		}
+
+		events, err := streamer.StreamChatEvents(ctx, messages, opts...)
+		if err != nil {
+			return true, err
+		}
+		first, ok := <-events
+		if !ok {
+			first = core.StreamEvent{Done: true}
+		}
+		if first.Err != nil {
+			return true, first.Err
+		}
+		out = prepend(ctx, first, events)
+		return true, nil
+	})
+	return out, err
+}
+
+// try runs call on each available backend in turn. call reports false when
+// the backend cannot serve this kind of call.
+func (f *Fallback) try(ctx context.Context, call func(llm core.LLM) (bool, error)) error {
+	var errs []error
+	attempted := false
+	failovers := 0
+
+	for pass := 0; pass < 2 && !attempted; pass++ {
+		for i, llm := range f.backends {
+			// The first pass skips tripped backends; the second runs only
+			// when all of them were tripped.
+			if pass == 0 && f.tripped(i) {
+				continue
+			}
+			served, err := call(llm)
+			if !served {
+				continue
+			}
+			attempted = true
+			if err == nil {
+				f.succeeded(i)
+				f.report(ctx, i, failovers)
+				return nil
+			}
+			if ctx.Err() != nil || !f.retryable(err) {
+				return err
+			}
+			f.failed(i)
+			failovers++
+			errs = append(errs, fmt.Errorf("%s: %w", name(llm), err))
+		}
+	}
+
+	if !attempted {
+		return ErrNoBackends
+	}
+	return fmt.Errorf("fallback: all backends failed: %w", errors.Join(errs...))
+}
+
+func (f *Fallback) tripped(i int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now().Before(f.breakers[i].openUntil)
+}
+
+func (f *Fallback) succeeded(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.breakers[i] = breaker{}
+}
+
+func (f *Fallback) failed(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b := &f.breakers[i]
+	// Failures only reset on success, so a backend that still fails after
+	// its cooldown is tripped again straight away.
+	b.failures++
+	if b.failures >= f.threshold {
+		b.openUntil = f.now().Add(f.cooldown)
+	}
+}
+
+func (f *Fallback) report(ctx context.Context, i, failovers int) {
+	if f.onServe == nil {
+		return
+	}
+	s := Served{Index: i, Failovers: failovers}
+	if info, ok := f.backends[i].(core.ModelInfo); ok {
+		s.Provider = info.Provider()
+		s.Model = info.Model()
+	}
+	f.onServe(ctx, s)
+}
+
+// name identifies a backend in errors.
+func name(llm core.LLM) string {
+	if info, ok := llm.(core.ModelInfo); ok {
+		return info.Provider()
+	}
+	return fmt.Sprintf("%T", llm)
+}
+
+// textEvents adapts a plain text stream to events ending with Done.
+func textEvents(ctx context.Context, chunks <-chan string) <-chan core.StreamEvent {
+	events := make(chan core.StreamEvent)
+	go func() {
+		defer close(events)
+		for chunk := range chunks {
+			select {
+			case events <- core.StreamEvent{Content: chunk}:
+			case <-ctx.Done():
+				for range chunks {
+				}
+				return
+			}
+		}
+		select {
+		case events <- core.StreamEvent{Done: true}:
+		case <-ctx.Done():
+		}
+	}()
+	return events
+}
+
+// prepend re-emits first ahead of the rest of events.
+func prepend(ctx context.Context, first core.StreamEvent, events <-chan core.StreamEvent) <-chan core.StreamEvent {
+	out := make(chan core.StreamEvent)
+	go func() {
+		defer close(out)
+		defer func() {
+			for range events {
+			}
+		}()
+		select {
+		case out <- first:
+		case <-ctx.Done():
+			return
+		}
+		for ev := range events {
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,202 @@
+package fallback_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/llm/fallback"
+)
+
+// MockLLM answers with its name, or fails with err. streamErr is sent as
+// the first stream event instead of content.
+type MockLLM struct {
+	name      string
+	err       error
+	streamErr error
+	calls     int
+}
+
+func (m *MockLLM) Generate(ctx context.Context, prompt string, opts ...core.Option) (string, error) {
+	m.calls++
+	if m.err != nil {
+		return "", m.err
+	}
+	return m.name, nil
+}
+
+func (m *MockLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	return m.Generate(ctx, "", opts...)
+}
+
+func (m *MockLLM) Stream(ctx context.Context, prompt string, opts ...core.Option) (<-chan string, error) {
+	if _, err := m.Generate(ctx, prompt, opts...); err != nil {
+		return nil, err
+	}
+	ch := make(chan string, 1)
+	ch <- m.name
+	close(ch)
+	return ch, nil
+}
+
+func (m *MockLLM) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	return m.Stream(ctx, "", opts...)
+}
+
+func (m *MockLLM) Provider() string { return m.name }
+func (m *MockLLM) Model() string    { return m.name + "-model" }
+
+// EventLLM implements core.EventStreamer.
+type EventLLM struct{ MockLLM }
+
+func (m *EventLLM) StreamChatEvents(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan core.StreamEvent, error) {
+	if _, err := m.Generate(ctx, "", opts...); err != nil {
+		return nil, err
+	}
+	ch := make(chan core.StreamEvent, 3)
+	if m.streamErr != nil {
+		ch <- core.StreamEvent{Err: m.streamErr}
+	} else {
+		ch <- core.StreamEvent{Content: m.name}
+		ch <- core.StreamEvent{Content: "!"}
+		ch <- core.StreamEvent{Done: true}
+	}
+	close(ch)
+	return ch, nil
+}
+
+var (
+	overloaded = &core.APIError{Provider: "x", StatusCode: http.StatusServiceUnavailable, Message: "overloaded"}
+	rateLimit  = &core.APIError{Provider: "x", StatusCode: http.StatusTooManyRequests, Message: "slow down"}
+	badRequest = &core.APIError{Provider: "x", StatusCode: http.StatusBadRequest, Message: "bad prompt"}
+)
+
+func TestFallback_FailsOver(t *testing.T) {
+	primary := &MockLLM{name: "primary", err: rateLimit}
+	secondary := &MockLLM{name: "secondary"}
+	var served []fallback.Served
+	llm := fallback.New([]core.LLM{primary, secondary}, fallback.OnServe(func(ctx context.Context, s fallback.Served) {
+		served = append(served, s)
+	}))
+
+	out, err := llm.Generate(context.Background(), "hi")
+	if err != nil || out != "secondary" {
+		t.Fatalf("Expected secondary to answer, got %q, %v", out, err)
+	}
+	want := fallback.Served{Index: 1, Provider: "secondary", Model: "secondary-model", Failovers: 1}
+	if len(served) != 1 || served[0] != want {
+		t.Errorf("served = %+v, want %+v", served, want)
+	}
+
+	chunks, err := llm.Stream(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-chunks; got != "secondary" {
+		t.Errorf("Expected the stream from secondary, got %q", got)
+	}
+}
+
+func TestFallback_NonRetryableStops(t *testing.T) {
+	primary := &MockLLM{name: "primary", err: badRequest}
+	secondary := &MockLLM{name: "secondary"}
+	llm := fallback.New([]core.LLM{primary, secondary})
+
+	_, err := llm.GenerateChat(context.Background(), []core.Message{{Role: core.RoleUser, Content: "hi"}})
+	if !errors.Is(err, badRequest) || secondary.calls != 0 {
+		t.Errorf("Expected the 400 to be returned without failover, got %v (secondary calls %d)", err, secondary.calls)
+	}
+}
+
+func TestFallback_AllFail(t *testing.T) {
+	llm := fallback.New([]core.LLM{
+		&MockLLM{name: "a", err: overloaded},
+		&MockLLM{name: "b", err: context.DeadlineExceeded},
+	})
+	_, err := llm.Generate(context.Background(), "hi")
+	if err == nil || !errors.Is(err, overloaded) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected both errors, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "a:") || !strings.Contains(err.Error(), "b:") {
+		t.Errorf("Expected errors to name the backends: %v", err)
+	}
+}
+
+func TestFallback_CircuitBreaker(t *testing.T) {
+	primary := &MockLLM{name: "primary", err: overloaded}
+	secondary := &MockLLM{name: "secondary"}
+	llm := fallback.New([]core.LLM{primary, secondary},
+		fallback.WithFailureThreshold(2),
+		fallback.WithCooldown(50*time.Millisecond),
+	)
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		if _, err := llm.Generate(ctx, "hi"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if primary.calls != 2 {
+		t.Errorf("Expected primary to be skipped once tripped, got %d calls", primary.calls)
+	}
+
+	// After the cooldown the primary is tried again and serves once healthy.
+	time.Sleep(60 * time.Millisecond)
+	primary.err = nil
+	if out, _ := llm.Generate(ctx, "hi"); out != "primary" {
+		t.Errorf("Expected primary after cooldown, got %q", out)
+	}
+}
+
+func TestFallback_AllTrippedStillTried(t *testing.T) {
+	only := &MockLLM{name: "only", err: overloaded}
+	llm := fallback.New([]core.LLM{only}, fallback.WithFailureThreshold(1), fallback.WithCooldown(time.Hour))
+
+	llm.Generate(context.Background(), "hi")
+	only.err = nil
+	if out, err := llm.Generate(context.Background(), "hi"); err != nil || out != "only" {
+		t.Errorf("Expected a tripped sole backend to be tried, got %q, %v", out, err)
+	}
+}
+
+func TestFallback_StreamFailsOverBeforeFirstChunk(t *testing.T) {
+	primary := &EventLLM{MockLLM{name: "primary", streamErr: overloaded}}
+	secondary := &EventLLM{MockLLM{name: "secondary"}}
+	llm := fallback.New([]core.LLM{primary, secondary})
+
+	chunks, err := llm.StreamChat(context.Background(), []core.Message{{Role: core.RoleUser, Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	for chunk := range chunks {
+		sb.WriteString(chunk)
+	}
+	if sb.String() != "secondary!" {
+		t.Errorf("Expected the secondary stream, got %q", sb.String())
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{rateLimit, true},
+		{overloaded, true},
+		{badRequest, false},
+		{&core.APIError{Type: "overloaded_error"}, true},
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		if got := core.IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+const (
+	// defaultEmbeddingModel is used when no embedding model is configured.
+	defaultEmbeddingModel = "gemini-embedding-001"
+	// maxEmbeddingBatch is the most requests batchEmbedContents accepts.
+	maxEmbeddingBatch = 100
+)
+
+// Task types tell the model how an embedding will be used.
+const (
+	taskDocument = "RETRIEVAL_DOCUMENT"
+	taskQuery    = "RETRIEVAL_QUERY"
+)
+
+type embeddingConfig struct {
+	model      string
+	batchSize  int
+	dimensions int
+}
+
+// WithEmbeddingModel sets the model used by Embed and EmbedQuery.
+func WithEmbeddingModel(model string) Option {
+	return func(c *Client) {
+		c.embeddings.model = model
+	}
+}
+
+// WithEmbeddingBatchSize sets how many texts are sent per batch request.
+// Values outside 1-100 use the API maximum.
+func WithEmbeddingBatchSize(n int) Option {
+	return func(c *Client) {
+		c.embeddings.batchSize = n
+	}
+}
+
+// WithEmbeddingDimensions truncates the returned vectors to n dimensions.
+func WithEmbeddingDimensions(n int) Option {
+	return func(c *Client) {
+		c.embeddings.dimensions = n
+	}
+}
+
+type embedContentRequest struct {
+	Model                string  `json:"model"`
+	Content              content `json:"content"`
+	TaskType             string  `json:"taskType,omitempty"`
+	OutputDimensionality int     `json:"outputDimensionality,omitempty"`
+}
+
+type batchEmbedRequest struct {
+	Requests []embedContentRequest `json:"requests"`
+}
+
+type batchEmbedResponse struct {
+	Embeddings []struct {
+		Values []float32 `json:"values"`
+	} `json:"embeddings"`
+}
+
+// ============ Embedder Interface Implementation ============
+
+// Embed returns one document embedding per text, in input order. Inputs
+// larger than the batch size are split across several requests.
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return c.embed(ctx, texts, taskDocument)
+}
+
+// EmbedQuery embeds a search query with the RETRIEVAL_QUERY task type, so
+// it is comparable with document embeddings from Embed.
+func (c *Client) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	vectors, err := c.embed(ctx, []string{query}, taskQuery)
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+func (c *Client) embed(ctx context.Context, texts []string, task string) ([][]float32, error) {
+	batch := c.embeddings.batchSize
+	if batch <= 0 || batch > maxEmbeddingBatch {
+		batch = maxEmbeddingBatch
+	}
+
+	out := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batch {
+		end := min(start+batch, len(texts))
+		vectors, err := c.embedBatch(ctx, texts[start:end], task)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, vectors...)
+	}
+	return out, nil
+}
+
+// embedBatch sends one batchEmbedContents request. Embeddings come back in
+// request order.
+func (c *Client) embedBatch(ctx context.Context, texts []string, task string) ([][]float32, error) {
+	model := "models/" + c.embeddings.model
+	req := batchEmbedRequest{Requests: make([]embedContentRequest, len(texts))}
+	for i, text := range texts {
+		req.Requests[i] = embedContentRequest{
+			Model:                model,
+			Content:              content{Parts: []part{{Text: text}}},
+			TaskType:             task,
+			OutputDimensionality: c.embeddings.dimensions,
+		}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/%s:batchEmbedContents?key=%s", c.baseURL, model, c.apiKey)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		var errResp errorResponse
+		json.Unmarshal(respBody, &errResp)
+		return nil, &core.APIError{Provider: "gemini", StatusCode: resp.StatusCode, Type: errResp.Error.Status, Message: errResp.Error.Message}
+	}
+
+	var embResp batchEmbedResponse
+	if err := json.Unmarshal(respBody, &embResp); err != nil {
+		return nil, err
+	}
+	if len(embResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embResp.Embeddings))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, e := range embResp.Embeddings {
+		vectors[i] = e.Values
+	}
+	return vectors, nil
+}
@@ -5,23 +5,27 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/nuulab/goflow/internal/sse"
 	"github.com/nuulab/goflow/pkg/core"
 )
 
 const defaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
 
-// Client implements core.LLM for Google Gemini.
+// Client implements core.LLM and core.Embedder for Google Gemini.
 type Client struct {
 	apiKey     string
 	baseURL    string
 	model      string
 	httpClient *http.Client
+	embeddings embeddingConfig
 }
 
 // Option configures the Gemini client.
@@ -44,6 +48,10 @@ func New(apiKey string, opts ...Option) *Client {
 		httpClient: &http.Client{
 			Timeout: 120 * time.Second,
 		},
+		embeddings: embeddingConfig{
+			model:     defaultEmbeddingModel,
+			batchSize: maxEmbeddingBatch,
+		},
 	}
 
 	for _, opt := range opts {
@@ -80,6 +88,17 @@ type generateRequest struct {
 	Contents         []content           `json:"contents"`
 	SystemInstruction *content           `json:"systemInstruction,omitempty"`
 	GenerationConfig *generationConfig   `json:"generationConfig,omitempty"`
+	Tools            []tool              `json:"tools,omitempty"`
+}
+
+type tool struct {
+	FunctionDeclarations []functionDeclaration `json:"functionDeclarations"`
+}
+
+type functionDeclaration struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
 }
 
 type content struct {
@@ -88,7 +107,19 @@ type content struct {
 }
 
 type part struct {
-	Text string `json:"text"`
+	Text             string            `json:"text,omitempty"`
+	FunctionCall     *functionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *functionResponse `json:"functionResponse,omitempty"`
+}
+
+type functionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type functionResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
 }
 
 type generationConfig struct {
@@ -96,23 +127,25 @@ type generationConfig struct {
 	TopP            *float64 `json:"topP,omitempty"`
 	MaxOutputTokens *int     `json:"maxOutputTokens,omitempty"`
 	StopSequences   []string `json:"stopSequences,omitempty"`
+	// ResponseMimeType is "application/json" in JSON mode.
+	ResponseMimeType string `json:"responseMimeType,omitempty"`
 }
 
 type generateResponse struct {
 	Candidates []struct {
 		Content struct {
-			Parts []struct {
-				Text string `json:"text"`
-			} `json:"parts"`
-			Role string `json:"role"`
+			Parts []part `json:"parts"`
+			Role  string `json:"role"`
 		} `json:"content"`
 		FinishReason string `json:"finishReason"`
 	} `json:"candidates"`
-	UsageMetadata struct {
-		PromptTokenCount     int `json:"promptTokenCount"`
-		CandidatesTokenCount int `json:"candidatesTokenCount"`
-		TotalTokenCount      int `json:"totalTokenCount"`
-	} `json:"usageMetadata"`
+	UsageMetadata usageMetadata `json:"usageMetadata"`
+}
+
+type usageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
 }
 
 type streamResponse struct {
@@ -124,6 +157,12 @@ type streamResponse struct {
 		} `json:"content"`
 		FinishReason string `json:"finishReason,omitempty"`
 	} `json:"candidates"`
+	// UsageMetadata is cumulative; the last chunk holds the totals.
+	UsageMetadata *usageMetadata `json:"usageMetadata,omitempty"`
+	Error         *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
 }
 
 type errorResponse struct {
@@ -145,40 +184,96 @@ func (c *Client) Generate(ctx context.Context, prompt string, opts ...core.Optio
 
 // GenerateChat produces a completion for a conversation.
 func (c *Client) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
-	options := &core.CallOptions{}
-	for _, opt := range opts {
-		opt(options)
+	genResp, err := c.complete(ctx, c.newGenerateRequest(messages, opts))
+	if err != nil {
+		return "", err
 	}
+	core.ReportUsage(opts, c.usage(genResp.UsageMetadata))
+	return genResp.Candidates[0].Content.Parts[0].Text, nil
+}
 
-	// Convert messages
-	var systemInstruction *content
-	var contents []content
-
-	for _, msg := range messages {
-		if msg.Role == core.RoleSystem {
-			systemInstruction = &content{
-				Parts: []part{{Text: msg.Content}},
+// GenerateWithTools produces a completion with the tools sent as function
+// declarations. functionCall parts are returned as tool calls in order.
+// Gemini does not identify calls, so IDs are assigned per response
+// ("call_1", "call_2", ...); results are matched back by tool name.
+func (c *Client) GenerateWithTools(ctx context.Context, messages []core.Message, tools []core.ToolDefinition, opts ...core.Option) (*core.Response, error) {
+	req := c.newGenerateRequest(messages, opts)
+	if len(tools) > 0 {
+		decls := make([]functionDeclaration, 0, len(tools))
+		for _, t := range tools {
+			params, err := convertSchema(t.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("gemini: tool %s: %w", t.Name, err)
 			}
-		} else {
-			role := "user"
-			if msg.Role == core.RoleAssistant {
-				role = "model"
+			decls = append(decls, functionDeclaration{Name: t.Name, Description: t.Description, Parameters: params})
+		}
+		req.Tools = []tool{{FunctionDeclarations: decls}}
+		// Gemini rejects JSON mode combined with function calling.
+		if req.GenerationConfig != nil {
+			req.GenerationConfig.ResponseMimeType = ""
+		}
+	}
+
+	genResp, err := c.complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	candidate := genResp.Candidates[0]
+	result := &core.Response{StopReason: candidate.FinishReason, Usage: c.usage(genResp.UsageMetadata)}
+	var text []string
+	for _, p := range candidate.Content.Parts {
+		if p.FunctionCall != nil {
+			args := string(p.FunctionCall.Args)
+			if args == "" {
+				args = "{}"
 			}
-			contents = append(contents, content{
-				Role:  role,
-				Parts: []part{{Text: msg.Content}},
+			result.ToolCalls = append(result.ToolCalls, core.ToolCall{
+				ID:        fmt.Sprintf("call_%d", len(result.ToolCalls)+1),
+				Name:      p.FunctionCall.Name,
+				Arguments: args,
 			})
+			continue
 		}
+		if p.Text != "" {
+			text = append(text, p.Text)
+		}
+	}
+	result.Content = strings.Join(text, "")
+	core.ReportUsage(opts, result.Usage)
+	return result, nil
+}
+
+// usage attributes reported token counts to this client's model.
+func (c *Client) usage(u usageMetadata) core.Usage {
+	return core.Usage{
+		Provider:         c.Provider(),
+		Model:            c.model,
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: u.CandidatesTokenCount,
+		TotalTokens:      u.TotalTokenCount,
+	}
+}
+
+func (c *Client) newGenerateRequest(messages []core.Message, opts []core.Option) generateRequest {
+	options := &core.CallOptions{}
+	for _, opt := range opts {
+		opt(options)
 	}
 
+	systemInstruction, contents := convertMessages(messages)
+
 	req := generateRequest{
 		Contents:          contents,
 		SystemInstruction: systemInstruction,
 	}
 
 	// Add generation config if any options set
-	if options.Temperature > 0 || options.MaxTokens > 0 || options.TopP > 0 || len(options.StopSequences) > 0 {
+	if options.Temperature > 0 || options.MaxTokens > 0 || options.TopP > 0 || len(options.StopSequences) > 0 || options.JSONResponse {
 		req.GenerationConfig = &generationConfig{}
+		if options.JSONResponse {
+			req.GenerationConfig.ResponseMimeType = "application/json"
+		}
 		if options.Temperature > 0 {
 			req.GenerationConfig.Temperature = &options.Temperature
 		}
@@ -192,74 +287,149 @@ func (c *Client) GenerateChat(ctx context.Context, messages []core.Message, opts
 			req.GenerationConfig.StopSequences = options.StopSequences
 		}
 	}
+	return req
+}
 
+// complete sends a generateContent request.
+func (c *Client) complete(ctx context.Context, req generateRequest) (*generateResponse, error) {
 	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s", c.baseURL, c.model, c.apiKey)
 
 	body, err := json.Marshal(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if resp.StatusCode >= 400 {
 		var errResp errorResponse
 		json.Unmarshal(respBody, &errResp)
-		return "", fmt.Errorf("Gemini API error (%d): %s", resp.StatusCode, errResp.Error.Message)
+		return nil, &core.APIError{Provider: "gemini", StatusCode: resp.StatusCode, Type: errResp.Error.Status, Message: errResp.Error.Message}
 	}
 
 	var genResp generateResponse
 	if err := json.Unmarshal(respBody, &genResp); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(genResp.Candidates) == 0 || len(genResp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no content returned")
+		return nil, fmt.Errorf("no content returned")
 	}
 
-	return genResp.Candidates[0].Content.Parts[0].Text, nil
+	return &genResp, nil
 }
 
-// Stream produces a streaming completion for the given prompt.
-func (c *Client) Stream(ctx context.Context, prompt string, opts ...core.Option) (<-chan string, error) {
-	return c.StreamChat(ctx, []core.Message{
-		{Role: core.RoleUser, Content: prompt},
-	}, opts...)
+// schemaKeys are the JSON schema keywords Gemini's Schema object accepts.
+var schemaKeys = map[string]bool{
+	"type": true, "format": true, "description": true, "nullable": true,
+	"enum": true, "properties": true, "required": true, "items": true,
 }
 
-// StreamChat produces a streaming completion for a conversation.
-func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
-	options := &core.CallOptions{}
-	for _, opt := range opts {
-		opt(options)
+// convertSchema converts a JSON schema, such as a tools.Schema, to Gemini's
+// schema format: type names are uppercased (STRING, OBJECT, ...) and
+// unsupported keywords are dropped.
+func convertSchema(schema any) (map[string]any, error) {
+	if schema == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("schema must be a JSON object: %w", err)
 	}
+	return geminiSchema(m), nil
+}
+
+func geminiSchema(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		if !schemaKeys[k] {
+			continue
+		}
+		switch k {
+		case "type":
+			if s, ok := v.(string); ok {
+				v = strings.ToUpper(s)
+			}
+		case "properties":
+			if props, ok := v.(map[string]any); ok {
+				converted := make(map[string]any, len(props))
+				for name, p := range props {
+					if pm, ok := p.(map[string]any); ok {
+						converted[name] = geminiSchema(pm)
+					}
+				}
+				v = converted
+			}
+		case "items":
+			if im, ok := v.(map[string]any); ok {
+				v = geminiSchema(im)
+			}
+		}
+		out[k] = v
+	}
+	if _, ok := out["enum"]; ok && out["type"] == "STRING" {
+		out["format"] = "enum"
+	}
+	return out
+}
 
-	// Convert messages
+// convertMessages maps core messages to Gemini contents. Assistant tool calls
+// become functionCall parts and tool results become functionResponse parts.
+func convertMessages(messages []core.Message) (*content, []content) {
 	var systemInstruction *content
 	var contents []content
 
 	for _, msg := range messages {
-		if msg.Role == core.RoleSystem {
+		switch {
+		case msg.Role == core.RoleSystem:
 			systemInstruction = &content{
 				Parts: []part{{Text: msg.Content}},
 			}
-		} else {
+
+		case msg.Role == core.RoleTool && msg.Name != "":
+			contents = append(contents, content{
+				Role: "user",
+				Parts: []part{{FunctionResponse: &functionResponse{
+					Name:     msg.Name,
+					Response: toolResponse(msg.Content),
+				}}},
+			})
+
+		case msg.Role == core.RoleAssistant && len(msg.ToolCalls) > 0:
+			var parts []part
+			if msg.Content != "" {
+				parts = append(parts, part{Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				args := json.RawMessage(tc.Arguments)
+				if !json.Valid(args) {
+					args = json.RawMessage("{}")
+				}
+				parts = append(parts, part{FunctionCall: &functionCall{Name: tc.Name, Args: args}})
+			}
+			contents = append(contents, content{Role: "model", Parts: parts})
+
+		default:
 			role := "user"
 			if msg.Role == core.RoleAssistant {
 				role = "model"
@@ -270,6 +440,46 @@ func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts .
 			})
 		}
 	}
+	return systemInstruction, contents
+}
+
+// toolResponse wraps a tool result as the JSON object Gemini expects.
+func toolResponse(result string) map[string]any {
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(result), &obj); err == nil && obj != nil {
+		return obj
+	}
+	return map[string]any{"result": result}
+}
+
+// Stream produces a streaming completion for the given prompt.
+func (c *Client) Stream(ctx context.Context, prompt string, opts ...core.Option) (<-chan string, error) {
+	return c.StreamChat(ctx, []core.Message{
+		{Role: core.RoleUser, Content: prompt},
+	}, opts...)
+}
+
+// StreamChat produces a streaming completion for a conversation. Text is
+// emitted as the API sends it; stream errors end the channel early, use
+// StreamChatEvents to observe them.
+func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	events, err := c.StreamChatEvents(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return core.StreamText(ctx, events), nil
+}
+
+// StreamChatEvents streams a completion as events using the SSE variant of
+// streamGenerateContent. Errors reported mid-stream, malformed chunks and
+// streams cut off before a finish reason end the stream with an error event.
+func (c *Client) StreamChatEvents(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan core.StreamEvent, error) {
+	options := &core.CallOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	systemInstruction, contents := convertMessages(messages)
 
 	req := generateRequest{
 		Contents:          contents,
@@ -286,7 +496,7 @@ func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts .
 		}
 	}
 
-	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?key=%s", c.baseURL, c.model, c.apiKey)
+	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse&key=%s", c.baseURL, c.model, c.apiKey)
 
 	body, err := json.Marshal(req)
 	if err != nil {
@@ -310,43 +520,114 @@ func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts .
 		respBody, _ := io.ReadAll(resp.Body)
 		var errResp errorResponse
 		json.Unmarshal(respBody, &errResp)
-		return nil, fmt.Errorf("Gemini API error (%d): %s", resp.StatusCode, errResp.Error.Message)
+		return nil, &core.APIError{Provider: "gemini", StatusCode: resp.StatusCode, Type: errResp.Error.Status, Message: errResp.Error.Message}
 	}
 
-	ch := make(chan string)
+	events := make(chan core.StreamEvent)
 
 	go func() {
-		defer close(ch)
+		defer close(events)
 		defer resp.Body.Close()
 
-		// Read entire response body
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return
+		send := func(ev core.StreamEvent) bool {
+			select {
+			case events <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
 		}
-
-		// Parse JSON array of stream responses
-		var streamResps []streamResponse
-		if err := json.Unmarshal(respBody, &streamResps); err != nil {
-			return
+		fail := func(err error) {
+			if ctx.Err() == nil {
+				send(core.StreamEvent{Err: err})
+			}
 		}
 
-		// Emit each chunk
-		for _, streamResp := range streamResps {
-			select {
-			case <-ctx.Done():
+		var usage *usageMetadata
+		finished := false
+		reader := sse.NewReader(resp.Body)
+		for {
+			ev, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				if !finished {
+					fail(fmt.Errorf("gemini: stream ended before a finish reason: %w", io.ErrUnexpectedEOF))
+					return
+				}
+				if usage != nil && options.OnUsage != nil {
+					options.OnUsage(c.usage(*usage))
+				}
+				send(core.StreamEvent{Done: true})
+				return
+			}
+			if err != nil {
+				fail(fmt.Errorf("gemini: reading stream: %w", err))
+				return
+			}
+
+			var chunk streamResponse
+			if err := json.Unmarshal([]byte(ev.Data), &chunk); err != nil {
+				fail(fmt.Errorf("gemini: invalid stream chunk: %w", err))
+				return
+			}
+			if chunk.Error != nil {
+				fail(&core.APIError{Provider: "gemini", StatusCode: chunk.Error.Code, Message: chunk.Error.Message})
 				return
-			default:
 			}
 
-			if len(streamResp.Candidates) > 0 && len(streamResp.Candidates[0].Content.Parts) > 0 {
-				text := streamResp.Candidates[0].Content.Parts[0].Text
-				if text != "" {
-					ch <- text
+			if len(chunk.Candidates) > 0 {
+				candidate := chunk.Candidates[0]
+				for _, p := range candidate.Content.Parts {
+					if p.Text != "" && !send(core.StreamEvent{Content: p.Text}) {
+						return
+					}
 				}
+				if candidate.FinishReason != "" {
+					finished = true
+				}
+			}
+			if chunk.UsageMetadata != nil {
+				usage = chunk.UsageMetadata
 			}
 		}
 	}()
 
-	return ch, nil
+	return events, nil
+}
+
+// SupportsToolMessages reports false: Gemini expects function declarations
+// alongside functionCall history, which only GenerateWithTools sends.
+// Tool messages built by callers are still mapped to functionResponse parts.
+func (c *Client) SupportsToolMessages() bool { return false }
+
+// ============ Health ============
+
+// Provider returns the provider name.
+func (c *Client) Provider() string { return "gemini" }
+
+// Model returns the configured model.
+func (c *Client) Model() string { return c.model }
+
+// Ping verifies the API key and model by retrieving the model metadata.
+func (c *Client) Ping(ctx context.Context) error {
+	url := fmt.Sprintf("%s/models/%s?key=%s", c.baseURL, c.model, c.apiKey)
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		var errResp errorResponse
+		json.Unmarshal(respBody, &errResp)
+		return &core.APIError{Provider: "gemini", StatusCode: resp.StatusCode, Type: errResp.Error.Status, Message: errResp.Error.Message}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
 }
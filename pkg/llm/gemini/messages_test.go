@@ -0,0 +1,372 @@
+// Package gemini_test provides request fixture tests for the Gemini provider.
+package gemini_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/llm/gemini"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestGemini_ToolMessages(t *testing.T) {
+	var captured []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"It is sunny."}]}}]}`))
+	}))
+	defer server.Close()
+
+	client := gemini.New("test", gemini.WithBaseURL(server.URL))
+	_, err := client.GenerateChat(context.Background(), []core.Message{
+		{Role: core.RoleSystem, Content: "sys"},
+		{Role: core.RoleUser, Content: "Weather in Paris?"},
+		{Role: core.RoleAssistant, ToolCalls: []core.ToolCall{
+			{ID: "call_1", Name: "weather", Arguments: `{"city":"Paris"}`},
+		}},
+		{Role: core.RoleTool, Name: "weather", ToolCallID: "call_1", Content: "sunny"},
+		{Role: core.RoleTool, Name: "forecast", ToolCallID: "call_2", Content: `{"high":21}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req struct {
+		Contents []struct {
+			Role  string `json:"role"`
+			Parts []struct {
+				Text         string `json:"text"`
+				FunctionCall *struct {
+					Name string         `json:"name"`
+					Args map[string]any `json:"args"`
+				} `json:"functionCall"`
+				FunctionResponse *struct {
+					Name     string         `json:"name"`
+					Response map[string]any `json:"response"`
+				} `json:"functionResponse"`
+			} `json:"parts"`
+		} `json:"contents"`
+	}
+	if err := json.Unmarshal(captured, &req); err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Contents) != 4 {
+		t.Fatalf("Expected 4 contents, got %s", captured)
+	}
+
+	call := req.Contents[1]
+	if call.Role != "model" || len(call.Parts) != 1 || call.Parts[0].FunctionCall == nil {
+		t.Fatalf("Expected model functionCall, got %+v", call)
+	}
+	if fc := call.Parts[0].FunctionCall; fc.Name != "weather" || fc.Args["city"] != "Paris" {
+		t.Errorf("Unexpected functionCall: %+v", fc)
+	}
+
+	text := req.Contents[2].Parts[0].FunctionResponse
+	if req.Contents[2].Role != "user" || text == nil || text.Name != "weather" || text.Response["result"] != "sunny" {
+		t.Errorf("Expected wrapped text result, got %s", captured)
+	}
+	obj := req.Contents[3].Parts[0].FunctionResponse
+	if obj == nil || obj.Name != "forecast" || obj.Response["high"] != float64(21) {
+		t.Errorf("Expected JSON object result passed through, got %s", captured)
+	}
+}
+
+func TestGemini_GenerateWithTools(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&captured)
+		w.Write([]byte(`{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[
+			{"functionCall":{"name":"weather","args":{"city":"Paris"}}},
+			{"functionCall":{"name":"weather","args":{"city":"Rome"}}}
+		]}}]}`))
+	}))
+	defer server.Close()
+
+	registry := tools.NewRegistry()
+	registry.Register(tools.Build("weather").
+		Description("Current weather").
+		Param("city", "string", "City name").
+		EnumParam("unit", "Temperature unit", "celsius", "fahrenheit").
+		Handler(func(ctx context.Context, input string) (string, error) { return "sunny", nil }).
+		Create())
+
+	client := gemini.New("test", gemini.WithBaseURL(server.URL))
+	resp, err := client.GenerateWithTools(context.Background(), []core.Message{
+		{Role: core.RoleUser, Content: "Weather in Paris and Rome?"},
+	}, registry.ToolDefinitions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.ToolCalls) != 2 || resp.StopReason != "STOP" {
+		t.Fatalf("Unexpected response: %+v", resp)
+	}
+	if tc := resp.ToolCalls[1]; tc.ID != "call_2" || tc.Name != "weather" || tc.Arguments != `{"city":"Rome"}` {
+		t.Errorf("Unexpected tool call: %+v", tc)
+	}
+
+	decls := captured["tools"].([]any)[0].(map[string]any)["functionDeclarations"].([]any)
+	decl := decls[0].(map[string]any)
+	params := decl["parameters"].(map[string]any)
+	props := params["properties"].(map[string]any)
+	if decl["name"] != "weather" || params["type"] != "OBJECT" {
+		t.Errorf("Unexpected declaration: %v", decl)
+	}
+	if city := props["city"].(map[string]any); city["type"] != "STRING" || city["description"] != "City name" {
+		t.Errorf("Unexpected city schema: %v", city)
+	}
+	unit := props["unit"].(map[string]any)
+	if unit["format"] != "enum" || len(unit["enum"].([]any)) != 2 {
+		t.Errorf("Unexpected enum schema: %v", unit)
+	}
+}
+
+func TestGemini_AgentToolCalling(t *testing.T) {
+	var requests []map[string]any
+	replies := []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"weather","args":{"city":"Paris"}}}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Sunny in Paris."}]}}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		w.Write([]byte(replies[len(requests)-1]))
+	}))
+	defer server.Close()
+
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{
+		Name:        "weather",
+		Description: "Current weather",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			return "sunny", nil
+		},
+	})
+
+	a := agent.New(gemini.New("test", gemini.WithBaseURL(server.URL)), registry)
+	result, err := a.Run(context.Background(), "Weather in Paris?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Output != "Sunny in Paris." {
+		t.Errorf("Unexpected output: %q", result.Output)
+	}
+
+	contents := requests[1]["contents"].([]any)
+	call := contents[len(contents)-2].(map[string]any)["parts"].([]any)[0].(map[string]any)
+	if call["functionCall"].(map[string]any)["name"] != "weather" {
+		t.Errorf("Expected functionCall history, got %v", call)
+	}
+	last := contents[len(contents)-1].(map[string]any)["parts"].([]any)[0].(map[string]any)
+	fr, _ := last["functionResponse"].(map[string]any)
+	if fr == nil || fr["name"] != "weather" || fr["response"].(map[string]any)["result"] != "sunny" {
+		t.Errorf("Expected functionResponse, got %v", last)
+	}
+}
+
+func TestGemini_Usage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "streamGenerateContent") {
+			w.Write([]byte("data: " + `{"candidates":[{"content":{"parts":[{"text":"h"}]}}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":1,"totalTokenCount":6}}` + "\r\n\r\n" +
+				"data: " + `{"candidates":[{"content":{"parts":[{"text":"i"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":2,"totalTokenCount":7}}` + "\r\n\r\n"))
+			return
+		}
+		w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"hi"}]}}],"usageMetadata":{"promptTokenCount":11,"candidatesTokenCount":2,"totalTokenCount":13}}`))
+	}))
+	defer server.Close()
+
+	client := gemini.New("test", gemini.WithBaseURL(server.URL))
+	var usage []core.Usage
+	record := core.WithUsageCallback(func(u core.Usage) { usage = append(usage, u) })
+
+	if _, err := client.Generate(context.Background(), "hello", record); err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := client.Stream(context.Background(), "hello", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range chunks {
+	}
+
+	if len(usage) != 2 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	if u := usage[0]; u.Provider != "gemini" || u.PromptTokens != 11 || u.CompletionTokens != 2 || u.TotalTokens != 13 {
+		t.Errorf("generate usage = %+v", u)
+	}
+	if u := usage[1]; u.PromptTokens != 5 || u.CompletionTokens != 2 || u.TotalTokens != 7 {
+		t.Errorf("stream usage = %+v", u)
+	}
+}
+
+func TestGemini_StreamIncremental(t *testing.T) {
+	release := make(chan struct{})
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`data: {"candidates":[{"content":{"parts":[{"text":"Hel"}]}}]}` + "\n\n"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte(`data: {"candidates":[{"content":{"parts":[{"text":"lo"}]},"finishReason":"STOP"}]}` + "\n\n"))
+	}))
+	defer server.Close()
+
+	client := gemini.New("test", gemini.WithBaseURL(server.URL))
+	chunks, err := client.Stream(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first chunk must arrive while the server is still generating.
+	select {
+	case first := <-chunks:
+		if first != "Hel" {
+			t.Errorf("first chunk = %q", first)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("first chunk was not delivered before the response finished")
+	}
+	close(release)
+	if rest := <-chunks; rest != "lo" {
+		t.Errorf("second chunk = %q", rest)
+	}
+	if _, ok := <-chunks; ok {
+		t.Error("expected the stream to be closed")
+	}
+	if !strings.Contains(query, "alt=sse") {
+		t.Errorf("query = %q, want alt=sse", query)
+	}
+}
+
+func TestGemini_StreamErrors(t *testing.T) {
+	tests := map[string]string{
+		"api error": `data: {"candidates":[{"content":{"parts":[{"text":"a"}]}}]}` + "\n\n" +
+			`data: {"error":{"code":429,"message":"quota exceeded"}}` + "\n\n",
+		"malformed": "data: {not json\n\n",
+		"truncated": `data: {"candidates":[{"content":{"parts":[{"text":"a"}]}}]}` + "\n\n",
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(body))
+			}))
+			defer server.Close()
+
+			client := gemini.New("test", gemini.WithBaseURL(server.URL))
+			events, err := client.StreamChatEvents(context.Background(), []core.Message{{Role: core.RoleUser, Content: "hi"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var last core.StreamEvent
+			for ev := range events {
+				last = ev
+			}
+			if last.Err == nil || last.Done {
+				t.Errorf("expected an error event, got %+v", last)
+			}
+		})
+	}
+}
+
+func TestGemini_StreamCancel(t *testing.T) {
+	closed := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`data: {"candidates":[{"content":{"parts":[{"text":"a"}]}}]}` + "\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(closed)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := gemini.New("test", gemini.WithBaseURL(server.URL))
+	chunks, err := client.Stream(ctx, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-chunks
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		for range chunks {
+		}
+		close(done)
+	}()
+	for _, ch := range []chan struct{}{done, closed} {
+		select {
+		case <-ch:
+		case <-time.After(2 * time.Second):
+			t.Fatal("stream did not stop after cancellation")
+		}
+	}
+}
+
+func TestGemini_Embed(t *testing.T) {
+	var sizes []int
+	var tasks []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-embedding-001:batchEmbedContents" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		var req struct {
+			Requests []struct {
+				Model   string `json:"model"`
+				Content struct {
+					Parts []struct {
+						Text string `json:"text"`
+					} `json:"parts"`
+				} `json:"content"`
+				TaskType string `json:"taskType"`
+			} `json:"requests"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		sizes = append(sizes, len(req.Requests))
+
+		type embedding struct {
+			Values []float32 `json:"values"`
+		}
+		var embeddings []embedding
+		for _, r := range req.Requests {
+			tasks = append(tasks, r.TaskType)
+			embeddings = append(embeddings, embedding{Values: []float32{float32(len(r.Content.Parts[0].Text))}})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": embeddings})
+	}))
+	defer server.Close()
+
+	var embedder core.Embedder = gemini.New("test", gemini.WithBaseURL(server.URL), gemini.WithEmbeddingBatchSize(2))
+
+	texts := []string{"a", "bb", "ccc"}
+	vectors, err := embedder.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 2 || sizes[0] != 2 || sizes[1] != 1 {
+		t.Errorf("Expected batches of 2 and 1, got %v", sizes)
+	}
+	for i, v := range vectors {
+		if int(v[0]) != len(texts[i]) {
+			t.Errorf("vectors[%d] belongs to another input: %v", i, v)
+		}
+	}
+
+	if _, err := embedder.EmbedQuery(context.Background(), "query"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tasks, ","); got != "RETRIEVAL_DOCUMENT,RETRIEVAL_DOCUMENT,RETRIEVAL_DOCUMENT,RETRIEVAL_QUERY" {
+		t.Errorf("Unexpected task types: %s", got)
+	}
+}
@@ -0,0 +1,150 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+const (
+	// defaultEmbeddingModel is used when no embedding model is configured.
+	defaultEmbeddingModel = "text-embedding-3-small"
+	// maxEmbeddingBatch is the most inputs the API accepts per request.
+	maxEmbeddingBatch = 2048
+)
+
+type embeddingConfig struct {
+	model      string
+	batchSize  int
+	dimensions int
+}
+
+// WithEmbeddingModel sets the model used by Embed, such as
+// "text-embedding-3-large".
+func WithEmbeddingModel(model string) Option {
+	return func(c *Client) {
+		c.embeddings.model = model
+	}
+}
+
+// WithEmbeddingBatchSize sets how many texts are sent per embeddings
+// request. Values outside 1-2048 use the API maximum.
+func WithEmbeddingBatchSize(n int) Option {
+	return func(c *Client) {
+		c.embeddings.batchSize = n
+	}
+}
+
+// WithEmbeddingDimensions shortens the returned vectors to n dimensions.
+// Only the text-embedding-3 models support it.
+func WithEmbeddingDimensions(n int) Option {
+	return func(c *Client) {
+		c.embeddings.dimensions = n
+	}
+}
+
+type embeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// ============ Embedder Interface Implementation ============
+
+// Embed returns one embedding per text, in input order. Inputs larger than
+// the batch size are split across several requests.
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	batch := c.embeddings.batchSize
+	if batch <= 0 || batch > maxEmbeddingBatch {
+		batch = maxEmbeddingBatch
+	}
+
+	out := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batch {
+		end := min(start+batch, len(texts))
+		vectors, err := c.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, vectors...)
+	}
+	return out, nil
+}
+
+// EmbedQuery embeds a single search query. OpenAI embeds queries and
+// documents the same way.
+func (c *Client) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	vectors, err := c.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// embedBatch sends one embeddings request and orders the results by their
+// input index.
+func (c *Client) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(embeddingRequest{
+		Model:      c.embeddings.model,
+		Input:      texts,
+		Dimensions: c.embeddings.dimensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		var errResp errorResponse
+		json.Unmarshal(respBody, &errResp)
+		return nil, &core.APIError{Provider: "openai", StatusCode: resp.StatusCode, Type: errResp.Error.Type, Message: errResp.Error.Message}
+	}
+
+	var embResp embeddingResponse
+	if err := json.Unmarshal(respBody, &embResp); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range embResp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("no embedding returned for input %d", i)
+		}
+	}
+	return vectors, nil
+}
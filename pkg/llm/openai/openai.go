@@ -5,23 +5,31 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"time"
 
+	"github.com/nuulab/goflow/internal/httpclient"
+	"github.com/nuulab/goflow/internal/sse"
 	"github.com/nuulab/goflow/pkg/core"
 )
 
+// defaultMaxRetries is how many times failed requests are retried.
+const defaultMaxRetries = 2
+
 const defaultBaseURL = "https://api.openai.com/v1"
 
-// Client implements core.LLM for OpenAI.
+// Client implements core.LLM and core.Embedder for OpenAI.
 type Client struct {
 	apiKey     string
 	baseURL    string
 	model      string
 	httpClient *http.Client
+	retry      httpclient.Config
+	embeddings embeddingConfig
 }
 
 // Option configures the OpenAI client.
@@ -41,6 +49,17 @@ func New(apiKey string, opts ...Option) *Client {
 		httpClient: &http.Client{
 			Timeout: 120 * time.Second,
 		},
+		retry: httpclient.Config{
+			MaxRetries:           defaultMaxRetries,
+			BaseDelay:            500 * time.Millisecond,
+			MaxDelay:             30 * time.Second,
+			MaxRetryAfter:        httpclient.DefaultMaxRetryAfter,
+			RetryableStatusCodes: httpclient.DefaultConfig().RetryableStatusCodes,
+		},
+		embeddings: embeddingConfig{
+			model:     defaultEmbeddingModel,
+			batchSize: maxEmbeddingBatch,
+		},
 	}
 
 	for _, opt := range opts {
@@ -71,6 +90,32 @@ func WithTimeout(d time.Duration) Option {
 	}
 }
 
+// WithMaxRetries sets how many times a request failing with a rate limit,
+// server error or dropped connection is retried. Zero disables retries.
+func WithMaxRetries(n int) Option {
+	return func(c *Client) {
+		c.retry.MaxRetries = n
+	}
+}
+
+// WithRetryBackoff sets the delay before the first retry, doubled on each
+// further retry up to max. A longer Retry-After from the server wins, up
+// to the limit set with WithMaxRetryAfter.
+func WithRetryBackoff(initial, max time.Duration) Option {
+	return func(c *Client) {
+		c.retry.BaseDelay = initial
+		c.retry.MaxDelay = max
+	}
+}
+
+// WithMaxRetryAfter caps how long a server's Retry-After can make a retry
+// wait (default 1m). A longer Retry-After is cut to d.
+func WithMaxRetryAfter(d time.Duration) Option {
+	return func(c *Client) {
+		c.retry.MaxRetryAfter = d
+	}
+}
+
 // ============ Request/Response Types ============
 
 type chatRequest struct {
@@ -81,11 +126,51 @@ type chatRequest struct {
 	TopP        *float64      `json:"top_p,omitempty"`
 	Stop        []string      `json:"stop,omitempty"`
 	Stream      bool          `json:"stream,omitempty"`
+	Tools       []chatTool    `json:"tools,omitempty"`
+	// ParallelToolCalls is disabled when tools are sent: the agent executes
+	// one tool call per step.
+	ParallelToolCalls *bool `json:"parallel_tool_calls,omitempty"`
+	// StreamOptions requests a final usage chunk on streams.
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+	// ResponseFormat enables JSON mode.
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+}
+
+type responseFormat struct {
+	Type string `json:"type"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatTool struct {
+	Type     string       `json:"type"`
+	Function toolFunction `json:"function"`
+}
+
+type toolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters"`
 }
 
 type chatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+}
+
+type toolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function functionCall `json:"function"`
+}
+
+type functionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 type chatResponse struct {
@@ -96,16 +181,19 @@ type chatResponse struct {
 	Choices []struct {
 		Index   int `json:"index"`
 		Message struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
+			Role      string     `json:"role"`
+			Content   string     `json:"content"`
+			ToolCalls []toolCall `json:"tool_calls"`
 		} `json:"message"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage chatUsage `json:"usage"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 type streamChunk struct {
@@ -115,6 +203,14 @@ type streamChunk struct {
 		} `json:"delta"`
 		FinishReason *string `json:"finish_reason"`
 	} `json:"choices"`
+	// Usage is only set on the final chunk when stream_options.include_usage
+	// was requested.
+	Usage *chatUsage `json:"usage"`
+	// Error is set when the API fails after the stream started.
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
 }
 
 type errorResponse struct {
@@ -136,23 +232,76 @@ func (c *Client) Generate(ctx context.Context, prompt string, opts ...core.Optio
 
 // GenerateChat produces a completion for a conversation.
 func (c *Client) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	resp, err := c.complete(ctx, c.newChatRequest(messages, opts))
+	if err != nil {
+		return "", err
+	}
+	core.ReportUsage(opts, c.usage(resp.Usage))
+	return resp.Choices[0].Message.Content, nil
+}
+
+// GenerateWithTools produces a completion with tools passed natively and
+// returns the tool calls requested by the model. At most one tool call is
+// requested per response.
+func (c *Client) GenerateWithTools(ctx context.Context, messages []core.Message, tools []core.ToolDefinition, opts ...core.Option) (*core.Response, error) {
+	req := c.newChatRequest(messages, opts)
+	for _, tool := range tools {
+		req.Tools = append(req.Tools, chatTool{
+			Type: "function",
+			Function: toolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	if len(req.Tools) > 0 {
+		parallel := false
+		req.ParallelToolCalls = &parallel
+	}
+
+	resp, err := c.complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := resp.Choices[0].Message
+	result := &core.Response{
+		Content:    msg.Content,
+		StopReason: resp.Choices[0].FinishReason,
+		Usage:      c.usage(resp.Usage),
+	}
+	for _, tc := range msg.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, core.ToolCall{
+			ID:        tc.ID,
+			Name:      tc.Function.Name,
+			Arguments: tc.Function.Arguments,
+		})
+	}
+	core.ReportUsage(opts, result.Usage)
+	return result, nil
+}
+
+// usage attributes reported token counts to this client's model.
+func (c *Client) usage(u chatUsage) core.Usage {
+	return core.Usage{
+		Provider:         c.Provider(),
+		Model:            c.model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
+func (c *Client) newChatRequest(messages []core.Message, opts []core.Option) chatRequest {
 	options := &core.CallOptions{}
 	for _, opt := range opts {
 		opt(options)
 	}
 
-	// Convert messages
-	chatMessages := make([]chatMessage, len(messages))
-	for i, msg := range messages {
-		chatMessages[i] = chatMessage{
-			Role:    string(msg.Role),
-			Content: msg.Content,
-		}
-	}
-
 	req := chatRequest{
 		Model:    c.model,
-		Messages: chatMessages,
+		Messages: convertMessages(messages),
 	}
 
 	if options.Temperature > 0 {
@@ -167,47 +316,83 @@ func (c *Client) GenerateChat(ctx context.Context, messages []core.Message, opts
 	if len(options.StopSequences) > 0 {
 		req.Stop = options.StopSequences
 	}
+	if options.JSONResponse {
+		req.ResponseFormat = &responseFormat{Type: "json_object"}
+	}
+	return req
+}
+
+// do sends a request, retrying rate limits and transient failures. The
+// retries happen before any of the response is read, so streams are only
+// retried until they start.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	return httpclient.NewWithHTTPClient(c.retry, c.httpClient).Do(req.Context(), req)
+}
 
+// complete sends a non-streaming chat completion request.
+func (c *Client) complete(ctx context.Context, req chatRequest) (*chatResponse, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewReader(body))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if resp.StatusCode >= 400 {
 		var errResp errorResponse
 		json.Unmarshal(respBody, &errResp)
-		return "", fmt.Errorf("OpenAI API error (%d): %s", resp.StatusCode, errResp.Error.Message)
+		return nil, &core.APIError{Provider: "openai", StatusCode: resp.StatusCode, Type: errResp.Error.Type, Message: errResp.Error.Message}
 	}
 
 	var chatResp chatResponse
 	if err := json.Unmarshal(respBody, &chatResp); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("no choices returned")
+		return nil, fmt.Errorf("no choices returned")
 	}
 
-	return chatResp.Choices[0].Message.Content, nil
+	return &chatResp, nil
+}
+
+// convertMessages maps core messages to OpenAI chat messages, including
+// assistant tool calls and role=tool results.
+func convertMessages(messages []core.Message) []chatMessage {
+	chatMessages := make([]chatMessage, len(messages))
+	for i, msg := range messages {
+		cm := chatMessage{
+			Role:       string(msg.Role),
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, tc := range msg.ToolCalls {
+			cm.ToolCalls = append(cm.ToolCalls, toolCall{
+				ID:       tc.ID,
+				Type:     "function",
+				Function: functionCall{Name: tc.Name, Arguments: tc.Arguments},
+			})
+		}
+		chatMessages[i] = cm
+	}
+	return chatMessages
 }
 
 // Stream produces a streaming completion for the given prompt.
@@ -217,21 +402,27 @@ func (c *Client) Stream(ctx context.Context, prompt string, opts ...core.Option)
 	}, opts...)
 }
 
-// StreamChat produces a streaming completion for a conversation.
+// StreamChat produces a streaming completion for a conversation. The
+// channel closes at the end of the stream or on error; use
+// StreamChatEvents to observe errors.
 func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	events, err := c.StreamChatEvents(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return core.StreamText(ctx, events), nil
+}
+
+// StreamChatEvents streams a completion as events. Errors reported by the
+// API mid-stream, malformed chunks and streams cut off before "[DONE]" end
+// the stream with an error event.
+func (c *Client) StreamChatEvents(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan core.StreamEvent, error) {
 	options := &core.CallOptions{}
 	for _, opt := range opts {
 		opt(options)
 	}
 
-	// Convert messages
-	chatMessages := make([]chatMessage, len(messages))
-	for i, msg := range messages {
-		chatMessages[i] = chatMessage{
-			Role:    string(msg.Role),
-			Content: msg.Content,
-		}
-	}
+	chatMessages := convertMessages(messages)
 
 	req := chatRequest{
 		Model:    c.model,
@@ -245,6 +436,9 @@ func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts .
 	if options.MaxTokens > 0 {
 		req.MaxTokens = &options.MaxTokens
 	}
+	if options.OnUsage != nil {
+		req.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
 
 	body, err := json.Marshal(req)
 	if err != nil {
@@ -259,7 +453,7 @@ func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts .
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, err
 	}
@@ -269,63 +463,101 @@ func (c *Client) StreamChat(ctx context.Context, messages []core.Message, opts .
 		respBody, _ := io.ReadAll(resp.Body)
 		var errResp errorResponse
 		json.Unmarshal(respBody, &errResp)
-		return nil, fmt.Errorf("OpenAI API error (%d): %s", resp.StatusCode, errResp.Error.Message)
+		return nil, &core.APIError{Provider: "openai", StatusCode: resp.StatusCode, Type: errResp.Error.Type, Message: errResp.Error.Message}
 	}
 
-	ch := make(chan string)
+	events := make(chan core.StreamEvent)
 
 	go func() {
-		defer close(ch)
+		defer close(events)
 		defer resp.Body.Close()
 
-		decoder := json.NewDecoder(resp.Body)
-		for {
+		send := func(ev core.StreamEvent) bool {
 			select {
+			case events <- ev:
+				return true
 			case <-ctx.Done():
-				return
-			default:
+				return false
 			}
-
-			// Read SSE line
-			var line []byte
-			for {
-				b := make([]byte, 1)
-				_, err := resp.Body.Read(b)
-				if err != nil {
-					return
-				}
-				if b[0] == '\n' {
-					break
-				}
-				line = append(line, b[0])
+		}
+		fail := func(err error) {
+			if ctx.Err() == nil {
+				send(core.StreamEvent{Err: err})
 			}
+		}
 
-			lineStr := string(line)
-			if lineStr == "" || lineStr == "data: [DONE]" {
-				continue
+		reader := sse.NewReader(resp.Body)
+		for {
+			ev, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				fail(fmt.Errorf("openai: stream ended before [DONE]: %w", io.ErrUnexpectedEOF))
+				return
+			}
+			if err != nil {
+				fail(fmt.Errorf("openai: reading stream: %w", err))
+				return
+			}
+			if ev.Data == sse.Done {
+				send(core.StreamEvent{Done: true})
+				return
 			}
 
-			// Parse SSE data
-			if len(lineStr) > 6 && lineStr[:6] == "data: " {
-				var chunk streamChunk
-				if err := json.Unmarshal([]byte(lineStr[6:]), &chunk); err != nil {
-					continue
-				}
-
-				if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-					ch <- chunk.Choices[0].Delta.Content
-				}
+			var chunk streamChunk
+			if err := json.Unmarshal([]byte(ev.Data), &chunk); err != nil {
+				fail(fmt.Errorf("openai: invalid stream chunk: %w", err))
+				return
+			}
+			if chunk.Error != nil {
+				fail(&core.APIError{Provider: "openai", Type: chunk.Error.Type, Message: chunk.Error.Message})
+				return
+			}
 
-				if len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason != nil {
+			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+				if !send(core.StreamEvent{Content: chunk.Choices[0].Delta.Content}) {
 					return
 				}
 			}
-
-			// Reset decoder for next chunk
-			decoder = json.NewDecoder(resp.Body)
-			_ = decoder
+			// With include_usage the usage chunk follows the finish reason.
+			if chunk.Usage != nil && options.OnUsage != nil {
+				options.OnUsage(c.usage(*chunk.Usage))
+			}
 		}
 	}()
 
-	return ch, nil
+	return events, nil
+}
+
+// SupportsToolMessages reports that OpenAI accepts role=tool messages.
+func (c *Client) SupportsToolMessages() bool { return true }
+
+// ============ Health ============
+
+// Provider returns the provider name.
+func (c *Client) Provider() string { return "openai" }
+
+// Model returns the configured model.
+func (c *Client) Model() string { return c.model }
+
+// Ping verifies the API key and model by retrieving the model metadata.
+func (c *Client) Ping(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/models/"+c.model, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		var errResp errorResponse
+		json.Unmarshal(respBody, &errResp)
+		return &core.APIError{Provider: "openai", StatusCode: resp.StatusCode, Type: errResp.Error.Type, Message: errResp.Error.Message}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
 }
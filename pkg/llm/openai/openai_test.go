@@ -0,0 +1,432 @@
+// Package openai_test provides request fixture tests for the OpenAI provider.
+package openai_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/llm/openai"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestOpenAI_ToolMessages(t *testing.T) {
+	var captured []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"It is sunny."}}]}`))
+	}))
+	defer server.Close()
+
+	client := openai.New("test", openai.WithBaseURL(server.URL))
+	if !client.SupportsToolMessages() {
+		t.Fatal("Expected OpenAI to support tool messages")
+	}
+
+	_, err := client.GenerateChat(context.Background(), []core.Message{
+		{Role: core.RoleSystem, Content: "sys"},
+		{Role: core.RoleUser, Content: "Weather in Paris?"},
+		{Role: core.RoleAssistant, ToolCalls: []core.ToolCall{
+			{ID: "call_1", Name: "weather", Arguments: `{"city":"Paris"}`},
+		}},
+		{Role: core.RoleTool, Name: "weather", ToolCallID: "call_1", Content: "sunny"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req struct {
+		Messages []struct {
+			Role       string `json:"role"`
+			Content    string `json:"content"`
+			ToolCallID string `json:"tool_call_id"`
+			ToolCalls  []struct {
+				ID       string `json:"id"`
+				Type     string `json:"type"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(captured, &req); err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Messages) != 4 {
+		t.Fatalf("Expected 4 messages, got %s", captured)
+	}
+
+	call := req.Messages[2]
+	if call.Role != "assistant" || len(call.ToolCalls) != 1 {
+		t.Fatalf("Expected assistant tool call, got %+v", call)
+	}
+	tc := call.ToolCalls[0]
+	if tc.ID != "call_1" || tc.Type != "function" || tc.Function.Name != "weather" || tc.Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("Unexpected tool call: %+v", tc)
+	}
+
+	result := req.Messages[3]
+	if result.Role != "tool" || result.ToolCallID != "call_1" || result.Content != "sunny" {
+		t.Errorf("Unexpected tool result: %+v", result)
+	}
+}
+
+func TestOpenAI_GenerateWithTools(t *testing.T) {
+	var captured []map[string]any
+	replies := []string{
+		`{"choices":[{"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_x1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}`,
+		`{"choices":[{"message":{"role":"assistant","content":"It is sunny in Paris."}}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		captured = append(captured, req)
+		w.Write([]byte(replies[len(captured)-1]))
+	}))
+	defer server.Close()
+
+	registry := tools.NewRegistry()
+	registry.Register(tools.NewTool("weather", "Current weather",
+		func(ctx context.Context, in struct {
+			City string `json:"city"`
+		}) (string, error) {
+			return "sunny in " + in.City, nil
+		}))
+
+	a := agent.New(openai.New("test", openai.WithBaseURL(server.URL)), registry)
+	result, err := a.Run(context.Background(), "Weather in Paris?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Output != "It is sunny in Paris." || result.Steps[0].Observation != `"sunny in Paris"` {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+
+	first := captured[0]
+	if first["parallel_tool_calls"] != false {
+		t.Errorf("Expected parallel tool calls disabled, got %v", first["parallel_tool_calls"])
+	}
+	defs, _ := first["tools"].([]any)
+	if len(defs) != 1 {
+		t.Fatalf("Expected 1 tool, got %v", first["tools"])
+	}
+	fn := defs[0].(map[string]any)["function"].(map[string]any)
+	params := fn["parameters"].(map[string]any)
+	if fn["name"] != "weather" || params["type"] != "object" || params["properties"] == nil {
+		t.Errorf("Unexpected tool definition: %v", fn)
+	}
+
+	msgs := captured[1]["messages"].([]any)
+	last := msgs[len(msgs)-1].(map[string]any)
+	if last["role"] != "tool" || last["tool_call_id"] != "call_x1" {
+		t.Errorf("Expected tool result for call_x1, got %v", last)
+	}
+}
+
+func TestOpenAI_JSONResponse(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&captured)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"label\":\"spam\"}"}}]}`))
+	}))
+	defer server.Close()
+
+	client := openai.New("test", openai.WithBaseURL(server.URL))
+	out, err := client.GenerateChat(context.Background(), []core.Message{{Role: core.RoleUser, Content: "Reply in JSON"}}, core.WithJSONResponse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != `{"label":"spam"}` {
+		t.Errorf("Unexpected output: %s", out)
+	}
+	format, _ := captured["response_format"].(map[string]any)
+	if format["type"] != "json_object" {
+		t.Errorf("Expected JSON mode, got %v", captured["response_format"])
+	}
+}
+
+func TestOpenAI_Usage(t *testing.T) {
+	var streamOpts []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Stream        bool            `json:"stream"`
+			StreamOptions json.RawMessage `json:"stream_options"`
+		}
+		json.Unmarshal(body, &req)
+		if !req.Stream {
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"}}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`))
+			return
+		}
+		streamOpts = req.StreamOptions
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n" +
+			"data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+			"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":1,\"total_tokens\":8}}\n\n" +
+			"data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	client := openai.New("test", openai.WithBaseURL(server.URL), openai.WithModel("gpt-4o-mini"))
+	var usage []core.Usage
+	record := core.WithUsageCallback(func(u core.Usage) { usage = append(usage, u) })
+
+	if _, err := client.Generate(context.Background(), "hello", record); err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := client.Stream(context.Background(), "hello", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range chunks {
+	}
+
+	if string(streamOpts) != `{"include_usage":true}` {
+		t.Errorf("stream_options = %s", streamOpts)
+	}
+	want := []core.Usage{
+		{Provider: "openai", Model: "gpt-4o-mini", PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15},
+		{Provider: "openai", Model: "gpt-4o-mini", PromptTokens: 7, CompletionTokens: 1, TotalTokens: 8},
+	}
+	if len(usage) != len(want) {
+		t.Fatalf("usage = %+v", usage)
+	}
+	for i := range want {
+		if usage[i] != want[i] {
+			t.Errorf("usage[%d] = %+v, want %+v", i, usage[i], want[i])
+		}
+	}
+}
+
+// sseServer writes the stream in the given pieces, flushing after each.
+func sseServer(pieces ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, p := range pieces {
+			w.Write([]byte(p))
+			w.(http.Flusher).Flush()
+		}
+	}))
+}
+
+func TestOpenAI_StreamChunkBoundaries(t *testing.T) {
+	// "é" (0xC3 0xA9) and the CRLF line endings are split across flushes.
+	server := sseServer(
+		"data: {\"choices\":[{\"delta\":{\"content\":\"caf\xc3",
+		"\xa9\"}}]}\r",
+		"\n\r\ndata: {\"choices\":[{\"delta\":{\"content\":\" ok\"},\"finish_reason\":\"stop\"}]}\r\n\r\n",
+		"data: [DONE]\r\n\r\n",
+	)
+	defer server.Close()
+
+	client := openai.New("test", openai.WithBaseURL(server.URL))
+	chunks, err := client.Stream(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	for chunk := range chunks {
+		got += chunk
+	}
+	if got != "café ok" {
+		t.Errorf("Expected %q, got %q", "café ok", got)
+	}
+}
+
+func TestOpenAI_StreamErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   string
+	}{
+		{"api error", "data: {\"choices\":[{\"delta\":{\"content\":\"par\"}}]}\n\ndata: {\"error\":{\"message\":\"server overloaded\"}}\n\n", "server overloaded"},
+		{"cut off", "data: {\"choices\":[{\"delta\":{\"content\":\"par\"}}]}\n\n", "before [DONE]"},
+		{"malformed", "data: {\"choices\":\n\n", "invalid stream chunk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := sseServer(tt.stream)
+			defer server.Close()
+
+			client := openai.New("test", openai.WithBaseURL(server.URL))
+			events, err := client.StreamChatEvents(context.Background(), []core.Message{{Role: core.RoleUser, Content: "hi"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var last core.StreamEvent
+			for ev := range events {
+				last = ev
+			}
+			if last.Err == nil || !strings.Contains(last.Err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %+v", tt.want, last)
+			}
+		})
+	}
+}
+
+func TestOpenAI_Retry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int // responses before a success; the last repeats
+		retries  int
+		attempts int
+		wantErr  int
+	}{
+		{"rate limited", []int{429, 429}, 2, 3, 0},
+		{"gives up", []int{503}, 1, 2, 503},
+		{"not retryable", []int{400}, 2, 1, 400},
+		{"disabled", []int{500}, 0, 1, 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			var bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+				attempts++
+				if attempts <= len(tt.statuses) || tt.wantErr != 0 {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(tt.statuses[min(attempts, len(tt.statuses))-1])
+					w.Write([]byte(`{"error":{"message":"try later"}}`))
+					return
+				}
+				w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+			}))
+			defer server.Close()
+
+			client := openai.New("test", openai.WithBaseURL(server.URL),
+				openai.WithMaxRetries(tt.retries), openai.WithRetryBackoff(time.Millisecond, 10*time.Millisecond))
+			out, err := client.Generate(context.Background(), "hi")
+
+			if attempts != tt.attempts {
+				t.Errorf("Expected %d attempts, got %d", tt.attempts, attempts)
+			}
+			var apiErr *core.APIError
+			switch {
+			case tt.wantErr == 0 && (err != nil || out != "ok"):
+				t.Fatalf("Expected success, got %q, %v", out, err)
+			case tt.wantErr != 0 && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantErr || apiErr.Message != "try later"):
+				t.Fatalf("Expected the %d API error, got %v", tt.wantErr, err)
+			}
+			for _, b := range bodies {
+				if b != bodies[0] || b == "" {
+					t.Errorf("Retried request body differs: %q vs %q", b, bodies[0])
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAI_RetryAfterPastDeadline(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := openai.New("test", openai.WithBaseURL(server.URL))
+
+	start := time.Now()
+	_, err := client.StreamChat(ctx, []core.Message{{Role: core.RoleUser, Content: "hi"}})
+	if !core.IsRetryable(err) || attempts != 1 {
+		t.Fatalf("Expected the 429 without retrying, got %v after %d attempts", err, attempts)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Waited for a Retry-After beyond the deadline")
+	}
+}
+
+func TestOpenAI_RetryAfterCapped(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	client := openai.New("test", openai.WithBaseURL(server.URL),
+		openai.WithRetryBackoff(time.Millisecond, 10*time.Millisecond),
+		openai.WithMaxRetryAfter(10*time.Millisecond))
+
+	start := time.Now()
+	out, err := client.Generate(context.Background(), "hi")
+	if err != nil || out != "ok" || attempts != 2 {
+		t.Fatalf("Expected a retry after the capped wait, got %q, %v after %d attempts", out, err, attempts)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Waited for the full Retry-After")
+	}
+}
+
+func TestOpenAI_Embed(t *testing.T) {
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		var req struct {
+			Model      string   `json:"model"`
+			Input      []string `json:"input"`
+			Dimensions int      `json:"dimensions"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "text-embedding-3-large" || req.Dimensions != 2 {
+			t.Errorf("Unexpected request: %+v", req)
+		}
+		batches = append(batches, req.Input)
+
+		// Answer in reverse to check results are put back in input order.
+		type datum struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		var data []datum
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			data = append(data, datum{Index: i, Embedding: []float32{float32(len(req.Input[i])), 0}})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer server.Close()
+
+	var embedder core.Embedder = openai.New("test",
+		openai.WithBaseURL(server.URL),
+		openai.WithEmbeddingModel("text-embedding-3-large"),
+		openai.WithEmbeddingDimensions(2),
+		openai.WithEmbeddingBatchSize(2),
+	)
+
+	texts := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+	vectors, err := embedder.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 3 || len(batches[2]) != 1 {
+		t.Errorf("Expected batches of 2, 2 and 1, got %v", batches)
+	}
+	if len(vectors) != len(texts) {
+		t.Fatalf("Expected %d vectors, got %d", len(texts), len(vectors))
+	}
+	for i, v := range vectors {
+		if int(v[0]) != len(texts[i]) {
+			t.Errorf("vectors[%d] belongs to another input: %v", i, v)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Package router provides an LLM that routes each call to a fast or a smart
+// model.
+package router
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+// defaultTokenThreshold is the estimated prompt size above which calls are
+// escalated to the smart model.
+const defaultTokenThreshold = 4000
+
+// Reason explains why a tier was chosen.
+type Reason string
+
+const (
+	// ReasonHint means the caller passed core.WithModelTier.
+	ReasonHint Reason = "hint"
+	// ReasonTools means the call carried tool schemas.
+	ReasonTools Reason = "tools"
+	// ReasonTokens means the prompt exceeded the token threshold.
+	ReasonTokens Reason = "tokens"
+	// ReasonClassifier means the classifier prompt decided.
+	ReasonClassifier Reason = "classifier"
+	// ReasonDefault means no rule applied and the fast model was used.
+	ReasonDefault Reason = "default"
+)
+
+// Decision records the model that served a call. Provider and Model are
+// empty when the backend does not implement core.ModelInfo.
+type Decision struct {
+	Tier     core.ModelTier
+	Reason   Reason
+	Provider string
+	Model    string
+}
+
+const classifierPrompt = `Decide whether the request below needs a highly capable model (multi-step reasoning, planning, code, or careful judgement) or whether a fast, cheap model is enough.
+
+Request:
+%s
+
+Reply with exactly one word: SMART or FAST.`
+
+// Router implements core.LLM by delegating each call to a fast or a smart
+// model. The tier is chosen, in order, by an explicit core.WithModelTier
+// hint, by heuristics (tool schemas or a large prompt escalate to smart),
+// and by an optional classifier prompt run on the fast model. Calls that
+// match no rule go to the fast model.
+type Router struct {
+	fast           core.LLM
+	smart          core.LLM
+	tokenThreshold int
+	classify       bool
+	onRoute        func(ctx context.Context, d Decision)
+}
+
+// Option configures a Router.
+type Option func(*Router)
+
+// WithTokenThreshold sets the estimated prompt size in tokens above which
+// calls are escalated to the smart model. Zero disables the rule.
+func WithTokenThreshold(tokens int) Option {
+	return func(r *Router) {
+		r.tokenThreshold = tokens
+	}
+}
+
+// WithClassifier asks the fast model whether a call needs the smart model
+// when neither a hint nor a heuristic decided. Classifier failures escalate.
+func WithClassifier() Option {
+	return func(r *Router) {
+		r.classify = true
+	}
+}
+
+// OnRoute registers fn to be called with the decision for every call, so
+// usage and cost can be attributed to the model that actually served it.
+func OnRoute(fn func(ctx context.Context, d Decision)) Option {
+	return func(r *Router) {
+		r.onRoute = fn
+	}
+}
+
+// New creates a router over a fast and a smart model.
+func New(fast, smart core.LLM, opts ...Option) *Router {
+	r := &Router{
+		fast:           fast,
+		smart:          smart,
+		tokenThreshold: defaultTokenThreshold,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Generate routes a single-prompt completion.
+func (r *Router) Generate(ctx context.Context, prompt string, opts ...core.Option) (string, error) {
+	llm := r.route(ctx, prompt, false, opts)
+	return llm.Generate(ctx, prompt, opts...)
+}
+
+// GenerateChat routes a chat completion.
+func (r *Router) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	llm := r.route(ctx, joinMessages(messages), false, opts)
+	return llm.GenerateChat(ctx, messages, opts...)
+}
+
+// Stream routes a streaming completion.
+func (r *Router) Stream(ctx context.Context, prompt string, opts ...core.Option) (<-chan string, error) {
+	llm := r.route(ctx, prompt, false, opts)
+	return llm.Stream(ctx, prompt, opts...)
+}
+
+// StreamChat routes a streaming chat completion.
+func (r *Router) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	llm := r.route(ctx, joinMessages(messages), false, opts)
+	return llm.StreamChat(ctx, messages, opts...)
+}
+
+// GenerateWithTools routes a native tool calling request. Calls with tools
+// go to the smart model unless a hint says otherwise. It fails if the chosen
+// model does not implement core.ToolCallingLLM.
+func (r *Router) GenerateWithTools(ctx context.Context, messages []core.Message, tools []core.ToolDefinition, opts ...core.Option) (*core.Response, error) {
+	llm := r.route(ctx, joinMessages(messages), len(tools) > 0, opts)
+	caller, ok := llm.(core.ToolCallingLLM)
+	if !ok {
+		return nil, fmt.Errorf("router: %T does not support tool calling", llm)
+	}
+	return caller.GenerateWithTools(ctx, messages, tools, opts...)
+}
+
+// Backends returns the fast and smart models.
+func (r *Router) Backends() []core.LLM {
+	return []core.LLM{r.fast, r.smart}
+}
+
+// route picks the backend for a call and reports the decision.
+func (r *Router) route(ctx context.Context, prompt string, hasTools bool, opts []core.Option) core.LLM {
+	tier, reason := r.decide(ctx, prompt, hasTools, opts)
+	llm := r.fast
+	if tier == core.TierSmart {
+		llm = r.smart
+	}
+
+	if r.onRoute != nil {
+		d := Decision{Tier: tier, Reason: reason}
+		if info, ok := llm.(core.ModelInfo); ok {
+			d.Provider = info.Provider()
+			d.Model = info.Model()
+		}
+		r.onRoute(ctx, d)
+	}
+	return llm
+}
+
+func (r *Router) decide(ctx context.Context, prompt string, hasTools bool, opts []core.Option) (core.ModelTier, Reason) {
+	var options core.CallOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	switch {
+	case options.ModelTier == core.TierFast || options.ModelTier == core.TierSmart:
+		return options.ModelTier, ReasonHint
+	case hasTools:
+		return core.TierSmart, ReasonTools
+	case r.tokenThreshold > 0 && r.countTokens(ctx, prompt) > r.tokenThreshold:
+		return core.TierSmart, ReasonTokens
+	case r.classify:
+		return r.classifyPrompt(ctx, prompt), ReasonClassifier
+	}
+	return core.TierFast, ReasonDefault
+}
+
+// classifyPrompt asks the fast model whether prompt needs the smart model.
+func (r *Router) classifyPrompt(ctx context.Context, prompt string) core.ModelTier {
+	answer, err := r.fast.Generate(ctx, fmt.Sprintf(classifierPrompt, prompt), core.WithMaxTokens(5), core.WithTemperature(0))
+	if err != nil || strings.Contains(strings.ToUpper(answer), "SMART") {
+		return core.TierSmart
+	}
+	return core.TierFast
+}
+
+// countTokens uses the fast model's token counter when it has one and
+// falls back to a 4-characters-per-token estimate.
+func (r *Router) countTokens(ctx context.Context, text string) int {
+	if counter, ok := r.fast.(core.TokenCounter); ok {
+		if n, err := counter.CountTokens(ctx, text); err == nil {
+			return n
+		}
+	}
+	return len(text) / 4
+}
+
+func joinMessages(messages []core.Message) string {
+	var sb strings.Builder
+	for _, m := range messages {
+		sb.WriteString(string(m.Role))
+		sb.WriteString(": ")
+		sb.WriteString(m.Content)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,161 @@
+package router_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/llm/router"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// MockLLM answers every call with its name and records the prompts it saw.
+type MockLLM struct {
+	name     string
+	classify string // reply to classifier prompts
+	err      error
+	prompts  []string
+}
+
+func (m *MockLLM) Generate(ctx context.Context, prompt string, opts ...core.Option) (string, error) {
+	m.prompts = append(m.prompts, prompt)
+	if strings.Contains(prompt, "SMART or FAST") {
+		return m.classify, m.err
+	}
+	return m.name, nil
+}
+
+func (m *MockLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	return m.Generate(ctx, messages[len(messages)-1].Content, opts...)
+}
+
+func (m *MockLLM) Stream(ctx context.Context, prompt string, opts ...core.Option) (<-chan string, error) {
+	ch := make(chan string, 1)
+	ch <- m.name
+	close(ch)
+	return ch, nil
+}
+
+func (m *MockLLM) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	return m.Stream(ctx, "", opts...)
+}
+
+func (m *MockLLM) GenerateWithTools(ctx context.Context, messages []core.Message, defs []core.ToolDefinition, opts ...core.Option) (*core.Response, error) {
+	return &core.Response{Content: "Final answer from " + m.name}, nil
+}
+
+func (m *MockLLM) Provider() string { return "mock" }
+func (m *MockLLM) Model() string    { return m.name }
+
+func newRouter(opts ...router.Option) (*router.Router, *MockLLM, *MockLLM, *[]router.Decision) {
+	fast := &MockLLM{name: "fast"}
+	smart := &MockLLM{name: "smart"}
+	decisions := &[]router.Decision{}
+	opts = append(opts, router.OnRoute(func(ctx context.Context, d router.Decision) {
+		*decisions = append(*decisions, d)
+	}))
+	return router.New(fast, smart, opts...), fast, smart, decisions
+}
+
+func TestRouter_Hint(t *testing.T) {
+	r, _, _, decisions := newRouter()
+	ctx := context.Background()
+
+	got, err := r.Generate(ctx, "hello", core.WithModelTier(core.TierSmart))
+	if err != nil || got != "smart" {
+		t.Fatalf("smart hint: got %q, %v", got, err)
+	}
+	long := strings.Repeat("word ", 10000)
+	if got, _ := r.Generate(ctx, long, core.WithModelTier(core.TierFast)); got != "fast" {
+		t.Errorf("fast hint should override the token rule, got %q", got)
+	}
+	for _, d := range *decisions {
+		if d.Reason != router.ReasonHint {
+			t.Errorf("reason = %q, want hint", d.Reason)
+		}
+	}
+}
+
+func TestRouter_Heuristics(t *testing.T) {
+	r, _, _, decisions := newRouter(router.WithTokenThreshold(100))
+	ctx := context.Background()
+
+	if got, _ := r.Generate(ctx, "short question"); got != "fast" {
+		t.Errorf("short prompt routed to %q", got)
+	}
+	msgs := []core.Message{{Role: core.RoleUser, Content: strings.Repeat("a", 800)}}
+	if got, _ := r.GenerateChat(ctx, msgs); got != "smart" {
+		t.Errorf("long prompt routed to %q", got)
+	}
+	defs := []core.ToolDefinition{{Name: "search"}}
+	resp, err := r.GenerateWithTools(ctx, []core.Message{{Role: core.RoleUser, Content: "hi"}}, defs)
+	if err != nil || !strings.HasSuffix(resp.Content, "smart") {
+		t.Errorf("tool call routed to %v, %v", resp, err)
+	}
+
+	want := []router.Reason{router.ReasonDefault, router.ReasonTokens, router.ReasonTools}
+	if len(*decisions) != len(want) {
+		t.Fatalf("decisions = %+v", *decisions)
+	}
+	for i, d := range *decisions {
+		if d.Reason != want[i] {
+			t.Errorf("decision %d reason = %q, want %q", i, d.Reason, want[i])
+		}
+	}
+}
+
+func TestRouter_ClassifierEscalates(t *testing.T) {
+	r, fast, _, decisions := newRouter(router.WithClassifier())
+	ctx := context.Background()
+
+	fast.classify = "FAST"
+	if got, _ := r.Generate(ctx, "what is 2+2?"); got != "fast" {
+		t.Errorf("classifier FAST routed to %q", got)
+	}
+	fast.classify = "SMART"
+	if got, _ := r.Generate(ctx, "design a distributed lock"); got != "smart" {
+		t.Errorf("classifier SMART routed to %q", got)
+	}
+	fast.classify, fast.err = "", errors.New("unavailable")
+	if got, _ := r.Generate(ctx, "anything"); got != "smart" {
+		t.Errorf("classifier failure routed to %q, want escalation", got)
+	}
+
+	if len(*decisions) != 3 || (*decisions)[1].Reason != router.ReasonClassifier {
+		t.Errorf("decisions = %+v", *decisions)
+	}
+	if !strings.Contains(fast.prompts[2], "design a distributed lock") {
+		t.Errorf("classifier prompt missing request: %q", fast.prompts[2])
+	}
+}
+
+func TestRouter_DecisionAttributesModel(t *testing.T) {
+	r, _, _, decisions := newRouter()
+	r.Generate(context.Background(), "hi", core.WithModelTier(core.TierSmart))
+
+	d := (*decisions)[0]
+	if d.Tier != core.TierSmart || d.Provider != "mock" || d.Model != "smart" {
+		t.Errorf("decision = %+v", d)
+	}
+	if len(r.Backends()) != 2 {
+		t.Errorf("Backends() = %v", r.Backends())
+	}
+}
+
+func TestRouter_AgentUsesSmartForPlanning(t *testing.T) {
+	r, _, _, decisions := newRouter()
+	a := agent.New(r, tools.NewRegistry(), agent.WithToolCalling(false), agent.WithMaxIterations(1))
+	a.Run(context.Background(), "hi")
+
+	if len(*decisions) == 0 {
+		t.Fatal("agent made no routed calls")
+	}
+	for _, d := range *decisions {
+		if d.Tier != core.TierSmart || d.Reason != router.ReasonHint {
+			t.Errorf("agent call decision = %+v, want smart hint", d)
+		}
+	}
+}
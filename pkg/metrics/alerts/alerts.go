@@ -0,0 +1,610 @@
+// Package alerts evaluates threshold rules against in-process metrics and
+// sends firing and resolved notifications, for deployments that do not run
+// Prometheus and Alertmanager.
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/notify"
+)
+
+// Event names carried in alert notifications.
+const (
+	EventFiring   = "alert.firing"
+	EventResolved = "alert.resolved"
+)
+
+// DefaultRenotifyInterval is the minimum time between repeated
+// notifications for an alert that keeps firing.
+const DefaultRenotifyInterval = time.Hour
+
+var (
+	// ErrRuleExists is returned when adding a rule whose name is taken.
+	ErrRuleExists = errors.New("alerts: rule already exists")
+	// ErrRuleNotFound is returned for an unknown rule name.
+	ErrRuleNotFound = errors.New("alerts: rule not found")
+)
+
+// Aggregation reduces a metric's samples over the rule window to a value.
+type Aggregation string
+
+const (
+	AggregationLast     Aggregation = "last"     // the current value
+	AggregationAvg      Aggregation = "avg"      // mean of the samples
+	AggregationMin      Aggregation = "min"      // smallest sample
+	AggregationMax      Aggregation = "max"      // largest sample
+	AggregationIncrease Aggregation = "increase" // newest minus oldest sample
+	AggregationRate     Aggregation = "rate"     // increase per second
+)
+
+// Comparison is the operator a rule applies to its value and threshold.
+type Comparison string
+
+const (
+	GreaterThan    Comparison = ">"
+	GreaterOrEqual Comparison = ">="
+	LessThan       Comparison = "<"
+	LessOrEqual    Comparison = "<="
+	Equal          Comparison = "=="
+	NotEqual       Comparison = "!="
+)
+
+func (c Comparison) holds(value, threshold float64) bool {
+	switch c {
+	case GreaterThan:
+		return value > threshold
+	case GreaterOrEqual:
+		return value >= threshold
+	case LessThan:
+		return value < threshold
+	case LessOrEqual:
+		return value <= threshold
+	case Equal:
+		return value == threshold
+	case NotEqual:
+		return value != threshold
+	}
+	return false
+}
+
+// Rule fires when its aggregated metric compares true against Threshold
+// for at least For. In JSON, Window and For are duration strings such as
+// "5m".
+//
+//	{"name": "dlq", "metric": "goflow_jobs_dlq_total", "aggregation": "increase",
+//	 "window": "5m", "op": ">", "threshold": 100}
+type Rule struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Metric      string `json:"metric"`
+	// Divisor, if set, turns the rule into a ratio: the aggregated Metric
+	// divided by the Divisor aggregated the same way. A zero divisor gives
+	// a value of 0.
+	Divisor     string          `json:"divisor,omitempty"`
+	Aggregation Aggregation     `json:"aggregation,omitempty"` // default last
+	Window      time.Duration   `json:"window,omitempty"`
+	Op          Comparison      `json:"op"`
+	Threshold   float64         `json:"threshold"`
+	For         time.Duration   `json:"for,omitempty"`
+	Severity    notify.Severity `json:"severity,omitempty"` // default warning
+}
+
+type ruleJSON struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Metric      string          `json:"metric"`
+	Divisor     string          `json:"divisor,omitempty"`
+	Aggregation Aggregation     `json:"aggregation,omitempty"`
+	Window      string          `json:"window,omitempty"`
+	Op          Comparison      `json:"op"`
+	Threshold   float64         `json:"threshold"`
+	For         string          `json:"for,omitempty"`
+	Severity    notify.Severity `json:"severity,omitempty"`
+}
+
+// MarshalJSON encodes Window and For as duration strings.
+func (r Rule) MarshalJSON() ([]byte, error) {
+	out := ruleJSON{
+		Name:        r.Name,
+		Description: r.Description,
+		Metric:      r.Metric,
+		Divisor:     r.Divisor,
+		Aggregation: r.Aggregation,
+		Op:          r.Op,
+		Threshold:   r.Threshold,
+		Severity:    r.Severity,
+	}
+	if r.Window > 0 {
+		out.Window = r.Window.String()
+	}
+	if r.For > 0 {
+		out.For = r.For.String()
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes Window and For from duration strings.
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	var in ruleJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*r = Rule{
+		Name:        in.Name,
+		Description: in.Description,
+		Metric:      in.Metric,
+		Divisor:     in.Divisor,
+		Aggregation: in.Aggregation,
+		Op:          in.Op,
+		Threshold:   in.Threshold,
+		Severity:    in.Severity,
+	}
+	var err error
+	if r.Window, err = parseDuration("window", in.Window); err != nil {
+		return err
+	}
+	if r.For, err = parseDuration("for", in.For); err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseDuration(field, s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("alerts: invalid %s: %w", field, err)
+	}
+	return d, nil
+}
+
+// LoadRules reads a JSON array of rules from path.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("alerts: %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Source provides current metric values. *metrics.Metrics implements it.
+type Source interface {
+	Value(name string) (float64, bool)
+}
+
+// Clock provides the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// State is where a rule is in its alert lifecycle.
+type State string
+
+const (
+	StateInactive State = "inactive"
+	StatePending  State = "pending" // condition holds, For not yet elapsed
+	StateFiring   State = "firing"
+)
+
+// Alert is a firing or resolved notification, as delivered to listeners.
+type Alert struct {
+	Event     string    `json:"event"` // EventFiring or EventResolved
+	Rule      Rule      `json:"rule"`
+	Value     float64   `json:"value"`
+	StartedAt time.Time `json:"started_at"` // when the rule started firing
+	At        time.Time `json:"at"`
+	// Repeat is set on re-notifications of an alert that is still firing.
+	Repeat bool `json:"repeat,omitempty"`
+}
+
+// RuleStatus is a rule together with its current evaluation state.
+type RuleStatus struct {
+	Rule         Rule      `json:"rule"`
+	State        State     `json:"state"`
+	Value        float64   `json:"value"`
+	ActiveSince  time.Time `json:"active_since,omitempty"`
+	LastNotified time.Time `json:"last_notified,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+type sample struct {
+	at      time.Time
+	value   float64
+	divisor float64
+}
+
+type ruleState struct {
+	RuleStatus
+	samples []sample
+}
+
+// Manager evaluates rules against a Source.
+type Manager struct {
+	source    Source
+	notifier  notify.Notifier
+	clock     Clock
+	renotify  time.Duration
+	rules     map[string]*ruleState
+	listeners []func(ctx context.Context, a Alert)
+	mu        sync.Mutex
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithClock sets the clock used for windows, For durations and
+// re-notification.
+func WithClock(c Clock) Option {
+	return func(m *Manager) {
+		m.clock = c
+	}
+}
+
+// WithRenotifyInterval sets the minimum time between notifications for an
+// alert that keeps firing. A negative interval notifies only on firing and
+// resolving.
+func WithRenotifyInterval(d time.Duration) Option {
+	return func(m *Manager) {
+		m.renotify = d
+	}
+}
+
+// NewManager creates a manager reading from source. notifier may be nil
+// when alerts only go to listeners.
+func NewManager(source Source, notifier notify.Notifier, opts ...Option) *Manager {
+	m := &Manager{
+		source:   source,
+		notifier: notifier,
+		clock:    realClock{},
+		renotify: DefaultRenotifyInterval,
+		rules:    make(map[string]*ruleState),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Subscribe registers fn to receive every alert that is notified.
+func (m *Manager) Subscribe(fn func(ctx context.Context, a Alert)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// ============ Rules ============
+
+// Validate checks a rule and fills in defaults.
+func (m *Manager) Validate(rule *Rule) error {
+	var problems []string
+	if rule.Name == "" || strings.Contains(rule.Name, "/") {
+		problems = append(problems, "name is required and cannot contain '/'")
+	}
+	if _, ok := m.source.Value(rule.Metric); !ok {
+		problems = append(problems, fmt.Sprintf("unknown metric %q", rule.Metric))
+	}
+	if rule.Divisor != "" {
+		if _, ok := m.source.Value(rule.Divisor); !ok {
+			problems = append(problems, fmt.Sprintf("unknown divisor metric %q", rule.Divisor))
+		}
+	}
+
+	if rule.Aggregation == "" {
+		rule.Aggregation = AggregationLast
+	}
+	switch rule.Aggregation {
+	case AggregationLast:
+	case AggregationAvg, AggregationMin, AggregationMax, AggregationIncrease, AggregationRate:
+		if rule.Window <= 0 {
+			problems = append(problems, fmt.Sprintf("aggregation %q needs a window", rule.Aggregation))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown aggregation %q", rule.Aggregation))
+	}
+	if !rule.Op.valid() {
+		problems = append(problems, fmt.Sprintf("unknown comparison %q", rule.Op))
+	}
+	if rule.Window < 0 || rule.For < 0 {
+		problems = append(problems, "window and for cannot be negative")
+	}
+	if rule.Severity == "" {
+		rule.Severity = notify.SeverityWarning
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("alerts: invalid rule: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (c Comparison) valid() bool {
+	switch c {
+	case GreaterThan, GreaterOrEqual, LessThan, LessOrEqual, Equal, NotEqual:
+		return true
+	}
+	return false
+}
+
+// Add adds a rule. It fails with ErrRuleExists if the name is taken.
+func (m *Manager) Add(rule Rule) error {
+	if err := m.Validate(&rule); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.rules[rule.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrRuleExists, rule.Name)
+	}
+	m.rules[rule.Name] = newRuleState(rule)
+	return nil
+}
+
+// Put adds rule or replaces the rule with the same name. A replaced rule
+// starts over as inactive, without a resolved notification.
+func (m *Manager) Put(rule Rule) error {
+	if err := m.Validate(&rule); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules[rule.Name] = newRuleState(rule)
+	return nil
+}
+
+func newRuleState(rule Rule) *ruleState {
+	return &ruleState{RuleStatus: RuleStatus{Rule: rule, State: StateInactive}}
+}
+
+// Delete removes a rule.
+func (m *Manager) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.rules[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrRuleNotFound, name)
+	}
+	delete(m.rules, name)
+	return nil
+}
+
+// Get returns a rule's status.
+func (m *Manager) Get(name string) (RuleStatus, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rs, ok := m.rules[name]
+	if !ok {
+		return RuleStatus{}, false
+	}
+	return rs.RuleStatus, true
+}
+
+// List returns every rule's status, sorted by name.
+func (m *Manager) List() []RuleStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]RuleStatus, 0, len(m.rules))
+	for _, rs := range m.rules {
+		out = append(out, rs.RuleStatus)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Rule.Name < out[j].Rule.Name })
+	return out
+}
+
+// ============ Evaluation ============
+
+// Evaluate samples every rule's metrics once and sends the notifications
+// its transitions call for: one when a rule starts firing, repeats no more
+// often than the re-notify interval while it keeps firing, and one when it
+// resolves. It returns the notifier errors, which are also recorded as the
+// rules' LastError.
+func (m *Manager) Evaluate(ctx context.Context) error {
+	now := m.clock.Now()
+
+	m.mu.Lock()
+	var alerts []Alert
+	for _, rs := range m.rules {
+		if a, ok := m.evaluate(rs, now); ok {
+			alerts = append(alerts, a)
+		}
+	}
+	listeners := m.listeners
+	m.mu.Unlock()
+
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Rule.Name < alerts[j].Rule.Name })
+
+	var errs []error
+	for _, a := range alerts {
+		for _, fn := range listeners {
+			fn(ctx, a)
+		}
+		if m.notifier == nil {
+			continue
+		}
+		err := m.notifier.Notify(ctx, message(a))
+		m.mu.Lock()
+		if rs, ok := m.rules[a.Rule.Name]; ok {
+			rs.LastError = ""
+			if err != nil {
+				rs.LastError = err.Error()
+			}
+		}
+		m.mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", a.Rule.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// evaluate records a sample for rs and advances its state, returning the
+// alert to send, if any. m.mu must be held.
+func (m *Manager) evaluate(rs *ruleState, now time.Time) (Alert, bool) {
+	rule := rs.Rule
+	value, ok := m.source.Value(rule.Metric)
+	if !ok {
+		return Alert{}, false
+	}
+	s := sample{at: now, value: value}
+	if rule.Divisor != "" {
+		s.divisor, _ = m.source.Value(rule.Divisor)
+	}
+
+	// Keep the samples inside the window; a zero window keeps only this one.
+	start := now.Add(-rule.Window)
+	kept := rs.samples[:0]
+	for _, old := range rs.samples {
+		if !old.at.Before(start) && old.at.Before(now) {
+			kept = append(kept, old)
+		}
+	}
+	rs.samples = append(kept, s)
+
+	rs.Value = aggregate(rule, rs.samples, func(s sample) float64 { return s.value })
+	if rule.Divisor != "" {
+		divisor := aggregate(rule, rs.samples, func(s sample) float64 { return s.divisor })
+		if divisor == 0 {
+			rs.Value = 0
+		} else {
+			rs.Value /= divisor
+		}
+	}
+
+	alert := Alert{Rule: rule, Value: rs.Value, At: now}
+	if !rule.Op.holds(rs.Value, rule.Threshold) {
+		wasFiring := rs.State == StateFiring
+		alert.StartedAt = rs.ActiveSince
+		rs.State, rs.ActiveSince = StateInactive, time.Time{}
+		if !wasFiring {
+			return Alert{}, false
+		}
+		rs.LastNotified = now
+		alert.Event = EventResolved
+		return alert, true
+	}
+
+	switch rs.State {
+	case StateInactive:
+		rs.State, rs.ActiveSince = StatePending, now
+		fallthrough
+	case StatePending:
+		if now.Sub(rs.ActiveSince) < rule.For {
+			return Alert{}, false
+		}
+		rs.State = StateFiring
+	case StateFiring:
+		if m.renotify < 0 || now.Sub(rs.LastNotified) < m.renotify {
+			return Alert{}, false
+		}
+		alert.Repeat = true
+	}
+	rs.LastNotified = now
+	alert.Event = EventFiring
+	alert.StartedAt = rs.ActiveSince
+	return alert, true
+}
+
+// aggregate reduces samples, oldest first, with the rule's aggregation.
+func aggregate(rule Rule, samples []sample, value func(sample) float64) float64 {
+	last := value(samples[len(samples)-1])
+	switch rule.Aggregation {
+	case AggregationAvg:
+		sum := 0.0
+		for _, s := range samples {
+			sum += value(s)
+		}
+		return sum / float64(len(samples))
+	case AggregationMin, AggregationMax:
+		out := last
+		for _, s := range samples {
+			v := value(s)
+			if (rule.Aggregation == AggregationMin && v < out) || (rule.Aggregation == AggregationMax && v > out) {
+				out = v
+			}
+		}
+		return out
+	case AggregationIncrease, AggregationRate:
+		increase := last - value(samples[0])
+		if rule.Aggregation == AggregationIncrease {
+			return increase
+		}
+		return increase / rule.Window.Seconds()
+	}
+	return last
+}
+
+// message renders an alert as a notification.
+func message(a Alert) notify.Message {
+	rule := a.Rule
+	subject := rule.Metric
+	if rule.Divisor != "" {
+		subject += " / " + rule.Divisor
+	}
+	if rule.Aggregation != AggregationLast {
+		subject = fmt.Sprintf("%s of %s over %v", rule.Aggregation, subject, rule.Window)
+	}
+	value := strconv.FormatFloat(a.Value, 'g', 4, 64)
+	threshold := strconv.FormatFloat(rule.Threshold, 'g', -1, 64)
+
+	msg := notify.Message{
+		Severity: rule.Severity,
+		Fields: map[string]string{
+			"event":     a.Event,
+			"rule":      rule.Name,
+			"metric":    rule.Metric,
+			"value":     value,
+			"threshold": string(rule.Op) + " " + threshold,
+		},
+		Timestamp: a.At,
+	}
+	if a.Event == EventResolved {
+		msg.Title = "Resolved: " + rule.Name
+		msg.Body = fmt.Sprintf("%s is %s, no longer %s %s", subject, value, rule.Op, threshold)
+		msg.Severity = notify.SeverityInfo
+		return msg
+	}
+	msg.Title = "Firing: " + rule.Name
+	msg.Body = fmt.Sprintf("%s is %s (%s %s)", subject, value, rule.Op, threshold)
+	if rule.Description != "" {
+		msg.Body = rule.Description + "\n" + msg.Body
+	}
+	if a.Repeat {
+		msg.Fields["repeat"] = "true"
+		msg.Body += fmt.Sprintf("; firing since %s", a.StartedAt.Format(time.RFC3339))
+	}
+	return msg
+}
+
+// Start evaluates the rules every interval until ctx is done.
+func (m *Manager) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.Evaluate(ctx)
+			}
+		}
+	}()
+}
@@ -0,0 +1,220 @@
+// Package alerts_test provides tests for alert rule evaluation.
+package alerts_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/metrics"
+	"github.com/nuulab/goflow/pkg/metrics/alerts"
+	"github.com/nuulab/goflow/pkg/notify"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+type recordingNotifier struct {
+	messages []notify.Message
+	err      error
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, msg notify.Message) error {
+	r.messages = append(r.messages, msg)
+	return r.err
+}
+
+func (r *recordingNotifier) titles() string {
+	var titles []string
+	for _, m := range r.messages {
+		if m.Fields["repeat"] == "true" {
+			titles = append(titles, m.Title+" (repeat)")
+			continue
+		}
+		titles = append(titles, m.Title)
+	}
+	return strings.Join(titles, "|")
+}
+
+func TestManager_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	m := metrics.NewMetrics()
+	clock := workflow.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	notifier := &recordingNotifier{}
+	manager := alerts.NewManager(m, notifier, alerts.WithClock(clock), alerts.WithRenotifyInterval(5*time.Minute))
+
+	var events []string
+	manager.Subscribe(func(ctx context.Context, a alerts.Alert) {
+		events = append(events, a.Event)
+	})
+
+	err := manager.Add(alerts.Rule{
+		Name:      "queue-backlog",
+		Metric:    "goflow_queue_depth",
+		Op:        alerts.GreaterThan,
+		Threshold: 100,
+		For:       2 * time.Minute,
+		Severity:  notify.SeverityCritical,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		depth float64
+		state alerts.State
+		sent  string
+	}{
+		{50, alerts.StateInactive, ""},
+		{150, alerts.StatePending, ""},
+		{150, alerts.StatePending, ""},
+		{150, alerts.StateFiring, "Firing: queue-backlog"},
+		{150, alerts.StateFiring, ""}, // deduplicated
+		{150, alerts.StateFiring, ""},
+		{150, alerts.StateFiring, ""},
+		{150, alerts.StateFiring, ""},
+		{150, alerts.StateFiring, "Firing: queue-backlog (repeat)"}, // 5m after the first
+		{40, alerts.StateInactive, "Resolved: queue-backlog"},
+		{150, alerts.StatePending, ""},
+		{40, alerts.StateInactive, ""}, // never fired, so nothing to resolve
+	}
+
+	var want []string
+	for i, step := range steps {
+		m.QueueDepth.Set(step.depth)
+		if err := manager.Evaluate(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if step.sent != "" {
+			want = append(want, step.sent)
+		}
+		if got := notifier.titles(); got != strings.Join(want, "|") {
+			t.Fatalf("minute %d: notifications = %q, want %q", i, got, want)
+		}
+		if status, _ := manager.Get("queue-backlog"); status.State != step.state {
+			t.Fatalf("minute %d: state = %s, want %s", i, status.State, step.state)
+		}
+		clock.Advance(time.Minute)
+	}
+
+	first, resolved := notifier.messages[0], notifier.messages[2]
+	if first.Severity != notify.SeverityCritical || first.Fields["value"] != "150" || first.Fields["threshold"] != "> 100" {
+		t.Errorf("Unexpected firing message: %+v", first)
+	}
+	if resolved.Severity != notify.SeverityInfo || resolved.Fields["event"] != alerts.EventResolved {
+		t.Errorf("Unexpected resolved message: %+v", resolved)
+	}
+	if strings.Join(events, ",") != "alert.firing,alert.firing,alert.resolved" {
+		t.Errorf("Listener saw %v", events)
+	}
+}
+
+func TestManager_FailureRatio(t *testing.T) {
+	ctx := context.Background()
+	m := metrics.NewMetrics()
+	clock := workflow.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	notifier := &recordingNotifier{}
+	manager := alerts.NewManager(m, notifier, alerts.WithClock(clock))
+
+	err := manager.Add(alerts.Rule{
+		Name:        "workflow-failures",
+		Metric:      "goflow_workflows_failed_total",
+		Divisor:     "goflow_workflows_started_total",
+		Aggregation: alerts.AggregationIncrease,
+		Window:      5 * time.Minute,
+		Op:          alerts.GreaterThan,
+		Threshold:   0.1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No workflows yet: a zero divisor does not fire.
+	manager.Evaluate(ctx)
+
+	clock.Advance(time.Minute)
+	m.WorkflowsStarted.Add(10)
+	m.WorkflowsFailed.Add(2)
+	manager.Evaluate(ctx)
+
+	// Six minutes later the failures have left the window.
+	clock.Advance(6 * time.Minute)
+	m.WorkflowsStarted.Add(10)
+	manager.Evaluate(ctx)
+
+	if got := notifier.titles(); got != "Firing: workflow-failures|Resolved: workflow-failures" {
+		t.Fatalf("notifications = %q", got)
+	}
+	body := notifier.messages[0].Body
+	if !strings.Contains(body, "increase of goflow_workflows_failed_total / goflow_workflows_started_total over 5m0s is 0.2") {
+		t.Errorf("Unexpected body: %s", body)
+	}
+}
+
+func TestManager_NotifierError(t *testing.T) {
+	m := metrics.NewMetrics()
+	notifier := &recordingNotifier{err: errors.New("smtp down")}
+	manager := alerts.NewManager(m, notifier)
+	manager.Add(alerts.Rule{Name: "busy", Metric: "goflow_workers_busy", Op: alerts.GreaterOrEqual, Threshold: 0})
+
+	err := manager.Evaluate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "smtp down") {
+		t.Fatalf("Expected the notifier error, got %v", err)
+	}
+	if status, _ := manager.Get("busy"); status.LastError != "smtp down" || status.State != alerts.StateFiring {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+
+	// Delivery is not retried on every evaluation.
+	manager.Evaluate(context.Background())
+	if len(notifier.messages) != 1 {
+		t.Errorf("Expected one delivery attempt, got %d", len(notifier.messages))
+	}
+}
+
+func TestManager_Rules(t *testing.T) {
+	manager := alerts.NewManager(metrics.NewMetrics(), nil)
+
+	invalid := []alerts.Rule{
+		{Name: "", Metric: "goflow_queue_depth", Op: alerts.GreaterThan},
+		{Name: "x", Metric: "nope", Op: alerts.GreaterThan},
+		{Name: "x", Metric: "goflow_queue_depth", Op: "=>"},
+		{Name: "x", Metric: "goflow_jobs_dlq_total", Aggregation: alerts.AggregationRate, Op: alerts.GreaterThan},
+	}
+	for _, rule := range invalid {
+		if err := manager.Add(rule); err == nil {
+			t.Errorf("Expected %+v to be rejected", rule)
+		}
+	}
+
+	var rule alerts.Rule
+	if err := json.Unmarshal([]byte(`{"name":"dlq","metric":"goflow_jobs_dlq_total","aggregation":"increase","window":"5m","op":">","threshold":100,"for":"30s"}`), &rule); err != nil {
+		t.Fatal(err)
+	}
+	if rule.Window != 5*time.Minute || rule.For != 30*time.Second {
+		t.Errorf("Durations not parsed: %+v", rule)
+	}
+	if err := manager.Add(rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Add(rule); !errors.Is(err, alerts.ErrRuleExists) {
+		t.Errorf("Expected ErrRuleExists, got %v", err)
+	}
+
+	status, _ := manager.Get("dlq")
+	if status.Rule.Severity != notify.SeverityWarning || status.State != alerts.StateInactive {
+		t.Errorf("Unexpected defaults: %+v", status)
+	}
+	data, _ := json.Marshal(status.Rule)
+	if !strings.Contains(string(data), `"window":"5m0s"`) {
+		t.Errorf("Expected a duration string: %s", data)
+	}
+
+	if err := manager.Delete("dlq"); err != nil || len(manager.List()) != 0 {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if err := manager.Delete("dlq"); !errors.Is(err, alerts.ErrRuleNotFound) {
+		t.Errorf("Expected ErrRuleNotFound, got %v", err)
+	}
+}
@@ -2,172 +2,379 @@
 package metrics
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // Note: This is a minimal implementation without prometheus dependency.
+// It emits the Prometheus text format, or OpenMetrics when exemplars are enabled.
 // To use real Prometheus, add: github.com/prometheus/client_golang
 
+// Content types served by Handler.
+const (
+	ContentTypeText        = "text/plain; version=0.0.4; charset=utf-8"
+	ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// maxExemplarRunes is the OpenMetrics limit on the combined length of
+// exemplar label names and values.
+const maxExemplarRunes = 128
+
 // Metrics holds all GoFlow metrics.
 type Metrics struct {
 	// Jobs
-	JobsEnqueued    *Counter
-	JobsDequeued    *Counter
-	JobsCompleted   *Counter
-	JobsFailed      *Counter
-	JobsRetried     *Counter
-	JobsDLQ         *Counter
-	JobDuration     *Histogram
-	QueueDepth      *Gauge
-	
+	JobsEnqueued  *Counter
+	JobsDequeued  *Counter
+	JobsCompleted *Counter
+	JobsFailed    *Counter
+	JobsRetried   *Counter
+	JobsDLQ       *Counter
+	JobDuration   *Histogram
+	QueueDepth    *Gauge
+
 	// Workers
-	WorkersActive   *Gauge
-	WorkersBusy     *Gauge
-	
+	WorkersActive *Gauge
+	WorkersBusy   *Gauge
+
 	// Agents
-	AgentRuns       *Counter
-	AgentSteps      *Counter
-	AgentToolCalls  *Counter
-	
+	AgentRuns      *Counter
+	AgentSteps     *Counter
+	AgentToolCalls *Counter
+
+	// Tools
+	ToolCalls       *CounterVec
+	ToolPanics      *Counter
+	ToolDuration    *Histogram
+	ToolCacheHits   *Counter
+	ToolCacheMisses *Counter
+
+	// Egress
+	EgressDenied *Counter
+
 	// Workflows
 	WorkflowsStarted   *Counter
 	WorkflowsCompleted *Counter
 	WorkflowsFailed    *Counter
 	WorkflowDuration   *Histogram
-	
+
 	// System
-	Uptime          *Gauge
-	MemoryUsage     *Gauge
-	GoroutineCount  *Gauge
+	Uptime         *Gauge
+	MemoryUsage    *Gauge
+	GoroutineCount *Gauge
+
+	exemplars bool
+}
+
+// Option configures Metrics.
+type Option func(*Metrics)
+
+// WithExemplars enables OpenMetrics exemplars on the job and workflow
+// duration histograms. sampleRate in (0, 1] bounds how many observations
+// carry an exemplar: 0.1 attaches one to every tenth observation.
+func WithExemplars(sampleRate float64) Option {
+	return func(m *Metrics) {
+		if sampleRate <= 0 {
+			return
+		}
+		m.exemplars = true
+		m.JobDuration.EnableExemplars(sampleRate)
+		m.WorkflowDuration.EnableExemplars(sampleRate)
+	}
 }
 
 // Counter is a monotonically increasing counter.
 type Counter struct {
 	name   string
+	help   string
 	labels map[string]string
 	value  float64
+	mu     sync.Mutex
+}
+
+// CounterVec is a family of counters partitioned by one label.
+type CounterVec struct {
+	name     string
+	help     string
+	label    string
+	children map[string]*Counter
+	mu       sync.Mutex
 }
 
 // Gauge is a value that can go up or down.
 type Gauge struct {
 	name   string
+	help   string
 	labels map[string]string
 	value  float64
+	mu     sync.Mutex
 }
 
 // Histogram tracks distribution of values.
 type Histogram struct {
 	name    string
+	help    string
 	labels  map[string]string
 	count   uint64
 	sum     float64
 	buckets []float64
+	counts  []uint64 // per bucket, last entry is +Inf
+
+	exemplars   []*Exemplar // latest sampled exemplar per bucket
+	sampleEvery uint64      // 0 disables exemplars
+	candidates  uint64      // observations offered with exemplar labels
+	mu          sync.Mutex
+}
+
+// Exemplar links a single observation to a trace or run.
+type Exemplar struct {
+	Labels    map[string]string
+	Value     float64
+	Timestamp time.Time
 }
 
 // NewMetrics creates a new metrics instance.
-func NewMetrics() *Metrics {
-	return &Metrics{
+func NewMetrics(opts ...Option) *Metrics {
+	m := &Metrics{
 		// Jobs
-		JobsEnqueued:    NewCounter("goflow_jobs_enqueued_total", "Total jobs enqueued"),
-		JobsDequeued:    NewCounter("goflow_jobs_dequeued_total", "Total jobs dequeued"),
-		JobsCompleted:   NewCounter("goflow_jobs_completed_total", "Total jobs completed"),
-		JobsFailed:      NewCounter("goflow_jobs_failed_total", "Total jobs failed"),
-		JobsRetried:     NewCounter("goflow_jobs_retried_total", "Total jobs retried"),
-		JobsDLQ:         NewCounter("goflow_jobs_dlq_total", "Total jobs sent to DLQ"),
-		JobDuration:     NewHistogram("goflow_job_duration_seconds", "Job processing duration"),
-		QueueDepth:      NewGauge("goflow_queue_depth", "Current queue depth"),
-		
+		JobsEnqueued:  NewCounter("goflow_jobs_enqueued_total", "Total jobs enqueued"),
+		JobsDequeued:  NewCounter("goflow_jobs_dequeued_total", "Total jobs dequeued"),
+		JobsCompleted: NewCounter("goflow_jobs_completed_total", "Total jobs completed"),
+		JobsFailed:    NewCounter("goflow_jobs_failed_total", "Total jobs failed"),
+		JobsRetried:   NewCounter("goflow_jobs_retried_total", "Total jobs retried"),
+		JobsDLQ:       NewCounter("goflow_jobs_dlq_total", "Total jobs sent to DLQ"),
+		JobDuration:   NewHistogram("goflow_job_duration_seconds", "Job processing duration"),
+		QueueDepth:    NewGauge("goflow_queue_depth", "Current queue depth"),
+
 		// Workers
-		WorkersActive:   NewGauge("goflow_workers_active", "Number of active workers"),
-		WorkersBusy:     NewGauge("goflow_workers_busy", "Number of busy workers"),
-		
+		WorkersActive: NewGauge("goflow_workers_active", "Number of active workers"),
+		WorkersBusy:   NewGauge("goflow_workers_busy", "Number of busy workers"),
+
 		// Agents
-		AgentRuns:       NewCounter("goflow_agent_runs_total", "Total agent runs"),
-		AgentSteps:      NewCounter("goflow_agent_steps_total", "Total agent steps"),
-		AgentToolCalls:  NewCounter("goflow_agent_tool_calls_total", "Total tool calls"),
-		
+		AgentRuns:      NewCounter("goflow_agent_runs_total", "Total agent runs"),
+		AgentSteps:     NewCounter("goflow_agent_steps_total", "Total agent steps"),
+		AgentToolCalls: NewCounter("goflow_agent_tool_calls_total", "Total tool calls"),
+
+		// Tools
+		ToolCalls:       NewCounterVec("goflow_tool_calls_total", "Tool calls by tool", "tool"),
+		ToolPanics:      NewCounter("goflow_tool_panics_total", "Tool executions that panicked"),
+		ToolDuration:    NewHistogram("goflow_tool_duration_seconds", "Tool execution duration"),
+		ToolCacheHits:   NewCounter("goflow_tool_cache_hits_total", "Tool calls served from the result cache"),
+		ToolCacheMisses: NewCounter("goflow_tool_cache_misses_total", "Cacheable tool calls not found in the result cache"),
+
+		// Egress
+		EgressDenied: NewCounter("goflow_egress_denied_total", "Outbound requests blocked by the egress policy"),
+
 		// Workflows
 		WorkflowsStarted:   NewCounter("goflow_workflows_started_total", "Total workflows started"),
 		WorkflowsCompleted: NewCounter("goflow_workflows_completed_total", "Total workflows completed"),
 		WorkflowsFailed:    NewCounter("goflow_workflows_failed_total", "Total workflows failed"),
 		WorkflowDuration:   NewHistogram("goflow_workflow_duration_seconds", "Workflow duration"),
-		
+
 		// System
 		Uptime:         NewGauge("goflow_uptime_seconds", "Process uptime"),
 		MemoryUsage:    NewGauge("goflow_memory_bytes", "Memory usage"),
 		GoroutineCount: NewGauge("goflow_goroutines", "Number of goroutines"),
 	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
 // NewCounter creates a new counter.
 func NewCounter(name, help string) *Counter {
-	return &Counter{name: name, labels: make(map[string]string)}
+	return &Counter{name: name, help: help, labels: make(map[string]string)}
+}
+
+// NewCounterVec creates a counter family partitioned by label.
+func NewCounterVec(name, help, label string) *CounterVec {
+	return &CounterVec{name: name, help: help, label: label, children: make(map[string]*Counter)}
 }
 
 // NewGauge creates a new gauge.
 func NewGauge(name, help string) *Gauge {
-	return &Gauge{name: name, labels: make(map[string]string)}
+	return &Gauge{name: name, help: help, labels: make(map[string]string)}
 }
 
 // NewHistogram creates a new histogram.
 func NewHistogram(name, help string) *Histogram {
+	buckets := []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
 	return &Histogram{
-		name:    name,
-		labels:  make(map[string]string),
-		buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		name:      name,
+		help:      help,
+		labels:    make(map[string]string),
+		buckets:   buckets,
+		counts:    make([]uint64, len(buckets)+1),
+		exemplars: make([]*Exemplar, len(buckets)+1),
 	}
 }
 
 // Inc increments a counter by 1.
 func (c *Counter) Inc() {
-	c.value++
+	c.Add(1)
 }
 
 // Add adds a value to a counter.
 func (c *Counter) Add(v float64) {
+	c.mu.Lock()
 	c.value += v
+	c.mu.Unlock()
 }
 
 // WithLabels returns a counter with labels.
 func (c *Counter) WithLabels(labels map[string]string) *Counter {
-	return &Counter{name: c.name, labels: labels, value: 0}
+	return &Counter{name: c.name, help: c.help, labels: labels}
 }
 
 // Value returns the current value.
 func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.value
 }
 
+// With returns the counter for a label value, creating it on first use.
+func (v *CounterVec) With(value string) *Counter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, ok := v.children[value]
+	if !ok {
+		c = &Counter{name: v.name, help: v.help, labels: map[string]string{v.label: value}}
+		v.children[value] = c
+	}
+	return c
+}
+
+// Value returns the sum of all counters in the family.
+func (v *CounterVec) Value() float64 {
+	var total float64
+	for _, c := range v.counters() {
+		total += c.Value()
+	}
+	return total
+}
+
+// counters returns the family's counters ordered by label value.
+func (v *CounterVec) counters() []*Counter {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	values := make([]string, 0, len(v.children))
+	for value := range v.children {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	counters := make([]*Counter, len(values))
+	for i, value := range values {
+		counters[i] = v.children[value]
+	}
+	return counters
+}
+
 // Set sets a gauge value.
 func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
 	g.value = v
+	g.mu.Unlock()
 }
 
 // Inc increments a gauge by 1.
 func (g *Gauge) Inc() {
-	g.value++
+	g.Add(1)
 }
 
 // Dec decrements a gauge by 1.
 func (g *Gauge) Dec() {
-	g.value--
+	g.Add(-1)
 }
 
 // Add adds to a gauge.
 func (g *Gauge) Add(v float64) {
+	g.mu.Lock()
 	g.value += v
+	g.mu.Unlock()
 }
 
 // Value returns the current value.
 func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	return g.value
 }
 
+// EnableExemplars attaches exemplars to every 1/sampleRate-th observation
+// made with ObserveWithExemplar. A rate <= 0 disables exemplars.
+func (h *Histogram) EnableExemplars(sampleRate float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if sampleRate <= 0 {
+		h.sampleEvery = 0
+		return
+	}
+	every := uint64(math.Round(1 / math.Min(sampleRate, 1)))
+	if every == 0 {
+		every = 1
+	}
+	h.sampleEvery = every
+}
+
 // Observe records a value in the histogram.
 func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.observe(v)
+}
+
+func (h *Histogram) observe(v float64) int {
 	h.count++
 	h.sum += v
+	i := sort.SearchFloat64s(h.buckets, v) // first bucket with upper bound >= v
+	h.counts[i]++
+	return i
+}
+
+// ObserveWithExemplar records a value and, if exemplars are enabled and the
+// observation is sampled, attaches labels (e.g. run_id, trace_id) as the
+// bucket's exemplar. Label sets longer than OpenMetrics allows are dropped.
+func (h *Histogram) ObserveWithExemplar(v float64, labels map[string]string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := h.observe(v)
+	if h.sampleEvery == 0 || len(labels) == 0 {
+		return
+	}
+	h.candidates++
+	if (h.candidates-1)%h.sampleEvery != 0 || !validExemplarLabels(labels) {
+		return
+	}
+
+	copied := make(map[string]string, len(labels))
+	for k, val := range labels {
+		copied[k] = val
+	}
+	h.exemplars[i] = &Exemplar{Labels: copied, Value: v, Timestamp: time.Now()}
+}
+
+// Exemplars returns the latest exemplar of each bucket that has one, from
+// the lowest bucket up.
+func (h *Histogram) Exemplars() []Exemplar {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var out []Exemplar
+	for _, ex := range h.exemplars {
+		if ex != nil {
+			out = append(out, *ex)
+		}
+	}
+	return out
 }
 
 // ObserveDuration records a duration.
@@ -177,70 +384,254 @@ func (h *Histogram) ObserveDuration(start time.Time) {
 
 // Count returns the number of observations.
 func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	return h.count
 }
 
 // Sum returns the sum of observations.
 func (h *Histogram) Sum() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	return h.sum
 }
 
 // Avg returns the average.
 func (h *Histogram) Avg() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	if h.count == 0 {
 		return 0
 	}
 	return h.sum / float64(h.count)
 }
 
-// Handler returns an HTTP handler for metrics.
+// Value returns the current value of the named counter or gauge, or of a
+// histogram's _count or _sum series. A labelled counter family reports
+// its total. It reports false for unknown names.
+func (m *Metrics) Value(name string) (float64, bool) {
+	for _, c := range m.counters() {
+		if c.name == name {
+			return c.Value(), true
+		}
+	}
+	for _, v := range m.counterVecs() {
+		if v.name == name {
+			return v.Value(), true
+		}
+	}
+	for _, g := range m.gauges() {
+		if g.name == name {
+			return g.Value(), true
+		}
+	}
+	for _, h := range m.histograms() {
+		switch name {
+		case h.name + "_count":
+			return float64(h.Count()), true
+		case h.name + "_sum":
+			return h.Sum(), true
+		}
+	}
+	return 0, false
+}
+
+func (m *Metrics) counters() []*Counter {
+	return []*Counter{
+		m.JobsEnqueued, m.JobsDequeued, m.JobsCompleted, m.JobsFailed, m.JobsRetried, m.JobsDLQ,
+		m.AgentRuns, m.AgentSteps, m.AgentToolCalls,
+		m.ToolPanics, m.ToolCacheHits, m.ToolCacheMisses,
+		m.EgressDenied,
+		m.WorkflowsStarted, m.WorkflowsCompleted, m.WorkflowsFailed,
+	}
+}
+
+func (m *Metrics) counterVecs() []*CounterVec {
+	return []*CounterVec{m.ToolCalls}
+}
+
+func (m *Metrics) gauges() []*Gauge {
+	return []*Gauge{
+		m.QueueDepth,
+		m.WorkersActive, m.WorkersBusy,
+		m.Uptime, m.MemoryUsage, m.GoroutineCount,
+	}
+}
+
+func (m *Metrics) histograms() []*Histogram {
+	return []*Histogram{m.JobDuration, m.ToolDuration, m.WorkflowDuration}
+}
+
+func validExemplarLabels(labels map[string]string) bool {
+	n := 0
+	for k, v := range labels {
+		n += utf8.RuneCountInString(k) + utf8.RuneCountInString(v)
+	}
+	return n <= maxExemplarRunes
+}
+
+// ============ Exposition ============
+
+// Handler returns an HTTP handler for metrics. It serves OpenMetrics when
+// exemplars are enabled and the Prometheus text format otherwise.
 func (m *Metrics) Handler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		
-		// Jobs
-		writeMetric(w, "goflow_jobs_enqueued_total", m.JobsEnqueued.Value())
-		writeMetric(w, "goflow_jobs_dequeued_total", m.JobsDequeued.Value())
-		writeMetric(w, "goflow_jobs_completed_total", m.JobsCompleted.Value())
-		writeMetric(w, "goflow_jobs_failed_total", m.JobsFailed.Value())
-		writeMetric(w, "goflow_jobs_retried_total", m.JobsRetried.Value())
-		writeMetric(w, "goflow_jobs_dlq_total", m.JobsDLQ.Value())
-		writeMetric(w, "goflow_queue_depth", m.QueueDepth.Value())
-		
-		// Job duration histogram
-		writeMetric(w, "goflow_job_duration_seconds_count", float64(m.JobDuration.Count()))
-		writeMetric(w, "goflow_job_duration_seconds_sum", m.JobDuration.Sum())
-		
-		// Workers
-		writeMetric(w, "goflow_workers_active", m.WorkersActive.Value())
-		writeMetric(w, "goflow_workers_busy", m.WorkersBusy.Value())
-		
-		// Agents
-		writeMetric(w, "goflow_agent_runs_total", m.AgentRuns.Value())
-		writeMetric(w, "goflow_agent_steps_total", m.AgentSteps.Value())
-		writeMetric(w, "goflow_agent_tool_calls_total", m.AgentToolCalls.Value())
-		
-		// Workflows
-		writeMetric(w, "goflow_workflows_started_total", m.WorkflowsStarted.Value())
-		writeMetric(w, "goflow_workflows_completed_total", m.WorkflowsCompleted.Value())
-		writeMetric(w, "goflow_workflows_failed_total", m.WorkflowsFailed.Value())
-		
-		// System
-		writeMetric(w, "goflow_uptime_seconds", m.Uptime.Value())
-		writeMetric(w, "goflow_memory_bytes", m.MemoryUsage.Value())
-		writeMetric(w, "goflow_goroutines", m.GoroutineCount.Value())
+		if m.exemplars {
+			w.Header().Set("Content-Type", ContentTypeOpenMetrics)
+		} else {
+			w.Header().Set("Content-Type", ContentTypeText)
+		}
+		w.Write([]byte(m.Expose()))
 	})
 }
 
-func writeMetric(w http.ResponseWriter, name string, value float64) {
-	w.Write([]byte(name + " " + formatFloat(value) + "\n"))
+// Expose renders all metrics in the format served by Handler.
+func (m *Metrics) Expose() string {
+	e := &exposition{openMetrics: m.exemplars}
+
+	// Jobs
+	e.counter(m.JobsEnqueued)
+	e.counter(m.JobsDequeued)
+	e.counter(m.JobsCompleted)
+	e.counter(m.JobsFailed)
+	e.counter(m.JobsRetried)
+	e.counter(m.JobsDLQ)
+	e.histogram(m.JobDuration)
+	e.gauge(m.QueueDepth)
+
+	// Workers
+	e.gauge(m.WorkersActive)
+	e.gauge(m.WorkersBusy)
+
+	// Agents
+	e.counter(m.AgentRuns)
+	e.counter(m.AgentSteps)
+	e.counter(m.AgentToolCalls)
+
+	// Tools
+	e.counterVec(m.ToolCalls)
+	e.counter(m.ToolPanics)
+	e.histogram(m.ToolDuration)
+	e.counter(m.ToolCacheHits)
+	e.counter(m.ToolCacheMisses)
+
+	// Egress
+	e.counter(m.EgressDenied)
+
+	// Workflows
+	e.counter(m.WorkflowsStarted)
+	e.counter(m.WorkflowsCompleted)
+	e.counter(m.WorkflowsFailed)
+	e.histogram(m.WorkflowDuration)
+
+	// System
+	e.gauge(m.Uptime)
+	e.gauge(m.MemoryUsage)
+	e.gauge(m.GoroutineCount)
+
+	if e.openMetrics {
+		e.sb.WriteString("# EOF\n")
+	}
+	return e.sb.String()
+}
+
+type exposition struct {
+	sb          strings.Builder
+	openMetrics bool
+}
+
+func (e *exposition) header(name, help, typ string) {
+	fmt.Fprintf(&e.sb, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(&e.sb, "# TYPE %s %s\n", name, typ)
+}
+
+func (e *exposition) counter(c *Counter) {
+	family := c.name
+	if e.openMetrics {
+		// OpenMetrics counter families omit the _total suffix.
+		family = strings.TrimSuffix(c.name, "_total")
+	}
+	e.header(family, c.help, "counter")
+	fmt.Fprintf(&e.sb, "%s%s %s\n", c.name, formatLabels(c.labels, "", ""), formatFloat(c.Value()))
+}
+
+func (e *exposition) counterVec(v *CounterVec) {
+	family := v.name
+	if e.openMetrics {
+		family = strings.TrimSuffix(v.name, "_total")
+	}
+	e.header(family, v.help, "counter")
+	for _, c := range v.counters() {
+		fmt.Fprintf(&e.sb, "%s%s %s\n", c.name, formatLabels(c.labels, "", ""), formatFloat(c.Value()))
+	}
+}
+
+func (e *exposition) gauge(g *Gauge) {
+	e.header(g.name, g.help, "gauge")
+	fmt.Fprintf(&e.sb, "%s%s %s\n", g.name, formatLabels(g.labels, "", ""), formatFloat(g.Value()))
+}
+
+func (e *exposition) histogram(h *Histogram) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e.header(h.name, h.help, "histogram")
+	var cumulative uint64
+	for i := range h.counts {
+		cumulative += h.counts[i]
+		le := "+Inf"
+		if i < len(h.buckets) {
+			le = formatFloat(h.buckets[i])
+		}
+		fmt.Fprintf(&e.sb, "%s_bucket%s %d", h.name, formatLabels(h.labels, "le", le), cumulative)
+		if ex := h.exemplars[i]; e.openMetrics && ex != nil {
+			fmt.Fprintf(&e.sb, " # %s %s %s", formatLabels(ex.Labels, "", ""), formatFloat(ex.Value),
+				strconv.FormatFloat(float64(ex.Timestamp.UnixNano())/1e9, 'f', 3, 64))
+		}
+		e.sb.WriteString("\n")
+	}
+	fmt.Fprintf(&e.sb, "%s_sum%s %s\n", h.name, formatLabels(h.labels, "", ""), formatFloat(h.sum))
+	fmt.Fprintf(&e.sb, "%s_count%s %d\n", h.name, formatLabels(h.labels, "", ""), h.count)
+}
+
+// formatLabels renders {k="v",...} in sorted key order, with an optional
+// extra label appended.
+func formatLabels(labels map[string]string, extraKey, extraValue string) string {
+	if len(labels) == 0 && extraKey == "" {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, k, escapeLabel(labels[k])))
+	}
+	if extraKey != "" {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, extraKey, escapeLabel(extraValue)))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
 }
 
 func formatFloat(v float64) string {
-	if v == float64(int64(v)) {
-		return string(rune(int64(v) + '0'))
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
 	}
-	return string(rune(int64(v)))
+	return strconv.FormatFloat(v, 'g', -1, 64)
 }
 
 // Global metrics instance
@@ -254,10 +645,60 @@ func JobFailed()    { DefaultMetrics.JobsFailed.Inc() }
 func JobRetried()   { DefaultMetrics.JobsRetried.Inc() }
 func JobToDLQ()     { DefaultMetrics.JobsDLQ.Inc() }
 
+// Workflow run outcomes. Cancelled runs count neither as completed nor as
+// failed.
+func WorkflowStarted()   { DefaultMetrics.WorkflowsStarted.Inc() }
+func WorkflowCompleted() { DefaultMetrics.WorkflowsCompleted.Inc() }
+func WorkflowFailed()    { DefaultMetrics.WorkflowsFailed.Inc() }
+
+// EgressDenied counts an outbound request blocked by the egress policy.
+func EgressDenied() { DefaultMetrics.EgressDenied.Inc() }
+
+// ToolPanicked counts a tool execution that panicked and was recovered.
+func ToolPanicked() { DefaultMetrics.ToolPanics.Inc() }
+
+// ToolCalled counts a call of the named tool, in total and per tool.
+func ToolCalled(name string) {
+	DefaultMetrics.AgentToolCalls.Inc()
+	DefaultMetrics.ToolCalls.With(name).Inc()
+}
+
+// ToolCacheHit counts a tool call served from the result cache.
+func ToolCacheHit() { DefaultMetrics.ToolCacheHits.Inc() }
+
+// ToolCacheMiss counts a cacheable tool call that had to run.
+func ToolCacheMiss() { DefaultMetrics.ToolCacheMisses.Inc() }
+
+// ObserveToolDuration records the duration of a tool execution.
+func ObserveToolDuration(start time.Time) {
+	DefaultMetrics.ToolDuration.ObserveDuration(start)
+}
+
 func ObserveJobDuration(start time.Time) {
 	DefaultMetrics.JobDuration.ObserveDuration(start)
 }
 
+// ObserveJobRun records a job duration with the job's run and trace IDs as exemplar.
+func ObserveJobRun(start time.Time, runID, traceID string) {
+	DefaultMetrics.JobDuration.ObserveWithExemplar(time.Since(start).Seconds(), exemplarLabels(runID, traceID))
+}
+
+// ObserveWorkflowRun records a workflow duration with its run and trace IDs as exemplar.
+func ObserveWorkflowRun(start time.Time, runID, traceID string) {
+	DefaultMetrics.WorkflowDuration.ObserveWithExemplar(time.Since(start).Seconds(), exemplarLabels(runID, traceID))
+}
+
+func exemplarLabels(runID, traceID string) map[string]string {
+	labels := make(map[string]string, 2)
+	if runID != "" {
+		labels["run_id"] = runID
+	}
+	if traceID != "" {
+		labels["trace_id"] = traceID
+	}
+	return labels
+}
+
 func SetQueueDepth(v float64) {
 	DefaultMetrics.QueueDepth.Set(v)
 }
@@ -0,0 +1,145 @@
+// Package metrics_test provides tests for the metrics exposition.
+package metrics_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/metrics"
+)
+
+// exemplarLine matches a histogram bucket carrying an OpenMetrics exemplar.
+var exemplarLine = regexp.MustCompile(`^(\w+)_bucket\{le="([^"]+)"\} (\d+) # \{([^}]*)\} ([0-9.e+-]+) (\d+\.\d{3})$`)
+
+func scrape(t *testing.T, m *metrics.Metrics) (string, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	return rec.Header().Get("Content-Type"), rec.Body.String()
+}
+
+func exemplars(body string) []string {
+	var out []string
+	for _, line := range strings.Split(body, "\n") {
+		if strings.Contains(line, " # {") {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+func TestExposition_TextFormat(t *testing.T) {
+	m := metrics.NewMetrics()
+	m.JobsEnqueued.Add(12)
+	m.QueueDepth.Set(3.5)
+	m.JobDuration.ObserveWithExemplar(0.3, map[string]string{"run_id": "job-1"})
+	m.JobDuration.Observe(7)
+
+	contentType, body := scrape(t, m)
+	if contentType != metrics.ContentTypeText {
+		t.Errorf("Expected text content type, got %q", contentType)
+	}
+
+	for _, want := range []string{
+		"# TYPE goflow_jobs_enqueued_total counter\ngoflow_jobs_enqueued_total 12\n",
+		"goflow_queue_depth 3.5\n",
+		"# TYPE goflow_job_duration_seconds histogram\n",
+		`goflow_job_duration_seconds_bucket{le="0.25"} 0` + "\n",
+		`goflow_job_duration_seconds_bucket{le="0.5"} 1` + "\n",
+		`goflow_job_duration_seconds_bucket{le="+Inf"} 2` + "\n",
+		"goflow_job_duration_seconds_sum 7.3\n",
+		"goflow_job_duration_seconds_count 2\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Missing %q in:\n%s", want, body)
+		}
+	}
+	if len(exemplars(body)) != 0 || strings.Contains(body, "# EOF") {
+		t.Error("Exemplars must not be emitted without WithExemplars")
+	}
+}
+
+func TestExposition_CounterVec(t *testing.T) {
+	m := metrics.NewMetrics()
+	m.ToolCalls.With("search").Add(2)
+	m.ToolCalls.With("calculator").Inc()
+
+	_, body := scrape(t, m)
+	want := "# TYPE goflow_tool_calls_total counter\n" +
+		`goflow_tool_calls_total{tool="calculator"} 1` + "\n" +
+		`goflow_tool_calls_total{tool="search"} 2` + "\n"
+	if !strings.Contains(body, want) {
+		t.Errorf("Missing %q in:\n%s", want, body)
+	}
+	if v, ok := m.Value("goflow_tool_calls_total"); !ok || v != 3 {
+		t.Errorf("Expected the family total 3, got %v (%v)", v, ok)
+	}
+}
+
+func TestExposition_Exemplars(t *testing.T) {
+	m := metrics.NewMetrics(metrics.WithExemplars(1))
+	m.JobsEnqueued.Inc()
+	m.WorkflowDuration.ObserveWithExemplar(1.7, map[string]string{"run_id": "wf-42", "trace_id": "abc123"})
+
+	contentType, body := scrape(t, m)
+	if contentType != metrics.ContentTypeOpenMetrics {
+		t.Errorf("Expected OpenMetrics content type, got %q", contentType)
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Error("OpenMetrics exposition must end with # EOF")
+	}
+	if !strings.Contains(body, "# TYPE goflow_jobs_enqueued counter\ngoflow_jobs_enqueued_total 1\n") {
+		t.Errorf("Unexpected OpenMetrics counter in:\n%s", body)
+	}
+
+	lines := exemplars(body)
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 exemplar, got %d:\n%s", len(lines), body)
+	}
+	match := exemplarLine.FindStringSubmatch(lines[0])
+	if match == nil {
+		t.Fatalf("Invalid exemplar syntax: %q", lines[0])
+	}
+	if match[1] != "goflow_workflow_duration_seconds" || match[2] != "2.5" || match[3] != "1" {
+		t.Errorf("Exemplar on wrong bucket: %q", lines[0])
+	}
+	if match[4] != `run_id="wf-42",trace_id="abc123"` || match[5] != "1.7" {
+		t.Errorf("Unexpected exemplar labels/value: %q", lines[0])
+	}
+}
+
+func TestExemplars_Sampling(t *testing.T) {
+	m := metrics.NewMetrics(metrics.WithExemplars(0.25))
+	h := m.JobDuration
+
+	// One observation per bucket so each sampled exemplar remains visible.
+	values := []float64{0.0005, 0.003, 0.007, 0.02, 0.04, 0.07, 0.2, 0.4}
+	for i, v := range values {
+		h.ObserveWithExemplar(v, map[string]string{"run_id": fmt.Sprintf("job-%d", i)})
+	}
+
+	_, body := scrape(t, m)
+	lines := exemplars(body)
+	if len(lines) != 2 {
+		t.Fatalf("Expected every 4th observation sampled (2), got %d:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+	if !strings.Contains(lines[0], `run_id="job-0"`) || !strings.Contains(lines[1], `run_id="job-4"`) {
+		t.Errorf("Unexpected sampled runs: %q", lines)
+	}
+	if h.Count() != uint64(len(values)) {
+		t.Errorf("Sampling must not drop observations, got count %d", h.Count())
+	}
+}
+
+func TestExemplars_LabelLimit(t *testing.T) {
+	m := metrics.NewMetrics(metrics.WithExemplars(1))
+	m.JobDuration.ObserveWithExemplar(0.1, map[string]string{"run_id": strings.Repeat("x", 200)})
+
+	_, body := scrape(t, m)
+	if len(exemplars(body)) != 0 {
+		t.Error("Exemplars over the OpenMetrics label limit must be dropped")
+	}
+}
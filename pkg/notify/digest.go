@@ -0,0 +1,319 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a run outcome reported to a Digest. A nil Err marks a success.
+type Event struct {
+	Workflow string
+	RunID    string
+	Err      error
+	Time     time.Time // zero uses the digest clock
+}
+
+// FingerprintFunc groups failure events. Events with the same fingerprint
+// are digested together.
+type FingerprintFunc func(Event) string
+
+// DefaultFingerprint groups failures by workflow name and error class.
+func DefaultFingerprint(e Event) string {
+	return e.Workflow + "|" + ErrorClass(e.Err)
+}
+
+var digitRuns = regexp.MustCompile(`[0-9]+`)
+
+// ErrorClass returns a stable class for err: the message of the innermost
+// wrapped error with numbers collapsed, so "dial 10.0.0.1:5432" and
+// "dial 10.0.0.2:5432" fall into the same class.
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			break
+		}
+		err = inner
+	}
+	return digitRuns.ReplaceAllString(err.Error(), "#")
+}
+
+// DigestOption configures a Digest.
+type DigestOption func(*Digest)
+
+// WithDigestWindow sets how long repeated failures are accumulated before a
+// digest is sent. Default: 15 minutes.
+func WithDigestWindow(d time.Duration) DigestOption {
+	return func(g *Digest) {
+		g.window = d
+	}
+}
+
+// WithDigestThreshold sets the minimum number of accumulated failures needed
+// to send a digest; smaller counts are carried into the next window.
+// Default: 1.
+func WithDigestThreshold(n int) DigestOption {
+	return func(g *Digest) {
+		g.threshold = n
+	}
+}
+
+// WithDigestSamples sets how many run IDs a digest lists. Default: 5.
+func WithDigestSamples(n int) DigestOption {
+	return func(g *Digest) {
+		g.samples = n
+	}
+}
+
+// WithDigestGroupTTL sets how long a fingerprint is remembered after its
+// last failure once nothing is left to send. An expired fingerprint alerts
+// immediately again, and its workflow's next success sends no resolution
+// notice. Default: 24 hours.
+func WithDigestGroupTTL(d time.Duration) DigestOption {
+	return func(g *Digest) {
+		g.ttl = d
+	}
+}
+
+// WithFingerprint replaces the failure grouping key.
+func WithFingerprint(fn FingerprintFunc) DigestOption {
+	return func(g *Digest) {
+		g.fingerprint = fn
+	}
+}
+
+// WithDigestClock sets the time source, for tests.
+func WithDigestClock(now func() time.Time) DigestOption {
+	return func(g *Digest) {
+		g.now = now
+	}
+}
+
+// Digest rate-limits failure notifications. The first failure of a
+// fingerprint is sent immediately; further failures are accumulated and
+// summarized in one digest per window. The first success of a workflow after
+// failures sends a resolution notice.
+type Digest struct {
+	next        Notifier
+	window      time.Duration
+	threshold   int
+	samples     int
+	ttl         time.Duration
+	fingerprint FingerprintFunc
+	now         func() time.Time
+
+	groups map[string]*digestGroup
+	mu     sync.Mutex
+}
+
+type digestGroup struct {
+	fingerprint string
+	workflow    string
+	class       string
+	firstSeen   time.Time
+	lastSeen    time.Time
+	windowStart time.Time
+	total       int
+	pending     int
+	sampleIDs   []string
+}
+
+// NewDigest creates a digest that delivers to next.
+func NewDigest(next Notifier, opts ...DigestOption) *Digest {
+	d := &Digest{
+		next:        next,
+		window:      15 * time.Minute,
+		threshold:   1,
+		samples:     5,
+		ttl:         24 * time.Hour,
+		fingerprint: DefaultFingerprint,
+		now:         time.Now,
+		groups:      make(map[string]*digestGroup),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Record reports a run outcome.
+func (d *Digest) Record(ctx context.Context, e Event) error {
+	d.mu.Lock()
+	if e.Time.IsZero() {
+		e.Time = d.now()
+	}
+	msgs := d.due(e.Time)
+
+	if e.Err == nil {
+		if msg, ok := d.resolve(e); ok {
+			msgs = append(msgs, msg)
+		}
+	} else {
+		key := d.fingerprint(e)
+		g, ok := d.groups[key]
+		if !ok {
+			g = &digestGroup{
+				fingerprint: key,
+				workflow:    e.Workflow,
+				class:       ErrorClass(e.Err),
+				firstSeen:   e.Time,
+				windowStart: e.Time,
+			}
+			d.groups[key] = g
+			msgs = append(msgs, d.alert(g, e))
+		} else {
+			g.pending++
+			if len(g.sampleIDs) < d.samples && e.RunID != "" {
+				g.sampleIDs = append(g.sampleIDs, e.RunID)
+			}
+		}
+		g.total++
+		g.lastSeen = e.Time
+	}
+	d.mu.Unlock()
+
+	return d.send(ctx, msgs)
+}
+
+// Flush sends digests for every group whose window has elapsed and forgets
+// groups idle for longer than the group TTL.
+func (d *Digest) Flush(ctx context.Context) error {
+	d.mu.Lock()
+	msgs := d.due(d.now())
+	d.mu.Unlock()
+	return d.send(ctx, msgs)
+}
+
+// Start flushes due digests every interval until ctx is cancelled.
+func (d *Digest) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.Flush(ctx)
+			}
+		}
+	}()
+}
+
+// due builds digests for elapsed windows and drops expired groups. Caller
+// must hold d.mu.
+func (d *Digest) due(now time.Time) []Message {
+	var msgs []Message
+	for _, key := range d.sortedKeys() {
+		g := d.groups[key]
+		if g.pending < d.threshold && now.Sub(g.lastSeen) >= d.ttl {
+			delete(d.groups, key)
+			continue
+		}
+		if now.Sub(g.windowStart) < d.window || g.pending < d.threshold {
+			continue
+		}
+		msgs = append(msgs, d.digest(g, now))
+		g.pending = 0
+		g.sampleIDs = nil
+		g.windowStart = now
+	}
+	return msgs
+}
+
+// resolve closes every group of the event's workflow. Caller must hold d.mu.
+func (d *Digest) resolve(e Event) (Message, bool) {
+	var (
+		total     int
+		firstSeen time.Time
+		classes   []string
+	)
+	for _, key := range d.sortedKeys() {
+		g := d.groups[key]
+		if g.workflow != e.Workflow {
+			continue
+		}
+		total += g.total
+		if firstSeen.IsZero() || g.firstSeen.Before(firstSeen) {
+			firstSeen = g.firstSeen
+		}
+		classes = append(classes, g.class)
+		delete(d.groups, key)
+	}
+	if total == 0 {
+		return Message{}, false
+	}
+	return Message{
+		Title:    fmt.Sprintf("Resolved: %s recovered", e.Workflow),
+		Body:     fmt.Sprintf("%s succeeded after %d failure(s) over %s.", e.Workflow, total, e.Time.Sub(firstSeen)),
+		Severity: SeverityInfo,
+		Fields: map[string]string{
+			"workflow":   e.Workflow,
+			"run_id":     e.RunID,
+			"failures":   fmt.Sprint(total),
+			"errors":     strings.Join(classes, "; "),
+			"first_seen": firstSeen.Format(time.RFC3339),
+		},
+		Timestamp: e.Time,
+	}, true
+}
+
+func (d *Digest) alert(g *digestGroup, e Event) Message {
+	return Message{
+		Title:    fmt.Sprintf("%s failed", g.workflow),
+		Body:     e.Err.Error(),
+		Severity: SeverityCritical,
+		Fields: map[string]string{
+			"workflow":    g.workflow,
+			"run_id":      e.RunID,
+			"fingerprint": g.fingerprint,
+		},
+		Timestamp: e.Time,
+	}
+}
+
+func (d *Digest) digest(g *digestGroup, now time.Time) Message {
+	return Message{
+		Title:    fmt.Sprintf("%s failed %d more time(s)", g.workflow, g.pending),
+		Body:     fmt.Sprintf("%d failure(s) of %q since %s (%d total).", g.pending, g.class, g.windowStart.Format(time.RFC3339), g.total),
+		Severity: SeverityWarning,
+		Fields: map[string]string{
+			"workflow":    g.workflow,
+			"fingerprint": g.fingerprint,
+			"count":       fmt.Sprint(g.pending),
+			"total":       fmt.Sprint(g.total),
+			"first_seen":  g.firstSeen.Format(time.RFC3339),
+			"last_seen":   g.lastSeen.Format(time.RFC3339),
+			"sample_runs": strings.Join(g.sampleIDs, ","),
+		},
+		Timestamp: now,
+	}
+}
+
+func (d *Digest) sortedKeys() []string {
+	keys := make([]string, 0, len(d.groups))
+	for key := range d.groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (d *Digest) send(ctx context.Context, msgs []Message) error {
+	var firstErr error
+	for _, msg := range msgs {
+		if err := d.next.Notify(ctx, msg); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,173 @@
+// Package notify_test provides tests for notification digests.
+package notify_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/notify"
+)
+
+type recorder struct {
+	messages []notify.Message
+}
+
+func (r *recorder) Notify(ctx context.Context, msg notify.Message) error {
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func TestDigest_NoisyFailures(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	rec := &recorder{}
+	digest := notify.NewDigest(rec,
+		notify.WithDigestWindow(15*time.Minute),
+		notify.WithDigestClock(func() time.Time { return now }),
+	)
+	ctx := context.Background()
+
+	// 500 failures over an hour, alternating between two fingerprints.
+	for i := 0; i < 500; i++ {
+		now = start.Add(time.Duration(i) * 7200 * time.Millisecond)
+		event := notify.Event{RunID: fmt.Sprintf("run-%d", i)}
+		if i%2 == 0 {
+			event.Workflow = "billing"
+			event.Err = fmt.Errorf("charge: %w", fmt.Errorf("dial 10.0.0.%d:5432: connection refused", i%7))
+		} else {
+			event.Workflow = "sync"
+			event.Err = errors.New("timeout after 30s")
+		}
+		if err := digest.Record(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now = start.Add(time.Hour + 5*time.Minute)
+	digest.Flush(ctx)
+	digest.Record(ctx, notify.Event{Workflow: "billing", RunID: "ok-1"})
+	digest.Record(ctx, notify.Event{Workflow: "sync", RunID: "ok-2"})
+	digest.Record(ctx, notify.Event{Workflow: "billing", RunID: "ok-3"})
+
+	want := []string{
+		"billing failed",
+		"sync failed",
+		"billing failed 62 more time(s)",
+		"sync failed 62 more time(s)",
+		"billing failed 62 more time(s)",
+		"sync failed 62 more time(s)",
+		"billing failed 63 more time(s)",
+		"sync failed 63 more time(s)",
+		"billing failed 62 more time(s)",
+		"sync failed 62 more time(s)",
+		"Resolved: billing recovered",
+		"Resolved: sync recovered",
+	}
+	if len(rec.messages) != len(want) {
+		for _, m := range rec.messages {
+			t.Log(m.Title)
+		}
+		t.Fatalf("Expected %d messages, got %d", len(want), len(rec.messages))
+	}
+	for i, title := range want {
+		if rec.messages[i].Title != title {
+			t.Errorf("Message %d: got %q, want %q", i, rec.messages[i].Title, title)
+		}
+	}
+
+	first := rec.messages[2]
+	if first.Fields["sample_runs"] != "run-2,run-4,run-6,run-8,run-10" {
+		t.Errorf("Unexpected samples: %q", first.Fields["sample_runs"])
+	}
+	if first.Fields["first_seen"] != start.Format(time.RFC3339) || first.Fields["last_seen"] != start.Add(892800*time.Millisecond).Format(time.RFC3339) {
+		t.Errorf("Unexpected first/last seen: %v", first.Fields)
+	}
+	if first.Fields["fingerprint"] != "billing|dial #.#.#.#:#: connection refused" {
+		t.Errorf("Unexpected fingerprint: %q", first.Fields["fingerprint"])
+	}
+
+	resolved := rec.messages[10]
+	if resolved.Fields["failures"] != "250" || resolved.Severity != notify.SeverityInfo {
+		t.Errorf("Unexpected resolution: %+v", resolved)
+	}
+}
+
+func TestDigest_Threshold(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rec := &recorder{}
+	digest := notify.NewDigest(rec,
+		notify.WithDigestWindow(time.Minute),
+		notify.WithDigestThreshold(3),
+		notify.WithDigestClock(func() time.Time { return now }),
+	)
+	ctx := context.Background()
+	fail := notify.Event{Workflow: "wf", Err: errors.New("boom")}
+
+	digest.Record(ctx, fail)
+	digest.Record(ctx, fail)
+	now = now.Add(2 * time.Minute)
+	digest.Flush(ctx)
+	if len(rec.messages) != 1 {
+		t.Fatalf("Expected below-threshold failures to be held, got %d messages", len(rec.messages))
+	}
+
+	digest.Record(ctx, fail)
+	digest.Record(ctx, fail)
+	digest.Flush(ctx)
+	if len(rec.messages) != 2 || rec.messages[1].Fields["count"] != "3" {
+		t.Fatalf("Expected digest of 3 failures, got %+v", rec.messages)
+	}
+}
+
+func TestDigest_CustomFingerprint(t *testing.T) {
+	rec := &recorder{}
+	digest := notify.NewDigest(rec, notify.WithFingerprint(func(e notify.Event) string {
+		return e.Workflow
+	}))
+	ctx := context.Background()
+
+	digest.Record(ctx, notify.Event{Workflow: "wf", Err: errors.New("a")})
+	digest.Record(ctx, notify.Event{Workflow: "wf", Err: errors.New("b")})
+	if len(rec.messages) != 1 {
+		t.Errorf("Expected errors grouped by workflow, got %d messages", len(rec.messages))
+	}
+}
+
+func TestDigest_GroupTTL(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rec := &recorder{}
+	digest := notify.NewDigest(rec,
+		notify.WithDigestWindow(time.Minute),
+		notify.WithDigestGroupTTL(time.Hour),
+		notify.WithDigestClock(func() time.Time { return now }),
+	)
+	ctx := context.Background()
+	fail := notify.Event{Workflow: "wf", Err: errors.New("boom")}
+
+	digest.Record(ctx, fail)
+	digest.Record(ctx, fail)
+	now = now.Add(2 * time.Minute)
+	digest.Flush(ctx) // sends the digest; the group stays
+	now = now.Add(30 * time.Minute)
+	digest.Flush(ctx)
+	digest.Record(ctx, fail)
+	if len(rec.messages) != 2 {
+		t.Fatalf("Expected a failure within the TTL to be held, got %+v", rec.messages)
+	}
+
+	// Once idle past the TTL the group is forgotten.
+	now = now.Add(2 * time.Hour)
+	digest.Flush(ctx)
+	digest.Record(ctx, notify.Event{Workflow: "wf", RunID: "ok"})
+	digest.Record(ctx, fail)
+	titles := make([]string, len(rec.messages))
+	for i, msg := range rec.messages {
+		titles[i] = msg.Title
+	}
+	if len(titles) != 4 || titles[2] != "wf failed 1 more time(s)" || titles[3] != "wf failed" {
+		t.Errorf("Expected the expired group to alert again without a resolution, got %q", titles)
+	}
+}
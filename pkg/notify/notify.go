@@ -0,0 +1,54 @@
+// Package notify provides a small abstraction for sending operator
+// notifications (chat, email, paging) from workflows and the server.
+package notify
+
+import (
+	"context"
+	"time"
+)
+
+// Severity indicates how urgent a notification is.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Message is a single notification.
+type Message struct {
+	Title     string            `json:"title"`
+	Body      string            `json:"body"`
+	Severity  Severity          `json:"severity"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// Notifier delivers notifications.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface.
+type NotifierFunc func(ctx context.Context, msg Message) error
+
+// Notify calls f(ctx, msg).
+func (f NotifierFunc) Notify(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// Multi fans a notification out to several notifiers.
+// It returns the first error but always attempts every notifier.
+type Multi []Notifier
+
+// Notify sends msg to every notifier.
+func (m Multi) Notify(ctx context.Context, msg Message) error {
+	var firstErr error
+	for _, n := range m {
+		if err := n.Notify(ctx, msg); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
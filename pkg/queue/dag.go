@@ -0,0 +1,339 @@
+// Package queue provides DAG workflows that run nodes as their dependencies
+// complete.
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DAGWorkflow represents a Directed Acyclic Graph workflow.
+type DAGWorkflow struct {
+	nodes   map[string]*DAGNode
+	edges   map[string][]string // node -> dependencies
+	options DAGOptions
+}
+
+// DAGNode is a node in the DAG workflow.
+type DAGNode struct {
+	Name    string
+	Handler func(ctx context.Context, inputs map[string]any) (any, error)
+	Retry   *RetryPolicy // overrides DAGOptions.Retry
+}
+
+// DAGOptions configures how a DAG workflow executes.
+type DAGOptions struct {
+	// FailFast cancels the running nodes and starts no others once a node
+	// fails. Otherwise only the failed node's dependents are skipped.
+	FailFast bool
+	// MaxConcurrency limits how many nodes run at once; zero means no limit.
+	MaxConcurrency int
+	// Retry is the retry policy of nodes without their own.
+	Retry *RetryPolicy
+}
+
+// RetryPolicy retries a failed DAG node. The delay before each retry
+// starts at Delay and is multiplied by Multiplier, up to MaxDelay.
+type RetryPolicy struct {
+	MaxAttempts int
+	Delay       time.Duration
+	MaxDelay    time.Duration
+	Multiplier  float64 // zero keeps the delay constant
+}
+
+// backoff returns the delay after the given failed attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Delay
+	if p.Multiplier > 0 {
+		d = time.Duration(float64(d) * math.Pow(p.Multiplier, float64(attempt-1)))
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// NodeStatus is the outcome of a DAG node.
+type NodeStatus string
+
+const (
+	NodeCompleted NodeStatus = "completed"
+	NodeFailed    NodeStatus = "failed"
+	NodeCancelled NodeStatus = "cancelled" // stopped by cancellation while running
+	NodeSkipped   NodeStatus = "skipped"   // never ran
+)
+
+// NodeResult is the outcome of one node of a DAG execution. Err is a
+// *NodeError for failed nodes and the handler's error for cancelled ones.
+type NodeResult struct {
+	Status   NodeStatus
+	Output   any
+	Err      error
+	Attempts int
+	Duration time.Duration
+}
+
+// NodeError reports a DAG node that failed after all its attempts.
+type NodeError struct {
+	Node     string
+	Attempts int
+	Err      error
+}
+
+func (e *NodeError) Error() string {
+	return fmt.Sprintf("dag: node %s failed after %d attempt(s): %v", e.Node, e.Attempts, e.Err)
+}
+
+func (e *NodeError) Unwrap() error { return e.Err }
+
+// CycleError reports a dependency cycle, listing its nodes from the first
+// back to itself.
+type CycleError struct {
+	Cycle []string
+}
+
+func (e *CycleError) Error() string {
+	return "dag: dependency cycle " + strings.Join(e.Cycle, " -> ")
+}
+
+// NewDAGWorkflow creates a DAG workflow.
+func NewDAGWorkflow() *DAGWorkflow {
+	return &DAGWorkflow{
+		nodes: make(map[string]*DAGNode),
+		edges: make(map[string][]string),
+	}
+}
+
+// Node adds a node to the DAG.
+func (dw *DAGWorkflow) Node(name string, handler func(ctx context.Context, inputs map[string]any) (any, error)) *DAGWorkflow {
+	dw.nodes[name] = &DAGNode{
+		Name:    name,
+		Handler: handler,
+	}
+	return dw
+}
+
+// NodeWithRetry adds a node with its own retry policy.
+func (dw *DAGWorkflow) NodeWithRetry(name string, policy *RetryPolicy, handler func(ctx context.Context, inputs map[string]any) (any, error)) *DAGWorkflow {
+	dw.nodes[name] = &DAGNode{
+		Name:    name,
+		Handler: handler,
+		Retry:   policy,
+	}
+	return dw
+}
+
+// Edge adds a dependency edge (from depends on to).
+func (dw *DAGWorkflow) Edge(from, to string) *DAGWorkflow {
+	dw.edges[from] = append(dw.edges[from], to)
+	return dw
+}
+
+// WithOptions sets the execution options.
+func (dw *DAGWorkflow) WithOptions(opts DAGOptions) *DAGWorkflow {
+	dw.options = opts
+	return dw
+}
+
+// Validate checks that every edge joins known nodes and that there are no
+// cycles, returning a *CycleError for the first one found.
+func (dw *DAGWorkflow) Validate() error {
+	_, err := dw.order()
+	return err
+}
+
+// order returns the nodes sorted so that each follows its dependencies.
+func (dw *DAGWorkflow) order() ([]string, error) {
+	names := make([]string, 0, len(dw.nodes))
+	for name := range dw.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for from, deps := range dw.edges {
+		if dw.nodes[from] == nil {
+			return nil, fmt.Errorf("dag: edge from unknown node %q", from)
+		}
+		for _, dep := range deps {
+			if dw.nodes[dep] == nil {
+				return nil, fmt.Errorf("dag: node %q depends on unknown node %q", from, dep)
+			}
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[string]int, len(names))
+	order := make([]string, 0, len(names))
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch marks[name] {
+		case visited:
+			return nil
+		case visiting:
+			i := len(path) - 1
+			for path[i] != name {
+				i--
+			}
+			return &CycleError{Cycle: append(append([]string(nil), path[i:]...), name)}
+		}
+		marks[name] = visiting
+		path = append(path, name)
+		for _, dep := range dw.edges[name] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		marks[name] = visited
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// nodeDone carries a finished node back to Execute.
+type nodeDone struct {
+	name   string
+	result *NodeResult
+}
+
+// Execute runs the DAG workflow, starting each node once its dependencies
+// have completed and passing their outputs, plus the input under "_input",
+// as the node's inputs. It returns a result for every node.
+//
+// A failed node's dependents are skipped. The error joins the *NodeError
+// of each failed node; with FailFast it is the first one. If ctx is
+// cancelled, running nodes see it, no more start and ctx.Err() is
+// returned.
+func (dw *DAGWorkflow) Execute(ctx context.Context, input any) (map[string]*NodeResult, error) {
+	order, err := dw.order()
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	waiting := make(map[string]int, len(order))
+	dependents := make(map[string][]string)
+	var ready []string
+	for _, name := range order {
+		waiting[name] = len(dw.edges[name])
+		for _, dep := range dw.edges[name] {
+			dependents[dep] = append(dependents[dep], name)
+		}
+		if waiting[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	results := make(map[string]*NodeResult, len(order))
+	done := make(chan nodeDone)
+	running := 0
+	var failures []error
+	for {
+		stopped := runCtx.Err() != nil
+		for !stopped && len(ready) > 0 && (dw.options.MaxConcurrency <= 0 || running < dw.options.MaxConcurrency) {
+			node := dw.nodes[ready[0]]
+			ready = ready[1:]
+			inputs := map[string]any{"_input": input}
+			for _, dep := range dw.edges[node.Name] {
+				inputs[dep] = results[dep].Output
+			}
+			running++
+			go func() {
+				done <- nodeDone{name: node.Name, result: dw.run(runCtx, node, inputs)}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+
+		finished := <-done
+		running--
+		results[finished.name] = finished.result
+		switch finished.result.Status {
+		case NodeCompleted:
+			for _, next := range dependents[finished.name] {
+				if waiting[next]--; waiting[next] == 0 {
+					ready = append(ready, next)
+				}
+			}
+		case NodeFailed:
+			failures = append(failures, finished.result.Err)
+			if dw.options.FailFast {
+				cancel()
+			}
+		}
+	}
+
+	for _, name := range order {
+		if results[name] == nil {
+			results[name] = &NodeResult{Status: NodeSkipped}
+		}
+	}
+	switch {
+	case dw.options.FailFast && len(failures) > 0:
+		return results, failures[0]
+	case ctx.Err() != nil:
+		return results, ctx.Err()
+	}
+	return results, errors.Join(failures...)
+}
+
+// run executes node with its retry policy.
+func (dw *DAGWorkflow) run(ctx context.Context, node *DAGNode, inputs map[string]any) *NodeResult {
+	policy := node.Retry
+	if policy == nil {
+		policy = dw.options.Retry
+	}
+	attempts := 1
+	if policy != nil && policy.MaxAttempts > 1 {
+		attempts = policy.MaxAttempts
+	}
+
+	start := time.Now()
+	result := &NodeResult{}
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		result.Attempts = attempt
+		result.Output, err = node.Handler(ctx, inputs)
+		if err == nil || attempt == attempts || ctx.Err() != nil {
+			break
+		}
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	result.Duration = time.Since(start)
+
+	switch {
+	case err == nil:
+		result.Status = NodeCompleted
+	case ctx.Err() != nil:
+		result.Status, result.Output, result.Err = NodeCancelled, nil, err
+	default:
+		result.Status, result.Output = NodeFailed, nil
+		result.Err = &NodeError{Node: node.Name, Attempts: result.Attempts, Err: err}
+	}
+	return result
+}
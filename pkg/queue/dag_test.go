@@ -0,0 +1,177 @@
+// Package queue_test provides tests for DAG workflows.
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/queue"
+)
+
+type dagHandler = func(ctx context.Context, inputs map[string]any) (any, error)
+
+func value(v any) dagHandler {
+	return func(ctx context.Context, inputs map[string]any) (any, error) { return v, nil }
+}
+
+func failing(err error) dagHandler {
+	return func(ctx context.Context, inputs map[string]any) (any, error) { return nil, err }
+}
+
+func TestDAGWorkflow_Execute(t *testing.T) {
+	dag := queue.NewDAGWorkflow().
+		Node("fetch", value(2)).
+		Node("double", func(ctx context.Context, inputs map[string]any) (any, error) {
+			return inputs["fetch"].(int) * inputs["_input"].(int), nil
+		}).
+		Edge("double", "fetch")
+
+	results, err := dag.Execute(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	double := results["double"]
+	if double.Status != queue.NodeCompleted || double.Output != 6 || double.Attempts != 1 || double.Duration <= 0 {
+		t.Errorf("Unexpected result: %+v", double)
+	}
+}
+
+func TestDAGWorkflow_FailureSkipsDependents(t *testing.T) {
+	boom := errors.New("boom")
+	dag := queue.NewDAGWorkflow().
+		Node("bad", failing(boom)).
+		Node("after", value(1)).
+		Node("other", value(2)).
+		Edge("after", "bad")
+
+	results, err := dag.Execute(context.Background(), nil)
+	var nodeErr *queue.NodeError
+	if !errors.As(err, &nodeErr) || nodeErr.Node != "bad" || !errors.Is(err, boom) {
+		t.Fatalf("Expected a NodeError for bad, got %v", err)
+	}
+	if results["bad"].Status != queue.NodeFailed || results["after"].Status != queue.NodeSkipped {
+		t.Errorf("Expected bad failed and after skipped, got %s and %s", results["bad"].Status, results["after"].Status)
+	}
+	if results["other"].Status != queue.NodeCompleted {
+		t.Errorf("Expected the independent node to complete, got %s", results["other"].Status)
+	}
+}
+
+func TestDAGWorkflow_FailFast(t *testing.T) {
+	slow := func(ctx context.Context, inputs map[string]any) (any, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+			return "done", nil
+		}
+	}
+	dag := queue.NewDAGWorkflow().
+		Node("bad", failing(errors.New("boom"))).
+		Node("slow", slow).
+		Node("later", value(1)).
+		Edge("later", "slow").
+		WithOptions(queue.DAGOptions{FailFast: true})
+
+	start := time.Now()
+	results, err := dag.Execute(context.Background(), nil)
+	var nodeErr *queue.NodeError
+	if !errors.As(err, &nodeErr) || nodeErr.Node != "bad" {
+		t.Fatalf("Expected the NodeError of bad, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("Expected the slow node to be cancelled")
+	}
+	if results["slow"].Status != queue.NodeCancelled || results["later"].Status != queue.NodeSkipped {
+		t.Errorf("Expected slow cancelled and later skipped, got %s and %s", results["slow"].Status, results["later"].Status)
+	}
+}
+
+func TestDAGWorkflow_Retry(t *testing.T) {
+	var calls atomic.Int32
+	flaky := func(ctx context.Context, inputs map[string]any) (any, error) {
+		if calls.Add(1) < 3 {
+			return nil, errors.New("flaky")
+		}
+		return "ok", nil
+	}
+	dag := queue.NewDAGWorkflow().
+		NodeWithRetry("flaky", &queue.RetryPolicy{MaxAttempts: 3, Delay: time.Millisecond}, flaky).
+		Node("once", failing(errors.New("boom"))).
+		WithOptions(queue.DAGOptions{Retry: &queue.RetryPolicy{MaxAttempts: 2}})
+
+	results, err := dag.Execute(context.Background(), nil)
+	if results["flaky"].Status != queue.NodeCompleted || results["flaky"].Attempts != 3 {
+		t.Errorf("Expected flaky to complete on attempt 3, got %+v", results["flaky"])
+	}
+	var nodeErr *queue.NodeError
+	if !errors.As(err, &nodeErr) || nodeErr.Node != "once" || nodeErr.Attempts != 2 {
+		t.Errorf("Expected once to fail after the default 2 attempts, got %v", err)
+	}
+}
+
+func TestDAGWorkflow_MaxConcurrency(t *testing.T) {
+	var active, peak atomic.Int32
+	node := func(ctx context.Context, inputs map[string]any) (any, error) {
+		n := active.Add(1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+		active.Add(-1)
+		return nil, nil
+	}
+	dag := queue.NewDAGWorkflow().WithOptions(queue.DAGOptions{MaxConcurrency: 2})
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		dag.Node(name, node)
+	}
+
+	if _, err := dag.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if p := peak.Load(); p != 2 {
+		t.Errorf("Expected at most 2 nodes at once, peaked at %d", p)
+	}
+}
+
+func TestDAGWorkflow_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	dag := queue.NewDAGWorkflow().
+		Node("first", func(ctx context.Context, inputs map[string]any) (any, error) {
+			cancel()
+			return "ok", nil
+		}).
+		Node("second", value(1)).
+		Edge("second", "first")
+
+	results, err := dag.Execute(ctx, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if results["first"].Status != queue.NodeCompleted || results["second"].Status != queue.NodeSkipped {
+		t.Errorf("Expected no nodes started after cancellation, got %s and %s", results["first"].Status, results["second"].Status)
+	}
+}
+
+func TestDAGWorkflow_Validate(t *testing.T) {
+	dag := queue.NewDAGWorkflow().
+		Node("a", value(1)).
+		Node("b", value(2)).
+		Node("c", value(3)).
+		Edge("a", "b").
+		Edge("b", "c").
+		Edge("c", "b")
+
+	_, err := dag.Execute(context.Background(), nil)
+	var cycle *queue.CycleError
+	if !errors.As(err, &cycle) || !reflect.DeepEqual(cycle.Cycle, []string{"b", "c", "b"}) {
+		t.Fatalf("Expected the cycle b -> c -> b, got %v", err)
+	}
+
+	if err := queue.NewDAGWorkflow().Node("a", value(1)).Edge("a", "missing").Validate(); err == nil {
+		t.Error("Expected an unknown dependency to fail")
+	}
+}
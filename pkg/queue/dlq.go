@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/nuulab/goflow/pkg/metrics"
 )
 
 // DLQ is an enhanced dead letter queue with alerting.
@@ -69,6 +71,7 @@ func (d *DLQ) Add(ctx context.Context, job *Job, err error, workerID string) err
 	if pushErr := d.client.LPush(ctx, d.key, data).Err(); pushErr != nil {
 		return pushErr
 	}
+	metrics.JobToDLQ()
 
 	// Trim to max size
 	d.client.LTrim(ctx, d.key, 0, d.maxSize-1)
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/nuulab/goflow/pkg/metrics"
 )
 
 // DragonflyQueue implements Queue using DragonflyDB (Redis-compatible).
@@ -186,15 +188,19 @@ func (dq *DragonflyQueue) Close() error {
 
 // MoveToDLQ moves a failed job to the dead letter queue.
 func (dq *DragonflyQueue) MoveToDLQ(ctx context.Context, job *Job, reason string) error {
-	job.Metadata["dlq_reason"] = reason
-	job.Metadata["dlq_time"] = time.Now().Format(time.RFC3339)
+	job.WithMetadata("dlq_reason", reason)
+	job.WithMetadata("dlq_time", time.Now().Format(time.RFC3339))
 
 	data, err := json.Marshal(job)
 	if err != nil {
 		return err
 	}
 
-	return dq.client.LPush(ctx, dq.dlqKey, data).Err()
+	if err := dq.client.LPush(ctx, dq.dlqKey, data).Err(); err != nil {
+		return err
+	}
+	metrics.JobToDLQ()
+	return nil
 }
 
 // GetDLQJobs retrieves jobs from the dead letter queue.
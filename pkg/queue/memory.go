@@ -0,0 +1,219 @@
+// Package queue provides an in-memory queue for testing and development.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryQueue implements DelayedQueue in memory.
+// Useful for testing and development without DragonflyDB or Postgres.
+type MemoryQueue struct {
+	jobs   []memoryJob
+	seq    int64
+	notify chan struct{} // closed and replaced on every enqueue
+	mu     sync.Mutex
+}
+
+type memoryJob struct {
+	seq         int64
+	priority    int
+	availableAt time.Time
+	data        []byte
+}
+
+// NewMemoryQueue creates an in-memory queue.
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{notify: make(chan struct{})}
+}
+
+// Enqueue adds a job to the queue.
+func (mq *MemoryQueue) Enqueue(ctx context.Context, job *Job) error {
+	return mq.EnqueueAt(ctx, job, time.Time{})
+}
+
+// EnqueueAt adds a job that becomes available at the given time.
+func (mq *MemoryQueue) EnqueueAt(ctx context.Context, job *Job, at time.Time) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("queue: failed to marshal job: %w", err)
+	}
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	mq.seq++
+	mq.jobs = append(mq.jobs, memoryJob{seq: mq.seq, priority: job.Priority, availableAt: at, data: data})
+	close(mq.notify)
+	mq.notify = make(chan struct{})
+	return nil
+}
+
+// next returns the index of the next available job, or -1 and the time the
+// earliest delayed job becomes available.
+func (mq *MemoryQueue) next(now time.Time) (int, time.Time) {
+	best := -1
+	var wake time.Time
+	for i, j := range mq.jobs {
+		if j.availableAt.After(now) {
+			if wake.IsZero() || j.availableAt.Before(wake) {
+				wake = j.availableAt
+			}
+			continue
+		}
+		if best < 0 || j.priority > mq.jobs[best].priority ||
+			(j.priority == mq.jobs[best].priority && j.seq < mq.jobs[best].seq) {
+			best = i
+		}
+	}
+	return best, wake
+}
+
+// Dequeue removes and returns the available job with the highest priority,
+// oldest first. It waits up to timeout and returns nil if none arrives.
+func (mq *MemoryQueue) Dequeue(ctx context.Context, timeout time.Duration) (*Job, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		mq.mu.Lock()
+		now := time.Now()
+		i, wake := mq.next(now)
+		if i >= 0 {
+			data := mq.jobs[i].data
+			last := len(mq.jobs) - 1
+			copy(mq.jobs[i:], mq.jobs[i+1:])
+			mq.jobs[last] = memoryJob{} // drop the payload reference
+			mq.jobs = mq.jobs[:last]
+			mq.mu.Unlock()
+			return decodeJob(data)
+		}
+		notify := mq.notify
+		mq.mu.Unlock()
+
+		wait := deadline.Sub(now)
+		if wait <= 0 {
+			return nil, nil
+		}
+		if !wake.IsZero() && wake.Sub(now) < wait {
+			wait = wake.Sub(now)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-notify:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Peek returns the next available job without removing it.
+func (mq *MemoryQueue) Peek(ctx context.Context) (*Job, error) {
+	mq.mu.Lock()
+	i, _ := mq.next(time.Now())
+	if i < 0 {
+		mq.mu.Unlock()
+		return nil, nil
+	}
+	data := mq.jobs[i].data
+	mq.mu.Unlock()
+	return decodeJob(data)
+}
+
+// Len returns the number of jobs in the queue, including delayed jobs.
+func (mq *MemoryQueue) Len(ctx context.Context) (int64, error) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	return int64(len(mq.jobs)), nil
+}
+
+// MemoryQueueStats is a point-in-time summary of a MemoryQueue.
+type MemoryQueueStats struct {
+	// Total counts every job, Ready those available at the snapshot time
+	// and Delayed those scheduled later. Total is always Ready + Delayed.
+	Total   int64
+	Ready   int64
+	Delayed int64
+	// NextAvailableAt is when the earliest delayed job becomes available,
+	// or zero when there are none.
+	NextAvailableAt time.Time
+}
+
+// Stats returns counts taken from a single consistent view of the queue.
+func (mq *MemoryQueue) Stats(ctx context.Context) (MemoryQueueStats, error) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	now := time.Now()
+	stats := MemoryQueueStats{Total: int64(len(mq.jobs))}
+	for _, j := range mq.jobs {
+		if !j.availableAt.After(now) {
+			stats.Ready++
+			continue
+		}
+		stats.Delayed++
+		if stats.NextAvailableAt.IsZero() || j.availableAt.Before(stats.NextAvailableAt) {
+			stats.NextAvailableAt = j.availableAt
+		}
+	}
+	return stats, nil
+}
+
+// List returns every job in the queue, in the order Dequeue would return
+// them: available jobs by priority then age, followed by delayed jobs by
+// availability time. The jobs are decoded from a copy taken under the lock,
+// so concurrent enqueues and dequeues never produce a torn view.
+func (mq *MemoryQueue) List(ctx context.Context) ([]*Job, error) {
+	jobs, now := mq.snapshot()
+
+	sort.Slice(jobs, func(a, b int) bool {
+		x, y := jobs[a], jobs[b]
+		xReady, yReady := !x.availableAt.After(now), !y.availableAt.After(now)
+		switch {
+		case xReady != yReady:
+			return xReady
+		case !xReady && !x.availableAt.Equal(y.availableAt):
+			return x.availableAt.Before(y.availableAt)
+		case xReady && x.priority != y.priority:
+			return x.priority > y.priority
+		}
+		return x.seq < y.seq
+	})
+
+	out := make([]*Job, 0, len(jobs))
+	for _, j := range jobs {
+		job, err := decodeJob(j.data)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, job)
+	}
+	return out, nil
+}
+
+// snapshot copies the job index under the lock. Job data is never mutated
+// after enqueue, so the copy shares it without further locking.
+func (mq *MemoryQueue) snapshot() ([]memoryJob, time.Time) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	jobs := make([]memoryJob, len(mq.jobs))
+	copy(jobs, mq.jobs)
+	return jobs, time.Now()
+}
+
+// Close is a no-op.
+func (mq *MemoryQueue) Close() error {
+	return nil
+}
+
+func decodeJob(data []byte) (*Job, error) {
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("queue: failed to unmarshal job: %w", err)
+	}
+	return &job, nil
+}
@@ -0,0 +1,195 @@
+// Package queue_test provides tests for the in-memory queue.
+package queue_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/queue"
+	"github.com/nuulab/goflow/pkg/queue/queuetest"
+)
+
+func TestMemoryQueue(t *testing.T) {
+	queuetest.Run(t, func(t *testing.T) queue.Queue {
+		return queue.NewMemoryQueue()
+	})
+}
+
+func TestWorker_MemoryQueue(t *testing.T) {
+	q := queue.NewMemoryQueue()
+	worker := queue.NewWorker(q)
+
+	var processed atomic.Int32
+	done := make(chan struct{})
+	worker.Handle("count", func(ctx context.Context, job *queue.Job) error {
+		if processed.Add(1) == 3 {
+			close(done)
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 2)
+	defer worker.Stop()
+
+	for i := 0; i < 3; i++ {
+		job, _ := queue.NewJob("count", i)
+		if err := q.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("worker processed %d of 3 jobs", processed.Load())
+	}
+}
+
+func TestMemoryQueue_StatsAndList(t *testing.T) {
+	ctx := context.Background()
+	q := queue.NewMemoryQueue()
+
+	low, _ := queue.NewJob("low", 0)
+	high, _ := queue.NewJob("high", 0)
+	high.WithPriority(5)
+	later, _ := queue.NewJob("later", 0)
+	soon, _ := queue.NewJob("soon", 0)
+	q.Enqueue(ctx, low)
+	q.EnqueueAt(ctx, later, time.Now().Add(2*time.Hour))
+	q.Enqueue(ctx, high)
+	q.EnqueueAt(ctx, soon, time.Now().Add(time.Hour))
+
+	stats, err := q.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 4 || stats.Ready != 2 || stats.Delayed != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if d := time.Until(stats.NextAvailableAt); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("NextAvailableAt should be the earliest delayed job, got %v", stats.NextAvailableAt)
+	}
+
+	jobs, err := q.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, job := range jobs {
+		order = append(order, job.Type)
+	}
+	if got := strings.Join(order, ","); got != "high,low,soon,later" {
+		t.Errorf("List order = %s, want dequeue order", got)
+	}
+	if n, _ := q.Len(ctx); n != 4 {
+		t.Errorf("List should not remove jobs, Len = %d", n)
+	}
+}
+
+// TestMemoryQueue_ConcurrentSnapshots runs readers against workers that
+// enqueue and dequeue continuously. Run with -race.
+func TestMemoryQueue_ConcurrentSnapshots(t *testing.T) {
+	ctx := context.Background()
+	q := queue.NewMemoryQueue()
+
+	const delayed = 10
+	for i := 0; i < delayed; i++ {
+		job, _ := queue.NewJob("delayed", i)
+		q.EnqueueAt(ctx, job, time.Now().Add(time.Hour))
+	}
+
+	stop := make(chan struct{})
+	var writers sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				job, _ := queue.NewJob("work", i)
+				job.WithPriority(i % 3)
+				q.Enqueue(ctx, job)
+				q.Dequeue(ctx, 0)
+			}
+		}()
+	}
+
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for i := 0; i < 200; i++ {
+				stats, _ := q.Stats(ctx)
+				if stats.Delayed != delayed || stats.Total != stats.Ready+stats.Delayed {
+					t.Errorf("Torn stats: %+v", stats)
+					return
+				}
+				jobs, err := q.List(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(jobs) < delayed {
+					t.Errorf("List lost delayed jobs: %d", len(jobs))
+					return
+				}
+				for _, job := range jobs[len(jobs)-delayed:] {
+					if job.Type != "delayed" {
+						t.Errorf("Delayed jobs should list last, got %s", job.Type)
+						return
+					}
+				}
+				if _, err := q.Peek(ctx); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	readers.Wait()
+	close(stop)
+	writers.Wait()
+}
+
+func BenchmarkMemoryQueue_EnqueueDequeue(b *testing.B) {
+	ctx := context.Background()
+	q := queue.NewMemoryQueue()
+	job, _ := queue.NewJob("bench", map[string]int{"n": 1})
+	for i := 0; i < 64; i++ {
+		q.Enqueue(ctx, job)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.Enqueue(ctx, job)
+		if _, err := q.Dequeue(ctx, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMemoryQueue_Stats(b *testing.B) {
+	ctx := context.Background()
+	q := queue.NewMemoryQueue()
+	job, _ := queue.NewJob("bench", 0)
+	for i := 0; i < 1000; i++ {
+		q.Enqueue(ctx, job)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.Stats(ctx)
+	}
+}
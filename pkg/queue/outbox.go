@@ -0,0 +1,240 @@
+// Package queue provides a transactional outbox for enqueueing jobs.
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Intent is a job staged in an outbox. It is stored in the same write as
+// the data that produced it and enqueued by the relay once that write is
+// durable.
+type Intent struct {
+	// ID identifies the intent and is also the ID of the enqueued job.
+	ID string `json:"id"`
+	// Key is the staging key the intent was committed under.
+	Key      string    `json:"key"`
+	Job      *Job      `json:"job"`
+	StagedAt time.Time `json:"staged_at"`
+	SentAt   time.Time `json:"sent_at,omitempty"`
+}
+
+// Sent reports whether the intent has been enqueued.
+func (i Intent) Sent() bool { return !i.SentAt.IsZero() }
+
+// IntentStore is the durable storage the outbox relays from, usually the
+// store of the records that staged the intents.
+type IntentStore interface {
+	// Pending returns committed intents that have not been sent.
+	Pending(ctx context.Context) ([]Intent, error)
+	// MarkSent records that the intents with ids were enqueued at sentAt.
+	MarkSent(ctx context.Context, ids []string, sentAt time.Time) error
+}
+
+// DeliveryLog remembers which intents were enqueued so that relaying an
+// intent twice, for example after a crash before MarkSent, does not
+// enqueue its job twice.
+type DeliveryLog interface {
+	Delivered(ctx context.Context, id string) (bool, error)
+	MarkDelivered(ctx context.Context, id string) error
+}
+
+// Outbox stages jobs under a staging key and relays committed intents to a
+// queue exactly once.
+//
+// A writer stages jobs with Stage, takes them with Take and persists them
+// in the same write as its own data, then calls Send. Staged intents that
+// are never taken, such as those of a failed transaction, are dropped with
+// Discard. Relay re-sends intents that were committed but not sent, e.g.
+// after a crash.
+type Outbox struct {
+	queue  Queue
+	store  IntentStore
+	log    DeliveryLog
+	staged map[string][]Intent
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+// OutboxOption configures an Outbox.
+type OutboxOption func(*Outbox)
+
+// WithDeliveryLog sets the delivery log. The default in-memory log only
+// deduplicates within one process; use a RedisDeliveryLog to survive restarts.
+func WithDeliveryLog(log DeliveryLog) OutboxOption {
+	return func(o *Outbox) {
+		o.log = log
+	}
+}
+
+// NewOutbox creates an outbox relaying intents from store to queue.
+func NewOutbox(queue Queue, store IntentStore, opts ...OutboxOption) *Outbox {
+	o := &Outbox{
+		queue:  queue,
+		store:  store,
+		log:    NewMemoryDeliveryLog(),
+		staged: make(map[string][]Intent),
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Stage records job under key without enqueueing it. Intent IDs are derived
+// from key and the staging order, so staging the same jobs again under the
+// same key (a retried transaction) yields the same IDs. The job's ID is set
+// to the intent ID so consumers can recognize it too.
+func (o *Outbox) Stage(ctx context.Context, key string, job *Job) (Intent, error) {
+	if key == "" {
+		return Intent{}, fmt.Errorf("queue: outbox staging key cannot be empty")
+	}
+	if job == nil {
+		return Intent{}, fmt.Errorf("queue: cannot stage nil job")
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	intent := Intent{
+		ID:       fmt.Sprintf("%s#%d", key, len(o.staged[key])+1),
+		Key:      key,
+		Job:      job,
+		StagedAt: o.now(),
+	}
+	job.ID = intent.ID
+	o.staged[key] = append(o.staged[key], intent)
+	return intent, nil
+}
+
+// Take removes and returns the intents staged under key so the caller can
+// persist them.
+func (o *Outbox) Take(key string) []Intent {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	intents := o.staged[key]
+	delete(o.staged, key)
+	return intents
+}
+
+// Discard drops the intents staged under key.
+func (o *Outbox) Discard(key string) {
+	o.Take(key)
+}
+
+// Send enqueues the unsent intents, skipping those already delivered, and
+// marks them sent in the store. It returns the IDs marked sent.
+func (o *Outbox) Send(ctx context.Context, intents []Intent) ([]string, error) {
+	var sent []string
+	var errs []error
+	for _, intent := range intents {
+		if intent.Sent() {
+			continue
+		}
+		delivered, err := o.log.Delivered(ctx, intent.ID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !delivered {
+			if err := o.queue.Enqueue(ctx, intent.Job); err != nil {
+				errs = append(errs, fmt.Errorf("queue: outbox intent %s: %w", intent.ID, err))
+				continue
+			}
+			if err := o.log.MarkDelivered(ctx, intent.ID); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		sent = append(sent, intent.ID)
+	}
+
+	if len(sent) > 0 {
+		if err := o.store.MarkSent(ctx, sent, o.now()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return sent, errors.Join(errs...)
+}
+
+// Relay sends all pending intents from the store and returns how many were
+// marked sent.
+func (o *Outbox) Relay(ctx context.Context) (int, error) {
+	pending, err := o.store.Pending(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sent, err := o.Send(ctx, pending)
+	return len(sent), err
+}
+
+// StartRelay relays pending intents every interval until ctx is done.
+func (o *Outbox) StartRelay(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				o.Relay(ctx)
+			}
+		}
+	}()
+}
+
+// ============ Delivery Logs ============
+
+// MemoryDeliveryLog is an in-process DeliveryLog.
+type MemoryDeliveryLog struct {
+	ids map[string]bool
+	mu  sync.Mutex
+}
+
+// NewMemoryDeliveryLog creates an empty in-memory delivery log.
+func NewMemoryDeliveryLog() *MemoryDeliveryLog {
+	return &MemoryDeliveryLog{ids: make(map[string]bool)}
+}
+
+// Delivered reports whether id was marked delivered.
+func (l *MemoryDeliveryLog) Delivered(ctx context.Context, id string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ids[id], nil
+}
+
+// MarkDelivered records id as delivered.
+func (l *MemoryDeliveryLog) MarkDelivered(ctx context.Context, id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ids[id] = true
+	return nil
+}
+
+// RedisDeliveryLog stores delivered intent IDs in Redis/DragonflyDB.
+// Entries expire after seven days.
+type RedisDeliveryLog struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisDeliveryLog creates a delivery log in Redis.
+func NewRedisDeliveryLog(client *redis.Client) *RedisDeliveryLog {
+	return &RedisDeliveryLog{client: client, prefix: "goflow:outbox:delivered:"}
+}
+
+// Delivered reports whether id was marked delivered.
+func (l *RedisDeliveryLog) Delivered(ctx context.Context, id string) (bool, error) {
+	n, err := l.client.Exists(ctx, l.prefix+id).Result()
+	return n > 0, err
+}
+
+// MarkDelivered records id as delivered.
+func (l *RedisDeliveryLog) MarkDelivered(ctx context.Context, id string) error {
+	return l.client.Set(ctx, l.prefix+id, 1, 7*24*time.Hour).Err()
+}
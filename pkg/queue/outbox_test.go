@@ -0,0 +1,122 @@
+// Package queue_test provides tests for the job outbox.
+package queue_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/queue"
+)
+
+// memoryIntents is an IntentStore holding committed intents in a slice.
+type memoryIntents struct {
+	intents []queue.Intent
+	mu      sync.Mutex
+}
+
+func (m *memoryIntents) commit(intents ...queue.Intent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.intents = append(m.intents, intents...)
+}
+
+func (m *memoryIntents) Pending(ctx context.Context) ([]queue.Intent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var pending []queue.Intent
+	for _, intent := range m.intents {
+		if !intent.Sent() {
+			pending = append(pending, intent)
+		}
+	}
+	return pending, nil
+}
+
+func (m *memoryIntents) MarkSent(ctx context.Context, ids []string, sentAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.intents {
+		for _, id := range ids {
+			if m.intents[i].ID == id {
+				m.intents[i].SentAt = sentAt
+			}
+		}
+	}
+	return nil
+}
+
+func TestOutbox_StageTakeSend(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryIntents{}
+	q := queue.NewMemoryQueue()
+	outbox := queue.NewOutbox(q, store)
+
+	for i := 0; i < 2; i++ {
+		job, _ := queue.NewJob("email", i)
+		if _, err := outbox.Stage(ctx, "order-1", job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, _ := q.Len(ctx); n != 0 {
+		t.Fatal("Staging must not enqueue")
+	}
+
+	intents := outbox.Take("order-1")
+	if len(intents) != 2 || intents[0].ID != "order-1#1" || intents[1].Job.ID != "order-1#2" {
+		t.Fatalf("Unexpected intents: %+v", intents)
+	}
+	if len(outbox.Take("order-1")) != 0 {
+		t.Error("Take must drain the staging key")
+	}
+
+	store.commit(intents...)
+	if _, err := outbox.Send(ctx, intents); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := outbox.Relay(ctx); n != 0 {
+		t.Errorf("Expected nothing pending after send, relayed %d", n)
+	}
+	if n, _ := q.Len(ctx); n != 2 {
+		t.Errorf("Expected 2 jobs, got %d", n)
+	}
+}
+
+func TestOutbox_Discard(t *testing.T) {
+	ctx := context.Background()
+	outbox := queue.NewOutbox(queue.NewMemoryQueue(), &memoryIntents{})
+
+	job, _ := queue.NewJob("email", 1)
+	outbox.Stage(ctx, "tx", job)
+	outbox.Discard("tx")
+
+	intent, _ := outbox.Stage(ctx, "tx", job)
+	if intent.ID != "tx#1" {
+		t.Errorf("Expected restaged intent to reuse ID tx#1, got %s", intent.ID)
+	}
+	if _, err := outbox.Stage(ctx, "", job); err == nil {
+		t.Error("Expected error for empty staging key")
+	}
+}
+
+func TestOutbox_DeliveryLogDedup(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryIntents{}
+	q := queue.NewMemoryQueue()
+	log := queue.NewMemoryDeliveryLog()
+	outbox := queue.NewOutbox(q, store, queue.WithDeliveryLog(log))
+
+	job, _ := queue.NewJob("email", 1)
+	outbox.Stage(ctx, "tx", job)
+	intents := outbox.Take("tx")
+	store.commit(intents...)
+	log.MarkDelivered(ctx, intents[0].ID) // enqueued before a crash
+
+	if n, err := outbox.Relay(ctx); err != nil || n != 1 {
+		t.Fatalf("Expected intent marked sent, got %d, %v", n, err)
+	}
+	if n, _ := q.Len(ctx); n != 0 {
+		t.Errorf("Delivered intent must not be enqueued again, got %d jobs", n)
+	}
+}
@@ -0,0 +1,154 @@
+// Package queue provides a Postgres queue implementation.
+package queue
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed postgres_schema.sql
+var postgresSchema string
+
+// PostgresQueue implements DelayedQueue on a Postgres table. Dequeue claims
+// rows with SELECT ... FOR UPDATE SKIP LOCKED, so any number of workers can
+// share a queue without handing out a job twice.
+type PostgresQueue struct {
+	pool         *pgxpool.Pool
+	name         string
+	pollInterval time.Duration
+}
+
+// PostgresOption configures a PostgresQueue.
+type PostgresOption func(*PostgresQueue)
+
+// WithPollInterval sets how often a blocked Dequeue checks for new jobs.
+// Default: 250ms.
+func WithPollInterval(d time.Duration) PostgresOption {
+	return func(pq *PostgresQueue) {
+		pq.pollInterval = d
+	}
+}
+
+// NewPostgresQueue creates a queue named name and migrates the schema.
+// The pool is owned by the caller; Close does not close it.
+func NewPostgresQueue(ctx context.Context, pool *pgxpool.Pool, name string, opts ...PostgresOption) (*PostgresQueue, error) {
+	pq := &PostgresQueue{
+		pool:         pool,
+		name:         name,
+		pollInterval: 250 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(pq)
+	}
+	if _, err := pool.Exec(ctx, postgresSchema); err != nil {
+		return nil, fmt.Errorf("queue: migrate postgres schema: %w", err)
+	}
+	return pq, nil
+}
+
+// Enqueue adds a job to the queue.
+func (pq *PostgresQueue) Enqueue(ctx context.Context, job *Job) error {
+	return pq.enqueue(ctx, job, nil)
+}
+
+// EnqueueAt adds a job that becomes available at the given time.
+func (pq *PostgresQueue) EnqueueAt(ctx context.Context, job *Job, at time.Time) error {
+	return pq.enqueue(ctx, job, &at)
+}
+
+func (pq *PostgresQueue) enqueue(ctx context.Context, job *Job, at *time.Time) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("queue: failed to marshal job: %w", err)
+	}
+	_, err = pq.pool.Exec(ctx, `
+		INSERT INTO goflow_jobs (queue, id, priority, available_at, job)
+		VALUES ($1, $2, $3, COALESCE($4, now()), $5)`,
+		pq.name, job.ID, job.Priority, at, data)
+	if err != nil {
+		return fmt.Errorf("queue: enqueue failed: %w", err)
+	}
+	return nil
+}
+
+// Dequeue removes and returns the available job with the highest priority,
+// oldest first. It polls until timeout and returns nil if none arrives.
+func (pq *PostgresQueue) Dequeue(ctx context.Context, timeout time.Duration) (*Job, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		job, err := pq.claim(ctx)
+		if err != nil || job != nil {
+			return job, err
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, nil
+		}
+		timer := time.NewTimer(min(wait, pq.pollInterval))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// claim deletes and returns the next available job without blocking.
+func (pq *PostgresQueue) claim(ctx context.Context) (*Job, error) {
+	var data []byte
+	err := pq.pool.QueryRow(ctx, `
+		DELETE FROM goflow_jobs
+		WHERE seq = (
+			SELECT seq FROM goflow_jobs
+			WHERE queue = $1 AND available_at <= now()
+			ORDER BY priority DESC, seq
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING job`, pq.name).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("queue: dequeue failed: %w", err)
+	}
+	return decodeJob(data)
+}
+
+// Peek returns the next available job without removing it.
+func (pq *PostgresQueue) Peek(ctx context.Context) (*Job, error) {
+	var data []byte
+	err := pq.pool.QueryRow(ctx, `
+		SELECT job FROM goflow_jobs
+		WHERE queue = $1 AND available_at <= now()
+		ORDER BY priority DESC, seq
+		LIMIT 1`, pq.name).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("queue: peek failed: %w", err)
+	}
+	return decodeJob(data)
+}
+
+// Len returns the number of jobs in the queue, including delayed jobs.
+func (pq *PostgresQueue) Len(ctx context.Context) (int64, error) {
+	var n int64
+	err := pq.pool.QueryRow(ctx, `SELECT count(*) FROM goflow_jobs WHERE queue = $1`, pq.name).Scan(&n)
+	return n, err
+}
+
+// Close is a no-op; the pool belongs to the caller.
+func (pq *PostgresQueue) Close() error {
+	return nil
+}
@@ -0,0 +1,14 @@
+-- Schema for PostgresQueue. Statements are idempotent and run on startup.
+
+CREATE TABLE IF NOT EXISTS goflow_jobs (
+    seq          BIGSERIAL PRIMARY KEY,
+    queue        TEXT NOT NULL,
+    id           TEXT NOT NULL,
+    priority     INTEGER NOT NULL DEFAULT 0,
+    available_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    job          JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS goflow_jobs_dequeue_idx ON goflow_jobs (queue, priority DESC, seq);
+CREATE INDEX IF NOT EXISTS goflow_jobs_available_at_idx ON goflow_jobs (queue, available_at);
@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/nuulab/goflow/pkg/metrics"
 )
 
 // Queue defines the interface for job queue operations.
@@ -31,6 +33,14 @@ type Queue interface {
 	Close() error
 }
 
+// DelayedQueue is a Queue that can hold jobs back until a given time.
+type DelayedQueue interface {
+	Queue
+
+	// EnqueueAt adds a job that becomes available at the given time.
+	EnqueueAt(ctx context.Context, job *Job, at time.Time) error
+}
+
 // Job represents a unit of work in the queue.
 // It is safe for concurrent use when using the fluent API methods.
 type Job struct {
@@ -87,6 +97,10 @@ func (j *Job) WithMaxRetries(n int) *Job {
 	return j
 }
 
+// MetadataTraceID is the metadata key of a job's trace ID. Workers attach
+// it, with the job ID, as the exemplar of the job's duration.
+const MetadataTraceID = "trace_id"
+
 // WithMetadata adds metadata to the job (fluent API).
 // It is safe for concurrent use.
 func (j *Job) WithMetadata(key, value string) *Job {
@@ -186,14 +200,34 @@ func (w *Worker) processJob(ctx context.Context, job *Job) {
 		return
 	}
 
+	metrics.JobDequeued()
+	start := time.Now()
 	err := handler(ctx, job)
-	if err != nil {
-		job.Attempts++
-		if job.Attempts < job.MaxRetries {
-			// Re-queue for retry
-			_ = w.queue.Enqueue(ctx, job)
-		}
+	job.mu.Lock()
+	traceID := job.Metadata[MetadataTraceID]
+	job.mu.Unlock()
+	metrics.ObserveJobRun(start, job.ID, traceID)
+	if err == nil {
+		metrics.JobCompleted()
+		return
+	}
+	metrics.JobFailed()
+	job.Attempts++
+	if job.Attempts < job.MaxRetries {
+		// Re-queue for retry
+		metrics.JobRetried()
+		_ = w.queue.Enqueue(ctx, job)
+		return
 	}
+	// Out of retries: dead-letter the job where the queue keeps a DLQ
+	if dlq, ok := w.queue.(deadLetterQueue); ok {
+		_ = dlq.MoveToDLQ(ctx, job, err.Error())
+	}
+}
+
+// deadLetterQueue is a queue that keeps jobs that failed permanently.
+type deadLetterQueue interface {
+	MoveToDLQ(ctx context.Context, job *Job, reason string) error
 }
 
 // generateID creates a unique job ID using crypto/rand.
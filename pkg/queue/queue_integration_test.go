@@ -0,0 +1,85 @@
+//go:build integration
+
+// Package queue_test runs the queue conformance suite against real backends.
+// Set GOFLOW_TEST_POSTGRES_DSN and GOFLOW_TEST_REDIS_ADDR and run with
+// -tags integration.
+package queue_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nuulab/goflow/pkg/queue"
+	"github.com/nuulab/goflow/pkg/queue/queuetest"
+)
+
+// ephemeralPool returns a pool bound to a fresh schema that is dropped
+// when the test ends.
+func ephemeralPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("GOFLOW_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("GOFLOW_TEST_POSTGRES_DSN not set")
+	}
+	ctx := context.Background()
+	admin, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("goflow_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+		admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		admin.Close()
+	})
+	return pool
+}
+
+func TestPostgresQueue(t *testing.T) {
+	queuetest.Run(t, func(t *testing.T) queue.Queue {
+		q, err := queue.NewPostgresQueue(context.Background(), ephemeralPool(t), "conformance",
+			queue.WithPollInterval(20*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return q
+	})
+}
+
+func TestDragonflyQueue(t *testing.T) {
+	addr := os.Getenv("GOFLOW_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("GOFLOW_TEST_REDIS_ADDR not set")
+	}
+	queuetest.Run(t, func(t *testing.T) queue.Queue {
+		name := fmt.Sprintf("goflow-test:%d", time.Now().UnixNano())
+		q, err := queue.NewDragonflyQueue(queue.Config{Address: addr, QueueName: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			q.Client().Del(context.Background(), name, name+":priority")
+			q.Close()
+		})
+		return q
+	})
+}
@@ -4,9 +4,11 @@ package queue_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/nuulab/goflow/pkg/metrics"
 	"github.com/nuulab/goflow/pkg/queue"
 )
 
@@ -157,6 +159,51 @@ func TestWorker_Handle(t *testing.T) {
 	}
 }
 
+// deadLetterMemoryQueue is a MemoryQueue that records dead-lettered jobs.
+type deadLetterMemoryQueue struct {
+	*queue.MemoryQueue
+	dead chan *queue.Job
+}
+
+func (q *deadLetterMemoryQueue) MoveToDLQ(ctx context.Context, job *queue.Job, reason string) error {
+	q.dead <- job
+	return nil
+}
+
+func TestWorker_CountsOutcomesAndDeadLetters(t *testing.T) {
+	value := func(name string) float64 {
+		v, _ := metrics.DefaultMetrics.Value(name)
+		return v
+	}
+	failed, retried := value("goflow_jobs_failed_total"), value("goflow_jobs_retried_total")
+
+	q := &deadLetterMemoryQueue{MemoryQueue: queue.NewMemoryQueue(), dead: make(chan *queue.Job, 1)}
+	worker := queue.NewWorker(q)
+	worker.Handle("flaky", func(ctx context.Context, job *queue.Job) error {
+		return errors.New("boom")
+	})
+	job, _ := queue.NewJob("flaky", map[string]string{})
+	q.Enqueue(context.Background(), job.WithMaxRetries(2))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	select {
+	case dead := <-q.dead:
+		if dead.ID != job.ID || dead.Attempts != 2 {
+			t.Errorf("Expected the job dead-lettered after 2 attempts, got %+v", dead)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Job was not dead-lettered")
+	}
+	if got := value("goflow_jobs_failed_total") - failed; got != 2 {
+		t.Errorf("Expected 2 failures counted, got %v", got)
+	}
+	if got := value("goflow_jobs_retried_total") - retried; got != 1 {
+		t.Errorf("Expected 1 retry counted, got %v", got)
+	}
+}
+
 func TestJob_Serialization(t *testing.T) {
 	job, _ := queue.NewJob("serialization_test", map[string]int{"value": 123})
 	job.WithPriority(5).WithMaxRetries(3)
@@ -184,3 +231,34 @@ func TestJob_Serialization(t *testing.T) {
 		t.Error("Priority mismatch")
 	}
 }
+
+func TestWorker_RecordsJobExemplar(t *testing.T) {
+	duration := metrics.DefaultMetrics.JobDuration
+	duration.EnableExemplars(1)
+	defer duration.EnableExemplars(0)
+	before := duration.Count()
+
+	q := queue.NewMemoryQueue()
+	worker := queue.NewWorker(q)
+	worker.Handle("traced", func(ctx context.Context, job *queue.Job) error { return nil })
+	job, _ := queue.NewJob("traced", map[string]string{})
+	q.Enqueue(context.Background(), job.WithMetadata(queue.MetadataTraceID, "trace-1"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker.Start(ctx, 1)
+	deadline := time.Now().Add(5 * time.Second)
+	for duration.Count() == before {
+		if time.Now().After(deadline) {
+			t.Fatal("Job duration was not observed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, ex := range duration.Exemplars() {
+		if ex.Labels["run_id"] == job.ID && ex.Labels["trace_id"] == "trace-1" {
+			return
+		}
+	}
+	t.Errorf("Expected an exemplar for job %s, got %+v", job.ID, duration.Exemplars())
+}
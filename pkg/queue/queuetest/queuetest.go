@@ -0,0 +1,224 @@
+// Package queuetest provides a conformance suite for queue.Queue
+// implementations.
+package queuetest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/queue"
+)
+
+// Factory returns an empty queue for one subtest. It should register any
+// cleanup with t.
+type Factory func(t *testing.T) queue.Queue
+
+// Run runs the conformance suite against queues created by newQueue.
+// Delay tests run only for queues implementing queue.DelayedQueue.
+func Run(t *testing.T, newQueue Factory) {
+	t.Run("EmptyDequeueTimesOut", func(t *testing.T) { testEmpty(t, newQueue(t)) })
+	t.Run("RoundTrip", func(t *testing.T) { testRoundTrip(t, newQueue(t)) })
+	t.Run("FIFO", func(t *testing.T) { testFIFO(t, newQueue(t)) })
+	t.Run("Priority", func(t *testing.T) { testPriority(t, newQueue(t)) })
+	t.Run("PeekAndLen", func(t *testing.T) { testPeekAndLen(t, newQueue(t)) })
+	t.Run("ConcurrentDequeue", func(t *testing.T) { testConcurrent(t, newQueue(t)) })
+	t.Run("Delayed", func(t *testing.T) {
+		q, ok := newQueue(t).(queue.DelayedQueue)
+		if !ok {
+			t.Skip("queue does not implement DelayedQueue")
+		}
+		testDelayed(t, q)
+	})
+}
+
+func newJob(t *testing.T, n int) *queue.Job {
+	t.Helper()
+	job, err := queue.NewJob("conformance", map[string]int{"n": n})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func jobNumber(t *testing.T, job *queue.Job) int {
+	t.Helper()
+	var payload struct{ N int }
+	if err := job.UnmarshalPayload(&payload); err != nil {
+		t.Fatal(err)
+	}
+	return payload.N
+}
+
+func enqueue(t *testing.T, q queue.Queue, jobs ...*queue.Job) {
+	t.Helper()
+	for _, job := range jobs {
+		if err := q.Enqueue(context.Background(), job); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+}
+
+func dequeue(t *testing.T, q queue.Queue) *queue.Job {
+	t.Helper()
+	job, err := q.Dequeue(context.Background(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("Dequeue: %v", err)
+	}
+	if job == nil {
+		t.Fatal("Dequeue returned no job")
+	}
+	return job
+}
+
+func testEmpty(t *testing.T, q queue.Queue) {
+	start := time.Now()
+	job, err := q.Dequeue(context.Background(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Dequeue: %v", err)
+	}
+	if job != nil {
+		t.Fatalf("expected no job, got %s", job.ID)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Dequeue waited %v for a 200ms timeout", elapsed)
+	}
+
+	job, err = q.Peek(context.Background())
+	if err != nil || job != nil {
+		t.Errorf("Peek on empty queue = %v, %v", job, err)
+	}
+}
+
+func testRoundTrip(t *testing.T, q queue.Queue) {
+	job := newJob(t, 7).WithMaxRetries(3).WithMetadata("tenant", "acme")
+	job.Attempts = 1
+	enqueue(t, q, job)
+
+	got := dequeue(t, q)
+	if got.ID != job.ID || got.Type != job.Type || got.MaxRetries != 3 || got.Attempts != 1 {
+		t.Errorf("job fields not preserved: %+v", got)
+	}
+	if got.Metadata["tenant"] != "acme" {
+		t.Errorf("metadata not preserved: %v", got.Metadata)
+	}
+	if jobNumber(t, got) != 7 {
+		t.Errorf("payload not preserved: %s", got.Payload)
+	}
+	if !got.CreatedAt.Equal(job.CreatedAt) {
+		t.Errorf("CreatedAt = %v, want %v", got.CreatedAt, job.CreatedAt)
+	}
+}
+
+func testFIFO(t *testing.T, q queue.Queue) {
+	for i := 0; i < 5; i++ {
+		enqueue(t, q, newJob(t, i))
+	}
+	for i := 0; i < 5; i++ {
+		if n := jobNumber(t, dequeue(t, q)); n != i {
+			t.Fatalf("dequeue %d returned job %d", i, n)
+		}
+	}
+}
+
+func testPriority(t *testing.T, q queue.Queue) {
+	enqueue(t, q,
+		newJob(t, 0),
+		newJob(t, 5).WithPriority(5),
+		newJob(t, 1).WithPriority(1),
+		newJob(t, 10).WithPriority(10),
+	)
+	for _, want := range []int{10, 5, 1, 0} {
+		if n := jobNumber(t, dequeue(t, q)); n != want {
+			t.Fatalf("got job %d, want %d", n, want)
+		}
+	}
+}
+
+func testPeekAndLen(t *testing.T, q queue.Queue) {
+	ctx := context.Background()
+	enqueue(t, q, newJob(t, 1), newJob(t, 2))
+
+	if n, err := q.Len(ctx); err != nil || n != 2 {
+		t.Fatalf("Len = %d, %v; want 2", n, err)
+	}
+	job, err := q.Peek(ctx)
+	if err != nil || job == nil {
+		t.Fatalf("Peek = %v, %v", job, err)
+	}
+	if jobNumber(t, job) != 1 {
+		t.Errorf("Peek returned job %d, want 1", jobNumber(t, job))
+	}
+	if n, _ := q.Len(ctx); n != 2 {
+		t.Errorf("Peek removed a job: Len = %d", n)
+	}
+
+	dequeue(t, q)
+	if n, _ := q.Len(ctx); n != 1 {
+		t.Errorf("Len after dequeue = %d, want 1", n)
+	}
+}
+
+func testConcurrent(t *testing.T, q queue.Queue) {
+	const jobs, workers = 50, 8
+	for i := 0; i < jobs; i++ {
+		enqueue(t, q, newJob(t, i))
+	}
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := q.Dequeue(context.Background(), 300*time.Millisecond)
+				if err != nil {
+					t.Errorf("Dequeue: %v", err)
+					return
+				}
+				if job == nil {
+					return
+				}
+				mu.Lock()
+				seen[job.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != jobs {
+		t.Errorf("dequeued %d distinct jobs, want %d", len(seen), jobs)
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("job %s dequeued %d times", id, n)
+		}
+	}
+}
+
+func testDelayed(t *testing.T, q queue.DelayedQueue) {
+	ctx := context.Background()
+	if err := q.EnqueueAt(ctx, newJob(t, 1), time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("EnqueueAt: %v", err)
+	}
+	enqueue(t, q, newJob(t, 2))
+
+	if n := jobNumber(t, dequeue(t, q)); n != 2 {
+		t.Fatalf("got job %d before the delayed job was due, want 2", n)
+	}
+	if job, err := q.Dequeue(ctx, 100*time.Millisecond); err != nil || job != nil {
+		t.Fatalf("delayed job returned early: %v, %v", job, err)
+	}
+
+	job, err := q.Dequeue(ctx, 5*time.Second)
+	if err != nil || job == nil {
+		t.Fatalf("delayed job not delivered: %v, %v", job, err)
+	}
+	if jobNumber(t, job) != 1 {
+		t.Errorf("got job %d, want 1", jobNumber(t, job))
+	}
+}
@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// JobSchemas holds the payload schema of each registered job type, so
+// producers such as schedules and webhooks can validate payloads before
+// they are enqueued. It is safe for concurrent use.
+type JobSchemas struct {
+	mu      sync.RWMutex
+	schemas map[string]tools.Schema
+}
+
+// NewJobSchemas creates an empty schema registry.
+func NewJobSchemas() *JobSchemas {
+	return &JobSchemas{schemas: make(map[string]tools.Schema)}
+}
+
+// Register sets the payload schema for jobType.
+func (s *JobSchemas) Register(jobType string, schema tools.Schema) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schemas[jobType] = schema
+}
+
+// Get returns the payload schema for jobType.
+func (s *JobSchemas) Get(jobType string) (tools.Schema, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	schema, ok := s.schemas[jobType]
+	return schema, ok
+}
+
+// Validate checks payload against the schema registered for jobType. Job
+// types without a schema accept any payload.
+func (s *JobSchemas) Validate(jobType string, payload map[string]any) error {
+	schema, ok := s.Get(jobType)
+	if !ok {
+		return nil
+	}
+	if err := schema.Validate(payload); err != nil {
+		return fmt.Errorf("queue: %s payload: %w", jobType, err)
+	}
+	return nil
+}
@@ -307,115 +307,3 @@ func (we *WorkflowEngine) Resume(ctx context.Context, workflowID string) error {
 	
 	return nil
 }
-
-// DAGWorkflow represents a Directed Acyclic Graph workflow.
-type DAGWorkflow struct {
-	nodes map[string]*DAGNode
-	edges map[string][]string // node -> dependencies
-}
-
-// DAGNode is a node in the DAG workflow.
-type DAGNode struct {
-	Name    string
-	Handler func(ctx context.Context, inputs map[string]any) (any, error)
-}
-
-// NewDAGWorkflow creates a DAG workflow.
-func NewDAGWorkflow() *DAGWorkflow {
-	return &DAGWorkflow{
-		nodes: make(map[string]*DAGNode),
-		edges: make(map[string][]string),
-	}
-}
-
-// Node adds a node to the DAG.
-func (dw *DAGWorkflow) Node(name string, handler func(ctx context.Context, inputs map[string]any) (any, error)) *DAGWorkflow {
-	dw.nodes[name] = &DAGNode{
-		Name:    name,
-		Handler: handler,
-	}
-	return dw
-}
-
-// Edge adds a dependency edge (from depends on to).
-func (dw *DAGWorkflow) Edge(from, to string) *DAGWorkflow {
-	dw.edges[from] = append(dw.edges[from], to)
-	return dw
-}
-
-// Execute runs the DAG workflow.
-func (dw *DAGWorkflow) Execute(ctx context.Context, input any) (map[string]any, error) {
-	results := make(map[string]any)
-	results["_input"] = input
-	
-	completed := make(map[string]bool)
-	var mu sync.Mutex
-	
-	// Find nodes with no dependencies
-	var ready []string
-	for name := range dw.nodes {
-		if len(dw.edges[name]) == 0 {
-			ready = append(ready, name)
-		}
-	}
-	
-	for len(completed) < len(dw.nodes) {
-		if len(ready) == 0 {
-			return results, fmt.Errorf("deadlock detected in DAG")
-		}
-		
-		// Execute ready nodes in parallel
-		var wg sync.WaitGroup
-		for _, name := range ready {
-			wg.Add(1)
-			go func(nodeName string) {
-				defer wg.Done()
-				
-				node := dw.nodes[nodeName]
-				
-				// Gather inputs from dependencies
-				inputs := make(map[string]any)
-				for _, dep := range dw.edges[nodeName] {
-					mu.Lock()
-					inputs[dep] = results[dep]
-					mu.Unlock()
-				}
-				inputs["_input"] = input
-				
-				result, err := node.Handler(ctx, inputs)
-				
-				mu.Lock()
-				if err != nil {
-					results[nodeName] = fmt.Sprintf("error: %v", err)
-				} else {
-					results[nodeName] = result
-				}
-				completed[nodeName] = true
-				mu.Unlock()
-			}(name)
-		}
-		wg.Wait()
-		
-		// Find newly ready nodes
-		ready = nil
-		for name := range dw.nodes {
-			if completed[name] {
-				continue
-			}
-			
-			allDepsComplete := true
-			for _, dep := range dw.edges[name] {
-				if !completed[dep] {
-					allDepsComplete = false
-					break
-				}
-			}
-			
-			if allDepsComplete {
-				ready = append(ready, name)
-			}
-		}
-	}
-	
-	return results, nil
-}
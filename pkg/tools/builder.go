@@ -16,6 +16,10 @@ type ToolBuilder struct {
 	examples    []string
 	category    string
 	tags        []string
+	confirm     bool
+	freeform    bool
+	cacheable   bool
+	version     string
 }
 
 type paramDef struct {
@@ -54,6 +58,32 @@ func (b *ToolBuilder) Tags(tags ...string) *ToolBuilder {
 	return b
 }
 
+// RequiresConfirmation marks the tool as needing approval before agents
+// call it.
+func (b *ToolBuilder) RequiresConfirmation() *ToolBuilder {
+	b.confirm = true
+	return b
+}
+
+// SkipValidation lets the tool accept input that does not match its
+// parameters.
+func (b *ToolBuilder) SkipValidation() *ToolBuilder {
+	b.freeform = true
+	return b
+}
+
+// Cacheable marks the tool's output as depending only on its input.
+func (b *ToolBuilder) Cacheable() *ToolBuilder {
+	b.cacheable = true
+	return b
+}
+
+// CacheVersion sets the tool's CacheVersion.
+func (b *ToolBuilder) CacheVersion(version string) *ToolBuilder {
+	b.version = version
+	return b
+}
+
 // Param adds a required parameter.
 func (b *ToolBuilder) Param(name, paramType, description string) *ToolBuilder {
 	b.params = append(b.params, paramDef{
@@ -100,6 +130,18 @@ func (b *ToolBuilder) EnumParam(name, description string, values ...string) *Too
 	return b
 }
 
+// OptionalEnumParam adds an optional parameter with enum constraints.
+func (b *ToolBuilder) OptionalEnumParam(name, description string, values ...string) *ToolBuilder {
+	b.params = append(b.params, paramDef{
+		name:        name,
+		paramType:   "string",
+		description: description,
+		required:    false,
+		enumValues:  values,
+	})
+	return b
+}
+
 // Example adds a usage example.
 func (b *ToolBuilder) Example(example string) *ToolBuilder {
 	b.examples = append(b.examples, example)
@@ -123,10 +165,14 @@ func (b *ToolBuilder) Create() *Tool {
 	schema := b.buildSchema()
 
 	return &Tool{
-		Name:        b.name,
-		Description: b.buildDescription(),
-		Parameters:  schema,
-		Execute:     b.createExecutor(),
+		Name:                 b.name,
+		Description:          b.buildDescription(),
+		Parameters:           schema,
+		Execute:              b.createExecutor(),
+		RequiresConfirmation: b.confirm,
+		SkipValidation:       b.freeform,
+		Cacheable:            b.cacheable,
+		CacheVersion:         b.version,
 	}
 }
 
@@ -174,16 +220,12 @@ func (b *ToolBuilder) createExecutor() func(ctx context.Context, jsonInput strin
 	return func(ctx context.Context, jsonInput string) (string, error) {
 		switch fn := b.handler.(type) {
 		case func(ctx context.Context, input string) (string, error):
-			// Simple string handler - extract first param or use raw
+			// Simple string handler - unwrap a single string param or
+			// pass the raw JSON for handlers with several params
 			var params map[string]any
-			if err := json.Unmarshal([]byte(jsonInput), &params); err == nil {
-				// Try to get the first param value
-				for _, p := range b.params {
-					if val, ok := params[p.name]; ok {
-						if strVal, ok := val.(string); ok {
-							return fn(ctx, strVal)
-						}
-					}
+			if len(b.params) == 1 && json.Unmarshal([]byte(jsonInput), &params) == nil {
+				if strVal, ok := params[b.params[0].name].(string); ok {
+					return fn(ctx, strVal)
 				}
 			}
 			return fn(ctx, jsonInput)
@@ -195,11 +237,13 @@ func (b *ToolBuilder) createExecutor() func(ctx context.Context, jsonInput strin
 	}
 }
 
-// QuickTool creates a simple tool with minimal configuration.
+// QuickTool creates a simple tool with minimal configuration. Its input is
+// free-form: fn gets the "input" parameter, or the raw input without it.
 func QuickTool(name, description string, fn func(ctx context.Context, input string) (string, error)) *Tool {
 	return Build(name).
 		Description(description).
 		Param("input", "string", "The input to process").
+		SkipValidation().
 		Handler(fn).
 		Create()
 }
@@ -109,6 +109,7 @@ func CalculatorTool() *Tool {
 	return &Tool{
 		Name:        "calculator",
 		Description: "Performs basic arithmetic calculations. Supports add, subtract, multiply, divide operations.",
+		Cacheable:   true,
 		Parameters: Schema{
 			Type: "object",
 			Properties: map[string]Property{
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/cache"
+	"github.com/nuulab/goflow/pkg/metrics"
+)
+
+// WithCache serves repeated calls to Cacheable tools of r from c, and
+// returns r. Outputs are keyed by the tool name, its CacheVersion and the
+// input, with JSON inputs compared by value, and kept for ttl. Failed
+// calls are not cached.
+func (r *Registry) WithCache(c cache.Cache, ttl time.Duration) *Registry {
+	r.Use(func(next ToolFunc) ToolFunc {
+		return func(ctx context.Context, name, jsonInput string) (string, error) {
+			tool, ok := r.Get(name)
+			if !ok || !tool.Cacheable {
+				return next(ctx, name, jsonInput)
+			}
+			key := cacheKey(tool, jsonInput)
+			if data, err := c.Get(ctx, key); err == nil {
+				metrics.ToolCacheHit()
+				return string(data), nil
+			}
+			metrics.ToolCacheMiss()
+
+			output, err := next(ctx, name, jsonInput)
+			if err == nil {
+				// A cache that fails to store only costs a later miss.
+				_ = c.Set(ctx, key, []byte(output), ttl)
+			}
+			return output, err
+		}
+	})
+	return r
+}
+
+// cacheKey prefixes a hash of the tool's input, re-encoded if it is JSON
+// so that formatting and key order do not matter, with the tool's name
+// and CacheVersion. Numbers keep their literal digits, so large integers
+// that would round to the same float64 still get different keys.
+func cacheKey(tool *Tool, jsonInput string) string {
+	input := []byte(jsonInput)
+	var value any
+	dec := json.NewDecoder(strings.NewReader(jsonInput))
+	dec.UseNumber()
+	if dec.Decode(&value) == nil && dec.Decode(new(any)) == io.EOF {
+		input, _ = json.Marshal(value)
+	}
+	version := tool.CacheVersion
+	if version == "" {
+		version = "0"
+	}
+	sum := sha256.Sum256(input)
+	return "tools:result:" + tool.Name + ":" + version + ":" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,130 @@
+package tools_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/cache"
+	"github.com/nuulab/goflow/pkg/metrics"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestRegistry_WithCache(t *testing.T) {
+	runs := map[string]int{}
+	counting := func(name string) *tools.ToolBuilder {
+		return tools.Build(name).
+			OptionalParam("n", "integer", "A number").
+			OptionalParam("fail", "boolean", "Fail the call").
+			Handler(func(ctx context.Context, input string) (string, error) {
+				runs[name]++
+				if input == `{"fail": true}` {
+					return "", errors.New("flaky")
+				}
+				return input, nil
+			})
+	}
+	registry := tools.NewRegistry().WithCache(cache.NewMemoryCache(cache.Config{}), time.Minute)
+	registry.Register(counting("stats").Cacheable().Create())
+	registry.Register(counting("post").Create())
+	ctx := context.Background()
+	hits, misses := metrics.DefaultMetrics.ToolCacheHits.Value(), metrics.DefaultMetrics.ToolCacheMisses.Value()
+
+	first, _ := registry.Execute(ctx, "stats", `{"n": 1}`)
+	second, _ := registry.Execute(ctx, "stats", `{ "n":1 }`) // same value, different formatting
+	if runs["stats"] != 1 || second != first {
+		t.Errorf("Expected the second call served from the cache, ran %d times (%q)", runs["stats"], second)
+	}
+	results := registry.ExecuteCalls(ctx, []tools.ToolCall{{ID: "1", Name: "stats", Arguments: `{"n": 1}`}})
+	if runs["stats"] != 1 || results[0].Content != first {
+		t.Errorf("Expected ExecuteCalls to use the cache, ran %d times", runs["stats"])
+	}
+	registry.Execute(ctx, "stats", `{"n": 2}`)
+	if runs["stats"] != 2 {
+		t.Errorf("Expected a different input to run, ran %d times", runs["stats"])
+	}
+
+	// Errors are not cached.
+	registry.Execute(ctx, "stats", `{"fail": true}`)
+	if _, err := registry.Execute(ctx, "stats", `{"fail": true}`); err == nil || runs["stats"] != 4 {
+		t.Errorf("Expected failed calls to run again, ran %d times (%v)", runs["stats"], err)
+	}
+
+	// Tools that are not cacheable always run.
+	registry.Execute(ctx, "post", `{"n": 1}`)
+	registry.Execute(ctx, "post", `{"n": 1}`)
+	if runs["post"] != 2 {
+		t.Errorf("Expected the uncacheable tool to run every time, ran %d times", runs["post"])
+	}
+
+	if got := metrics.DefaultMetrics.ToolCacheHits.Value() - hits; got != 2 {
+		t.Errorf("Expected 2 hits, got %v", got)
+	}
+	if got := metrics.DefaultMetrics.ToolCacheMisses.Value() - misses; got != 4 {
+		t.Errorf("Expected 4 misses, got %v", got)
+	}
+}
+
+func TestRegistry_WithCacheLargeIntegers(t *testing.T) {
+	runs := 0
+	registry := tools.NewRegistry().WithCache(cache.NewMemoryCache(cache.Config{}), time.Minute)
+	registry.Register(tools.Build("user").
+		OptionalParam("id", "integer", "User ID").
+		Cacheable().
+		Handler(func(ctx context.Context, input string) (string, error) {
+			runs++
+			return input, nil
+		}).
+		Create())
+	ctx := context.Background()
+
+	// Both IDs round to the same float64.
+	first, _ := registry.Execute(ctx, "user", `{"id": 18014398509481985}`)
+	second, _ := registry.Execute(ctx, "user", `{"id": 18014398509481986}`)
+	if runs != 2 || first == second {
+		t.Errorf("Expected both IDs to miss the cache, ran %d times (%q, %q)", runs, first, second)
+	}
+}
+
+// keyRecorder is a cache that records the keys it stores.
+type keyRecorder struct {
+	cache.Cache
+	keys []string
+}
+
+func (c *keyRecorder) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.keys = append(c.keys, key)
+	return c.Cache.Set(ctx, key, value, ttl)
+}
+
+func TestRegistry_WithCacheKeys(t *testing.T) {
+	shared := &keyRecorder{Cache: cache.NewMemoryCache(cache.Config{})}
+	ctx := context.Background()
+	runs := 0
+	tool := func(version string) *tools.Tool {
+		return tools.Build("lookup").
+			OptionalParam("n", "integer", "A number").
+			Cacheable().CacheVersion(version).
+			Handler(func(ctx context.Context, input string) (string, error) {
+				runs++
+				return version, nil
+			}).
+			Create()
+	}
+
+	v1 := tools.NewRegistry().WithCache(shared, time.Minute)
+	v1.Register(tool("1"))
+	v1.Execute(ctx, "lookup", `{"n": 1}`)
+	if len(shared.keys) != 1 || !strings.HasPrefix(shared.keys[0], "tools:result:lookup:1:") {
+		t.Fatalf("Expected a key prefixed with the tool name and version, got %v", shared.keys)
+	}
+
+	// A new version of the tool does not read the old version's entries.
+	v2 := tools.NewRegistry().WithCache(shared, time.Minute)
+	v2.Register(tool("2"))
+	if out, _ := v2.Execute(ctx, "lookup", `{"n": 1}`); out != "2" || runs != 2 {
+		t.Errorf("Expected version 2 to run, got %q after %d runs", out, runs)
+	}
+}
@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// PartialError is returned by Registry.Execute when a run was cancelled
+// while a tool was working and the tool reported partial output.
+type PartialError struct {
+	Tool   string
+	Output string
+	Err    error // the cancellation cause
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("tools: %s cancelled with partial output: %v", e.Tool, e.Err)
+}
+
+func (e *PartialError) Unwrap() error { return e.Err }
+
+// cancelToken collects the partial output of a tool execution.
+type cancelToken struct {
+	mu      sync.Mutex
+	output  string
+	partial bool
+}
+
+type cancelTokenKey struct{}
+
+// Canceled reports whether the run executing the tool has been cancelled.
+// Long-running handlers should poll it, stop early and report what they
+// have so far with PartialResult.
+func Canceled(ctx context.Context) bool {
+	return ctx.Err() != nil
+}
+
+// PartialResult records output produced before cancellation. Registry.Execute
+// returns it wrapped in a PartialError. Later calls replace earlier output.
+// It is a no-op outside Registry.Execute.
+func PartialResult(ctx context.Context, output string) {
+	token, ok := ctx.Value(cancelTokenKey{}).(*cancelToken)
+	if !ok {
+		return
+	}
+	token.mu.Lock()
+	defer token.mu.Unlock()
+	token.output = output
+	token.partial = true
+}
+
+// executeCancellable runs tool with a cancellation token installed in ctx.
+func executeCancellable(ctx context.Context, tool *Tool, jsonInput string) (string, error) {
+	token := &cancelToken{}
+	output, err := tool.Execute(context.WithValue(ctx, cancelTokenKey{}, token), jsonInput)
+
+	token.mu.Lock()
+	defer token.mu.Unlock()
+	if !token.partial || ctx.Err() == nil {
+		return output, err
+	}
+	cause := context.Cause(ctx)
+	if err != nil && !errors.Is(err, ctx.Err()) {
+		cause = err
+	}
+	return token.output, &PartialError{Tool: tool.Name, Output: token.output, Err: cause}
+}
@@ -0,0 +1,140 @@
+// Package tools_test provides tests for cooperative tool cancellation.
+package tools_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// countdownCtx reports cancellation after Err has been called n times.
+type countdownCtx struct {
+	context.Context
+	n atomic.Int32
+}
+
+func (c *countdownCtx) Err() error {
+	if c.n.Add(-1) < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestRegistry_PartialResult(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{
+		Name: "crawl",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			var pages []string
+			for i := 1; ; i++ {
+				if tools.Canceled(ctx) {
+					tools.PartialResult(ctx, strings.Join(pages, ","))
+					return "", ctx.Err()
+				}
+				pages = append(pages, fmt.Sprintf("page%d", i))
+				if i == 3 {
+					<-ctx.Done()
+				}
+			}
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	output, err := registry.Execute(ctx, "crawl", "{}")
+	var partial *tools.PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Expected PartialError, got %v", err)
+	}
+	if output != "page1,page2,page3" || partial.Output != output || partial.Tool != "crawl" {
+		t.Errorf("Unexpected partial result: %q, %+v", output, partial)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected PartialError to wrap the cancellation, got %v", err)
+	}
+}
+
+func TestRegistry_CancelWithoutPartial(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{
+		Name: "wait",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := registry.Execute(ctx, "wait", "{}")
+	var partial *tools.PartialError
+	if errors.As(err, &partial) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected bare cancellation error, got %v", err)
+	}
+
+	// Outside the registry PartialResult is a no-op.
+	tools.PartialResult(context.Background(), "ignored")
+}
+
+func TestSearchFiles_Cancel(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%02d.txt", i)), []byte("needle"), 0o644)
+	}
+	registry := tools.NewRegistry()
+	tools.FileToolkit(dir).RegisterTo(registry)
+	input := fmt.Sprintf(`{"path": %q, "query": "needle"}`, dir)
+
+	full, err := registry.Execute(context.Background(), "search_files", input)
+	if err != nil || strings.Count(full, `"file"`) != 10 {
+		t.Fatalf("Expected 10 matches, got %q, %v", full, err)
+	}
+
+	// The root directory and three files are visited before cancellation.
+	ctx := &countdownCtx{Context: context.Background()}
+	ctx.n.Store(4)
+	output, err := registry.Execute(ctx, "search_files", input)
+	var partial *tools.PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Expected PartialError, got %v", err)
+	}
+	if n := strings.Count(output, `"file"`); n != 3 {
+		t.Errorf("Expected 3 partial matches, got %d: %s", n, output)
+	}
+}
+
+func TestRunCommand_Cancel(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("requires /bin/sh")
+	}
+	registry := tools.NewRegistry()
+	config := tools.DefaultShellConfig()
+	config.AllowShell = true
+	tools.ShellToolkit(config).RegisterTo(registry)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	output, err := registry.Execute(ctx, "run_command",
+		`{"command": "sh", "args": ["-c", "echo first-line; exec sleep 10"]}`)
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("run_command did not stop on cancellation")
+	}
+	var partial *tools.PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Expected PartialError, got %v", err)
+	}
+	if !strings.Contains(output, "first-line") || !strings.Contains(output, `"exit_code": -1`) {
+		t.Errorf("Expected partial stdout, got %s", output)
+	}
+}
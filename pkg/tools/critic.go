@@ -0,0 +1,112 @@
+// Package tools provides a self-evaluation tool for agents.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nuulab/goflow/pkg/core"
+)
+
+// CriticToolName is the name under which CriticTool is registered.
+const CriticToolName = "evaluate_answer"
+
+// Critique is the structured output of the evaluate_answer tool.
+type Critique struct {
+	// Score rates the answer from 0 (wrong) to 100 (complete and correct).
+	Score       int      `json:"score"`
+	Issues      []string `json:"issues"`
+	Suggestions []string `json:"suggestions"`
+}
+
+const criticPrompt = `You are a strict reviewer. Evaluate the candidate answer against the task.
+
+Task:
+%s
+
+Candidate answer:
+%s
+
+Score the answer from 0 to 100 using this rubric:
+- Correctness: are the facts and reasoning right?
+- Completeness: does it address every part of the task?
+- Clarity: is it direct and unambiguous?
+
+Respond with only a JSON object in this exact format:
+{"score": 0, "issues": ["..."], "suggestions": ["..."]}`
+
+// CriticTool lets an agent score a candidate answer before finalizing it.
+// The critique runs on llm, which may differ from the agent's own model.
+// The tool returns a Critique as JSON.
+func CriticTool(llm core.LLM) *Tool {
+	return &Tool{
+		Name:        CriticToolName,
+		Description: "Evaluate a candidate answer against the task before giving the final answer. Returns a score from 0 to 100, issues and suggested fixes.",
+		Parameters: Schema{
+			Type: "object",
+			Properties: map[string]Property{
+				"task": {
+					Type:        "string",
+					Description: "The task being solved",
+				},
+				"answer": {
+					Type:        "string",
+					Description: "The candidate answer to evaluate",
+				},
+			},
+			Required: []string{"task", "answer"},
+		},
+		Execute: func(ctx context.Context, jsonInput string) (string, error) {
+			var input struct {
+				Task   string `json:"task"`
+				Answer string `json:"answer"`
+			}
+			if err := json.Unmarshal([]byte(jsonInput), &input); err != nil {
+				return "", fmt.Errorf("invalid input: %w", err)
+			}
+			if input.Answer == "" {
+				return "", fmt.Errorf("answer is required")
+			}
+
+			response, err := llm.Generate(ctx, fmt.Sprintf(criticPrompt, input.Task, input.Answer), core.WithModelTier(core.TierSmart))
+			if err != nil {
+				return "", fmt.Errorf("tools: critique failed: %w", err)
+			}
+			critique, err := parseCritique(response)
+			if err != nil {
+				return "", err
+			}
+
+			output, err := json.Marshal(critique)
+			if err != nil {
+				return "", err
+			}
+			return string(output), nil
+		},
+	}
+}
+
+// parseCritique extracts the critique JSON from the critic's response.
+func parseCritique(response string) (Critique, error) {
+	var critique Critique
+
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start == -1 || end < start {
+		return critique, fmt.Errorf("tools: critique is not JSON: %q", response)
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &critique); err != nil {
+		return critique, fmt.Errorf("tools: invalid critique: %w", err)
+	}
+
+	critique.Score = max(0, min(100, critique.Score))
+	if critique.Issues == nil {
+		critique.Issues = []string{}
+	}
+	if critique.Suggestions == nil {
+		critique.Suggestions = []string{}
+	}
+	return critique, nil
+}
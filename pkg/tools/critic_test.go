@@ -0,0 +1,91 @@
+// Package tools_test provides tests for the critic tool.
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// criticLLM answers every Generate call with response and records prompts.
+type criticLLM struct {
+	response string
+	err      error
+	prompts  []string
+}
+
+func (c *criticLLM) Generate(ctx context.Context, prompt string, opts ...core.Option) (string, error) {
+	c.prompts = append(c.prompts, prompt)
+	return c.response, c.err
+}
+
+func (c *criticLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (c *criticLLM) Stream(ctx context.Context, prompt string, opts ...core.Option) (<-chan string, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *criticLLM) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestCriticTool(t *testing.T) {
+	llm := &criticLLM{response: `Here is my review:
+{"score": 35, "issues": ["Misses the second question"], "suggestions": ["Answer both questions"]}`}
+	tool := tools.CriticTool(llm)
+	if tool.Name != tools.CriticToolName {
+		t.Fatalf("Expected name %s, got %s", tools.CriticToolName, tool.Name)
+	}
+
+	out, err := tool.Execute(context.Background(), `{"task": "What and why?", "answer": "Because."}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var critique tools.Critique
+	if err := json.Unmarshal([]byte(out), &critique); err != nil {
+		t.Fatalf("Output is not a critique: %q", out)
+	}
+	if critique.Score != 35 || len(critique.Issues) != 1 || critique.Suggestions[0] != "Answer both questions" {
+		t.Errorf("Unexpected critique: %+v", critique)
+	}
+	if !strings.Contains(llm.prompts[0], "What and why?") || !strings.Contains(llm.prompts[0], "Because.") {
+		t.Errorf("Prompt must include task and answer: %q", llm.prompts[0])
+	}
+}
+
+func TestCriticTool_Normalizes(t *testing.T) {
+	tool := tools.CriticTool(&criticLLM{response: `{"score": 140}`})
+
+	out, err := tool.Execute(context.Background(), `{"task": "t", "answer": "a"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != `{"score":100,"issues":[],"suggestions":[]}` {
+		t.Errorf("Unexpected output: %s", out)
+	}
+}
+
+func TestCriticTool_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		llm   *criticLLM
+		input string
+	}{
+		{"missing answer", &criticLLM{response: `{"score": 50}`}, `{"task": "t"}`},
+		{"not json", &criticLLM{response: "looks fine"}, `{"task": "t", "answer": "a"}`},
+		{"llm error", &criticLLM{err: errors.New("boom")}, `{"task": "t", "answer": "a"}`},
+	}
+	for _, tt := range tests {
+		if _, err := tools.CriticTool(tt.llm).Execute(context.Background(), tt.input); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+// Package tools provides tools for parsing and querying CSV data.
+package tools
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultCSVMaxRows is the row limit of CSVToolkit when none is given.
+const DefaultCSVMaxRows = 10000
+
+// CSVToolkit returns tools for parsing, filtering and aggregating CSV.
+// Input with more than maxRows data rows is rejected; maxRows <= 0 uses
+// DefaultCSVMaxRows. Parsing follows RFC 4180: quoted fields may hold
+// delimiters, newlines and doubled quotes.
+func CSVToolkit(maxRows int) *Toolkit {
+	if maxRows <= 0 {
+		maxRows = DefaultCSVMaxRows
+	}
+	return &Toolkit{
+		Name:        "csv",
+		Description: "Tools for tabular data: parse, filter and aggregate CSV",
+		Tools: []*Tool{
+			csvParseTool(maxRows),
+			csvQueryTool(maxRows),
+			csvStatsTool(maxRows),
+		},
+	}
+}
+
+// csvInput holds the parameters shared by the CSV tools.
+type csvInput struct {
+	CSV       string `json:"csv"`
+	Delimiter string `json:"delimiter"`
+	Header    *bool  `json:"header"`
+}
+
+// csvTable is parsed CSV.
+type csvTable struct {
+	columns []string
+	rows    [][]string
+}
+
+// csvParams adds the parameters shared by the CSV tools to b.
+func csvParams(b *ToolBuilder) *ToolBuilder {
+	return b.
+		Param("csv", "string", "The CSV text").
+		OptionalParam("delimiter", "string", "Field delimiter (default \",\")").
+		OptionalParam("header", "boolean", "Whether the first row names the columns (default true); without it columns are column1, column2, ...")
+}
+
+// read parses the input, with at most maxRows data rows.
+func (in csvInput) read(maxRows int) (*csvTable, error) {
+	r := csv.NewReader(strings.NewReader(in.CSV))
+	if in.Delimiter != "" {
+		d, size := utf8.DecodeRuneInString(in.Delimiter)
+		if size != len(in.Delimiter) || d == '"' || d == '\r' || d == '\n' {
+			return nil, fmt.Errorf("delimiter must be a single character other than a quote or newline, got %q", in.Delimiter)
+		}
+		r.Comma = d
+	}
+
+	table := &csvTable{}
+	header := in.Header == nil || *in.Header
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if header && table.columns == nil {
+			table.columns = columnNames(record)
+			continue
+		}
+		if len(table.rows) == maxRows {
+			return nil, fmt.Errorf("CSV has more than %d rows", maxRows)
+		}
+		table.rows = append(table.rows, record)
+	}
+	if table.columns == nil {
+		if len(table.rows) == 0 {
+			return nil, fmt.Errorf("CSV is empty")
+		}
+		table.columns = make([]string, len(table.rows[0]))
+		for i := range table.columns {
+			table.columns[i] = fmt.Sprintf("column%d", i+1)
+		}
+	}
+	return table, nil
+}
+
+// columnNames names blank header cells and makes duplicates unique.
+func columnNames(record []string) []string {
+	names := make([]string, len(record))
+	seen := make(map[string]int, len(record))
+	for i, name := range record {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = fmt.Sprintf("column%d", i+1)
+		}
+		if seen[name]++; seen[name] > 1 {
+			name = fmt.Sprintf("%s_%d", name, seen[name])
+		}
+		names[i] = name
+	}
+	return names
+}
+
+func (t *csvTable) index(column string) (int, error) {
+	for i, name := range t.columns {
+		if name == column {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown column %q; columns are %s", column, strings.Join(t.columns, ", "))
+}
+
+// csvRows marshals rows as JSON objects with keys in column order.
+type csvRows struct {
+	columns []int // indexes into the table's columns
+	table   *csvTable
+	rows    [][]string
+}
+
+func (r csvRows) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, row := range r.rows {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('{')
+		for j, col := range r.columns {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(r.table.columns[col])
+			value, _ := json.Marshal(cell(row, col))
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// cell returns row[i], or "" for a short row.
+func cell(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+func allColumns(t *csvTable) []int {
+	cols := make([]int, len(t.columns))
+	for i := range cols {
+		cols[i] = i
+	}
+	return cols
+}
+
+func csvParseTool(maxRows int) *Tool {
+	return csvParams(Build("csv_parse").
+		Description("Parse CSV into a JSON array of row objects keyed by column name").
+		Cacheable()).
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params csvInput
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			table, err := params.read(maxRows)
+			if err != nil {
+				return "", err
+			}
+			out, err := json.Marshal(csvRows{columns: allColumns(table), table: table, rows: table.rows})
+			return string(out), err
+		}).
+		Create()
+}
+
+// csvCondition is one filter of csv_query, such as "age>=30".
+type csvCondition struct {
+	column int
+	op     string
+	value  string
+}
+
+// parseCondition parses "column op value" with op one of =, !=, >, >=,
+// <, <= or ~ (contains).
+func (t *csvTable) parseCondition(expr string) (csvCondition, error) {
+	i := strings.IndexAny(expr, "=!<>~")
+	if i <= 0 {
+		return csvCondition{}, fmt.Errorf("invalid condition %q: use column=value, or !=, >, >=, <, <= or ~ (contains)", expr)
+	}
+	op := expr[i : i+1]
+	if i+1 < len(expr) && expr[i+1] == '=' && strings.Contains("!<>", op) {
+		op += "="
+	}
+	if op == "!" {
+		return csvCondition{}, fmt.Errorf("invalid condition %q: use != for not equal", expr)
+	}
+	col, err := t.index(strings.TrimSpace(expr[:i]))
+	if err != nil {
+		return csvCondition{}, err
+	}
+	return csvCondition{column: col, op: op, value: strings.TrimSpace(expr[i+len(op):])}, nil
+}
+
+// match compares the row's cell with the condition's value, as numbers
+// when both parse as numbers and as strings otherwise.
+func (c csvCondition) match(row []string) bool {
+	v := cell(row, c.column)
+	if c.op == "~" {
+		return strings.Contains(strings.ToLower(v), strings.ToLower(c.value))
+	}
+	order := strings.Compare(v, c.value)
+	a, errA := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	b, errB := strconv.ParseFloat(c.value, 64)
+	if errA == nil && errB == nil {
+		order = cmp.Compare(a, b)
+	}
+	switch c.op {
+	case "=":
+		return order == 0
+	case "!=":
+		return order != 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	case "<":
+		return order < 0
+	default: // "<="
+		return order <= 0
+	}
+}
+
+func csvQueryTool(maxRows int) *Tool {
+	return csvParams(Build("csv_query").
+		Description("Filter CSV rows and select columns. Returns a JSON array of row objects").
+		Cacheable()).
+		OptionalParam("where", "array", "Conditions that rows must all meet, e.g. [\"status=active\", \"age>=30\"]; operators are =, !=, >, >=, <, <= and ~ (contains)").
+		OptionalParam("columns", "array", "Columns to return (default all)").
+		OptionalParam("limit", "integer", "Maximum number of rows to return").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				csvInput
+				Where   []string `json:"where"`
+				Columns []string `json:"columns"`
+				Limit   int      `json:"limit"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			table, err := params.read(maxRows)
+			if err != nil {
+				return "", err
+			}
+
+			conditions := make([]csvCondition, len(params.Where))
+			for i, expr := range params.Where {
+				if conditions[i], err = table.parseCondition(expr); err != nil {
+					return "", err
+				}
+			}
+			cols := allColumns(table)
+			if len(params.Columns) > 0 {
+				cols = make([]int, len(params.Columns))
+				for i, name := range params.Columns {
+					if cols[i], err = table.index(name); err != nil {
+						return "", err
+					}
+				}
+			}
+
+			var rows [][]string
+		rows:
+			for _, row := range table.rows {
+				for _, c := range conditions {
+					if !c.match(row) {
+						continue rows
+					}
+				}
+				rows = append(rows, row)
+				if params.Limit > 0 && len(rows) == params.Limit {
+					break
+				}
+			}
+			out, err := json.Marshal(csvRows{columns: cols, table: table, rows: rows})
+			return string(out), err
+		}).
+		Create()
+}
+
+// csvStats aggregates the numbers of one column.
+type csvStats struct {
+	Group string  `json:"group,omitempty"`
+	Count int     `json:"count"`
+	Sum   float64 `json:"sum"`
+	Avg   float64 `json:"avg"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+}
+
+func (s *csvStats) add(v float64) {
+	if s.Count == 0 || v < s.Min {
+		s.Min = v
+	}
+	if s.Count == 0 || v > s.Max {
+		s.Max = v
+	}
+	s.Count++
+	s.Sum += v
+	s.Avg = s.Sum / float64(s.Count)
+}
+
+func csvStatsTool(maxRows int) *Tool {
+	return csvParams(Build("csv_stats").
+		Description("Count rows and compute sum, avg, min and max of a numeric column, optionally per group").
+		Cacheable()).
+		OptionalParam("column", "string", "Numeric column to aggregate; without it only rows are counted").
+		OptionalParam("group_by", "string", "Column to group rows by").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				csvInput
+				Column  string `json:"column"`
+				GroupBy string `json:"group_by"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			table, err := params.read(maxRows)
+			if err != nil {
+				return "", err
+			}
+			col, group := -1, -1
+			if params.Column != "" {
+				if col, err = table.index(params.Column); err != nil {
+					return "", err
+				}
+			}
+			if params.GroupBy != "" {
+				if group, err = table.index(params.GroupBy); err != nil {
+					return "", err
+				}
+			}
+
+			groups := map[string]*csvStats{}
+			for i, row := range table.rows {
+				key := ""
+				if group >= 0 {
+					key = cell(row, group)
+				}
+				s, ok := groups[key]
+				if !ok {
+					s = &csvStats{Group: key}
+					groups[key] = s
+				}
+				if col < 0 {
+					s.Count++
+					continue
+				}
+				raw := strings.TrimSpace(cell(row, col))
+				if raw == "" {
+					continue // missing values are not counted
+				}
+				v, err := strconv.ParseFloat(raw, 64)
+				if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+					return "", fmt.Errorf("row %d: %s value %q is not a number", i+1, params.Column, raw)
+				}
+				s.add(v)
+			}
+
+			var result any
+			if group < 0 {
+				total := groups[""]
+				if total == nil {
+					total = &csvStats{}
+				}
+				result = total
+			} else {
+				list := make([]*csvStats, 0, len(groups))
+				for _, s := range groups {
+					list = append(list, s)
+				}
+				sort.Slice(list, func(i, j int) bool { return list[i].Group < list[j].Group })
+				result = map[string]any{"group_by": params.GroupBy, "groups": list}
+			}
+			out, err := json.Marshal(result)
+			return string(out), err
+		}).
+		Create()
+}
@@ -0,0 +1,62 @@
+package tools_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestCSVToolkit(t *testing.T) {
+	registry := tools.NewRegistry()
+	if err := tools.CSVToolkit(5).RegisterTo(registry); err != nil {
+		t.Fatal(err)
+	}
+	const orders = `"region,order,amount\neast,1,10\nwest,2,5.5\neast,3,20\nnorth,4,\n"`
+	tests := []struct {
+		tool, input string
+		want        string
+		wantErr     string
+	}{
+		{tool: "csv_parse", input: `{"csv": "name,note\nAda,\"likes \"\"math\"\", logic\"\n"}`,
+			want: `[{"name":"Ada","note":"likes \"math\", logic"}]`},
+		{tool: "csv_parse", input: `{"csv": "b,a\n1,\"multi\nline\"\n"}`, want: `[{"b":"1","a":"multi\nline"}]`},
+		{tool: "csv_parse", input: `{"csv": "x;y\n1;2", "delimiter": ";", "header": false}`,
+			want: `[{"column1":"x","column2":"y"},{"column1":"1","column2":"2"}]`},
+		{tool: "csv_parse", input: `{"csv": "id,id,\n1,2,3"}`, want: `[{"id":"1","id_2":"2","column3":"3"}]`},
+		{tool: "csv_parse", input: `{"csv": "a,b\n1,2,3"}`, wantErr: "wrong number of fields"},
+		{tool: "csv_parse", input: `{"csv": "a\n\"open"}`, wantErr: "invalid CSV"},
+		{tool: "csv_parse", input: `{"csv": "a\n1\n2\n3\n4\n5\n6"}`, wantErr: "more than 5 rows"},
+		{tool: "csv_parse", input: `{"csv": "a", "delimiter": "||"}`, wantErr: "single character"},
+		{tool: "csv_query", input: `{"csv": ` + orders + `, "where": ["region=east", "amount>15"]}`,
+			want: `[{"region":"east","order":"3","amount":"20"}]`},
+		{tool: "csv_query", input: `{"csv": ` + orders + `, "where": ["amount >= 5.5"], "columns": ["order"]}`,
+			want: `[{"order":"1"},{"order":"2"},{"order":"3"}]`},
+		{tool: "csv_query", input: `{"csv": ` + orders + `, "where": ["region~ST"], "limit": 1}`,
+			want: `[{"region":"east","order":"1","amount":"10"}]`},
+		{tool: "csv_query", input: `{"csv": ` + orders + `, "where": ["region=south"]}`, want: `[]`},
+		{tool: "csv_query", input: `{"csv": ` + orders + `, "where": ["city=Paris"]}`, wantErr: `unknown column "city"; columns are region, order, amount`},
+		{tool: "csv_query", input: `{"csv": ` + orders + `, "where": ["region"]}`, wantErr: "invalid condition"},
+		{tool: "csv_stats", input: `{"csv": ` + orders + `, "column": "amount"}`,
+			want: `{"count":3,"sum":35.5,"avg":11.833333333333334,"min":5.5,"max":20}`},
+		{tool: "csv_stats", input: `{"csv": ` + orders + `, "group_by": "region"}`,
+			want: `{"group_by":"region","groups":[{"group":"east","count":2,"sum":0,"avg":0,"min":0,"max":0},{"group":"north","count":1,"sum":0,"avg":0,"min":0,"max":0},{"group":"west","count":1,"sum":0,"avg":0,"min":0,"max":0}]}`},
+		{tool: "csv_stats", input: `{"csv": ` + orders + `, "column": "amount", "group_by": "region"}`,
+			want: `{"group":"east","count":2,"sum":30,"avg":15,"min":10,"max":20}`},
+		{tool: "csv_stats", input: `{"csv": ` + orders + `, "column": "region"}`, wantErr: `row 1: region value "east" is not a number`},
+	}
+	for _, tt := range tests {
+		out, err := registry.Execute(context.Background(), tt.tool, tt.input)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s %s: expected error containing %q, got %v (%s)", tt.tool, tt.input, tt.wantErr, err, out)
+			}
+		case err != nil:
+			t.Errorf("%s %s: %v", tt.tool, tt.input, err)
+		case !strings.Contains(out, tt.want):
+			t.Errorf("%s %s: expected %s in %s", tt.tool, tt.input, tt.want, out)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+// Package tools provides date and time tools for agents.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeToolkit returns tools for the current time, date parsing, date
+// arithmetic and timezone conversion. Timezones are IANA names such as
+// "Europe/Paris"; an empty timezone means UTC.
+func TimeToolkit() *Toolkit {
+	return &Toolkit{
+		Name:        "time",
+		Description: "Tools for dates and times: current time, parsing, arithmetic and timezones",
+		Tools: []*Tool{
+			nowTool(),
+			parseDateTool(),
+			dateAddTool(),
+			dateDiffTool(),
+			convertTimezoneTool(),
+		},
+	}
+}
+
+// dateLayouts are tried in order when parse_date is given no layout.
+// Day/month orders that differ by locale, like 01/02/2006, are left out.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.ANSIC,
+	"January 2, 2006 15:04",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+}
+
+// timeOutput is the JSON result of the time tools.
+type timeOutput struct {
+	Time     string `json:"time"`
+	Unix     int64  `json:"unix"`
+	Timezone string `json:"timezone"`
+	Weekday  string `json:"weekday"`
+}
+
+func formatTime(t time.Time) (string, error) {
+	zone := t.Location().String()
+	if zone == "" {
+		zone = t.Format("-07:00")
+	}
+	out, err := json.Marshal(timeOutput{
+		Time:     t.Format(time.RFC3339),
+		Unix:     t.Unix(),
+		Timezone: zone,
+		Weekday:  t.Weekday().String(),
+	})
+	return string(out), err
+}
+
+// loadLocation resolves an IANA timezone name. "" means UTC.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q: use an IANA name such as \"America/New_York\"", name)
+	}
+	return loc, nil
+}
+
+// parseTime parses value with layout, or with dateLayouts when layout is
+// empty. Unix seconds are accepted too. Values without an offset are read
+// as wall-clock time in loc.
+func parseTime(value, layout string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if layout != "" {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse %q with layout %q: %w", value, layout, err)
+		}
+		return inZone(t, layout, loc)
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0).In(loc), nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return inZone(t, layout, loc)
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q: use RFC3339 (2006-01-02T15:04:05Z07:00) or pass a Go layout", value)
+}
+
+// inZone places t, parsed with layout, in loc if the layout carries no
+// offset of its own.
+func inZone(t time.Time, layout string, loc *time.Location) (time.Time, error) {
+	if strings.Contains(layout, "Z07") || strings.Contains(layout, "-07") || strings.Contains(layout, "MST") {
+		return t, nil
+	}
+	return wallClock(t, loc)
+}
+
+// wallClock returns the instant at which clocks in loc show the date and
+// time of naive (read in UTC). It fails for times skipped or repeated by a
+// daylight saving change, rather than silently picking one.
+func wallClock(naive time.Time, loc *time.Location) (time.Time, error) {
+	guess := time.Date(naive.Year(), naive.Month(), naive.Day(), naive.Hour(), naive.Minute(), naive.Second(), naive.Nanosecond(), loc)
+	var matches []time.Time
+	seen := map[int]bool{}
+	for _, probe := range []time.Time{guess.Add(-24 * time.Hour), guess, guess.Add(24 * time.Hour)} {
+		_, offset := probe.Zone()
+		if seen[offset] {
+			continue
+		}
+		seen[offset] = true
+		t := naive.Add(-time.Duration(offset) * time.Second).In(loc)
+		if _, o := t.Zone(); o == offset {
+			matches = append(matches, t)
+		}
+	}
+
+	local := naive.Format("2006-01-02T15:04:05")
+	switch len(matches) {
+	case 0:
+		return time.Time{}, fmt.Errorf("%s does not exist in %s: clocks skip it for daylight saving time", local, loc)
+	case 1:
+		return matches[0], nil
+	default:
+		return time.Time{}, fmt.Errorf("%s is ambiguous in %s: it occurs at both %s and %s because of a daylight saving change; include an offset",
+			local, loc, matches[0].Format("-07:00"), matches[1].Format("-07:00"))
+	}
+}
+
+func nowTool() *Tool {
+	return Build("now").
+		Description("Get the current date and time").
+		OptionalParam("timezone", "string", "IANA timezone, e.g. \"Europe/Paris\" (default UTC)").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			// With a single parameter the builder passes the timezone
+			// itself, or the raw JSON when it is missing.
+			timezone := strings.TrimSpace(input)
+			var params struct {
+				Timezone string `json:"timezone"`
+			}
+			if json.Unmarshal([]byte(input), &params) == nil {
+				timezone = params.Timezone
+			}
+			loc, err := loadLocation(timezone)
+			if err != nil {
+				return "", err
+			}
+			return formatTime(time.Now().In(loc))
+		}).
+		Create()
+}
+
+func parseDateTool() *Tool {
+	return Build("parse_date").
+		Description("Parse a date or time. Formats like RFC3339, 2006-01-02 and \"Jan 2, 2006\" are detected; pass a Go layout for others").
+		Cacheable().
+		Param("date", "string", "The date to parse").
+		OptionalParam("layout", "string", "Go time layout, e.g. \"02/01/2006 15:04\"").
+		OptionalParam("timezone", "string", "IANA timezone for dates without an offset (default UTC)").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Date     string `json:"date"`
+				Layout   string `json:"layout"`
+				Timezone string `json:"timezone"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			loc, err := loadLocation(params.Timezone)
+			if err != nil {
+				return "", err
+			}
+			t, err := parseTime(params.Date, params.Layout, loc)
+			if err != nil {
+				return "", err
+			}
+			return formatTime(t)
+		}).
+		Create()
+}
+
+func dateAddTool() *Tool {
+	return Build("date_add").
+		Description("Add calendar units and a duration to a date. Use negative values to subtract").
+		Cacheable().
+		Param("date", "string", "The starting date").
+		OptionalParam("years", "integer", "Years to add").
+		OptionalParam("months", "integer", "Months to add").
+		OptionalParam("days", "integer", "Days to add").
+		OptionalParam("duration", "string", "Duration to add, e.g. \"2h30m\" or \"-45m\"").
+		OptionalParam("timezone", "string", "IANA timezone the calendar units are counted in (default UTC)").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Date     string `json:"date"`
+				Years    int    `json:"years"`
+				Months   int    `json:"months"`
+				Days     int    `json:"days"`
+				Duration string `json:"duration"`
+				Timezone string `json:"timezone"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			loc, err := loadLocation(params.Timezone)
+			if err != nil {
+				return "", err
+			}
+			t, err := parseTime(params.Date, "", loc)
+			if err != nil {
+				return "", err
+			}
+			var d time.Duration
+			if params.Duration != "" {
+				if d, err = time.ParseDuration(params.Duration); err != nil {
+					return "", fmt.Errorf("invalid duration %q: use a form like \"1h30m\"", params.Duration)
+				}
+			}
+			t = addMonths(t.In(loc), params.Years*12+params.Months)
+			return formatTime(t.AddDate(0, 0, params.Days).Add(d))
+		}).
+		Create()
+}
+
+func dateDiffTool() *Tool {
+	return Build("date_diff").
+		Description("Get the time between two dates, as a duration and in calendar years, months and days").
+		Cacheable().
+		Param("start", "string", "The start date").
+		Param("end", "string", "The end date").
+		OptionalParam("timezone", "string", "IANA timezone for dates without an offset and for calendar units (default UTC)").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Start    string `json:"start"`
+				End      string `json:"end"`
+				Timezone string `json:"timezone"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			loc, err := loadLocation(params.Timezone)
+			if err != nil {
+				return "", err
+			}
+			start, err := parseTime(params.Start, "", loc)
+			if err != nil {
+				return "", err
+			}
+			end, err := parseTime(params.End, "", loc)
+			if err != nil {
+				return "", err
+			}
+
+			d := end.Sub(start)
+			years, months, days := calendarDiff(start.In(loc), end.In(loc))
+			out, err := json.Marshal(map[string]any{
+				"seconds":    d.Seconds(),
+				"duration":   d.String(),
+				"total_days": d.Hours() / 24,
+				"calendar":   map[string]int{"years": years, "months": months, "days": days},
+			})
+			return string(out), err
+		}).
+		Create()
+}
+
+// calendarDiff counts whole years, months and days from a to b, negative
+// when b is before a.
+func calendarDiff(a, b time.Time) (years, months, days int) {
+	sign := 1
+	if b.Before(a) {
+		a, b, sign = b, a, -1
+	}
+	total := (b.Year()-a.Year())*12 + int(b.Month()-a.Month())
+	if addMonths(a, total).After(b) {
+		total--
+	}
+	mid := addMonths(a, total)
+	days = int(b.Sub(mid).Hours() / 24)
+	for !mid.AddDate(0, 0, days+1).After(b) {
+		days++
+	}
+	for days > 0 && mid.AddDate(0, 0, days).After(b) {
+		days--
+	}
+	return sign * (total / 12), sign * (total % 12), sign * days
+}
+
+// addMonths adds n months to t, clamping the day to the end of a shorter
+// month: Jan 31 plus one month is Feb 28, not Mar 3 as with AddDate.
+func addMonths(t time.Time, n int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, last)-1)
+}
+
+func convertTimezoneTool() *Tool {
+	return Build("convert_timezone").
+		Description("Convert a date and time to another timezone").
+		Cacheable().
+		Param("date", "string", "The date and time to convert").
+		Param("to", "string", "Target IANA timezone, e.g. \"Asia/Tokyo\"").
+		OptionalParam("from", "string", "IANA timezone of a date without an offset (default UTC)").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Date string `json:"date"`
+				To   string `json:"to"`
+				From string `json:"from"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			from, err := loadLocation(params.From)
+			if err != nil {
+				return "", err
+			}
+			to, err := loadLocation(params.To)
+			if err != nil {
+				return "", err
+			}
+			t, err := parseTime(params.Date, "", from)
+			if err != nil {
+				return "", err
+			}
+			return formatTime(t.In(to))
+		}).
+		Create()
+}
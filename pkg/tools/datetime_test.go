@@ -0,0 +1,80 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func timeRegistry(t *testing.T) *tools.Registry {
+	t.Helper()
+	registry := tools.NewRegistry()
+	if err := tools.TimeToolkit().RegisterTo(registry); err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+func TestTimeToolkit_Now(t *testing.T) {
+	registry := timeRegistry(t)
+	before := time.Now().Unix()
+	out, err := registry.Execute(context.Background(), "now", `{"timezone": "Asia/Tokyo"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Time     string `json:"time"`
+		Unix     int64  `json:"unix"`
+		Timezone string `json:"timezone"`
+	}
+	json.Unmarshal([]byte(out), &got)
+	if got.Unix < before || got.Unix > time.Now().Unix() || got.Timezone != "Asia/Tokyo" || !strings.HasSuffix(got.Time, "+09:00") {
+		t.Errorf("Unexpected now output: %s", out)
+	}
+
+	if _, err := registry.Execute(context.Background(), "now", `{"timezone": "Mars/Olympus"}`); err == nil || !strings.Contains(err.Error(), "unknown timezone") {
+		t.Errorf("Expected an unknown timezone error, got %v", err)
+	}
+}
+
+func TestTimeToolkit(t *testing.T) {
+	registry := timeRegistry(t)
+	tests := []struct {
+		tool, input string
+		want        string // substring of the output
+		wantErr     string // substring of the error
+	}{
+		{tool: "parse_date", input: `{"date": "2026-10-15T09:30:00+02:00"}`, want: `"unix":1792049400`},
+		{tool: "parse_date", input: `{"date": "Oct 15, 2026", "timezone": "America/New_York"}`, want: `"time":"2026-10-15T00:00:00-04:00"`},
+		{tool: "parse_date", input: `{"date": "15/10/2026 09:30", "layout": "02/01/2006 15:04"}`, want: `"weekday":"Thursday"`},
+		{tool: "parse_date", input: `{"date": "next tuesday"}`, wantErr: "cannot parse"},
+		{tool: "parse_date", input: `{"date": "2026-03-29 02:30", "timezone": "Europe/Paris"}`, wantErr: "does not exist in Europe/Paris"},
+		{tool: "parse_date", input: `{"date": "2026-10-25 02:30", "timezone": "Europe/Paris"}`, wantErr: "ambiguous in Europe/Paris: it occurs at both +02:00 and +01:00"},
+		{tool: "date_add", input: `{"date": "2024-02-29", "years": 1, "days": 1}`, want: `"time":"2025-03-01T00:00:00Z"`},
+		{tool: "date_add", input: `{"date": "2026-03-28T12:00:00", "days": 1, "timezone": "Europe/Paris"}`, want: `"time":"2026-03-29T12:00:00+02:00"`},
+		{tool: "date_add", input: `{"date": "2026-10-15T10:00:00Z", "duration": "-90m"}`, want: `"time":"2026-10-15T08:30:00Z"`},
+		{tool: "date_add", input: `{"date": "2026-10-15", "duration": "soon"}`, wantErr: "invalid duration"},
+		{tool: "date_diff", input: `{"start": "2024-01-31", "end": "2026-03-01"}`, want: `"calendar":{"days":1,"months":1,"years":2}`},
+		{tool: "date_diff", input: `{"start": "2026-10-16", "end": "2026-10-15T12:00:00Z"}`, want: `"duration":"-12h0m0s"`},
+		{tool: "convert_timezone", input: `{"date": "2026-10-15 18:00", "from": "America/Los_Angeles", "to": "Asia/Tokyo"}`, want: `"time":"2026-10-16T10:00:00+09:00"`},
+		{tool: "convert_timezone", input: `{"date": "2026-10-15T18:00:00Z", "to": "Nowhere/City"}`, wantErr: "unknown timezone"},
+	}
+	for _, tt := range tests {
+		out, err := registry.Execute(context.Background(), tt.tool, tt.input)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s %s: expected error containing %q, got %v (%s)", tt.tool, tt.input, tt.wantErr, err, out)
+			}
+		case err != nil:
+			t.Errorf("%s %s: %v", tt.tool, tt.input, err)
+		case !strings.Contains(out, tt.want):
+			t.Errorf("%s %s: expected %s in %s", tt.tool, tt.input, tt.want, out)
+		}
+	}
+}
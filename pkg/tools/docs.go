@@ -0,0 +1,188 @@
+// Package tools provides meta-tools that let agents look up tool docs.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Names under which the documentation meta-tools are registered.
+const (
+	DescribeToolName = "describe_tool"
+	ListToolsName    = "list_tools"
+)
+
+// ToolDoc is the full documentation of a tool, as returned by
+// describe_tool.
+type ToolDoc struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Parameters  Schema `json:"parameters"`
+	// Example is a sample input synthesized from the schema.
+	Example map[string]any `json:"example"`
+}
+
+// Describe documents tool with an example input.
+func Describe(tool *Tool) ToolDoc {
+	return ToolDoc{
+		Name:        tool.Name,
+		Description: tool.Description,
+		Parameters:  tool.Parameters,
+		Example:     ExampleInput(tool.Parameters),
+	}
+}
+
+// ExampleInput builds a plausible input for schema: the first enum value
+// where there is one, otherwise a value of the property's type chosen from
+// its name.
+func ExampleInput(schema Schema) map[string]any {
+	example := make(map[string]any, len(schema.Properties))
+	for name, prop := range schema.Properties {
+		example[name] = exampleValue(name, prop)
+	}
+	return example
+}
+
+func exampleValue(name string, prop Property) any {
+	if len(prop.Enum) > 0 {
+		return prop.Enum[0]
+	}
+	lower := strings.ToLower(name)
+	switch prop.Type {
+	case "integer":
+		return 1
+	case "number":
+		return 1.5
+	case "boolean":
+		return true
+	case "array":
+		if prop.Items != nil {
+			return []any{exampleValue(strings.TrimSuffix(name, "s"), *prop.Items)}
+		}
+		return []any{}
+	case "object":
+		example := make(map[string]any, len(prop.Properties))
+		for field, fieldProp := range prop.Properties {
+			example[field] = exampleValue(field, fieldProp)
+		}
+		return example
+	}
+
+	switch {
+	case strings.Contains(lower, "url"):
+		return "https://example.com"
+	case strings.Contains(lower, "email"):
+		return "user@example.com"
+	case strings.Contains(lower, "path"), strings.Contains(lower, "file"):
+		return "/tmp/example.txt"
+	case strings.Contains(lower, "date"):
+		return "2024-01-31"
+	case strings.HasSuffix(lower, "id"):
+		return "abc123"
+	case strings.Contains(lower, "query"), strings.Contains(lower, "search"):
+		return "example search"
+	}
+	return "example " + name
+}
+
+// DescribeTool returns the describe_tool meta-tool, which documents any
+// tool in r that visible accepts. A nil visible accepts every tool. Hidden
+// tools are reported as unknown.
+func DescribeTool(r *Registry, visible func(name string) bool) *Tool {
+	return &Tool{
+		Name:        DescribeToolName,
+		Description: "Get the full parameter schema and an example input for a tool before calling it.",
+		Parameters: Schema{
+			Type: "object",
+			Properties: map[string]Property{
+				"name": {Type: "string", Description: "The tool name"},
+			},
+			Required: []string{"name"},
+		},
+		Execute: func(ctx context.Context, jsonInput string) (string, error) {
+			var input struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal([]byte(jsonInput), &input); err != nil {
+				return "", fmt.Errorf("invalid input: %w", err)
+			}
+			tool, ok := r.Get(input.Name)
+			if !ok || (visible != nil && !visible(input.Name)) {
+				return "", fmt.Errorf("tools: unknown tool %q", input.Name)
+			}
+			output, err := json.Marshal(Describe(tool))
+			if err != nil {
+				return "", err
+			}
+			return string(output), nil
+		},
+	}
+}
+
+// ToolSummary is a tool's entry in the list_tools output.
+type ToolSummary struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ListTools returns the list_tools meta-tool, which lists the tools in r
+// that visible accepts, optionally filtered by keywords. A nil visible
+// accepts every tool.
+func ListTools(r *Registry, visible func(name string) bool) *Tool {
+	return &Tool{
+		Name:        ListToolsName,
+		Description: "List the available tools, optionally only those matching keywords.",
+		Parameters: Schema{
+			Type: "object",
+			Properties: map[string]Property{
+				"query": {Type: "string", Description: "Keywords to match against tool names and descriptions"},
+			},
+		},
+		Execute: func(ctx context.Context, jsonInput string) (string, error) {
+			var input struct {
+				Query string `json:"query"`
+			}
+			if jsonInput != "" {
+				if err := json.Unmarshal([]byte(jsonInput), &input); err != nil {
+					return "", fmt.Errorf("invalid input: %w", err)
+				}
+			}
+			keywords := strings.Fields(strings.ToLower(input.Query))
+
+			summaries := []ToolSummary{}
+			for _, tool := range r.List() {
+				if visible != nil && !visible(tool.Name) {
+					continue
+				}
+				if !matchesAny(strings.ToLower(tool.Name+" "+tool.Description), keywords) {
+					continue
+				}
+				summaries = append(summaries, ToolSummary{Name: tool.Name, Description: tool.Description})
+			}
+			sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+
+			output, err := json.Marshal(summaries)
+			if err != nil {
+				return "", err
+			}
+			return string(output), nil
+		},
+	}
+}
+
+// matchesAny reports whether text contains any keyword. No keywords match
+// everything.
+func matchesAny(text string, keywords []string) bool {
+	if len(keywords) == 0 {
+		return true
+	}
+	for _, kw := range keywords {
+		if strings.Contains(text, kw) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func docsRegistry() *tools.Registry {
+	r := tools.NewRegistry()
+	r.Register(&tools.Tool{
+		Name:        "create_invoice",
+		Description: "Create an invoice for a customer",
+		Parameters: tools.Schema{
+			Type: "object",
+			Properties: map[string]tools.Property{
+				"customer_id": {Type: "string", Description: "Billing customer"},
+				"amount":      {Type: "number", Description: "Total in major units"},
+				"currency":    {Type: "string", Enum: []string{"EUR", "USD"}},
+				"lines":       {Type: "integer"},
+				"draft":       {Type: "boolean"},
+			},
+			Required: []string{"customer_id", "amount"},
+		},
+	})
+	r.Register(&tools.Tool{Name: "send_email", Description: "Send an email"})
+	r.Register(&tools.Tool{Name: "delete_account", Description: "Delete a customer account"})
+	return r
+}
+
+func TestDescribeTool(t *testing.T) {
+	r := docsRegistry()
+	describe := tools.DescribeTool(r, nil)
+
+	out, err := describe.Execute(context.Background(), `{"name": "create_invoice"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc tools.ToolDoc
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tool, _ := r.Get("create_invoice")
+	if doc.Description != tool.Description || !reflect.DeepEqual(doc.Parameters, tool.Parameters) {
+		t.Errorf("Description does not match the registered schema:\n%s", out)
+	}
+	if doc.Example["currency"] != "EUR" || doc.Example["customer_id"] != "abc123" || doc.Example["draft"] != true {
+		t.Errorf("Unexpected example: %v", doc.Example)
+	}
+
+	// The example must satisfy the schema it was generated from.
+	var example map[string]any
+	raw, _ := json.Marshal(doc.Example)
+	json.Unmarshal(raw, &example)
+	if err := tool.Parameters.Validate(example); err != nil {
+		t.Errorf("Example fails its own schema: %v", err)
+	}
+}
+
+func TestDescribeTool_Hidden(t *testing.T) {
+	describe := tools.DescribeTool(docsRegistry(), func(name string) bool { return name != "delete_account" })
+
+	_, hiddenErr := describe.Execute(context.Background(), `{"name": "delete_account"}`)
+	_, missingErr := describe.Execute(context.Background(), `{"name": "nope"}`)
+	if hiddenErr == nil || missingErr == nil {
+		t.Fatal("Expected hidden and missing tools to fail")
+	}
+	if strings.Replace(hiddenErr.Error(), "delete_account", "nope", 1) != missingErr.Error() {
+		t.Errorf("Hidden tool error reveals it exists: %v vs %v", hiddenErr, missingErr)
+	}
+}
+
+func TestListTools(t *testing.T) {
+	list := tools.ListTools(docsRegistry(), func(name string) bool { return name != "delete_account" })
+
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{`{}`, []string{"create_invoice", "send_email"}},
+		{`{"query": "EMAIL"}`, []string{"send_email"}},
+		{`{"query": "customer"}`, []string{"create_invoice"}},
+		{`{"query": "account"}`, nil},
+	}
+	for _, tt := range tests {
+		out, err := list.Execute(context.Background(), tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var summaries []tools.ToolSummary
+		json.Unmarshal([]byte(out), &summaries)
+		var names []string
+		for _, s := range summaries {
+			names = append(names, s.Name)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.input, names, tt.want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package tools provides hashing, encoding and random value tools.
+package tools
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxRandomLength bounds the length of a random_string.
+const maxRandomLength = 4096
+
+// EncodingToolkit returns tools for hashing, base64, UUIDs, HMAC signatures
+// and random strings.
+func EncodingToolkit() *Toolkit {
+	return &Toolkit{
+		Name:        "encoding",
+		Description: "Tools for hashing, encoding, signing and random values",
+		Tools: []*Tool{
+			hashTool(),
+			base64Tool(),
+			uuidTool(),
+			hmacSignTool(),
+			randomStringTool(),
+		},
+	}
+}
+
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+var charsets = map[string]string{
+	"alphanumeric": "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+	"letters":      "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+	"digits":       "0123456789",
+	"hex":          "0123456789abcdef",
+	"url_safe":     "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_",
+	"printable":    "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!#$%&()*+,-./:;<=>?@[]^_{|}~",
+}
+
+func hashTool() *Tool {
+	return Build("hash").
+		Description("Compute the hex digest of a string").
+		Cacheable().
+		EnumParam("algorithm", "Hash algorithm", "md5", "sha1", "sha256", "sha512").
+		Param("input", "string", "The data to hash").
+		OptionalEnumParam("input_encoding", "How input is encoded (default text)", "text", "hex").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Algorithm     string `json:"algorithm"`
+				Input         string `json:"input"`
+				InputEncoding string `json:"input_encoding"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			newHash, ok := hashes[params.Algorithm]
+			if !ok {
+				return "", fmt.Errorf("unsupported algorithm: %s", params.Algorithm)
+			}
+			data := []byte(params.Input)
+			if params.InputEncoding == "hex" {
+				var err error
+				if data, err = hex.DecodeString(params.Input); err != nil {
+					return "", fmt.Errorf("invalid hex input: %w", err)
+				}
+			}
+			h := newHash()
+			h.Write(data)
+			return hex.EncodeToString(h.Sum(nil)), nil
+		}).
+		Create()
+}
+
+func base64Tool() *Tool {
+	return Build("base64").
+		Description("Encode text as base64 or decode base64 to text").
+		Cacheable().
+		EnumParam("operation", "Whether to encode or decode", "encode", "decode").
+		Param("input", "string", "The text to encode or the base64 to decode").
+		OptionalEnumParam("variant", "Alphabet: std uses + and /, url uses - and _ (default std)", "std", "url").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Operation string `json:"operation"`
+				Input     string `json:"input"`
+				Variant   string `json:"variant"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			enc := base64.StdEncoding
+			if params.Variant == "url" {
+				enc = base64.URLEncoding
+			}
+
+			switch params.Operation {
+			case "encode":
+				return enc.EncodeToString([]byte(params.Input)), nil
+			case "decode":
+				// Padding is often stripped, so accept input without it.
+				data, err := enc.WithPadding(base64.NoPadding).DecodeString(strings.TrimRight(strings.TrimSpace(params.Input), "="))
+				if err != nil {
+					return "", fmt.Errorf("invalid base64: %w", err)
+				}
+				if !utf8.Valid(data) {
+					return "", fmt.Errorf("decoded data is binary, not text; its hex is %s", hex.EncodeToString(data))
+				}
+				return string(data), nil
+			}
+			return "", fmt.Errorf("unsupported operation: %s", params.Operation)
+		}).
+		Create()
+}
+
+func uuidTool() *Tool {
+	return Build("uuid").
+		Description("Generate a random UUID. Version 7 UUIDs sort by creation time").
+		OptionalEnumParam("version", "UUID version (default 4)", "4", "7").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			// With a single parameter the builder passes the version
+			// itself, or the raw JSON when it is missing.
+			version := strings.TrimSpace(input)
+			var params struct {
+				Version string `json:"version"`
+			}
+			if json.Unmarshal([]byte(input), &params) == nil {
+				version = params.Version
+			}
+
+			var u [16]byte
+			if _, err := rand.Read(u[:]); err != nil {
+				return "", err
+			}
+			switch version {
+			case "", "4":
+				u[6] = u[6]&0x0f | 0x40
+			case "7":
+				var ms [8]byte
+				binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixMilli()))
+				copy(u[:6], ms[2:])
+				u[6] = u[6]&0x0f | 0x70
+			default:
+				return "", fmt.Errorf("unsupported UUID version: %s", version)
+			}
+			u[8] = u[8]&0x3f | 0x80 // RFC 9562 variant
+			return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+		}).
+		Create()
+}
+
+func hmacSignTool() *Tool {
+	return Build("hmac_sign").
+		Description("Sign a message with HMAC, e.g. to sign or verify a webhook payload").
+		EnumParam("algorithm", "Hash algorithm", "sha1", "sha256", "sha512").
+		Param("secret", "string", "The shared secret key").
+		Param("message", "string", "The message to sign").
+		OptionalEnumParam("output", "Signature encoding (default hex)", "hex", "base64").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Algorithm string `json:"algorithm"`
+				Secret    string `json:"secret"`
+				Message   string `json:"message"`
+				Output    string `json:"output"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			newHash, ok := hashes[params.Algorithm]
+			if !ok || params.Algorithm == "md5" {
+				return "", fmt.Errorf("unsupported algorithm: %s", params.Algorithm)
+			}
+			mac := hmac.New(newHash, []byte(params.Secret))
+			mac.Write([]byte(params.Message))
+			if params.Output == "base64" {
+				return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+			}
+			return hex.EncodeToString(mac.Sum(nil)), nil
+		}).
+		Create()
+}
+
+func randomStringTool() *Tool {
+	return Build("random_string").
+		Description("Generate a cryptographically random string, e.g. for passwords, tokens or nonces").
+		Param("length", "integer", fmt.Sprintf("Number of characters (1-%d)", maxRandomLength)).
+		OptionalEnumParam("charset", "Characters to draw from (default alphanumeric)", "alphanumeric", "letters", "digits", "hex", "url_safe", "printable").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Length  int    `json:"length"`
+				Charset string `json:"charset"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			if params.Length < 1 || params.Length > maxRandomLength {
+				return "", fmt.Errorf("length must be between 1 and %d", maxRandomLength)
+			}
+			if params.Charset == "" {
+				params.Charset = "alphanumeric"
+			}
+			chars, ok := charsets[params.Charset]
+			if !ok {
+				return "", fmt.Errorf("unsupported charset: %s", params.Charset)
+			}
+
+			out := make([]byte, params.Length)
+			n := big.NewInt(int64(len(chars)))
+			for i := range out {
+				j, err := rand.Int(rand.Reader, n)
+				if err != nil {
+					return "", err
+				}
+				out[i] = chars[j.Int64()]
+			}
+			return string(out), nil
+		}).
+		Create()
+}
@@ -0,0 +1,94 @@
+package tools_test
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func encodingRegistry(t *testing.T) *tools.Registry {
+	t.Helper()
+	registry := tools.NewRegistry()
+	if err := tools.EncodingToolkit().RegisterTo(registry); err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+func TestEncodingToolkit(t *testing.T) {
+	registry := encodingRegistry(t)
+	const jefe = `"secret": "Jefe", "message": "what do ya want for nothing?"` // RFC 4231 test case 2
+	tests := []struct {
+		tool, input string
+		want        string
+		wantErr     string
+	}{
+		{tool: "hash", input: `{"algorithm": "md5", "input": "abc"}`, want: "900150983cd24fb0d6963f7d28e17f72"},
+		{tool: "hash", input: `{"algorithm": "sha1", "input": "abc"}`, want: "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{tool: "hash", input: `{"algorithm": "sha256", "input": "abc"}`, want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{tool: "hash", input: `{"algorithm": "sha512", "input": "abc"}`, want: "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"},
+		{tool: "hash", input: `{"algorithm": "sha256", "input": "616263", "input_encoding": "hex"}`, want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{tool: "hash", input: `{"algorithm": "sha256", "input": "xyz", "input_encoding": "hex"}`, wantErr: "invalid hex input"},
+		{tool: "hash", input: `{"algorithm": "crc32", "input": "abc"}`, wantErr: "must be one of"},
+		{tool: "base64", input: `{"operation": "encode", "input": "hello?>"}`, want: "aGVsbG8/Pg=="},
+		{tool: "base64", input: `{"operation": "encode", "input": "hello?>", "variant": "url"}`, want: "aGVsbG8_Pg=="},
+		{tool: "base64", input: `{"operation": "decode", "input": "aGVsbG8/Pg=="}`, want: "hello?>"},
+		{tool: "base64", input: `{"operation": "decode", "input": "aGVsbG8_Pg", "variant": "url"}`, want: "hello?>"},
+		{tool: "base64", input: `{"operation": "decode", "input": "aGVsbG8_Pg"}`, wantErr: "invalid base64"},
+		{tool: "base64", input: `{"operation": "decode", "input": "/w=="}`, wantErr: "its hex is ff"},
+		{tool: "hmac_sign", input: `{"algorithm": "sha1", ` + jefe + `}`, want: "effcdf6ae5eb2fa2d27416d5f184df9c259a7c79"},
+		{tool: "hmac_sign", input: `{"algorithm": "sha256", ` + jefe + `}`, want: "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{tool: "hmac_sign", input: `{"algorithm": "sha512", ` + jefe + `}`, want: "164b7a7bfcf819e2e395fbe73b56e0a387bd64222e831fd610270cd7ea2505549758bf75c05a994a6d034f65f8f0e6fdcaeab1a34d4a6b4b636e070a38bce737"},
+		{tool: "hmac_sign", input: `{"algorithm": "sha256", "output": "base64", ` + jefe + `}`, want: "W9zBRr9gdU5qBCQmCJV1x1oAPwidJzmDnexYuWTsOEM="},
+		{tool: "random_string", input: `{"length": 0}`, wantErr: "length must be between"},
+		{tool: "uuid", input: `{"version": "1"}`, wantErr: "must be one of"},
+	}
+	for _, tt := range tests {
+		out, err := registry.Execute(context.Background(), tt.tool, tt.input)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s %s: expected error containing %q, got %v (%s)", tt.tool, tt.input, tt.wantErr, err, out)
+			}
+		case err != nil:
+			t.Errorf("%s %s: %v", tt.tool, tt.input, err)
+		case out != tt.want:
+			t.Errorf("%s %s: expected %s, got %s", tt.tool, tt.input, tt.want, out)
+		}
+	}
+}
+
+func TestEncodingToolkit_Random(t *testing.T) {
+	registry := encodingRegistry(t)
+	ctx := context.Background()
+	tests := []struct {
+		tool, input string
+		pattern     string
+	}{
+		{"uuid", `{}`, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{"uuid", `{"version": "7"}`, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{"random_string", `{"length": 32}`, `^[A-Za-z0-9]{32}$`},
+		{"random_string", `{"length": 12, "charset": "digits"}`, `^[0-9]{12}$`},
+		{"random_string", `{"length": 40, "charset": "url_safe"}`, `^[A-Za-z0-9_-]{40}$`},
+	}
+	for _, tt := range tests {
+		first, err := registry.Execute(ctx, tt.tool, tt.input)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.tool, tt.input, err)
+		}
+		second, _ := registry.Execute(ctx, tt.tool, tt.input)
+		if !regexp.MustCompile(tt.pattern).MatchString(first) || first == second {
+			t.Errorf("%s %s: got %q then %q", tt.tool, tt.input, first, second)
+		}
+	}
+
+	// Version 7 UUIDs created later sort later.
+	a, _ := registry.Execute(ctx, "uuid", `{"version": "7"}`)
+	b, _ := registry.Execute(ctx, "uuid", `{"version": "7"}`)
+	if a[:13] > b[:13] {
+		t.Errorf("Expected time-ordered v7 UUIDs, got %s then %s", a, b)
+	}
+}
@@ -0,0 +1,299 @@
+// Package tools provides an arithmetic expression evaluator.
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxExpressionLength bounds the length of an expression.
+const maxExpressionLength = 1000
+
+// ExpressionTool evaluates arithmetic expressions such as "((3+4)*7)/2" in
+// one call. It supports + - * / % ^, parentheses, unary minus and the
+// functions in expressionFuncs; anything else is rejected.
+func ExpressionTool() *Tool {
+	return Build("evaluate_expression").
+		Description("Evaluate an arithmetic expression, e.g. \"((3+4)*7)/2\" or \"sqrt(2)^2 + max(1, 5, 3)\". Supports + - * / % ^ (power, right-associative), parentheses, unary minus and the functions sqrt, abs, round, floor, ceil, min, max, pow(x, y) and log(x) or log(x, base). Variables and other functions are not supported").
+		Cacheable().
+		Param("expression", "string", fmt.Sprintf("The expression, up to %d characters", maxExpressionLength)).
+		Handler(func(ctx context.Context, input string) (string, error) {
+			// With a single parameter the builder passes the expression
+			// itself.
+			result, err := evalExpression(input)
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatFloat(result, 'g', -1, 64), nil
+		}).
+		Create()
+}
+
+// expressionFuncs are the functions an expression may call.
+var expressionFuncs = map[string]struct {
+	minArgs, maxArgs int // maxArgs < 0 means any number
+	fn               func(args []float64) float64
+}{
+	"sqrt":  {1, 1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"abs":   {1, 1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"round": {1, 1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"floor": {1, 1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, 1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"pow":   {2, 2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min": {1, -1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	}},
+	"max": {1, -1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	}},
+	"log": {1, 2, func(a []float64) float64 {
+		if len(a) == 2 {
+			return math.Log(a[0]) / math.Log(a[1])
+		}
+		return math.Log(a[0])
+	}},
+}
+
+// evalExpression parses and evaluates expr.
+func evalExpression(expr string) (float64, error) {
+	if len(expr) > maxExpressionLength {
+		return 0, fmt.Errorf("expression is %d characters; the limit is %d", len(expr), maxExpressionLength)
+	}
+	p := &exprParser{s: expr}
+	if p.skipSpace(); p.pos == len(p.s) {
+		return 0, fmt.Errorf("expression is empty")
+	}
+	v, err := p.sum()
+	if err != nil {
+		return 0, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return 0, p.unexpected()
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+	return v, nil
+}
+
+// exprParser is a recursive-descent parser that evaluates as it goes:
+//
+//	sum     = product {("+" | "-") product}
+//	product = unary {("*" | "/" | "%") unary}
+//	unary   = ("-" | "+") unary | power
+//	power   = primary ["^" unary]
+//	primary = number | name "(" sum {"," sum} ")" | "(" sum ")"
+type exprParser struct {
+	s   string
+	pos int
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+// next skips spaces and returns the next byte, or 0 at the end.
+func (p *exprParser) next() byte {
+	p.skipSpace()
+	if p.pos == len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *exprParser) unexpected() error {
+	if p.pos >= len(p.s) {
+		return fmt.Errorf("unexpected end of expression")
+	}
+	return fmt.Errorf("unexpected %q at position %d", p.s[p.pos], p.pos+1)
+}
+
+func (p *exprParser) sum() (float64, error) {
+	v, err := p.product()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.next()
+		if op != '+' && op != '-' {
+			return v, nil
+		}
+		p.pos++
+		w, err := p.product()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			v += w
+		} else {
+			v -= w
+		}
+	}
+}
+
+func (p *exprParser) product() (float64, error) {
+	v, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.next()
+		if op != '*' && op != '/' && op != '%' {
+			return v, nil
+		}
+		p.pos++
+		w, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case op == '*':
+			v *= w
+		case w == 0:
+			return 0, fmt.Errorf("division by zero")
+		case op == '/':
+			v /= w
+		default:
+			v = math.Mod(v, w)
+		}
+	}
+}
+
+func (p *exprParser) unary() (float64, error) {
+	switch p.next() {
+	case '-':
+		p.pos++
+		v, err := p.unary()
+		return -v, err
+	case '+':
+		p.pos++
+		return p.unary()
+	}
+	return p.power()
+}
+
+func (p *exprParser) power() (float64, error) {
+	v, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if p.next() != '^' {
+		return v, nil
+	}
+	p.pos++
+	// The exponent is parsed as a unary so 2^-1 works and 2^3^2 is 2^(3^2).
+	w, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(v, w), nil
+}
+
+func (p *exprParser) primary() (float64, error) {
+	switch c := p.next(); {
+	case c == '(':
+		p.pos++
+		v, err := p.sum()
+		if err != nil {
+			return 0, err
+		}
+		if p.next() != ')' {
+			return 0, p.unexpected()
+		}
+		p.pos++
+		return v, nil
+	case c >= '0' && c <= '9' || c == '.':
+		return p.number()
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		return p.call()
+	}
+	return 0, p.unexpected()
+}
+
+func (p *exprParser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.s) && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] == '.') {
+		p.pos++
+	}
+	// An exponent, as in 1e3 or 2.5E-4.
+	if p.pos < len(p.s) && (p.s[p.pos] == 'e' || p.s[p.pos] == 'E') {
+		end := p.pos + 1
+		if end < len(p.s) && (p.s[end] == '+' || p.s[end] == '-') {
+			end++
+		}
+		if end < len(p.s) && p.s[end] >= '0' && p.s[end] <= '9' {
+			for end < len(p.s) && p.s[end] >= '0' && p.s[end] <= '9' {
+				end++
+			}
+			p.pos = end
+		}
+	}
+	v, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q at position %d", p.s[start:p.pos], start+1)
+	}
+	return v, nil
+}
+
+func (p *exprParser) call() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.s) && isAlnum(p.s[p.pos]) {
+		p.pos++
+	}
+	name := p.s[start:p.pos]
+	f, ok := expressionFuncs[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown function or name %q at position %d: use sqrt, abs, round, floor, ceil, min, max, pow or log", name, start+1)
+	}
+	if p.next() != '(' {
+		return 0, fmt.Errorf("%s at position %d must be called with parentheses", name, start+1)
+	}
+	p.pos++
+
+	var args []float64
+	if p.next() != ')' {
+		for {
+			v, err := p.sum()
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, v)
+			if p.next() != ',' {
+				break
+			}
+			p.pos++
+		}
+	}
+	if p.next() != ')' {
+		return 0, p.unexpected()
+	}
+	p.pos++
+
+	if len(args) < f.minArgs || f.maxArgs >= 0 && len(args) > f.maxArgs {
+		want := strconv.Itoa(f.minArgs)
+		switch {
+		case f.maxArgs < 0:
+			want = "at least " + want
+		case f.maxArgs > f.minArgs:
+			want += " or " + strconv.Itoa(f.maxArgs)
+		}
+		if want == "1" {
+			return 0, fmt.Errorf("%s takes 1 argument, got %d", name, len(args))
+		}
+		return 0, fmt.Errorf("%s takes %s arguments, got %d", name, want, len(args))
+	}
+	return f.fn(args), nil
+}
@@ -0,0 +1,142 @@
+package tools_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func evaluate(t *testing.T, expr string) (string, error) {
+	t.Helper()
+	registry := tools.NewRegistry()
+	registry.Register(tools.ExpressionTool())
+	return registry.Execute(context.Background(), "evaluate_expression", fmt.Sprintf(`{"expression": %q}`, expr))
+}
+
+func TestExpressionTool(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr string
+	}{
+		{"((3+4)*7)/2", "24.5", ""},
+		{"1 + 2 * 3", "7", ""},
+		{"(1 + 2) * 3", "9", ""},
+		{"10 - 4 - 3", "3", ""},
+		{"2 * 3 % 4", "2", ""},
+		{"7 % -3", "1", ""},
+		{"2 ^ 3 ^ 2", "512", ""},
+		{"-2 ^ 2", "-4", ""},
+		{"2 ^ -1", "0.5", ""},
+		{"--3", "3", ""},
+		{"-(1 + 2)", "-3", ""},
+		{"1.5e3 + .5", "1500.5", ""},
+		{"sqrt(16) + abs(-2)", "6", ""},
+		{"round(2.5) + floor(-1.5) + ceil(1.2)", "3", ""},
+		{"min(3, 1, 2) + max(4)", "5", ""},
+		{"pow(2, 10)", "1024", ""},
+		{"log(100, 10)", "2", ""},
+		{"log(1)", "0", ""},
+		{"SQRT(9)", "3", ""},
+		{" 1 +\t2 ", "3", ""},
+		{"1 / 0", "", "division by zero"},
+		{"5 % 0", "", "division by zero"},
+		{"sqrt(-1)", "", "not a finite number"},
+		{"log(0)", "", "not a finite number"},
+		{"pi * 2", "", `unknown function or name "pi"`},
+		{"exp(1)", "", `unknown function or name "exp"`},
+		{"sqrt 4", "", "must be called with parentheses"},
+		{"sqrt(1, 2)", "", "sqrt takes 1 argument, got 2"},
+		{"log()", "", "log takes 1 or 2 arguments, got 0"},
+		{"max()", "", "max takes at least 1 arguments, got 0"},
+		{"(1 + 2", "", "unexpected end of expression"},
+		{"1 + 2)", "", `unexpected ')' at position 6`},
+		{"1 +", "", "unexpected end of expression"},
+		{"1 2", "", `unexpected '2' at position 3`},
+		{"1.2.3", "", `invalid number "1.2.3"`},
+		{"2 ** 3", "", `unexpected '*' at position 4`},
+		{"x = 1", "", `unknown function or name "x"`},
+		{"   ", "", "expression is empty"},
+		{strings.Repeat("1+", 500) + "1", "", "the limit is 1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := evaluate(t, tt.expr)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error %q, got %q (%v)", tt.wantErr, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Got %q (%v), want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// genExpr builds a random fully parenthesized expression and its value.
+func genExpr(r *rand.Rand, depth int) (string, float64) {
+	if depth == 0 || r.Intn(4) == 0 {
+		n := float64(r.Intn(100)) / float64(1+r.Intn(4))
+		return strconv.FormatFloat(n, 'g', -1, 64), n
+	}
+	a, x := genExpr(r, depth-1)
+	b, y := genExpr(r, depth-1)
+	switch r.Intn(12) {
+	case 0:
+		return "(" + a + " + " + b + ")", x + y
+	case 1:
+		return "(" + a + " - " + b + ")", x - y
+	case 2:
+		return "(" + a + " * " + b + ")", x * y
+	case 3:
+		if y == 0 {
+			return "(" + a + " + " + b + ")", x + y
+		}
+		return "(" + a + " / " + b + ")", x / y
+	case 4:
+		if y == 0 {
+			return "(" + a + " - " + b + ")", x - y
+		}
+		return "(" + a + " % " + b + ")", math.Mod(x, y)
+	case 5:
+		return "-" + a, -x
+	case 6:
+		return "(" + a + ")^2", x * x
+	case 7:
+		return "sqrt(abs(" + a + "))", math.Sqrt(math.Abs(x))
+	case 8:
+		return "min(" + a + ", " + b + ")", math.Min(x, y)
+	case 9:
+		return "max(" + a + ", " + b + ", 0)", math.Max(math.Max(x, y), 0)
+	case 10:
+		return "round(" + a + ")", math.Round(x)
+	default:
+		return "pow(" + a + ", 1)", x
+	}
+}
+
+func TestExpressionTool_Generated(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		expr, want := genExpr(r, 5)
+		if len(expr) > 1000 || math.IsInf(want, 0) || math.IsNaN(want) {
+			continue
+		}
+		got, err := evaluate(t, expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		v, err := strconv.ParseFloat(got, 64)
+		if err != nil || math.Abs(v-want) > 1e-9*math.Max(1, math.Abs(want)) {
+			t.Fatalf("%s = %s, want %v", expr, got, want)
+		}
+	}
+}
@@ -4,13 +4,19 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-// FileToolkit returns tools for filesystem operations.
+// FileToolkit returns tools for filesystem operations confined to
+// allowedPaths. Paths are checked after resolving symlinks, may not contain
+// "..", and writes never go through a symlink. With no allowed paths any
+// path is accepted.
 // Note: These tools should be used carefully with proper sandboxing.
 func FileToolkit(allowedPaths ...string) *Toolkit {
 	validator := newPathValidator(allowedPaths)
@@ -24,17 +30,63 @@ func FileToolkit(allowedPaths ...string) *Toolkit {
 			listDirTool(validator),
 			fileInfoTool(validator),
 			searchFilesTool(validator),
+			deleteFileTool(validator),
+			moveFileTool(validator),
+			copyFileTool(validator),
 		},
 	}
 }
 
-// pathValidator ensures operations stay within allowed paths.
+// pathValidator ensures operations stay within allowed paths. Paths are
+// compared after resolving symlinks, so a link inside an allowed directory
+// cannot reach outside it.
 type pathValidator struct {
-	allowedPaths []string
+	allowedPaths []string // absolute, as given
+	resolved     []string // absolute, with symlinks resolved
 }
 
 func newPathValidator(paths []string) *pathValidator {
-	return &pathValidator{allowedPaths: paths}
+	v := &pathValidator{}
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			abs = filepath.Clean(p)
+		}
+		real, err := resolvePath(abs)
+		if err != nil {
+			real = abs
+		}
+		v.allowedPaths = append(v.allowedPaths, abs)
+		v.resolved = append(v.resolved, real)
+	}
+	return v
+}
+
+// resolvePath resolves the symlinks in the absolute path abs. Missing
+// trailing components, such as a file about to be written, are kept as is.
+func resolvePath(abs string) (string, error) {
+	var rest []string
+	for p := abs; ; p = filepath.Dir(p) {
+		real, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(append([]string{real}, rest...)...), nil
+		}
+		if _, lerr := os.Lstat(p); lerr == nil || !errors.Is(err, fs.ErrNotExist) {
+			// p exists but does not resolve, e.g. a dangling symlink.
+			return "", err
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return abs, nil
+		}
+		rest = append([]string{filepath.Base(p)}, rest...)
+	}
+}
+
+// within reports whether path is dir or inside it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
 
 func (v *pathValidator) validate(path string) error {
@@ -42,14 +94,22 @@ func (v *pathValidator) validate(path string) error {
 		return nil // No restrictions
 	}
 
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == filepath.Separator }) {
+		if part == ".." {
+			return fmt.Errorf("path must not contain \"..\": %s", path)
+		}
+	}
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return fmt.Errorf("invalid path: %w", err)
 	}
+	realPath, err := resolvePath(absPath)
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
 
-	for _, allowed := range v.allowedPaths {
-		allowedAbs, _ := filepath.Abs(allowed)
-		if strings.HasPrefix(absPath, allowedAbs) {
+	for _, allowed := range v.resolved {
+		if within(realPath, allowed) {
 			return nil
 		}
 	}
@@ -57,6 +117,36 @@ func (v *pathValidator) validate(path string) error {
 	return fmt.Errorf("path not in allowed directories: %s", path)
 }
 
+// validateWrite is validate for paths about to be written. It also refuses
+// symlinks anywhere below the allowed directory, since a write follows them
+// and the link could be swapped after the check.
+func (v *pathValidator) validateWrite(path string) error {
+	if err := v.validate(path); err != nil || len(v.allowedPaths) == 0 {
+		return err
+	}
+
+	absPath, _ := filepath.Abs(path)
+	for p := absPath; !v.isRoot(p); p = filepath.Dir(p) {
+		if info, err := os.Lstat(p); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to write through symlink %s", p)
+		}
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	return nil
+}
+
+// isRoot reports whether p is an allowed directory.
+func (v *pathValidator) isRoot(p string) bool {
+	for i := range v.allowedPaths {
+		if p == v.allowedPaths[i] || p == v.resolved[i] {
+			return true
+		}
+	}
+	return false
+}
+
 func readFileTool(validator *pathValidator) *Tool {
 	return Build("read_file").
 		Description("Read the contents of a file").
@@ -92,6 +182,7 @@ func readFileTool(validator *pathValidator) *Tool {
 func writeFileTool(validator *pathValidator) *Tool {
 	return Build("write_file").
 		Description("Write content to a file").
+		RequiresConfirmation().
 		Param("path", "string", "Path to the file to write").
 		Param("content", "string", "Content to write").
 		OptionalParam("append", "boolean", "Append instead of overwrite").
@@ -105,7 +196,7 @@ func writeFileTool(validator *pathValidator) *Tool {
 				return "", err
 			}
 
-			if err := validator.validate(params.Path); err != nil {
+			if err := validator.validateWrite(params.Path); err != nil {
 				return "", err
 			}
 
@@ -268,9 +359,15 @@ func searchFilesTool(validator *pathValidator) *Tool {
 			var matches []map[string]any
 
 			err := filepath.Walk(params.Path, func(path string, info os.FileInfo, err error) error {
+				if Canceled(ctx) {
+					return filepath.SkipAll
+				}
 				if err != nil || info.IsDir() {
 					return nil
 				}
+				if info.Mode()&os.ModeSymlink != 0 && validator.validate(path) != nil {
+					return nil // links out of the allowed directories
+				}
 
 				if params.Pattern != "" {
 					matched, _ := filepath.Match(params.Pattern, filepath.Base(path))
@@ -315,7 +412,301 @@ func searchFilesTool(validator *pathValidator) *Tool {
 			}
 
 			result, _ := json.MarshalIndent(matches, "", "  ")
+			if Canceled(ctx) {
+				PartialResult(ctx, string(result))
+				return "", ctx.Err()
+			}
 			return string(result), nil
 		}).
 		Create()
 }
+
+// maxListedPaths caps the paths listed in a file operation's result.
+const maxListedPaths = 100
+
+// fileOp is the JSON result of delete_file, move_file and copy_file.
+type fileOp struct {
+	Operation   string   `json:"operation"`
+	Source      string   `json:"source"`
+	Destination string   `json:"destination,omitempty"`
+	Paths       []string `json:"paths"` // affected files, up to maxListedPaths
+	Files       int      `json:"files"`
+	Bytes       int64    `json:"bytes"`
+	DryRun      bool     `json:"dry_run,omitempty"`
+}
+
+// add records a file of size bytes.
+func (op *fileOp) add(path string, size int64) {
+	if len(op.Paths) < maxListedPaths {
+		op.Paths = append(op.Paths, path)
+	}
+	op.Files++
+	op.Bytes += size
+}
+
+func (op *fileOp) json() (string, error) {
+	out, err := json.MarshalIndent(op, "", "  ")
+	return string(out), err
+}
+
+// sourceInfo stats path, which must be a directory only when recursive.
+func sourceInfo(path string, recursive bool, verb string) (fs.FileInfo, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat: %w", err)
+	}
+	if info.IsDir() && !recursive {
+		return nil, fmt.Errorf("%s is a directory; set recursive to %s it and everything in it", path, verb)
+	}
+	return info, nil
+}
+
+// walkFiles calls fn for every file under root without following symlinks.
+func walkFiles(root string, fn func(path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(path, info)
+	})
+}
+
+// checkDestination fails if dst exists and overwrite is not set. An existing
+// directory is never replaced.
+func checkDestination(dst string, overwrite bool) error {
+	info, err := os.Lstat(dst)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to stat: %w", err)
+	case info.IsDir():
+		return fmt.Errorf("destination %s is an existing directory", dst)
+	case !overwrite:
+		return fmt.Errorf("destination %s exists; set overwrite to replace it", dst)
+	}
+	return nil
+}
+
+func deleteFileTool(validator *pathValidator) *Tool {
+	return Build("delete_file").
+		Description("Delete a file, or a directory with recursive. Use dry_run to see what would be deleted").
+		RequiresConfirmation().
+		Param("path", "string", "Path to delete").
+		OptionalParam("recursive", "boolean", "Delete a directory and everything in it").
+		OptionalParam("dry_run", "boolean", "Report what would be deleted without deleting").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Path      string `json:"path"`
+				Recursive bool   `json:"recursive"`
+				DryRun    bool   `json:"dry_run"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+
+			if err := validator.validateWrite(params.Path); err != nil {
+				return "", err
+			}
+			if abs, _ := filepath.Abs(params.Path); validator.isRoot(abs) {
+				return "", fmt.Errorf("refusing to delete allowed directory %s", params.Path)
+			}
+			info, err := sourceInfo(params.Path, params.Recursive, "delete")
+			if err != nil {
+				return "", err
+			}
+
+			op := &fileOp{Operation: "delete", Source: params.Path, DryRun: params.DryRun}
+			if info.IsDir() {
+				if err := walkFiles(params.Path, func(path string, info fs.FileInfo) error {
+					op.add(path, info.Size())
+					return nil
+				}); err != nil {
+					return "", err
+				}
+			} else {
+				op.add(params.Path, info.Size())
+			}
+			if !params.DryRun {
+				if err := os.RemoveAll(params.Path); err != nil {
+					return "", fmt.Errorf("failed to delete: %w", err)
+				}
+			}
+			return op.json()
+		}).
+		Create()
+}
+
+func moveFileTool(validator *pathValidator) *Tool {
+	return Build("move_file").
+		Description("Move or rename a file or directory. Use dry_run to see what would be moved").
+		RequiresConfirmation().
+		Param("source", "string", "Path to move").
+		Param("destination", "string", "New path").
+		OptionalParam("overwrite", "boolean", "Replace an existing destination file").
+		OptionalParam("dry_run", "boolean", "Report what would be moved without moving").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Source      string `json:"source"`
+				Destination string `json:"destination"`
+				Overwrite   bool   `json:"overwrite"`
+				DryRun      bool   `json:"dry_run"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+
+			for _, path := range []string{params.Source, params.Destination} {
+				if err := validator.validateWrite(path); err != nil {
+					return "", err
+				}
+			}
+			if abs, _ := filepath.Abs(params.Source); validator.isRoot(abs) {
+				return "", fmt.Errorf("refusing to move allowed directory %s", params.Source)
+			}
+			info, err := sourceInfo(params.Source, true, "move")
+			if err != nil {
+				return "", err
+			}
+			if err := checkDestination(params.Destination, params.Overwrite); err != nil {
+				return "", err
+			}
+
+			op := &fileOp{Operation: "move", Source: params.Source, Destination: params.Destination, DryRun: params.DryRun}
+			if info.IsDir() {
+				if err := walkFiles(params.Source, func(path string, info fs.FileInfo) error {
+					rel, _ := filepath.Rel(params.Source, path)
+					op.add(filepath.Join(params.Destination, rel), info.Size())
+					return nil
+				}); err != nil {
+					return "", err
+				}
+			} else {
+				op.add(params.Destination, info.Size())
+			}
+			if !params.DryRun {
+				if err := os.MkdirAll(filepath.Dir(params.Destination), 0755); err != nil {
+					return "", fmt.Errorf("failed to create directory: %w", err)
+				}
+				if err := os.Rename(params.Source, params.Destination); err != nil {
+					return "", fmt.Errorf("failed to move: %w", err)
+				}
+			}
+			return op.json()
+		}).
+		Create()
+}
+
+func copyFileTool(validator *pathValidator) *Tool {
+	return Build("copy_file").
+		Description("Copy a file, or a directory with recursive. Use dry_run to see what would be copied").
+		RequiresConfirmation().
+		Param("source", "string", "Path to copy").
+		Param("destination", "string", "Path of the copy").
+		OptionalParam("recursive", "boolean", "Copy a directory and everything in it").
+		OptionalParam("overwrite", "boolean", "Replace existing destination files").
+		OptionalParam("dry_run", "boolean", "Report what would be copied without copying").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				Source      string `json:"source"`
+				Destination string `json:"destination"`
+				Recursive   bool   `json:"recursive"`
+				Overwrite   bool   `json:"overwrite"`
+				DryRun      bool   `json:"dry_run"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+
+			if err := validator.validate(params.Source); err != nil {
+				return "", err
+			}
+			if err := validator.validateWrite(params.Destination); err != nil {
+				return "", err
+			}
+			info, err := sourceInfo(params.Source, params.Recursive, "copy")
+			if err != nil {
+				return "", err
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				return "", fmt.Errorf("refusing to copy symlink %s", params.Source)
+			}
+
+			// Plan the copy first so a conflict leaves nothing half-copied.
+			type copyPair struct {
+				src, dst string
+				info     fs.FileInfo
+			}
+			var pairs []copyPair
+			if info.IsDir() {
+				src, _ := filepath.Abs(params.Source)
+				dst, _ := filepath.Abs(params.Destination)
+				if within(dst, src) {
+					return "", fmt.Errorf("cannot copy %s into itself", params.Source)
+				}
+				err = walkFiles(params.Source, func(path string, info fs.FileInfo) error {
+					if !info.Mode().IsRegular() {
+						return nil // symlinks and devices are not copied
+					}
+					rel, _ := filepath.Rel(params.Source, path)
+					pairs = append(pairs, copyPair{path, filepath.Join(params.Destination, rel), info})
+					return nil
+				})
+				if err != nil {
+					return "", err
+				}
+			} else {
+				pairs = append(pairs, copyPair{params.Source, params.Destination, info})
+			}
+
+			op := &fileOp{Operation: "copy", Source: params.Source, Destination: params.Destination, DryRun: params.DryRun}
+			for _, p := range pairs {
+				if err := checkDestination(p.dst, params.Overwrite); err != nil {
+					return "", err
+				}
+				op.add(p.dst, p.info.Size())
+			}
+			if params.DryRun {
+				return op.json()
+			}
+			for _, p := range pairs {
+				if Canceled(ctx) {
+					return "", ctx.Err()
+				}
+				if err := copyFile(p.src, p.dst, p.info.Mode().Perm()); err != nil {
+					return "", err
+				}
+			}
+			return op.json()
+		}).
+		Create()
+}
+
+// copyFile copies the regular file src to dst, creating dst's directory.
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy: %w", err)
+	}
+	return out.Close()
+}
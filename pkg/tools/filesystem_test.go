@@ -0,0 +1,255 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// sandboxDirs creates base/data, the allowed directory, next to base/data-evil
+// and base/outside, each holding files, plus symlinks out of and within data.
+func sandboxDirs(t *testing.T) (base string) {
+	t.Helper()
+	base = t.TempDir()
+	for _, dir := range []string{"data/sub", "data-evil", "outside"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"data/notes.txt":     "needle inside",
+		"data/sub/inner.txt": "inner",
+		"data-evil/secret":   "needle evil",
+		"outside/secret":     "needle outside",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(base, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"data/escape":   filepath.Join(base, "outside"),
+		"data/passwd":   filepath.Join(base, "outside/secret"),
+		"data/alias":    filepath.Join(base, "data/sub"),
+		"data/dangling": filepath.Join(base, "outside/created"),
+		"datalink":      filepath.Join(base, "data"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(base, name)); err != nil {
+			t.Skipf("symlinks unsupported: %v", err)
+		}
+	}
+	return base
+}
+
+func fileCall(t *testing.T, registry *tools.Registry, tool string, params map[string]any) (string, error) {
+	t.Helper()
+	input, _ := json.Marshal(params)
+	return registry.Execute(context.Background(), tool, string(input))
+}
+
+func TestFileToolkit_PathValidation(t *testing.T) {
+	base := sandboxDirs(t)
+	registry := tools.NewRegistry()
+	tools.FileToolkit(filepath.Join(base, "data")).RegisterTo(registry)
+	path := func(p string) string { return filepath.Join(base, p) }
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"file inside", path("data/notes.txt"), ""},
+		{"allowed directory itself", path("data"), ""},
+		{"nested file", path("data/sub/inner.txt"), ""},
+		{"symlink within the directory", path("data/alias/inner.txt"), ""},
+		{"allowed directory through a symlink", path("datalink/notes.txt"), ""},
+		{"prefix collision", path("data-evil/secret"), "not in allowed directories"},
+		{"parent directory", base, "not in allowed directories"},
+		{"dot-dot component", base + "/data/../data-evil/secret", `must not contain ".."`},
+		{"dot-dot back inside", base + "/data/sub/../notes.txt", `must not contain ".."`},
+		{"symlinked directory escape", path("data/escape/secret"), "not in allowed directories"},
+		{"symlinked file escape", path("data/passwd"), "not in allowed directories"},
+		{"dangling symlink", path("data/dangling"), "invalid path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fileCall(t, registry, "file_info", map[string]any{"path": tt.path})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected %s to be allowed, got %v", tt.path, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected %s to fail with %q, got %v", tt.path, tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFileToolkit_Writes(t *testing.T) {
+	base := sandboxDirs(t)
+	registry := tools.NewRegistry()
+	tools.FileToolkit(filepath.Join(base, "data")).RegisterTo(registry)
+	path := func(p string) string { return filepath.Join(base, p) }
+
+	if _, err := fileCall(t, registry, "write_file", map[string]any{"path": path("data/new/deep/file.txt"), "content": "ok"}); err != nil {
+		t.Errorf("Expected a write to new directories to be allowed: %v", err)
+	}
+
+	for _, p := range []string{
+		"data/alias/planted.txt",   // symlinked parent, even though it stays inside
+		"data/escape/planted.txt",  // symlinked parent pointing outside
+		"data/passwd",              // symlinked file
+		"data/dangling",            // dangling symlink to a file outside
+		"data-evil/planted.txt",    // prefix collision
+		"datalink/sub/planted.txt", // allowed directory through a symlink
+	} {
+		_, err := fileCall(t, registry, "write_file", map[string]any{"path": path(p), "content": "pwned"})
+		if err == nil {
+			t.Errorf("Expected writing %s to be refused", p)
+		}
+	}
+	if data, _ := os.ReadFile(path("outside/secret")); string(data) != "needle outside" {
+		t.Errorf("File outside the sandbox was changed: %q", data)
+	}
+	for _, p := range []string{"outside/created", "outside/planted.txt", "data/sub/planted.txt", "data-evil/planted.txt"} {
+		if _, err := os.Lstat(path(p)); err == nil {
+			t.Errorf("Expected %s not to be created", p)
+		}
+	}
+}
+
+func TestFileToolkit_RelativePaths(t *testing.T) {
+	base := sandboxDirs(t)
+	t.Chdir(base)
+	registry := tools.NewRegistry()
+	tools.FileToolkit("data").RegisterTo(registry)
+
+	if out, err := fileCall(t, registry, "read_file", map[string]any{"path": "data/notes.txt"}); err != nil || out != "needle inside" {
+		t.Errorf("Expected a relative path inside to be readable, got %q, %v", out, err)
+	}
+	for _, p := range []string{"data-evil/secret", "./data/../outside/secret", "data/escape/secret", "."} {
+		if _, err := fileCall(t, registry, "read_file", map[string]any{"path": p}); err == nil {
+			t.Errorf("Expected %s to be refused", p)
+		}
+	}
+}
+
+func TestFileToolkit_SearchSkipsEscapingLinks(t *testing.T) {
+	base := sandboxDirs(t)
+	registry := tools.NewRegistry()
+	tools.FileToolkit(filepath.Join(base, "data")).RegisterTo(registry)
+
+	out, err := fileCall(t, registry, "search_files", map[string]any{"path": filepath.Join(base, "data"), "query": "needle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "notes.txt") || strings.Contains(out, "passwd") {
+		t.Errorf("Expected only notes.txt to match, got %s", out)
+	}
+}
+
+func TestFileToolkit_Unrestricted(t *testing.T) {
+	base := sandboxDirs(t)
+	registry := tools.NewRegistry()
+	tools.FileToolkit().RegisterTo(registry)
+
+	if _, err := fileCall(t, registry, "read_file", map[string]any{"path": filepath.Join(base, "outside/secret")}); err != nil {
+		t.Errorf("Expected no restrictions without allowed paths, got %v", err)
+	}
+}
+
+func TestFileToolkit_DeleteMoveCopy(t *testing.T) {
+	base := sandboxDirs(t)
+	registry := tools.NewRegistry()
+	tools.FileToolkit(filepath.Join(base, "data")).RegisterTo(registry)
+	path := func(p string) string { return filepath.Join(base, p) }
+	exists := func(p string) bool {
+		_, err := os.Lstat(path(p))
+		return err == nil
+	}
+	type result struct {
+		Paths  []string `json:"paths"`
+		Files  int      `json:"files"`
+		Bytes  int64    `json:"bytes"`
+		DryRun bool     `json:"dry_run"`
+	}
+	call := func(tool string, params map[string]any) (result, error) {
+		t.Helper()
+		var r result
+		out, err := fileCall(t, registry, tool, params)
+		if err == nil {
+			err = json.Unmarshal([]byte(out), &r)
+		}
+		return r, err
+	}
+
+	// Copy a file, then refuse to overwrite it.
+	r, err := call("copy_file", map[string]any{"source": path("data/notes.txt"), "destination": path("data/copy/notes.txt")})
+	if err != nil || r.Files != 1 || r.Bytes != 13 || !exists("data/copy/notes.txt") {
+		t.Fatalf("Unexpected copy: %+v, %v", r, err)
+	}
+	if _, err := call("copy_file", map[string]any{"source": path("data/notes.txt"), "destination": path("data/copy/notes.txt")}); err == nil || !strings.Contains(err.Error(), "set overwrite") {
+		t.Errorf("Expected an existing destination error, got %v", err)
+	}
+
+	// Directories need recursive; dry runs change nothing.
+	if _, err := call("copy_file", map[string]any{"source": path("data/sub"), "destination": path("data/sub2")}); err == nil || !strings.Contains(err.Error(), "set recursive") {
+		t.Errorf("Expected a directory error, got %v", err)
+	}
+	r, err = call("copy_file", map[string]any{"source": path("data/sub"), "destination": path("data/sub2"), "recursive": true, "dry_run": true})
+	if err != nil || !r.DryRun || r.Files != 1 || r.Paths[0] != path("data/sub2/inner.txt") || exists("data/sub2") {
+		t.Errorf("Unexpected dry run: %+v, %v", r, err)
+	}
+	if _, err := call("copy_file", map[string]any{"source": path("data/sub"), "destination": path("data/sub/nested"), "recursive": true}); err == nil {
+		t.Error("Expected copying a directory into itself to fail")
+	}
+
+	// Move, checking both paths.
+	r, err = call("move_file", map[string]any{"source": path("data/copy/notes.txt"), "destination": path("data/moved.txt")})
+	if err != nil || r.Bytes != 13 || exists("data/copy/notes.txt") || !exists("data/moved.txt") {
+		t.Errorf("Unexpected move: %+v, %v", r, err)
+	}
+	for _, params := range []map[string]any{
+		{"source": path("data/moved.txt"), "destination": path("outside/moved.txt")},
+		{"source": path("data-evil/secret"), "destination": path("data/secret")},
+		{"source": path("data/moved.txt"), "destination": path("data/escape/moved.txt")},
+		{"source": path("data"), "destination": path("data2")},
+	} {
+		if _, err := call("move_file", params); err == nil {
+			t.Errorf("Expected move %v to be refused", params)
+		}
+	}
+	if _, err := call("copy_file", map[string]any{"source": path("data/passwd"), "destination": path("data/stolen")}); err == nil {
+		t.Error("Expected copying from a link outside to be refused")
+	}
+
+	// Delete.
+	if _, err := call("delete_file", map[string]any{"path": path("data/sub")}); err == nil || !strings.Contains(err.Error(), "set recursive") {
+		t.Errorf("Expected a directory error, got %v", err)
+	}
+	r, err = call("delete_file", map[string]any{"path": path("data/sub"), "recursive": true, "dry_run": true})
+	if err != nil || r.Files != 1 || !exists("data/sub/inner.txt") {
+		t.Errorf("Unexpected dry run: %+v, %v", r, err)
+	}
+	r, err = call("delete_file", map[string]any{"path": path("data/sub"), "recursive": true})
+	if err != nil || r.Files != 1 || r.Bytes != 5 || exists("data/sub") {
+		t.Errorf("Unexpected delete: %+v, %v", r, err)
+	}
+	for _, p := range []string{"data", "data-evil/secret", "data/escape/secret"} {
+		if _, err := call("delete_file", map[string]any{"path": path(p), "recursive": true}); err == nil || !exists(p) {
+			t.Errorf("Expected deleting %s to be refused", p)
+		}
+	}
+	// Symlinks are refused even as the thing deleted.
+	if _, err := call("delete_file", map[string]any{"path": path("data/escape")}); err == nil {
+		t.Error("Expected deleting through a symlink to be refused")
+	}
+}
@@ -0,0 +1,143 @@
+package tools_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func geminiRegistry() *tools.Registry {
+	noop := func(ctx context.Context, input string) (string, error) { return "", nil }
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{
+		Name:        "weather",
+		Description: "Get the weather forecast",
+		Parameters: tools.Schema{
+			Type: "object",
+			Properties: map[string]tools.Property{
+				"location": {Type: "string", Description: "City name"},
+				"unit":     {Type: "string", Enum: []string{"celsius", "fahrenheit"}},
+				"days":     {Type: "integer", Description: "Forecast length", Enum: []string{"1", "3", "7"}},
+			},
+			Required: []string{"location"},
+		},
+		Execute: noop,
+	})
+	registry.Register(&tools.Tool{
+		Name:        "order",
+		Description: "Place an order",
+		Parameters: tools.Schema{
+			Type: "object",
+			Properties: map[string]tools.Property{
+				"items": {
+					Type: "array",
+					Items: &tools.Property{
+						Type: "object",
+						Properties: map[string]tools.Property{
+							"sku":      {Type: "string"},
+							"quantity": {Type: "integer"},
+						},
+						Required: []string{"sku", "quantity"},
+					},
+				},
+				"counts": {Type: "object", Description: "Boxes per size", AdditionalProperties: &tools.Property{Type: "integer"}},
+				"gift":   {Type: "boolean"},
+				"weight": {Type: "number"},
+			},
+			Required: []string{"items"},
+		},
+		Execute: noop,
+	})
+	registry.Register(&tools.Tool{Name: "ping", Description: "Check the service", Execute: noop})
+	return registry
+}
+
+// geminiDeclaration mirrors Gemini's FunctionDeclaration, so decoding with
+// unknown fields disallowed fails on keywords Gemini does not accept.
+type geminiDeclaration struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Parameters  *geminiSchema `json:"parameters,omitempty"`
+}
+
+type geminiSchema struct {
+	Type        string                  `json:"type"`
+	Format      string                  `json:"format,omitempty"`
+	Description string                  `json:"description,omitempty"`
+	Enum        []string                `json:"enum,omitempty"`
+	Items       *geminiSchema           `json:"items,omitempty"`
+	Properties  map[string]geminiSchema `json:"properties,omitempty"`
+	Required    []string                `json:"required,omitempty"`
+}
+
+func TestRegistry_ToGeminiFormat(t *testing.T) {
+	data, err := json.Marshal(geminiRegistry().ToGeminiFormat())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := os.ReadFile("testdata/gemini_tools.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got, want any
+	json.Unmarshal(data, &got)
+	if err := json.Unmarshal(fixture, &want); err != nil {
+		t.Fatalf("Invalid fixture: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		indented, _ := json.MarshalIndent(got, "", "  ")
+		t.Errorf("Format does not match testdata/gemini_tools.json, got:\n%s", indented)
+	}
+
+	// The output decodes as Gemini declarations and encodes back the same.
+	var decls []geminiDeclaration
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&decls); err != nil {
+		t.Fatalf("Output is not valid Gemini declarations: %v", err)
+	}
+	again, _ := json.Marshal(decls)
+	var roundTrip any
+	json.Unmarshal(again, &roundTrip)
+	if !reflect.DeepEqual(roundTrip, want) {
+		t.Errorf("Declarations changed in a round trip:\n%s", again)
+	}
+}
+
+func TestRegistry_ToGeminiFormatLeavesOutMaps(t *testing.T) {
+	registry := tools.BuiltinTools()
+	tools.WebToolkit().RegisterTo(registry)
+	data, _ := json.Marshal(registry.ToGeminiFormat())
+	var decls []geminiDeclaration
+	if err := json.Unmarshal(data, &decls); err != nil {
+		t.Fatal(err)
+	}
+
+	var check func(path string, s *geminiSchema)
+	check = func(path string, s *geminiSchema) {
+		if s == nil {
+			return
+		}
+		if s.Type == "OBJECT" && len(s.Properties) == 0 {
+			t.Errorf("%s: OBJECT without properties", path)
+		}
+		check(path+"[]", s.Items)
+		for name, prop := range s.Properties {
+			check(path+"."+name, &prop)
+		}
+	}
+	for _, decl := range decls {
+		check(decl.Name, decl.Parameters)
+		if decl.Name == "http_get" {
+			if _, ok := decl.Parameters.Properties["headers"]; ok {
+				t.Error("Expected the http_get headers map to be left out")
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxListedKeys caps the keys listed when a path names a missing key.
+const maxListedKeys = 20
+
+// pathSegment is one step of a JSON path.
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func (s pathSegment) String() string {
+	switch {
+	case s.wildcard:
+		return "[*]"
+	case s.isIndex:
+		return fmt.Sprintf("[%d]", s.index)
+	case strings.ContainsAny(s.key, ".[]'\" "):
+		return fmt.Sprintf("[%q]", s.key)
+	}
+	return "." + s.key
+}
+
+// parsePath parses a JSONPath-style path: an optional leading "$", dotted
+// keys, [n] indexes (negative counts from the end), [*] or .* wildcards,
+// and ['key'] or ["key"] for keys with dots or brackets.
+func parsePath(path string) ([]pathSegment, error) {
+	p := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	var segs []pathSegment
+	for i := 0; i < len(p); {
+		switch p[i] {
+		case '.':
+			i++
+			if i == len(p) || p[i] == '.' || p[i] == '[' {
+				return nil, fmt.Errorf("invalid path %q: empty key at offset %d", path, i)
+			}
+			continue
+		case '[':
+			if i+1 < len(p) && (p[i+1] == '\'' || p[i+1] == '"') {
+				// A quoted key may hold dots and brackets.
+				n := strings.IndexByte(p[i+2:], p[i+1])
+				if n < 0 || i+n+3 >= len(p) || p[i+n+3] != ']' {
+					return nil, fmt.Errorf("invalid path %q: unterminated quoted key", path)
+				}
+				segs = append(segs, pathSegment{key: p[i+2 : i+2+n]})
+				i += n + 4
+				continue
+			}
+			end := strings.IndexByte(p[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unclosed [", path)
+			}
+			inner := strings.TrimSpace(p[i+1 : i+end])
+			switch n, err := strconv.Atoi(inner); {
+			case inner == "*":
+				segs = append(segs, pathSegment{wildcard: true})
+			case err == nil:
+				segs = append(segs, pathSegment{index: n, isIndex: true})
+			default:
+				return nil, fmt.Errorf("invalid path %q: [%s] is not an index, * or a quoted key", path, inner)
+			}
+			i += end + 1
+		default:
+			end := strings.IndexAny(p[i:], ".[")
+			if end < 0 {
+				end = len(p) - i
+			}
+			key := p[i : i+end]
+			if key == "*" {
+				segs = append(segs, pathSegment{wildcard: true})
+			} else {
+				segs = append(segs, pathSegment{key: key})
+			}
+			i += end
+		}
+	}
+	return segs, nil
+}
+
+// evalPath resolves path in data. Without wildcards it returns the single
+// value, or an error naming the step that did not resolve. With wildcards
+// it returns an array of every match, skipping branches that do not match.
+func evalPath(data any, path string) (any, error) {
+	segs, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	values := []any{data}
+	wild := false
+	at := "$"
+	for _, seg := range segs {
+		wild = wild || seg.wildcard
+		var next []any
+		for _, v := range values {
+			matched, err := step(v, seg, at)
+			if err != nil && !wild {
+				return nil, err
+			}
+			next = append(next, matched...)
+		}
+		values = next
+		at += seg.String()
+	}
+	if wild {
+		if values == nil {
+			values = []any{}
+		}
+		return values, nil
+	}
+	return values[0], nil
+}
+
+// step applies one segment to v, found at the path at.
+func step(v any, seg pathSegment, at string) ([]any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		if seg.wildcard {
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			out := make([]any, len(keys))
+			for i, k := range keys {
+				out[i] = v[k]
+			}
+			return out, nil
+		}
+		if seg.isIndex {
+			return nil, fmt.Errorf("path %s%s: %s is an object, not an array", at, seg, at)
+		}
+		value, ok := v[seg.key]
+		if !ok {
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			if len(keys) > maxListedKeys {
+				keys = append(keys[:maxListedKeys], "...")
+			}
+			return nil, fmt.Errorf("path %s%s: key %q not found; keys at %s are [%s]", at, seg, seg.key, at, strings.Join(keys, ", "))
+		}
+		return []any{value}, nil
+	case []any:
+		if seg.wildcard {
+			return v, nil
+		}
+		if !seg.isIndex {
+			return nil, fmt.Errorf("path %s%s: %s is an array of %d items; use an index like [0] or [*]", at, seg, at, len(v))
+		}
+		i := seg.index
+		if i < 0 {
+			i += len(v)
+		}
+		if i < 0 || i >= len(v) {
+			return nil, fmt.Errorf("path %s%s: index %d out of range for %d items", at, seg, seg.index, len(v))
+		}
+		return []any{v[i]}, nil
+	}
+	return nil, fmt.Errorf("path %s%s: %s is %s, which has no fields or items", at, seg, at, jsonKind(v))
+}
+
+// jsonKind names the JSON type of a decoded value.
+func jsonKind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
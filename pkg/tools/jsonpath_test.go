@@ -0,0 +1,61 @@
+package tools_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestJSONParse_Paths(t *testing.T) {
+	registry := tools.NewRegistry()
+	tools.DataToolkit().RegisterTo(registry)
+	const doc = `{"data": {"items": [{"id": 1, "name": "a", "tags": ["x", "y"]}, {"id": 2, "name": "b"}, {"id": 3}]},` +
+		` "a.b": {"c]": true}, "empty": [], "nothing": null}`
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr string
+	}{
+		{path: "data.items[0].name", want: `"a"`},
+		{path: "$.data.items[1].id", want: `2`},
+		{path: "data.items[-1]", want: `{"id":3}`},
+		{path: "data.items[-3].tags[1]", want: `"y"`},
+		{path: "data.items[*].name", want: `["a","b"]`},
+		{path: "data.items[*].tags[*]", want: `["x","y"]`},
+		{path: "data.items.*.id", want: `[1,2,3]`},
+		{path: "data.*", want: `[[{"id":1,"name":"a","tags":["x","y"]},{"id":2,"name":"b"},{"id":3}]]`},
+		{path: "empty[*]", want: `[]`},
+		{path: `['a.b']["c]"]`, want: `true`},
+		{path: "nothing", want: `null`},
+		{path: "$", want: `{"a.b"`},
+		{path: "data.items[0].nme", wantErr: `path $.data.items[0].nme: key "nme" not found; keys at $.data.items[0] are [id, name, tags]`},
+		{path: "data.items[3]", wantErr: "index 3 out of range for 3 items"},
+		{path: "data.items[-4]", wantErr: "index -4 out of range"},
+		{path: "data.items.name", wantErr: "$.data.items is an array of 3 items; use an index like [0] or [*]"},
+		{path: "data[0]", wantErr: "$.data is an object, not an array"},
+		{path: "data.items[0].id.value", wantErr: "$.data.items[0].id is a number"},
+		{path: "nothing.x", wantErr: "$.nothing is null"},
+		{path: "data.items[first]", wantErr: "is not an index"},
+		{path: "data.items[0", wantErr: "unclosed ["},
+		{path: "['a.b'", wantErr: "unterminated quoted key"},
+		{path: "data..items", wantErr: "empty key"},
+	}
+	for _, tt := range tests {
+		input := `{"json": ` + strconv.Quote(doc) + `, "path": ` + strconv.Quote(tt.path) + `}`
+		out, err := registry.Execute(context.Background(), "json_parse", input)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: expected error containing %q, got %v (%s)", tt.path, tt.wantErr, err, out)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.path, err)
+		case !strings.HasPrefix(out, tt.want):
+			t.Errorf("%s: expected %s, got %s", tt.path, tt.want, out)
+		}
+	}
+}
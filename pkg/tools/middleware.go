@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/metrics"
+)
+
+// ToolFunc executes the named tool with a JSON input.
+type ToolFunc func(ctx context.Context, name, jsonInput string) (string, error)
+
+// ToolMiddleware wraps a ToolFunc. It may change the input or output, or
+// return without calling next.
+type ToolMiddleware func(next ToolFunc) ToolFunc
+
+// Use adds middleware around every tool execution through the registry,
+// including tools registered later. The first middleware is the outermost.
+// Subsets made afterwards share it. It is safe for concurrent use.
+func (r *Registry) Use(mw ...ToolMiddleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.chain = append(r.chain, mw...)
+}
+
+// LoggingMiddleware logs each tool execution with its latency to logger,
+// or to slog.Default when logger is nil. Inputs and outputs are logged by
+// size only, as they may hold secrets.
+func LoggingMiddleware(logger core.Logger) ToolMiddleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next ToolFunc) ToolFunc {
+		return func(ctx context.Context, name, jsonInput string) (string, error) {
+			start := time.Now()
+			output, err := next(ctx, name, jsonInput)
+			args := []any{
+				"tool", name,
+				"duration", time.Since(start),
+				"input_bytes", len(jsonInput),
+				"output_bytes", len(output),
+			}
+			if err != nil {
+				logger.Error("tool call failed", append(args, "error", err)...)
+			} else {
+				logger.Info("tool call", args...)
+			}
+			return output, err
+		}
+	}
+}
+
+// MetricsMiddleware records the duration of each tool execution in
+// goflow_tool_duration_seconds.
+func MetricsMiddleware() ToolMiddleware {
+	return func(next ToolFunc) ToolFunc {
+		return func(ctx context.Context, name, jsonInput string) (string, error) {
+			defer metrics.ObserveToolDuration(time.Now())
+			return next(ctx, name, jsonInput)
+		}
+	}
+}
+
+// RateLimitMiddleware limits each tool to perSecond executions per second
+// on average, with bursts of up to burst. A call over the limit waits for
+// its turn, or returns the context's error if ctx ends first.
+func RateLimitMiddleware(perSecond float64, burst int) ToolMiddleware {
+	if burst < 1 {
+		burst = 1
+	}
+	var mu sync.Mutex
+	buckets := make(map[string]*bucket)
+
+	return func(next ToolFunc) ToolFunc {
+		return func(ctx context.Context, name, jsonInput string) (string, error) {
+			mu.Lock()
+			b, ok := buckets[name]
+			if !ok {
+				b = &bucket{tokens: float64(burst), last: time.Now()}
+				buckets[name] = b
+			}
+			wait := b.take(perSecond, float64(burst))
+			mu.Unlock()
+
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					mu.Lock()
+					b.tokens++ // give the reserved token back
+					mu.Unlock()
+					return "", ctx.Err()
+				}
+			}
+			return next(ctx, name, jsonInput)
+		}
+	}
+}
+
+// bucket is a token bucket. Tokens go negative for callers waiting on a
+// reserved token.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take reserves a token and returns how long to wait until it is available.
+func (b *bucket) take(rate, burst float64) time.Duration {
+	now := time.Now()
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
@@ -0,0 +1,140 @@
+package tools_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/metrics"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func echoTool(name string) *tools.Tool {
+	return &tools.Tool{
+		Name: name,
+		Execute: func(ctx context.Context, input string) (string, error) {
+			return input, nil
+		},
+	}
+}
+
+func TestRegistry_Use(t *testing.T) {
+	var trace []string
+	tag := func(label string) tools.ToolMiddleware {
+		return func(next tools.ToolFunc) tools.ToolFunc {
+			return func(ctx context.Context, name, input string) (string, error) {
+				trace = append(trace, label+" "+name)
+				return next(ctx, name, input+" "+label)
+			}
+		}
+	}
+	registry := tools.NewRegistry()
+	registry.Use(tag("outer"), tag("inner"))
+	registry.Register(echoTool("echo")) // registered after Use
+
+	output, err := registry.Execute(context.Background(), "echo", "in")
+	if err != nil || output != "in outer inner" {
+		t.Errorf("Expected the input rewritten in order, got %q (%v)", output, err)
+	}
+	if strings.Join(trace, ", ") != "outer echo, inner echo" {
+		t.Errorf("Unexpected order: %v", trace)
+	}
+
+	// ExecuteCalls and subsets go through the chain too.
+	subset, _ := registry.Subset("echo")
+	results := subset.ExecuteCalls(context.Background(), []tools.ToolCall{{ID: "1", Name: "echo", Arguments: "call"}})
+	if results[0].Content != "call outer inner" {
+		t.Errorf("Expected the subset's calls wrapped, got %q", results[0].Content)
+	}
+}
+
+func TestRegistry_UseShortCircuit(t *testing.T) {
+	denied := errors.New("denied")
+	ran := false
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{Name: "rm", Execute: func(ctx context.Context, input string) (string, error) {
+		ran = true
+		return "", nil
+	}})
+	registry.Use(func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, name, input string) (string, error) {
+			return "", denied
+		}
+	})
+
+	if _, err := registry.Execute(context.Background(), "rm", "{}"); !errors.Is(err, denied) || ran {
+		t.Errorf("Expected the middleware to stop the call, got %v (ran: %v)", err, ran)
+	}
+}
+
+func TestRegistry_UseRecoversPanics(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(echoTool("echo"))
+	registry.Use(func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, name, input string) (string, error) {
+			var limits map[string]int
+			limits[name]++ // nil map
+			return next(ctx, name, input)
+		}
+	})
+
+	_, err := registry.Execute(context.Background(), "echo", "{}")
+	var panicErr *tools.PanicError
+	if !errors.As(err, &panicErr) || panicErr.Tool != "echo" || !strings.Contains(panicErr.Stack, "middleware_test.go") {
+		t.Errorf("Expected the middleware panic as a *PanicError, got %v", err)
+	}
+	if stats := registry.Stats()["echo"]; stats.Errors != 1 {
+		t.Errorf("Expected the panic counted as an error, got %+v", stats)
+	}
+}
+
+func TestLoggingAndMetricsMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	registry := tools.NewRegistry()
+	registry.Register(echoTool("echo"))
+	registry.Use(tools.LoggingMiddleware(slog.New(slog.NewTextHandler(&buf, nil))), tools.MetricsMiddleware())
+	before := metrics.DefaultMetrics.ToolDuration.Count()
+
+	registry.Execute(context.Background(), "echo", "hello")
+	registry.Execute(context.Background(), "missing", "hello")
+
+	line := strings.TrimSpace(buf.String())
+	if strings.Count(line, "\n") != 0 || !strings.Contains(line, "tool=echo") || !strings.Contains(line, "input_bytes=5") || !strings.Contains(line, "duration=") {
+		t.Errorf("Unexpected log: %q", buf.String())
+	}
+	if strings.Contains(line, "hello") {
+		t.Error("Inputs must not be logged")
+	}
+	if got := metrics.DefaultMetrics.ToolDuration.Count() - before; got != 1 {
+		t.Errorf("Expected one duration observed, got %d", got)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(echoTool("a"))
+	registry.Register(echoTool("b"))
+	registry.Use(tools.RateLimitMiddleware(20, 1)) // one call per 50ms
+	ctx := context.Background()
+
+	start := time.Now()
+	registry.Execute(ctx, "a", "1")
+	registry.Execute(ctx, "b", "1") // separate bucket
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("Expected the first calls to run at once, took %s", elapsed)
+	}
+	registry.Execute(ctx, "a", "2")
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected the second call to a to wait, took %s", elapsed)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	if _, err := registry.Execute(short, "a", "3"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait to end with the context, got %v", err)
+	}
+}
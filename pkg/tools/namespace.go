@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+)
+
+// NamespaceSeparator joins a namespace and a tool name.
+const NamespaceSeparator = "."
+
+// Namespaced returns name prefixed with namespace, or name itself when
+// namespace is empty.
+func Namespaced(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + NamespaceSeparator + name
+}
+
+// RegisterNamespaced registers a copy of tool named "namespace.name". The
+// model sees and calls the namespaced name; tool itself is not modified.
+// It is safe for concurrent use.
+func (r *Registry) RegisterNamespaced(namespace string, tool *Tool) error {
+	if tool.Name == "" {
+		return fmt.Errorf("tools: tool name cannot be empty")
+	}
+	return r.Register(namespaced(namespace, tool))
+}
+
+// Merge registers every tool of other into r, under namespace when it is
+// not empty. If any name is already taken nothing is registered.
+// It is safe for concurrent use.
+func (r *Registry) Merge(other *Registry, namespace string) error {
+	incoming := other.List()
+	sort.Slice(incoming, func(i, j int) bool { return incoming[i].Name < incoming[j].Name })
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, tool := range incoming {
+		if _, exists := r.tools[Namespaced(namespace, tool.Name)]; exists {
+			return fmt.Errorf("tools: tool %q already registered", Namespaced(namespace, tool.Name))
+		}
+	}
+	for _, tool := range incoming {
+		tool = namespaced(namespace, tool)
+		r.tools[tool.Name] = tool
+	}
+	return nil
+}
+
+// namespaced returns tool renamed into namespace.
+func namespaced(namespace string, tool *Tool) *Tool {
+	if namespace == "" {
+		return tool
+	}
+	c := *tool
+	c.Name = Namespaced(namespace, tool.Name)
+	return &c
+}
@@ -0,0 +1,84 @@
+package tools_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestRegistry_RegisterNamespaced(t *testing.T) {
+	registry := tools.NewRegistry()
+	search := echoTool("search")
+	if err := registry.RegisterNamespaced("github", search); err != nil {
+		t.Fatalf("RegisterNamespaced: %v", err)
+	}
+	if err := registry.RegisterNamespaced("jira", search); err != nil {
+		t.Fatalf("Expected the same tool under another namespace to register: %v", err)
+	}
+	if search.Name != "search" {
+		t.Errorf("Expected the original tool to keep its name, got %q", search.Name)
+	}
+
+	out, err := registry.Execute(context.Background(), "jira.search", `{"q":"bug"}`)
+	if err != nil || out != `{"q":"bug"}` {
+		t.Errorf("Execute of a namespaced tool = %q, %v", out, err)
+	}
+	if _, err := registry.Execute(context.Background(), "search", "{}"); err == nil {
+		t.Error("Expected the bare name not to resolve")
+	}
+
+	names := map[string]bool{}
+	for _, def := range registry.ToOpenAIFormat() {
+		names[def["function"].(map[string]any)["name"].(string)] = true
+	}
+	for _, def := range registry.ToAnthropicFormat() {
+		names["anthropic:"+def["name"].(string)] = true
+	}
+	for _, want := range []string{"github.search", "jira.search", "anthropic:github.search", "anthropic:jira.search"} {
+		if !names[want] {
+			t.Errorf("Expected %s in the provider formats, got %v", want, names)
+		}
+	}
+}
+
+func TestRegistry_Merge(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(echoTool("search"))
+
+	other := tools.NewRegistry()
+	other.Register(echoTool("search"))
+	other.Register(echoTool("fetch"))
+
+	if err := registry.Merge(other, ""); err == nil {
+		t.Fatal("Expected a collision merging without a namespace")
+	}
+	if registry.Has("fetch") {
+		t.Error("Expected a failed merge to register nothing")
+	}
+
+	if err := registry.Merge(other, "mcp"); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	for _, name := range []string{"search", "mcp.search", "mcp.fetch"} {
+		if !registry.Has(name) {
+			t.Errorf("Expected %s after merge", name)
+		}
+	}
+	if !other.Has("search") || other.Has("mcp.search") {
+		t.Error("Expected the merged registry to be unchanged")
+	}
+}
+
+func TestToolkit_RegisterToNamespace(t *testing.T) {
+	registry := tools.NewRegistry()
+	if err := tools.MathToolkit().RegisterTo(registry, "math"); err != nil {
+		t.Fatalf("RegisterTo: %v", err)
+	}
+	if err := tools.MathToolkit().RegisterTo(registry); err != nil {
+		t.Fatalf("Expected the unprefixed toolkit not to collide: %v", err)
+	}
+	if !registry.Has("math.calculator") || !registry.Has("calculator") {
+		t.Errorf("Expected calculator with and without namespace, got %d tools", len(registry.List()))
+	}
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"github.com/nuulab/goflow/pkg/metrics"
+)
+
+// maxStackLines caps the stack trace kept in a PanicError.
+const maxStackLines = 20
+
+// PanicError is returned when a tool, or a middleware wrapping it,
+// panics. Stack is the panicking goroutine's stack from the panic site,
+// trimmed to a few frames.
+type PanicError struct {
+	Tool  string
+	Value any
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("tools: tool %q panicked: %v", e.Tool, e.Value)
+}
+
+// RegistryOption configures a Registry.
+type RegistryOption func(*Registry)
+
+// WithoutPanicRecovery lets panics in tools propagate to the caller
+// instead of returning a *PanicError, so a debugger stops at them.
+func WithoutPanicRecovery() RegistryOption {
+	return func(r *Registry) {
+		r.propagate = true
+	}
+}
+
+// recoverTool turns a panic in the named tool into a *PanicError in err.
+// It must be deferred.
+func recoverTool(name string, err *error) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	metrics.ToolPanicked()
+	*err = &PanicError{Tool: name, Value: value, Stack: trimStack(debug.Stack())}
+}
+
+// trimStack drops the frames of the recovery itself, up to the call to
+// panic, and keeps at most maxStackLines lines after it.
+func trimStack(stack []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(stack)), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "panic(") && i+2 <= len(lines) {
+			lines = lines[i+2:]
+			break
+		}
+	}
+	if len(lines) > maxStackLines {
+		lines = lines[:maxStackLines]
+	}
+	return strings.Join(lines, "\n")
+}
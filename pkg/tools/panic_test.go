@@ -0,0 +1,76 @@
+package tools_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/metrics"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func panicRegistry(opts ...tools.RegistryOption) *tools.Registry {
+	registry := tools.NewRegistry(opts...)
+	registry.Register(&tools.Tool{
+		Name: "lookup",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			var m map[string]string
+			m[input] = "boom" // nil map write
+			return "", nil
+		},
+	})
+	registry.Register(&tools.Tool{
+		Name: "echo",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			return input, nil
+		},
+	})
+	return registry
+}
+
+func TestRegistry_ExecuteRecoversPanic(t *testing.T) {
+	before := metrics.DefaultMetrics.ToolPanics.Value()
+
+	_, err := panicRegistry().Execute(context.Background(), "lookup", "{}")
+	var panicked *tools.PanicError
+	if !errors.As(err, &panicked) {
+		t.Fatalf("Expected a PanicError, got %v", err)
+	}
+	if panicked.Tool != "lookup" || !strings.Contains(err.Error(), "assignment to entry in nil map") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	// The trace starts at the panicking tool.
+	if first := strings.SplitN(panicked.Stack, "\n", 2)[0]; !strings.Contains(first, "panicRegistry") {
+		t.Errorf("Expected the stack to start at the tool, got:\n%s", panicked.Stack)
+	}
+	if got := metrics.DefaultMetrics.ToolPanics.Value() - before; got != 1 {
+		t.Errorf("Expected the panic counted once, got %v", got)
+	}
+}
+
+func TestRegistry_ExecuteCallsIsolatesPanics(t *testing.T) {
+	results := panicRegistry().ExecuteCalls(context.Background(), []tools.ToolCall{
+		{ID: "1", Name: "lookup", Arguments: "{}"},
+		{ID: "2", Name: "echo", Arguments: "ok"},
+	})
+
+	var panicked *tools.PanicError
+	if !errors.As(results[0].Err, &panicked) || !strings.Contains(results[0].Error, "panicked") {
+		t.Errorf("Expected the first call to fail with a PanicError, got %+v", results[0])
+	}
+	if results[1].Err != nil || results[1].Content != "ok" {
+		t.Errorf("Expected the second call unaffected, got %+v", results[1])
+	}
+}
+
+func TestRegistry_WithoutPanicRecovery(t *testing.T) {
+	registry := panicRegistry(tools.WithoutPanicRecovery())
+	subset, _ := registry.Subset("lookup")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected the panic to propagate")
+		}
+	}()
+	subset.Execute(context.Background(), "lookup", "{}")
+}
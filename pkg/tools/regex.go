@@ -0,0 +1,225 @@
+// Package tools provides regular expression tools.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// maxPatternLength bounds the length of a regex pattern.
+	maxPatternLength = 1000
+	// maxCachedPatterns bounds the compiled pattern cache.
+	maxCachedPatterns = 256
+	// defaultMatchLimit caps regex_extract results when no limit is given.
+	defaultMatchLimit = 100
+)
+
+// RegexToolkit returns tools for matching, extracting, replacing and
+// splitting text with regular expressions. Patterns use RE2 syntax, which
+// matches in linear time but has no lookarounds or backreferences.
+func RegexToolkit() *Toolkit {
+	return &Toolkit{
+		Name:        "regex",
+		Description: "Tools for regular expressions: match, extract, replace and split text",
+		Tools: []*Tool{
+			regexMatchTool(),
+			regexExtractTool(),
+			regexReplaceTool(),
+			regexSplitTool(),
+		},
+	}
+}
+
+// patterns caches compiled regexps by flags and pattern.
+var patterns = struct {
+	sync.Mutex
+	m map[string]*regexp.Regexp
+}{m: make(map[string]*regexp.Regexp)}
+
+// compileRegex compiles pattern with flags (any of i, m, s), reusing a
+// cached regexp when there is one.
+func compileRegex(pattern, flags string) (*regexp.Regexp, error) {
+	if len(pattern) > maxPatternLength {
+		return nil, fmt.Errorf("pattern is %d characters; the limit is %d", len(pattern), maxPatternLength)
+	}
+	if strings.Trim(flags, "ims") != "" {
+		return nil, fmt.Errorf("invalid flags %q: use any of i (ignore case), m (multi-line) and s (dot matches newline)", flags)
+	}
+	expr := pattern
+	if flags != "" {
+		expr = "(?" + flags + ")" + pattern
+	}
+
+	patterns.Lock()
+	defer patterns.Unlock()
+	if re, ok := patterns.m[expr]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w (patterns use RE2 syntax, without lookarounds or backreferences)", err)
+	}
+	if len(patterns.m) >= maxCachedPatterns {
+		clear(patterns.m)
+	}
+	patterns.m[expr] = re
+	return re, nil
+}
+
+// regexParams adds the parameters shared by the regex tools to b.
+func regexParams(b *ToolBuilder) *ToolBuilder {
+	return b.
+		Param("pattern", "string", "Regular expression in RE2 syntax").
+		Param("text", "string", "The text to search").
+		OptionalParam("flags", "string", "Any of i (ignore case), m (^ and $ match at lines), s (. matches newline)")
+}
+
+// regexInput holds the parameters shared by the regex tools.
+type regexInput struct {
+	Pattern string `json:"pattern"`
+	Text    string `json:"text"`
+	Flags   string `json:"flags"`
+}
+
+func (in regexInput) compile() (*regexp.Regexp, error) {
+	return compileRegex(in.Pattern, in.Flags)
+}
+
+func regexMatchTool() *Tool {
+	return regexParams(Build("regex_match").
+		Description("Check whether text matches a regular expression and return the first match").
+		Cacheable()).
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params regexInput
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			re, err := params.compile()
+			if err != nil {
+				return "", err
+			}
+			result := map[string]any{"matched": false}
+			if loc := re.FindStringIndex(params.Text); loc != nil {
+				result = map[string]any{"matched": true, "match": params.Text[loc[0]:loc[1]], "index": loc[0]}
+			}
+			out, err := json.Marshal(result)
+			return string(out), err
+		}).
+		Create()
+}
+
+// regexMatch is one match of regex_extract.
+type regexMatch struct {
+	Match  string             `json:"match"`
+	Index  int                `json:"index"`
+	Groups map[string]*string `json:"groups,omitempty"`
+}
+
+func regexExtractTool() *Tool {
+	return regexParams(Build("regex_extract").
+		Description("Find all matches of a regular expression, with their capture groups by number and name").
+		Cacheable()).
+		OptionalParam("limit", "integer", fmt.Sprintf("Maximum number of matches (default %d)", defaultMatchLimit)).
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				regexInput
+				Limit int `json:"limit"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			re, err := params.compile()
+			if err != nil {
+				return "", err
+			}
+			limit := params.Limit
+			if limit <= 0 {
+				limit = defaultMatchLimit
+			}
+
+			names := re.SubexpNames()
+			matches := []regexMatch{}
+			for _, loc := range re.FindAllStringSubmatchIndex(params.Text, limit) {
+				m := regexMatch{Match: params.Text[loc[0]:loc[1]], Index: loc[0]}
+				if len(names) > 1 {
+					m.Groups = make(map[string]*string, len(names)-1)
+				}
+				for g := 1; g < len(names); g++ {
+					key := names[g]
+					if key == "" {
+						key = strconv.Itoa(g)
+					}
+					var value *string // null for a group that did not take part
+					if loc[2*g] >= 0 {
+						s := params.Text[loc[2*g]:loc[2*g+1]]
+						value = &s
+					}
+					m.Groups[key] = value
+				}
+				matches = append(matches, m)
+			}
+			out, err := json.Marshal(map[string]any{"count": len(matches), "matches": matches})
+			return string(out), err
+		}).
+		Create()
+}
+
+func regexReplaceTool() *Tool {
+	return regexParams(Build("regex_replace").
+		Description("Replace every match of a regular expression. The replacement may use $1 or ${name} for groups").
+		Cacheable()).
+		Param("replacement", "string", "Replacement text; $1 or ${name} insert a group, $$ a literal $").
+		OptionalParam("literal", "boolean", "Insert the replacement as-is, without expanding $").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				regexInput
+				Replacement string `json:"replacement"`
+				Literal     bool   `json:"literal"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			re, err := params.compile()
+			if err != nil {
+				return "", err
+			}
+			if params.Literal {
+				return re.ReplaceAllLiteralString(params.Text, params.Replacement), nil
+			}
+			return re.ReplaceAllString(params.Text, params.Replacement), nil
+		}).
+		Create()
+}
+
+func regexSplitTool() *Tool {
+	return regexParams(Build("regex_split").
+		Description("Split text around the matches of a regular expression. Returns a JSON array").
+		Cacheable()).
+		OptionalParam("limit", "integer", "Maximum number of parts; the last part holds the rest (default all)").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			var params struct {
+				regexInput
+				Limit int `json:"limit"`
+			}
+			if err := json.Unmarshal([]byte(input), &params); err != nil {
+				return "", err
+			}
+			re, err := params.compile()
+			if err != nil {
+				return "", err
+			}
+			limit := params.Limit
+			if limit <= 0 {
+				limit = -1
+			}
+			out, err := json.Marshal(re.Split(params.Text, limit))
+			return string(out), err
+		}).
+		Create()
+}
@@ -0,0 +1,57 @@
+package tools_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestRegexToolkit(t *testing.T) {
+	registry := tools.NewRegistry()
+	if err := tools.RegexToolkit().RegisterTo(registry); err != nil {
+		t.Fatal(err)
+	}
+	const text = "Order #12 shipped 2026-10-15; order #7 pending"
+	in := func(pattern, extra string) string {
+		return `{"pattern": ` + strconv.Quote(pattern) + `, "text": ` + strconv.Quote(text) + extra + `}`
+	}
+	tests := []struct {
+		tool, input string
+		want        string
+		wantErr     string
+	}{
+		{tool: "regex_match", input: in(`#\d+`, ""), want: `{"index":6,"match":"#12","matched":true}`},
+		{tool: "regex_match", input: in(`^order`, ""), want: `{"matched":false}`},
+		{tool: "regex_match", input: in(`^order`, `, "flags": "i"`), want: `{"index":0,"match":"Order","matched":true}`},
+		{tool: "regex_extract", input: in(`#(\d+) (?P<status>\w+)`, ""),
+			want: `{"count":2,"matches":[{"match":"#12 shipped","index":6,"groups":{"1":"12","status":"shipped"}},{"match":"#7 pending","index":36,"groups":{"1":"7","status":"pending"}}]}`},
+		{tool: "regex_extract", input: in(`#\d+( shipped)?`, `, "limit": 5`),
+			want: `{"count":2,"matches":[{"match":"#12 shipped","index":6,"groups":{"1":" shipped"}},{"match":"#7","index":36,"groups":{"1":null}}]}`},
+		{tool: "regex_extract", input: in(`\d{4}-\d{2}-\d{2}`, ""), want: `{"count":1,"matches":[{"match":"2026-10-15","index":18}]}`},
+		{tool: "regex_extract", input: in(`\d+`, `, "limit": 1`), want: `{"count":1,"matches":[{"match":"12","index":7}]}`},
+		{tool: "regex_extract", input: in(`refund`, ""), want: `{"count":0,"matches":[]}`},
+		{tool: "regex_replace", input: in(`#(?P<id>\d+)`, `, "replacement": "[${id}]"`), want: "Order [12] shipped 2026-10-15; order [7] pending"},
+		{tool: "regex_replace", input: in(`#\d+`, `, "replacement": "$1", "literal": true`), want: "Order $1 shipped 2026-10-15; order $1 pending"},
+		{tool: "regex_split", input: in(`;\s*`, ""), want: `["Order #12 shipped 2026-10-15","order #7 pending"]`},
+		{tool: "regex_split", input: in(`\s+`, `, "limit": 2`), want: `["Order","#12 shipped 2026-10-15; order #7 pending"]`},
+		{tool: "regex_match", input: in(`(\w+) \1`, ""), wantErr: "RE2 syntax"},
+		{tool: "regex_match", input: in(`x`, `, "flags": "g"`), wantErr: "invalid flags"},
+		{tool: "regex_match", input: in(strings.Repeat("a", 1001), ""), wantErr: "the limit is 1000"},
+	}
+	for _, tt := range tests {
+		out, err := registry.Execute(context.Background(), tt.tool, tt.input)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s %s: expected error containing %q, got %v (%s)", tt.tool, tt.input, tt.wantErr, err, out)
+			}
+		case err != nil:
+			t.Errorf("%s %s: %v", tt.tool, tt.input, err)
+		case out != tt.want:
+			t.Errorf("%s %s:\nexpected %s\ngot      %s", tt.tool, tt.input, tt.want, out)
+		}
+	}
+}
@@ -6,7 +6,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 )
@@ -23,8 +27,21 @@ type ShellConfig struct {
 	Timeout time.Duration
 	// MaxOutputSize limits output size in bytes.
 	MaxOutputSize int
+	// AllowShell runs commands that use shell syntax, such as pipes,
+	// redirects and $(...), through sh -c. The allow and block lists still
+	// apply to every command in the line, but variables and eval can hide a
+	// command from them. Without it such commands are rejected and the rest
+	// run directly, without a shell.
+	AllowShell bool
+	// SensitiveEnvNames lists substrings of environment variable names that
+	// get_env refuses to read, matched case-insensitively. Nil uses
+	// DefaultSensitiveEnvNames.
+	SensitiveEnvNames []string
 }
 
+// DefaultSensitiveEnvNames are the name fragments get_env blocks by default.
+var DefaultSensitiveEnvNames = []string{"PASSWORD", "SECRET", "TOKEN", "KEY", "CREDENTIAL"}
+
 // DefaultShellConfig returns safe defaults.
 func DefaultShellConfig() ShellConfig {
 	return ShellConfig{
@@ -42,14 +59,23 @@ func ShellToolkit(config ShellConfig) *Toolkit {
 		Tools: []*Tool{
 			runCommandTool(config),
 			whichTool(),
-			envTool(),
+			envTool(config),
+			listEnvTool(),
 		},
 	}
 }
 
+func runCommandDescription(config ShellConfig) string {
+	if config.AllowShell {
+		return "Execute a shell command and return its output"
+	}
+	return "Execute a command and return its output. It runs without a shell, so pipes, redirects and variables are not available"
+}
+
 func runCommandTool(config ShellConfig) *Tool {
 	return Build("run_command").
-		Description("Execute a shell command and return its output").
+		Description(runCommandDescription(config)).
+		RequiresConfirmation().
 		Param("command", "string", "The command to execute").
 		OptionalParam("args", "array", "Command arguments").
 		OptionalParam("working_dir", "string", "Working directory").
@@ -64,26 +90,9 @@ func runCommandTool(config ShellConfig) *Tool {
 				params.Command = input
 			}
 
-			// Security: Check blocked commands
-			cmdName := strings.Split(params.Command, " ")[0]
-			for _, blocked := range config.BlockedCommands {
-				if cmdName == blocked {
-					return "", fmt.Errorf("command '%s' is blocked for security reasons", cmdName)
-				}
-			}
-
-			// Security: Check allowed commands
-			if len(config.AllowedCommands) > 0 {
-				allowed := false
-				for _, cmd := range config.AllowedCommands {
-					if cmdName == cmd {
-						allowed = true
-						break
-					}
-				}
-				if !allowed {
-					return "", fmt.Errorf("command '%s' is not in the allowed list", cmdName)
-				}
+			argv, err := config.parseCommand(params.Command, params.Args)
+			if err != nil {
+				return "", err
 			}
 
 			// Set timeout
@@ -91,21 +100,12 @@ func runCommandTool(config ShellConfig) *Tool {
 			if timeout == 0 {
 				timeout = 30 * time.Second
 			}
-			ctx, cancel := context.WithTimeout(ctx, timeout)
+			runCtx, cancel := context.WithTimeout(ctx, timeout)
 			defer cancel()
 
-			// Build command
-			var cmd *exec.Cmd
-			if len(params.Args) > 0 {
-				cmd = exec.CommandContext(ctx, params.Command, params.Args...)
-			} else {
-				// Parse command string
-				parts := strings.Fields(params.Command)
-				if len(parts) == 0 {
-					return "", fmt.Errorf("empty command")
-				}
-				cmd = exec.CommandContext(ctx, parts[0], parts[1:]...)
-			}
+			cmd := exec.CommandContext(runCtx, argv[0], argv[1:]...)
+			// Don't wait for orphaned children holding the pipes after a kill
+			cmd.WaitDelay = time.Second
 
 			// Set working directory
 			if params.WorkingDir != "" {
@@ -115,18 +115,32 @@ func runCommandTool(config ShellConfig) *Tool {
 			}
 
 			// Capture output
-			var stdout, stderr bytes.Buffer
-			cmd.Stdout = &stdout
-			cmd.Stderr = &stderr
+			stdout := &cappedBuffer{max: config.MaxOutputSize}
+			stderr := &cappedBuffer{max: config.MaxOutputSize}
+			cmd.Stdout = stdout
+			cmd.Stderr = stderr
 
-			err := cmd.Run()
+			err = cmd.Run()
 
 			// Build result
 			result := map[string]any{
-				"stdout":    limitString(stdout.String(), config.MaxOutputSize),
-				"stderr":    limitString(stderr.String(), config.MaxOutputSize),
+				"stdout":    stdout.buf.String(),
+				"stderr":    stderr.buf.String(),
 				"exit_code": 0,
 			}
+			if stdout.truncated() || stderr.truncated() {
+				result["truncated"] = true
+				result["stdout_bytes"] = stdout.total
+				result["stderr_bytes"] = stderr.total
+			}
+
+			// Run cancelled: report what the command printed so far
+			if Canceled(ctx) {
+				result["exit_code"] = -1
+				out, _ := json.MarshalIndent(result, "", "  ")
+				PartialResult(ctx, string(out))
+				return "", ctx.Err()
+			}
 
 			if err != nil {
 				if exitErr, ok := err.(*exec.ExitError); ok {
@@ -142,11 +156,161 @@ func runCommandTool(config ShellConfig) *Tool {
 		Create()
 }
 
-func limitString(s string, maxLen int) string {
-	if maxLen <= 0 || len(s) <= maxLen {
-		return s
+// cappedBuffer keeps the first max bytes written to it and counts the
+// rest. max <= 0 keeps everything.
+type cappedBuffer struct {
+	buf   bytes.Buffer
+	max   int
+	total int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	if b.max <= 0 {
+		return b.buf.Write(p)
+	}
+	if room := b.max - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) truncated() bool {
+	return b.max > 0 && b.total > b.max
+}
+
+// maxWrapperDepth bounds nested wrappers such as env sh -c "xargs ...".
+const maxWrapperDepth = 8
+
+// parseCommand splits command into the argv to run and checks it against
+// the allow and block lists. Commands with shell syntax run through sh -c
+// when AllowShell is set.
+func (c ShellConfig) parseCommand(command string, args []string) ([]string, error) {
+	line, err := parseShell(command)
+	if err != nil {
+		return nil, err
+	}
+	if line.syntax {
+		if !c.AllowShell {
+			return nil, errShellSyntax
+		}
+		if len(args) > 0 {
+			return nil, fmt.Errorf("args cannot be combined with shell syntax in the command")
+		}
+		if err := c.checkScript(command, 0); err != nil {
+			return nil, err
+		}
+		return []string{"sh", "-c", command}, nil
+	}
+
+	argv := append(line.words(), args...)
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return argv, c.checkArgv(argv, 0)
+}
+
+// checkScript checks every command of a shell script, including those in
+// command substitutions.
+func (c ShellConfig) checkScript(script string, depth int) error {
+	if depth > maxWrapperDepth {
+		return fmt.Errorf("command is nested too deeply")
+	}
+	line, err := parseShell(script)
+	if err != nil {
+		return err
+	}
+	if line.syntax && !c.AllowShell {
+		return errShellSyntax
+	}
+	for _, argv := range line.commands() {
+		if err := c.checkArgv(argv, depth); err != nil {
+			return err
+		}
+	}
+	for _, sub := range line.substitutions {
+		if err := c.checkScript(sub, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkArgv applies the allow and block lists to the executable of argv
+// and, for wrappers such as env, xargs and sh -c, to the command they run.
+func (c ShellConfig) checkArgv(argv []string, depth int) error {
+	if depth > maxWrapperDepth {
+		return fmt.Errorf("command is nested too deeply")
+	}
+	cmdName := argv[0]
+	if strings.ContainsAny(cmdName, "$`") {
+		return fmt.Errorf("command name '%s' must be literal, not computed", cmdName)
+	}
+
+	// Security: Check blocked commands, by name and by resolved executable
+	names := commandNames(cmdName)
+	for _, name := range names {
+		if slices.Contains(c.BlockedCommands, name) {
+			return fmt.Errorf("command '%s' is blocked for security reasons", cmdName)
+		}
+	}
+
+	// Security: Check allowed commands
+	if len(c.AllowedCommands) > 0 && !slices.ContainsFunc(names, func(name string) bool {
+		return slices.Contains(c.AllowedCommands, name)
+	}) {
+		return fmt.Errorf("command '%s' is not in the allowed list", cmdName)
+	}
+
+	// Check the command a wrapper runs
+	for _, name := range names {
+		switch {
+		case name == "eval":
+			return c.checkScript(strings.Join(argv[1:], " "), depth+1)
+		case isShell(name):
+			scripts, err := shellScripts(name, argv)
+			if err != nil {
+				return err
+			}
+			for _, script := range scripts {
+				if err := c.checkScript(script, depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		case shellWrappers[name] != nil:
+			wrapped, err := shellWrappers[name](argv)
+			if err != nil {
+				return err
+			}
+			if len(wrapped) > 0 {
+				return c.checkArgv(wrapped, depth+1)
+			}
+			return nil
+		}
 	}
-	return s[:maxLen] + "\n... (output truncated)"
+	return nil
+}
+
+// commandNames returns the names a command goes by: as given, its base
+// name, and the path and base name of the executable it resolves to.
+func commandNames(cmdName string) []string {
+	names := []string{cmdName}
+	add := func(name string) {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	add(filepath.Base(cmdName))
+	if path, err := exec.LookPath(cmdName); err == nil {
+		add(path)
+		add(filepath.Base(path))
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			add(real)
+			add(filepath.Base(real))
+		}
+	}
+	return names
 }
 
 func whichTool() *Tool {
@@ -171,9 +335,13 @@ func whichTool() *Tool {
 		Create()
 }
 
-func envTool() *Tool {
+func envTool(config ShellConfig) *Tool {
+	sensitive := config.SensitiveEnvNames
+	if sensitive == nil {
+		sensitive = DefaultSensitiveEnvNames
+	}
 	return Build("get_env").
-		Description("Get environment variable value").
+		Description("Get an environment variable's value. The result says whether it is set, since a variable can be set but empty").
 		Param("name", "string", "Environment variable name").
 		Handler(func(ctx context.Context, input string) (string, error) {
 			var params struct {
@@ -184,21 +352,49 @@ func envTool() *Tool {
 			}
 
 			// Security: Block sensitive env vars
-			blocked := []string{"PASSWORD", "SECRET", "TOKEN", "KEY", "CREDENTIAL"}
 			upper := strings.ToUpper(params.Name)
-			for _, b := range blocked {
-				if strings.Contains(upper, b) {
+			for _, b := range sensitive {
+				if b != "" && strings.Contains(upper, strings.ToUpper(b)) {
 					return "", fmt.Errorf("access to sensitive environment variable '%s' is blocked", params.Name)
 				}
 			}
 
-			cmd := exec.Command("printenv", params.Name)
-			out, err := cmd.Output()
-			if err != nil {
-				return "", fmt.Errorf("environment variable not set: %s", params.Name)
+			result := map[string]any{"name": params.Name, "set": false}
+			if value, ok := os.LookupEnv(params.Name); ok {
+				result["set"] = true
+				result["value"] = value
 			}
+			out, _ := json.Marshal(result)
+			return string(out), nil
+		}).
+		Create()
+}
 
-			return strings.TrimSpace(string(out)), nil
+func listEnvTool() *Tool {
+	return Build("list_env").
+		Description("List the names of the environment variables that are set. Values are not shown; read one with get_env").
+		OptionalParam("prefix", "string", "Only list names starting with this prefix").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			// With a single parameter the builder passes the prefix itself,
+			// or the raw JSON when it is missing.
+			prefix := strings.TrimSpace(input)
+			var params struct {
+				Prefix string `json:"prefix"`
+			}
+			if json.Unmarshal([]byte(input), &params) == nil {
+				prefix = params.Prefix
+			}
+
+			names := []string{}
+			for _, kv := range os.Environ() {
+				name, _, _ := strings.Cut(kv, "=")
+				if name != "" && strings.HasPrefix(name, prefix) {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			out, _ := json.Marshal(map[string]any{"count": len(names), "names": names})
+			return string(out), nil
 		}).
 		Create()
 }
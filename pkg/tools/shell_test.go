@@ -0,0 +1,227 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func shellRegistry(t *testing.T, config tools.ShellConfig) *tools.Registry {
+	t.Helper()
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("requires /bin/sh")
+	}
+	registry := tools.NewRegistry()
+	tools.ShellToolkit(config).RegisterTo(registry)
+	return registry
+}
+
+func runCommand(registry *tools.Registry, params map[string]any) (map[string]any, error) {
+	input, _ := json.Marshal(params)
+	out, err := registry.Execute(context.Background(), "run_command", string(input))
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]any
+	err = json.Unmarshal([]byte(out), &result)
+	return result, err
+}
+
+func TestRunCommand_Blocking(t *testing.T) {
+	config := tools.DefaultShellConfig()
+	config.AllowShell = true
+	registry := shellRegistry(t, config)
+
+	// A symlink to rm under another name still resolves to rm.
+	rm, err := exec.LookPath("rm")
+	if err != nil {
+		t.Skip("requires rm")
+	}
+	alias := filepath.Join(t.TempDir(), "tidy")
+	if err := os.Symlink(rm, alias); err != nil {
+		t.Skip("requires symlinks")
+	}
+
+	blocked := []string{
+		"rm -rf /tmp/x",
+		rm + " -rf /tmp/x",
+		alias + " /tmp/x",
+		`bash -c "rm -rf /tmp/x"`,
+		`sh -ec 'echo hi && rm -rf /tmp/x'`,
+		"env rm -rf /tmp/x",
+		"env -i FOO=bar rm -rf /tmp/x",
+		"xargs -n 1 rm",
+		"timeout 5 nice -n 10 rm /tmp/x",
+		"echo hi | xargs rm",
+		"echo $(rm -rf /tmp/x)",
+		"echo `sudo ls`",
+		`eval "rm -rf /tmp/x"`,
+		`sh -c "sh -c 'env rm /tmp/x'"`,
+		"FOO=1 rm /tmp/x",
+		"$(echo rm) /tmp/x",
+	}
+	for _, command := range blocked {
+		if _, err := runCommand(registry, map[string]any{"command": command}); err == nil || !strings.Contains(err.Error(), "blocked") && !strings.Contains(err.Error(), "literal") {
+			t.Errorf("%s: expected the command to be blocked, got %v", command, err)
+		}
+	}
+
+	// Wrappers whose options hide the command, and options the parser does
+	// not know, are refused too.
+	for _, command := range []string{
+		"env -S 'rm -rf /tmp/x'",
+		"env -iS'rm -rf /tmp/x'",
+		"env --split-string=rm -f /tmp/x",
+		"env --split-string 'FOO=1 rm -f /tmp/x'",
+		"env -S '-i sh -c \"rm -rf /tmp/x\"'",
+		"bash -o posix -c 'rm -rf /tmp/x'",
+		"bash -O extglob -c 'rm -rf /tmp/x'",
+		"bash +o posix -c 'rm -rf /tmp/x'",
+		"bash -e -c 'rm -rf /tmp/x'",
+		"bash --rcfile /dev/null -c 'rm -rf /tmp/x'",
+		"fish --command='rm -rf /tmp/x'",
+		"fish -C 'rm -rf /tmp/x'",
+		"xargs --max-args 1 rm",
+		"timeout -s KILL 5 rm /tmp/x",
+	} {
+		if _, err := runCommand(registry, map[string]any{"command": command}); err == nil || !strings.Contains(err.Error(), "blocked") {
+			t.Errorf("%s: expected the command to be blocked, got %v", command, err)
+		}
+	}
+	for _, command := range []string{
+		"bash --frobnicate 'rm -rf /tmp/x'",
+		"env --frobnicate rm -rf /tmp/x",
+		"xargs -Z rm",
+	} {
+		if _, err := runCommand(registry, map[string]any{"command": command}); err == nil || !strings.Contains(err.Error(), "unknown option") {
+			t.Errorf("%s: expected an unknown option to be refused, got %v", command, err)
+		}
+	}
+
+	result, err := runCommand(registry, map[string]any{"command": "echo hello | tr a-z A-Z"})
+	if err != nil || result["stdout"] != "HELLO\n" {
+		t.Errorf("Expected a pipeline to run with AllowShell, got %v, %v", result, err)
+	}
+}
+
+func TestRunCommand_NoShell(t *testing.T) {
+	registry := shellRegistry(t, tools.DefaultShellConfig())
+
+	// Quoted arguments stay whole and metacharacters inside quotes are literal.
+	result, err := runCommand(registry, map[string]any{"command": `printf '%s|' "a b" 'c;d' e\ f '$HOME'`})
+	if err != nil || result["stdout"] != "a b|c;d|e f|$HOME|" {
+		t.Errorf("Unexpected output %v, %v", result, err)
+	}
+	result, err = runCommand(registry, map[string]any{"command": "printf", "args": []string{"%s", "$(id); x"}})
+	if err != nil || result["stdout"] != "$(id); x" {
+		t.Errorf("Expected args to be passed literally, got %v, %v", result, err)
+	}
+
+	for _, command := range []string{
+		"echo hi | cat",
+		"echo hi > /tmp/out",
+		"echo $HOME",
+		"echo $(id)",
+		"echo a; echo b",
+		"true && echo b",
+		`sh -c "echo a; echo b"`,
+	} {
+		if _, err := runCommand(registry, map[string]any{"command": command}); err == nil || !strings.Contains(err.Error(), "shell syntax") {
+			t.Errorf("%s: expected shell syntax to be rejected, got %v", command, err)
+		}
+	}
+	if _, err := runCommand(registry, map[string]any{"command": `echo "unterminated`}); err == nil || !strings.Contains(err.Error(), "unterminated") {
+		t.Errorf("Expected an unterminated quote error, got %v", err)
+	}
+	if _, err := runCommand(registry, map[string]any{"command": "sh -c 'echo ok'"}); err != nil {
+		t.Errorf("Expected a simple sh -c script to run, got %v", err)
+	}
+}
+
+func TestRunCommand_AllowedCommands(t *testing.T) {
+	registry := shellRegistry(t, tools.ShellConfig{AllowedCommands: []string{"echo"}, AllowShell: true})
+
+	if _, err := runCommand(registry, map[string]any{"command": "echo ok"}); err != nil {
+		t.Errorf("Expected echo to be allowed, got %v", err)
+	}
+	for _, command := range []string{"ls", "env echo ok", "echo ok | cat", "echo $(ls)"} {
+		if _, err := runCommand(registry, map[string]any{"command": command}); err == nil || !strings.Contains(err.Error(), "not in the allowed list") {
+			t.Errorf("%s: expected a command outside the allowed list to be refused, got %v", command, err)
+		}
+	}
+}
+
+func TestRunCommand_Truncation(t *testing.T) {
+	registry := shellRegistry(t, tools.ShellConfig{MaxOutputSize: 10})
+
+	result, err := runCommand(registry, map[string]any{"command": "printf", "args": []string{"0123456789abcdef"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["stdout"] != "0123456789" || result["truncated"] != true || result["stdout_bytes"] != float64(16) {
+		t.Errorf("Expected truncated output, got %v", result)
+	}
+
+	result, err = runCommand(registry, map[string]any{"command": "printf short"})
+	if err != nil || result["stdout"] != "short" || result["truncated"] != nil {
+		t.Errorf("Expected complete output, got %v, %v", result, err)
+	}
+}
+
+func TestEnvTools(t *testing.T) {
+	t.Setenv("GOFLOW_TEST_SET", "value")
+	t.Setenv("GOFLOW_TEST_EMPTY", "")
+	t.Setenv("GOFLOW_TEST_API_KEY", "hunter2")
+	t.Setenv("GOFLOW_TEST_PRIVATE", "hidden")
+	os.Unsetenv("GOFLOW_TEST_UNSET")
+
+	registry := tools.NewRegistry()
+	tools.ShellToolkit(tools.DefaultShellConfig()).RegisterTo(registry)
+	ctx := context.Background()
+
+	tests := []struct {
+		name, want, wantErr string
+	}{
+		{name: "GOFLOW_TEST_SET", want: `{"name":"GOFLOW_TEST_SET","set":true,"value":"value"}`},
+		{name: "GOFLOW_TEST_EMPTY", want: `{"name":"GOFLOW_TEST_EMPTY","set":true,"value":""}`},
+		{name: "GOFLOW_TEST_UNSET", want: `{"name":"GOFLOW_TEST_UNSET","set":false}`},
+		{name: "goflow_test_api_key", wantErr: "sensitive"},
+	}
+	for _, tt := range tests {
+		out, err := registry.Execute(ctx, "get_env", `{"name": "`+tt.name+`"}`)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: expected error %q, got %v", tt.name, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil || out != tt.want {
+			t.Errorf("%s: got %s (%v), want %s", tt.name, out, err, tt.want)
+		}
+	}
+
+	out, err := registry.Execute(ctx, "list_env", `{"prefix": "GOFLOW_TEST_"}`)
+	want := `{"count":4,"names":["GOFLOW_TEST_API_KEY","GOFLOW_TEST_EMPTY","GOFLOW_TEST_PRIVATE","GOFLOW_TEST_SET"]}`
+	if err != nil || out != want {
+		t.Errorf("list_env: got %s (%v), want %s", out, err, want)
+	}
+	if strings.Contains(out, "hunter2") {
+		t.Error("list_env leaked a value")
+	}
+
+	// A custom blocklist replaces the default one.
+	registry = tools.NewRegistry()
+	tools.ShellToolkit(tools.ShellConfig{SensitiveEnvNames: []string{"private"}}).RegisterTo(registry)
+	if _, err := registry.Execute(ctx, "get_env", `{"name": "GOFLOW_TEST_PRIVATE"}`); err == nil {
+		t.Error("Expected the custom blocklist to apply")
+	}
+	if out, err := registry.Execute(ctx, "get_env", `{"name": "GOFLOW_TEST_API_KEY"}`); err != nil || !strings.Contains(out, "hunter2") {
+		t.Errorf("Expected the default blocklist to be replaced, got %s, %v", out, err)
+	}
+}
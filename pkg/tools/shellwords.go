@@ -0,0 +1,437 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// shellToken is a word or an operator of a shell command line.
+type shellToken struct {
+	text string
+	op   bool // |, &&, ;, >, ( and the like
+}
+
+// shellLine is a tokenized command line.
+type shellLine struct {
+	tokens []shellToken
+	// substitutions holds the commands inside $(...) and backquotes.
+	substitutions []string
+	// syntax is set when the line needs a shell: operators, redirects,
+	// substitutions, variables, assignments or comments.
+	syntax bool
+}
+
+// words returns the words of a line without shell syntax.
+func (l *shellLine) words() []string {
+	words := make([]string, len(l.tokens))
+	for i, tok := range l.tokens {
+		words[i] = tok.text
+	}
+	return words
+}
+
+// commands splits the line into simple commands at |, &&, ;, & and
+// parentheses, dropping redirects and leading VAR=value assignments.
+func (l *shellLine) commands() [][]string {
+	var cmds [][]string
+	var cur []string
+	for i := 0; i < len(l.tokens); i++ {
+		tok := l.tokens[i]
+		switch {
+		case !tok.op:
+			if len(cur) == 0 && isAssignment(tok.text) {
+				continue
+			}
+			cur = append(cur, tok.text)
+		case strings.ContainsAny(tok.text, "<>"):
+			i++ // skip the redirect target
+		default:
+			if len(cur) > 0 {
+				cmds = append(cmds, cur)
+			}
+			cur = nil
+		}
+	}
+	if len(cur) > 0 {
+		cmds = append(cmds, cur)
+	}
+	return cmds
+}
+
+// isAssignment reports whether word is a VAR=value prefix.
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && (i == 0 || !(r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// shellOperators lists the operators, longest first.
+var shellOperators = []string{"&&", "||", ";;", ">>", "<<", ">&", "<&", ">|", "&>", "|", "&", ";", "<", ">", "(", ")"}
+
+// parseShell splits s into words like a POSIX shell: quotes group words,
+// backslashes escape, and operators end words. Nothing is expanded.
+func parseShell(s string) (*shellLine, error) {
+	line := &shellLine{}
+	var word strings.Builder
+	inWord := false
+	flush := func() {
+		if inWord {
+			if isAssignment(word.String()) && (len(line.tokens) == 0 || line.tokens[len(line.tokens)-1].op) {
+				line.syntax = true // VAR=value before a command
+			}
+			line.tokens = append(line.tokens, shellToken{text: word.String()})
+		}
+		word.Reset()
+		inWord = false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			flush()
+		case c == '\n':
+			flush()
+			line.tokens = append(line.tokens, shellToken{text: ";", op: true})
+			line.syntax = true
+		case c == '\\':
+			if i+1 < len(s) {
+				i++
+				if s[i] != '\n' { // backslash-newline continues the line
+					word.WriteByte(s[i])
+				}
+			}
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated ' in command")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			j, err := line.doubleQuoted(s, i+1, &word)
+			if err != nil {
+				return nil, err
+			}
+			i = j
+			inWord = true
+		case c == '$' || c == '`':
+			j, err := line.substitution(s, i, &word)
+			if err != nil {
+				return nil, err
+			}
+			i = j
+			inWord = true
+		case c == '#' && !inWord:
+			line.syntax = true
+			end := strings.IndexByte(s[i:], '\n')
+			if end < 0 {
+				return line, nil
+			}
+			i += end - 1
+		case strings.IndexByte("|&;<>()", c) >= 0:
+			if (c == '<' || c == '>') && inWord && isDigits(word.String()) {
+				word.Reset() // a file descriptor, as in 2>&1
+				inWord = false
+			}
+			flush()
+			for _, op := range shellOperators {
+				if strings.HasPrefix(s[i:], op) {
+					line.tokens = append(line.tokens, shellToken{text: op, op: true})
+					i += len(op) - 1
+					break
+				}
+			}
+			line.syntax = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	flush()
+	return line, nil
+}
+
+// doubleQuoted reads a "..." string starting after the quote at s[start-1]
+// into word and returns the index of the closing quote.
+func (l *shellLine) doubleQuoted(s string, start int, word *strings.Builder) (int, error) {
+	for i := start; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return i, nil
+		case '\\':
+			if i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+				i++
+			}
+			word.WriteByte(s[i])
+		case '$', '`':
+			j, err := l.substitution(s, i, word)
+			if err != nil {
+				return 0, err
+			}
+			i = j
+		default:
+			word.WriteByte(c)
+		}
+	}
+	return 0, errors.New("unterminated \" in command")
+}
+
+// substitution reads the $ or ` at s[i], recording command substitutions,
+// and returns the index of its last character.
+func (l *shellLine) substitution(s string, i int, word *strings.Builder) (int, error) {
+	switch {
+	case s[i] == '`':
+		end := strings.IndexByte(s[i+1:], '`')
+		if end < 0 {
+			return 0, errors.New("unterminated ` in command")
+		}
+		l.substitutions = append(l.substitutions, s[i+1:i+1+end])
+		l.syntax = true
+		word.WriteString(s[i : i+end+2])
+		return i + end + 1, nil
+	case strings.HasPrefix(s[i:], "$("):
+		depth := 0
+		for j := i + 1; j < len(s); j++ {
+			switch s[j] {
+			case '(':
+				depth++
+			case ')':
+				if depth--; depth == 0 {
+					l.substitutions = append(l.substitutions, s[i+2:j])
+					l.syntax = true
+					word.WriteString(s[i : j+1])
+					return j, nil
+				}
+			}
+		}
+		return 0, errors.New("unterminated $( in command")
+	case i+1 < len(s) && (s[i+1] == '{' || s[i+1] == '_' || isAlnum(s[i+1]) || strings.IndexByte("?@*#!$-", s[i+1]) >= 0):
+		l.syntax = true // a variable
+	}
+	word.WriteByte('$')
+	return i, nil
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// shellOption is an option of a wrapper or shell invocation.
+type shellOption struct {
+	name  string // the letter or long name, without dashes
+	value string
+}
+
+// optionSpec lists the options a command accepts. Options outside the spec
+// are refused rather than guessed at, since an unknown option might take
+// the wrapped command as its value.
+type optionSpec struct {
+	flags      string   // short options without a value
+	valued     string   // short options taking a value
+	long       []string // long options without a value, or with an attached =value
+	longValued []string // long options taking a value
+	plus       bool     // +x options are accepted as well, as in shells
+	stop       []string // options after which parsing stops, as env -S
+}
+
+// parse splits args[1:] into options and operands. It returns the options
+// and the index of the first operand, which is len(args) when there is none.
+// After a stop option the index is that of the argument following it.
+func (s optionSpec) parse(args []string) ([]shellOption, int, error) {
+	var opts []shellOption
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return opts, i + 1, nil
+		case len(arg) < 2 || arg[0] != '-' && !(s.plus && arg[0] == '+'):
+			return opts, i, nil
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			switch {
+			case slices.Contains(s.longValued, name):
+				if !hasValue {
+					if i+1 >= len(args) {
+						return nil, 0, fmt.Errorf("option %s of %s needs a value", arg, args[0])
+					}
+					i++
+					value = args[i]
+				}
+			case !slices.Contains(s.long, name):
+				return nil, 0, fmt.Errorf("unknown option %s of %s", arg, args[0])
+			}
+			opts = append(opts, shellOption{name: name, value: value})
+			if slices.Contains(s.stop, name) {
+				return opts, i + 1, nil
+			}
+		default:
+			for j := 1; j < len(arg); j++ {
+				name := arg[j : j+1]
+				switch {
+				case strings.Contains(s.valued, name):
+					value := arg[j+1:]
+					if value == "" {
+						if i+1 >= len(args) {
+							return nil, 0, fmt.Errorf("option -%s of %s needs a value", name, args[0])
+						}
+						i++
+						value = args[i]
+					}
+					opts = append(opts, shellOption{name: name, value: value})
+					if slices.Contains(s.stop, name) {
+						return opts, i + 1, nil
+					}
+					j = len(arg)
+				case strings.Contains(s.flags, name):
+					opts = append(opts, shellOption{name: name})
+				default:
+					return nil, 0, fmt.Errorf("unknown option -%s of %s", name, args[0])
+				}
+			}
+		}
+	}
+	return opts, len(args), nil
+}
+
+// wrapped returns the operands of a wrapper, which form the command it runs.
+func (s optionSpec) wrapped(args []string) ([]string, error) {
+	_, i, err := s.parse(args)
+	if err != nil {
+		return nil, err
+	}
+	return args[i:], nil
+}
+
+// shellWrappers run the command given in their arguments. Each returns the
+// argv of that command, or nil if there is none.
+var shellWrappers = map[string]func(args []string) ([]string, error){
+	"env": envCommand,
+	"xargs": optionSpec{
+		flags:      "0oprtxeil",
+		valued:     "EIJLPRSadns",
+		long:       []string{"null", "open-tty", "interactive", "no-run-if-empty", "verbose", "exit", "show-limits", "eof", "replace", "max-lines"},
+		longValued: []string{"arg-file", "delimiter", "max-args", "max-procs", "max-chars", "process-slot-var"},
+	}.wrapped,
+	"nice":    optionSpec{flags: "0123456789", valued: "n", longValued: []string{"adjustment"}}.wrapped,
+	"nohup":   optionSpec{}.wrapped,
+	"time":    optionSpec{flags: "apqv", valued: "fo", long: []string{"append", "portability", "quiet", "verbose"}, longValued: []string{"format", "output"}}.wrapped,
+	"command": optionSpec{flags: "pvV"}.wrapped,
+	"exec":    optionSpec{flags: "cl", valued: "a"}.wrapped,
+	"builtin": optionSpec{}.wrapped,
+	"timeout": func(args []string) ([]string, error) {
+		argv, err := optionSpec{
+			flags:      "v",
+			valued:     "ks",
+			long:       []string{"foreground", "preserve-status", "verbose"},
+			longValued: []string{"kill-after", "signal"},
+		}.wrapped(args)
+		// The duration comes before the command.
+		if len(argv) < 2 {
+			return nil, err
+		}
+		return argv[1:], err
+	},
+}
+
+// envOptions are the options of env. -S splits its value into arguments.
+var envOptions = optionSpec{
+	flags:      "0iv",
+	valued:     "CSu",
+	long:       []string{"ignore-environment", "null", "debug", "block-signal", "default-signal", "ignore-signal", "list-signal-handling"},
+	longValued: []string{"chdir", "split-string", "unset"},
+	stop:       []string{"S", "split-string"},
+}
+
+// envCommand finds the command of env, after its options and assignments.
+// The value of -S is parsed as a command line whose words take the place of
+// the option, so env -S 'rm -rf x' runs rm.
+func envCommand(args []string) ([]string, error) {
+	opts, i, err := envOptions.parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(opts); n > 0 && slices.Contains(envOptions.stop, opts[n-1].name) {
+		line, err := parseShell(opts[n-1].value)
+		if err != nil {
+			return nil, fmt.Errorf("env -S: %w", err)
+		}
+		return envCommand(append(append([]string{args[0]}, line.words()...), args[i:]...))
+	}
+	for ; i < len(args); i++ {
+		if args[i] != "-" && !isAssignment(args[i]) {
+			return args[i:], nil
+		}
+	}
+	return nil, nil
+}
+
+// posixShell lists the options of sh, bash and their kin. -c makes the
+// first operand the script.
+var posixShell = optionSpec{
+	flags:      "abcefhiklmnprstuvxBCDEHPT",
+	valued:     "oO",
+	long:       []string{"debugger", "dump-po-strings", "dump-strings", "help", "login", "noediting", "noprofile", "norc", "posix", "protected", "restricted", "verbose", "version"},
+	longValued: []string{"init-file", "rcfile"},
+	plus:       true,
+}
+
+// fishShell lists the options of fish, whose -c and -C take the script as
+// their value.
+var fishShell = optionSpec{
+	flags:      "ehilnNPv",
+	valued:     "cCdfop",
+	long:       []string{"help", "interactive", "login", "no-config", "no-execute", "print-debug-categories", "print-rusage-self", "private", "version"},
+	longValued: []string{"command", "debug", "debug-output", "features", "init-command", "profile", "profile-startup"},
+}
+
+// shells are the interpreters whose scripts are checked.
+var shells = map[string]optionSpec{
+	"sh": posixShell, "bash": posixShell, "dash": posixShell, "zsh": posixShell, "ksh": posixShell, "ash": posixShell,
+	"fish": fishShell,
+}
+
+func isShell(name string) bool {
+	_, ok := shells[name]
+	return ok
+}
+
+// shellScripts returns the scripts a shell invocation runs from its
+// arguments, as opposed to from a file or standard input.
+func shellScripts(name string, args []string) ([]string, error) {
+	opts, i, err := shells[name].parse(args)
+	if err != nil {
+		return nil, err
+	}
+	var scripts []string
+	for _, opt := range opts {
+		switch {
+		case name == "fish" && (opt.name == "c" || opt.name == "C" || opt.name == "command" || opt.name == "init-command"):
+			scripts = append(scripts, opt.value)
+		case name != "fish" && opt.name == "c":
+			if i < len(args) {
+				scripts = append(scripts, args[i])
+			}
+		}
+	}
+	return scripts, nil
+}
+
+// errShellSyntax is returned for commands needing a shell when it is not
+// allowed.
+var errShellSyntax = errors.New("command uses shell syntax (pipes, redirects, ;, &&, $ or backquotes), which is disabled; run a single command")
@@ -0,0 +1,374 @@
+// Package tools provides SQL database tools for agents.
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLConfig configures the SQL tools.
+type SQLConfig struct {
+	// Dialect is "postgres", "mysql" or "sqlite". It picks the placeholder
+	// style shown to the model and how sql_schema reads the schema; other
+	// values use ? placeholders and information_schema.
+	Dialect string
+	// MaxRows caps the rows returned by sql_query.
+	MaxRows int
+	// Timeout limits each query.
+	Timeout time.Duration
+	// AllowWrites adds the sql_execute tool for INSERT, UPDATE, DELETE
+	// and DDL statements. It requires confirmation.
+	AllowWrites bool
+}
+
+// DefaultSQLConfig returns read-only defaults.
+func DefaultSQLConfig() SQLConfig {
+	return SQLConfig{
+		MaxRows: 100,
+		Timeout: 30 * time.Second,
+	}
+}
+
+// SQLToolkit returns tools to query db. sql_query runs only read-only
+// statements, inside a read-only transaction that is always rolled back.
+// Queries take parameters, which are never interpolated into the SQL.
+func SQLToolkit(db *sql.DB, config SQLConfig) *Toolkit {
+	if config.MaxRows <= 0 {
+		config.MaxRows = DefaultSQLConfig().MaxRows
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultSQLConfig().Timeout
+	}
+	tk := &Toolkit{
+		Name:        "sql",
+		Description: "Tools for querying a SQL database",
+		Tools: []*Tool{
+			sqlQueryTool(db, config),
+			sqlSchemaTool(db, config),
+		},
+	}
+	if config.AllowWrites {
+		tk.Tools = append(tk.Tools, sqlExecuteTool(db, config))
+	}
+	return tk
+}
+
+// readOnlyKeywords are the statements sql_query accepts.
+var readOnlyKeywords = map[string]bool{
+	"select": true, "with": true, "explain": true, "show": true,
+	"describe": true, "desc": true, "values": true, "table": true,
+}
+
+func (c SQLConfig) placeholders() string {
+	if c.Dialect == "postgres" {
+		return "$1, $2, ..."
+	}
+	return "?"
+}
+
+// sqlInput holds the parameters of sql_query and sql_execute.
+type sqlInput struct {
+	Query string `json:"query"`
+	Args  []any  `json:"params"`
+}
+
+// parseSQLInput decodes input, keeping whole numbers in params as
+// integers so they bind to integer columns.
+func parseSQLInput(input string) (sqlInput, error) {
+	var params sqlInput
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+	if err := dec.Decode(&params); err != nil {
+		return params, err
+	}
+	for i, arg := range params.Args {
+		if n, ok := arg.(json.Number); ok {
+			if v, err := n.Int64(); err == nil {
+				params.Args[i] = v
+			} else {
+				params.Args[i], _ = n.Float64()
+			}
+		}
+	}
+	return params, nil
+}
+
+func sqlParams(b *ToolBuilder, config SQLConfig) *ToolBuilder {
+	return b.
+		Param("query", "string", "A single SQL statement").
+		OptionalParam("params", "array", fmt.Sprintf("Values for the %s placeholders in the query, in order", config.placeholders()))
+}
+
+// statement returns the first keyword of query, lowercased. It fails if
+// query holds more than one statement. String literals, quoted identifiers
+// and comments are skipped.
+func statement(query string) (string, error) {
+	var stripped strings.Builder
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated %c in query", c)
+			}
+			// A doubled quote continues the literal and is skipped by
+			// the next pass of the loop.
+			i += end + 1
+			stripped.WriteByte(' ')
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			i += end
+			stripped.WriteByte(' ')
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return "", errors.New("unterminated comment in query")
+			}
+			i += end + 3
+			stripped.WriteByte(' ')
+		default:
+			stripped.WriteByte(c)
+		}
+	}
+
+	var statements []string
+	for _, s := range strings.Split(stripped.String(), ";") {
+		if s = strings.TrimSpace(s); s != "" {
+			statements = append(statements, s)
+		}
+	}
+	switch len(statements) {
+	case 0:
+		return "", errors.New("query is empty")
+	case 1:
+		fields := strings.Fields(strings.TrimLeft(statements[0], "( \t\r\n"))
+		return strings.ToLower(fields[0]), nil
+	}
+	return "", errors.New("run one statement at a time")
+}
+
+// timeoutError explains a query that ran out of time.
+func timeoutError(ctx context.Context, err error, timeout time.Duration) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("query timed out after %s", timeout)
+	}
+	return err
+}
+
+func sqlQueryTool(db *sql.DB, config SQLConfig) *Tool {
+	writes := "writes are disabled"
+	if config.AllowWrites {
+		writes = "use sql_execute for writes"
+	}
+	return sqlParams(Build("sql_query").
+		Description(fmt.Sprintf("Run a read-only SQL query (SELECT, WITH, EXPLAIN, SHOW) and return up to %d rows as JSON. Use %s placeholders with params for values", config.MaxRows, config.placeholders())), config).
+		Handler(func(ctx context.Context, input string) (string, error) {
+			params, err := parseSQLInput(input)
+			if err != nil {
+				return "", err
+			}
+			keyword, err := statement(params.Query)
+			if err != nil {
+				return "", err
+			}
+			if !readOnlyKeywords[keyword] {
+				return "", fmt.Errorf("%s statements are not allowed: sql_query is read-only; %s", strings.ToUpper(keyword), writes)
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+			defer cancel()
+			tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+			if err != nil {
+				// Some drivers have no read-only transactions; the
+				// rollback below still discards any change.
+				if tx, err = db.BeginTx(ctx, nil); err != nil {
+					return "", timeoutError(ctx, err, config.Timeout)
+				}
+			}
+			defer tx.Rollback()
+
+			rows, err := tx.QueryContext(ctx, params.Query, params.Args...)
+			if err != nil {
+				return "", timeoutError(ctx, err, config.Timeout)
+			}
+			defer rows.Close()
+			result, err := scanRows(rows, config.MaxRows)
+			if err != nil {
+				return "", timeoutError(ctx, err, config.Timeout)
+			}
+			out, err := json.Marshal(result)
+			return string(out), err
+		}).
+		Create()
+}
+
+// sqlRows is the JSON result of sql_query.
+type sqlRows struct {
+	Columns   []string         `json:"columns"`
+	Rows      []map[string]any `json:"rows"`
+	Count     int              `json:"count"`
+	Truncated bool             `json:"truncated,omitempty"`
+}
+
+// scanRows reads up to limit rows.
+func scanRows(rows *sql.Rows, limit int) (*sqlRows, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &sqlRows{Columns: columns, Rows: []map[string]any{}}
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if len(result.Rows) == limit {
+			result.Truncated = true
+			break
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(columns))
+		for i, col := range columns {
+			row[col] = sqlValue(values[i])
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	result.Count = len(result.Rows)
+	return result, rows.Err()
+}
+
+// sqlValue converts a scanned value to its JSON form.
+func sqlValue(v any) any {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// schemaQueries list table, column, type and nullability per dialect.
+var schemaQueries = map[string]string{
+	"postgres": `SELECT table_name, column_name, data_type, is_nullable = 'YES'
+		FROM information_schema.columns
+		WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
+		ORDER BY table_schema, table_name, ordinal_position`,
+	"mysql": `SELECT table_name, column_name, data_type, is_nullable = 'YES'
+		FROM information_schema.columns
+		WHERE table_schema = DATABASE()
+		ORDER BY table_name, ordinal_position`,
+	"sqlite": `SELECT m.name, p.name, p.type, p."notnull" = 0
+		FROM sqlite_master m JOIN pragma_table_info(m.name) p
+		WHERE m.type IN ('table', 'view') AND m.name NOT LIKE 'sqlite_%'
+		ORDER BY m.name, p.cid`,
+	"": `SELECT table_name, column_name, data_type, is_nullable = 'YES'
+		FROM information_schema.columns
+		WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
+		ORDER BY table_name, ordinal_position`,
+}
+
+// sqlTable describes one table for sql_schema.
+type sqlTable struct {
+	Name    string      `json:"name"`
+	Columns []sqlColumn `json:"columns"`
+}
+
+type sqlColumn struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+func sqlSchemaTool(db *sql.DB, config SQLConfig) *Tool {
+	query, ok := schemaQueries[config.Dialect]
+	if !ok {
+		query = schemaQueries[""]
+	}
+	return Build("sql_schema").
+		Description("List the database's tables and their columns with types").
+		OptionalParam("table", "string", "Only describe this table").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			// With a single parameter the builder passes the table name
+			// itself, or the raw JSON when it is missing.
+			table := strings.TrimSpace(input)
+			var params struct {
+				Table string `json:"table"`
+			}
+			if json.Unmarshal([]byte(input), &params) == nil {
+				table = params.Table
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+			defer cancel()
+			rows, err := db.QueryContext(ctx, query)
+			if err != nil {
+				return "", timeoutError(ctx, err, config.Timeout)
+			}
+			defer rows.Close()
+
+			tables := []sqlTable{}
+			for rows.Next() {
+				var name string
+				var col sqlColumn
+				if err := rows.Scan(&name, &col.Name, &col.Type, &col.Nullable); err != nil {
+					return "", err
+				}
+				if table != "" && !strings.EqualFold(name, table) {
+					continue
+				}
+				if n := len(tables); n == 0 || tables[n-1].Name != name {
+					tables = append(tables, sqlTable{Name: name})
+				}
+				tables[len(tables)-1].Columns = append(tables[len(tables)-1].Columns, col)
+			}
+			if err := rows.Err(); err != nil {
+				return "", timeoutError(ctx, err, config.Timeout)
+			}
+			if table != "" && len(tables) == 0 {
+				return "", fmt.Errorf("table %q not found", table)
+			}
+			out, err := json.Marshal(map[string]any{"tables": tables})
+			return string(out), err
+		}).
+		Create()
+}
+
+func sqlExecuteTool(db *sql.DB, config SQLConfig) *Tool {
+	return sqlParams(Build("sql_execute").
+		Description(fmt.Sprintf("Run a SQL statement that changes data or schema (INSERT, UPDATE, DELETE, CREATE, ...) and return the affected row count. Use %s placeholders with params for values", config.placeholders())).
+		RequiresConfirmation(), config).
+		Handler(func(ctx context.Context, input string) (string, error) {
+			params, err := parseSQLInput(input)
+			if err != nil {
+				return "", err
+			}
+			if _, err := statement(params.Query); err != nil {
+				return "", err
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+			defer cancel()
+			res, err := db.ExecContext(ctx, params.Query, params.Args...)
+			if err != nil {
+				return "", timeoutError(ctx, err, config.Timeout)
+			}
+			affected, err := res.RowsAffected()
+			if err != nil {
+				return `{"rows_affected": null}`, nil
+			}
+			return fmt.Sprintf(`{"rows_affected": %d}`, affected), nil
+		}).
+		Create()
+}
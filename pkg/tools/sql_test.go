@@ -0,0 +1,209 @@
+package tools_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// fakeDB is a database/sql driver that answers queries from a table of
+// canned results and records what it was asked to run.
+type fakeDB struct {
+	mu       sync.Mutex
+	results  map[string]fakeResult // by query prefix
+	ran      []string
+	args     [][]driver.NamedValue
+	readOnly []bool
+}
+
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return c, nil }
+func (c *fakeConn) Commit() error                       { return nil }
+func (c *fakeConn) Rollback() error                     { return nil }
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.readOnly = append(c.db.readOnly, opts.ReadOnly)
+	return c, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	c.db.ran = append(c.db.ran, query)
+	c.db.args = append(c.db.args, args)
+	c.db.mu.Unlock()
+	if strings.Contains(query, "pg_sleep") {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	for prefix, res := range c.db.results {
+		if strings.HasPrefix(query, prefix) {
+			return &fakeRows{res: res}, nil
+		}
+	}
+	return nil, fmt.Errorf("no result for %q", query)
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.ran = append(c.db.ran, query)
+	c.db.args = append(c.db.args, args)
+	return driver.RowsAffected(2), nil
+}
+
+type fakeRows struct {
+	res fakeResult
+	i   int
+}
+
+func (r *fakeRows) Columns() []string { return r.res.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i == len(r.res.rows) {
+		return io.EOF
+	}
+	copy(dest, r.res.rows[r.i])
+	r.i++
+	return nil
+}
+
+func sqlRegistry(t *testing.T, db *fakeDB, config tools.SQLConfig) *tools.Registry {
+	t.Helper()
+	registry := tools.NewRegistry()
+	if err := tools.SQLToolkit(sql.OpenDB(db), config).RegisterTo(registry); err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+func TestSQLToolkit_Query(t *testing.T) {
+	db := &fakeDB{results: map[string]fakeResult{
+		"SELECT id, name": {
+			columns: []string{"id", "name", "created"},
+			rows: [][]driver.Value{
+				{int64(1), []byte("ada"), time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)},
+				{int64(2), "bob", nil},
+				{int64(3), "cy", nil},
+			},
+		},
+	}}
+	config := tools.DefaultSQLConfig()
+	config.MaxRows = 2
+	registry := sqlRegistry(t, db, config)
+	ctx := context.Background()
+
+	out, err := registry.Execute(ctx, "sql_query", `{"query": "SELECT id, name, created FROM users WHERE team = $1 AND id > $2", "params": ["core", 0]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"columns":["id","name","created"],"rows":[{"created":"2026-10-15T09:00:00Z","id":1,"name":"ada"},{"created":null,"id":2,"name":"bob"}],"count":2,"truncated":true}`
+	if out != want {
+		t.Errorf("Unexpected result:\n%s\nwant %s", out, want)
+	}
+	if args := db.args[0]; len(args) != 2 || args[0].Value != "core" || args[1].Value != int64(0) {
+		t.Errorf("Expected params bound as arguments, got %+v", args)
+	}
+	if len(db.readOnly) != 1 || !db.readOnly[0] {
+		t.Errorf("Expected a read-only transaction, got %v", db.readOnly)
+	}
+
+	for query, wantErr := range map[string]string{
+		"DELETE FROM users":                          "DELETE statements are not allowed: sql_query is read-only; writes are disabled",
+		"SELECT 1; DROP TABLE users":                 "run one statement at a time",
+		"/* SELECT */ UPDATE users SET admin = true": "UPDATE statements are not allowed",
+		"  ":                   "query is empty",
+		"SELECT 'unterminated": "unterminated",
+	} {
+		before := len(db.ran)
+		if _, err := registry.Execute(ctx, "sql_query", `{"query": `+fmt.Sprintf("%q", query)+`}`); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%q: expected error containing %q, got %v", query, wantErr, err)
+		}
+		if len(db.ran) != before {
+			t.Errorf("%q: expected nothing sent to the database", query)
+		}
+	}
+
+	// Semicolons and keywords inside literals and comments are fine.
+	db.results["SELECT name"] = fakeResult{columns: []string{"name"}}
+	if _, err := registry.Execute(ctx, "sql_query", `{"query": "SELECT name FROM users WHERE note = 'a;b''c' -- ; DELETE\n;"}`); err != nil {
+		t.Errorf("Expected quoted semicolons to be ignored: %v", err)
+	}
+	if registry.Has("sql_execute") {
+		t.Error("Expected sql_execute only with AllowWrites")
+	}
+}
+
+func TestSQLToolkit_Timeout(t *testing.T) {
+	config := tools.DefaultSQLConfig()
+	config.Timeout = 20 * time.Millisecond
+	registry := sqlRegistry(t, &fakeDB{}, config)
+
+	start := time.Now()
+	_, err := registry.Execute(context.Background(), "sql_query", `{"query": "SELECT pg_sleep(60)"}`)
+	if err == nil || !strings.Contains(err.Error(), "query timed out after 20ms") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Query was not cut off, took %s", time.Since(start))
+	}
+}
+
+func TestSQLToolkit_SchemaAndExecute(t *testing.T) {
+	db := &fakeDB{results: map[string]fakeResult{
+		"SELECT m.name": {
+			columns: []string{"table", "column", "type", "nullable"},
+			rows: [][]driver.Value{
+				{"orders", "id", "INTEGER", false},
+				{"orders", "total", "REAL", true},
+				{"users", "id", "INTEGER", false},
+			},
+		},
+	}}
+	config := tools.DefaultSQLConfig()
+	config.Dialect = "sqlite"
+	config.AllowWrites = true
+	registry := sqlRegistry(t, db, config)
+	ctx := context.Background()
+
+	out, err := registry.Execute(ctx, "sql_schema", `{"table": "orders"}`)
+	want := `{"tables":[{"name":"orders","columns":[{"name":"id","type":"INTEGER","nullable":false},{"name":"total","type":"REAL","nullable":true}]}]}`
+	if err != nil || out != want {
+		t.Errorf("Unexpected schema: %s (%v)", out, err)
+	}
+	if _, err := registry.Execute(ctx, "sql_schema", `{"table": "missing"}`); err == nil || !strings.Contains(err.Error(), `table "missing" not found`) {
+		t.Errorf("Expected a missing table error, got %v", err)
+	}
+
+	tool, _ := registry.Get("sql_execute")
+	if tool == nil || !tool.RequiresConfirmation {
+		t.Fatal("Expected sql_execute to require confirmation")
+	}
+	out, err = registry.Execute(ctx, "sql_execute", `{"query": "UPDATE users SET name = ? WHERE id = ?", "params": ["ada", 1]}`)
+	if err != nil || out != `{"rows_affected": 2}` {
+		t.Errorf("Unexpected execute result: %s (%v)", out, err)
+	}
+	if _, err := registry.Execute(ctx, "sql_execute", `{"query": "DELETE FROM a; DELETE FROM b"}`); err == nil {
+		t.Error("Expected sql_execute to refuse several statements")
+	}
+}
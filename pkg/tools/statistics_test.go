@@ -0,0 +1,57 @@
+package tools_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestStatisticsTool(t *testing.T) {
+	registry := tools.NewRegistry()
+	tools.MathToolkit().RegisterTo(registry)
+	numbers := `[2, 4, 4, 4, 5, 5, 7, 9]`
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{"average", `{"operation": "average", "numbers": ` + numbers + `}`, "5", ""},
+		{"median even", `{"operation": "median", "numbers": ` + numbers + `}`, "4.5", ""},
+		{"median odd unsorted", `{"operation": "median", "numbers": [9, 1, 5]}`, "5", ""},
+		{"variance", `{"operation": "variance", "numbers": ` + numbers + `}`, "4", ""},
+		{"stddev", `{"operation": "stddev", "numbers": ` + numbers + `}`, "2", ""},
+		{"sample variance", `{"operation": "sample_variance", "numbers": ` + numbers + `}`, "4.57143", ""},
+		{"sample stddev", `{"operation": "sample_stddev", "numbers": ` + numbers + `}`, "2.13809", ""},
+		{"mode", `{"operation": "mode", "numbers": ` + numbers + `}`, "4", ""},
+		{"mode tie takes smallest", `{"operation": "mode", "numbers": [3, 1, 3, 1, 2]}`, "1", ""},
+		{"percentile interpolates", `{"operation": "percentile", "numbers": [10, 20, 30, 40], "p": 90}`, "37", ""},
+		{"percentile 0", `{"operation": "percentile", "numbers": [3, 1, 2], "p": 0}`, "1", ""},
+		{"percentile 100", `{"operation": "percentile", "numbers": [3, 1, 2], "p": 100}`, "3", ""},
+		{"single element", `{"operation": "percentile", "numbers": [7], "p": 25}`, "7", ""},
+		{"single element sample stddev", `{"operation": "sample_stddev", "numbers": [7]}`, "0", ""},
+		{"single element variance", `{"operation": "variance", "numbers": [7]}`, "0", ""},
+		{"empty median", `{"operation": "median", "numbers": []}`, "0", ""},
+		{"empty mode", `{"operation": "mode", "numbers": []}`, "0", ""},
+		{"percentile without p", `{"operation": "percentile", "numbers": [1, 2]}`, "", "requires p"},
+		{"percentile above 100", `{"operation": "percentile", "numbers": [1, 2], "p": 101}`, "", "between 0 and 100"},
+		{"percentile below 0", `{"operation": "percentile", "numbers": [], "p": -1}`, "", "between 0 and 100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := registry.Execute(context.Background(), "statistics", tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Got %q (%v), want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/metrics"
+)
+
+// latencyWindow is how many recent durations per tool feed P95Duration.
+const latencyWindow = 128
+
+// ToolStats summarizes the executions of one tool.
+type ToolStats struct {
+	Calls         int64         `json:"calls"`
+	Errors        int64         `json:"errors"`
+	TotalDuration time.Duration `json:"total_duration"`
+	LastDuration  time.Duration `json:"last_duration"`
+	// P95Duration is the 95th percentile of the most recent calls.
+	P95Duration time.Duration `json:"p95_duration"`
+	LastCalled  time.Time     `json:"last_called"`
+}
+
+// toolStats holds the counters of one tool.
+type toolStats struct {
+	ToolStats
+	recent []time.Duration // ring of the latest latencyWindow durations
+	next   int
+}
+
+// statsTable records executions per tool name.
+type statsTable struct {
+	mu    sync.Mutex
+	tools map[string]*toolStats
+}
+
+func newStatsTable() *statsTable {
+	return &statsTable{tools: make(map[string]*toolStats)}
+}
+
+func (t *statsTable) record(name string, start time.Time, err error) {
+	d := time.Since(start)
+	metrics.ToolCalled(name)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.tools[name]
+	if !ok {
+		s = &toolStats{}
+		t.tools[name] = s
+	}
+	s.Calls++
+	if err != nil {
+		s.Errors++
+	}
+	s.TotalDuration += d
+	s.LastDuration = d
+	s.LastCalled = start
+	if len(s.recent) < latencyWindow {
+		s.recent = append(s.recent, d)
+	} else {
+		s.recent[s.next] = d
+		s.next = (s.next + 1) % latencyWindow
+	}
+}
+
+// Stats returns execution statistics for every tool called through the
+// registry, by name. Subsets share the statistics of the registry they
+// were made from; clones start empty.
+// It is safe for concurrent use.
+func (r *Registry) Stats() map[string]ToolStats {
+	r.stats.mu.Lock()
+	defer r.stats.mu.Unlock()
+	out := make(map[string]ToolStats, len(r.stats.tools))
+	for name, s := range r.stats.tools {
+		stats := s.ToolStats
+		stats.P95Duration = percentile(s.recent, 0.95)
+		out[name] = stats
+	}
+	return out
+}
+
+// ResetStats clears the registry's execution statistics.
+// It is safe for concurrent use.
+func (r *Registry) ResetStats() {
+	r.stats.mu.Lock()
+	defer r.stats.mu.Unlock()
+	clear(r.stats.tools)
+}
+
+// percentile returns the p-th percentile of durations by nearest rank.
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	i := int(math.Ceil(float64(len(sorted))*p)) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
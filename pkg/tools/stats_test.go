@@ -0,0 +1,52 @@
+package tools_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/metrics"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+func TestRegistry_Stats(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(echoTool("echo"))
+	registry.Register(&tools.Tool{
+		Name: "fail",
+		Execute: func(ctx context.Context, input string) (string, error) {
+			return "", errors.New("boom")
+		},
+	})
+	ctx := context.Background()
+	calls := metrics.DefaultMetrics.ToolCalls.With("echo").Value()
+
+	registry.Execute(ctx, "echo", "{}")
+	subset, _ := registry.Subset("echo", "fail")
+	subset.Execute(ctx, "echo", "{}")
+	subset.Execute(ctx, "fail", "{}")
+	registry.Execute(ctx, "missing", "{}")
+
+	stats := registry.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("Expected stats for the two called tools, got %v", stats)
+	}
+	echo := stats["echo"]
+	if echo.Calls != 2 || echo.Errors != 0 || echo.LastCalled.IsZero() || echo.P95Duration > echo.TotalDuration {
+		t.Errorf("Unexpected echo stats: %+v", echo)
+	}
+	if fail := stats["fail"]; fail.Calls != 1 || fail.Errors != 1 {
+		t.Errorf("Unexpected fail stats: %+v", fail)
+	}
+	if got := metrics.DefaultMetrics.ToolCalls.With("echo").Value() - calls; got != 2 {
+		t.Errorf("Expected goflow_tool_calls_total{tool=\"echo\"} to grow by 2, got %v", got)
+	}
+	if len(registry.Clone().Stats()) != 0 {
+		t.Error("Expected a clone to start without stats")
+	}
+
+	registry.ResetStats()
+	if len(registry.Stats()) != 0 || len(subset.Stats()) != 0 {
+		t.Error("Expected ResetStats to clear the stats")
+	}
+}
@@ -2,14 +2,19 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/nuulab/goflow/pkg/egress"
 )
 
 // Toolkit is a collection of related tools.
@@ -19,37 +24,58 @@ type Toolkit struct {
 	Tools       []*Tool
 }
 
-// RegisterTo registers all toolkit tools to a registry.
-func (tk *Toolkit) RegisterTo(registry *Registry) error {
+// RegisterTo registers all toolkit tools to a registry. An optional
+// namespace prefixes each name, as in RegisterNamespaced.
+func (tk *Toolkit) RegisterTo(registry *Registry, namespace ...string) error {
+	ns := ""
+	if len(namespace) > 0 {
+		ns = namespace[0]
+	}
 	for _, tool := range tk.Tools {
-		if err := registry.Register(tool); err != nil {
+		if err := registry.RegisterNamespaced(ns, tool); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// WebToolkit returns tools for web interactions.
-func WebToolkit() *Toolkit {
+// NewHTTPClient creates the HTTP client used by the web toolkit.
+// Custom tools should use it so their requests follow the egress policy.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return egress.NewClient(timeout)
+}
+
+// WebToolkit returns tools for web interactions. An optional config
+// replaces DefaultWebConfig.
+func WebToolkit(config ...WebConfig) *Toolkit {
+	cfg := DefaultWebConfig()
+	if len(config) > 0 {
+		cfg = config[0].withDefaults()
+	}
 	return &Toolkit{
 		Name:        "web",
 		Description: "Tools for web interactions: HTTP requests, APIs, and web content",
 		Tools: []*Tool{
-			httpGetTool(),
-			httpPostTool(),
-			jsonAPITool(),
+			httpGetTool(cfg),
+			httpPostTool(cfg),
+			jsonAPITool(cfg),
 			urlEncodeTool(),
 		},
 	}
 }
 
-func httpGetTool() *Tool {
-	return Build("http_get").
-		Description("Fetch content from a URL using HTTP GET").
+// webResultDescription describes the JSON returned by http_get and http_post.
+const webResultDescription = `. Returns {"status", "headers", "final_url", "body", "truncated"}; error statuses are returned, not raised`
+
+func httpGetTool(config WebConfig) *Tool {
+	return webParams(Build("http_get").
+		Description("Fetch content from a URL using HTTP GET"+webResultDescription).
+		Cacheable().
 		Param("url", "string", "The URL to fetch").
-		OptionalParam("headers", "object", "Optional HTTP headers").
+		OptionalParam("headers", "object", "Optional HTTP headers"), config).
 		Handler(func(ctx context.Context, input string) (string, error) {
 			var params struct {
+				webRequest
 				URL     string            `json:"url"`
 				Headers map[string]string `json:"headers"`
 			}
@@ -57,40 +83,35 @@ func httpGetTool() *Tool {
 				params.URL = input
 			}
 
-			req, err := http.NewRequestWithContext(ctx, "GET", params.URL, nil)
-			if err != nil {
-				return "", fmt.Errorf("failed to create request: %w", err)
-			}
-
-			for k, v := range params.Headers {
-				req.Header.Set(k, v)
-			}
-
-			client := &http.Client{Timeout: 30 * time.Second}
-			resp, err := client.Do(req)
-			if err != nil {
-				return "", fmt.Errorf("request failed: %w", err)
-			}
-			defer resp.Body.Close()
-
-			body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024)) // 1MB limit
+			resp, err := config.send(ctx, params.webRequest, func() (*http.Request, error) {
+				req, err := http.NewRequestWithContext(ctx, "GET", params.URL, nil)
+				if err != nil {
+					return nil, err
+				}
+				for k, v := range params.Headers {
+					req.Header.Set(k, v)
+				}
+				return req, nil
+			})
 			if err != nil {
-				return "", fmt.Errorf("failed to read response: %w", err)
+				return "", err
 			}
-
-			return string(body), nil
+			out, err := json.Marshal(resp)
+			return string(out), err
 		}).
 		Create()
 }
 
-func httpPostTool() *Tool {
-	return Build("http_post").
-		Description("Send data to a URL using HTTP POST").
+func httpPostTool(config WebConfig) *Tool {
+	return webParams(Build("http_post").
+		Description("Send data to a URL using HTTP POST"+webResultDescription+". Only ask for retries when repeating the request is safe").
+		RequiresConfirmation().
 		Param("url", "string", "The URL to post to").
 		Param("body", "string", "The request body").
-		OptionalParam("content_type", "string", "Content-Type header (default: application/json)").
+		OptionalParam("content_type", "string", "Content-Type header (default: application/json)"), config).
 		Handler(func(ctx context.Context, input string) (string, error) {
 			var params struct {
+				webRequest
 				URL         string `json:"url"`
 				Body        string `json:"body"`
 				ContentType string `json:"content_type"`
@@ -103,75 +124,78 @@ func httpPostTool() *Tool {
 				params.ContentType = "application/json"
 			}
 
-			req, err := http.NewRequestWithContext(ctx, "POST", params.URL, strings.NewReader(params.Body))
-			if err != nil {
-				return "", fmt.Errorf("failed to create request: %w", err)
-			}
-			req.Header.Set("Content-Type", params.ContentType)
-
-			client := &http.Client{Timeout: 30 * time.Second}
-			resp, err := client.Do(req)
-			if err != nil {
-				return "", fmt.Errorf("request failed: %w", err)
-			}
-			defer resp.Body.Close()
-
-			body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+			resp, err := config.send(ctx, params.webRequest, func() (*http.Request, error) {
+				req, err := http.NewRequestWithContext(ctx, "POST", params.URL, strings.NewReader(params.Body))
+				if err != nil {
+					return nil, err
+				}
+				req.Header.Set("Content-Type", params.ContentType)
+				return req, nil
+			})
 			if err != nil {
-				return "", fmt.Errorf("failed to read response: %w", err)
+				return "", err
 			}
-
-			return string(body), nil
+			out, err := json.Marshal(resp)
+			return string(out), err
 		}).
 		Create()
 }
 
-func jsonAPITool() *Tool {
-	return Build("json_api").
+func jsonAPITool(config WebConfig) *Tool {
+	return webParams(Build("json_api").
 		Description("Make a JSON API request and parse the response").
 		EnumParam("method", "HTTP method", "GET", "POST", "PUT", "DELETE").
 		Param("url", "string", "The API endpoint URL").
 		OptionalParam("data", "object", "JSON data to send (for POST/PUT)").
+		OptionalParam("token", "string", "Bearer token for the Authorization header"), config).
 		Handler(func(ctx context.Context, input string) (string, error) {
 			var params struct {
+				webRequest
 				Method string         `json:"method"`
 				URL    string         `json:"url"`
 				Data   map[string]any `json:"data"`
+				Token  string         `json:"token"`
 			}
 			if err := json.Unmarshal([]byte(input), &params); err != nil {
 				return "", fmt.Errorf("invalid input: %w", err)
 			}
 
-			var body io.Reader
+			var jsonData []byte
 			if params.Data != nil && (params.Method == "POST" || params.Method == "PUT") {
-				jsonData, _ := json.Marshal(params.Data)
-				body = strings.NewReader(string(jsonData))
+				jsonData, _ = json.Marshal(params.Data)
 			}
 
-			req, err := http.NewRequestWithContext(ctx, params.Method, params.URL, body)
+			resp, err := config.send(ctx, params.webRequest, func() (*http.Request, error) {
+				var body io.Reader
+				if jsonData != nil {
+					body = bytes.NewReader(jsonData)
+				}
+				req, err := http.NewRequestWithContext(ctx, params.Method, params.URL, body)
+				if err != nil {
+					return nil, err
+				}
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("Accept", "application/json")
+				if params.Token != "" {
+					req.Header.Set("Authorization", "Bearer "+params.Token)
+				}
+				return req, nil
+			})
 			if err != nil {
 				return "", err
 			}
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("Accept", "application/json")
-
-			client := &http.Client{Timeout: 30 * time.Second}
-			resp, err := client.Do(req)
-			if err != nil {
-				return "", err
+			if resp.Truncated {
+				return fmt.Sprintf("%s\n[truncated at %d bytes]", resp.Body, config.MaxResponseBytes), nil
 			}
-			defer resp.Body.Close()
-
-			respBody, _ := io.ReadAll(resp.Body)
 
 			// Pretty-print if JSON
 			var prettyJSON map[string]any
-			if err := json.Unmarshal(respBody, &prettyJSON); err == nil {
+			if err := json.Unmarshal([]byte(resp.Body), &prettyJSON); err == nil {
 				formatted, _ := json.MarshalIndent(prettyJSON, "", "  ")
 				return string(formatted), nil
 			}
 
-			return string(respBody), nil
+			return resp.Body, nil
 		}).
 		Create()
 }
@@ -179,6 +203,7 @@ func jsonAPITool() *Tool {
 func urlEncodeTool() *Tool {
 	return Build("url_encode").
 		Description("URL encode/decode strings").
+		Cacheable().
 		EnumParam("action", "Action to perform", "encode", "decode").
 		Param("text", "string", "Text to encode or decode").
 		Handler(func(ctx context.Context, input string) (string, error) {
@@ -218,9 +243,10 @@ func DataToolkit() *Toolkit {
 
 func jsonParseTool() *Tool {
 	return Build("json_parse").
-		Description("Parse JSON and extract values using dot notation (e.g., 'user.name')").
+		Description("Parse JSON and extract values with a JSONPath-style path (e.g., 'user.name', 'items[-1]', 'items[*].id')").
+		Cacheable().
 		Param("json", "string", "JSON string to parse").
-		OptionalParam("path", "string", "Dot-notation path to extract (e.g., 'data.items[0].name')").
+		OptionalParam("path", "string", "Path to extract: dotted keys, [n] indexes (negative from the end), [*] wildcards, ['key.with.dots']").
 		Handler(func(ctx context.Context, input string) (string, error) {
 			var params struct {
 				JSON string `json:"json"`
@@ -240,10 +266,9 @@ func jsonParseTool() *Tool {
 				return string(formatted), nil
 			}
 
-			// Simple path extraction
-			result := extractPath(data, params.Path)
-			if result == nil {
-				return "null", nil
+			result, err := evalPath(data, params.Path)
+			if err != nil {
+				return "", err
 			}
 
 			out, _ := json.Marshal(result)
@@ -252,25 +277,10 @@ func jsonParseTool() *Tool {
 		Create()
 }
 
-func extractPath(data any, path string) any {
-	parts := strings.Split(path, ".")
-	current := data
-
-	for _, part := range parts {
-		switch v := current.(type) {
-		case map[string]any:
-			current = v[part]
-		default:
-			return nil
-		}
-	}
-
-	return current
-}
-
 func jsonFormatTool() *Tool {
 	return Build("json_format").
 		Description("Format/prettify JSON").
+		Cacheable().
 		Param("json", "string", "JSON to format").
 		Handler(func(ctx context.Context, input string) (string, error) {
 			var params struct {
@@ -365,6 +375,7 @@ func MathToolkit() *Toolkit {
 		Description: "Tools for mathematical operations",
 		Tools: []*Tool{
 			CalculatorTool(),
+			ExpressionTool(),
 			statisticsTool(),
 			conversionTool(),
 		},
@@ -373,17 +384,28 @@ func MathToolkit() *Toolkit {
 
 func statisticsTool() *Tool {
 	return Build("statistics").
-		Description("Calculate statistics on a list of numbers").
-		EnumParam("operation", "Statistic to calculate", "sum", "average", "min", "max", "count").
+		Description("Calculate statistics on a list of numbers. variance and stddev are for a population, sample_variance and sample_stddev divide by n-1. percentile takes p from 0 to 100 and interpolates linearly between the closest ranks (p=50 is the median). mode returns the smallest of equally common values. An empty list gives 0").
+		Cacheable().
+		EnumParam("operation", "Statistic to calculate", "sum", "average", "min", "max", "count", "median", "variance", "stddev", "sample_variance", "sample_stddev", "mode", "percentile").
 		Param("numbers", "array", "Array of numbers").
+		OptionalParam("p", "number", "Percentile to compute, from 0 to 100 (percentile only)").
 		Handler(func(ctx context.Context, input string) (string, error) {
 			var params struct {
 				Operation string    `json:"operation"`
 				Numbers   []float64 `json:"numbers"`
+				P         *float64  `json:"p"`
 			}
 			if err := json.Unmarshal([]byte(input), &params); err != nil {
 				return "", err
 			}
+			if params.Operation == "percentile" {
+				if params.P == nil {
+					return "", fmt.Errorf("percentile requires p, from 0 to 100")
+				}
+				if *params.P < 0 || *params.P > 100 || math.IsNaN(*params.P) {
+					return "", fmt.Errorf("p must be between 0 and 100, got %g", *params.P)
+				}
+			}
 
 			if len(params.Numbers) == 0 {
 				return "0", nil
@@ -396,10 +418,7 @@ func statisticsTool() *Tool {
 					result += n
 				}
 			case "average":
-				for _, n := range params.Numbers {
-					result += n
-				}
-				result /= float64(len(params.Numbers))
+				result = mean(params.Numbers)
 			case "min":
 				result = params.Numbers[0]
 				for _, n := range params.Numbers[1:] {
@@ -416,6 +435,19 @@ func statisticsTool() *Tool {
 				}
 			case "count":
 				result = float64(len(params.Numbers))
+			case "median":
+				result = linearPercentile(params.Numbers, 50)
+			case "percentile":
+				result = linearPercentile(params.Numbers, *params.P)
+			case "variance", "stddev", "sample_variance", "sample_stddev":
+				result = variance(params.Numbers, strings.HasPrefix(params.Operation, "sample_"))
+				if strings.HasSuffix(params.Operation, "stddev") {
+					result = math.Sqrt(result)
+				}
+			case "mode":
+				result = mode(params.Numbers)
+			default:
+				return "", fmt.Errorf("unsupported operation: %s", params.Operation)
 			}
 
 			return fmt.Sprintf("%.6g", result), nil
@@ -423,9 +455,64 @@ func statisticsTool() *Tool {
 		Create()
 }
 
+func mean(numbers []float64) float64 {
+	var sum float64
+	for _, n := range numbers {
+		sum += n
+	}
+	return sum / float64(len(numbers))
+}
+
+// variance returns the population variance of numbers, or the sample
+// variance (dividing by n-1), which is 0 for a single number.
+func variance(numbers []float64, sample bool) float64 {
+	n := float64(len(numbers))
+	if sample {
+		if n < 2 {
+			return 0
+		}
+		n--
+	}
+	m := mean(numbers)
+	var sum float64
+	for _, x := range numbers {
+		sum += (x - m) * (x - m)
+	}
+	return sum / n
+}
+
+// linearPercentile interpolates linearly between the closest ranks: the
+// value at rank p/100*(n-1) of the sorted numbers.
+func linearPercentile(numbers []float64, p float64) float64 {
+	sorted := append([]float64(nil), numbers...)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	if lo >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lo] + (rank-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+// mode returns the most common value, the smallest one on ties.
+func mode(numbers []float64) float64 {
+	counts := make(map[float64]int, len(numbers))
+	for _, n := range numbers {
+		counts[n]++
+	}
+	best, bestCount := 0.0, 0
+	for n, c := range counts {
+		if c > bestCount || c == bestCount && n < best {
+			best, bestCount = n, c
+		}
+	}
+	return best
+}
+
 func conversionTool() *Tool {
 	return Build("convert").
 		Description("Convert between units").
+		Cacheable().
 		Param("value", "number", "Value to convert").
 		Param("from", "string", "Source unit").
 		Param("to", "string", "Target unit").
@@ -474,6 +561,10 @@ func AllToolkits() []*Toolkit {
 		WebToolkit(),
 		DataToolkit(),
 		MathToolkit(),
+		TimeToolkit(),
+		EncodingToolkit(),
+		CSVToolkit(0),
+		RegexToolkit(),
 	}
 }
 
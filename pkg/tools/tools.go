@@ -4,10 +4,15 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/core"
 )
 
 // Tool represents an executable function that can be called by an LLM.
@@ -20,6 +25,19 @@ type Tool struct {
 	Parameters Schema `json:"parameters"`
 	// Execute is the function that runs when the tool is invoked.
 	Execute func(ctx context.Context, jsonInput string) (string, error) `json:"-"`
+	// RequiresConfirmation marks tools with side effects that agents must
+	// get approved before calling (see agent.WithConfirmationHandler).
+	RequiresConfirmation bool `json:"requires_confirmation,omitempty"`
+	// SkipValidation passes input to Execute without checking it against
+	// Parameters, for tools that accept free-form input.
+	SkipValidation bool `json:"-"`
+	// Cacheable marks tools whose output depends only on their input, so
+	// a registry with a cache may reuse it (see Registry.WithCache).
+	Cacheable bool `json:"-"`
+	// CacheVersion is part of the tool's cache keys. Change it when the
+	// tool's output for the same input changes, so old entries are not
+	// served.
+	CacheVersion string `json:"-"`
 }
 
 // Schema represents a JSON schema for tool parameters.
@@ -29,25 +47,40 @@ type Schema struct {
 	Required   []string            `json:"required,omitempty"`
 }
 
-// Property represents a property in a JSON schema.
+// Property represents a property in a JSON schema. Items describes the
+// elements of an array; Properties and Required the fields of an object,
+// and AdditionalProperties the values of a map.
 type Property struct {
-	Type        string   `json:"type"`
-	Description string   `json:"description,omitempty"`
-	Enum        []string `json:"enum,omitempty"`
+	Type                 string              `json:"type"`
+	Description          string              `json:"description,omitempty"`
+	Enum                 []string            `json:"enum,omitempty"`
+	Items                *Property           `json:"items,omitempty"`
+	Properties           map[string]Property `json:"properties,omitempty"`
+	Required             []string            `json:"required,omitempty"`
+	AdditionalProperties *Property           `json:"additionalProperties,omitempty"`
 }
 
 // Registry holds a collection of tools and provides lookup.
 // It is safe for concurrent use.
 type Registry struct {
-	mu    sync.RWMutex
-	tools map[string]*Tool
+	mu        sync.RWMutex
+	tools     map[string]*Tool
+	chain     []ToolMiddleware
+	stats     *statsTable
+	propagate bool // let tool panics through
 }
 
-// NewRegistry creates a new tool registry.
-func NewRegistry() *Registry {
-	return &Registry{
+// NewRegistry creates a new tool registry. Panics in its tools are
+// returned as a *PanicError unless WithoutPanicRecovery is given.
+func NewRegistry(opts ...RegistryOption) *Registry {
+	r := &Registry{
 		tools: make(map[string]*Tool),
+		stats: newStatsTable(),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	return r
 }
 
 // Register adds a tool to the registry.
@@ -74,6 +107,87 @@ func (r *Registry) Get(name string) (*Tool, bool) {
 	return tool, ok
 }
 
+// Replace registers tool, overwriting any tool with the same name.
+// It is safe for concurrent use.
+func (r *Registry) Replace(tool *Tool) error {
+	if tool.Name == "" {
+		return fmt.Errorf("tools: tool name cannot be empty")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[tool.Name] = tool
+	return nil
+}
+
+// Unregister removes the named tool and reports whether it was registered.
+// Calls already running finish normally.
+// It is safe for concurrent use.
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.tools[name]
+	delete(r.tools, name)
+	return ok
+}
+
+// Has reports whether a tool is registered under name.
+// It is safe for concurrent use.
+func (r *Registry) Has(name string) bool {
+	_, ok := r.Get(name)
+	return ok
+}
+
+// Clone returns a copy of the registry with its middleware. Tools are
+// copied too, so changing a tool or the tool set of one registry does not
+// affect the other. The clone's Stats start empty.
+// It is safe for concurrent use.
+func (r *Registry) Clone() *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	clone := NewRegistry()
+	clone.chain = append(clone.chain, r.chain...)
+	clone.propagate = r.propagate
+	for name, tool := range r.tools {
+		clone.tools[name] = tool.clone()
+	}
+	return clone
+}
+
+// clone copies t, including its schema.
+func (t *Tool) clone() *Tool {
+	c := *t
+	c.Parameters.Properties = cloneProperties(t.Parameters.Properties)
+	c.Parameters.Required = slices.Clone(t.Parameters.Required)
+	return &c
+}
+
+func cloneProperties(props map[string]Property) map[string]Property {
+	if props == nil {
+		return nil
+	}
+	out := make(map[string]Property, len(props))
+	for name, prop := range props {
+		out[name] = prop.clone()
+	}
+	return out
+}
+
+func (p Property) clone() Property {
+	p.Enum = slices.Clone(p.Enum)
+	p.Required = slices.Clone(p.Required)
+	p.Properties = cloneProperties(p.Properties)
+	if p.Items != nil {
+		items := p.Items.clone()
+		p.Items = &items
+	}
+	if p.AdditionalProperties != nil {
+		values := p.AdditionalProperties.clone()
+		p.AdditionalProperties = &values
+	}
+	return p
+}
+
 // List returns all registered tools.
 // It is safe for concurrent use.
 func (r *Registry) List() []*Tool {
@@ -86,22 +200,92 @@ func (r *Registry) List() []*Tool {
 	return result
 }
 
-// Execute runs a tool by name with the given JSON input.
+// Subset returns a new registry containing only the named tools.
+// It returns an error if any name is not registered.
+func (r *Registry) Subset(names ...string) (*Registry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subset := NewRegistry()
+	subset.chain = append(subset.chain, r.chain...)
+	subset.stats = r.stats
+	subset.propagate = r.propagate
+	for _, name := range names {
+		tool, ok := r.tools[name]
+		if !ok {
+			return nil, fmt.Errorf("tools: unknown tool %q", name)
+		}
+		subset.tools[name] = tool
+	}
+	return subset, nil
+}
+
+// Execute runs a tool by name with the given JSON input, through the
+// registry's middleware, and records it in Stats. If ctx is cancelled and
+// the tool reported partial output with PartialResult, the output is
+// returned along with a *PartialError. A panic in the tool or in a
+// middleware is returned as a *PanicError.
 func (r *Registry) Execute(ctx context.Context, name string, jsonInput string) (string, error) {
 	tool, ok := r.Get(name)
 	if !ok {
 		return "", fmt.Errorf("tools: unknown tool %q", name)
 	}
-	return tool.Execute(ctx, jsonInput)
+	r.mu.RLock()
+	chain := r.chain
+	r.mu.RUnlock()
+
+	next := func(ctx context.Context, name, jsonInput string) (string, error) {
+		return r.run(ctx, tool, jsonInput)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		next = chain[i](next)
+	}
+	start := time.Now()
+	output, err := r.invoke(ctx, next, name, jsonInput)
+	r.stats.record(name, start, err)
+	return output, err
 }
 
-// ToOpenAIFormat converts tools to OpenAI's function calling format.
-// It is safe for concurrent use.
+// invoke calls the middleware chain, recovering panics in it like those
+// in the tool.
+func (r *Registry) invoke(ctx context.Context, next ToolFunc, name, jsonInput string) (output string, err error) {
+	if !r.propagate {
+		defer recoverTool(name, &err)
+	}
+	return next(ctx, name, jsonInput)
+}
+
+// run validates the input and executes tool.
+func (r *Registry) run(ctx context.Context, tool *Tool, jsonInput string) (output string, err error) {
+	if err := tool.validateInput(jsonInput); err != nil {
+		return "", err
+	}
+	if !r.propagate {
+		defer recoverTool(tool.Name, &err)
+	}
+	return executeCancellable(ctx, tool, jsonInput)
+}
+
+// sortedTools returns the tools sorted by name, so the provider formats
+// list them in the same order every time. r.mu must be held.
+func (r *Registry) sortedTools() []*Tool {
+	sorted := make([]*Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		sorted = append(sorted, tool)
+	}
+	slices.SortFunc(sorted, func(a, b *Tool) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return sorted
+}
+
+// ToOpenAIFormat converts tools to OpenAI's function calling format,
+// sorted by name. It is safe for concurrent use.
 func (r *Registry) ToOpenAIFormat() []map[string]any {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	result := make([]map[string]any, 0, len(r.tools))
-	for _, tool := range r.tools {
+	for _, tool := range r.sortedTools() {
 		result = append(result, map[string]any{
 			"type": "function",
 			"function": map[string]any{
@@ -114,6 +298,27 @@ func (r *Registry) ToOpenAIFormat() []map[string]any {
 	return result
 }
 
+// ToolDefinitions returns the tools as provider-neutral definitions for
+// native tool calling, sorted by name. Tools without a schema get an empty
+// object schema. It is safe for concurrent use.
+func (r *Registry) ToolDefinitions() []core.ToolDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]core.ToolDefinition, 0, len(r.tools))
+	for _, tool := range r.sortedTools() {
+		params := tool.Parameters
+		if params.Type == "" {
+			params.Type = "object"
+		}
+		result = append(result, core.ToolDefinition{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  params,
+		})
+	}
+	return result
+}
+
 // ToolCall represents a request from the LLM to execute a tool.
 type ToolCall struct {
 	ID        string `json:"id"`
@@ -126,26 +331,118 @@ type ToolResult struct {
 	ToolCallID string `json:"tool_call_id"`
 	Content    string `json:"content"`
 	Error      string `json:"error,omitempty"`
+	// Err is the error Error was built from.
+	Err error `json:"-"`
+	// TimedOut reports that the call exceeded the WithCallTimeout limit.
+	TimedOut bool `json:"timed_out,omitempty"`
 }
 
-// ExecuteCalls runs multiple tool calls and returns results.
-func (r *Registry) ExecuteCalls(ctx context.Context, calls []ToolCall) []ToolResult {
-	results := make([]ToolResult, len(calls))
+// CallOption configures ExecuteCalls.
+type CallOption func(*callConfig)
 
+type callConfig struct {
+	concurrency int
+	timeout     time.Duration
+	stopOnError bool
+}
+
+// WithConcurrency runs up to n calls at once. Zero or less runs all calls
+// at once. Without it calls run one after another, in call order.
+func WithConcurrency(n int) CallOption {
+	return func(c *callConfig) { c.concurrency = n }
+}
+
+// WithCallTimeout bounds each call.
+func WithCallTimeout(d time.Duration) CallOption {
+	return func(c *callConfig) { c.timeout = d }
+}
+
+// WithStopOnError cancels the calls still running or waiting once one call
+// fails. By default a failed call does not affect the others.
+func WithStopOnError() CallOption {
+	return func(c *callConfig) { c.stopOnError = true }
+}
+
+// ExecuteCalls runs multiple tool calls and returns their results in call
+// order. Calls run one after another unless WithConcurrency is given, in
+// which case they start in call order on a pool of that many workers. A
+// panicking call fails alone, and once ctx is done the calls not yet
+// started fail with its cause.
+func (r *Registry) ExecuteCalls(ctx context.Context, calls []ToolCall, opts ...CallOption) []ToolResult {
+	cfg := callConfig{concurrency: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	limit := cfg.concurrency
+	if limit <= 0 || limit > len(calls) {
+		limit = len(calls)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	results := make([]ToolResult, len(calls))
 	for i, call := range calls {
-		output, err := r.Execute(ctx, call.Name, call.Arguments)
-		results[i] = ToolResult{
-			ToolCallID: call.ID,
-			Content:    output,
-		}
-		if err != nil {
-			results[i].Error = err.Error()
+		results[i] = ToolResult{ToolCallID: call.ID}
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range limit {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				r.executeCall(ctx, cancel, calls[i], &results[i], cfg)
+			}
+		}()
+	}
+
+	started := 0
+feed:
+	for ; started < len(calls); started++ {
+		select {
+		case next <- started:
+		case <-ctx.Done():
+			break feed
 		}
 	}
+	close(next)
+	wg.Wait()
 
+	for i := started; i < len(calls); i++ {
+		results[i].Err = context.Cause(ctx)
+		results[i].Error = results[i].Err.Error()
+	}
 	return results
 }
 
+// executeCall runs one call of ExecuteCalls into result.
+func (r *Registry) executeCall(ctx context.Context, cancel context.CancelCauseFunc, call ToolCall, result *ToolResult, cfg callConfig) {
+	if ctx.Err() != nil {
+		result.Err = context.Cause(ctx)
+		result.Error = result.Err.Error()
+		return
+	}
+
+	callCtx, stop := ctx, context.CancelFunc(func() {})
+	if cfg.timeout > 0 {
+		callCtx, stop = context.WithTimeout(ctx, cfg.timeout)
+	}
+	output, err := r.Execute(callCtx, call.Name, call.Arguments)
+	timedOut := err != nil && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded)
+	stop()
+
+	result.Content = output
+	result.TimedOut = timedOut
+	if err != nil {
+		result.Err = err
+		result.Error = err.Error()
+		if cfg.stopOnError {
+			cancel(fmt.Errorf("%w after %s failed: %w", context.Canceled, call.Name, err))
+		}
+	}
+}
+
 // NewTool is a helper to create a typed tool with automatic JSON parsing.
 func NewTool[I, O any](name, description string, fn func(ctx context.Context, input I) (O, error)) *Tool {
 	return &Tool{
@@ -173,6 +470,12 @@ func NewTool[I, O any](name, description string, fn func(ctx context.Context, in
 	}
 }
 
+// SchemaFor returns the schema inferred from T, the same way NewTool
+// derives its input schema.
+func SchemaFor[T any]() Schema {
+	return inferSchema[T]()
+}
+
 // inferSchema creates a JSON schema from a type using reflection.
 func inferSchema[T any]() Schema {
 	var zero T
@@ -201,14 +504,48 @@ func reflectSchema(t reflect.Type) Schema {
 		return schema
 	}
 
+	addFields(&schema.Properties, &schema.Required, t, map[reflect.Type]bool{t: true})
+	return schema
+}
+
+// timeType is encoded by encoding/json as an RFC 3339 string.
+var timeType = reflect.TypeOf(time.Time{})
+
+// reflectProperty builds the Property for a value of type t. seen holds
+// the structs being expanded, so recursive types end in a bare object.
+func reflectProperty(t reflect.Type, seen map[reflect.Type]bool) Property {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	prop := Property{Type: goKindToJSONType(t.Kind())}
+
+	switch {
+	case t == timeType:
+		prop.Type = "string"
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		prop.Type = "string" // []byte is base64 encoded
+	case t.Kind() == reflect.Slice, t.Kind() == reflect.Array:
+		items := reflectProperty(t.Elem(), seen)
+		prop.Items = &items
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		values := reflectProperty(t.Elem(), seen)
+		prop.AdditionalProperties = &values
+	case t.Kind() == reflect.Struct && !seen[t]:
+		seen[t] = true
+		prop.Properties = make(map[string]Property)
+		addFields(&prop.Properties, &prop.Required, t, seen)
+		delete(seen, t)
+	}
+	return prop
+}
+
+// addFields adds the JSON fields of struct type t to properties. Fields
+// without omitempty are required. Embedded structs without a JSON name are
+// flattened, as encoding/json does.
+func addFields(properties *map[string]Property, required *[]string, t reflect.Type, seen map[reflect.Type]bool) {
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
 
-		// Skip unexported fields
-		if !field.IsExported() {
-			continue
-		}
-
 		// Get JSON tag
 		jsonTag := field.Tag.Get("json")
 		if jsonTag == "-" {
@@ -229,28 +566,44 @@ func reflectSchema(t reflect.Type) Schema {
 			}
 		}
 
-		// Get description from doc tag
-		description := field.Tag.Get("description")
+		embedded := field.Type
+		if embedded.Kind() == reflect.Ptr {
+			embedded = embedded.Elem()
+		}
+		if field.Anonymous && embedded.Kind() == reflect.Struct && (jsonTag == "" || strings.HasPrefix(jsonTag, ",")) {
+			if !seen[embedded] {
+				seen[embedded] = true
+				addFields(properties, required, embedded, seen)
+				delete(seen, embedded)
+			}
+			continue
+		}
 
-		prop := Property{
-			Type:        goKindToJSONType(field.Type.Kind()),
-			Description: description,
+		// Skip unexported fields
+		if !field.IsExported() {
+			continue
 		}
 
-		// Handle enum tag
+		prop := reflectProperty(field.Type, seen)
+		// Get description from doc tag
+		prop.Description = field.Tag.Get("description")
+
+		// Handle enum tag, which constrains the elements of a slice
 		if enumTag := field.Tag.Get("enum"); enumTag != "" {
-			prop.Enum = strings.Split(enumTag, ",")
+			if prop.Items != nil {
+				prop.Items.Enum = strings.Split(enumTag, ",")
+			} else {
+				prop.Enum = strings.Split(enumTag, ",")
+			}
 		}
 
-		schema.Properties[name] = prop
+		(*properties)[name] = prop
 
 		// Add to required if not omitempty
 		if !omitempty {
-			schema.Required = append(schema.Required, name)
+			*required = append(*required, name)
 		}
 	}
-
-	return schema
 }
 
 // goKindToJSONType converts Go types to JSON schema types.
@@ -274,13 +627,13 @@ func goKindToJSONType(k reflect.Kind) string {
 	}
 }
 
-// ToAnthropicFormat converts tools to Anthropic's tool format.
-// It is safe for concurrent use.
+// ToAnthropicFormat converts tools to Anthropic's tool format, sorted by
+// name. It is safe for concurrent use.
 func (r *Registry) ToAnthropicFormat() []map[string]any {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	result := make([]map[string]any, 0, len(r.tools))
-	for _, tool := range r.tools {
+	for _, tool := range r.sortedTools() {
 		result = append(result, map[string]any{
 			"name":        tool.Name,
 			"description": tool.Description,
@@ -293,3 +646,94 @@ func (r *Registry) ToAnthropicFormat() []map[string]any {
 	}
 	return result
 }
+
+// ToGeminiFormat converts tools to Gemini's function declarations, sorted
+// by name. Send them as {"tools": [{"functionDeclarations": ...}]}.
+// It is safe for concurrent use.
+func (r *Registry) ToGeminiFormat() []map[string]any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]map[string]any, 0, len(r.tools))
+	for _, tool := range r.sortedTools() {
+		decl := map[string]any{
+			"name":        tool.Name,
+			"description": tool.Description,
+		}
+		// Gemini rejects an OBJECT without properties; a tool without
+		// parameters Gemini can express leaves them out.
+		params := Property{
+			Type:       "object",
+			Properties: tool.Parameters.Properties,
+			Required:   tool.Parameters.Required,
+		}
+		if geminiExpressible(params) {
+			decl["parameters"] = geminiProperty(params)
+		}
+		result = append(result, decl)
+	}
+	return result
+}
+
+// geminiProperty converts p to Gemini's Schema object. Types are uppercased
+// (STRING, INTEGER, OBJECT, ...). Gemini only has string enums, so the
+// values of any other enum are listed in the description. Gemini rejects
+// an OBJECT without properties, so free-form objects such as maps are left
+// out, along with their names in Required.
+func geminiProperty(p Property) map[string]any {
+	out := map[string]any{}
+	if p.Type != "" {
+		out["type"] = strings.ToUpper(p.Type)
+	}
+	description := p.Description
+	if len(p.Enum) > 0 {
+		if p.Type == "string" || p.Type == "" {
+			out["type"] = "STRING"
+			out["format"] = "enum"
+			out["enum"] = p.Enum
+		} else {
+			description = strings.TrimSpace(fmt.Sprintf("%s (one of %s)", description, strings.Join(p.Enum, ", ")))
+		}
+	}
+	if description != "" {
+		out["description"] = description
+	}
+	if p.Items != nil {
+		out["items"] = geminiProperty(*p.Items)
+	}
+	if len(p.Properties) > 0 {
+		props := make(map[string]any, len(p.Properties))
+		for name, prop := range p.Properties {
+			if geminiExpressible(prop) {
+				props[name] = geminiProperty(prop)
+			}
+		}
+		out["properties"] = props
+		var required []string
+		for _, name := range p.Required {
+			if _, ok := props[name]; ok {
+				required = append(required, name)
+			}
+		}
+		if len(required) > 0 {
+			out["required"] = required
+		}
+	}
+	return out
+}
+
+// geminiExpressible reports whether p converts to a schema Gemini accepts:
+// objects need at least one such property and arrays such items.
+func geminiExpressible(p Property) bool {
+	if p.Items != nil && !geminiExpressible(*p.Items) {
+		return false
+	}
+	if p.Type != "object" {
+		return true
+	}
+	for _, prop := range p.Properties {
+		if geminiExpressible(prop) {
+			return true
+		}
+	}
+	return false
+}
@@ -192,6 +192,118 @@ func TestRegistry_ConcurrentRegisterAndGet(t *testing.T) {
 	t.Log("Concurrent register/get completed without panic")
 }
 
+func TestRegistry_ConcurrentReplaceUnregisterClone(t *testing.T) {
+	registry := tools.NewRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(4)
+		name := "tool_" + string(rune('a'+i%5))
+		go func() {
+			defer wg.Done()
+			registry.Replace(&tools.Tool{
+				Name: name,
+				Execute: func(ctx context.Context, input string) (string, error) {
+					return "ok", nil
+				},
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			registry.Unregister(name)
+		}()
+		go func() {
+			defer wg.Done()
+			registry.Has(name)
+		}()
+		go func() {
+			defer wg.Done()
+			registry.Clone().List()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRegistry_ReplaceAndUnregister(t *testing.T) {
+	registry := tools.NewRegistry()
+	reply := func(out string) *tools.Tool {
+		return &tools.Tool{
+			Name: "greet",
+			Execute: func(ctx context.Context, input string) (string, error) {
+				return out, nil
+			},
+		}
+	}
+
+	if err := registry.Replace(reply("hello")); err != nil {
+		t.Fatalf("Replace of a new tool: %v", err)
+	}
+	if err := registry.Replace(reply("hi")); err != nil {
+		t.Fatalf("Replace of an existing tool: %v", err)
+	}
+	if out, _ := registry.Execute(context.Background(), "greet", "{}"); out != "hi" {
+		t.Errorf("Expected replaced tool to run, got %q", out)
+	}
+	if err := registry.Replace(&tools.Tool{}); err == nil {
+		t.Error("Expected error for a tool without a name")
+	}
+
+	if !registry.Has("greet") {
+		t.Error("Expected Has to find greet")
+	}
+	if !registry.Unregister("greet") {
+		t.Error("Expected Unregister to report a removed tool")
+	}
+	if registry.Unregister("greet") {
+		t.Error("Expected Unregister of a missing tool to report false")
+	}
+	if registry.Has("greet") {
+		t.Error("Expected greet to be gone")
+	}
+	if err := registry.Register(reply("again")); err != nil {
+		t.Errorf("Expected name to be free after Unregister: %v", err)
+	}
+}
+
+func TestRegistry_CloneIsIndependent(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(&tools.Tool{
+		Name:        "order",
+		Description: "original",
+		Parameters: tools.Schema{
+			Type: "object",
+			Properties: map[string]tools.Property{
+				"items": {Type: "array", Items: &tools.Property{Type: "string", Enum: []string{"a", "b"}}},
+			},
+			Required: []string{"items"},
+		},
+		Execute: func(ctx context.Context, input string) (string, error) {
+			return "ok", nil
+		},
+	})
+
+	clone := registry.Clone()
+	tool, _ := clone.Get("order")
+	tool.Description = "changed"
+	tool.Parameters.Required[0] = "changed"
+	tool.Parameters.Properties["items"].Items.Enum[0] = "changed"
+	clone.Unregister("order")
+	clone.Register(&tools.Tool{Name: "extra"})
+
+	original, ok := registry.Get("order")
+	if !ok {
+		t.Fatal("Expected Unregister on the clone to leave the original alone")
+	}
+	if original.Description != "original" ||
+		original.Parameters.Required[0] != "items" ||
+		original.Parameters.Properties["items"].Items.Enum[0] != "a" {
+		t.Errorf("Expected original tool to be unchanged, got %+v", original)
+	}
+	if registry.Has("extra") {
+		t.Error("Expected tools registered on the clone to stay off the original")
+	}
+}
+
 // ============ Error Handling Tests ============
 
 func TestRegistry_ExecuteWithError(t *testing.T) {
@@ -221,14 +333,12 @@ func TestRegistry_ExecuteWithPanic(t *testing.T) {
 		},
 	})
 
-	// This should panic - test that we can handle it
-	defer func() {
-		if r := recover(); r == nil {
-			t.Error("Expected panic to propagate")
-		}
-	}()
-
-	registry.Execute(context.Background(), "panic_tool", "{}")
+	// The panic is returned as an error
+	_, err := registry.Execute(context.Background(), "panic_tool", "{}")
+	var panicked *tools.PanicError
+	if !errors.As(err, &panicked) || panicked.Value != "intentional panic" {
+		t.Errorf("Expected a PanicError, got %v", err)
+	}
 }
 
 func TestRegistry_ContextCancellation(t *testing.T) {
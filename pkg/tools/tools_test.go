@@ -4,7 +4,13 @@ package tools_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/nuulab/goflow/pkg/tools"
 )
@@ -160,6 +166,135 @@ func TestRegistry_ExecuteCalls(t *testing.T) {
 	}
 }
 
+func TestRegistry_ExecuteCallsSequentialByDefault(t *testing.T) {
+	registry := tools.NewRegistry()
+	var mu sync.Mutex
+	var order []string
+	active, peak := 0, 0
+	registry.Register(tools.QuickTool("step", "step", func(ctx context.Context, input string) (string, error) {
+		mu.Lock()
+		active++
+		peak = max(peak, active)
+		order = append(order, input)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return input, nil
+	}))
+
+	var calls []tools.ToolCall
+	for _, input := range []string{"a", "b", "c", "d"} {
+		calls = append(calls, tools.ToolCall{ID: input, Name: "step", Arguments: input})
+	}
+	registry.ExecuteCalls(context.Background(), calls)
+
+	if peak != 1 || strings.Join(order, "") != "abcd" {
+		t.Errorf("Expected calls one at a time in order, got %v with %d at once", order, peak)
+	}
+}
+
+func TestRegistry_ExecuteCallsConcurrently(t *testing.T) {
+	registry := tools.NewRegistry()
+	var mu sync.Mutex
+	active, peak := 0, 0
+	registry.Register(tools.QuickTool("work", "work", func(ctx context.Context, input string) (string, error) {
+		mu.Lock()
+		active++
+		peak = max(peak, active)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		if input == "bad" {
+			return "", errors.New("bad input")
+		}
+		return "done " + input, nil
+	}))
+
+	var calls []tools.ToolCall
+	for _, input := range []string{"a", "bad", "c", "d"} {
+		calls = append(calls, tools.ToolCall{ID: input, Name: "work", Arguments: input})
+	}
+	results := registry.ExecuteCalls(context.Background(), calls, tools.WithConcurrency(2))
+
+	if peak != 2 {
+		t.Errorf("Expected 2 calls at once, got %d", peak)
+	}
+	// A failed call does not affect the others; results keep call order.
+	for i, want := range []string{"done a", "", "done c", "done d"} {
+		if results[i].ToolCallID != calls[i].ID || results[i].Content != want {
+			t.Errorf("Result %d: got %+v, want %q", i, results[i], want)
+		}
+	}
+	if results[1].Error != "bad input" || results[1].Err == nil {
+		t.Errorf("Expected the failure recorded, got %+v", results[1])
+	}
+}
+
+func TestRegistry_ExecuteCallsInOrder(t *testing.T) {
+	registry := tools.NewRegistry()
+	var mu sync.Mutex
+	var order []string
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registry.Register(tools.QuickTool("record", "record", func(ctx context.Context, input string) (string, error) {
+		mu.Lock()
+		order = append(order, input)
+		mu.Unlock()
+		if input == "stop" {
+			cancel()
+		}
+		return input, nil
+	}))
+
+	var calls []tools.ToolCall
+	for _, input := range []string{"a", "b", "c", "d", "e", "f"} {
+		calls = append(calls, tools.ToolCall{ID: input, Name: "record", Arguments: input})
+	}
+	registry.ExecuteCalls(context.Background(), calls, tools.WithConcurrency(1))
+	if strings.Join(order, "") != "abcdef" {
+		t.Errorf("Expected calls one at a time in call order, got %v", order)
+	}
+
+	// Calls not started when ctx is done fail with its cause.
+	order = nil
+	calls[1].Arguments = "stop"
+	results := registry.ExecuteCalls(ctx, calls, tools.WithConcurrency(1))
+	if len(order) != 2 || results[1].Err != nil {
+		t.Errorf("Expected two calls to run, got %v", order)
+	}
+	for _, res := range results[2:] {
+		if !errors.Is(res.Err, context.Canceled) || res.Content != "" {
+			t.Errorf("Expected %s cancelled, got %+v", res.ToolCallID, res)
+		}
+	}
+}
+
+func TestRegistry_ExecuteCallsStopOnError(t *testing.T) {
+	registry := tools.NewRegistry()
+	registry.Register(tools.QuickTool("fail", "fail", func(ctx context.Context, input string) (string, error) {
+		return "", errors.New("boom")
+	}))
+	registry.Register(tools.QuickTool("wait", "wait", func(ctx context.Context, input string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}))
+
+	calls := []tools.ToolCall{{ID: "1", Name: "wait"}, {ID: "2", Name: "fail"}}
+	results := registry.ExecuteCalls(context.Background(), calls, tools.WithConcurrency(0), tools.WithStopOnError())
+	if !errors.Is(results[0].Err, context.Canceled) || results[0].TimedOut {
+		t.Errorf("Expected the sibling cancelled, got %+v", results[0])
+	}
+
+	results = registry.ExecuteCalls(context.Background(), calls[:1], tools.WithCallTimeout(10*time.Millisecond))
+	if !results[0].TimedOut {
+		t.Errorf("Expected the call to time out, got %+v", results[0])
+	}
+}
+
 func TestRegistry_ToOpenAIFormat(t *testing.T) {
 	registry := tools.NewRegistry()
 
@@ -219,6 +354,28 @@ func TestRegistry_ToAnthropicFormat(t *testing.T) {
 	}
 }
 
+func TestRegistry_ToolDefinitionsSorted(t *testing.T) {
+	registry := tools.BuiltinTools()
+	var want []string
+	for _, tool := range registry.List() {
+		want = append(want, tool.Name)
+	}
+	sort.Strings(want)
+
+	for range 5 {
+		var names, openAI []string
+		for _, def := range registry.ToolDefinitions() {
+			names = append(names, def.Name)
+		}
+		for _, f := range registry.ToOpenAIFormat() {
+			openAI = append(openAI, f["function"].(map[string]any)["name"].(string))
+		}
+		if !slices.Equal(names, want) || !slices.Equal(openAI, want) {
+			t.Fatalf("Expected tools sorted by name, got %v and %v", names, openAI)
+		}
+	}
+}
+
 // Test typed tool creation
 type AddInput struct {
 	A int `json:"a"`
@@ -250,3 +407,151 @@ func TestNewTool(t *testing.T) {
 		t.Errorf("Expected sum 8, got %d", output.Sum)
 	}
 }
+
+func TestSchema_Validate(t *testing.T) {
+	schema := tools.Schema{
+		Type: "object",
+		Properties: map[string]tools.Property{
+			"name":  {Type: "string"},
+			"count": {Type: "integer"},
+			"mode":  {Type: "string", Enum: []string{"fast", "slow"}},
+		},
+		Required: []string{"name"},
+	}
+
+	if err := schema.Validate(map[string]any{"name": "x", "count": float64(2), "mode": "fast"}); err != nil {
+		t.Errorf("Expected valid input, got %v", err)
+	}
+
+	err := schema.Validate(map[string]any{"count": 1.5, "mode": "medium"})
+	verr, ok := err.(*tools.ValidationError)
+	if !ok {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+	if len(verr.Problems) != 3 {
+		t.Errorf("Expected 3 problems, got %q", verr.Problems)
+	}
+}
+
+func TestRegistry_ExecuteValidatesInput(t *testing.T) {
+	ran := 0
+	forecast := tools.Build("forecast").
+		Param("city", "string", "City").
+		EnumParam("units", "Units", "metric", "imperial").
+		OptionalParam("days", "integer", "Days ahead").
+		Handler(func(ctx context.Context, input string) (string, error) {
+			ran++
+			return "sunny", nil
+		}).
+		Create()
+	registry := tools.NewRegistry()
+	registry.Register(forecast)
+
+	_, err := registry.Execute(context.Background(), "forecast", `{"units": "kelvin", "days": "3"}`)
+	var verr *tools.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+	want := "tools: invalid input: city: required; days: expected integer, got string; units: must be one of [metric, imperial]"
+	if err.Error() != want {
+		t.Errorf("Unexpected error:\n got: %s\nwant: %s", err, want)
+	}
+	if _, err := registry.Execute(context.Background(), "forecast", `"Lisbon"`); !errors.As(err, &verr) || verr.Problems[0] != "input: expected a JSON object" {
+		t.Errorf("Expected a non-object input rejected, got %v", err)
+	}
+
+	results := registry.ExecuteCalls(context.Background(), []tools.ToolCall{{ID: "1", Name: "forecast", Arguments: `{}`}})
+	if !errors.As(results[0].Err, &verr) {
+		t.Errorf("Expected ExecuteCalls to validate, got %v", results[0].Err)
+	}
+	if ran != 0 {
+		t.Errorf("Handler ran %d times with invalid input", ran)
+	}
+
+	if out, err := registry.Execute(context.Background(), "forecast", `{"city": "Lisbon", "units": "metric", "days": 2}`); err != nil || out != "sunny" {
+		t.Errorf("Expected valid input to run, got %q (%v)", out, err)
+	}
+	forecast.SkipValidation = true
+	if _, err := registry.Execute(context.Background(), "forecast", `"Lisbon"`); err != nil {
+		t.Errorf("Expected SkipValidation to accept free-form input, got %v", err)
+	}
+}
+
+type OrderItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+type Address struct {
+	City string `json:"city"`
+	Zip  string `json:"zip,omitempty"`
+}
+
+type Category struct {
+	Name     string     `json:"name"`
+	Children []Category `json:"children,omitempty"`
+}
+
+type auditFields struct {
+	Note string `json:"note,omitempty"`
+}
+
+type OrderInput struct {
+	auditFields
+	Items    []OrderItem    `json:"items"`
+	Ship     Address        `json:"ship"`
+	Tags     []string       `json:"tags,omitempty" enum:"gift,rush"`
+	Counts   map[string]int `json:"counts,omitempty"`
+	Category *Category      `json:"category,omitempty"`
+}
+
+func TestSchemaFor_Nested(t *testing.T) {
+	schema := tools.SchemaFor[OrderInput]()
+
+	items := schema.Properties["items"]
+	if items.Type != "array" || items.Items == nil || items.Items.Type != "object" || items.Items.Properties["quantity"].Type != "integer" {
+		t.Errorf("Unexpected items schema: %+v", items)
+	}
+	if ship := schema.Properties["ship"]; ship.Properties["city"].Type != "string" || strings.Join(ship.Required, ",") != "city" {
+		t.Errorf("Unexpected nested struct schema: %+v", ship)
+	}
+	if tags := schema.Properties["tags"]; tags.Items == nil || strings.Join(tags.Items.Enum, ",") != "gift,rush" {
+		t.Errorf("Expected the enum on the elements, got %+v", tags)
+	}
+	if counts := schema.Properties["counts"]; counts.Type != "object" || counts.AdditionalProperties == nil || counts.AdditionalProperties.Type != "integer" {
+		t.Errorf("Unexpected map schema: %+v", counts)
+	}
+	children := schema.Properties["category"].Properties["children"]
+	if children.Items == nil || children.Items.Type != "object" || children.Items.Properties != nil {
+		t.Errorf("Expected the recursive type to end in a bare object, got %+v", children)
+	}
+	if _, ok := schema.Properties["note"]; !ok {
+		t.Error("Expected embedded fields to be flattened")
+	}
+
+	// The providers' formats carry the nested schema.
+	registry := tools.NewRegistry()
+	registry.Register(tools.NewTool("order", "Place an order", func(ctx context.Context, input OrderInput) (int, error) {
+		return input.Items[1].Quantity + input.Counts["boxes"], nil
+	}))
+	for name, format := range map[string]any{"openai": registry.ToOpenAIFormat(), "anthropic": registry.ToAnthropicFormat()} {
+		data, _ := json.Marshal(format)
+		if !strings.Contains(string(data), `"items":{"type":"array","items":{"type":"object","properties":{"quantity":{"type":"integer"},"sku":{"type":"string"}},"required":["sku","quantity"]}}`) {
+			t.Errorf("%s format lacks the items schema: %s", name, data)
+		}
+		if !strings.Contains(string(data), `"additionalProperties":{"type":"integer"}`) {
+			t.Errorf("%s format lacks the map schema: %s", name, data)
+		}
+	}
+
+	// Nested input round-trips, and nested problems are reported by path.
+	input := `{"items": [{"sku": "a", "quantity": 1}, {"sku": "b", "quantity": 2}], "ship": {"city": "Porto"}, "counts": {"boxes": 3}}`
+	if out, err := registry.Execute(context.Background(), "order", input); err != nil || out != "5" {
+		t.Errorf("Expected 5, got %q (%v)", out, err)
+	}
+	_, err := registry.Execute(context.Background(), "order", `{"items": [{"quantity": "2"}], "ship": {}, "tags": ["slow"], "counts": {"boxes": "x"}}`)
+	want := "tools: invalid input: counts.boxes: expected integer, got string; items[0].sku: required; items[0].quantity: expected integer, got string; ship.city: required; tags[0]: must be one of [gift, rush]"
+	if err == nil || err.Error() != want {
+		t.Errorf("Unexpected error:\n got: %v\nwant: %s", err, want)
+	}
+}
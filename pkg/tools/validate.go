@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ValidationError lists every problem found when validating input against a Schema.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "tools: invalid input: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks required properties, basic types and enum membership,
+// recursing into nested objects, array items and map values. Properties
+// not declared in the schema are ignored.
+func (s Schema) Validate(input map[string]any) error {
+	var problems []string
+	validateObject("", s.Properties, s.Required, input, &problems)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateObject checks the fields of an object at path.
+func validateObject(path string, properties map[string]Property, required []string, input map[string]any, problems *[]string) {
+	for _, name := range required {
+		if v, ok := input[name]; !ok || v == nil {
+			*problems = append(*problems, fmt.Sprintf("%s: required", path+name))
+		}
+	}
+
+	names := make([]string, 0, len(input))
+	for name := range input {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, ok := properties[name]
+		if !ok {
+			continue
+		}
+		validateValue(path+name, prop, input[name], problems)
+	}
+}
+
+// validateValue checks one value at path against prop.
+func validateValue(path string, prop Property, value any, problems *[]string) {
+	if value == nil {
+		return
+	}
+	if prop.Type != "" && !matchesType(prop.Type, value) {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %T", path, prop.Type, value))
+		return
+	}
+	if len(prop.Enum) > 0 && !inEnum(prop.Enum, value) {
+		*problems = append(*problems, fmt.Sprintf("%s: must be one of [%s]", path, strings.Join(prop.Enum, ", ")))
+	}
+
+	switch v := value.(type) {
+	case []any:
+		if prop.Items != nil {
+			for i, item := range v {
+				validateValue(fmt.Sprintf("%s[%d]", path, i), *prop.Items, item, problems)
+			}
+		}
+	case map[string]any:
+		validateObject(path+".", prop.Properties, prop.Required, v, problems)
+		if prop.AdditionalProperties != nil {
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if _, declared := prop.Properties[key]; !declared {
+					validateValue(path+"."+key, *prop.AdditionalProperties, v[key], problems)
+				}
+			}
+		}
+	}
+}
+
+// validateInput checks jsonInput against the tool's Parameters. Tools
+// that declare no properties, or set SkipValidation, accept any input.
+func (t *Tool) validateInput(jsonInput string) error {
+	if t.SkipValidation || (len(t.Parameters.Properties) == 0 && len(t.Parameters.Required) == 0) {
+		return nil
+	}
+	var input map[string]any
+	if err := json.Unmarshal([]byte(jsonInput), &input); err != nil || input == nil {
+		return &ValidationError{Problems: []string{"input: expected a JSON object"}}
+	}
+	return t.Parameters.Validate(input)
+}
+
+func matchesType(typ string, value any) bool {
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		switch value.(type) {
+		case float64, float32, int, int64:
+			return true
+		}
+		return false
+	case "integer":
+		switch v := value.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return v == math.Trunc(v)
+		}
+		return false
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	}
+	return true
+}
+
+func inEnum(enum []string, value any) bool {
+	s := fmt.Sprint(value)
+	for _, e := range enum {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
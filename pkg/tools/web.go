@@ -0,0 +1,192 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/egress"
+)
+
+// WebConfig configures the web toolkit.
+type WebConfig struct {
+	// MaxResponseBytes caps the response body returned to the model.
+	// Longer bodies are cut and marked truncated.
+	MaxResponseBytes int64
+	// Timeout limits each attempt of a request.
+	Timeout time.Duration
+	// MaxRetries caps the retries a call may ask for. A negative value
+	// disables retries.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry; it doubles after
+	// each one.
+	RetryBackoff time.Duration
+	// MaxRedirects caps the redirects a call may ask to follow. A negative
+	// value disables redirects, returning the redirect response itself.
+	MaxRedirects int
+	// UserAgent is sent unless a call sets its own.
+	UserAgent string
+}
+
+// DefaultWebConfig returns the defaults used by WebToolkit.
+func DefaultWebConfig() WebConfig {
+	return WebConfig{
+		MaxResponseBytes: 1024 * 1024,
+		Timeout:          30 * time.Second,
+		MaxRetries:       3,
+		RetryBackoff:     500 * time.Millisecond,
+		MaxRedirects:     10,
+		UserAgent:        "GoFlow",
+	}
+}
+
+func (c WebConfig) withDefaults() WebConfig {
+	d := DefaultWebConfig()
+	if c.MaxResponseBytes <= 0 {
+		c.MaxResponseBytes = d.MaxResponseBytes
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = d.Timeout
+	}
+	switch {
+	case c.MaxRetries == 0:
+		c.MaxRetries = d.MaxRetries
+	case c.MaxRetries < 0:
+		c.MaxRetries = 0
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = d.RetryBackoff
+	}
+	switch {
+	case c.MaxRedirects == 0:
+		c.MaxRedirects = d.MaxRedirects
+	case c.MaxRedirects < 0:
+		c.MaxRedirects = 0
+	}
+	if c.UserAgent == "" {
+		c.UserAgent = d.UserAgent
+	}
+	return c
+}
+
+// webRequest holds the options shared by the web tools.
+type webRequest struct {
+	Retries      int  `json:"retries"`
+	MaxRedirects *int `json:"max_redirects"`
+}
+
+// webParams adds the retries and max_redirects parameters to b.
+func webParams(b *ToolBuilder, config WebConfig) *ToolBuilder {
+	return b.
+		OptionalParam("retries", "integer", fmt.Sprintf("Retries on connection errors and 5xx responses, with backoff (default 0, at most %d)", config.MaxRetries)).
+		OptionalParam("max_redirects", "integer", fmt.Sprintf("Redirects to follow (default and at most %d); 0 returns the redirect itself", config.MaxRedirects))
+}
+
+// webResponse is the JSON result of http_get and http_post.
+type webResponse struct {
+	Status    int               `json:"status"`
+	Headers   map[string]string `json:"headers"`
+	FinalURL  string            `json:"final_url"`
+	Body      string            `json:"body"`
+	Truncated bool              `json:"truncated"`
+}
+
+// responseHeaders are the headers returned to the model. Others, such as
+// Set-Cookie, are dropped.
+var responseHeaders = []string{
+	"Content-Type", "Content-Length", "Content-Language", "Content-Disposition",
+	"Location", "Retry-After", "Last-Modified", "ETag", "Cache-Control",
+	"Expires", "Link", "WWW-Authenticate",
+}
+
+func filterHeaders(h http.Header) map[string]string {
+	headers := make(map[string]string)
+	for _, name := range responseHeaders {
+		if v := h.Values(name); len(v) > 0 {
+			headers[name] = strings.Join(v, ", ")
+		}
+	}
+	for name, v := range h {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-ratelimit-") || strings.HasPrefix(lower, "ratelimit-") {
+			headers[name] = strings.Join(v, ", ")
+		}
+	}
+	return headers
+}
+
+// send runs the request built by newRequest, retrying connection errors and
+// 5xx responses up to opts.Retries times. A 5xx left after the last retry
+// is returned like any other response.
+func (c WebConfig) send(ctx context.Context, opts webRequest, newRequest func() (*http.Request, error)) (*webResponse, error) {
+	retries := min(max(opts.Retries, 0), c.MaxRetries)
+	redirects := c.MaxRedirects
+	if opts.MaxRedirects != nil {
+		redirects = min(max(*opts.MaxRedirects, 0), c.MaxRedirects)
+	}
+	client := NewHTTPClient(c.Timeout)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > redirects {
+			// Hand the redirect to the model, Location and all.
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if req.Header.Get("User-Agent") == "" {
+			req.Header.Set("User-Agent", c.UserAgent)
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && (resp.StatusCode < 500 || attempt == retries) {
+			defer resp.Body.Close()
+			return c.readResponse(resp)
+		}
+		if err != nil {
+			if attempt == retries || errors.Is(err, egress.ErrEgressDenied) || ctx.Err() != nil {
+				if attempt > 0 {
+					return nil, fmt.Errorf("request failed after %d attempts: %w", attempt+1, err)
+				}
+				return nil, fmt.Errorf("request failed: %w", err)
+			}
+		} else {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, c.MaxResponseBytes))
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c WebConfig) readResponse(resp *http.Response) (*webResponse, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.MaxResponseBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	result := &webResponse{
+		Status:   resp.StatusCode,
+		Headers:  filterHeaders(resp.Header),
+		FinalURL: resp.Request.URL.String(),
+	}
+	if int64(len(body)) > c.MaxResponseBytes {
+		body = body[:c.MaxResponseBytes]
+		result.Truncated = true
+	}
+	result.Body = string(body)
+	return result, nil
+}
@@ -0,0 +1,188 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+type webResult struct {
+	Status    int               `json:"status"`
+	Headers   map[string]string `json:"headers"`
+	FinalURL  string            `json:"final_url"`
+	Body      string            `json:"body"`
+	Truncated bool              `json:"truncated"`
+}
+
+func webRegistry(config ...tools.WebConfig) *tools.Registry {
+	registry := tools.NewRegistry()
+	tools.WebToolkit(config...).RegisterTo(registry)
+	return registry
+}
+
+func httpGet(t *testing.T, registry *tools.Registry, input string) webResult {
+	t.Helper()
+	out, err := registry.Execute(context.Background(), "http_get", input)
+	if err != nil {
+		t.Fatalf("http_get failed: %v", err)
+	}
+	var result webResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("Invalid result %q: %v", out, err)
+	}
+	return result
+}
+
+func TestHTTPGet_Envelope(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-RateLimit-Remaining", "41")
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprint(w, r.UserAgent())
+	}))
+	defer srv.Close()
+
+	got := httpGet(t, webRegistry(), `{"url": "`+srv.URL+`/old"}`)
+	if got.Status != http.StatusTeapot || got.FinalURL != srv.URL+"/new" || got.Body != "GoFlow" || got.Truncated {
+		t.Errorf("Unexpected result %+v", got)
+	}
+	if got.Headers["Content-Type"] != "text/plain" || got.Headers["X-Ratelimit-Remaining"] != "41" {
+		t.Errorf("Expected content type and rate limit headers, got %v", got.Headers)
+	}
+	if _, ok := got.Headers["Set-Cookie"]; ok {
+		t.Error("Set-Cookie should be filtered out")
+	}
+}
+
+func TestHTTPGet_Truncated(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("x", 100))
+	}))
+	defer srv.Close()
+
+	got := httpGet(t, webRegistry(tools.WebConfig{MaxResponseBytes: 10}), `{"url": "`+srv.URL+`"}`)
+	if got.Body != strings.Repeat("x", 10) || !got.Truncated {
+		t.Errorf("Expected 10 bytes and truncated, got %+v", got)
+	}
+}
+
+func TestHTTPGet_Retries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+	registry := webRegistry(tools.WebConfig{MaxRetries: 2, RetryBackoff: time.Millisecond})
+
+	tests := []struct {
+		retries    int
+		wantStatus int
+		wantCalls  int32
+	}{
+		{0, http.StatusServiceUnavailable, 1},
+		{2, http.StatusOK, 3},
+		{5, http.StatusOK, 3}, // capped at MaxRetries
+		{1, http.StatusServiceUnavailable, 2},
+	}
+	for _, tt := range tests {
+		calls.Store(0)
+		got := httpGet(t, registry, fmt.Sprintf(`{"url": %q, "retries": %d}`, srv.URL, tt.retries))
+		if got.Status != tt.wantStatus || calls.Load() != tt.wantCalls {
+			t.Errorf("retries %d: got status %d after %d calls, want %d after %d", tt.retries, got.Status, calls.Load(), tt.wantStatus, tt.wantCalls)
+		}
+	}
+
+	// A negative MaxRetries disables retries whatever the call asks.
+	calls.Store(0)
+	got := httpGet(t, webRegistry(tools.WebConfig{MaxRetries: -1}), `{"url": "`+srv.URL+`", "retries": 2}`)
+	if got.Status != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("Expected one attempt with retries disabled, got status %d after %d calls", got.Status, calls.Load())
+	}
+}
+
+func TestHTTPGet_RetriesConnectionErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	registry := webRegistry(tools.WebConfig{RetryBackoff: time.Millisecond})
+	_, err := registry.Execute(context.Background(), "http_get", `{"url": "`+url+`", "retries": 2}`)
+	if err == nil || !strings.Contains(err.Error(), "request failed after 3 attempts") {
+		t.Errorf("Expected failure after 3 attempts, got %v", err)
+	}
+}
+
+func TestHTTPGet_MaxRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/%d", &n)
+		if n < 3 {
+			http.Redirect(w, r, fmt.Sprintf("/%d", n+1), http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "done")
+	}))
+	defer srv.Close()
+	registry := webRegistry()
+
+	tests := []struct {
+		maxRedirects string
+		wantStatus   int
+		wantURL      string
+		wantLocation string
+	}{
+		{"", http.StatusOK, "/3", ""},
+		{`, "max_redirects": 3`, http.StatusOK, "/3", ""},
+		{`, "max_redirects": 1`, http.StatusFound, "/1", "/2"},
+		{`, "max_redirects": 0`, http.StatusFound, "/0", "/1"},
+	}
+	for _, tt := range tests {
+		got := httpGet(t, registry, `{"url": "`+srv.URL+`/0"`+tt.maxRedirects+`}`)
+		if got.Status != tt.wantStatus || got.FinalURL != srv.URL+tt.wantURL || got.Headers["Location"] != tt.wantLocation {
+			t.Errorf("max_redirects %q: got %d at %s to %q, want %d at %s to %q", tt.maxRedirects, got.Status, got.FinalURL, got.Headers["Location"], tt.wantStatus, tt.wantURL, tt.wantLocation)
+		}
+	}
+
+	// A negative MaxRedirects disables redirects whatever the call asks.
+	got := httpGet(t, webRegistry(tools.WebConfig{MaxRedirects: -1}), `{"url": "`+srv.URL+`/0", "max_redirects": 3}`)
+	if got.Status != http.StatusFound || got.Headers["Location"] != "/1" {
+		t.Errorf("Expected the redirect itself with redirects disabled, got %+v", got)
+	}
+}
+
+func TestJSONAPI_TokenAndUserAgent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"auth":  r.Header.Get("Authorization"),
+			"agent": r.UserAgent(),
+		})
+	}))
+	defer srv.Close()
+
+	registry := webRegistry(tools.WebConfig{UserAgent: "test-agent"})
+	out, err := registry.Execute(context.Background(), "json_api", `{"method": "GET", "url": "`+srv.URL+`", "token": "abc"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal([]byte(out), &got); err != nil || got["auth"] != "Bearer abc" || got["agent"] != "test-agent" {
+		t.Errorf("Expected bearer token and user agent, got %q (%v)", out, err)
+	}
+}
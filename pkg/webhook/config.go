@@ -0,0 +1,288 @@
+// Package webhook provides self-serve webhook configuration with persistence.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/cache"
+	"github.com/nuulab/goflow/pkg/queue"
+	"github.com/nuulab/goflow/pkg/tools"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// storeKey is the cache key holding all persisted webhook configs.
+const storeKey = "webhooks:configs"
+
+var (
+	// ErrNotFound is returned when no webhook has the given ID.
+	ErrNotFound = errors.New("webhook: not found")
+	// ErrPathConflict is returned when a path is already registered.
+	ErrPathConflict = errors.New("webhook: path already registered")
+)
+
+// SetStore persists webhook configs in c and loads any previously saved ones.
+func (h *WebhookHandler) SetStore(ctx context.Context, c cache.Cache) error {
+	h.mu.Lock()
+	h.store = c
+	h.mu.Unlock()
+
+	data, err := c.Get(ctx, storeKey)
+	if errors.Is(err, cache.ErrCacheMiss) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("webhook: failed to load configs: %w", err)
+	}
+
+	var configs []*WebhookConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return fmt.Errorf("webhook: failed to decode configs: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, cfg := range configs {
+		if err := h.compileTransform(cfg); err != nil {
+			return err
+		}
+		h.hooks[cfg.Path] = cfg
+	}
+	return nil
+}
+
+// Create validates and registers a webhook config, rejecting path collisions.
+// The config is persisted if a store is set.
+func (h *WebhookHandler) Create(ctx context.Context, cfg *WebhookConfig) error {
+	if err := validate(cfg); err != nil {
+		return err
+	}
+	if err := h.compileTransform(cfg); err != nil {
+		return err
+	}
+	if cfg.ID == "" {
+		cfg.ID = fmt.Sprintf("wh-%d", time.Now().UnixNano())
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Path + " webhook"
+	}
+	cfg.CreatedAt = time.Now()
+	cfg.Enabled = true
+
+	h.mu.Lock()
+	if _, exists := h.hooks[cfg.Path]; exists {
+		h.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrPathConflict, cfg.Path)
+	}
+	h.hooks[cfg.Path] = cfg
+	h.mu.Unlock()
+
+	return h.save(ctx)
+}
+
+// Put registers cfg, replacing the webhook with the same ID in one step.
+// Unlike Create it keeps cfg.Enabled, and a replaced webhook's creation
+// time carries over. Paths used by other webhooks are rejected.
+func (h *WebhookHandler) Put(ctx context.Context, cfg *WebhookConfig) error {
+	if cfg.ID == "" {
+		return fmt.Errorf("webhook: id is required")
+	}
+	if err := validate(cfg); err != nil {
+		return err
+	}
+	if err := h.compileTransform(cfg); err != nil {
+		return err
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Path + " webhook"
+	}
+
+	h.mu.Lock()
+	if other, exists := h.hooks[cfg.Path]; exists && other.ID != cfg.ID {
+		h.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrPathConflict, cfg.Path)
+	}
+	if old := h.findByID(cfg.ID); old != nil {
+		if cfg.CreatedAt.IsZero() {
+			cfg.CreatedAt = old.CreatedAt
+		}
+		delete(h.hooks, old.Path)
+	}
+	if cfg.CreatedAt.IsZero() {
+		cfg.CreatedAt = time.Now()
+	}
+	h.hooks[cfg.Path] = cfg
+	h.mu.Unlock()
+
+	return h.save(ctx)
+}
+
+// Check reports whether Create would accept cfg, apart from path
+// collisions, without registering it.
+func (h *WebhookHandler) Check(cfg *WebhookConfig) error {
+	if err := validate(cfg); err != nil {
+		return err
+	}
+	check := *cfg
+	return h.compileTransform(&check)
+}
+
+// Get returns a webhook by ID.
+func (h *WebhookHandler) Get(id string) (*WebhookConfig, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	cfg := h.findByID(id)
+	return cfg, cfg != nil
+}
+
+// Delete removes a webhook by ID.
+func (h *WebhookHandler) Delete(ctx context.Context, id string) error {
+	h.mu.Lock()
+	cfg := h.findByID(id)
+	if cfg == nil {
+		h.mu.Unlock()
+		return ErrNotFound
+	}
+	delete(h.hooks, cfg.Path)
+	h.mu.Unlock()
+
+	return h.save(ctx)
+}
+
+// SetEnabled enables or disables a webhook by ID.
+func (h *WebhookHandler) SetEnabled(ctx context.Context, id string, enabled bool) (*WebhookConfig, error) {
+	h.mu.Lock()
+	cfg := h.findByID(id)
+	if cfg == nil {
+		h.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	cfg.Enabled = enabled
+	h.mu.Unlock()
+
+	return cfg, h.save(ctx)
+}
+
+// DryRun applies the webhook's transform to payload and reports the job or
+// workflow that a delivery would create, without creating it.
+func (h *WebhookHandler) DryRun(ctx context.Context, id string, payload *WebhookPayload) (*DryRunResult, error) {
+	cfg, ok := h.Get(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if payload == nil {
+		payload = SamplePayload()
+	}
+	return h.plan(cfg, *payload)
+}
+
+// SamplePayload returns a synthetic payload used for test deliveries.
+func SamplePayload() *WebhookPayload {
+	return &WebhookPayload{
+		Event:     "test",
+		Data:      map[string]any{"id": "sample-1", "message": "GoFlow test delivery"},
+		Timestamp: time.Now(),
+		Source:    "goflow-test",
+	}
+}
+
+// findByID must be called with h.mu held.
+func (h *WebhookHandler) findByID(id string) *WebhookConfig {
+	for _, cfg := range h.hooks {
+		if cfg.ID == id {
+			return cfg
+		}
+	}
+	return nil
+}
+
+// save writes all configs to the store, if any.
+func (h *WebhookHandler) save(ctx context.Context) error {
+	h.mu.RLock()
+	store := h.store
+	configs := make([]*WebhookConfig, 0, len(h.hooks))
+	for _, cfg := range h.hooks {
+		configs = append(configs, cfg)
+	}
+	data, err := json.Marshal(configs)
+	h.mu.RUnlock()
+
+	if store == nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("webhook: failed to encode configs: %w", err)
+	}
+	if err := store.Set(ctx, storeKey, data, 0); err != nil {
+		return fmt.Errorf("webhook: failed to save configs: %w", err)
+	}
+	return nil
+}
+
+func validate(cfg *WebhookConfig) error {
+	if !strings.HasPrefix(cfg.Path, "/") || len(cfg.Path) < 2 {
+		return fmt.Errorf("webhook: path must start with / and not be empty")
+	}
+	switch cfg.Action {
+	case ActionEnqueueJob:
+		if cfg.JobType == "" {
+			return fmt.Errorf("webhook: job_type is required for %s", cfg.Action)
+		}
+	case ActionStartWorkflow:
+		if cfg.WorkflowID == "" {
+			return fmt.Errorf("webhook: workflow_id is required for %s", cfg.Action)
+		}
+	case ActionSignal, ActionCustom:
+	default:
+		return fmt.Errorf("webhook: unknown action %q", cfg.Action)
+	}
+	return nil
+}
+
+// SetJobSchemas validates enqueue_job payloads against the registered job
+// schemas, both when payload templates are created and before enqueue.
+func (h *WebhookHandler) SetJobSchemas(s *queue.JobSchemas) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.schemas = s
+}
+
+// compileTransform compiles the transform expression or payload template
+// and records the template's static analysis warnings.
+func (h *WebhookHandler) compileTransform(cfg *WebhookConfig) error {
+	if cfg.TransformExpr != "" && cfg.PayloadTemplate != nil {
+		return fmt.Errorf("webhook: transform and payload_template are mutually exclusive")
+	}
+	if cfg.TransformExpr != "" {
+		expr, err := workflow.CompileExpr(cfg.TransformExpr)
+		if err != nil {
+			return fmt.Errorf("webhook: invalid transform: %w", err)
+		}
+		cfg.transform = expr
+	}
+	if cfg.PayloadTemplate == nil {
+		return nil
+	}
+
+	tmpl, err := workflow.CompilePayloadTemplate(cfg.PayloadTemplate)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	var schema *tools.Schema
+	if cfg.Action == ActionEnqueueJob && h.schemas != nil {
+		if s, ok := h.schemas.Get(cfg.JobType); ok {
+			schema = &s
+		}
+	}
+	warnings, err := tmpl.Check(TemplateScope, schema)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	cfg.template = tmpl
+	cfg.Warnings = warnings
+	return nil
+}
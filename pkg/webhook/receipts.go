@@ -0,0 +1,401 @@
+// Package webhook provides a receipt log of webhook deliveries with
+// duplicate detection and replay.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// defaultMaxReceipts caps the receipts kept per webhook.
+	defaultMaxReceipts = 1000
+	// defaultDedupWindow is how long a body hash marks a delivery as seen.
+	defaultDedupWindow = 10 * time.Minute
+	// deliveryIDRetention is how long a provider delivery ID is remembered.
+	deliveryIDRetention = 7 * 24 * time.Hour
+)
+
+// ErrReceiptNotFound is returned when no receipt has the given ID.
+var ErrReceiptNotFound = errors.New("webhook: receipt not found")
+
+// DeliveryIDHeaders are checked, in order, for a provider-assigned delivery
+// ID. Deliveries without one are deduplicated by body hash.
+var DeliveryIDHeaders = []string{
+	"X-Webhook-Delivery",
+	"X-GitHub-Delivery",
+	"X-Delivery-ID",
+	"Idempotency-Key",
+}
+
+// recordedHeaders are kept on receipts in addition to the delivery ID
+// headers. Signatures are never recorded.
+var recordedHeaders = []string{"Content-Type", "User-Agent", "X-GitHub-Event", "X-Event-Type"}
+
+// ReceiptOutcome is what happened to a delivery.
+type ReceiptOutcome string
+
+const (
+	OutcomeProcessed ReceiptOutcome = "processed"
+	OutcomeDuplicate ReceiptOutcome = "duplicate"
+	OutcomeFailed    ReceiptOutcome = "failed"
+)
+
+// Receipt records a webhook delivery that passed signature validation.
+type Receipt struct {
+	ID         string            `json:"id"`
+	WebhookID  string            `json:"webhook_id"`
+	DeliveryID string            `json:"delivery_id,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	BodyHash   string            `json:"body_hash"`
+	// Body is kept so the delivery can be replayed.
+	Body    string            `json:"body"`
+	Outcome ReceiptOutcome    `json:"outcome"`
+	Result  map[string]string `json:"result,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	// DuplicateOf is the receipt of the first delivery of a duplicate.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// ReplayOf is the receipt a replayed delivery was re-processed from.
+	ReplayOf   string    `json:"replay_of,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// ReceiptStore persists receipts and the keys used to detect duplicates.
+type ReceiptStore interface {
+	// Append stores r.
+	Append(ctx context.Context, r *Receipt) error
+	// List returns up to limit receipts of a webhook, newest first.
+	List(ctx context.Context, webhookID string, limit int) ([]Receipt, error)
+	// Get returns a receipt by ID.
+	Get(ctx context.Context, webhookID, id string) (*Receipt, error)
+	// Claim marks key as seen by receiptID for ttl. If the key is already
+	// claimed it returns false and the receipt ID holding it.
+	Claim(ctx context.Context, key, receiptID string, ttl time.Duration) (bool, string, error)
+	// Release drops a claim so a retried delivery is processed again.
+	Release(ctx context.Context, key string) error
+}
+
+// SetReceiptStore enables the receipt log. Every accepted delivery is
+// recorded in store, and deliveries already seen are acknowledged without
+// triggering their action again.
+func (h *WebhookHandler) SetReceiptStore(store ReceiptStore) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.receipts = store
+}
+
+// SetDedupWindow sets how long an identical body without a delivery ID is
+// treated as a duplicate. The default is 10 minutes.
+func (h *WebhookHandler) SetDedupWindow(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dedupTTL = d
+}
+
+// Deliveries returns up to limit recent receipts of a webhook, newest first.
+func (h *WebhookHandler) Deliveries(ctx context.Context, id string, limit int) ([]Receipt, error) {
+	store := h.receiptStore()
+	if store == nil {
+		return nil, fmt.Errorf("webhook: receipt log not configured")
+	}
+	if _, ok := h.Get(id); !ok {
+		return nil, ErrNotFound
+	}
+	return store.List(ctx, id, limit)
+}
+
+// Replay re-processes a recorded delivery, bypassing duplicate detection,
+// and returns the receipt of the replay.
+func (h *WebhookHandler) Replay(ctx context.Context, id, receiptID string) (*Receipt, error) {
+	store := h.receiptStore()
+	if store == nil {
+		return nil, fmt.Errorf("webhook: receipt log not configured")
+	}
+	cfg, ok := h.Get(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	original, err := store.Get(ctx, id, receiptID)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := &Receipt{
+		ID:         newReceiptID(),
+		WebhookID:  id,
+		DeliveryID: original.DeliveryID,
+		Headers:    original.Headers,
+		BodyHash:   original.BodyHash,
+		Body:       original.Body,
+		ReplayOf:   original.ID,
+		ReceivedAt: time.Now(),
+	}
+	result, err := h.executeAction(ctx, cfg, parsePayload([]byte(original.Body)))
+	recordOutcome(receipt, result, err)
+	if appendErr := store.Append(ctx, receipt); appendErr != nil {
+		return nil, fmt.Errorf("webhook: failed to record replay: %w", appendErr)
+	}
+	return receipt, err
+}
+
+func (h *WebhookHandler) receiptStore() ReceiptStore {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.receipts
+}
+
+// newReceipt builds the receipt of an incoming delivery.
+func newReceipt(cfg *WebhookConfig, r *http.Request, body []byte) *Receipt {
+	sum := sha256.Sum256(body)
+	receipt := &Receipt{
+		ID:         newReceiptID(),
+		WebhookID:  cfg.ID,
+		Headers:    make(map[string]string),
+		BodyHash:   hex.EncodeToString(sum[:]),
+		Body:       string(body),
+		ReceivedAt: time.Now(),
+	}
+	for _, name := range DeliveryIDHeaders {
+		if v := r.Header.Get(name); v != "" {
+			if receipt.DeliveryID == "" {
+				receipt.DeliveryID = v
+			}
+			receipt.Headers[name] = v
+		}
+	}
+	for _, name := range recordedHeaders {
+		if v := r.Header.Get(name); v != "" {
+			receipt.Headers[name] = v
+		}
+	}
+	return receipt
+}
+
+// newReceiptID returns a random receipt ID.
+func newReceiptID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("rcpt-%d", time.Now().UnixNano())
+	}
+	return "rcpt-" + hex.EncodeToString(b)
+}
+
+// dedupKey returns the claim key of a delivery and how long it is held.
+func (h *WebhookHandler) dedupKey(r *Receipt) (string, time.Duration) {
+	if r.DeliveryID != "" {
+		return fmt.Sprintf("%s:delivery:%s", r.WebhookID, r.DeliveryID), deliveryIDRetention
+	}
+	h.mu.RLock()
+	window := h.dedupTTL
+	h.mu.RUnlock()
+	if window <= 0 {
+		window = defaultDedupWindow
+	}
+	return fmt.Sprintf("%s:body:%s", r.WebhookID, r.BodyHash), window
+}
+
+// recordOutcome stores the action result or error on r.
+func recordOutcome(r *Receipt, result any, err error) {
+	if err != nil {
+		r.Outcome = OutcomeFailed
+		r.Error = err.Error()
+		return
+	}
+	r.Outcome = OutcomeProcessed
+	if m, ok := result.(map[string]string); ok {
+		r.Result = m
+	}
+}
+
+// ============ Memory Store ============
+
+// MemoryReceiptStore keeps receipts in process memory.
+type MemoryReceiptStore struct {
+	max      int
+	receipts map[string][]Receipt
+	claims   map[string]claim
+	now      func() time.Time
+	mu       sync.Mutex
+}
+
+type claim struct {
+	receiptID string
+	expires   time.Time
+}
+
+// NewMemoryReceiptStore creates a store keeping up to max receipts per
+// webhook. A max of zero uses the default of 1000.
+func NewMemoryReceiptStore(max int) *MemoryReceiptStore {
+	if max <= 0 {
+		max = defaultMaxReceipts
+	}
+	return &MemoryReceiptStore{
+		max:      max,
+		receipts: make(map[string][]Receipt),
+		claims:   make(map[string]claim),
+		now:      time.Now,
+	}
+}
+
+// Append stores r, dropping the oldest receipt beyond the cap.
+func (s *MemoryReceiptStore) Append(ctx context.Context, r *Receipt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := append(s.receipts[r.WebhookID], *r)
+	if len(list) > s.max {
+		list = list[len(list)-s.max:]
+	}
+	s.receipts[r.WebhookID] = list
+	return nil
+}
+
+// List returns up to limit receipts of a webhook, newest first.
+func (s *MemoryReceiptStore) List(ctx context.Context, webhookID string, limit int) ([]Receipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := s.receipts[webhookID]
+	result := make([]Receipt, 0, len(list))
+	for i := len(list) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		result = append(result, list[i])
+	}
+	return result, nil
+}
+
+// Get returns a receipt by ID.
+func (s *MemoryReceiptStore) Get(ctx context.Context, webhookID, id string) (*Receipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.receipts[webhookID] {
+		if r.ID == id {
+			return &r, nil
+		}
+	}
+	return nil, ErrReceiptNotFound
+}
+
+// Claim marks key as seen by receiptID for ttl.
+func (s *MemoryReceiptStore) Claim(ctx context.Context, key, receiptID string, ttl time.Duration) (bool, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if c, ok := s.claims[key]; ok && now.Before(c.expires) {
+		return false, c.receiptID, nil
+	}
+	s.claims[key] = claim{receiptID: receiptID, expires: now.Add(ttl)}
+	return true, "", nil
+}
+
+// Release drops a claim.
+func (s *MemoryReceiptStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.claims, key)
+	return nil
+}
+
+// ============ Redis Store ============
+
+// RedisReceiptStore keeps receipts in one capped Redis stream per webhook
+// (Redis/DragonflyDB). Duplicate claims are keys with a TTL.
+type RedisReceiptStore struct {
+	client *redis.Client
+	prefix string
+	max    int64
+}
+
+// NewRedisReceiptStore creates a store keeping about max receipts per
+// webhook. A max of zero uses the default of 1000.
+func NewRedisReceiptStore(client *redis.Client, max int64) *RedisReceiptStore {
+	if max <= 0 {
+		max = defaultMaxReceipts
+	}
+	return &RedisReceiptStore{client: client, prefix: "goflow:webhooks:", max: max}
+}
+
+func (s *RedisReceiptStore) streamKey(webhookID string) string {
+	return s.prefix + "receipts:" + webhookID
+}
+
+// Append adds r to the webhook's stream, trimming it to about the cap.
+func (s *RedisReceiptStore) Append(ctx context.Context, r *Receipt) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.streamKey(r.WebhookID),
+		MaxLen: s.max,
+		Approx: true,
+		Values: map[string]any{"receipt": data},
+	}).Err()
+}
+
+// List returns up to limit receipts of a webhook, newest first.
+func (s *RedisReceiptStore) List(ctx context.Context, webhookID string, limit int) ([]Receipt, error) {
+	var msgs []redis.XMessage
+	var err error
+	if limit > 0 {
+		msgs, err = s.client.XRevRangeN(ctx, s.streamKey(webhookID), "+", "-", int64(limit)).Result()
+	} else {
+		msgs, err = s.client.XRevRange(ctx, s.streamKey(webhookID), "+", "-").Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	receipts := make([]Receipt, 0, len(msgs))
+	for _, msg := range msgs {
+		data, _ := msg.Values["receipt"].(string)
+		var r Receipt
+		if err := json.Unmarshal([]byte(data), &r); err != nil {
+			return nil, fmt.Errorf("webhook: invalid receipt %s: %w", msg.ID, err)
+		}
+		receipts = append(receipts, r)
+	}
+	return receipts, nil
+}
+
+// Get returns a receipt by ID. The stream is capped, so this scans it.
+func (s *RedisReceiptStore) Get(ctx context.Context, webhookID, id string) (*Receipt, error) {
+	receipts, err := s.List(ctx, webhookID, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range receipts {
+		if r.ID == id {
+			return &r, nil
+		}
+	}
+	return nil, ErrReceiptNotFound
+}
+
+// Claim marks key as seen by receiptID for ttl.
+func (s *RedisReceiptStore) Claim(ctx context.Context, key, receiptID string, ttl time.Duration) (bool, string, error) {
+	full := s.prefix + "seen:" + key
+	ok, err := s.client.SetNX(ctx, full, receiptID, ttl).Result()
+	if err != nil || ok {
+		return ok, "", err
+	}
+	holder, err := s.client.Get(ctx, full).Result()
+	if errors.Is(err, redis.Nil) {
+		// The claim expired in between; try once more.
+		ok, err = s.client.SetNX(ctx, full, receiptID, ttl).Result()
+		return ok, "", err
+	}
+	return false, holder, err
+}
+
+// Release drops a claim.
+func (s *RedisReceiptStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+"seen:"+key).Err()
+}
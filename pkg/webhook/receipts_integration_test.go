@@ -0,0 +1,63 @@
+//go:build integration
+
+// Package webhook_test runs the receipt store against Redis. Set
+// GOFLOW_TEST_REDIS_ADDR and run with -tags integration.
+package webhook_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/nuulab/goflow/pkg/webhook"
+)
+
+func TestRedisReceiptStore(t *testing.T) {
+	addr := os.Getenv("GOFLOW_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("GOFLOW_TEST_REDIS_ADDR not set")
+	}
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	webhookID := fmt.Sprintf("wh-test-%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		keys, _ := client.Keys(ctx, "goflow:webhooks:*"+webhookID+"*").Result()
+		if len(keys) > 0 {
+			client.Del(ctx, keys...)
+		}
+		client.Close()
+	})
+
+	store := webhook.NewRedisReceiptStore(client, 2)
+	for i := 1; i <= 3; i++ {
+		if err := store.Append(ctx, &webhook.Receipt{ID: fmt.Sprintf("r%d", i), WebhookID: webhookID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := store.List(ctx, webhookID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "r3" {
+		t.Fatalf("Expected newest receipts first, got %+v", list)
+	}
+	if r, err := store.Get(ctx, webhookID, "r2"); err != nil || r.ID != "r2" {
+		t.Errorf("Get returned %+v, %v", r, err)
+	}
+
+	key := webhookID + ":delivery:d-1"
+	if ok, _, err := store.Claim(ctx, key, "r1", time.Minute); err != nil || !ok {
+		t.Fatalf("First claim failed: %v", err)
+	}
+	if ok, holder, _ := store.Claim(ctx, key, "r2", time.Minute); ok || holder != "r1" {
+		t.Errorf("Expected claim held by r1, got %v %q", ok, holder)
+	}
+	store.Release(ctx, key)
+	if ok, _, _ := store.Claim(ctx, key, "r3", time.Minute); !ok {
+		t.Error("Expected claim after release")
+	}
+}
@@ -7,13 +7,18 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/nuulab/goflow/pkg/cache"
+	"github.com/nuulab/goflow/pkg/egress"
 	"github.com/nuulab/goflow/pkg/queue"
+	"github.com/nuulab/goflow/pkg/tools"
 	"github.com/nuulab/goflow/pkg/workflow"
 )
 
@@ -23,6 +28,11 @@ type WebhookHandler struct {
 	engine   *workflow.Engine
 	hooks    map[string]*WebhookConfig
 	secret   string
+	store    cache.Cache
+	receipts ReceiptStore
+	dedupTTL time.Duration
+	schemas  *queue.JobSchemas
+	mu       sync.RWMutex
 }
 
 // WebhookConfig defines how a webhook triggers actions.
@@ -31,12 +41,32 @@ type WebhookConfig struct {
 	Name        string            `json:"name"`
 	Path        string            `json:"path"`
 	Secret      string            `json:"secret,omitempty"`
+	// SecretRef names the secret Secret was resolved from, so exports
+	// can reference it instead of embedding the value.
+	SecretRef   string            `json:"secret_ref,omitempty"`
 	Action      WebhookAction     `json:"action"`
 	JobType     string            `json:"job_type,omitempty"`
 	WorkflowID  string            `json:"workflow_id,omitempty"`
 	Transform   func([]byte) any  `json:"-"`
+	// TransformExpr is an expression (see workflow.CompileExpr) evaluated
+	// with payload, event and data variables. It is used when Transform is nil.
+	TransformExpr string          `json:"transform,omitempty"`
+	// PayloadTemplate builds the job or workflow payload (see
+	// workflow.PayloadTemplate) from the same variables as TransformExpr.
+	PayloadTemplate map[string]any `json:"payload_template,omitempty"`
 	Enabled     bool              `json:"enabled"`
 	CreatedAt   time.Time         `json:"created_at"`
+	// Warnings are the template's static analysis warnings.
+	Warnings    []string          `json:"warnings,omitempty"`
+	transform   *workflow.Expr
+	template    *workflow.PayloadTemplate
+}
+
+// TemplateScope lists the variables webhook payload templates can read.
+var TemplateScope = workflow.TemplateScope{
+	"payload": {"event", "data", "timestamp", "source"},
+	"event":   {},
+	"data":    nil,
 }
 
 // WebhookAction defines what the webhook triggers.
@@ -70,7 +100,9 @@ func NewWebhookHandler(q queue.Queue, engine *workflow.Engine) *WebhookHandler {
 func (h *WebhookHandler) Register(cfg *WebhookConfig) {
 	cfg.CreatedAt = time.Now()
 	cfg.Enabled = true
+	h.mu.Lock()
 	h.hooks[cfg.Path] = cfg
+	h.mu.Unlock()
 }
 
 // RegisterJobWebhook creates a webhook that enqueues a job.
@@ -110,13 +142,16 @@ func (h *WebhookHandler) Handler() http.Handler {
 
 		// Find matching webhook
 		path := strings.TrimPrefix(r.URL.Path, "/webhooks")
+		h.mu.RLock()
 		cfg, ok := h.hooks[path]
+		enabled := ok && cfg.Enabled
+		h.mu.RUnlock()
 		if !ok {
 			http.Error(w, "Webhook not found", http.StatusNotFound)
 			return
 		}
 
-		if !cfg.Enabled {
+		if !enabled {
 			http.Error(w, "Webhook disabled", http.StatusServiceUnavailable)
 			return
 		}
@@ -124,6 +159,11 @@ func (h *WebhookHandler) Handler() http.Handler {
 		// Read body
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
 			http.Error(w, "Failed to read body", http.StatusBadRequest)
 			return
 		}
@@ -141,43 +181,92 @@ func (h *WebhookHandler) Handler() http.Handler {
 			}
 		}
 
-		// Parse payload
-		var payload WebhookPayload
-		if err := json.Unmarshal(body, &payload); err != nil {
-			// Try raw data
-			payload = WebhookPayload{
-				Data:      map[string]any{"raw": string(body)},
-				Timestamp: time.Now(),
-			}
-		}
-
 		// Execute action
-		ctx := r.Context()
-		result, err := h.executeAction(ctx, cfg, payload)
+		receipt, result, err := h.deliver(r.Context(), cfg, r, body)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			status := http.StatusInternalServerError
+			var invalid *tools.ValidationError
+			if errors.As(err, &invalid) {
+				status = http.StatusUnprocessableEntity
+			}
+			http.Error(w, err.Error(), status)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]any{
+		response := map[string]any{
 			"success": true,
 			"result":  result,
-		})
+		}
+		if receipt != nil {
+			response["receipt_id"] = receipt.ID
+			if receipt.Outcome == OutcomeDuplicate {
+				response["duplicate"] = true
+				response["duplicate_of"] = receipt.DuplicateOf
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
 	})
 }
 
+// parsePayload decodes a delivery body, wrapping non-JSON bodies as raw data.
+func parsePayload(body []byte) WebhookPayload {
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		payload = WebhookPayload{
+			Data:      map[string]any{"raw": string(body)},
+			Timestamp: time.Now(),
+		}
+	}
+	return payload
+}
+
+// deliver runs the action of an accepted delivery. With a receipt log, a
+// delivery already seen is acknowledged without running the action, and
+// every delivery is recorded. Failing to record a receipt does not fail
+// the delivery.
+func (h *WebhookHandler) deliver(ctx context.Context, cfg *WebhookConfig, r *http.Request, body []byte) (*Receipt, any, error) {
+	store := h.receiptStore()
+	if store == nil {
+		result, err := h.executeAction(ctx, cfg, parsePayload(body))
+		return nil, result, err
+	}
+
+	receipt := newReceipt(cfg, r, body)
+	key, ttl := h.dedupKey(receipt)
+	claimed, holder, err := store.Claim(ctx, key, receipt.ID, ttl)
+	if err != nil {
+		return nil, nil, fmt.Errorf("webhook: duplicate check failed: %w", err)
+	}
+
+	var result any
+	if claimed {
+		result, err = h.executeAction(ctx, cfg, parsePayload(body))
+		recordOutcome(receipt, result, err)
+		if err != nil {
+			// Let the provider's retry run the action again.
+			store.Release(ctx, key)
+		}
+	} else {
+		receipt.Outcome = OutcomeDuplicate
+		receipt.DuplicateOf = holder
+	}
+	store.Append(ctx, receipt)
+	return receipt, result, err
+}
+
 func (h *WebhookHandler) executeAction(ctx context.Context, cfg *WebhookConfig, payload WebhookPayload) (any, error) {
+	plan, err := h.plan(cfg, payload)
+	if err != nil {
+		return nil, err
+	}
+
 	switch cfg.Action {
 	case ActionEnqueueJob:
-		// Transform payload if transformer is set
-		var jobPayload any = payload.Data
-		if cfg.Transform != nil {
-			data, _ := json.Marshal(payload)
-			jobPayload = cfg.Transform(data)
+		if h.queue == nil {
+			return nil, fmt.Errorf("queue not configured")
 		}
-
-		job, err := queue.NewJob(cfg.JobType, jobPayload)
+		job, err := queue.NewJob(cfg.JobType, plan.Payload)
 		if err != nil {
 			return nil, err
 		}
@@ -190,13 +279,10 @@ func (h *WebhookHandler) executeAction(ctx context.Context, cfg *WebhookConfig,
 		return map[string]string{"job_id": job.ID}, nil
 
 	case ActionStartWorkflow:
-		input := payload.Data
-		if cfg.Transform != nil {
-			data, _ := json.Marshal(payload)
-			input = cfg.Transform(data).(map[string]any)
+		if h.engine == nil {
+			return nil, fmt.Errorf("workflow engine not configured")
 		}
-
-		stateID, err := h.engine.Start(ctx, cfg.WorkflowID, input)
+		stateID, err := h.engine.Start(ctx, cfg.WorkflowID, plan.Payload.(map[string]any))
 		if err != nil {
 			return nil, err
 		}
@@ -214,6 +300,73 @@ func (h *WebhookHandler) executeAction(ctx context.Context, cfg *WebhookConfig,
 	}
 }
 
+// DryRunResult describes what a webhook delivery would do.
+type DryRunResult struct {
+	WebhookID  string         `json:"webhook_id"`
+	Action     WebhookAction  `json:"action"`
+	JobType    string         `json:"job_type,omitempty"`
+	WorkflowID string         `json:"workflow_id,omitempty"`
+	Request    WebhookPayload `json:"request"`
+	Payload    any            `json:"payload"`
+}
+
+// plan applies the transform and resolves the action target without side effects.
+func (h *WebhookHandler) plan(cfg *WebhookConfig, payload WebhookPayload) (*DryRunResult, error) {
+	result := &DryRunResult{
+		WebhookID: cfg.ID,
+		Action:    cfg.Action,
+		Request:   payload,
+		Payload:   payload.Data,
+	}
+
+	switch {
+	case cfg.Transform != nil:
+		data, _ := json.Marshal(payload)
+		result.Payload = cfg.Transform(data)
+	case cfg.transform != nil:
+		out, err := cfg.transform.Eval(transformVars(payload))
+		if err != nil {
+			return nil, fmt.Errorf("transform failed: %w", err)
+		}
+		result.Payload = out
+	case cfg.template != nil:
+		out, err := cfg.template.Render(workflow.TriggerContext{Vars: transformVars(payload)})
+		if err != nil {
+			return nil, err
+		}
+		result.Payload = out
+	}
+
+	switch cfg.Action {
+	case ActionEnqueueJob:
+		result.JobType = cfg.JobType
+		if out, ok := result.Payload.(map[string]any); ok && h.schemas != nil {
+			if err := h.schemas.Validate(cfg.JobType, out); err != nil {
+				return nil, err
+			}
+		}
+	case ActionStartWorkflow:
+		result.WorkflowID = cfg.WorkflowID
+		if _, ok := result.Payload.(map[string]any); !ok {
+			return nil, fmt.Errorf("workflow input must be an object, got %T", result.Payload)
+		}
+	}
+	return result, nil
+}
+
+// transformVars exposes the payload to transform expressions as plain JSON values.
+func transformVars(payload WebhookPayload) map[string]any {
+	var generic map[string]any
+	data, _ := json.Marshal(payload)
+	json.Unmarshal(data, &generic)
+
+	return map[string]any{
+		"payload": generic,
+		"event":   payload.Event,
+		"data":    generic["data"],
+	}
+}
+
 func (h *WebhookHandler) validateSignature(body []byte, signature, secret string) bool {
 	if signature == "" {
 		return false
@@ -231,6 +384,8 @@ func (h *WebhookHandler) validateSignature(body []byte, signature, secret string
 
 // List returns all registered webhooks.
 func (h *WebhookHandler) List() []*WebhookConfig {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	result := make([]*WebhookConfig, 0, len(h.hooks))
 	for _, cfg := range h.hooks {
 		result = append(result, cfg)
@@ -240,6 +395,8 @@ func (h *WebhookHandler) List() []*WebhookConfig {
 
 // Enable enables a webhook by path.
 func (h *WebhookHandler) Enable(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	if cfg, ok := h.hooks[path]; ok {
 		cfg.Enabled = true
 	}
@@ -247,6 +404,8 @@ func (h *WebhookHandler) Enable(path string) {
 
 // Disable disables a webhook by path.
 func (h *WebhookHandler) Disable(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	if cfg, ok := h.hooks[path]; ok {
 		cfg.Enabled = false
 	}
@@ -254,6 +413,8 @@ func (h *WebhookHandler) Disable(path string) {
 
 // Remove removes a webhook by path.
 func (h *WebhookHandler) Remove(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	delete(h.hooks, path)
 }
 
@@ -301,7 +462,7 @@ func (wt *WebhookTool) SendWebhook(ctx context.Context, input SendWebhookInput)
 		req.Header.Set(k, v)
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := egress.NewClient(30 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
@@ -0,0 +1,204 @@
+// Package workflow_test provides tests for durable await steps.
+package workflow_test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// waitFor polls cond until it holds or fails the test after two seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// crashWhileAwaiting starts wf on an engine, waits until the run is
+// persisted as awaiting, and copies that state into a fresh store, as if
+// the process had died there.
+func crashWhileAwaiting(t *testing.T, wf *workflow.Workflow, status workflow.Status) (*workflow.MemoryPersistence, *workflow.State) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := workflow.NewMemoryPersistence()
+	engine := workflow.NewEngine(store)
+	engine.Register(wf)
+	id, err := engine.Start(ctx, wf.Name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var saved *workflow.State
+	waitFor(t, "the awaiting state to be saved", func() bool {
+		saved, err = store.Load(context.Background(), id)
+		return err == nil && saved.Status == status
+	})
+	restarted := workflow.NewMemoryPersistence()
+	restarted.Save(context.Background(), saved)
+	return restarted, saved
+}
+
+func TestAwait_SignalResumesAfterRestart(t *testing.T) {
+	var prepared atomic.Int32
+	wf := workflow.New("order").
+		Step("prepare", func(ctx context.Context, state *workflow.State) (any, error) {
+			prepared.Add(1)
+			return "packed", nil
+		}).Then().
+		AwaitSignal("ship", "shipped").Then().
+		Step("finish", func(ctx context.Context, state *workflow.State) (any, error) {
+			return state.Data["shipped"], nil
+		}).Then().
+		Build()
+
+	store, saved := crashWhileAwaiting(t, wf, workflow.StatusAwaitingSignal)
+	if saved.AwaitingStep != "ship" || saved.CurrentStep != 1 || saved.StepResults["prepare"] != "packed" {
+		t.Fatalf("Unexpected saved state: %+v", saved)
+	}
+
+	engine := workflow.NewEngine(store)
+	engine.Register(wf)
+	if n, err := engine.ResumeAwaiting(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Expected 1 run resumed, got %d (%v)", n, err)
+	}
+	waitFor(t, "the resumed await", func() bool { return engine.AwaitingSummary().Total == 1 })
+
+	// The resumed await keeps its original start time.
+	resaved, _ := store.Load(context.Background(), saved.ID)
+	if !resaved.AwaitingSince.Equal(saved.AwaitingSince) {
+		t.Errorf("Await restarted its clock: %v, was %v", resaved.AwaitingSince, saved.AwaitingSince)
+	}
+
+	engine.SendSignal(context.Background(), "shipped", map[string]any{"carrier": "ups"})
+	var final *workflow.State
+	waitFor(t, "the run to complete", func() bool {
+		final, _ = store.Load(context.Background(), saved.ID)
+		return final.Status == workflow.StatusCompleted
+	})
+	if prepared.Load() != 1 {
+		t.Errorf("Expected the step before the await to run once, ran %d times", prepared.Load())
+	}
+	if result, _ := final.StepResults["finish"].(map[string]any); result["carrier"] != "ups" {
+		t.Errorf("Expected the signal payload in state data, got %v", final.StepResults["finish"])
+	}
+}
+
+func TestAwait_ApprovalResumesAfterRestart(t *testing.T) {
+	wf := workflow.New("expense").
+		AwaitApproval("manager", []string{"alice"}).Then().
+		Build()
+	store, saved := crashWhileAwaiting(t, wf, workflow.StatusAwaitingApproval)
+
+	engine := workflow.NewEngine(store)
+	engine.Register(wf)
+	if err := engine.Resume(context.Background(), saved.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the resumed approval", func() bool {
+		return engine.Approve(context.Background(), saved.ID, "manager", "alice") == nil
+	})
+	waitFor(t, "the run to complete", func() bool {
+		final, _ := store.Load(context.Background(), saved.ID)
+		return final.Status == workflow.StatusCompleted && final.Data["_approved"] == true
+	})
+}
+
+func TestAwait_ParallelApprovals(t *testing.T) {
+	legal := workflow.New("legal").AwaitApproval("legal", []string{"alice"}).Then().Build().Steps[0]
+	finance := workflow.New("finance").AwaitApproval("finance", []string{"bob"}).Then().Build().Steps[0]
+	wf := workflow.New("contract").
+		Parallel("review", legal, finance).Then().
+		Build()
+
+	store := workflow.NewMemoryPersistence()
+	engine := workflow.NewEngine(store)
+	engine.Register(wf)
+	id, err := engine.Start(context.Background(), wf.Name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each await has its own pending approval, and approving one does not
+	// resolve the other.
+	waitFor(t, "the finance approval", func() bool {
+		return engine.Approve(context.Background(), id, "finance", "bob") == nil
+	})
+	time.Sleep(20 * time.Millisecond)
+	if state, _ := store.Load(context.Background(), id); state.Status == workflow.StatusCompleted {
+		t.Fatal("Run completed before the legal approval")
+	}
+	waitFor(t, "the legal approval", func() bool {
+		return engine.Approve(context.Background(), id, "legal", "alice") == nil
+	})
+	waitFor(t, "the run to complete", func() bool {
+		final, _ := store.Load(context.Background(), id)
+		return final.Status == workflow.StatusCompleted
+	})
+}
+
+func TestAwait_TimeoutCountsFromFirstWait(t *testing.T) {
+	wf := workflow.New("deadline").
+		AwaitSignal("ship", "shipped").Timeout(time.Hour).Then().
+		Build()
+	store, saved := crashWhileAwaiting(t, wf, workflow.StatusAwaitingSignal)
+
+	// The process comes back after the deadline has passed.
+	engine := workflow.NewEngine(store)
+	engine.SetClock(workflow.NewFakeClock(saved.AwaitingSince.Add(2 * time.Hour)))
+	engine.Register(wf)
+	if err := engine.Resume(context.Background(), saved.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the run to time out", func() bool {
+		final, _ := store.Load(context.Background(), saved.ID)
+		return final.Status == workflow.StatusFailed && strings.Contains(strings.Join(final.Errors, ""), "timed out")
+	})
+}
+
+func TestAwait_InParallelBranchSavesRun(t *testing.T) {
+	ship := workflow.New("ship").AwaitSignal("ship", "shipped").Then().Build().Steps[0]
+	wf := workflow.New("fulfil").
+		Step("charge", func(ctx context.Context, state *workflow.State) (any, error) { return "ok", nil }).
+		Compensate(func(ctx context.Context, state *workflow.State) error { return nil }).
+		Then().
+		Parallel("p", ship).Then().
+		Build()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := workflow.NewMemoryPersistence()
+	engine := workflow.NewEngine(store)
+	engine.Register(wf)
+	id, err := engine.Start(ctx, wf.Name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var saved *workflow.State
+	waitFor(t, "the awaiting run to be saved", func() bool {
+		saved, err = store.Load(context.Background(), id)
+		return err == nil && saved.Status == workflow.StatusAwaitingSignal
+	})
+	if saved.ID != id || saved.AwaitingStep != "ship" || len(saved.Compensations) != 1 || saved.StepResults["charge"] != "ok" {
+		t.Errorf("Expected the run state to be saved, got %+v", saved)
+	}
+	if running, ok := engine.GetState(id); !ok || running.Status != workflow.StatusAwaitingSignal {
+		t.Errorf("Expected GetState to report the await, got %+v", running)
+	}
+
+	engine.SendSignal(context.Background(), "shipped", nil)
+	waitFor(t, "the run to complete", func() bool {
+		final, _ := store.Load(context.Background(), id)
+		return final.Status == workflow.StatusCompleted
+	})
+}
@@ -0,0 +1,171 @@
+// Package workflow_test provides tests for cancelling workflow runs.
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func TestEngine_CancelRunning(t *testing.T) {
+	var mu sync.Mutex
+	var compensated []string
+	compensate := func(name string) func(ctx context.Context, state *workflow.State) error {
+		return func(ctx context.Context, state *workflow.State) error {
+			mu.Lock()
+			defer mu.Unlock()
+			compensated = append(compensated, name)
+			return nil
+		}
+	}
+	noop := func(ctx context.Context, state *workflow.State) (any, error) { return "ok", nil }
+	wf := workflow.New("booking").
+		Step("charge", noop).Compensate(compensate("charge")).Then().
+		Step("reserve", noop).Compensate(compensate("reserve")).Then().
+		AwaitSignal("confirm", "confirmed").Then().
+		Build()
+
+	store := workflow.NewMemoryPersistence()
+	engine := workflow.NewEngine(store)
+	engine.Register(wf)
+	var events []string
+	engine.Subscribe(func(ctx context.Context, ev workflow.RunEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev.Type)
+	})
+	id, err := engine.Start(context.Background(), "booking", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the run to await its signal", func() bool { return engine.AwaitingSummary().Total == 1 })
+
+	if err := engine.Cancel(context.Background(), id, "customer changed plans"); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+
+	mu.Lock()
+	if strings.Join(compensated, ",") != "reserve,charge" {
+		t.Errorf("Expected compensations in reverse order, got %v", compensated)
+	}
+	if len(events) == 0 || events[len(events)-1] != workflow.EventRunCancelled {
+		t.Errorf("Expected a final %s event, got %v", workflow.EventRunCancelled, events)
+	}
+	mu.Unlock()
+
+	state, err := store.Load(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Status != workflow.StatusCancelled {
+		t.Errorf("Expected status cancelled, got %s", state.Status)
+	}
+	if len(state.Errors) == 0 || !strings.Contains(state.Errors[len(state.Errors)-1], "customer changed plans") {
+		t.Errorf("Expected the reason in Errors, got %v", state.Errors)
+	}
+	if _, running := engine.GetState(id); running {
+		t.Error("Run still registered as running")
+	}
+
+	if err := engine.Cancel(context.Background(), id, ""); !errors.Is(err, workflow.ErrRunFinished) {
+		t.Errorf("Expected ErrRunFinished, got %v", err)
+	}
+}
+
+// TestEngine_CancelWhileListing reads running states while a run is
+// cancelled. Run with -race.
+func TestEngine_CancelWhileListing(t *testing.T) {
+	wf := workflow.New("listed").
+		AwaitSignal("confirm", "confirmed").Then().
+		Build()
+	engine := workflow.NewEngine(workflow.NewMemoryPersistence())
+	engine.Register(wf)
+	id, err := engine.Start(context.Background(), "listed", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the run to await its signal", func() bool { return engine.AwaitingSummary().Total == 1 })
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			for _, state := range engine.ListRunning() {
+				state.Summary()
+			}
+		}
+	}()
+
+	err = engine.Cancel(context.Background(), id, "stop")
+	close(stop)
+	readers.Wait()
+	if err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+}
+
+func TestEngine_CancelPersisted(t *testing.T) {
+	wf := workflow.New("order").
+		AwaitSignal("ship", "shipped").Then().
+		Build()
+	store, saved := crashWhileAwaiting(t, wf, workflow.StatusAwaitingSignal)
+
+	engine := workflow.NewEngine(store)
+	engine.Register(wf)
+	if err := engine.Cancel(context.Background(), saved.ID, "abandoned"); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	state, _ := store.Load(context.Background(), saved.ID)
+	if state.Status != workflow.StatusCancelled || state.AwaitingStep != "" || state.CompletedAt.IsZero() {
+		t.Errorf("Unexpected cancelled state: %+v", state)
+	}
+	if n, err := engine.ResumeAwaiting(context.Background()); err != nil || n != 0 {
+		t.Errorf("Expected no runs to resume, got %d (%v)", n, err)
+	}
+
+	if err := engine.Cancel(context.Background(), "missing", ""); !errors.Is(err, workflow.ErrStateNotFound) {
+		t.Errorf("Expected ErrStateNotFound, got %v", err)
+	}
+}
+
+func TestEngine_CancelAfterCompletion(t *testing.T) {
+	wf := workflow.New("quick").Checkpoint("done").Build()
+	engine := workflow.NewEngine(nil)
+	engine.Register(wf)
+
+	// Hold the run between completing and ending, then cancel it there.
+	completed := make(chan struct{})
+	proceed := make(chan struct{})
+	engine.Subscribe(func(ctx context.Context, ev workflow.RunEvent) {
+		if ev.Type == workflow.EventRunCompleted {
+			close(completed)
+			<-proceed
+		}
+	})
+	id, err := engine.Start(context.Background(), "quick", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-completed
+
+	done := make(chan error, 1)
+	go func() { done <- engine.Cancel(context.Background(), id, "too late") }()
+	time.Sleep(20 * time.Millisecond)
+	close(proceed)
+
+	if err := <-done; !errors.Is(err, workflow.ErrRunFinished) || !strings.Contains(err.Error(), string(workflow.StatusCompleted)) {
+		t.Errorf("Expected ErrRunFinished for a completed run, got %v", err)
+	}
+}
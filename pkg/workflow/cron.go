@@ -3,118 +3,506 @@ package workflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/queue"
+	"github.com/nuulab/goflow/pkg/tools"
 )
 
-// Cron manages scheduled workflow executions.
+// ErrScheduleNotFound is returned for a schedule ID the cron does not have.
+var ErrScheduleNotFound = errors.New("workflow: schedule not found")
+
+// Cron manages scheduled workflow executions and job enqueues.
 type Cron struct {
 	engine    *Engine
+	queue     queue.Queue
+	schemas   *queue.JobSchemas
+	secrets   func(name string) (string, bool)
 	schedules map[string]*Schedule
+	store     CronStore
+	loaded    bool // the store's schedules have been merged in
+	logger    core.Logger
 	stop      chan struct{}
 	running   bool
 	mu        sync.RWMutex
 }
 
-// Schedule represents a cron schedule.
+// Schedule represents a cron schedule. It starts WorkflowName or, when
+// JobType is set, enqueues a job of that type. LastError holds the failure
+// of the last trigger, if any. Location is the time zone the expression is
+// evaluated in; nil means the server's local time. Overlap decides what
+// happens when the schedule fires while its last workflow run is still
+// going, and Skipped counts the fires it dropped. Input is rendered as a
+// payload template each fire only when Templated is set.
 type Schedule struct {
 	ID           string
 	WorkflowName string
+	JobType      string
 	Expression   string
+	Location     *time.Location
 	Input        map[string]any
+	Templated    bool
 	Enabled      bool
+	Overlap      OverlapPolicy
+	MaxMissed    int
 	LastRun      time.Time
 	NextRun      time.Time
+	LastError    string
+	Skipped      int
 	parsed       *CronExpression
+	template     *PayloadTemplate
+	running      int         // fires in flight
+	pending      []time.Time // fires queued behind them
+}
+
+// OverlapPolicy decides what a schedule does when it fires while its
+// previous workflow run has not finished. Job schedules only enqueue, so
+// their fires never overlap.
+type OverlapPolicy string
+
+const (
+	// SkipIfRunning drops the fire. It is the default.
+	SkipIfRunning OverlapPolicy = "skip"
+	// AllowConcurrent starts another run alongside.
+	AllowConcurrent OverlapPolicy = "allow"
+	// QueueBehind starts the run when the previous one finishes.
+	QueueBehind OverlapPolicy = "queue"
+)
+
+// ScheduleOption configures a schedule.
+type ScheduleOption func(*Schedule)
+
+// WithOverlap sets the schedule's OverlapPolicy.
+func WithOverlap(policy OverlapPolicy) ScheduleOption {
+	return func(s *Schedule) { s.Overlap = policy }
+}
+
+// WithTemplate makes the schedule's input a payload template (see
+// PayloadTemplate) rendered each time it fires, instead of passing it to
+// every run as is. AddJob payloads are always templates.
+func WithTemplate() ScheduleOption {
+	return func(s *Schedule) { s.Templated = true }
+}
+
+// CatchUp makes Start fire up to maxMissed of the most recent times the
+// schedule missed since its LastRun, oldest first, while the scheduler was
+// down. The fires go through the schedule's OverlapPolicy, so all of them
+// run only with QueueBehind or AllowConcurrent.
+func CatchUp(maxMissed int) ScheduleOption {
+	return func(s *Schedule) { s.MaxMissed = maxMissed }
+}
+
+// CronTemplateScope lists the variables schedule input templates can read
+// in addition to now, today, env and secrets.
+var CronTemplateScope = TemplateScope{
+	"schedule": {"id", "expression"},
 }
 
-// NewCron creates a new cron scheduler.
+// NewCron creates a new cron scheduler. Its run log is kept in memory
+// until SetStore is called.
 func NewCron(engine *Engine) *Cron {
 	return &Cron{
 		engine:    engine,
 		schedules: make(map[string]*Schedule),
+		store:     NewMemoryCronStore(),
+		loaded:    true,
 		stop:      make(chan struct{}),
 	}
 }
 
-// Add adds a scheduled workflow.
-func (c *Cron) Add(id, workflowName, expression string, input map[string]any) error {
-	parsed, err := ParseCron(expression)
-	if err != nil {
-		return fmt.Errorf("invalid cron expression: %w", err)
+// SetQueue sets the queue scheduled jobs are enqueued on. Payloads of job
+// types registered in schemas are validated before they are enqueued;
+// schemas may be nil.
+func (c *Cron) SetQueue(q queue.Queue, schemas *queue.JobSchemas) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queue = q
+	c.schemas = schemas
+}
+
+// SetSecrets sets the resolver for secrets.NAME in input templates.
+func (c *Cron) SetSecrets(fn func(name string) (string, bool)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.secrets = fn
+}
+
+// SetLogger sets the logger failed fires and store errors are reported
+// to. Without one they are only recorded in the schedule's LastError and
+// run log.
+func (c *Cron) SetLogger(logger core.Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logger = logger
+}
+
+// logError reports a failure to the logger, if any.
+func (c *Cron) logError(msg string, args ...any) {
+	c.mu.RLock()
+	logger := c.logger
+	c.mu.RUnlock()
+	if logger != nil {
+		logger.Error(msg, args...)
 	}
+}
 
+// SetStore sets the store schedules and their run log are persisted in,
+// replacing the in-memory default.
+// Start loads it, merging the stored schedules with those already added:
+// stored run state and Enabled win, and the merged set is saved back.
+// From then on Add, Remove, Enable and Disable write through, and every
+// fire updates the stored schedule and appends to its run log. Fires are
+// locked in the store, so replicas sharing it fire each time once.
+func (c *Cron) SetStore(store CronStore) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.store = store
+	c.loaded = false
+}
 
-	schedule := &Schedule{
-		ID:           id,
-		WorkflowName: workflowName,
-		Expression:   expression,
-		Input:        input,
-		Enabled:      true,
-		parsed:       parsed,
-		NextRun:      parsed.Next(time.Now()),
+// Add adds a scheduled workflow. Input is passed to every run as is unless
+// WithTemplate is given.
+func (c *Cron) Add(id, workflowName, expression string, input map[string]any, opts ...ScheduleOption) error {
+	_, err := c.add(&Schedule{ID: id, WorkflowName: workflowName, Expression: expression, Input: input}, opts)
+	return err
+}
+
+// AddWithLocation adds a scheduled workflow whose expression is evaluated
+// in loc, so "0 9 * * *" fires at 9:00 there across DST changes. An
+// expression may instead start with "CRON_TZ=America/New_York ".
+func (c *Cron) AddWithLocation(id, workflowName, expression string, input map[string]any, loc *time.Location, opts ...ScheduleOption) error {
+	_, err := c.add(&Schedule{ID: id, WorkflowName: workflowName, Expression: expression, Input: input, Location: loc}, opts)
+	return err
+}
+
+// AddJob adds a schedule that enqueues a job of jobType with the rendered
+// payload template. The template is checked against the job type's
+// registered schema: problems that would fail every run are returned as
+// an error, likely mistakes as warnings.
+func (c *Cron) AddJob(id, jobType, expression string, payload map[string]any, opts ...ScheduleOption) (warnings []string, err error) {
+	return c.add(&Schedule{ID: id, JobType: jobType, Expression: expression, Input: payload, Templated: true}, opts)
+}
+
+func (c *Cron) add(schedule *Schedule, opts []ScheduleOption) ([]string, error) {
+	schedule.Overlap = SkipIfRunning
+	for _, opt := range opts {
+		opt(schedule)
+	}
+	switch schedule.Overlap {
+	case SkipIfRunning, AllowConcurrent, QueueBehind:
+	default:
+		return nil, fmt.Errorf("cron: unknown overlap policy %q", schedule.Overlap)
 	}
 
-	c.schedules[id] = schedule
-	return nil
+	parsed, err := ParseCron(schedule.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression: %w", err)
+	}
+	switch {
+	case schedule.Location == nil:
+		schedule.Location = parsed.location
+	case parsed.location == nil:
+		parsed.location = schedule.Location
+	case parsed.location.String() != schedule.Location.String():
+		return nil, fmt.Errorf("invalid cron expression: CRON_TZ=%s conflicts with location %s", parsed.location, schedule.Location)
+	}
+	var tmpl *PayloadTemplate
+	var warnings []string
+	c.mu.Lock()
+	if schedule.Templated {
+		if tmpl, warnings, err = c.compile(schedule.JobType, schedule.Input); err != nil {
+			c.mu.Unlock()
+			return warnings, err
+		}
+	}
+
+	schedule.Enabled = true
+	schedule.parsed = parsed
+	schedule.template = tmpl
+	schedule.NextRun = parsed.Next(time.Now())
+	c.schedules[schedule.ID] = schedule
+	c.mu.Unlock()
+
+	return warnings, c.persist(context.Background(), schedule)
+}
+
+// CheckTemplate reports whether AddJob, or Add with WithTemplate, would
+// accept input as the template of a schedule, without adding one. jobType
+// is empty for a workflow schedule.
+func (c *Cron) CheckTemplate(jobType string, input map[string]any) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, warnings, err := c.compile(jobType, input)
+	return warnings, err
+}
+
+// compile compiles an input template and checks it against the job type's
+// schema, if any. The caller must hold c.mu.
+func (c *Cron) compile(jobType string, input map[string]any) (*PayloadTemplate, []string, error) {
+	tmpl, err := CompilePayloadTemplate(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	var schema *tools.Schema
+	if jobType != "" && c.schemas != nil {
+		if s, ok := c.schemas.Get(jobType); ok {
+			schema = &s
+		}
+	}
+	warnings, err := tmpl.Check(CronTemplateScope, schema)
+	return tmpl, warnings, err
 }
 
 // Remove removes a scheduled workflow.
-func (c *Cron) Remove(id string) {
+func (c *Cron) Remove(id string) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
 	delete(c.schedules, id)
+	store := c.loadedStore()
+	c.mu.Unlock()
+	if store == nil {
+		return nil
+	}
+	return store.DeleteSchedule(context.Background(), id)
 }
 
 // Enable enables a schedule.
-func (c *Cron) Enable(id string) {
+func (c *Cron) Enable(id string) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	if s, ok := c.schedules[id]; ok {
+	s, ok := c.schedules[id]
+	if ok {
 		s.Enabled = true
 		s.NextRun = s.parsed.Next(time.Now())
 	}
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrScheduleNotFound, id)
+	}
+	return c.persist(context.Background(), s)
 }
 
 // Disable disables a schedule.
-func (c *Cron) Disable(id string) {
+func (c *Cron) Disable(id string) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	if s, ok := c.schedules[id]; ok {
+	s, ok := c.schedules[id]
+	if ok {
 		s.Enabled = false
 	}
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrScheduleNotFound, id)
+	}
+	return c.persist(context.Background(), s)
+}
+
+// Runs returns up to limit of a schedule's runs from the store, newest
+// first.
+func (c *Cron) Runs(ctx context.Context, id string, limit int) ([]CronRun, error) {
+	c.mu.RLock()
+	store := c.store
+	c.mu.RUnlock()
+	return store.Runs(ctx, id, limit)
+}
+
+// NextRuns returns the next n times a schedule fires from now, whether or
+// not it is enabled.
+func (c *Cron) NextRuns(id string, n int) ([]time.Time, error) {
+	c.mu.RLock()
+	schedule, ok := c.schedules[id]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrScheduleNotFound, id)
+	}
+	var times []time.Time
+	for t := time.Now(); len(times) < n; {
+		if t = schedule.parsed.Next(t); t.IsZero() {
+			break
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// loadedStore returns the store once Start has loaded it, or nil. The
+// caller must hold c.mu.
+func (c *Cron) loadedStore() CronStore {
+	if !c.loaded {
+		return nil
+	}
+	return c.store
+}
+
+// persist writes schedule through to the store.
+func (c *Cron) persist(ctx context.Context, schedule *Schedule) error {
+	c.mu.RLock()
+	store := c.loadedStore()
+	record := schedule.record()
+	c.mu.RUnlock()
+	if store == nil {
+		return nil
+	}
+	return store.SaveSchedule(ctx, record)
+}
+
+// record returns the stored form of s. The caller must hold the Cron's
+// lock.
+func (s *Schedule) record() *ScheduleRecord {
+	record := &ScheduleRecord{
+		ID:         s.ID,
+		Workflow:   s.WorkflowName,
+		JobType:    s.JobType,
+		Expression: s.Expression,
+		Input:      s.Input,
+		Template:   s.Templated,
+		Enabled:    s.Enabled,
+		Overlap:    s.Overlap,
+		CatchUp:    s.MaxMissed,
+		LastRun:    s.LastRun,
+		NextRun:    s.NextRun,
+		LastError:  s.LastError,
+		Skipped:    s.Skipped,
+	}
+	if s.Location != nil {
+		record.Location = s.Location.String()
+	}
+	return record
 }
 
-// List returns all schedules.
+// load merges the stored schedules into c and saves the result. A stored
+// schedule that no longer adds, say because its job schema changed, is
+// reported and left in the store.
+func (c *Cron) load(ctx context.Context) error {
+	records, err := c.store.ListSchedules(ctx)
+	if err != nil {
+		return fmt.Errorf("cron: load schedules: %w", err)
+	}
+	now := time.Now()
+	for _, record := range records {
+		c.mu.RLock()
+		schedule, ok := c.schedules[record.ID]
+		c.mu.RUnlock()
+		if !ok {
+			if schedule, err = c.restore(record); err != nil {
+				c.logError("cron: stored schedule is invalid", "schedule", record.ID, "error", err)
+				continue
+			}
+		}
+		c.mu.Lock()
+		schedule.Enabled = record.Enabled
+		schedule.LastRun = record.LastRun
+		schedule.LastError = record.LastError
+		schedule.Skipped = record.Skipped
+		schedule.NextRun = schedule.parsed.Next(now)
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	c.loaded = true
+	schedules := make([]*Schedule, 0, len(c.schedules))
+	for _, s := range c.schedules {
+		schedules = append(schedules, s)
+	}
+	c.mu.Unlock()
+	for _, s := range schedules {
+		if err := c.persist(ctx, s); err != nil {
+			return fmt.Errorf("cron: save schedule %s: %w", s.ID, err)
+		}
+	}
+	return nil
+}
+
+// restore adds a schedule from its stored record.
+func (c *Cron) restore(record *ScheduleRecord) (*Schedule, error) {
+	schedule := &Schedule{
+		ID:           record.ID,
+		WorkflowName: record.Workflow,
+		JobType:      record.JobType,
+		Expression:   record.Expression,
+		Input:        record.Input,
+		Templated:    record.Template,
+	}
+	if record.Location != "" {
+		loc, err := time.LoadLocation(record.Location)
+		if err != nil {
+			return nil, err
+		}
+		schedule.Location = loc
+	}
+	opts := []ScheduleOption{CatchUp(record.CatchUp)}
+	if record.Overlap != "" {
+		opts = append(opts, WithOverlap(record.Overlap))
+	}
+	if _, err := c.add(schedule, opts); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// Get returns a copy of a schedule by ID. The scheduler keeps updating
+// its own; call Get again for fresh run state.
+func (c *Cron) Get(id string) (*Schedule, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s, ok := c.schedules[id]
+	if !ok {
+		return nil, false
+	}
+	return s.snapshot(), true
+}
+
+// List returns copies of all schedules.
 func (c *Cron) List() []*Schedule {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	schedules := make([]*Schedule, 0, len(c.schedules))
 	for _, s := range c.schedules {
-		schedules = append(schedules, s)
+		schedules = append(schedules, s.snapshot())
 	}
 	return schedules
 }
 
-// Start starts the cron scheduler.
-func (c *Cron) Start(ctx context.Context) {
+// snapshot returns a copy of s. The caller must hold the Cron's lock.
+func (s *Schedule) snapshot() *Schedule {
+	cp := *s
+	cp.Input = maps.Clone(s.Input)
+	cp.pending = slices.Clone(s.pending)
+	return &cp
+}
+
+// Start starts the cron scheduler, first loading the store if one is set.
+func (c *Cron) Start(ctx context.Context) error {
 	c.mu.Lock()
 	if c.running {
 		c.mu.Unlock()
-		return
+		return nil
 	}
-	c.running = true
-	c.stop = make(chan struct{})
+	load := c.store != nil && !c.loaded
 	c.mu.Unlock()
 
+	if load {
+		if err := c.load(ctx); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		return nil
+	}
+	c.running = true
+	c.stop = make(chan struct{})
 	go c.run(ctx)
+	return nil
 }
 
 // Stop stops the cron scheduler.
@@ -132,6 +520,8 @@ func (c *Cron) run(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
+	c.catchUp(ctx, time.Now())
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -154,8 +544,7 @@ func (c *Cron) checkSchedules(ctx context.Context, now time.Time) {
 		}
 
 		if now.After(schedule.NextRun) || now.Equal(schedule.NextRun) {
-			// Trigger workflow
-			go c.triggerWorkflow(ctx, schedule)
+			c.dispatch(ctx, schedule, schedule.NextRun)
 
 			// Update schedule
 			schedule.LastRun = now
@@ -164,36 +553,233 @@ func (c *Cron) checkSchedules(ctx context.Context, now time.Time) {
 	}
 }
 
-func (c *Cron) triggerWorkflow(ctx context.Context, schedule *Schedule) {
-	input := make(map[string]any)
-	for k, v := range schedule.Input {
-		input[k] = v
+// catchUp fires the times each CatchUp schedule missed between its
+// LastRun and now.
+func (c *Cron) catchUp(ctx context.Context, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, schedule := range c.schedules {
+		if !schedule.Enabled || schedule.MaxMissed <= 0 || schedule.LastRun.IsZero() {
+			continue
+		}
+		var missed []time.Time
+		for t := schedule.parsed.Next(schedule.LastRun); !t.IsZero() && !t.After(now); t = schedule.parsed.Next(t) {
+			missed = append(missed, t)
+			if len(missed) > schedule.MaxMissed {
+				missed = missed[1:]
+			}
+		}
+		for _, at := range missed {
+			c.dispatch(ctx, schedule, at)
+			schedule.LastRun = at
+		}
+		schedule.NextRun = schedule.parsed.Next(now)
+	}
+}
+
+// dispatch fires schedule in the background according to its
+// OverlapPolicy. The caller must hold c.mu.
+func (c *Cron) dispatch(ctx context.Context, schedule *Schedule, at time.Time) {
+	if schedule.running > 0 {
+		switch schedule.Overlap {
+		case AllowConcurrent:
+		case QueueBehind:
+			schedule.pending = append(schedule.pending, at)
+			return
+		default:
+			schedule.Skipped++
+			return
+		}
 	}
-	input["_cron_schedule_id"] = schedule.ID
-	input["_cron_triggered_at"] = time.Now()
+	schedule.running++
+	go c.execute(ctx, schedule, at)
+}
 
-	_, err := c.engine.Start(ctx, schedule.WorkflowName, input)
+// execute fires schedule, waits for the workflow run unless overlapping
+// runs are allowed, then fires the next queued time, if any.
+func (c *Cron) execute(ctx context.Context, schedule *Schedule, at time.Time) {
+	for {
+		if c.claim(ctx, schedule, at) {
+			runID, err := c.fire(ctx, schedule, at)
+			if err == nil && runID != "" && schedule.Overlap != AllowConcurrent {
+				c.engine.waitRun(ctx, runID)
+			}
+		}
+
+		c.mu.Lock()
+		if len(schedule.pending) == 0 || ctx.Err() != nil {
+			schedule.running--
+			c.mu.Unlock()
+			return
+		}
+		at = schedule.pending[0]
+		schedule.pending = schedule.pending[1:]
+		c.mu.Unlock()
+	}
+}
+
+// claim locks the fire of schedule due at at in the store, if any. A
+// fire that cannot be locked is not made.
+func (c *Cron) claim(ctx context.Context, schedule *Schedule, at time.Time) bool {
+	c.mu.RLock()
+	store := c.loadedStore()
+	c.mu.RUnlock()
+	if store == nil {
+		return true
+	}
+	ok, err := store.Lock(ctx, schedule.ID, at, cronLockTTL)
 	if err != nil {
-		// Log error (in production, use proper logging)
-		fmt.Printf("Cron: failed to start workflow %s: %v\n", schedule.WorkflowName, err)
+		c.mu.Lock()
+		schedule.LastError = fmt.Sprintf("cron: lock: %v", err)
+		c.mu.Unlock()
+		c.logError("cron: schedule not fired", "schedule", schedule.ID, "error", err)
+	}
+	return ok && err == nil
+}
+
+// Trigger fires a schedule immediately, outside its cron expression.
+func (c *Cron) Trigger(ctx context.Context, id string) error {
+	_, err := c.TriggerNow(ctx, id)
+	return err
+}
+
+// TriggerNow fires a schedule immediately and returns the ID of the
+// workflow run it started, or "" for a job schedule. The fire is logged,
+// but LastRun and NextRun are left alone, and paused schedules fire too.
+func (c *Cron) TriggerNow(ctx context.Context, id string) (string, error) {
+	c.mu.RLock()
+	schedule, ok := c.schedules[id]
+	c.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrScheduleNotFound, id)
 	}
+	return c.fire(ctx, schedule, time.Now())
+}
+
+// fire renders the schedule's input and starts its workflow or enqueues
+// its job, recording the outcome on the schedule and in the store. It
+// returns the ID of the workflow run it started.
+func (c *Cron) fire(ctx context.Context, schedule *Schedule, at time.Time) (string, error) {
+	runID, err := c.trigger(ctx, schedule, at)
+	run := CronRun{ScheduleID: schedule.ID, At: at, RunID: runID}
+	c.mu.Lock()
+	schedule.LastError = ""
+	if err != nil {
+		schedule.LastError = err.Error()
+		run.Error = err.Error()
+	}
+	store := c.loadedStore()
+	c.mu.Unlock()
+	if err != nil {
+		c.logError("cron: schedule failed", "schedule", schedule.ID, "error", err)
+	}
+
+	if store != nil {
+		storeErr := store.AppendRun(ctx, run)
+		if storeErr == nil {
+			storeErr = c.persist(ctx, schedule)
+		}
+		if storeErr != nil {
+			c.mu.Lock()
+			if schedule.LastError == "" {
+				schedule.LastError = fmt.Sprintf("cron: save: %v", storeErr)
+			}
+			c.mu.Unlock()
+			c.logError("cron: schedule not saved", "schedule", schedule.ID, "error", storeErr)
+		}
+	}
+	return runID, err
+}
+
+func (c *Cron) trigger(ctx context.Context, schedule *Schedule, at time.Time) (string, error) {
+	c.mu.RLock()
+	q, schemas, secrets := c.queue, c.schemas, c.secrets
+	c.mu.RUnlock()
+
+	input := make(map[string]any, len(schedule.Input)+2)
+	if schedule.template == nil {
+		maps.Copy(input, schedule.Input)
+	} else {
+		var err error
+		input, err = schedule.template.Render(TriggerContext{
+			Time: at,
+			Vars: map[string]any{
+				"schedule": map[string]any{"id": schedule.ID, "expression": schedule.Expression},
+			},
+			Secrets: secrets,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if schedule.JobType != "" {
+		if q == nil {
+			return "", fmt.Errorf("cron: no queue set for job %s", schedule.JobType)
+		}
+		if schemas != nil {
+			if err := schemas.Validate(schedule.JobType, input); err != nil {
+				return "", err
+			}
+		}
+		job, err := queue.NewJob(schedule.JobType, input)
+		if err != nil {
+			return "", err
+		}
+		job.WithMetadata("cron_schedule_id", schedule.ID)
+		return "", q.Enqueue(ctx, job)
+	}
+
+	input["_cron_schedule_id"] = schedule.ID
+	input["_cron_triggered_at"] = at
+	runID, err := c.engine.Start(ctx, schedule.WorkflowName, input)
+	if err != nil {
+		return "", fmt.Errorf("cron: failed to start workflow %s: %w", schedule.WorkflowName, err)
+	}
+	return runID, nil
 }
 
 // ============ Cron Expression Parser ============
 
 // CronExpression represents a parsed cron expression.
 type CronExpression struct {
+	second     []int // 0-59, nil unless given
 	minute     []int // 0-59
 	hour       []int // 0-23
 	dayOfMonth []int // 1-31
 	month      []int // 1-12
 	dayOfWeek  []int // 0-6 (Sunday = 0)
+	domRules   []dayRule
+	dowRules   []dayRule
+	location   *time.Location
 }
 
+// dayRule matches a day by its place in the month.
+type dayRule func(t time.Time) bool
+
 // ParseCron parses a cron expression.
 // Supports: * */n n n-m n,m
-// Format: minute hour day-of-month month day-of-week
+// Format: [second] minute hour day-of-month month day-of-week
+// The seconds field is optional and detected by field count. Day of month
+// also takes L (last day), LW (last weekday) and nW (weekday nearest the
+// nth); day of week takes nL (last n-day of the month) and n#k (kth n-day).
+// A "CRON_TZ=<zone> " prefix evaluates the expression in that IANA zone.
 func ParseCron(expression string) (*CronExpression, error) {
+	if rest, ok := strings.CutPrefix(expression, "CRON_TZ="); ok {
+		zone, spec, _ := strings.Cut(rest, " ")
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("time zone: %w", err)
+		}
+		expr, err := ParseCron(strings.TrimSpace(spec))
+		if err != nil {
+			return nil, err
+		}
+		expr.location = loc
+		return expr, nil
+	}
+
 	// Handle special expressions
 	switch expression {
 	case "@yearly", "@annually":
@@ -215,13 +801,21 @@ func ParseCron(expression string) (*CronExpression, error) {
 	}
 
 	parts := strings.Fields(expression)
-	if len(parts) != 5 {
-		return nil, fmt.Errorf("expected 5 fields, got %d", len(parts))
+	if len(parts) != 5 && len(parts) != 6 {
+		return nil, fmt.Errorf("expected 5 or 6 fields, got %d", len(parts))
 	}
 
 	expr := &CronExpression{}
 	var err error
 
+	if len(parts) == 6 {
+		expr.second, err = parseField(parts[0], 0, 59)
+		if err != nil {
+			return nil, fmt.Errorf("second: %w", err)
+		}
+		parts = parts[1:]
+	}
+
 	expr.minute, err = parseField(parts[0], 0, 59)
 	if err != nil {
 		return nil, fmt.Errorf("minute: %w", err)
@@ -232,7 +826,7 @@ func ParseCron(expression string) (*CronExpression, error) {
 		return nil, fmt.Errorf("hour: %w", err)
 	}
 
-	expr.dayOfMonth, err = parseField(parts[2], 1, 31)
+	expr.dayOfMonth, expr.domRules, err = parseDayOfMonth(parts[2])
 	if err != nil {
 		return nil, fmt.Errorf("day of month: %w", err)
 	}
@@ -242,7 +836,7 @@ func ParseCron(expression string) (*CronExpression, error) {
 		return nil, fmt.Errorf("month: %w", err)
 	}
 
-	expr.dayOfWeek, err = parseField(parts[4], 0, 6)
+	expr.dayOfWeek, expr.dowRules, err = parseDayOfWeek(parts[4])
 	if err != nil {
 		return nil, fmt.Errorf("day of week: %w", err)
 	}
@@ -256,11 +850,27 @@ func parseEvery(duration string) (*CronExpression, error) {
 		return nil, fmt.Errorf("invalid duration: %w", err)
 	}
 
-	minutes := int(d.Minutes())
-	if minutes <= 0 {
-		return nil, fmt.Errorf("duration must be at least 1 minute")
+	if d < time.Minute {
+		seconds := int(d.Seconds())
+		if seconds <= 0 {
+			return nil, fmt.Errorf("duration must be at least 1 second")
+		}
+		secs := make([]int, 0)
+		for i := 0; i < 60; i += seconds {
+			secs = append(secs, i)
+		}
+		return &CronExpression{
+			second:     secs,
+			minute:     makeRange(0, 59),
+			hour:       makeRange(0, 23),
+			dayOfMonth: makeRange(1, 31),
+			month:      makeRange(1, 12),
+			dayOfWeek:  makeRange(0, 6),
+		}, nil
 	}
 
+	minutes := int(d.Minutes())
+
 	if minutes < 60 {
 		// Every N minutes
 		mins := make([]int, 0)
@@ -306,6 +916,9 @@ func parseField(field string, min, max int) ([]int, error) {
 		if err != nil {
 			return nil, err
 		}
+		if step <= 0 {
+			return nil, fmt.Errorf("invalid step: %s", field)
+		}
 		values := make([]int, 0)
 		for i := min; i <= max; i += step {
 			values = append(values, i)
@@ -329,6 +942,9 @@ func parseField(field string, min, max int) ([]int, error) {
 			if err != nil {
 				return nil, err
 			}
+			if start < min || end > max {
+				return nil, fmt.Errorf("range %s out of range [%d, %d]", part, min, max)
+			}
 			for i := start; i <= end; i++ {
 				values = append(values, i)
 			}
@@ -352,6 +968,97 @@ func parseField(field string, min, max int) ([]int, error) {
 	return values, nil
 }
 
+// parseDayOfMonth parses a day-of-month field with the L, LW and nW
+// extensions.
+func parseDayOfMonth(field string) ([]int, []dayRule, error) {
+	var plain []string
+	var rules []dayRule
+	for _, part := range strings.Split(field, ",") {
+		switch {
+		case part == "L":
+			rules = append(rules, func(t time.Time) bool { return t.Day() == daysIn(t) })
+		case part == "LW":
+			rules = append(rules, func(t time.Time) bool { return t.Day() == nearestWeekday(t, daysIn(t)) })
+		case strings.HasSuffix(part, "W"):
+			day, err := strconv.Atoi(strings.TrimSuffix(part, "W"))
+			if err != nil || day < 1 || day > 31 {
+				return nil, nil, fmt.Errorf("invalid weekday: %s", part)
+			}
+			rules = append(rules, func(t time.Time) bool { return t.Day() == nearestWeekday(t, day) })
+		default:
+			plain = append(plain, part)
+		}
+	}
+	if len(plain) == 0 {
+		return nil, rules, nil
+	}
+	values, err := parseField(strings.Join(plain, ","), 1, 31)
+	return values, rules, err
+}
+
+// parseDayOfWeek parses a day-of-week field with the nL and n#k
+// extensions.
+func parseDayOfWeek(field string) ([]int, []dayRule, error) {
+	var plain []string
+	var rules []dayRule
+	for _, part := range strings.Split(field, ",") {
+		if day, nth, ok := strings.Cut(part, "#"); ok {
+			weekday, err := strconv.Atoi(day)
+			k, kerr := strconv.Atoi(nth)
+			if err != nil || kerr != nil || weekday < 0 || weekday > 6 || k < 1 || k > 5 {
+				return nil, nil, fmt.Errorf("invalid nth weekday: %s", part)
+			}
+			rules = append(rules, func(t time.Time) bool {
+				return int(t.Weekday()) == weekday && (t.Day()-1)/7+1 == k
+			})
+			continue
+		}
+		if day, ok := strings.CutSuffix(part, "L"); ok {
+			weekday, err := strconv.Atoi(day)
+			if err != nil || weekday < 0 || weekday > 6 {
+				return nil, nil, fmt.Errorf("invalid last weekday: %s", part)
+			}
+			rules = append(rules, func(t time.Time) bool {
+				return int(t.Weekday()) == weekday && t.Day()+7 > daysIn(t)
+			})
+			continue
+		}
+		plain = append(plain, part)
+	}
+	if len(plain) == 0 {
+		return nil, rules, nil
+	}
+	values, err := parseField(strings.Join(plain, ","), 0, 6)
+	return values, rules, err
+}
+
+// daysIn returns the number of days in t's month.
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// nearestWeekday returns the weekday of t's month nearest to day, without
+// leaving the month, or 0 if the month has no such day.
+func nearestWeekday(t time.Time, day int) int {
+	last := daysIn(t)
+	if day > last {
+		return 0
+	}
+	switch time.Date(t.Year(), t.Month(), day, 0, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return 3
+		}
+		return day - 1
+	case time.Sunday:
+		if day == last {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
+
 func makeRange(min, max int) []int {
 	values := make([]int, max-min+1)
 	for i := range values {
@@ -360,21 +1067,64 @@ func makeRange(min, max int) []int {
 	return values
 }
 
-// Next returns the next time that matches the cron expression.
+// Location returns the time zone set with CRON_TZ, or nil.
+func (c *CronExpression) Location() *time.Location {
+	return c.location
+}
+
+// Next returns the next time that matches the cron expression, in the
+// expression's location or else from's. Wall-clock times skipped by a DST
+// change fire when the clocks jump; repeated ones fire once, the first time.
 func (c *CronExpression) Next(from time.Time) time.Time {
-	// Start from the next minute
-	t := from.Add(time.Minute).Truncate(time.Minute)
+	loc := c.location
+	if loc == nil {
+		loc = from.Location()
+	}
+	from = from.In(loc)
 
-	for i := 0; i < 366*24*60; i++ { // Search up to 1 year
-		if c.matches(t) {
-			return t
+	// Walk wall-clock minutes, converting only the matches. Seconds are
+	// only tried within matching minutes, starting with from's own.
+	seconds := c.second
+	if seconds == nil {
+		seconds = []int{0}
+	}
+	wall := wallClock(from)
+	for i := 0; i <= 366*24*60; i++ { // Search up to 1 year
+		if i > 0 {
+			wall = wall.Add(time.Minute)
+		}
+		if !c.matches(wall) {
+			continue
+		}
+		for _, sec := range seconds {
+			t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), sec, 0, loc)
+			if !wallClock(t).Equal(wall) {
+				t = gapEnd(t, wall)
+			}
+			if t.After(from) {
+				return t
+			}
 		}
-		t = t.Add(time.Minute)
 	}
 
 	return time.Time{} // No match found
 }
 
+// wallClock returns t's wall-clock minute as a UTC time.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+// gapEnd returns the first instant after the DST gap that skipped wall;
+// near is time.Date's normalization of wall.
+func gapEnd(near, wall time.Time) time.Time {
+	t := near.Add(-48 * time.Hour).Truncate(time.Minute)
+	for !wallClock(t).After(wall) {
+		t = t.Add(time.Minute)
+	}
+	return t
+}
+
 func (c *CronExpression) matches(t time.Time) bool {
 	if !contains(c.minute, t.Minute()) {
 		return false
@@ -382,18 +1132,27 @@ func (c *CronExpression) matches(t time.Time) bool {
 	if !contains(c.hour, t.Hour()) {
 		return false
 	}
-	if !contains(c.dayOfMonth, t.Day()) {
+	if !contains(c.dayOfMonth, t.Day()) && !matchesAny(c.domRules, t) {
 		return false
 	}
 	if !contains(c.month, int(t.Month())) {
 		return false
 	}
-	if !contains(c.dayOfWeek, int(t.Weekday())) {
+	if !contains(c.dayOfWeek, int(t.Weekday())) && !matchesAny(c.dowRules, t) {
 		return false
 	}
 	return true
 }
 
+func matchesAny(rules []dayRule, t time.Time) bool {
+	for _, rule := range rules {
+		if rule(t) {
+			return true
+		}
+	}
+	return false
+}
+
 func contains(values []int, v int) bool {
 	for _, val := range values {
 		if val == v {
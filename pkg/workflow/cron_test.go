@@ -0,0 +1,428 @@
+// Package workflow_test provides tests for cron scheduling.
+package workflow_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+	"github.com/nuulab/goflow/pkg/workflow/workflowtest"
+)
+
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	return loc
+}
+
+func TestCronExpression_Location(t *testing.T) {
+	loc := newYork(t)
+	expr, err := workflow.ParseCron("CRON_TZ=America/New_York 0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr.Location().String() != "America/New_York" {
+		t.Fatalf("Expected the CRON_TZ location, got %v", expr.Location())
+	}
+
+	// 12:00 UTC is 8:00 in New York in winter and 7:00 in summer.
+	next := expr.Next(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 1, 15, 9, 0, 0, 0, loc); !next.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, next)
+	}
+	next = expr.Next(time.Date(2026, 7, 15, 12, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 7, 15, 13, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("Expected 9:00 EDT (%v), got %v", want, next)
+	}
+
+	if _, err := workflow.ParseCron("CRON_TZ=Nowhere/Atlantis 0 9 * * *"); err == nil {
+		t.Error("Expected an unknown time zone to fail")
+	}
+}
+
+func TestCronExpression_DST(t *testing.T) {
+	loc := newYork(t)
+	expr, err := workflow.ParseCron("CRON_TZ=America/New_York 30 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		from time.Time
+		want time.Time
+	}{
+		// 2:00 EST jumps to 3:00 EDT; 2:30 is skipped and fires at 3:00.
+		{"spring forward", time.Date(2026, 3, 8, 0, 0, 0, 0, loc), time.Date(2026, 3, 8, 3, 0, 0, 0, loc)},
+		{"after spring forward", time.Date(2026, 3, 8, 3, 0, 0, 0, loc), time.Date(2026, 3, 9, 2, 30, 0, 0, loc)},
+		// 2:00 EDT falls back to 1:00 EST; 2:30 EST happens once.
+		{"fall back", time.Date(2026, 11, 1, 0, 0, 0, 0, loc), time.Date(2026, 11, 1, 7, 30, 0, 0, time.UTC)},
+		{"after fall back", time.Date(2026, 11, 1, 7, 30, 0, 0, time.UTC), time.Date(2026, 11, 2, 2, 30, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expr.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+
+	// 1:30 happens twice on fall-back day and fires only the first time.
+	repeated, _ := workflow.ParseCron("CRON_TZ=America/New_York 30 1 * * *")
+	first := repeated.Next(time.Date(2026, 11, 1, 0, 0, 0, 0, loc))
+	if want := time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC); !first.Equal(want) {
+		t.Errorf("Expected 1:30 EDT (%v), got %v", want, first)
+	}
+	if second := repeated.Next(first); !second.Equal(time.Date(2026, 11, 2, 1, 30, 0, 0, loc)) {
+		t.Errorf("Expected the next run on Nov 2, got %v", second)
+	}
+}
+
+func TestCronExpression_Seconds(t *testing.T) {
+	at := func(day, hour, min, sec int) time.Time { return time.Date(2026, 10, day, hour, min, sec, 0, time.UTC) }
+	tests := []struct {
+		expr     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"*/30 * * * * *", at(15, 10, 0, 15), at(15, 10, 0, 30)},
+		{"*/30 * * * * *", at(15, 10, 0, 30), at(15, 10, 1, 0)},
+		{"15 30 9 * * *", at(15, 9, 30, 20), at(16, 9, 30, 15)},
+		{"@every 20s", at(15, 10, 0, 45), at(15, 10, 1, 0)},
+		{"0 9 * * *", at(15, 9, 0, 0), at(16, 9, 0, 0)},
+	}
+	for _, tt := range tests {
+		expr, err := workflow.ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+		if got := expr.Next(tt.from); !got.Equal(tt.expected) {
+			t.Errorf("%q: Next(%v) = %v, want %v", tt.expr, tt.from, got, tt.expected)
+		}
+	}
+}
+
+func TestCronExpression_DayExtensions(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		expr     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"0 0 L * *", day(2026, 2, 1), day(2026, 2, 28)},
+		{"0 0 LW * *", day(2026, 2, 1), day(2026, 2, 27)},      // the 28th is a Saturday
+		{"0 0 1W * *", day(2026, 7, 31), day(2026, 8, 3)},      // the 1st is a Saturday
+		{"0 0 15W * *", day(2026, 11, 1), day(2026, 11, 16)},   // the 15th is a Sunday
+		{"0 0 31W * *", day(2026, 4, 1), day(2026, 5, 29)},     // April has no 31st
+		{"0 0 0 * * 1#2", day(2026, 10, 1), day(2026, 10, 12)}, // seconds and nth weekday
+		{"0 0 * * 5L", day(2026, 10, 1), day(2026, 10, 30)},    // last Friday
+		{"0 0 1,L * *", day(2026, 10, 2), day(2026, 10, 31)},   // mixed with plain days
+		{"0 0 * * 0,6#1", day(2026, 10, 1), day(2026, 10, 3)},  // first Saturday
+	}
+	for _, tt := range tests {
+		expr, err := workflow.ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+		if got := expr.Next(tt.from); !got.Equal(tt.expected) {
+			t.Errorf("%q: Next(%v) = %v, want %v", tt.expr, tt.from, got, tt.expected)
+		}
+	}
+
+	for _, bad := range []string{"* * * *", "0 0 * * * * *", "0 0 32W * *", "0 0 * * 7L", "0 0 * * 1#6", "0 0 * * L", "60 * * * * *"} {
+		if _, err := workflow.ParseCron(bad); err == nil {
+			t.Errorf("Expected %q to fail", bad)
+		}
+	}
+}
+
+func TestCronExpression_InvalidSteps(t *testing.T) {
+	for _, bad := range []string{"*/0 * * * *", "*/-5 * * * *", "0 */0 * * *", "*/0 * * * * *", "0-2000000000 * * * *"} {
+		done := make(chan error, 1)
+		go func() {
+			_, err := workflow.ParseCron(bad)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("Expected %q to fail", bad)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("ParseCron(%q) did not return", bad)
+		}
+	}
+}
+
+func TestCron_AddWithLocation(t *testing.T) {
+	loc := newYork(t)
+	cron := workflow.NewCron(nil)
+	if err := cron.AddWithLocation("standup", "standup", "0 9 * * 1-5", nil, loc); err != nil {
+		t.Fatal(err)
+	}
+	schedule, _ := cron.Get("standup")
+	if schedule.Location != loc || schedule.NextRun.Location().String() != "America/New_York" || schedule.NextRun.Hour() != 9 {
+		t.Errorf("Expected NextRun at 9:00 New York time, got %v", schedule.NextRun)
+	}
+
+	if err := cron.AddWithLocation("clash", "standup", "CRON_TZ=Europe/Paris 0 9 * * *", nil, loc); err == nil {
+		t.Error("Expected conflicting time zones to fail")
+	}
+	if err := cron.Add("paris", "standup", "CRON_TZ=Europe/Paris 0 9 * * *", nil); err != nil {
+		t.Fatal(err)
+	}
+	if paris, _ := cron.Get("paris"); paris.Location == nil || paris.Location.String() != "Europe/Paris" {
+		t.Errorf("Expected the CRON_TZ location on the schedule, got %v", paris.Location)
+	}
+}
+
+// cronRuns starts a cron whose "report" schedule missed its last three
+// minutes, and returns how many runs started and the most that overlapped.
+func cronRuns(t *testing.T, opts ...workflow.ScheduleOption) (*workflow.Schedule, int32, int32) {
+	t.Helper()
+	var started, active, peak atomic.Int32
+	wf := workflow.New("report").
+		Step("build", func(ctx context.Context, state *workflow.State) (any, error) {
+			started.Add(1)
+			n := active.Add(1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(30 * time.Millisecond)
+			active.Add(-1)
+			return nil, nil
+		}).Then().
+		Build()
+	engine := workflow.NewEngine(nil)
+	engine.Register(wf)
+
+	cron := workflow.NewCron(engine)
+	if err := cron.Add("report", "report", "* * * * *", nil, append([]workflow.ScheduleOption{workflow.CatchUp(3)}, opts...)...); err != nil {
+		t.Fatal(err)
+	}
+	// A stored LastRun makes Start catch up on the missed minutes.
+	store := workflow.NewMemoryCronStore()
+	store.SaveSchedule(context.Background(), &workflow.ScheduleRecord{
+		ID:      "report",
+		Enabled: true,
+		LastRun: time.Now().Add(-3*time.Minute - 30*time.Second),
+	})
+	cron.SetStore(store)
+
+	cron.Start(context.Background())
+	defer cron.Stop()
+	time.Sleep(150 * time.Millisecond)
+	schedule, _ := cron.Get("report")
+	return schedule, started.Load(), peak.Load()
+}
+
+func TestCron_Overlap(t *testing.T) {
+	t.Run("skip if running", func(t *testing.T) {
+		schedule, started, _ := cronRuns(t)
+		if started != 1 || schedule.Skipped != 2 {
+			t.Errorf("Expected 1 run and 2 skipped, got %d and %d", started, schedule.Skipped)
+		}
+	})
+	t.Run("allow concurrent", func(t *testing.T) {
+		_, started, peak := cronRuns(t, workflow.WithOverlap(workflow.AllowConcurrent))
+		if started != 3 || peak < 2 {
+			t.Errorf("Expected 3 overlapping runs, got %d peaking at %d", started, peak)
+		}
+	})
+	t.Run("queue behind", func(t *testing.T) {
+		_, started, peak := cronRuns(t, workflow.WithOverlap(workflow.QueueBehind))
+		if started != 3 || peak != 1 {
+			t.Errorf("Expected 3 runs one at a time, got %d peaking at %d", started, peak)
+		}
+	})
+	t.Run("unknown policy", func(t *testing.T) {
+		if err := workflow.NewCron(nil).Add("x", "report", "@hourly", nil, workflow.WithOverlap("never")); err == nil {
+			t.Error("Expected an unknown policy to fail")
+		}
+	})
+}
+
+func TestCron_CatchUpLimit(t *testing.T) {
+	schedule, started, _ := cronRuns(t, workflow.WithOverlap(workflow.QueueBehind), workflow.CatchUp(2))
+	if started != 2 {
+		t.Errorf("Expected the 2 most recent missed times, got %d runs", started)
+	}
+	if !schedule.NextRun.After(time.Now()) {
+		t.Errorf("Expected NextRun in the future, got %v", schedule.NextRun)
+	}
+}
+
+func TestMemoryCronStore(t *testing.T) {
+	workflowtest.RunCronStore(t, func(t *testing.T) workflow.CronStore {
+		return workflow.NewMemoryCronStore()
+	})
+}
+
+func TestCron_Store(t *testing.T) {
+	ctx := context.Background()
+	engine := workflow.NewEngine(nil)
+	engine.Register(workflow.New("report").Checkpoint("done").Build())
+	store := workflow.NewMemoryCronStore()
+
+	first := workflow.NewCron(engine)
+	first.SetStore(store)
+	if err := first.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Add("added", "report", "@hourly", map[string]any{"x": 1}, workflow.WithOverlap(workflow.QueueBehind)); err != nil {
+		t.Fatal(err)
+	}
+	first.Add("paused", "report", "@daily", nil)
+	if err := first.Disable("paused"); err != nil {
+		t.Fatal(err)
+	}
+	first.Add("removed", "report", "@daily", nil)
+	if err := first.Remove("removed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Trigger(ctx, "added"); err != nil {
+		t.Fatal(err)
+	}
+	first.Stop()
+
+	runs, err := first.Runs(ctx, "added", 0)
+	if err != nil || len(runs) != 1 || runs[0].RunID == "" || runs[0].Error != "" {
+		t.Fatalf("Expected one logged run, got %+v (%v)", runs, err)
+	}
+
+	// A restart re-adds "paused" in code; the stored schedules win.
+	second := workflow.NewCron(engine)
+	second.SetStore(store)
+	second.Add("paused", "report", "@daily", nil)
+	if err := second.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer second.Stop()
+	added, ok := second.Get("added")
+	if !ok || added.Overlap != workflow.QueueBehind || added.Input["x"] != float64(1) || !added.Enabled {
+		t.Errorf("Expected the added schedule to be restored, got %+v", added)
+	}
+	if paused, _ := second.Get("paused"); paused.Enabled {
+		t.Error("Expected the paused schedule to stay disabled")
+	}
+	if _, ok := second.Get("removed"); ok {
+		t.Error("Expected the removed schedule to stay removed")
+	}
+}
+
+// appendFailingStore is a CronStore whose run log cannot be written.
+type appendFailingStore struct{ workflow.CronStore }
+
+func (appendFailingStore) AppendRun(context.Context, workflow.CronRun) error {
+	return errors.New("store unavailable")
+}
+
+func TestCron_LogsStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	engine := workflow.NewEngine(nil)
+	engine.Register(workflow.New("report").Checkpoint("done").Build())
+
+	var logs bytes.Buffer
+	cron := workflow.NewCron(engine)
+	cron.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	cron.SetStore(appendFailingStore{workflow.NewMemoryCronStore()})
+	if err := cron.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer cron.Stop()
+	cron.Add("hourly", "report", "@hourly", nil)
+
+	if err := cron.Trigger(ctx, "hourly"); err != nil {
+		t.Fatal(err)
+	}
+	schedule, _ := cron.Get("hourly")
+	if !strings.Contains(schedule.LastError, "store unavailable") {
+		t.Errorf("Expected the save failure in LastError, got %q", schedule.LastError)
+	}
+	if !strings.Contains(logs.String(), "schedule not saved") || !strings.Contains(logs.String(), "schedule=hourly") {
+		t.Errorf("Expected the save failure to be logged, got %q", logs.String())
+	}
+}
+
+func TestCron_StoreReplicas(t *testing.T) {
+	ctx := context.Background()
+	var started atomic.Int32
+	engine := workflow.NewEngine(nil)
+	engine.Register(workflow.New("report").
+		Step("build", func(ctx context.Context, state *workflow.State) (any, error) {
+			started.Add(1)
+			return nil, nil
+		}).Then().
+		Build())
+
+	store := workflow.NewMemoryCronStore()
+	store.SaveSchedule(ctx, &workflow.ScheduleRecord{
+		ID: "shared", Workflow: "report", Expression: "* * * * *", Enabled: true,
+		CatchUp: 1, LastRun: time.Now().Add(-90 * time.Second),
+	})
+	for range 2 {
+		replica := workflow.NewCron(engine)
+		replica.SetStore(store)
+		if err := replica.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+		defer replica.Stop()
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if n := started.Load(); n != 1 {
+		t.Errorf("Expected one replica to fire the missed run, got %d runs", n)
+	}
+	records, _ := store.ListSchedules(ctx)
+	if len(records) != 1 || time.Since(records[0].LastRun) > time.Minute {
+		t.Errorf("Expected LastRun to be saved after the fire, got %+v", records)
+	}
+}
+
+func TestCron_NextRunsAndTriggerNow(t *testing.T) {
+	ctx := context.Background()
+	engine := workflow.NewEngine(nil)
+	engine.Register(workflow.New("report").Checkpoint("done").Build())
+	cron := workflow.NewCron(engine)
+	cron.Add("hourly", "report", "@hourly", nil)
+	cron.Disable("hourly")
+
+	times, err := cron.NextRuns("hourly", 3)
+	if err != nil || len(times) != 3 {
+		t.Fatalf("Expected 3 fire times for a disabled schedule, got %v (%v)", times, err)
+	}
+	for i, at := range times {
+		if at.Minute() != 0 || (i > 0 && at.Sub(times[i-1]) != time.Hour) {
+			t.Errorf("Unexpected fire times: %v", times)
+		}
+	}
+
+	runID, err := cron.TriggerNow(ctx, "hourly")
+	if err != nil || runID == "" {
+		t.Fatalf("Expected a run ID, got %q (%v)", runID, err)
+	}
+	runs, _ := cron.Runs(ctx, "hourly", 1)
+	if len(runs) != 1 || runs[0].RunID != runID {
+		t.Errorf("Expected the trigger in the run log, got %+v", runs)
+	}
+
+	if _, err := cron.NextRuns("missing", 1); !errors.Is(err, workflow.ErrScheduleNotFound) {
+		t.Errorf("Expected ErrScheduleNotFound, got %v", err)
+	}
+	if _, err := cron.TriggerNow(ctx, "missing"); !errors.Is(err, workflow.ErrScheduleNotFound) {
+		t.Errorf("Expected ErrScheduleNotFound, got %v", err)
+	}
+	if err := cron.Enable("missing"); !errors.Is(err, workflow.ErrScheduleNotFound) {
+		t.Errorf("Expected ErrScheduleNotFound, got %v", err)
+	}
+}
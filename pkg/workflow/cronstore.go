@@ -0,0 +1,270 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ============ Cron Store ============
+
+// cronRunLogSize is how many runs a CronStore keeps per schedule.
+const cronRunLogSize = 100
+
+// cronLockTTL is how long a fire's lock outlives it. It only has to cover
+// clock skew between scheduler replicas.
+const cronLockTTL = 10 * time.Minute
+
+// CronStore persists cron schedules and their run log so they survive
+// scheduler restarts, and locks each fire so that only one of several
+// scheduler replicas performs it. Implementations must be safe for
+// concurrent use.
+type CronStore interface {
+	// SaveSchedule creates or replaces a schedule.
+	SaveSchedule(ctx context.Context, record *ScheduleRecord) error
+	// DeleteSchedule removes a schedule and its run log. Deleting a
+	// missing schedule is not an error.
+	DeleteSchedule(ctx context.Context, id string) error
+	// ListSchedules returns all schedules sorted by ID.
+	ListSchedules(ctx context.Context) ([]*ScheduleRecord, error)
+
+	// AppendRun adds run to its schedule's log, dropping the oldest runs
+	// past the first hundred.
+	AppendRun(ctx context.Context, run CronRun) error
+	// Runs returns up to limit runs of a schedule, newest first. A limit
+	// of zero or less returns all of them.
+	Runs(ctx context.Context, scheduleID string, limit int) ([]CronRun, error)
+
+	// Lock claims the fire of a schedule due at at for ttl. It reports
+	// false if another replica claimed it first.
+	Lock(ctx context.Context, scheduleID string, at time.Time, ttl time.Duration) (bool, error)
+}
+
+// ScheduleRecord is the stored form of a Schedule.
+type ScheduleRecord struct {
+	ID         string         `json:"id"`
+	Workflow   string         `json:"workflow,omitempty"`
+	JobType    string         `json:"job_type,omitempty"`
+	Expression string         `json:"expression"`
+	Location   string         `json:"location,omitempty"`
+	Input      map[string]any `json:"input,omitempty"`
+	Template   bool           `json:"template,omitempty"`
+	Enabled    bool           `json:"enabled"`
+	Overlap    OverlapPolicy  `json:"overlap,omitempty"`
+	CatchUp    int            `json:"catch_up,omitempty"`
+	LastRun    time.Time      `json:"last_run,omitempty"`
+	NextRun    time.Time      `json:"next_run,omitempty"`
+	LastError  string         `json:"last_error,omitempty"`
+	Skipped    int            `json:"skipped,omitempty"`
+}
+
+// CronRun is one fire of a schedule: the time it was due, the workflow run
+// it started and the error it failed with, if any.
+type CronRun struct {
+	ScheduleID string    `json:"schedule_id"`
+	At         time.Time `json:"at"`
+	RunID      string    `json:"run_id,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ============ Redis Cron Store ============
+
+// RedisCronStore stores cron schedules in Redis/DragonflyDB: a hash of
+// schedules, a capped list of runs per schedule and a SET NX key per fire.
+type RedisCronStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisCronStore creates a Redis cron store keeping keys under prefix,
+// such as "goflow:cron".
+func NewRedisCronStore(client *redis.Client, prefix string) *RedisCronStore {
+	return &RedisCronStore{client: client, prefix: prefix}
+}
+
+func (s *RedisCronStore) schedulesKey() string     { return s.prefix + ":schedules" }
+func (s *RedisCronStore) runsKey(id string) string { return s.prefix + ":runs:" + id }
+
+func (s *RedisCronStore) lockKey(id string, at time.Time) string {
+	return s.prefix + ":lock:" + id + ":" + strconv.FormatInt(at.Unix(), 10)
+}
+
+// SaveSchedule saves a schedule.
+func (s *RedisCronStore) SaveSchedule(ctx context.Context, record *ScheduleRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, s.schedulesKey(), record.ID, data).Err()
+}
+
+// DeleteSchedule removes a schedule and its run log.
+func (s *RedisCronStore) DeleteSchedule(ctx context.Context, id string) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, s.schedulesKey(), id)
+		pipe.Del(ctx, s.runsKey(id))
+		return nil
+	})
+	return err
+}
+
+// ListSchedules lists all schedules.
+func (s *RedisCronStore) ListSchedules(ctx context.Context) ([]*ScheduleRecord, error) {
+	all, err := s.client.HGetAll(ctx, s.schedulesKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*ScheduleRecord, 0, len(all))
+	for id, data := range all {
+		var record ScheduleRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			return nil, fmt.Errorf("workflow: decode schedule %s: %w", id, err)
+		}
+		records = append(records, &record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records, nil
+}
+
+// AppendRun adds a run to its schedule's log.
+func (s *RedisCronStore) AppendRun(ctx context.Context, run CronRun) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	key := s.runsKey(run.ScheduleID)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, data)
+		pipe.LTrim(ctx, key, 0, cronRunLogSize-1)
+		return nil
+	})
+	return err
+}
+
+// Runs returns a schedule's runs, newest first.
+func (s *RedisCronStore) Runs(ctx context.Context, scheduleID string, limit int) ([]CronRun, error) {
+	all, err := s.client.LRange(ctx, s.runsKey(scheduleID), 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]CronRun, len(all))
+	for i, data := range all {
+		if err := json.Unmarshal([]byte(data), &runs[i]); err != nil {
+			return nil, fmt.Errorf("workflow: decode run of schedule %s: %w", scheduleID, err)
+		}
+	}
+	return runs, nil
+}
+
+// Lock claims a fire with SET NX.
+func (s *RedisCronStore) Lock(ctx context.Context, scheduleID string, at time.Time, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, s.lockKey(scheduleID, at), 1, ttl).Result()
+}
+
+// ============ Memory Cron Store ============
+
+// MemoryCronStore stores cron schedules in memory. Useful for testing;
+// its locks only exclude Cron instances sharing the store.
+type MemoryCronStore struct {
+	schedules map[string][]byte
+	runs      map[string][]CronRun // newest last
+	locks     map[string]time.Time // expiry
+	mu        sync.Mutex
+}
+
+// NewMemoryCronStore creates an in-memory cron store.
+func NewMemoryCronStore() *MemoryCronStore {
+	return &MemoryCronStore{
+		schedules: make(map[string][]byte),
+		runs:      make(map[string][]CronRun),
+		locks:     make(map[string]time.Time),
+	}
+}
+
+// SaveSchedule saves a schedule.
+func (s *MemoryCronStore) SaveSchedule(ctx context.Context, record *ScheduleRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schedules[record.ID] = data
+	return nil
+}
+
+// DeleteSchedule removes a schedule and its run log.
+func (s *MemoryCronStore) DeleteSchedule(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.schedules, id)
+	delete(s.runs, id)
+	return nil
+}
+
+// ListSchedules lists all schedules.
+func (s *MemoryCronStore) ListSchedules(ctx context.Context) ([]*ScheduleRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]*ScheduleRecord, 0, len(s.schedules))
+	for _, data := range s.schedules {
+		var record ScheduleRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, &record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records, nil
+}
+
+// AppendRun adds a run to its schedule's log.
+func (s *MemoryCronStore) AppendRun(ctx context.Context, run CronRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := append(s.runs[run.ScheduleID], run)
+	if len(runs) > cronRunLogSize {
+		runs = runs[len(runs)-cronRunLogSize:]
+	}
+	s.runs[run.ScheduleID] = runs
+	return nil
+}
+
+// Runs returns a schedule's runs, newest first.
+func (s *MemoryCronStore) Runs(ctx context.Context, scheduleID string, limit int) ([]CronRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := s.runs[scheduleID]
+	if limit <= 0 || limit > len(all) {
+		limit = len(all)
+	}
+	runs := make([]CronRun, 0, limit)
+	for i := len(all) - 1; i >= len(all)-limit; i-- {
+		runs = append(runs, all[i])
+	}
+	return runs, nil
+}
+
+// Lock claims a fire.
+func (s *MemoryCronStore) Lock(ctx context.Context, scheduleID string, at time.Time, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, expiry := range s.locks {
+		if now.After(expiry) {
+			delete(s.locks, key)
+		}
+	}
+	key := scheduleID + ":" + strconv.FormatInt(at.Unix(), 10)
+	if _, held := s.locks[key]; held {
+		return false, nil
+	}
+	s.locks[key] = now.Add(ttl)
+	return true, nil
+}
@@ -0,0 +1,777 @@
+// Package workflow provides declarative workflow definitions.
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Spec is a workflow definition described as data. LoadDefinition builds a
+// Workflow from its YAML or JSON form and ExportDefinition produces it. Its
+// JSON form can be stored as the Spec of a Definition.
+//
+//	name: expense
+//	steps:
+//	  - name: review
+//	    type: condition
+//	    if: data.amount > 1000
+//	    then:
+//	      - {name: approve, type: await, approvers: [finance]}
+//	  - {name: pay, type: action, handler: pay_expense}
+type Spec struct {
+	Name    string     `yaml:"name" json:"name"`
+	Version string     `yaml:"version,omitempty" json:"version,omitempty"`
+	Tools   []string   `yaml:"tools,omitempty" json:"tools,omitempty"`
+	Timeout string     `yaml:"timeout,omitempty" json:"timeout,omitempty"` // see Builder.Timeout
+	Steps   []StepSpec `yaml:"steps" json:"steps"`
+}
+
+// StepSpec is one step of a Spec. Type selects the kind of
+// step; only the fields of that kind may be set. Conditions are expression
+// scripts (see Expr) over data, results, id and workflow_id, and durations
+// use time.ParseDuration syntax.
+type StepSpec struct {
+	Name string   `yaml:"name" json:"name"`
+	Type StepType `yaml:"type" json:"type"`
+
+	// action
+	Handler     string     `yaml:"handler,omitempty" json:"handler,omitempty"`
+	Compensate  string     `yaml:"compensate,omitempty" json:"compensate,omitempty"`
+	Retry       *RetrySpec `yaml:"retry,omitempty" json:"retry,omitempty"`
+	Timeout     string     `yaml:"timeout,omitempty" json:"timeout,omitempty"` // action and await
+	OnErrorGoto string     `yaml:"on_error_goto,omitempty" json:"on_error_goto,omitempty"`
+	Output      string     `yaml:"output,omitempty" json:"output,omitempty"` // input is shared with subworkflow
+
+	// condition
+	If     string       `yaml:"if,omitempty" json:"if,omitempty"`
+	Then   []StepSpec   `yaml:"then,omitempty" json:"then,omitempty"`
+	ElseIf []BranchSpec `yaml:"else_if,omitempty" json:"else_if,omitempty"`
+	Else   []StepSpec   `yaml:"else,omitempty" json:"else,omitempty"`
+
+	// loop
+	ForEach       string `yaml:"for_each,omitempty" json:"for_each,omitempty"`
+	While         string `yaml:"while,omitempty" json:"while,omitempty"`
+	BreakWhen     string `yaml:"break_when,omitempty" json:"break_when,omitempty"`
+	MaxIterations int    `yaml:"max_iterations,omitempty" json:"max_iterations,omitempty"`
+
+	// loop body and parallel branches
+	Steps []StepSpec `yaml:"steps,omitempty" json:"steps,omitempty"`
+
+	// parallel: wait is "all" (the default) or "any"; wait_count waits for
+	// that many branches instead. merge is "last_wins" (the default),
+	// "error" or "collect".
+	Wait      string `yaml:"wait,omitempty" json:"wait,omitempty"`
+	WaitCount int    `yaml:"wait_count,omitempty" json:"wait_count,omitempty"`
+	Merge     string `yaml:"merge,omitempty" json:"merge,omitempty"`
+
+	// await: exactly one of signal and approvers
+	Signal      string           `yaml:"signal,omitempty" json:"signal,omitempty"`
+	Approvers   []string         `yaml:"approvers,omitempty" json:"approvers,omitempty"`
+	OnTimeout   string           `yaml:"on_timeout,omitempty" json:"on_timeout,omitempty"`
+	Escalations []EscalationSpec `yaml:"escalations,omitempty" json:"escalations,omitempty"`
+
+	// sleep
+	Duration string `yaml:"duration,omitempty" json:"duration,omitempty"`
+
+	// timer: until is an RFC 3339 time; cron is a cron expression
+	Until string `yaml:"until,omitempty" json:"until,omitempty"`
+	Cron  string `yaml:"cron,omitempty" json:"cron,omitempty"`
+
+	// subworkflow: a workflow registered with HandlerRegistry.RegisterWorkflow.
+	// input is also accepted by action steps.
+	Workflow string         `yaml:"workflow,omitempty" json:"workflow,omitempty"`
+	Input    map[string]any `yaml:"input,omitempty" json:"input,omitempty"`
+	Async    bool           `yaml:"async,omitempty" json:"async,omitempty"`
+
+	// wait_child: the async subworkflow step to join
+	Child string `yaml:"child,omitempty" json:"child,omitempty"`
+
+	// transform
+	Script   string `yaml:"script,omitempty" json:"script,omitempty"`
+	MaxSteps int    `yaml:"max_steps,omitempty" json:"max_steps,omitempty"`
+}
+
+// BranchSpec is an else-if branch of a condition step.
+type BranchSpec struct {
+	If    string     `yaml:"if" json:"if"`
+	Steps []StepSpec `yaml:"steps" json:"steps"`
+}
+
+// RetrySpec is the retry policy of an action step. Unset fields keep
+// the defaults of NewRetryPolicy.
+type RetrySpec struct {
+	Attempts     int     `yaml:"attempts,omitempty" json:"attempts,omitempty"`
+	InitialDelay string  `yaml:"initial_delay,omitempty" json:"initial_delay,omitempty"`
+	MaxDelay     string  `yaml:"max_delay,omitempty" json:"max_delay,omitempty"`
+	Multiplier   float64 `yaml:"multiplier,omitempty" json:"multiplier,omitempty"`
+	Jitter       float64 `yaml:"jitter,omitempty" json:"jitter,omitempty"`
+	FullJitter   bool    `yaml:"full_jitter,omitempty" json:"full_jitter,omitempty"`
+	Budget       string  `yaml:"budget,omitempty" json:"budget,omitempty"`
+}
+
+// EscalationSpec is an escalation of an await step.
+type EscalationSpec struct {
+	After     string         `yaml:"after" json:"after"`
+	Action    EscalationKind `yaml:"action" json:"action"`
+	Message   string         `yaml:"message,omitempty" json:"message,omitempty"`
+	Approvers []string       `yaml:"approvers,omitempty" json:"approvers,omitempty"`
+}
+
+// DefinitionError reports a problem with a definition. Path locates the
+// offending step, as in steps[2].then[0].
+type DefinitionError struct {
+	Path string
+	Step string
+	Msg  string
+}
+
+func (e *DefinitionError) Error() string {
+	if e.Step != "" {
+		return fmt.Sprintf("%s (%s): %s", e.Path, e.Step, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Msg)
+}
+
+// HandlerRegistry resolves the names a definition refers to: action
+// handlers, compensations and sub-workflows.
+type HandlerRegistry struct {
+	mu            sync.RWMutex
+	handlers      map[string]ActionHandler
+	compensations map[string]func(ctx context.Context, state *State) error
+	workflows     map[string]*Workflow
+}
+
+// NewHandlerRegistry creates an empty registry.
+func NewHandlerRegistry() *HandlerRegistry {
+	return &HandlerRegistry{
+		handlers:      make(map[string]ActionHandler),
+		compensations: make(map[string]func(ctx context.Context, state *State) error),
+		workflows:     make(map[string]*Workflow),
+	}
+}
+
+// Register makes handler available to action steps as name.
+func (r *HandlerRegistry) Register(name string, handler ActionHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[name] = handler
+}
+
+// RegisterCompensation makes handler available to the compensate field of
+// action steps as name.
+func (r *HandlerRegistry) RegisterCompensation(name string, handler func(ctx context.Context, state *State) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.compensations[name] = handler
+}
+
+// RegisterWorkflow makes wf available to subworkflow steps by its name.
+func (r *HandlerRegistry) RegisterWorkflow(wf *Workflow) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workflows[wf.Name] = wf
+}
+
+// LoadDefinition builds a workflow from a YAML or JSON definition. Every
+// problem found is reported as a *DefinitionError, joined into one error.
+func LoadDefinition(data []byte, handlers *HandlerRegistry) (*Workflow, error) {
+	var def Spec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&def); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("workflow definition: document is empty")
+		}
+		return nil, fmt.Errorf("workflow definition: %w", err)
+	}
+	if handlers == nil {
+		handlers = NewHandlerRegistry()
+	}
+
+	l := &definitionLoader{handlers: handlers, names: make(map[string]string)}
+	if def.Name == "" {
+		l.fail("name", "", "workflow name is required")
+	}
+	if len(def.Steps) == 0 {
+		l.fail("steps", "", "a workflow needs at least one step")
+	}
+	var timeout time.Duration
+	if def.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(def.Timeout); err != nil {
+			l.fail("timeout", "", "%v", err)
+		}
+	}
+	steps := l.steps("steps", def.Steps)
+	for _, g := range l.gotos {
+		if !slices.ContainsFunc(def.Steps, func(s StepSpec) bool { return s.Name == g.target }) {
+			l.fail(g.path, g.step, "on_error_goto: %q is not a top-level step", g.target)
+		}
+	}
+	if len(l.errs) > 0 {
+		return nil, fmt.Errorf("workflow definition %s: %w", def.Name, errors.Join(l.errs...))
+	}
+
+	b := New(def.Name)
+	if def.Version != "" {
+		b.Version(def.Version)
+	}
+	if len(def.Tools) > 0 {
+		b.Tools(def.Tools...)
+	}
+	if timeout > 0 {
+		b.Timeout(timeout)
+	}
+	wf := b.Build()
+	wf.Steps = steps
+	if err := wf.validateChildren(); err != nil {
+		return nil, err
+	}
+	return wf, nil
+}
+
+// mergeStrategies maps the merge values of parallel steps to strategies.
+var mergeStrategies = map[string]MergeStrategy{
+	"last_wins": MergeLastWins,
+	"error":     MergeError,
+	"collect":   MergeCollect,
+}
+
+// definitionLoader builds steps and collects the errors found on the way.
+type definitionLoader struct {
+	handlers *HandlerRegistry
+	names    map[string]string // step name to the path that defined it
+	gotos    []pendingGoto
+	errs     []error
+}
+
+// pendingGoto is an on_error_goto checked once the top-level steps are known.
+type pendingGoto struct {
+	path, step, target string
+}
+
+func (l *definitionLoader) fail(path, step, format string, args ...any) {
+	l.errs = append(l.errs, &DefinitionError{Path: path, Step: step, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (l *definitionLoader) steps(path string, defs []StepSpec) []Step {
+	steps := make([]Step, 0, len(defs))
+	for i, def := range defs {
+		if step := l.step(fmt.Sprintf("%s[%d]", path, i), def); step != nil {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// stepFields lists the fields each step type accepts besides name and type.
+var stepFields = map[StepType][]string{
+	StepTypeAction:      {"handler", "compensate", "retry", "timeout", "on_error_goto", "input", "output"},
+	StepTypeCondition:   {"if", "then", "else_if", "else"},
+	StepTypeLoop:        {"for_each", "while", "break_when", "max_iterations", "steps"},
+	StepTypeParallel:    {"steps", "wait", "wait_count", "merge"},
+	StepTypeAwait:       {"signal", "approvers", "timeout", "on_timeout", "escalations"},
+	StepTypeSleep:       {"duration"},
+	StepTypeTimer:       {"until", "cron"},
+	StepTypeSubWorkflow: {"workflow", "input", "async"},
+	StepTypeWaitChild:   {"child"},
+	StepTypeCheckpoint:  {},
+	StepTypeTransform:   {"script", "max_steps"},
+}
+
+// setFields returns the names of the kind-specific fields set on def.
+func (def StepSpec) setFields() []string {
+	var set []string
+	for name, ok := range map[string]bool{
+		"handler": def.Handler != "", "compensate": def.Compensate != "",
+		"retry": def.Retry != nil, "timeout": def.Timeout != "", "on_error_goto": def.OnErrorGoto != "",
+		"output": def.Output != "", "if": def.If != "",
+		"then": def.Then != nil, "else_if": def.ElseIf != nil, "else": def.Else != nil,
+		"for_each": def.ForEach != "", "while": def.While != "", "break_when": def.BreakWhen != "",
+		"max_iterations": def.MaxIterations != 0, "steps": def.Steps != nil,
+		"wait": def.Wait != "", "wait_count": def.WaitCount != 0, "merge": def.Merge != "",
+		"signal": def.Signal != "", "approvers": def.Approvers != nil,
+		"on_timeout": def.OnTimeout != "", "escalations": def.Escalations != nil,
+		"duration": def.Duration != "", "until": def.Until != "", "cron": def.Cron != "",
+		"workflow": def.Workflow != "", "input": def.Input != nil,
+		"async": def.Async, "child": def.Child != "",
+		"script": def.Script != "", "max_steps": def.MaxSteps != 0,
+	} {
+		if ok {
+			set = append(set, name)
+		}
+	}
+	sort.Strings(set)
+	return set
+}
+
+func (l *definitionLoader) step(path string, def StepSpec) Step {
+	if def.Name == "" {
+		l.fail(path, "", "step name is required")
+		return nil
+	}
+	if prev, ok := l.names[def.Name]; ok {
+		l.fail(path, def.Name, "step name is already used by %s", prev)
+	} else {
+		l.names[def.Name] = path
+	}
+	allowed, ok := stepFields[def.Type]
+	if def.Type == "" {
+		l.fail(path, def.Name, "step type is required")
+		return nil
+	}
+	if !ok {
+		l.fail(path, def.Name, "unknown step type %q", def.Type)
+		return nil
+	}
+	for _, field := range def.setFields() {
+		if !containsString(allowed, field) {
+			l.fail(path, def.Name, "field %s does not apply to %s steps", field, def.Type)
+		}
+	}
+
+	switch def.Type {
+	case StepTypeAction:
+		return l.action(path, def)
+	case StepTypeCondition:
+		return l.condition(path, def)
+	case StepTypeLoop:
+		return l.loop(path, def)
+	case StepTypeParallel:
+		return l.parallel(path, def)
+	case StepTypeAwait:
+		return l.await(path, def)
+	case StepTypeSleep:
+		if def.Duration == "" {
+			l.fail(path, def.Name, "sleep steps need a duration")
+		}
+		return &SleepStep{name: def.Name, duration: l.duration(path, def.Name, "duration", def.Duration)}
+	case StepTypeTimer:
+		return l.timer(path, def)
+	case StepTypeSubWorkflow:
+		l.handlers.mu.RLock()
+		sub, ok := l.handlers.workflows[def.Workflow]
+		l.handlers.mu.RUnlock()
+		if !ok {
+			l.fail(path, def.Name, "unknown workflow %q", def.Workflow)
+		}
+		return &SubWorkflowStep{name: def.Name, workflow: sub, input: def.Input, async: def.Async}
+	case StepTypeWaitChild:
+		if def.Child == "" {
+			l.fail(path, def.Name, "wait_child steps need a child")
+		}
+		return &WaitForChildStep{name: def.Name, child: def.Child}
+	case StepTypeCheckpoint:
+		return &CheckpointStep{name: def.Name}
+	default: // StepTypeTransform
+		expr := l.expr(path, def.Name, "script", def.Script)
+		if expr != nil && def.MaxSteps > 0 {
+			expr.MaxSteps = def.MaxSteps
+		}
+		return &TransformStep{name: def.Name, expr: expr}
+	}
+}
+
+func (l *definitionLoader) action(path string, def StepSpec) Step {
+	l.handlers.mu.RLock()
+	handler, ok := l.handlers.handlers[def.Handler]
+	compensation, compOK := l.handlers.compensations[def.Compensate]
+	l.handlers.mu.RUnlock()
+	if !ok {
+		l.fail(path, def.Name, "unknown handler %q", def.Handler)
+	}
+	if def.Compensate != "" && !compOK {
+		l.fail(path, def.Name, "unknown compensation %q", def.Compensate)
+	}
+
+	step := &ActionStep{
+		name:             def.Name,
+		handler:          handler,
+		compensation:     compensation,
+		timeout:          l.duration(path, def.Name, "timeout", def.Timeout),
+		onErrorGoto:      def.OnErrorGoto,
+		output:           def.Output,
+		handlerName:      def.Handler,
+		compensationName: def.Compensate,
+	}
+	if def.Input != nil {
+		step.input = compileStepInput(def.Input)
+		if step.input.err != nil {
+			l.fail(path, def.Name, "input: %v", step.input.err)
+		}
+	}
+	if def.OnErrorGoto != "" {
+		l.gotos = append(l.gotos, pendingGoto{path: path, step: def.Name, target: def.OnErrorGoto})
+	}
+	if r := def.Retry; r != nil {
+		policy := NewRetryPolicy()
+		if r.Attempts > 0 {
+			policy.MaxAttempts = r.Attempts
+		}
+		if d := l.duration(path, def.Name, "retry.initial_delay", r.InitialDelay); d > 0 {
+			policy.InitialDelay = d
+		}
+		if d := l.duration(path, def.Name, "retry.max_delay", r.MaxDelay); d > 0 {
+			policy.MaxDelay = d
+		}
+		if r.Multiplier > 0 {
+			policy.Multiplier = r.Multiplier
+		}
+		if r.Jitter < 0 || r.Jitter > 1 {
+			l.fail(path, def.Name, "retry.jitter must be between 0 and 1")
+		}
+		policy.Jitter, policy.FullJitter = r.Jitter, r.FullJitter
+		policy.Budget = l.duration(path, def.Name, "retry.budget", r.Budget)
+		step.retryPolicy = policy
+	}
+	return step
+}
+
+func (l *definitionLoader) condition(path string, def StepSpec) Step {
+	if def.If == "" {
+		l.fail(path, def.Name, "condition steps need an if expression")
+	}
+	step := &ConditionStep{
+		name:         def.Name,
+		condition:    l.condFunc(path, def.Name, "if", def.If),
+		thenSteps:    l.steps(path+".then", def.Then),
+		elseSteps:    l.steps(path+".else", def.Else),
+		conditionSrc: def.If,
+	}
+	for i, branch := range def.ElseIf {
+		branchPath := fmt.Sprintf("%s.else_if[%d]", path, i)
+		step.elifConds = append(step.elifConds, l.condFunc(branchPath, def.Name, "if", branch.If))
+		step.elifSteps = append(step.elifSteps, l.steps(branchPath+".steps", branch.Steps))
+		step.elifSrcs = append(step.elifSrcs, branch.If)
+	}
+	return step
+}
+
+func (l *definitionLoader) loop(path string, def StepSpec) Step {
+	if def.ForEach != "" && def.While != "" {
+		l.fail(path, def.Name, "loop steps take for_each or while, not both")
+	}
+	if def.ForEach == "" && def.While == "" && def.BreakWhen == "" && def.MaxIterations == 0 {
+		l.fail(path, def.Name, "loop never ends: set for_each, while, break_when or max_iterations")
+	}
+	if def.MaxIterations < 0 {
+		l.fail(path, def.Name, "max_iterations must not be negative")
+	}
+	step := &LoopStep{
+		name:          def.Name,
+		steps:         l.steps(path+".steps", def.Steps),
+		forEachKey:    def.ForEach,
+		maxIterations: def.MaxIterations,
+		whileSrc:      def.While,
+		breakSrc:      def.BreakWhen,
+	}
+	if def.While != "" {
+		step.whileCondition = l.condFunc(path, def.Name, "while", def.While)
+	}
+	if def.BreakWhen != "" {
+		step.breakCondition = l.condFunc(path, def.Name, "break_when", def.BreakWhen)
+	}
+	return step
+}
+
+func (l *definitionLoader) parallel(path string, def StepSpec) Step {
+	step := &ParallelStep{name: def.Name, steps: l.steps(path+".steps", def.Steps)}
+	switch {
+	case def.WaitCount != 0:
+		if def.Wait != "" {
+			l.fail(path, def.Name, "parallel steps take wait or wait_count, not both")
+		}
+		if def.WaitCount < 1 || def.WaitCount > len(def.Steps) {
+			l.fail(path, def.Name, "wait_count must be between 1 and %d", len(def.Steps))
+		}
+		step.waitStrategy, step.waitCount = WaitCount, def.WaitCount
+	case def.Wait == "any":
+		step.waitStrategy = WaitAny
+	case def.Wait != "" && def.Wait != "all":
+		l.fail(path, def.Name, "wait must be all or any, got %q", def.Wait)
+	}
+	if strategy, ok := mergeStrategies[def.Merge]; ok {
+		step.mergeStrategy = strategy
+	} else if def.Merge != "" {
+		l.fail(path, def.Name, "merge must be last_wins, error or collect, got %q", def.Merge)
+	}
+	return step
+}
+
+func (l *definitionLoader) await(path string, def StepSpec) Step {
+	step := &AwaitStep{
+		name:       def.Name,
+		signalName: def.Signal,
+		approvers:  def.Approvers,
+		timeout:    l.duration(path, def.Name, "timeout", def.Timeout),
+		onTimeout:  def.OnTimeout,
+	}
+	switch {
+	case def.Signal != "" && def.Approvers != nil:
+		l.fail(path, def.Name, "await steps take signal or approvers, not both")
+	case def.Signal != "":
+		step.awaitType = AwaitTypeSignal
+	case len(def.Approvers) > 0:
+		step.awaitType = AwaitTypeApproval
+	default:
+		l.fail(path, def.Name, "await steps need a signal or approvers")
+	}
+
+	for i, esc := range def.Escalations {
+		field := fmt.Sprintf("escalations[%d]", i)
+		action := EscalationAction{Kind: esc.Action, Message: esc.Message, Approvers: esc.Approvers}
+		switch esc.Action {
+		case EscalateNotify, EscalateAutoApprove, EscalateAutoReject:
+		case EscalateReroute:
+			if len(esc.Approvers) == 0 {
+				l.fail(path, def.Name, "%s: reroute needs approvers", field)
+			}
+		default:
+			l.fail(path, def.Name, "%s: unknown action %q", field, esc.Action)
+		}
+		after := l.duration(path, def.Name, field+".after", esc.After)
+		if after <= 0 {
+			l.fail(path, def.Name, "%s: after must be a positive duration", field)
+		}
+		step.escalations = append(step.escalations, Escalation{After: after, Action: action})
+	}
+	return step
+}
+
+// duration parses an optional duration field.
+func (l *definitionLoader) timer(path string, def StepSpec) Step {
+	step := &TimerStep{name: def.Name}
+	switch {
+	case (def.Until == "") == (def.Cron == ""):
+		l.fail(path, def.Name, "timer steps need one of until or cron")
+	case def.Cron != "":
+		cron, err := ParseCron(def.Cron)
+		if err != nil {
+			l.fail(path, def.Name, "cron: %v", err)
+		}
+		step.cron, step.cronExpr = cron, def.Cron
+	default:
+		at, err := time.Parse(time.RFC3339Nano, def.Until)
+		if err != nil {
+			l.fail(path, def.Name, "until: %v", err)
+		}
+		step.at = at
+	}
+	return step
+}
+
+func (l *definitionLoader) duration(path, step, field, value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		l.fail(path, step, "%s: %v", field, err)
+	}
+	return d
+}
+
+func (l *definitionLoader) expr(path, step, field, src string) *Expr {
+	if src == "" {
+		l.fail(path, step, "%s is required", field)
+		return nil
+	}
+	expr, err := CompileExpr(src)
+	if err != nil {
+		l.fail(path, step, "%s: %v", field, err)
+	}
+	return expr
+}
+
+func (l *definitionLoader) condFunc(path, step, field, src string) Condition {
+	expr := l.expr(path, step, field, src)
+	return func(state *State) bool {
+		if expr == nil {
+			return false
+		}
+		v, err := expr.Eval(stateVars(state))
+		return err == nil && truthy(v)
+	}
+}
+
+// ExportDefinition serializes wf as a YAML definition that LoadDefinition
+// accepts. Steps defined by Go code alone, such as actions built with
+// Builder.Step or conditions given as Go functions, have no names to
+// export and are reported as *DefinitionError.
+func ExportDefinition(wf *Workflow) ([]byte, error) {
+	def := Spec{Name: wf.Name, Version: wf.Version, Tools: wf.toolNames, Timeout: formatDuration(wf.timeout)}
+	e := &definitionExporter{}
+	def.Steps = e.steps("steps", wf.Steps)
+	if len(e.errs) > 0 {
+		return nil, fmt.Errorf("workflow %s: %w", wf.Name, errors.Join(e.errs...))
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(def); err != nil {
+		return nil, fmt.Errorf("workflow %s: %w", wf.Name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+type definitionExporter struct {
+	errs []error
+}
+
+func (e *definitionExporter) fail(path, step, format string, args ...any) {
+	e.errs = append(e.errs, &DefinitionError{Path: path, Step: step, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (e *definitionExporter) steps(path string, steps []Step) []StepSpec {
+	defs := make([]StepSpec, 0, len(steps))
+	for i, step := range steps {
+		defs = append(defs, e.step(fmt.Sprintf("%s[%d]", path, i), step))
+	}
+	return defs
+}
+
+func (e *definitionExporter) step(path string, step Step) StepSpec {
+	def := StepSpec{Name: step.Name(), Type: step.Type()}
+	switch s := step.(type) {
+	case *ActionStep:
+		if s.handlerName == "" {
+			e.fail(path, s.name, "action handler has no registered name")
+		}
+		if s.compensation != nil && s.compensationName == "" {
+			e.fail(path, s.name, "compensation has no registered name")
+		}
+		if s.onError != nil {
+			e.fail(path, s.name, "error handlers cannot be exported")
+		}
+		def.Handler, def.Compensate = s.handlerName, s.compensationName
+		def.OnErrorGoto = s.onErrorGoto
+		def.Timeout = formatDuration(s.timeout)
+		def.Output = s.output
+		if s.input != nil {
+			def.Input = s.input.source
+		}
+		if p := s.retryPolicy; p != nil {
+			if p.RetryOn != nil {
+				e.fail(path, s.name, "retry filters cannot be exported")
+			}
+			if p.Notify != nil {
+				e.fail(path, s.name, "retry callbacks cannot be exported")
+			}
+			def.Retry = &RetrySpec{
+				Attempts:     p.MaxAttempts,
+				InitialDelay: formatDuration(p.InitialDelay),
+				MaxDelay:     formatDuration(p.MaxDelay),
+				Multiplier:   p.Multiplier,
+				Jitter:       p.Jitter,
+				FullJitter:   p.FullJitter,
+				Budget:       formatDuration(p.Budget),
+			}
+		}
+
+	case *ConditionStep:
+		if s.conditionSrc == "" || len(s.elifSrcs) != len(s.elifConds) {
+			e.fail(path, s.name, "condition is a Go function, not an expression")
+		}
+		def.If = s.conditionSrc
+		def.Then = e.steps(path+".then", s.thenSteps)
+		for i, src := range s.elifSrcs {
+			def.ElseIf = append(def.ElseIf, BranchSpec{
+				If:    src,
+				Steps: e.steps(fmt.Sprintf("%s.else_if[%d].steps", path, i), s.elifSteps[i]),
+			})
+		}
+		if len(s.elseSteps) > 0 {
+			def.Else = e.steps(path+".else", s.elseSteps)
+		}
+
+	case *LoopStep:
+		if s.whileCondition != nil && s.whileSrc == "" || s.breakCondition != nil && s.breakSrc == "" {
+			e.fail(path, s.name, "loop condition is a Go function, not an expression")
+		}
+		def.ForEach, def.While, def.BreakWhen = s.forEachKey, s.whileSrc, s.breakSrc
+		def.MaxIterations = s.maxIterations
+		def.Steps = e.steps(path+".steps", s.steps)
+
+	case *ParallelStep:
+		def.Steps = e.steps(path+".steps", s.steps)
+		switch s.waitStrategy {
+		case WaitAny:
+			def.Wait = "any"
+		case WaitCount:
+			def.WaitCount = s.waitCount
+		}
+		for name, strategy := range mergeStrategies {
+			if strategy == s.mergeStrategy && strategy != MergeLastWins {
+				def.Merge = name
+			}
+		}
+
+	case *AwaitStep:
+		if s.awaitType == AwaitTypeSignal {
+			def.Signal = s.signalName
+		} else {
+			def.Approvers = s.approvers
+		}
+		def.Timeout, def.OnTimeout = formatDuration(s.timeout), s.onTimeout
+		for _, esc := range s.escalations {
+			def.Escalations = append(def.Escalations, EscalationSpec{
+				After:     formatDuration(esc.After),
+				Action:    esc.Action.Kind,
+				Message:   esc.Action.Message,
+				Approvers: esc.Action.Approvers,
+			})
+		}
+
+	case *SleepStep:
+		def.Duration = s.duration.String()
+
+	case *TimerStep:
+		switch {
+		case s.cronExpr != "":
+			def.Cron = s.cronExpr
+		case s.until != nil:
+			e.fail(path, s.name, "wait time is computed by Go code")
+		default:
+			def.Until = s.at.Format(time.RFC3339Nano)
+		}
+
+	case *SubWorkflowStep:
+		def.Workflow, def.Input, def.Async = s.workflow.Name, s.input, s.async
+
+	case *WaitForChildStep:
+		def.Child = s.child
+
+	case *CheckpointStep:
+
+	case *TransformStep:
+		if s.expr == nil {
+			e.fail(path, s.name, "script does not compile: %v", s.compileErr)
+			break
+		}
+		def.Script = s.expr.Source
+		if s.expr.MaxSteps != DefaultExprMaxSteps {
+			def.MaxSteps = s.expr.MaxSteps
+		}
+
+	default:
+		e.fail(path, step.Name(), "%s steps cannot be exported", step.Type())
+	}
+	return def
+}
+
+// formatDuration formats d for a definition, leaving zero unset.
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
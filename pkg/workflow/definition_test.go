@@ -0,0 +1,253 @@
+// Package workflow_test provides tests for declarative workflow definitions.
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+const expenseDefinition = `
+name: expense
+version: "2.0.0"
+timeout: 1h0m0s
+steps:
+  - name: total
+    type: transform
+    script: sum(data.items)
+  - name: review
+    type: condition
+    if: results.total > 100
+    then:
+      - {name: escalate, type: action, handler: record, compensate: undo, on_error_goto: saved}
+    else_if:
+      - if: results.total > 10
+        steps:
+          - {name: check, type: action, handler: record, input: {total: "{{ results.total }}"}, output: checked}
+    else:
+      - {name: auto, type: action, handler: record}
+  - name: each
+    type: loop
+    for_each: items
+    max_iterations: 10
+    steps:
+      - {name: visit, type: action, handler: count}
+  - name: notify
+    type: parallel
+    wait: any
+    merge: collect
+    steps:
+      - {name: email, type: action, handler: record, retry: {attempts: 2, initial_delay: 10ms, jitter: 0.1, budget: 1s}}
+      - {name: chat, type: action, handler: record}
+  - {name: saved, type: checkpoint}
+  - {name: pause, type: sleep, duration: 1ms}
+  - {name: resume, type: timer, until: "2020-01-01T00:00:00Z"}
+  - {name: audit, type: subworkflow, workflow: audit, input: {reason: expense}, async: true}
+  - {name: audited, type: wait_child, child: audit}
+`
+
+func definitionHandlers(trace *[]string) *workflow.HandlerRegistry {
+	handlers := workflow.NewHandlerRegistry()
+	handlers.Register("record", func(ctx context.Context, state *workflow.State) (any, error) {
+		return "recorded", nil
+	})
+	handlers.Register("count", func(ctx context.Context, state *workflow.State) (any, error) {
+		*trace = append(*trace, "visit")
+		return nil, nil
+	})
+	handlers.RegisterCompensation("undo", func(ctx context.Context, state *workflow.State) error {
+		return nil
+	})
+	handlers.RegisterWorkflow(workflow.New("audit").Checkpoint("audited").Build())
+	return handlers
+}
+
+func TestLoadDefinition(t *testing.T) {
+	var trace []string
+	wf, err := workflow.LoadDefinition([]byte(expenseDefinition), definitionHandlers(&trace))
+	if err != nil {
+		t.Fatalf("LoadDefinition failed: %v", err)
+	}
+	if wf.Name != "expense" || wf.Version != "2.0.0" || len(wf.Steps) != 9 {
+		t.Fatalf("Unexpected workflow: %s %s with %d steps", wf.Name, wf.Version, len(wf.Steps))
+	}
+
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, map[string]any{
+		"items": []any{20.0, 30.0},
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if state.StepResults["total"] != 50.0 {
+		t.Errorf("Expected total 50, got %v", state.StepResults["total"])
+	}
+	if state.StepResults["check"] != "recorded" || state.StepResults["escalate"] != nil || state.StepResults["auto"] != nil {
+		t.Errorf("Expected only the else-if branch to run, got %v", state.StepResults)
+	}
+	if len(trace) != 2 {
+		t.Errorf("Expected the loop body twice, got %v", trace)
+	}
+	if _, ok := state.Checkpoints["saved"]; !ok {
+		t.Error("Expected the checkpoint to be recorded")
+	}
+	if state.StepResults["audited"] == nil || state.Children["audit"] != state.StepResults["audit"] {
+		t.Errorf("Expected the sub-workflow to run, got %v", state.StepResults)
+	}
+}
+
+func TestLoadDefinition_JSON(t *testing.T) {
+	wf, err := workflow.LoadDefinition([]byte(`{
+		"name": "json",
+		"steps": [{"name": "double", "type": "transform", "script": "data.n * 2"}]
+	}`), nil)
+	if err != nil {
+		t.Fatalf("LoadDefinition failed: %v", err)
+	}
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, map[string]any{"n": 21})
+	if err != nil || state.StepResults["double"] != 42.0 {
+		t.Errorf("Expected 42, got %v (%v)", state.StepResults["double"], err)
+	}
+}
+
+func TestLoadDefinition_Errors(t *testing.T) {
+	var trace []string
+	tests := []struct {
+		name string
+		def  string
+		want []string
+	}{
+		{
+			"unknown handler",
+			"name: w\nsteps:\n  - {name: a, type: action, handler: missing}",
+			[]string{`steps[0] (a): unknown handler "missing"`},
+		},
+		{
+			"nested errors",
+			`name: w
+steps:
+  - name: branch
+    type: condition
+    if: data.x >
+    then:
+      - {name: wait, type: sleep, duration: soon}
+      - {name: b, type: await}`,
+			[]string{
+				"steps[0] (branch): if: expr",
+				`steps[0].then[0] (wait): duration: time: invalid duration "soon"`,
+				"steps[0].then[1] (b): await steps need a signal or approvers",
+			},
+		},
+		{
+			"wrong field for type",
+			"name: w\nsteps:\n  - {name: s, type: sleep, duration: 1s, handler: record}",
+			[]string{"steps[0] (s): field handler does not apply to sleep steps"},
+		},
+		{
+			"duplicate names",
+			"name: w\nsteps:\n  - {name: s, type: checkpoint}\n  - {name: s, type: checkpoint}",
+			[]string{"steps[1] (s): step name is already used by steps[0]"},
+		},
+		{
+			"unknown type and workflow",
+			"name: w\nsteps:\n  - {name: a, type: teleport}\n  - {name: b, type: subworkflow, workflow: nope}",
+			[]string{`steps[0] (a): unknown step type "teleport"`, `steps[1] (b): unknown workflow "nope"`},
+		},
+		{
+			"endless loop",
+			"name: w\nsteps:\n  - {name: l, type: loop, steps: [{name: c, type: checkpoint}]}",
+			[]string{"steps[0] (l): loop never ends"},
+		},
+		{
+			"missing name",
+			"steps:\n  - {type: checkpoint}",
+			[]string{"name: workflow name is required", "steps[0]: step name is required"},
+		},
+		{
+			"unknown field",
+			"name: w\nsteps:\n  - {name: a, type: checkpoint, colour: red}",
+			[]string{"field colour not found"},
+		},
+		{
+			"goto target",
+			"name: w\nsteps:\n  - {name: a, type: action, handler: record, on_error_goto: later}",
+			[]string{`steps[0] (a): on_error_goto: "later" is not a top-level step`},
+		},
+		{
+			"timer",
+			"name: w\nsteps:\n  - {name: t, type: timer}\n  - {name: c, type: timer, cron: \"61 * * * *\"}",
+			[]string{"steps[0] (t): timer steps need one of until or cron", "steps[1] (c): cron:"},
+		},
+		{
+			"action input",
+			"name: w\nsteps:\n  - {name: a, type: action, handler: record, input: {x: \"{{ data. }}\"}}",
+			[]string{`steps[0] (a): input: workflow: template field "x"`},
+		},
+		{"empty", "", []string{"document is empty"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := workflow.LoadDefinition([]byte(tt.def), definitionHandlers(&trace))
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected %q in:\n%v", want, err)
+				}
+			}
+		})
+	}
+
+	_, err := workflow.LoadDefinition([]byte("name: w\nsteps:\n  - {name: a, type: action, handler: x}"), nil)
+	var defErr *workflow.DefinitionError
+	if !errors.As(err, &defErr) || defErr.Path != "steps[0]" || defErr.Step != "a" {
+		t.Errorf("Expected a DefinitionError for steps[0], got %v", err)
+	}
+}
+
+func TestExportDefinition_RoundTrip(t *testing.T) {
+	var trace []string
+	handlers := definitionHandlers(&trace)
+	wf, err := workflow.LoadDefinition([]byte(expenseDefinition), handlers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := workflow.ExportDefinition(wf)
+	if err != nil {
+		t.Fatalf("ExportDefinition failed: %v", err)
+	}
+	reloaded, err := workflow.LoadDefinition(out, handlers)
+	if err != nil {
+		t.Fatalf("Exported definition does not load: %v\n%s", err, out)
+	}
+	again, err := workflow.ExportDefinition(reloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(out) {
+		t.Errorf("Round trip changed the definition:\n%s\nthen:\n%s", out, again)
+	}
+	for _, want := range []string{"else_if:", "for_each: items", "wait: any", "merge: collect", "initial_delay: 10ms", "compensate: undo", "on_error_goto: saved", "timeout: 1h0m0s", "async: true", "until: \"2020-01-01T00:00:00Z\"", "output: checked", "jitter: 0.1", "budget: 1s"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("Expected %q in export:\n%s", want, out)
+		}
+	}
+}
+
+func TestExportDefinition_GoSteps(t *testing.T) {
+	wf := workflow.New("code").
+		Step("charge", func(ctx context.Context, state *workflow.State) (any, error) { return nil, nil }).Then().
+		Sleep("pause", 0).
+		Build()
+
+	_, err := workflow.ExportDefinition(wf)
+	var defErr *workflow.DefinitionError
+	if !errors.As(err, &defErr) || defErr.Path != "steps[0]" || defErr.Step != "charge" {
+		t.Errorf("Expected a DefinitionError for the Go action, got %v", err)
+	}
+}
@@ -3,29 +3,61 @@ package workflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/nuulab/goflow/pkg/metrics"
+	"github.com/nuulab/goflow/pkg/notify"
+	"github.com/nuulab/goflow/pkg/queue"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+var (
+	// ErrCancelled is the cause of runs stopped by Engine.Cancel.
+	ErrCancelled = errors.New("workflow: cancelled")
+	// ErrRunTimeout is the cause of runs stopped by a run timeout.
+	ErrRunTimeout = errors.New("workflow: run timed out")
+	// ErrRunFinished is returned when cancelling a run that already ended.
+	ErrRunFinished = errors.New("workflow: run already finished")
 )
 
 // Engine executes workflows.
 type Engine struct {
-	persistence *Persistence
+	persistence Persistence
 	signals     *SignalManager
 	approvals   *ApprovalManager
 	workflows   map[string]*Workflow
 	running     map[string]*State
+	definitions map[string]*Workflow            // running state ID -> workflow
+	overrides   map[awaitKey]chan awaitOverride // running await step -> override
+	cancels     map[string]context.CancelCauseFunc
+	finished    map[string]chan struct{} // running state ID -> closed when the run ends
+	registry    *tools.Registry
+	notifier    notify.Notifier
+	digest      *notify.Digest
+	snapshots   SnapshotPolicy
+	clock       Clock
+	timeout     time.Duration // default run timeout
+	outbox      *queue.Outbox
+	listeners   []func(ctx context.Context, ev RunEvent)
 	mu          sync.RWMutex
 }
 
 // NewEngine creates a new workflow engine.
-func NewEngine(persistence *Persistence) *Engine {
+func NewEngine(persistence Persistence) *Engine {
 	return &Engine{
 		persistence: persistence,
 		signals:     NewSignalManager(),
 		approvals:   NewApprovalManager(),
 		workflows:   make(map[string]*Workflow),
 		running:     make(map[string]*State),
+		definitions: make(map[string]*Workflow),
+		overrides:   make(map[awaitKey]chan awaitOverride),
+		cancels:     make(map[string]context.CancelCauseFunc),
+		finished:    make(map[string]chan struct{}),
+		clock:       realClock{},
 	}
 }
 
@@ -36,6 +68,61 @@ func (e *Engine) Register(workflow *Workflow) {
 	e.workflows[workflow.Name] = workflow
 }
 
+// Unregister removes a registered workflow.
+func (e *Engine) Unregister(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.workflows, name)
+}
+
+// Workflow returns a registered workflow by name.
+func (e *Engine) Workflow(name string) (*Workflow, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	workflow, ok := e.workflows[name]
+	return workflow, ok
+}
+
+// SetTools sets the global tool registry. Workflows that declare their
+// tools see only a filtered view of it.
+func (e *Engine) SetTools(registry *tools.Registry) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.registry = registry
+}
+
+// SetClock replaces the clock used for await ages, escalations and timer
+// steps.
+func (e *Engine) SetClock(clock Clock) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.clock = clock
+}
+
+// SetRunTimeout sets the longest an execution may run, for workflows
+// without their own Builder.Timeout. Zero, the default, means no limit.
+// Each execution, including a resumed one, gets the full duration.
+func (e *Engine) SetRunTimeout(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.timeout = d
+}
+
+// SetNotifier sets the notifier used by escalations.
+func (e *Engine) SetNotifier(n notify.Notifier) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.notifier = n
+}
+
+// SetDigest reports every execution outcome to d, which notifies on failures
+// and recoveries without flooding the notifier.
+func (e *Engine) SetDigest(d *notify.Digest) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.digest = d
+}
+
 // Start begins a workflow execution.
 func (e *Engine) Start(ctx context.Context, workflowName string, input map[string]any) (string, error) {
 	e.mu.RLock()
@@ -45,9 +132,13 @@ func (e *Engine) Start(ctx context.Context, workflowName string, input map[strin
 	if !ok {
 		return "", fmt.Errorf("workflow not found: %s", workflowName)
 	}
+	if err := workflow.Validate(); err != nil {
+		return "", err
+	}
 
 	state := &State{
 		ID:          fmt.Sprintf("%s-%d", workflowName, time.Now().UnixNano()),
+		Workflow:    workflow.Name,
 		WorkflowID:  workflow.ID,
 		Status:      StatusRunning,
 		Data:        input,
@@ -60,10 +151,7 @@ func (e *Engine) Start(ctx context.Context, workflowName string, input map[strin
 		state.Data = make(map[string]any)
 	}
 
-	e.mu.Lock()
-	e.running[state.ID] = state
-	e.mu.Unlock()
-
+	e.track(state)
 	go e.execute(ctx, workflow, state)
 
 	return state.ID, nil
@@ -71,8 +159,13 @@ func (e *Engine) Start(ctx context.Context, workflowName string, input map[strin
 
 // Execute runs a workflow synchronously.
 func (e *Engine) Execute(ctx context.Context, workflow *Workflow, input map[string]any) (*State, error) {
+	if err := workflow.Validate(); err != nil {
+		return nil, err
+	}
+
 	state := &State{
 		ID:          fmt.Sprintf("%s-%d", workflow.Name, time.Now().UnixNano()),
+		Workflow:    workflow.Name,
 		WorkflowID:  workflow.ID,
 		Status:      StatusRunning,
 		Data:        input,
@@ -90,30 +183,98 @@ func (e *Engine) Execute(ctx context.Context, workflow *Workflow, input map[stri
 
 // ExecuteWithState executes with existing state.
 func (e *Engine) ExecuteWithState(ctx context.Context, workflow *Workflow, state *State) (*State, error) {
+	ctx = context.WithValue(ctx, engineContextKey{}, e)
+	ctx = context.WithValue(ctx, stateContextKey{}, state)
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	e.mu.RLock()
+	timeout := e.timeout
+	e.mu.RUnlock()
+	if workflow.timeout > 0 {
+		timeout = workflow.timeout
+	}
+	if timeout > 0 {
+		var stop context.CancelFunc
+		runCtx, stop = context.WithTimeoutCause(runCtx, timeout, ErrRunTimeout)
+		defer stop()
+	}
+
+	e.heartbeat(state)
 	e.mu.Lock()
+	finished, tracked := e.finished[state.ID]
+	if !tracked {
+		finished = make(chan struct{})
+	}
 	e.running[state.ID] = state
+	e.definitions[state.ID] = workflow
+	e.cancels[state.ID] = cancel
+	e.finished[state.ID] = finished
 	e.mu.Unlock()
 
 	defer func() {
 		e.mu.Lock()
 		delete(e.running, state.ID)
+		delete(e.definitions, state.ID)
+		delete(e.cancels, state.ID)
+		delete(e.finished, state.ID)
 		e.mu.Unlock()
+		close(finished)
 	}()
+	e.emit(ctx, state, EventRunStarted, "", nil)
+	if !state.Preview {
+		metrics.WorkflowStarted()
+	}
+
+	registry, err := e.scopedTools(workflow)
+	if err == nil {
+		if registry != nil {
+			runCtx = ContextWithTools(runCtx, registry)
+			ctx = ContextWithTools(ctx, registry)
+		}
+		err = e.executeSteps(runCtx, workflow, state)
+	}
+	if cause := context.Cause(runCtx); err != nil && cause != nil && cause != runCtx.Err() {
+		err = fmt.Errorf("%w: %w", cause, err)
+	}
 
-	err := e.executeSteps(ctx, workflow, state)
+	cancelled := err != nil && errors.Is(context.Cause(runCtx), ErrCancelled)
+	timedOut := err != nil && errors.Is(context.Cause(runCtx), ErrRunTimeout)
+	if cancelled {
+		// The reason, not the error of the interrupted step.
+		err = context.Cause(runCtx)
+	}
+	// Step failures are already in Errors.
+	record := err != nil && (cancelled || timedOut || !state.recorded(err))
 
+	// The run can be read through GetState and ListRunning while it
+	// finishes, so the state is only written under its lock.
+	state.mu.Lock()
 	state.CompletedAt = time.Now()
-	if err != nil {
+	compensate := err != nil && len(state.Compensations) > 0
+	switch {
+	case compensate:
+		state.Status = StatusCompensating
+	case cancelled:
+		state.Status = StatusCancelled
+	case err != nil:
 		state.Status = StatusFailed
+	default:
+		state.Status = StatusCompleted
+	}
+	if record {
 		state.Errors = append(state.Errors, err.Error())
+	}
+	state.mu.Unlock()
 
+	if compensate {
 		// Run compensations (saga pattern)
-		if len(state.Compensations) > 0 {
-			state.Status = StatusCompensating
-			e.runCompensations(ctx, state)
+		e.runCompensations(ctx, state)
+		state.mu.Lock()
+		state.Status = StatusFailed
+		if cancelled {
+			state.Status = StatusCancelled
 		}
-	} else {
-		state.Status = StatusCompleted
+		state.mu.Unlock()
 	}
 
 	// Save final state
@@ -124,16 +285,74 @@ func (e *Engine) ExecuteWithState(ctx context.Context, workflow *Workflow, state
 	if workflow.OnComplete != nil {
 		workflow.OnComplete(ctx, state)
 	}
+	if !state.Preview {
+		metrics.ObserveWorkflowRun(state.StartedAt, state.ID, "")
+	}
+	switch {
+	case cancelled:
+		e.emit(ctx, state, EventRunCancelled, "", err)
+	case err != nil:
+		e.emit(ctx, state, EventRunFailed, "", err)
+		if !state.Preview {
+			metrics.WorkflowFailed()
+		}
+	default:
+		e.emit(ctx, state, EventRunCompleted, "", nil)
+		if !state.Preview {
+			metrics.WorkflowCompleted()
+		}
+	}
+
+	e.mu.RLock()
+	digest := e.digest
+	e.mu.RUnlock()
+	if digest != nil && !state.Preview {
+		digest.Record(ctx, notify.Event{Workflow: workflow.Name, RunID: state.ID, Err: err})
+	}
 
 	return state, err
 }
 
+// track registers state as running before its execution starts, so it can
+// be found and waited for right away.
+func (e *Engine) track(state *State) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.running[state.ID] = state
+	if _, ok := e.finished[state.ID]; !ok {
+		e.finished[state.ID] = make(chan struct{})
+	}
+}
+
+// waitRun waits until the execution of stateID on this engine ends. It
+// returns at once if the run is not executing here.
+func (e *Engine) waitRun(ctx context.Context, stateID string) error {
+	e.mu.RLock()
+	finished, running := e.finished[stateID]
+	e.mu.RUnlock()
+	if !running {
+		return nil
+	}
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (e *Engine) execute(ctx context.Context, workflow *Workflow, state *State) {
 	e.ExecuteWithState(ctx, workflow, state)
 }
 
 func (e *Engine) executeSteps(ctx context.Context, workflow *Workflow, state *State) error {
-	for i := state.CurrentStep; i < len(workflow.Steps); i++ {
+	end := len(workflow.Steps)
+	if state.Preview && state.PreviewSteps < end {
+		end = state.PreviewSteps
+	}
+	gotos := 0
+
+	for i := state.CurrentStep; i < end; i++ {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -151,8 +370,39 @@ func (e *Engine) executeSteps(ctx context.Context, workflow *Workflow, state *St
 			e.persistence.Save(ctx, state)
 		}
 
+		e.heartbeat(state)
+		started := e.now()
 		err := step.Execute(ctx, state)
+		if _, ok := step.(*ActionStep); !ok {
+			result, _ := state.Result(step.Name())
+			recordExecution(ctx, state, step.Name(), 1, started, result, err)
+		}
+		e.recordStep(state, step)
+		e.heartbeat(state)
 		if err != nil {
+			e.emit(ctx, state, EventStepFailed, step.Name(), err)
+			e.discardOutbox(state, i)
+			if !state.recorded(err) {
+				state.recordStepError(step.Name(), err, 1)
+			}
+
+			var jump *gotoError
+			switch {
+			case Classify(err) == ErrorFatal:
+				return fmt.Errorf("step '%s' failed: %w", step.Name(), err)
+			case errors.As(err, &jump):
+				target := workflow.stepIndex(jump.step)
+				if target < 0 {
+					return fmt.Errorf("step '%s' failed: %w", step.Name(), err)
+				}
+				if gotos++; gotos > MaxErrorGotos {
+					return fmt.Errorf("step '%s' failed after %d jumps to other steps: %w", step.Name(), MaxErrorGotos, err)
+				}
+				i = target - 1
+				continue
+			case Classify(err) == ErrorSkippable:
+				continue
+			}
 			if workflow.OnError != nil {
 				if handleErr := workflow.OnError(ctx, state, err); handleErr != nil {
 					return handleErr
@@ -161,8 +411,17 @@ func (e *Engine) executeSteps(ctx context.Context, workflow *Workflow, state *St
 			}
 			return fmt.Errorf("step '%s' failed: %w", step.Name(), err)
 		}
+		e.commitOutbox(ctx, state, i)
+		e.emit(ctx, state, EventStepCompleted, step.Name(), nil)
+	}
+	// The last step may have ignored ctx and outlived the run's deadline.
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
+	if end < len(workflow.Steps) {
+		e.stopPreview(state, end, len(workflow.Steps))
+	}
 	return nil
 }
 
@@ -171,7 +430,9 @@ func (e *Engine) runCompensations(ctx context.Context, state *State) {
 	for i := len(state.Compensations) - 1; i >= 0; i-- {
 		comp := state.Compensations[i]
 		if err := comp.Handler(ctx, state); err != nil {
+			state.mu.Lock()
 			state.Errors = append(state.Errors, fmt.Sprintf("compensation '%s' failed: %v", comp.StepName, err))
+			state.mu.Unlock()
 		}
 	}
 }
@@ -187,12 +448,9 @@ func (e *Engine) Resume(ctx context.Context, stateID string) error {
 		return fmt.Errorf("failed to load state: %w", err)
 	}
 
-	e.mu.RLock()
-	workflow, ok := e.workflows[state.WorkflowID]
-	e.mu.RUnlock()
-
+	workflow, ok := e.workflowFor(state)
 	if !ok {
-		return fmt.Errorf("workflow not found: %s", state.WorkflowID)
+		return fmt.Errorf("workflow not found: %s", state.Workflow)
 	}
 
 	state.Status = StatusRunning
@@ -201,6 +459,51 @@ func (e *Engine) Resume(ctx context.Context, stateID string) error {
 	return nil
 }
 
+// ResumeAwaiting resumes every persisted run that was waiting for a signal,
+// an approval or a timer, such as after a restart. Each run re-enters its
+// waiting step without repeating earlier steps. It returns the number resumed.
+func (e *Engine) ResumeAwaiting(ctx context.Context) (int, error) {
+	if e.persistence == nil {
+		return 0, fmt.Errorf("persistence not configured")
+	}
+
+	resumed := 0
+	for _, status := range []Status{StatusAwaitingSignal, StatusAwaitingApproval, StatusAwaitingTimer} {
+		states, err := e.persistence.ListByStatus(ctx, status)
+		if err != nil {
+			return resumed, err
+		}
+		for _, state := range states {
+			if _, running := e.GetState(state.ID); running {
+				continue
+			}
+			workflow, ok := e.workflowFor(state)
+			if !ok {
+				continue
+			}
+			state.Status = StatusRunning
+			go e.execute(ctx, workflow, state)
+			resumed++
+		}
+	}
+	return resumed, nil
+}
+
+// workflowFor returns the registered workflow a stored state belongs to.
+func (e *Engine) workflowFor(state *State) (*Workflow, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if workflow, ok := e.workflows[state.Workflow]; ok {
+		return workflow, true
+	}
+	for _, workflow := range e.workflows {
+		if workflow.ID == state.WorkflowID {
+			return workflow, true
+		}
+	}
+	return nil, false
+}
+
 // ResumeFromCheckpoint resumes from a checkpoint.
 func (e *Engine) ResumeFromCheckpoint(ctx context.Context, stateID, checkpoint string) error {
 	if e.persistence == nil {
@@ -217,31 +520,82 @@ func (e *Engine) ResumeFromCheckpoint(ctx context.Context, stateID, checkpoint s
 		return fmt.Errorf("checkpoint not found: %s", checkpoint)
 	}
 
+	workflow, ok := e.workflowFor(state)
+	if !ok {
+		return fmt.Errorf("workflow not found: %s", state.Workflow)
+	}
+
 	state.CurrentStep = stepIndex
 	state.Status = StatusRunning
 
-	e.mu.RLock()
-	workflow := e.workflows[state.WorkflowID]
-	e.mu.RUnlock()
-
 	go e.execute(ctx, workflow, state)
 
 	return nil
 }
 
+// Cancel stops a run. A running execution, including one blocked in an
+// await step, has its context cancelled; its compensations run in reverse
+// order and it ends with StatusCancelled and reason in Errors. Cancel
+// waits for that, or for ctx. A persisted run that is not executing here
+// is marked cancelled directly. Cancelling a finished run returns
+// ErrRunFinished, as does a run that finished some other way before the
+// cancellation reached it.
+func (e *Engine) Cancel(ctx context.Context, stateID, reason string) error {
+	cause := ErrCancelled
+	if reason != "" {
+		cause = fmt.Errorf("%w: %s", ErrCancelled, reason)
+	}
+
+	e.mu.RLock()
+	finished, running := e.finished[stateID]
+	state := e.running[stateID]
+	e.mu.RUnlock()
+	if running {
+		e.cancelRun(stateID, cause)
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if state != nil && state.Status != StatusCancelled {
+			return fmt.Errorf("%w: %s is %s", ErrRunFinished, stateID, state.Status)
+		}
+		return nil
+	}
+
+	if e.persistence == nil {
+		return fmt.Errorf("%w: %s", ErrStateNotFound, stateID)
+	}
+	state, err := e.persistence.Load(ctx, stateID)
+	if err != nil {
+		return err
+	}
+	switch state.Status {
+	case StatusCompleted, StatusFailed, StatusCancelled:
+		return fmt.Errorf("%w: %s is %s", ErrRunFinished, stateID, state.Status)
+	}
+	state.Status = StatusCancelled
+	state.Errors = append(state.Errors, cause.Error())
+	state.AwaitingStep = ""
+	state.AwaitingSince = time.Time{}
+	state.WakeAt = time.Time{}
+	state.CompletedAt = time.Now()
+	return e.persistence.Save(ctx, state)
+}
+
 // SendSignal sends a signal to waiting workflows.
 func (e *Engine) SendSignal(ctx context.Context, signalName string, data any) {
 	e.signals.Send(signalName, data)
 }
 
-// Approve sends approval for a workflow.
-func (e *Engine) Approve(ctx context.Context, stateID string, approver string) error {
-	return e.approvals.Approve(stateID, approver)
+// Approve sends approval for the await step of a workflow.
+func (e *Engine) Approve(ctx context.Context, stateID, step, approver string) error {
+	return e.approvals.Approve(stateID, step, approver)
 }
 
-// Reject rejects a workflow approval.
-func (e *Engine) Reject(ctx context.Context, stateID string, approver, reason string) error {
-	return e.approvals.Reject(stateID, approver, reason)
+// Reject rejects the approval awaited by a step of a workflow.
+func (e *Engine) Reject(ctx context.Context, stateID, step, approver, reason string) error {
+	return e.approvals.Reject(stateID, step, approver, reason)
 }
 
 // GetState returns workflow state.
@@ -269,11 +623,8 @@ func NewSignalManager() *SignalManager {
 
 // Wait waits for a signal.
 func (sm *SignalManager) Wait(ctx context.Context, signalName string) (any, error) {
-	ch := make(chan any, 1)
-
-	sm.mu.Lock()
-	sm.waiters[signalName] = append(sm.waiters[signalName], ch)
-	sm.mu.Unlock()
+	ch, unsubscribe := sm.subscribe(signalName)
+	defer unsubscribe()
 
 	select {
 	case <-ctx.Done():
@@ -283,6 +634,28 @@ func (sm *SignalManager) Wait(ctx context.Context, signalName string) (any, erro
 	}
 }
 
+// subscribe registers a waiter for the next signalName signal. Signals sent
+// once it returns are not missed.
+func (sm *SignalManager) subscribe(signalName string) (<-chan any, func()) {
+	ch := make(chan any, 1)
+
+	sm.mu.Lock()
+	sm.waiters[signalName] = append(sm.waiters[signalName], ch)
+	sm.mu.Unlock()
+
+	return ch, func() {
+		sm.mu.Lock()
+		defer sm.mu.Unlock()
+		waiters := sm.waiters[signalName]
+		for i, w := range waiters {
+			if w == ch {
+				sm.waiters[signalName] = append(waiters[:i:i], waiters[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
 // Send sends a signal.
 func (sm *SignalManager) Send(signalName string, data any) {
 	sm.mu.Lock()
@@ -300,15 +673,23 @@ func (sm *SignalManager) Send(signalName string, data any) {
 
 // ============ Approval Manager ============
 
-// ApprovalManager handles human approvals.
+// ApprovalManager handles human approvals. Requests are keyed by run and
+// step, so parallel awaits of one run are approved separately.
 type ApprovalManager struct {
-	pending map[string]*ApprovalRequest
+	pending map[awaitKey]*ApprovalRequest
 	mu      sync.RWMutex
 }
 
+// awaitKey identifies an await step of a run.
+type awaitKey struct {
+	stateID string
+	step    string
+}
+
 // ApprovalRequest represents a pending approval.
 type ApprovalRequest struct {
 	StateID    string
+	Step       string
 	Approvers  []string
 	Approved   map[string]bool
 	Rejected   bool
@@ -319,34 +700,62 @@ type ApprovalRequest struct {
 // NewApprovalManager creates an approval manager.
 func NewApprovalManager() *ApprovalManager {
 	return &ApprovalManager{
-		pending: make(map[string]*ApprovalRequest),
+		pending: make(map[awaitKey]*ApprovalRequest),
 	}
 }
 
-// RequestApproval creates an approval request.
-func (am *ApprovalManager) RequestApproval(stateID string, approvers []string) chan bool {
-	ch := make(chan bool, 1)
+// RequestApproval creates an approval request for a step.
+func (am *ApprovalManager) RequestApproval(stateID, step string, approvers []string) chan bool {
+	return am.request(stateID, step, approvers).ResponseCh
+}
 
-	am.mu.Lock()
-	am.pending[stateID] = &ApprovalRequest{
+func (am *ApprovalManager) request(stateID, step string, approvers []string) *ApprovalRequest {
+	req := &ApprovalRequest{
 		StateID:    stateID,
+		Step:       step,
 		Approvers:  approvers,
 		Approved:   make(map[string]bool),
-		ResponseCh: ch,
+		ResponseCh: make(chan bool, 1),
 	}
+
+	am.mu.Lock()
+	am.pending[awaitKey{stateID, step}] = req
 	am.mu.Unlock()
 
-	return ch
+	return req
+}
+
+// cancel drops a pending request without responding.
+func (am *ApprovalManager) cancel(stateID, step string) {
+	am.mu.Lock()
+	delete(am.pending, awaitKey{stateID, step})
+	am.mu.Unlock()
+}
+
+// Reroute replaces the approvers of a pending request and clears
+// any approvals already given.
+func (am *ApprovalManager) Reroute(stateID, step string, approvers []string) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	req, ok := am.pending[awaitKey{stateID, step}]
+	if !ok {
+		return fmt.Errorf("no pending approval for %s at step '%s'", stateID, step)
+	}
+	req.Approvers = approvers
+	req.Approved = make(map[string]bool)
+	return nil
 }
 
 // Approve approves a request.
-func (am *ApprovalManager) Approve(stateID, approver string) error {
+func (am *ApprovalManager) Approve(stateID, step, approver string) error {
 	am.mu.Lock()
 	defer am.mu.Unlock()
 
-	req, ok := am.pending[stateID]
+	key := awaitKey{stateID, step}
+	req, ok := am.pending[key]
 	if !ok {
-		return fmt.Errorf("no pending approval for %s", stateID)
+		return fmt.Errorf("no pending approval for %s at step '%s'", stateID, step)
 	}
 
 	req.Approved[approver] = true
@@ -362,26 +771,27 @@ func (am *ApprovalManager) Approve(stateID, approver string) error {
 
 	if allApproved {
 		req.ResponseCh <- true
-		delete(am.pending, stateID)
+		delete(am.pending, key)
 	}
 
 	return nil
 }
 
 // Reject rejects a request.
-func (am *ApprovalManager) Reject(stateID, approver, reason string) error {
+func (am *ApprovalManager) Reject(stateID, step, approver, reason string) error {
 	am.mu.Lock()
 	defer am.mu.Unlock()
 
-	req, ok := am.pending[stateID]
+	key := awaitKey{stateID, step}
+	req, ok := am.pending[key]
 	if !ok {
-		return fmt.Errorf("no pending approval for %s", stateID)
+		return fmt.Errorf("no pending approval for %s at step '%s'", stateID, step)
 	}
 
 	req.Rejected = true
 	req.Reason = reason
 	req.ResponseCh <- false
-	delete(am.pending, stateID)
+	delete(am.pending, key)
 
 	return nil
 }
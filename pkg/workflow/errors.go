@@ -0,0 +1,138 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrorClass tells the engine how to treat a step error.
+type ErrorClass string
+
+const (
+	// ErrorRetryable errors are worth retrying. Use IsRetryable as a
+	// RetryPolicy filter to retry only these.
+	ErrorRetryable ErrorClass = "retryable"
+	// ErrorFatal errors abort the run: they are not retried and step and
+	// workflow error handlers are not consulted.
+	ErrorFatal ErrorClass = "fatal"
+	// ErrorSkippable errors are recorded and the run continues with the
+	// next top-level step.
+	ErrorSkippable ErrorClass = "skippable"
+)
+
+// ClassifiedError is an error with an ErrorClass.
+type ClassifiedError struct {
+	Class ErrorClass
+	Err   error
+}
+
+func (e *ClassifiedError) Error() string { return e.Err.Error() }
+func (e *ClassifiedError) Unwrap() error { return e.Err }
+
+// Retryable marks err as retryable.
+func Retryable(err error) error { return classify(err, ErrorRetryable) }
+
+// Fatal marks err as fatal.
+func Fatal(err error) error { return classify(err, ErrorFatal) }
+
+// Skippable marks err as skippable.
+func Skippable(err error) error { return classify(err, ErrorSkippable) }
+
+func classify(err error, class ErrorClass) error {
+	if err == nil {
+		return nil
+	}
+	return &ClassifiedError{Class: class, Err: err}
+}
+
+// Classify returns the class of the outermost ClassifiedError in err's
+// chain, or "" if err is unclassified.
+func Classify(err error) ErrorClass {
+	var ce *ClassifiedError
+	if errors.As(err, &ce) {
+		return ce.Class
+	}
+	return ""
+}
+
+// IsRetryable reports whether err was marked with Retryable.
+func IsRetryable(err error) bool {
+	return Classify(err) == ErrorRetryable
+}
+
+// StepError records a failed step attempt in State.StepErrors, including
+// failures that a handler recovered from.
+type StepError struct {
+	Step      string     `json:"step"`
+	Class     ErrorClass `json:"class,omitempty"`
+	Attempt   int        `json:"attempt"`
+	Message   string     `json:"message"`
+	Timestamp time.Time  `json:"timestamp"`
+}
+
+func (e StepError) String() string {
+	if e.Class == "" {
+		return fmt.Sprintf("step '%s' failed (attempt %d): %s", e.Step, e.Attempt, e.Message)
+	}
+	return fmt.Sprintf("step '%s' failed (%s, attempt %d): %s", e.Step, e.Class, e.Attempt, e.Message)
+}
+
+// recordStepError adds err to the state's StepErrors and Errors.
+func (s *State) recordStepError(step string, err error, attempt int) {
+	entry := StepError{
+		Step:      step,
+		Class:     Classify(err),
+		Attempt:   attempt,
+		Message:   err.Error(),
+		Timestamp: time.Now(),
+	}
+	s.mu.Lock()
+	s.StepErrors = append(s.StepErrors, entry)
+	s.Errors = append(s.Errors, entry.String())
+	s.lastStepError = err
+	s.mu.Unlock()
+}
+
+// recorded reports whether err, or an error it wraps, is the step error
+// recorded last, as when a nested step's error reaches the top level.
+func (s *State) recorded(err error) bool {
+	s.mu.RLock()
+	last := s.lastStepError
+	s.mu.RUnlock()
+	return last != nil && errors.Is(err, last)
+}
+
+// MaxErrorGotos is the number of OnErrorGoto jumps one execution of a run
+// may take. The next failure that would jump fails the run instead.
+const MaxErrorGotos = 100
+
+// gotoError asks executeSteps to continue at another top-level step.
+type gotoError struct {
+	step string
+	err  error
+}
+
+func (e *gotoError) Error() string { return e.err.Error() }
+func (e *gotoError) Unwrap() error { return e.err }
+
+// stepIndex returns the index of the top-level step called name, or -1.
+func (w *Workflow) stepIndex(name string) int {
+	for i, step := range w.Steps {
+		if step.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// validateGotos checks that every OnErrorGoto names a top-level step.
+func (w *Workflow) validateGotos() error {
+	var err error
+	walkSteps(w.Steps, func(step Step) {
+		if as, ok := step.(*ActionStep); ok && err == nil && as.onErrorGoto != "" && w.stepIndex(as.onErrorGoto) < 0 {
+			err = fmt.Errorf("workflow %s: step '%s' goes to unknown step '%s' on error", w.Name, as.name, as.onErrorGoto)
+		}
+	})
+	return err
+}
@@ -0,0 +1,157 @@
+// Package workflow_test provides tests for step error handling.
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func failWith(err error) workflow.ActionHandler {
+	return func(ctx context.Context, state *workflow.State) (any, error) {
+		return nil, err
+	}
+}
+
+func succeed(name string) workflow.ActionHandler {
+	return func(ctx context.Context, state *workflow.State) (any, error) {
+		return name, nil
+	}
+}
+
+func TestActionStep_OnError(t *testing.T) {
+	wf := workflow.New("recover").
+		Step("reserve", failWith(errors.New("sold out"))).
+		OnError(func(ctx context.Context, state *workflow.State, err error) error {
+			state.Set("cleaned_up", err.Error())
+			return nil
+		}).Then().
+		Step("notify", succeed("notified")).Then().
+		Build()
+
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatalf("Expected the run to recover, got %v", err)
+	}
+	if state.GetString("cleaned_up") != "sold out" || state.StepResults["notify"] != "notified" {
+		t.Errorf("Expected cleanup and the next step to run, got %v %v", state.Data, state.StepResults)
+	}
+	if len(state.StepErrors) != 1 || state.StepErrors[0].Step != "reserve" || state.StepErrors[0].Attempt != 1 {
+		t.Errorf("Expected the recovered failure to be recorded, got %+v", state.StepErrors)
+	}
+
+	wf = workflow.New("rethrow").
+		Step("reserve", failWith(errors.New("sold out"))).
+		OnError(func(ctx context.Context, state *workflow.State, err error) error {
+			return errors.New("cleanup failed")
+		}).Then().
+		Build()
+	if _, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil); err == nil || !strings.Contains(err.Error(), "cleanup failed") {
+		t.Errorf("Expected the handler's error, got %v", err)
+	}
+}
+
+func TestActionStep_OnErrorGoto(t *testing.T) {
+	wf := workflow.New("goto").
+		Step("charge", failWith(errors.New("card declined"))).OnErrorGoto("fallback").Then().
+		Step("ship", succeed("shipped")).Then().
+		Step("fallback", succeed("invoiced")).Then().
+		Build()
+
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := state.StepResults["ship"]; ok || state.StepResults["fallback"] != "invoiced" {
+		t.Errorf("Expected to jump over ship to fallback, got %v", state.StepResults)
+	}
+
+	bad := workflow.New("bad-goto").
+		Step("charge", failWith(errors.New("x"))).OnErrorGoto("nowhere").Then().
+		Build()
+	if _, err := workflow.NewEngine(nil).Execute(context.Background(), bad, nil); err == nil || !strings.Contains(err.Error(), "unknown step 'nowhere'") {
+		t.Errorf("Expected a validation error, got %v", err)
+	}
+
+	// A step that jumps back to itself stops after MaxErrorGotos jumps.
+	loop := workflow.New("loop").
+		Step("poll", failWith(errors.New("not ready"))).OnErrorGoto("poll").Then().
+		Build()
+	state, err = workflow.NewEngine(nil).Execute(context.Background(), loop, nil)
+	if err == nil || !strings.Contains(err.Error(), "not ready") {
+		t.Fatalf("Expected the jump loop to fail the run, got %v", err)
+	}
+	if len(state.StepErrors) != workflow.MaxErrorGotos+1 {
+		t.Errorf("Expected %d attempts, got %d", workflow.MaxErrorGotos+1, len(state.StepErrors))
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	fast := func() *workflow.RetryPolicy {
+		return workflow.NewRetryPolicy().Attempts(3).Exponential(time.Millisecond, time.Millisecond)
+	}
+
+	t.Run("skippable", func(t *testing.T) {
+		wf := workflow.New("skip").
+			Step("enrich", failWith(workflow.Skippable(errors.New("no data")))).Retry(fast()).Then().
+			Step("save", succeed("saved")).Then().
+			Build()
+		state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil)
+		if err != nil || state.StepResults["save"] != "saved" {
+			t.Fatalf("Expected the run to continue, got %v", err)
+		}
+		if len(state.Errors) != 1 || state.Errors[0] != "step 'enrich' failed (skippable, attempt 1): no data" {
+			t.Errorf("Unexpected errors: %q", state.Errors)
+		}
+	})
+
+	t.Run("fatal", func(t *testing.T) {
+		handled := false
+		wf := workflow.New("fatal").
+			OnError(func(ctx context.Context, state *workflow.State, err error) error {
+				handled = true
+				return nil
+			}).
+			Step("charge", failWith(workflow.Fatal(errors.New("account closed")))).Retry(fast()).
+			OnErrorGoto("save").Then().
+			Step("save", succeed("saved")).Then().
+			Build()
+		state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil)
+		if err == nil || workflow.Classify(err) != workflow.ErrorFatal {
+			t.Fatalf("Expected a fatal error, got %v", err)
+		}
+		if handled || state.StepResults["save"] != nil {
+			t.Error("Fatal error was recovered")
+		}
+		if e := state.StepErrors[0]; e.Class != workflow.ErrorFatal || e.Attempt != 1 {
+			t.Errorf("Expected one fatal attempt, got %+v", e)
+		}
+	})
+
+	t.Run("retryable", func(t *testing.T) {
+		calls := 0
+		wf := workflow.New("retry").
+			Step("fetch", func(ctx context.Context, state *workflow.State) (any, error) {
+				calls++
+				if calls == 1 {
+					return nil, workflow.Retryable(errors.New("timeout"))
+				}
+				return nil, errors.New("bad request")
+			}).Retry(fast().OnError(workflow.IsRetryable)).Then().
+			Build()
+		state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil)
+		if err == nil || calls != 2 {
+			t.Fatalf("Expected to stop retrying at the unclassified error, got %d calls (%v)", calls, err)
+		}
+		if e := state.StepErrors[0]; e.Step != "fetch" || e.Class != "" || e.Attempt != 2 {
+			t.Errorf("Unexpected step error: %+v", e)
+		}
+		if len(state.Errors) != 1 || !strings.Contains(state.Errors[0], "attempt 2") {
+			t.Errorf("Expected one Errors entry with the attempt, got %q", state.Errors)
+		}
+	})
+}
@@ -0,0 +1,356 @@
+// Package workflow provides escalation for awaits that stall too long.
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/notify"
+)
+
+// HistoryEscalation is the history entry type recorded for fired escalations.
+const HistoryEscalation = "escalation"
+
+// EscalationKind identifies what an escalation does.
+type EscalationKind string
+
+const (
+	EscalateNotify      EscalationKind = "notify"
+	EscalateAutoApprove EscalationKind = "auto_approve"
+	EscalateAutoReject  EscalationKind = "auto_reject"
+	EscalateReroute     EscalationKind = "reroute"
+)
+
+// EscalationAction is performed when an await has been pending too long.
+type EscalationAction struct {
+	Kind      EscalationKind
+	Approvers []string // for EscalateReroute
+	Message   string
+}
+
+// Escalation pairs an age threshold with an action.
+type Escalation struct {
+	After  time.Duration
+	Action EscalationAction
+}
+
+// Notify returns an action that sends a notification through the engine notifier.
+func Notify(message string) EscalationAction {
+	return EscalationAction{Kind: EscalateNotify, Message: message}
+}
+
+// AutoApprove returns an action that resumes the await as approved.
+func AutoApprove() EscalationAction {
+	return EscalationAction{Kind: EscalateAutoApprove}
+}
+
+// AutoReject returns an action that fails the await.
+func AutoReject(reason string) EscalationAction {
+	return EscalationAction{Kind: EscalateAutoReject, Message: reason}
+}
+
+// Reroute returns an action that hands a pending approval to new approvers.
+func Reroute(approvers ...string) EscalationAction {
+	return EscalationAction{Kind: EscalateReroute, Approvers: approvers}
+}
+
+// EscalateAfter adds an escalation fired once the await has been pending for d.
+// Escalations fire in order, each at most once per execution.
+func (ab *AwaitBuilder) EscalateAfter(d time.Duration, action EscalationAction) *AwaitBuilder {
+	ab.step.escalations = append(ab.step.escalations, Escalation{After: d, Action: action})
+	sort.SliceStable(ab.step.escalations, func(i, j int) bool {
+		return ab.step.escalations[i].After < ab.step.escalations[j].After
+	})
+	return ab
+}
+
+// ============ Engine Integration ============
+
+type engineContextKey struct{}
+
+// now returns the current time according to the engine clock.
+func (e *Engine) now() time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.clock.Now()
+}
+
+func engineFromContext(ctx context.Context) (*Engine, bool) {
+	e, ok := ctx.Value(engineContextKey{}).(*Engine)
+	return e, ok && e != nil
+}
+
+// awaitOverride resolves an await from outside its normal channel.
+type awaitOverride struct {
+	approved bool
+	reason   string
+}
+
+func (e *Engine) registerAwait(stateID, step string) chan awaitOverride {
+	ch := make(chan awaitOverride, 1)
+	e.mu.Lock()
+	e.overrides[awaitKey{stateID, step}] = ch
+	e.mu.Unlock()
+	return ch
+}
+
+func (e *Engine) unregisterAwait(stateID, step string) {
+	e.mu.Lock()
+	delete(e.overrides, awaitKey{stateID, step})
+	e.mu.Unlock()
+}
+
+// resolveAwait resumes (approved=true) or fails the await step of a
+// running state.
+func (e *Engine) resolveAwait(stateID, step string, approved bool, reason string) bool {
+	e.mu.RLock()
+	ch, ok := e.overrides[awaitKey{stateID, step}]
+	e.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	select {
+	case ch <- awaitOverride{approved: approved, reason: reason}:
+		return true
+	default:
+		return false
+	}
+}
+
+type awaitingExecution struct {
+	state *State
+	step  *AwaitStep
+	since time.Time
+}
+
+// awaiting returns running executions currently blocked in an await step.
+func (e *Engine) awaiting() []awaitingExecution {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var out []awaitingExecution
+	for id, state := range e.running {
+		state.mu.RLock()
+		stepName, since := state.AwaitingStep, state.AwaitingSince
+		state.mu.RUnlock()
+		if stepName == "" {
+			continue
+		}
+
+		var step *AwaitStep
+		if wf, ok := e.definitions[id]; ok {
+			walkSteps(wf.Steps, func(s Step) {
+				if as, ok := s.(*AwaitStep); ok && as.name == stepName {
+					step = as
+				}
+			})
+		}
+		if step != nil {
+			out = append(out, awaitingExecution{state: state, step: step, since: since})
+		}
+	}
+	return out
+}
+
+// CheckEscalations fires every escalation that has become due for awaiting
+// executions and returns how many fired. Each escalation fires at most once
+// per execution and is recorded in the state history.
+func (e *Engine) CheckEscalations(ctx context.Context) int {
+	now := e.now()
+
+	fired := 0
+	for _, a := range e.awaiting() {
+		age := now.Sub(a.since)
+		changed := false
+		for i, esc := range a.step.escalations {
+			if age < esc.After {
+				break
+			}
+			if !a.state.markEscalated(a.step.name, i+1, esc, now) {
+				continue
+			}
+			fired++
+			changed = true
+			if err := e.escalate(ctx, a, esc, age); err != nil {
+				a.state.mu.Lock()
+				a.state.Errors = append(a.state.Errors, fmt.Sprintf("escalation %d of '%s' failed: %v", i+1, a.step.name, err))
+				a.state.mu.Unlock()
+			}
+		}
+		if changed && e.persistence != nil {
+			e.persistence.Save(ctx, a.state)
+		}
+	}
+	return fired
+}
+
+// markEscalated records an escalation in the history unless it already fired.
+func (s *State) markEscalated(step string, index int, esc Escalation, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, h := range s.History {
+		if h.Type == HistoryEscalation && h.Step == step && h.Escalation == index {
+			return false
+		}
+	}
+	s.History = append(s.History, HistoryEntry{
+		Type:       HistoryEscalation,
+		Step:       step,
+		Message:    fmt.Sprintf("%s after %v", esc.Action.Kind, esc.After),
+		Escalation: index,
+		Timestamp:  now,
+	})
+	return true
+}
+
+func (e *Engine) escalate(ctx context.Context, a awaitingExecution, esc Escalation, age time.Duration) error {
+	switch esc.Action.Kind {
+	case EscalateNotify:
+		e.mu.RLock()
+		n := e.notifier
+		e.mu.RUnlock()
+		if n == nil {
+			return nil
+		}
+		body := esc.Action.Message
+		if body == "" {
+			body = fmt.Sprintf("Step '%s' has been awaiting for %v", a.step.name, age.Round(time.Second))
+		}
+		return n.Notify(ctx, notify.Message{
+			Title:    fmt.Sprintf("Workflow %s is stalled", a.state.ID),
+			Body:     body,
+			Severity: notify.SeverityWarning,
+			Fields: map[string]string{
+				"state_id": a.state.ID,
+				"step":     a.step.name,
+				"age":      age.String(),
+			},
+			Timestamp: e.now(),
+		})
+	case EscalateAutoApprove:
+		if !e.resolveAwait(a.state.ID, a.step.name, true, "") {
+			return fmt.Errorf("await is no longer pending")
+		}
+	case EscalateAutoReject:
+		if !e.resolveAwait(a.state.ID, a.step.name, false, esc.Action.Message) {
+			return fmt.Errorf("await is no longer pending")
+		}
+	case EscalateReroute:
+		if a.step.awaitType != AwaitTypeApproval {
+			return fmt.Errorf("reroute requires an approval await")
+		}
+		if err := e.approvals.Reroute(a.state.ID, a.step.name, esc.Action.Approvers); err != nil {
+			return err
+		}
+		a.state.Set("_awaiting_approvers", esc.Action.Approvers)
+	default:
+		return fmt.Errorf("unknown escalation action: %s", esc.Action.Kind)
+	}
+	return nil
+}
+
+// StartEscalationMonitor checks escalations every interval until ctx is done.
+func (e *Engine) StartEscalationMonitor(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.CheckEscalations(ctx)
+			}
+		}
+	}()
+}
+
+// ============ Awaiting Summary ============
+
+// AwaitingExecution describes a single execution blocked in an await step.
+type AwaitingExecution struct {
+	StateID     string        `json:"state_id"`
+	Workflow    string        `json:"workflow"`
+	Step        string        `json:"step"`
+	Type        string        `json:"type"`
+	Since       time.Time     `json:"since"`
+	Age         time.Duration `json:"age"`
+	Bucket      string        `json:"bucket"`
+	Escalations int           `json:"escalations"`
+}
+
+// AwaitingSummary groups awaiting executions by age.
+type AwaitingSummary struct {
+	Total      int                 `json:"total"`
+	Buckets    map[string]int      `json:"buckets"`
+	Executions []AwaitingExecution `json:"executions"`
+}
+
+// AwaitingBuckets are the age buckets reported by AwaitingSummary, in order.
+var AwaitingBuckets = []string{"<1h", "1h-24h", "1d-7d", ">7d"}
+
+func ageBucket(age time.Duration) string {
+	switch {
+	case age < time.Hour:
+		return AwaitingBuckets[0]
+	case age < 24*time.Hour:
+		return AwaitingBuckets[1]
+	case age < 7*24*time.Hour:
+		return AwaitingBuckets[2]
+	default:
+		return AwaitingBuckets[3]
+	}
+}
+
+// AwaitingSummary reports executions blocked in await steps, oldest first.
+func (e *Engine) AwaitingSummary() AwaitingSummary {
+	now := e.now()
+
+	summary := AwaitingSummary{
+		Buckets:    make(map[string]int, len(AwaitingBuckets)),
+		Executions: []AwaitingExecution{},
+	}
+	for _, b := range AwaitingBuckets {
+		summary.Buckets[b] = 0
+	}
+
+	for _, a := range e.awaiting() {
+		age := now.Sub(a.since)
+		item := AwaitingExecution{
+			StateID:  a.state.ID,
+			Workflow: a.state.WorkflowID,
+			Step:     a.step.name,
+			Type:     "signal",
+			Since:    a.since,
+			Age:      age,
+			Bucket:   ageBucket(age),
+		}
+		if a.step.awaitType == AwaitTypeApproval {
+			item.Type = "approval"
+		}
+		a.state.mu.RLock()
+		for _, h := range a.state.History {
+			if h.Type == HistoryEscalation && h.Step == a.step.name {
+				item.Escalations++
+			}
+		}
+		a.state.mu.RUnlock()
+
+		summary.Buckets[item.Bucket]++
+		summary.Executions = append(summary.Executions, item)
+	}
+	summary.Total = len(summary.Executions)
+
+	sort.Slice(summary.Executions, func(i, j int) bool {
+		ei, ej := summary.Executions[i], summary.Executions[j]
+		if !ei.Since.Equal(ej.Since) {
+			return ei.Since.Before(ej.Since)
+		}
+		return strings.Compare(ei.StateID, ej.StateID) < 0
+	})
+	return summary
+}
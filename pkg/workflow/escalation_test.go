@@ -0,0 +1,184 @@
+// Package workflow_test provides tests for await escalations.
+package workflow_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/notify"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+type recordingNotifier struct {
+	messages []notify.Message
+	mu       sync.Mutex
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, msg notify.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func (r *recordingNotifier) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.messages)
+}
+
+type execResult struct {
+	state *workflow.State
+	err   error
+}
+
+// startAwaiting runs wf in the background and waits until it is blocked in an await.
+func startAwaiting(t *testing.T, engine *workflow.Engine, wf *workflow.Workflow) <-chan execResult {
+	t.Helper()
+	done := make(chan execResult, 1)
+	go func() {
+		state, err := engine.Execute(context.Background(), wf, nil)
+		done <- execResult{state, err}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for engine.AwaitingSummary().Total == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Workflow never started awaiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return done
+}
+
+func TestEscalation_NotifyThenAutoReject(t *testing.T) {
+	clock := workflow.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	notifier := &recordingNotifier{}
+
+	engine := workflow.NewEngine(nil)
+	engine.SetClock(clock)
+	engine.SetNotifier(notifier)
+
+	wf := workflow.New("expense").
+		AwaitApproval("manager", []string{"alice"}).
+		EscalateAfter(48*time.Hour, workflow.AutoReject("no response")).
+		EscalateAfter(24*time.Hour, workflow.Notify("")).
+		Then().
+		Build()
+
+	done := startAwaiting(t, engine, wf)
+
+	clock.Advance(time.Hour)
+	if n := engine.CheckEscalations(context.Background()); n != 0 {
+		t.Fatalf("Expected no escalations after 1h, got %d", n)
+	}
+
+	clock.Advance(24 * time.Hour)
+	if n := engine.CheckEscalations(context.Background()); n != 1 {
+		t.Fatalf("Expected notify escalation, got %d", n)
+	}
+	if n := engine.CheckEscalations(context.Background()); n != 0 {
+		t.Fatalf("Escalation fired twice, got %d", n)
+	}
+	if notifier.count() != 1 {
+		t.Fatalf("Expected 1 notification, got %d", notifier.count())
+	}
+
+	summary := engine.AwaitingSummary()
+	if summary.Buckets["1d-7d"] != 1 || summary.Executions[0].Escalations != 1 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+
+	clock.Advance(24 * time.Hour)
+	if n := engine.CheckEscalations(context.Background()); n != 1 {
+		t.Fatalf("Expected auto-reject escalation, got %d", n)
+	}
+
+	var res execResult
+	select {
+	case res = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Workflow did not finish after auto-reject")
+	}
+	if res.err == nil || !strings.Contains(res.err.Error(), "no response") {
+		t.Fatalf("Expected rejection error, got %v", res.err)
+	}
+	if notifier.count() != 1 {
+		t.Errorf("Expected notify to fire once, got %d", notifier.count())
+	}
+
+	var escalations []workflow.HistoryEntry
+	for _, h := range res.state.History {
+		if h.Type == workflow.HistoryEscalation {
+			escalations = append(escalations, h)
+		}
+	}
+	if len(escalations) != 2 {
+		t.Fatalf("Expected 2 escalations in history, got %+v", res.state.History)
+	}
+	if escalations[0].Escalation != 1 || escalations[1].Escalation != 2 {
+		t.Errorf("Escalations recorded out of order: %+v", escalations)
+	}
+}
+
+func TestEscalation_AutoApprove(t *testing.T) {
+	clock := workflow.NewFakeClock(time.Now())
+	engine := workflow.NewEngine(nil)
+	engine.SetClock(clock)
+
+	wf := workflow.New("deploy").
+		AwaitSignal("wait-ci", "ci_passed").
+		EscalateAfter(time.Hour, workflow.AutoApprove()).
+		Then().
+		Build()
+
+	done := startAwaiting(t, engine, wf)
+	clock.Advance(2 * time.Hour)
+	engine.CheckEscalations(context.Background())
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			t.Fatalf("Expected auto-approve to resume, got %v", res.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Workflow did not resume")
+	}
+}
+
+func TestEscalation_Reroute(t *testing.T) {
+	clock := workflow.NewFakeClock(time.Now())
+	engine := workflow.NewEngine(nil)
+	engine.SetClock(clock)
+
+	wf := workflow.New("purchase").
+		AwaitApproval("approve", []string{"alice"}).
+		EscalateAfter(time.Hour, workflow.Reroute("bob")).
+		Then().
+		Build()
+
+	done := startAwaiting(t, engine, wf)
+	stateID := engine.AwaitingSummary().Executions[0].StateID
+
+	clock.Advance(2 * time.Hour)
+	engine.CheckEscalations(context.Background())
+
+	if err := engine.Approve(context.Background(), stateID, "approve", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			t.Fatalf("Expected rerouted approval to resume, got %v", res.err)
+		}
+		if res.state.Data["_approved"] != true {
+			t.Error("Expected approval recorded in state")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Workflow did not resume")
+	}
+}
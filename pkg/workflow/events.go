@@ -0,0 +1,80 @@
+package workflow
+
+import (
+	"context"
+	"time"
+)
+
+// Run event types.
+const (
+	EventRunStarted    = "workflow.started"
+	EventStepCompleted = "workflow.step_completed"
+	EventStepFailed    = "workflow.step_failed"
+	EventRunCompleted  = "workflow.completed"
+	EventRunFailed     = "workflow.failed"
+	EventRunCancelled  = "workflow.cancelled"
+)
+
+// RunEvent reports the progress of a workflow run. Preview runs do not
+// emit events.
+type RunEvent struct {
+	Type      string    `json:"type"`
+	RunID     string    `json:"run_id"`
+	Workflow  string    `json:"workflow"`
+	Step      string    `json:"step,omitempty"`
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Execution is set on EventStepExecuted events.
+	Execution *StepExecution `json:"execution,omitempty"`
+}
+
+// Final reports whether ev is the last event of its run.
+func (ev RunEvent) Final() bool {
+	return ev.Type == EventRunCompleted || ev.Type == EventRunFailed || ev.Type == EventRunCancelled
+}
+
+// Subscribe registers fn to receive the events of every run. Listeners are
+// called synchronously on the run's goroutine.
+func (e *Engine) Subscribe(fn func(ctx context.Context, ev RunEvent)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.listeners = append(e.listeners, fn)
+}
+
+// emit sends an event about state to the listeners.
+func (e *Engine) emit(ctx context.Context, state *State, eventType, step string, err error) {
+	e.publish(ctx, state, eventType, step, err, nil)
+}
+
+// publish sends an event, optionally carrying a step execution, to the
+// listeners.
+func (e *Engine) publish(ctx context.Context, state *State, eventType, step string, err error, exec *StepExecution) {
+	if state.Preview {
+		return
+	}
+	e.mu.RLock()
+	listeners := e.listeners
+	e.mu.RUnlock()
+	if len(listeners) == 0 {
+		return
+	}
+
+	state.mu.RLock()
+	ev := RunEvent{
+		Type:      eventType,
+		RunID:     state.ID,
+		Workflow:  state.Workflow,
+		Step:      step,
+		Status:    state.Status,
+		Timestamp: e.now(),
+		Execution: exec,
+	}
+	state.mu.RUnlock()
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	for _, fn := range listeners {
+		fn(ctx, ev)
+	}
+}
@@ -0,0 +1,67 @@
+// Package workflow_test provides tests for workflow run events.
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/metrics"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func TestEngine_Subscribe(t *testing.T) {
+	engine := workflow.NewEngine(nil)
+	var events []string
+	engine.Subscribe(func(ctx context.Context, ev workflow.RunEvent) {
+		events = append(events, ev.Type+" "+ev.Step)
+		if ev.Final() && ev.Type == workflow.EventRunFailed && !strings.Contains(ev.Error, "card declined") {
+			t.Errorf("Expected the failure in the final event, got %q", ev.Error)
+		}
+	})
+
+	wf := workflow.New("order").
+		Step("reserve", func(ctx context.Context, state *workflow.State) (any, error) { return "ok", nil }).Then().
+		Step("charge", func(ctx context.Context, state *workflow.State) (any, error) {
+			return nil, errors.New("card declined")
+		}).Then().
+		Build()
+	if _, err := engine.Execute(context.Background(), wf, nil); err == nil {
+		t.Fatal("Expected the run to fail")
+	}
+
+	want := "workflow.started |workflow.step_executed reserve|workflow.step_completed reserve|" +
+		"workflow.step_executed charge|workflow.step_failed charge|workflow.failed "
+	if got := strings.Join(events, "|"); got != want {
+		t.Errorf("Unexpected events:\n%s\nwant:\n%s", got, want)
+	}
+
+	events = nil
+	if _, err := engine.ExecutePrefix(context.Background(), wf, nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events from a preview, got %q", events)
+	}
+}
+
+func TestEngine_RecordsRunExemplar(t *testing.T) {
+	duration := metrics.DefaultMetrics.WorkflowDuration
+	duration.EnableExemplars(1)
+	defer duration.EnableExemplars(0)
+
+	wf := workflow.New("report").
+		Step("render", func(ctx context.Context, state *workflow.State) (any, error) { return "ok", nil }).Then().
+		Build()
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ex := range duration.Exemplars() {
+		if ex.Labels["run_id"] == state.ID {
+			return
+		}
+	}
+	t.Errorf("Expected an exemplar for run %s, got %+v", state.ID, duration.Exemplars())
+}
@@ -0,0 +1,1271 @@
+// Package workflow provides a restricted expression language for workflows.
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultExprMaxSteps bounds the number of evaluation steps a script may take.
+const DefaultExprMaxSteps = 10000
+
+// DefaultExprMaxSize bounds the strings, in bytes, and the arrays and
+// objects, in elements, a script may build.
+const DefaultExprMaxSize = 1 << 20
+
+// Expr is a compiled expression script.
+//
+// A script is a sequence of let-bindings followed by a result expression:
+//
+//	let total = sum(map(results.items, x => x.price));
+//	{ total: total, label: upper(data.customer) + ": " + str(total) }
+//
+// A builtin can also be applied as a filter: x | f(a) is f(x, a), so
+// data.region | default("eu") | upper reads left to right.
+//
+// Scripts have no I/O and no mutation; every evaluation is bounded by
+// MaxSteps so runaway recursion fails instead of hanging the workflow, and
+// by MaxSize so repeated doubling fails instead of exhausting memory.
+type Expr struct {
+	Source   string
+	MaxSteps int
+	MaxSize  int
+	prog     *program
+}
+
+// Pos is a position in the script source.
+type Pos struct {
+	Line int
+	Col  int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// ExprError is a compile or runtime error with its script position.
+type ExprError struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("expr %s: %s", e.Pos, e.Msg)
+}
+
+func errorf(p Pos, format string, args ...any) error {
+	return &ExprError{Pos: p, Msg: fmt.Sprintf(format, args...)}
+}
+
+// CompileExpr parses a script.
+func CompileExpr(src string) (*Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	prog, err := p.parseProgram()
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{Source: src, MaxSteps: DefaultExprMaxSteps, MaxSize: DefaultExprMaxSize, prog: prog}, nil
+}
+
+// EvalExpr compiles and evaluates a script in one call.
+func EvalExpr(src string, vars map[string]any) (any, error) {
+	e, err := CompileExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	return e.Eval(vars)
+}
+
+// Eval evaluates the script with the given variables in scope.
+// Numbers are returned as float64, arrays as []any and objects as map[string]any.
+func (e *Expr) Eval(vars map[string]any) (any, error) {
+	max := e.MaxSteps
+	if max <= 0 {
+		max = DefaultExprMaxSteps
+	}
+	maxSize := e.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultExprMaxSize
+	}
+	ev := &evaluator{max: max, maxSize: maxSize}
+	env := &scope{vars: make(map[string]any, len(vars))}
+	for k, v := range vars {
+		env.vars[k] = v
+	}
+	return ev.run(e.prog, env)
+}
+
+// ExprCondition returns a Condition that evaluates a script against the state.
+// Evaluation errors are treated as false.
+func ExprCondition(src string) Condition {
+	e, err := CompileExpr(src)
+	return func(state *State) bool {
+		if err != nil {
+			return false
+		}
+		v, evalErr := e.Eval(stateVars(state))
+		return evalErr == nil && truthy(v)
+	}
+}
+
+// stateVars builds the read-only view of state exposed to scripts.
+func stateVars(state *State) map[string]any {
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	data := make(map[string]any, len(state.Data))
+	for k, v := range state.Data {
+		data[k] = v
+	}
+	results := make(map[string]any, len(state.StepResults))
+	for k, v := range state.StepResults {
+		results[k] = v
+	}
+	return map[string]any{
+		"data":        data,
+		"results":     results,
+		"workflow_id": state.WorkflowID,
+		"id":          state.run().ID,
+	}
+}
+
+// ============ Lexer ============
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  Pos
+}
+
+var punctuators = []string{
+	"==", "!=", "<=", ">=", "&&", "||", "=>",
+	"+", "-", "*", "/", "%", "<", ">", "!", "=", "|",
+	"(", ")", "[", "]", "{", "}", ",", ":", ";", ".", "?",
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	rs := []rune(src)
+	line, col := 1, 1
+	i := 0
+
+	advance := func(n int) {
+		for k := 0; k < n; k++ {
+			if rs[i] == '\n' {
+				line++
+				col = 1
+			} else {
+				col++
+			}
+			i++
+		}
+	}
+
+	for i < len(rs) {
+		r := rs[i]
+		pos := Pos{Line: line, Col: col}
+
+		switch {
+		case unicode.IsSpace(r):
+			advance(1)
+
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '/':
+			for i < len(rs) && rs[i] != '\n' {
+				advance(1)
+			}
+
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.' || rs[i] == 'e' || rs[i] == 'E') {
+				advance(1)
+			}
+			text := string(rs[start:i])
+			n, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, errorf(pos, "invalid number %q", text)
+			}
+			toks = append(toks, token{kind: tokNumber, text: text, num: n, pos: pos})
+
+		case r == '"' || r == '\'':
+			quote := r
+			advance(1)
+			var sb strings.Builder
+			closed := false
+			for i < len(rs) {
+				c := rs[i]
+				if c == quote {
+					advance(1)
+					closed = true
+					break
+				}
+				if c == '\\' && i+1 < len(rs) {
+					advance(1)
+					switch rs[i] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 't':
+						sb.WriteRune('\t')
+					default:
+						sb.WriteRune(rs[i])
+					}
+					advance(1)
+					continue
+				}
+				sb.WriteRune(c)
+				advance(1)
+			}
+			if !closed {
+				return nil, errorf(pos, "unterminated string")
+			}
+			toks = append(toks, token{kind: tokString, text: sb.String(), pos: pos})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				advance(1)
+			}
+			toks = append(toks, token{kind: tokIdent, text: string(rs[start:i]), pos: pos})
+
+		default:
+			matched := ""
+			for _, p := range punctuators {
+				if strings.HasPrefix(string(rs[i:min(i+2, len(rs))]), p) {
+					matched = p
+					break
+				}
+			}
+			if matched == "" {
+				return nil, errorf(pos, "unexpected character %q", r)
+			}
+			advance(len(matched))
+			toks = append(toks, token{kind: tokPunct, text: matched, pos: pos})
+		}
+	}
+
+	toks = append(toks, token{kind: tokEOF, pos: Pos{Line: line, Col: col}})
+	return toks, nil
+}
+
+// ============ Parser ============
+
+type node interface {
+	position() Pos
+}
+
+type (
+	literalNode struct {
+		pos   Pos
+		value any
+	}
+	identNode struct {
+		pos  Pos
+		name string
+	}
+	arrayNode struct {
+		pos   Pos
+		items []node
+	}
+	objectNode struct {
+		pos    Pos
+		keys   []string
+		values []node
+	}
+	unaryNode struct {
+		pos Pos
+		op  string
+		x   node
+	}
+	binaryNode struct {
+		pos  Pos
+		op   string
+		x, y node
+	}
+	ternaryNode struct {
+		pos               Pos
+		cond, then, other node
+	}
+	memberNode struct {
+		pos  Pos
+		x    node
+		name string
+	}
+	indexNode struct {
+		pos   Pos
+		x     node
+		index node
+	}
+	callNode struct {
+		pos  Pos
+		fn   node
+		args []node
+	}
+	lambdaNode struct {
+		pos    Pos
+		params []string
+		body   node
+	}
+)
+
+func (n *literalNode) position() Pos { return n.pos }
+func (n *identNode) position() Pos   { return n.pos }
+func (n *arrayNode) position() Pos   { return n.pos }
+func (n *objectNode) position() Pos  { return n.pos }
+func (n *unaryNode) position() Pos   { return n.pos }
+func (n *binaryNode) position() Pos  { return n.pos }
+func (n *ternaryNode) position() Pos { return n.pos }
+func (n *memberNode) position() Pos  { return n.pos }
+func (n *indexNode) position() Pos   { return n.pos }
+func (n *callNode) position() Pos    { return n.pos }
+func (n *lambdaNode) position() Pos  { return n.pos }
+
+type binding struct {
+	pos   Pos
+	name  string
+	value node
+}
+
+type program struct {
+	lets   []binding
+	result node
+}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) is(text string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == text
+}
+
+func (p *parser) accept(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) (token, error) {
+	t := p.peek()
+	if t.kind != tokPunct || t.text != text {
+		return t, errorf(t.pos, "expected %q, found %s", text, describe(t))
+	}
+	return p.next(), nil
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokEOF:
+		return "end of script"
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+func (p *parser) parseProgram() (*program, error) {
+	prog := &program{}
+	for p.peek().kind == tokIdent && p.peek().text == "let" {
+		letTok := p.next()
+		name := p.next()
+		if name.kind != tokIdent {
+			return nil, errorf(name.pos, "expected name after let, found %s", describe(name))
+		}
+		if isKeyword(name.text) {
+			return nil, errorf(name.pos, "cannot bind reserved word %q", name.text)
+		}
+		if _, err := p.expect("="); err != nil {
+			return nil, err
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		prog.lets = append(prog.lets, binding{pos: letTok.pos, name: name.text, value: value})
+	}
+
+	if p.peek().kind == tokEOF {
+		return nil, errorf(p.peek().pos, "script has no result expression")
+	}
+	result, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	p.accept(";")
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorf(t.pos, "unexpected %s", describe(t))
+	}
+	prog.result = result
+	return prog, nil
+}
+
+func isKeyword(s string) bool {
+	switch s {
+	case "let", "true", "false", "null":
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseExpr() (node, error) {
+	x, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("|") {
+		bar := p.next()
+		name := p.next()
+		if name.kind != tokIdent {
+			return nil, errorf(name.pos, "expected filter name after '|', found %s", describe(name))
+		}
+		call := &callNode{pos: bar.pos, fn: &identNode{pos: name.pos, name: name.text}, args: []node{x}}
+		if p.accept("(") {
+			args, err := p.parseList(")")
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, args...)
+		}
+		x = call
+	}
+	return x, nil
+}
+
+func (p *parser) parseTernary() (node, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.is("?") {
+		return cond, nil
+	}
+	q := p.next()
+	then, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	other, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &ternaryNode{pos: q.pos, cond: cond, then: then, other: other}, nil
+}
+
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(precedence) {
+		return p.parseUnary()
+	}
+	x, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokPunct || !containsString(precedence[level], t.text) {
+			return x, nil
+		}
+		p.next()
+		y, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{pos: t.pos, op: t.text, x: x, y: y}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.is("!") || p.is("-") {
+		t := p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{pos: t.pos, op: t.text, x: x}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.is("."):
+			dot := p.next()
+			name := p.next()
+			if name.kind != tokIdent {
+				return nil, errorf(name.pos, "expected field name after '.', found %s", describe(name))
+			}
+			x = &memberNode{pos: dot.pos, x: x, name: name.text}
+		case p.is("["):
+			open := p.next()
+			idx, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &indexNode{pos: open.pos, x: x, index: idx}
+		case p.is("("):
+			open := p.next()
+			args, err := p.parseList(")")
+			if err != nil {
+				return nil, err
+			}
+			x = &callNode{pos: open.pos, fn: x, args: args}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) parseList(closer string) ([]node, error) {
+	var items []node
+	for !p.is(closer) {
+		item, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if _, err := p.expect(closer); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+
+	switch t.kind {
+	case tokNumber:
+		p.next()
+		return &literalNode{pos: t.pos, value: t.num}, nil
+
+	case tokString:
+		p.next()
+		return &literalNode{pos: t.pos, value: t.text}, nil
+
+	case tokIdent:
+		// Single-parameter lambda: x => body
+		if next := p.toks[p.i+1]; next.kind == tokPunct && next.text == "=>" {
+			p.next()
+			p.next()
+			body, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return &lambdaNode{pos: t.pos, params: []string{t.text}, body: body}, nil
+		}
+		p.next()
+		switch t.text {
+		case "true":
+			return &literalNode{pos: t.pos, value: true}, nil
+		case "false":
+			return &literalNode{pos: t.pos, value: false}, nil
+		case "null":
+			return &literalNode{pos: t.pos, value: nil}, nil
+		case "let":
+			return nil, errorf(t.pos, "let is only allowed at the start of a script")
+		}
+		return &identNode{pos: t.pos, name: t.text}, nil
+
+	case tokPunct:
+		switch t.text {
+		case "(":
+			if params, ok := p.lambdaParams(); ok {
+				body, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				return &lambdaNode{pos: t.pos, params: params, body: body}, nil
+			}
+			p.next()
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+
+		case "[":
+			p.next()
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &arrayNode{pos: t.pos, items: items}, nil
+
+		case "{":
+			p.next()
+			return p.parseObject(t.pos)
+		}
+	}
+
+	return nil, errorf(t.pos, "unexpected %s", describe(t))
+}
+
+// lambdaParams consumes "(a, b) =>" if present.
+func (p *parser) lambdaParams() ([]string, bool) {
+	j := p.i + 1
+	var params []string
+	for {
+		t := p.toks[j]
+		if t.kind == tokPunct && t.text == ")" && len(params) == 0 {
+			break
+		}
+		if t.kind != tokIdent {
+			return nil, false
+		}
+		params = append(params, t.text)
+		j++
+		if sep := p.toks[j]; sep.kind == tokPunct && sep.text == "," {
+			j++
+			continue
+		}
+		break
+	}
+	if t := p.toks[j]; t.kind != tokPunct || t.text != ")" {
+		return nil, false
+	}
+	if t := p.toks[j+1]; t.kind != tokPunct || t.text != "=>" {
+		return nil, false
+	}
+	p.i = j + 2
+	return params, true
+}
+
+func (p *parser) parseObject(pos Pos) (node, error) {
+	obj := &objectNode{pos: pos}
+	for !p.is("}") {
+		key := p.next()
+		if key.kind != tokIdent && key.kind != tokString {
+			return nil, errorf(key.pos, "expected object key, found %s", describe(key))
+		}
+		if _, err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		obj.keys = append(obj.keys, key.text)
+		obj.values = append(obj.values, value)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if _, err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// ============ Evaluator ============
+
+type scope struct {
+	vars   map[string]any
+	parent *scope
+}
+
+func (s *scope) lookup(name string) (any, bool) {
+	for sc := s; sc != nil; sc = sc.parent {
+		if v, ok := sc.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+type closure struct {
+	params []string
+	body   node
+	env    *scope
+}
+
+type builtinFunc struct {
+	name string
+	fn   func(ev *evaluator, pos Pos, args []any) (any, error)
+}
+
+type evaluator struct {
+	steps   int
+	max     int
+	maxSize int
+}
+
+func (ev *evaluator) tick(pos Pos) error {
+	ev.steps++
+	if ev.steps > ev.max {
+		return errorf(pos, "execution step limit of %d exceeded", ev.max)
+	}
+	return nil
+}
+
+// checkSize fails when a value of size n, in bytes for strings and
+// elements for arrays and objects, would exceed the size limit.
+func (ev *evaluator) checkSize(pos Pos, n int) error {
+	if n > ev.maxSize {
+		return errorf(pos, "result size %d exceeds the limit of %d", n, ev.maxSize)
+	}
+	return nil
+}
+
+// str is toString within the size limit. Arrays and objects are measured
+// before they are encoded, as they may repeat one large value many times.
+func (ev *evaluator) str(pos Pos, v any) (string, error) {
+	v = normalize(v)
+	switch v.(type) {
+	case []any, map[string]any:
+		if err := ev.checkSize(pos, encodedSize(v, ev.maxSize)); err != nil {
+			return "", err
+		}
+	}
+	s := toString(v)
+	return s, ev.checkSize(pos, len(s))
+}
+
+// encodedSize returns about the length of the JSON encoding of v, giving
+// up once it passes limit.
+func encodedSize(v any, limit int) int {
+	switch x := v.(type) {
+	case string:
+		return len(x) + 2
+	case []any:
+		n := 2
+		for _, item := range x {
+			if n += encodedSize(item, limit-n) + 1; n > limit {
+				break
+			}
+		}
+		return n
+	case map[string]any:
+		n := 2
+		for k, item := range x {
+			if n += len(k) + 4 + encodedSize(item, limit-n); n > limit {
+				break
+			}
+		}
+		return n
+	default:
+		return len(toString(x))
+	}
+}
+
+func (ev *evaluator) run(prog *program, env *scope) (any, error) {
+	for _, b := range prog.lets {
+		v, err := ev.eval(b.value, env)
+		if err != nil {
+			return nil, err
+		}
+		env.vars[b.name] = v
+	}
+	v, err := ev.eval(prog.result, env)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := v.(*closure); ok {
+		return nil, errorf(prog.result.position(), "script result cannot be a function")
+	}
+	return v, nil
+}
+
+func (ev *evaluator) eval(n node, env *scope) (any, error) {
+	if err := ev.tick(n.position()); err != nil {
+		return nil, err
+	}
+
+	switch n := n.(type) {
+	case *literalNode:
+		return n.value, nil
+
+	case *identNode:
+		if v, ok := env.lookup(n.name); ok {
+			return normalize(v), nil
+		}
+		if b, ok := builtins[n.name]; ok {
+			return b, nil
+		}
+		return nil, errorf(n.pos, "undefined name %q", n.name)
+
+	case *arrayNode:
+		out := make([]any, 0, len(n.items))
+		for _, item := range n.items {
+			v, err := ev.eval(item, env)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+
+	case *objectNode:
+		out := make(map[string]any, len(n.keys))
+		for i, k := range n.keys {
+			v, err := ev.eval(n.values[i], env)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = v
+		}
+		return out, nil
+
+	case *unaryNode:
+		x, err := ev.eval(n.x, env)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "!" {
+			return !truthy(x), nil
+		}
+		f, ok := x.(float64)
+		if !ok {
+			return nil, errorf(n.pos, "cannot negate %s", typeName(x))
+		}
+		return -f, nil
+
+	case *binaryNode:
+		return ev.evalBinary(n, env)
+
+	case *ternaryNode:
+		c, err := ev.eval(n.cond, env)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(c) {
+			return ev.eval(n.then, env)
+		}
+		return ev.eval(n.other, env)
+
+	case *memberNode:
+		x, err := ev.eval(n.x, env)
+		if err != nil {
+			return nil, err
+		}
+		obj, ok := x.(map[string]any)
+		if !ok {
+			return nil, errorf(n.pos, "cannot read field %q of %s", n.name, typeName(x))
+		}
+		return normalize(obj[n.name]), nil
+
+	case *indexNode:
+		x, err := ev.eval(n.x, env)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := ev.eval(n.index, env)
+		if err != nil {
+			return nil, err
+		}
+		return index(n.pos, x, idx)
+
+	case *callNode:
+		fn, err := ev.eval(n.fn, env)
+		if err != nil {
+			return nil, err
+		}
+		args := make([]any, 0, len(n.args))
+		for _, a := range n.args {
+			v, err := ev.eval(a, env)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, v)
+		}
+		return ev.call(n.pos, fn, args)
+
+	case *lambdaNode:
+		return &closure{params: n.params, body: n.body, env: env}, nil
+	}
+
+	return nil, errorf(n.position(), "unsupported expression")
+}
+
+func (ev *evaluator) call(pos Pos, fn any, args []any) (any, error) {
+	switch f := fn.(type) {
+	case *builtinFunc:
+		return f.fn(ev, pos, args)
+	case *closure:
+		if len(args) < len(f.params) {
+			return nil, errorf(pos, "function expects %d arguments, got %d", len(f.params), len(args))
+		}
+		local := &scope{vars: make(map[string]any, len(f.params)), parent: f.env}
+		for i, p := range f.params {
+			local.vars[p] = args[i]
+		}
+		return ev.eval(f.body, local)
+	}
+	return nil, errorf(pos, "%s is not callable", typeName(fn))
+}
+
+func (ev *evaluator) evalBinary(n *binaryNode, env *scope) (any, error) {
+	x, err := ev.eval(n.x, env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logical operators short-circuit and yield the deciding operand.
+	switch n.op {
+	case "&&":
+		if !truthy(x) {
+			return x, nil
+		}
+		return ev.eval(n.y, env)
+	case "||":
+		if truthy(x) {
+			return x, nil
+		}
+		return ev.eval(n.y, env)
+	}
+
+	y, err := ev.eval(n.y, env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(x, y), nil
+	case "!=":
+		return !equal(x, y), nil
+	case "+":
+		if xs, ok := x.([]any); ok {
+			if ys, ok := y.([]any); ok {
+				if err := ev.checkSize(n.pos, len(xs)+len(ys)); err != nil {
+					return nil, err
+				}
+				out := make([]any, 0, len(xs)+len(ys))
+				return append(append(out, xs...), ys...), nil
+			}
+		}
+		_, xStr := x.(string)
+		_, yStr := y.(string)
+		if xStr || yStr {
+			xs, err := ev.str(n.pos, x)
+			if err != nil {
+				return nil, err
+			}
+			ys, err := ev.str(n.pos, y)
+			if err != nil {
+				return nil, err
+			}
+			if err := ev.checkSize(n.pos, len(xs)+len(ys)); err != nil {
+				return nil, err
+			}
+			return xs + ys, nil
+		}
+	case "<", "<=", ">", ">=":
+		if xs, ok := x.(string); ok {
+			if ys, ok := y.(string); ok {
+				return compareOrdered(n.op, strings.Compare(xs, ys)), nil
+			}
+		}
+	}
+
+	a, aok := x.(float64)
+	b, bok := y.(float64)
+	if !aok || !bok {
+		return nil, errorf(n.pos, "operator %s not defined for %s and %s", n.op, typeName(x), typeName(y))
+	}
+
+	switch n.op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return nil, errorf(n.pos, "division by zero")
+		}
+		return a / b, nil
+	case "%":
+		if b == 0 {
+			return nil, errorf(n.pos, "division by zero")
+		}
+		return math.Mod(a, b), nil
+	default:
+		cmp := 0
+		if a < b {
+			cmp = -1
+		} else if a > b {
+			cmp = 1
+		}
+		return compareOrdered(n.op, cmp), nil
+	}
+}
+
+func compareOrdered(op string, cmp int) bool {
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func index(pos Pos, x, idx any) (any, error) {
+	switch c := x.(type) {
+	case []any:
+		f, ok := idx.(float64)
+		if !ok || f != math.Trunc(f) {
+			return nil, errorf(pos, "array index must be an integer, got %s", typeName(idx))
+		}
+		i := int(f)
+		if i < 0 {
+			i += len(c)
+		}
+		if i < 0 || i >= len(c) {
+			return nil, errorf(pos, "index %d out of range (length %d)", int(f), len(c))
+		}
+		return normalize(c[i]), nil
+	case map[string]any:
+		k, ok := idx.(string)
+		if !ok {
+			return nil, errorf(pos, "object key must be a string, got %s", typeName(idx))
+		}
+		return normalize(c[k]), nil
+	case string:
+		r := []rune(c)
+		f, ok := idx.(float64)
+		if !ok || f != math.Trunc(f) || int(f) < 0 || int(f) >= len(r) {
+			return nil, errorf(pos, "invalid string index %v", idx)
+		}
+		return string(r[int(f)]), nil
+	}
+	return nil, errorf(pos, "cannot index %s", typeName(x))
+}
+
+// ============ Values ============
+
+// normalize converts Go values from state into script values.
+func normalize(v any) any {
+	switch x := v.(type) {
+	case nil, bool, string, float64, []any, map[string]any, *closure, *builtinFunc:
+		return v
+	case int:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case uint:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case json.Number:
+		f, _ := x.Float64()
+		return f
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Slice, reflect.Array:
+		out := make([]any, rv.Len())
+		for i := range out {
+			out[i] = rv.Index(i).Interface()
+		}
+		return out
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			out := make(map[string]any, rv.Len())
+			iter := rv.MapRange()
+			for iter.Next() {
+				out[iter.Key().String()] = iter.Value().Interface()
+			}
+			return out
+		}
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil
+		}
+	}
+
+	// Structs and other values go through their JSON representation.
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return string(data)
+	}
+	return out
+}
+
+func truthy(v any) bool {
+	switch x := normalize(v).(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	}
+	return true
+}
+
+func equal(x, y any) bool {
+	x, y = normalize(x), normalize(y)
+	switch a := x.(type) {
+	case []any:
+		b, ok := y.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := y.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			w, ok := b[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return x == y
+}
+
+func typeName(v any) string {
+	switch normalize(v).(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case *closure, *builtinFunc:
+		return "function"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func toString(v any) string {
+	switch x := normalize(v).(type) {
+	case nil:
+		return "null"
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case *closure, *builtinFunc:
+		return "<function>"
+	default:
+		data, err := json.Marshal(x)
+		if err != nil {
+			return fmt.Sprint(x)
+		}
+		return string(data)
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
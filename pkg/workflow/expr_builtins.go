@@ -0,0 +1,716 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// builtins holds the functions available to every script. It is populated
+// in init because the higher-order builtins call back into the evaluator.
+var builtins map[string]*builtinFunc
+
+func init() {
+	builtins = make(map[string]*builtinFunc)
+	register := func(name string, fn func(ev *evaluator, pos Pos, args []any) (any, error)) {
+		builtins[name] = &builtinFunc{name: name, fn: fn}
+	}
+
+	// ============ Conversion ============
+
+	register("str", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "str", args, 1, 1); err != nil {
+			return nil, err
+		}
+		return ev.str(pos, args[0])
+	})
+	register("num", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "num", args, 1, 1); err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case float64:
+			return v, nil
+		case bool:
+			if v {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, errorf(pos, "num: cannot convert %q to a number", v)
+			}
+			return f, nil
+		}
+		return nil, errorf(pos, "num: cannot convert %s to a number", typeName(args[0]))
+	})
+	register("bool", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "bool", args, 1, 1); err != nil {
+			return nil, err
+		}
+		return truthy(args[0]), nil
+	})
+	register("type", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "type", args, 1, 1); err != nil {
+			return nil, err
+		}
+		return typeName(args[0]), nil
+	})
+	register("to_json", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "to_json", args, 1, 1); err != nil {
+			return nil, err
+		}
+		if err := ev.checkSize(pos, encodedSize(normalize(args[0]), ev.maxSize)); err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(args[0])
+		if err != nil {
+			return nil, errorf(pos, "to_json: %v", err)
+		}
+		return string(data), nil
+	})
+	register("from_json", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		s, err := stringArg(pos, "from_json", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		var out any
+		if err := json.Unmarshal([]byte(s), &out); err != nil {
+			return nil, errorf(pos, "from_json: %v", err)
+		}
+		return out, nil
+	})
+
+	// ============ Strings ============
+
+	register("upper", stringFunc("upper", strings.ToUpper))
+	register("lower", stringFunc("lower", strings.ToLower))
+	register("trim", stringFunc("trim", strings.TrimSpace))
+	register("split", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "split", args, 2, 2); err != nil {
+			return nil, err
+		}
+		s, err := stringArg(pos, "split", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		sep, err := stringArg(pos, "split", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(s, sep)
+		out := make([]any, len(parts))
+		for i, p := range parts {
+			out[i] = p
+		}
+		return out, nil
+	})
+	register("join", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "join", args, 1, 2); err != nil {
+			return nil, err
+		}
+		arr, err := arrayArg(pos, "join", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		sep := ""
+		if len(args) > 1 {
+			if sep, err = stringArg(pos, "join", args, 1); err != nil {
+				return nil, err
+			}
+		}
+		parts := make([]string, len(arr))
+		size := 0
+		for i, v := range arr {
+			if parts[i], err = ev.str(pos, v); err != nil {
+				return nil, err
+			}
+			if size += len(parts[i]) + len(sep); size > ev.maxSize {
+				return nil, ev.checkSize(pos, size)
+			}
+		}
+		return strings.Join(parts, sep), nil
+	})
+	register("replace", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "replace", args, 3, 3); err != nil {
+			return nil, err
+		}
+		var s [3]string
+		for i := range s {
+			v, err := stringArg(pos, "replace", args, i)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = v
+		}
+		count := strings.Count(s[0], s[1])
+		if err := ev.checkSize(pos, len(s[0])+count*(len(s[2])-len(s[1]))); err != nil {
+			return nil, err
+		}
+		return strings.ReplaceAll(s[0], s[1], s[2]), nil
+	})
+	register("starts_with", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "starts_with", args, 2, 2); err != nil {
+			return nil, err
+		}
+		s, prefix, err := strPair(ev, pos, args)
+		if err != nil {
+			return nil, err
+		}
+		return strings.HasPrefix(s, prefix), nil
+	})
+	register("ends_with", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "ends_with", args, 2, 2); err != nil {
+			return nil, err
+		}
+		s, suffix, err := strPair(ev, pos, args)
+		if err != nil {
+			return nil, err
+		}
+		return strings.HasSuffix(s, suffix), nil
+	})
+	register("format", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if len(args) == 0 {
+			return nil, errorf(pos, "format: expected a format string")
+		}
+		f, err := stringArg(pos, "format", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		// Widths and precisions could pad the result to any size.
+		for _, width := range formatWidths(f) {
+			if err := ev.checkSize(pos, width); err != nil {
+				return nil, err
+			}
+		}
+		size := len(f)
+		for _, arg := range args[1:] {
+			size += encodedSize(normalize(arg), ev.maxSize)
+		}
+		if err := ev.checkSize(pos, size); err != nil {
+			return nil, err
+		}
+		out := fmt.Sprintf(f, args[1:]...)
+		return out, ev.checkSize(pos, len(out))
+	})
+
+	// ============ Collections ============
+
+	register("len", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "len", args, 1, 1); err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case string:
+			return float64(len([]rune(v))), nil
+		case []any:
+			return float64(len(v)), nil
+		case map[string]any:
+			return float64(len(v)), nil
+		case nil:
+			return 0.0, nil
+		}
+		return nil, errorf(pos, "len: unsupported type %s", typeName(args[0]))
+	})
+	register("contains", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "contains", args, 2, 2); err != nil {
+			return nil, err
+		}
+		switch c := args[0].(type) {
+		case string:
+			sub, err := ev.str(pos, args[1])
+			if err != nil {
+				return nil, err
+			}
+			return strings.Contains(c, sub), nil
+		case []any:
+			for _, v := range c {
+				if equal(v, args[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]any:
+			_, ok := c[toString(args[1])]
+			return ok, nil
+		}
+		return nil, errorf(pos, "contains: unsupported type %s", typeName(args[0]))
+	})
+	register("slice", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "slice", args, 2, 3); err != nil {
+			return nil, err
+		}
+		var length int
+		switch c := args[0].(type) {
+		case string:
+			length = len([]rune(c))
+		case []any:
+			length = len(c)
+		default:
+			return nil, errorf(pos, "slice: unsupported type %s", typeName(args[0]))
+		}
+		start, err := intArg(pos, "slice", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		end := length
+		if len(args) > 2 {
+			if end, err = intArg(pos, "slice", args, 2); err != nil {
+				return nil, err
+			}
+		}
+		start, end = clampRange(start, length), clampRange(end, length)
+		if end < start {
+			end = start
+		}
+		if s, ok := args[0].(string); ok {
+			return string([]rune(s)[start:end]), nil
+		}
+		return append([]any(nil), args[0].([]any)[start:end]...), nil
+	})
+	register("concat", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		out := make([]any, 0)
+		for i := range args {
+			arr, err := arrayArg(pos, "concat", args, i)
+			if err != nil {
+				return nil, err
+			}
+			if err := ev.checkSize(pos, len(out)+len(arr)); err != nil {
+				return nil, err
+			}
+			out = append(out, arr...)
+		}
+		return out, nil
+	})
+	register("reverse", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "reverse", args, 1, 1); err != nil {
+			return nil, err
+		}
+		arr, err := arrayArg(pos, "reverse", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, len(arr))
+		for i, v := range arr {
+			out[len(arr)-1-i] = v
+		}
+		return out, nil
+	})
+	register("sort", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "sort", args, 1, 1); err != nil {
+			return nil, err
+		}
+		arr, err := arrayArg(pos, "sort", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, len(arr))
+		for i, v := range arr {
+			out[i] = normalize(v)
+		}
+		var sortErr error
+		sort.SliceStable(out, func(i, j int) bool {
+			switch a := out[i].(type) {
+			case float64:
+				if b, ok := out[j].(float64); ok {
+					return a < b
+				}
+			case string:
+				if b, ok := out[j].(string); ok {
+					return a < b
+				}
+			}
+			sortErr = errorf(pos, "sort: cannot compare %s and %s", typeName(out[i]), typeName(out[j]))
+			return false
+		})
+		if sortErr != nil {
+			return nil, sortErr
+		}
+		return out, nil
+	})
+	register("range", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "range", args, 1, 2); err != nil {
+			return nil, err
+		}
+		start, end := 0, 0
+		var err error
+		if len(args) == 1 {
+			end, err = intArg(pos, "range", args, 0)
+		} else {
+			if start, err = intArg(pos, "range", args, 0); err == nil {
+				end, err = intArg(pos, "range", args, 1)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, 0)
+		for i := start; i < end; i++ {
+			if err := ev.tick(pos); err != nil {
+				return nil, err
+			}
+			out = append(out, float64(i))
+		}
+		return out, nil
+	})
+	register("map", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "map", args, 2, 2); err != nil {
+			return nil, err
+		}
+		arr, err := arrayArg(pos, "map", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, len(arr))
+		for i, v := range arr {
+			r, err := ev.call(pos, args[1], []any{normalize(v), float64(i)})
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	})
+	register("filter", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "filter", args, 2, 2); err != nil {
+			return nil, err
+		}
+		arr, err := arrayArg(pos, "filter", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, 0)
+		for i, v := range arr {
+			keep, err := ev.call(pos, args[1], []any{normalize(v), float64(i)})
+			if err != nil {
+				return nil, err
+			}
+			if truthy(keep) {
+				out = append(out, v)
+			}
+		}
+		return out, nil
+	})
+	register("reduce", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "reduce", args, 3, 3); err != nil {
+			return nil, err
+		}
+		arr, err := arrayArg(pos, "reduce", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		acc := args[2]
+		for _, v := range arr {
+			if acc, err = ev.call(pos, args[1], []any{acc, normalize(v)}); err != nil {
+				return nil, err
+			}
+		}
+		return acc, nil
+	})
+
+	// ============ Objects ============
+
+	register("keys", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		obj, err := objectArg(pos, "keys", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		keys := sortedKeys(obj)
+		out := make([]any, len(keys))
+		for i, k := range keys {
+			out[i] = k
+		}
+		return out, nil
+	})
+	register("values", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		obj, err := objectArg(pos, "values", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		keys := sortedKeys(obj)
+		out := make([]any, len(keys))
+		for i, k := range keys {
+			out[i] = obj[k]
+		}
+		return out, nil
+	})
+	register("merge", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		out := make(map[string]any)
+		for i := range args {
+			if args[i] == nil {
+				continue
+			}
+			obj, err := objectArg(pos, "merge", args, i)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range obj {
+				out[k] = v
+			}
+		}
+		return out, nil
+	})
+	register("default", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "default", args, 2, 2); err != nil {
+			return nil, err
+		}
+		if args[0] == nil || args[0] == "" {
+			return args[1], nil
+		}
+		return args[0], nil
+	})
+	register("get", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "get", args, 2, 3); err != nil {
+			return nil, err
+		}
+		path, err := stringArg(pos, "get", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		var fallback any
+		if len(args) > 2 {
+			fallback = args[2]
+		}
+		cur := args[0]
+		for _, part := range strings.Split(path, ".") {
+			obj, ok := normalize(cur).(map[string]any)
+			if !ok {
+				return fallback, nil
+			}
+			if cur, ok = obj[part]; !ok {
+				return fallback, nil
+			}
+		}
+		if cur == nil {
+			return fallback, nil
+		}
+		return normalize(cur), nil
+	})
+
+	// ============ Math ============
+
+	register("abs", numberFunc("abs", math.Abs))
+	register("floor", numberFunc("floor", math.Floor))
+	register("ceil", numberFunc("ceil", math.Ceil))
+	register("round", func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, "round", args, 1, 2); err != nil {
+			return nil, err
+		}
+		x, err := numberArg(pos, "round", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		digits := 0
+		if len(args) > 1 {
+			if digits, err = intArg(pos, "round", args, 1); err != nil {
+				return nil, err
+			}
+		}
+		scale := math.Pow(10, float64(digits))
+		return math.Round(x*scale) / scale, nil
+	})
+	register("sum", aggregate("sum", func(nums []float64) float64 {
+		total := 0.0
+		for _, n := range nums {
+			total += n
+		}
+		return total
+	}))
+	register("avg", aggregate("avg", func(nums []float64) float64 {
+		if len(nums) == 0 {
+			return 0
+		}
+		total := 0.0
+		for _, n := range nums {
+			total += n
+		}
+		return total / float64(len(nums))
+	}))
+	register("min", aggregate("min", func(nums []float64) float64 {
+		if len(nums) == 0 {
+			return 0
+		}
+		m := nums[0]
+		for _, n := range nums[1:] {
+			m = math.Min(m, n)
+		}
+		return m
+	}))
+	register("max", aggregate("max", func(nums []float64) float64 {
+		if len(nums) == 0 {
+			return 0
+		}
+		m := nums[0]
+		for _, n := range nums[1:] {
+			m = math.Max(m, n)
+		}
+		return m
+	}))
+}
+
+// ============ Argument Helpers ============
+
+func arity(pos Pos, name string, args []any, min, max int) error {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return errorf(pos, "%s: expected %d arguments, got %d", name, min, len(args))
+		}
+		return errorf(pos, "%s: expected %d to %d arguments, got %d", name, min, max, len(args))
+	}
+	return nil
+}
+
+func stringArg(pos Pos, name string, args []any, i int) (string, error) {
+	if i >= len(args) {
+		return "", errorf(pos, "%s: missing argument %d", name, i+1)
+	}
+	s, ok := args[i].(string)
+	if !ok {
+		return "", errorf(pos, "%s: argument %d must be a string, got %s", name, i+1, typeName(args[i]))
+	}
+	return s, nil
+}
+
+func numberArg(pos Pos, name string, args []any, i int) (float64, error) {
+	if i >= len(args) {
+		return 0, errorf(pos, "%s: missing argument %d", name, i+1)
+	}
+	f, ok := args[i].(float64)
+	if !ok {
+		return 0, errorf(pos, "%s: argument %d must be a number, got %s", name, i+1, typeName(args[i]))
+	}
+	return f, nil
+}
+
+func intArg(pos Pos, name string, args []any, i int) (int, error) {
+	f, err := numberArg(pos, name, args, i)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) {
+		return 0, errorf(pos, "%s: argument %d must be an integer", name, i+1)
+	}
+	return int(f), nil
+}
+
+func arrayArg(pos Pos, name string, args []any, i int) ([]any, error) {
+	if i >= len(args) {
+		return nil, errorf(pos, "%s: missing argument %d", name, i+1)
+	}
+	arr, ok := args[i].([]any)
+	if !ok {
+		return nil, errorf(pos, "%s: argument %d must be an array, got %s", name, i+1, typeName(args[i]))
+	}
+	return arr, nil
+}
+
+func objectArg(pos Pos, name string, args []any, i int) (map[string]any, error) {
+	if i >= len(args) {
+		return nil, errorf(pos, "%s: missing argument %d", name, i+1)
+	}
+	obj, ok := args[i].(map[string]any)
+	if !ok {
+		return nil, errorf(pos, "%s: argument %d must be an object, got %s", name, i+1, typeName(args[i]))
+	}
+	return obj, nil
+}
+
+func clampRange(i, length int) int {
+	if i < 0 {
+		i += length
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > length {
+		return length
+	}
+	return i
+}
+
+func stringFunc(name string, fn func(string) string) func(ev *evaluator, pos Pos, args []any) (any, error) {
+	return func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, name, args, 1, 1); err != nil {
+			return nil, err
+		}
+		s, err := stringArg(pos, name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return fn(s), nil
+	}
+}
+
+func numberFunc(name string, fn func(float64) float64) func(ev *evaluator, pos Pos, args []any) (any, error) {
+	return func(ev *evaluator, pos Pos, args []any) (any, error) {
+		if err := arity(pos, name, args, 1, 1); err != nil {
+			return nil, err
+		}
+		x, err := numberArg(pos, name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return fn(x), nil
+	}
+}
+
+// aggregate accepts either a single array or a list of numbers.
+func aggregate(name string, fn func([]float64) float64) func(ev *evaluator, pos Pos, args []any) (any, error) {
+	return func(ev *evaluator, pos Pos, args []any) (any, error) {
+		items := args
+		if len(args) == 1 {
+			if arr, ok := args[0].([]any); ok {
+				items = arr
+			}
+		}
+		nums := make([]float64, len(items))
+		for i, v := range items {
+			f, ok := normalize(v).(float64)
+			if !ok {
+				return nil, errorf(pos, "%s: element %d is %s, not a number", name, i, typeName(v))
+			}
+			nums[i] = f
+		}
+		return fn(nums), nil
+	}
+}
+
+// strPair converts the two arguments of a string predicate within the size
+// limit.
+func strPair(ev *evaluator, pos Pos, args []any) (string, string, error) {
+	a, err := ev.str(pos, args[0])
+	if err != nil {
+		return "", "", err
+	}
+	b, err := ev.str(pos, args[1])
+	return a, b, err
+}
+
+// formatWidths returns the widths and precisions in the verbs of a format
+// string, with * (taken from an argument) as an unbounded width.
+func formatWidths(f string) []int {
+	var widths []int
+	for i := 0; i < len(f); i++ {
+		if f[i] != '%' {
+			continue
+		}
+		for i++; i < len(f) && strings.IndexByte("+-# 0.*123456789[]", f[i]) >= 0; i++ {
+			switch {
+			case f[i] == '*':
+				widths = append(widths, math.MaxInt)
+			case f[i] >= '1' && f[i] <= '9':
+				n := 0
+				for ; i < len(f) && f[i] >= '0' && f[i] <= '9'; i++ {
+					n = min(n*10+int(f[i]-'0'), math.MaxInt/10)
+				}
+				widths = append(widths, n)
+				i--
+			}
+		}
+	}
+	return widths
+}
@@ -0,0 +1,568 @@
+// Package workflow_test provides tests for the expression evaluator.
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// ============ Evaluator Tests ============
+
+func TestEvalExpr_Literals(t *testing.T) {
+	tests := []struct {
+		script string
+		want   any
+	}{
+		{`42`, 42.0},
+		{`3.5`, 3.5},
+		{`"hello"`, "hello"},
+		{`'single'`, "single"},
+		{`"line\nbreak"`, "line\nbreak"},
+		{`true`, true},
+		{`false`, false},
+		{`null`, nil},
+	}
+
+	for _, tt := range tests {
+		got, err := workflow.EvalExpr(tt.script, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.script, tt.want, got)
+		}
+	}
+}
+
+func TestEvalExpr_Arithmetic(t *testing.T) {
+	tests := []struct {
+		script string
+		want   float64
+	}{
+		{`1 + 2 * 3`, 7},
+		{`(1 + 2) * 3`, 9},
+		{`10 / 4`, 2.5},
+		{`10 % 3`, 1},
+		{`-5 + 2`, -3},
+		{`--5`, 5},
+		{`2 * -3`, -6},
+		{`1 - 2 - 3`, -4},
+	}
+
+	for _, tt := range tests {
+		got, err := workflow.EvalExpr(tt.script, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.script, tt.want, got)
+		}
+	}
+}
+
+func TestEvalExpr_ComparisonAndLogic(t *testing.T) {
+	tests := []struct {
+		script string
+		want   any
+	}{
+		{`1 < 2`, true},
+		{`2 <= 2`, true},
+		{`3 > 4`, false},
+		{`"a" < "b"`, true},
+		{`1 == 1`, true},
+		{`"x" != "y"`, true},
+		{`[1, 2] == [1, 2]`, true},
+		{`{a: 1} == {a: 1}`, true},
+		{`{a: 1} == {a: 2}`, false},
+		{`true && false`, false},
+		{`null || "fallback"`, "fallback"},
+		{`"set" || "fallback"`, "set"},
+		{`!0`, true},
+		{`!""`, true},
+		{`1 > 0 ? "pos" : "neg"`, "pos"},
+		{`0 ? "a" : 1 ? "b" : "c"`, "b"},
+	}
+
+	for _, tt := range tests {
+		got, err := workflow.EvalExpr(tt.script, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.script, tt.want, got)
+		}
+	}
+}
+
+func TestEvalExpr_ShortCircuit(t *testing.T) {
+	// The right-hand side would fail if evaluated.
+	got, err := workflow.EvalExpr(`false && undefined_name`, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != false {
+		t.Errorf("Expected false, got %v", got)
+	}
+}
+
+func TestEvalExpr_LetBindings(t *testing.T) {
+	script := `
+		let a = 10;
+		let b = a * 2;
+		// comments are allowed
+		let label = "total: " + str(a + b);
+		label
+	`
+	got, err := workflow.EvalExpr(script, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != "total: 30" {
+		t.Errorf("Expected 'total: 30', got %v", got)
+	}
+}
+
+func TestEvalExpr_Variables(t *testing.T) {
+	vars := map[string]any{
+		"data": map[string]any{
+			"user":  map[string]any{"name": "ada", "age": 36},
+			"items": []any{1, 2, 3},
+			"tags":  []string{"a", "b"},
+		},
+	}
+
+	tests := []struct {
+		script string
+		want   any
+	}{
+		{`data.user.name`, "ada"},
+		{`data.user.age + 1`, 37.0},
+		{`data["user"]["name"]`, "ada"},
+		{`data.items[0]`, 1.0},
+		{`data.items[-1]`, 3.0},
+		{`data.tags[1]`, "b"},
+		{`data.missing`, nil},
+		{`len(data.tags)`, 2.0},
+	}
+
+	for _, tt := range tests {
+		got, err := workflow.EvalExpr(tt.script, vars)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.script, tt.want, got)
+		}
+	}
+}
+
+func TestEvalExpr_StringBuiltins(t *testing.T) {
+	tests := []struct {
+		script string
+		want   any
+	}{
+		{`upper("abc")`, "ABC"},
+		{`lower("ABC")`, "abc"},
+		{`trim("  x  ")`, "x"},
+		{`join(split("a,b,c", ","), "-")`, "a-b-c"},
+		{`replace("aaa", "a", "b")`, "bbb"},
+		{`contains("hello", "ell")`, true},
+		{`starts_with("hello", "he")`, true},
+		{`ends_with("hello", "lo")`, true},
+		{`slice("hello", 1, 3)`, "el"},
+		{`format("%s=%v", "x", 1)`, "x=1"},
+		{`"n" + 1`, "n1"},
+		{`str(2.5)`, "2.5"},
+		{`num("12") + 1`, 13.0},
+		{`type([1])`, "array"},
+	}
+
+	for _, tt := range tests {
+		got, err := workflow.EvalExpr(tt.script, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.script, tt.want, got)
+		}
+	}
+}
+
+func TestEvalExpr_Filters(t *testing.T) {
+	vars := map[string]any{"name": " ada ", "tags": []any{"a", "b"}, "user": map[string]any{}}
+	tests := []struct {
+		script string
+		want   any
+	}{
+		{`name | trim | upper`, "ADA"},
+		{`tags | join("+")`, "a+b"},
+		{`user.nickname | default("none")`, "none"},
+		{`"" | default("x")`, "x"},
+		{`true || false`, true},
+		{`(len(tags) > 1 ? "many" : "one") | upper`, "MANY"},
+	}
+
+	for _, tt := range tests {
+		got, err := workflow.EvalExpr(tt.script, vars)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.script, tt.want, got)
+		}
+	}
+}
+
+func TestEvalExpr_CollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		script string
+		want   any
+	}{
+		{`len([1, 2, 3])`, 3.0},
+		{`sum([1, 2, 3])`, 6.0},
+		{`avg([2, 4])`, 3.0},
+		{`max(3, 9, 1)`, 9.0},
+		{`min([3, 9, 1])`, 1.0},
+		{`sum(map([1, 2, 3], x => x * 2))`, 12.0},
+		{`len(filter([1, 2, 3, 4], x => x % 2 == 0))`, 2.0},
+		{`reduce([1, 2, 3], (acc, x) => acc + x, 10)`, 16.0},
+		{`join(map(["a", "b"], (x, i) => x + str(i)), ",")`, "a0,b1"},
+		{`contains([1, 2], 2)`, true},
+		{`join(sort(["c", "a", "b"]), "")`, "abc"},
+		{`join(reverse([1, 2, 3]), "")`, "321"},
+		{`len(concat([1], [2, 3]))`, 3.0},
+		{`len([1] + [2])`, 2.0},
+		{`len(range(5))`, 5.0},
+		{`sum(range(1, 4))`, 6.0},
+		{`slice([1, 2, 3], 1)[0]`, 2.0},
+		{`round(2.345, 2)`, 2.35},
+		{`floor(2.7) + ceil(2.1) + abs(-1)`, 6.0},
+	}
+
+	for _, tt := range tests {
+		got, err := workflow.EvalExpr(tt.script, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.script, tt.want, got)
+		}
+	}
+}
+
+func TestEvalExpr_ObjectBuiltins(t *testing.T) {
+	got, err := workflow.EvalExpr(`merge({a: 1, b: 2}, {b: 3, "c": 4})`, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	obj, ok := got.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected object, got %T", got)
+	}
+	if obj["a"] != 1.0 || obj["b"] != 3.0 || obj["c"] != 4.0 {
+		t.Errorf("Unexpected merge result: %v", obj)
+	}
+
+	tests := []struct {
+		script string
+		want   any
+	}{
+		{`join(keys({b: 1, a: 2}), ",")`, "a,b"},
+		{`sum(values({b: 1, a: 2}))`, 3.0},
+		{`get({a: {b: 5}}, "a.b")`, 5.0},
+		{`get({a: {}}, "a.b.c", "none")`, "none"},
+		{`contains({a: 1}, "a")`, true},
+		{`from_json("{\"x\": 1}").x`, 1.0},
+		{`to_json({a: [1]})`, `{"a":[1]}`},
+	}
+
+	for _, tt := range tests {
+		got, err := workflow.EvalExpr(tt.script, nil)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.script, tt.want, got)
+		}
+	}
+}
+
+func TestEvalExpr_StructValues(t *testing.T) {
+	type order struct {
+		ID    string  `json:"id"`
+		Total float64 `json:"total"`
+	}
+	vars := map[string]any{"order": order{ID: "o-1", Total: 9.5}}
+
+	got, err := workflow.EvalExpr(`order.id + ":" + str(order.total)`, vars)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != "o-1:9.5" {
+		t.Errorf("Expected 'o-1:9.5', got %v", got)
+	}
+}
+
+// ============ Error Position Tests ============
+
+func TestEvalExpr_ErrorPositions(t *testing.T) {
+	tests := []struct {
+		script string
+		line   int
+		col    int
+		msg    string
+	}{
+		{`1 +`, 1, 4, "unexpected end of script"},
+		{`"open`, 1, 1, "unterminated string"},
+		{`1 # 2`, 1, 3, "unexpected character"},
+		{"let x = 1;\nx / 0", 2, 3, "division by zero"},
+		{"let x = 1;\n  nope + x", 2, 3, `undefined name "nope"`},
+		{`"a" - 1`, 1, 5, "operator - not defined"},
+		{`[1, 2][5]`, 1, 7, "out of range"},
+		{`null.field`, 1, 5, `cannot read field "field"`},
+		{`upper(1)`, 1, 6, "must be a string"},
+		{`let x = 1 x`, 1, 11, `expected ";"`},
+		{`{a 1}`, 1, 4, `expected ":"`},
+		{`let = 1; 2`, 1, 5, "expected name after let"},
+		{`let true = 1; 2`, 1, 5, "reserved word"},
+		{`let x = 1;`, 1, 11, "no result expression"},
+		{`1 2`, 1, 3, "unexpected"},
+		{`(1`, 1, 3, `expected ")"`},
+		{`5(1)`, 1, 2, "not callable"},
+		{`x => x`, 1, 1, "cannot be a function"},
+	}
+
+	for _, tt := range tests {
+		_, err := workflow.EvalExpr(tt.script, nil)
+		if err == nil {
+			t.Errorf("%q: expected error", tt.script)
+			continue
+		}
+		var exprErr *workflow.ExprError
+		if !errors.As(err, &exprErr) {
+			t.Errorf("%q: expected *ExprError, got %T", tt.script, err)
+			continue
+		}
+		if exprErr.Pos.Line != tt.line || exprErr.Pos.Col != tt.col {
+			t.Errorf("%q: expected position %d:%d, got %s (%v)", tt.script, tt.line, tt.col, exprErr.Pos, err)
+		}
+		if !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%q: expected message containing %q, got %q", tt.script, tt.msg, err.Error())
+		}
+	}
+}
+
+// ============ Step Bound Tests ============
+
+func TestEvalExpr_StepLimitStopsInfiniteRecursion(t *testing.T) {
+	_, err := workflow.EvalExpr(`let loop = n => loop(n + 1); loop(0)`, nil)
+	if err == nil {
+		t.Fatal("Expected step limit error")
+	}
+	if !strings.Contains(err.Error(), "step limit") {
+		t.Errorf("Expected step limit error, got %v", err)
+	}
+}
+
+func TestEvalExpr_StepLimitStopsLargeRange(t *testing.T) {
+	_, err := workflow.EvalExpr(`len(range(1000000000))`, nil)
+	if err == nil || !strings.Contains(err.Error(), "step limit") {
+		t.Errorf("Expected step limit error, got %v", err)
+	}
+}
+
+func TestEvalExpr_SizeLimitStopsDoubling(t *testing.T) {
+	// Each doubling takes a few steps, so the step limit alone would let
+	// these grow far past memory.
+	double := func(seed, op string) string {
+		src := "let v0 = " + seed + ";\n"
+		for i := 1; i <= 60; i++ {
+			src += fmt.Sprintf("let v%d = %s;\n", i, strings.ReplaceAll(op, "x", fmt.Sprintf("v%d", i-1)))
+		}
+		return src + "len(v60)"
+	}
+	for _, src := range []string{
+		double(`"ab"`, "x + x"),
+		double(`[1]`, "x + x"),
+		double(`[1]`, "concat(x, x)"),
+		double(`"ab"`, `join([x, x], "")`),
+		double(`"ab"`, `replace(x, "a", "aa")`),
+		double(`["ab"]`, `[str(x), str(x)]`),
+		`format("%999999999d", 1)`,
+		`format("%*d", 999999999, 1)`,
+	} {
+		_, err := workflow.EvalExpr(src, nil)
+		if err == nil || !strings.Contains(err.Error(), "size") {
+			t.Errorf("Expected a size limit error, got %v for\n%s", err, src)
+		}
+	}
+
+	expr, _ := workflow.CompileExpr(`len(data.s + data.s)`)
+	expr.MaxSize = 10
+	if _, err := expr.Eval(map[string]any{"data": map[string]any{"s": "abcdef"}}); err == nil {
+		t.Error("Expected a size limit error with MaxSize=10")
+	}
+}
+
+func TestExpr_CustomMaxSteps(t *testing.T) {
+	expr, err := workflow.CompileExpr(`sum(map(range(50), x => x))`)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	if _, err := expr.Eval(nil); err != nil {
+		t.Fatalf("Expected success with default limit, got %v", err)
+	}
+
+	expr.MaxSteps = 20
+	if _, err := expr.Eval(nil); err == nil {
+		t.Error("Expected step limit error with MaxSteps=20")
+	}
+}
+
+func TestExpr_Reusable(t *testing.T) {
+	expr, err := workflow.CompileExpr(`let doubled = x * 2; doubled`)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	for _, x := range []float64{1, 2, 3} {
+		got, err := expr.Eval(map[string]any{"x": x})
+		if err != nil {
+			t.Fatalf("Eval failed: %v", err)
+		}
+		if got != x*2 {
+			t.Errorf("Expected %v, got %v", x*2, got)
+		}
+	}
+}
+
+// ============ Transform Step Tests ============
+
+func TestTransformStep_Execute(t *testing.T) {
+	wf := workflow.New("transform").
+		Step("fetch", func(ctx context.Context, state *workflow.State) (any, error) {
+			return map[string]any{"hits": 30, "total": 40}, nil
+		}).Then().
+		Transform("ratio", `
+			let r = results.fetch.hits / results.fetch.total;
+			{ ratio: r, label: upper(data.name) + " " + str(round(r * 100)) + "%" }
+		`).Then().
+		Build()
+
+	state := &workflow.State{
+		Data:        map[string]any{"name": "cache"},
+		StepResults: make(map[string]any),
+	}
+
+	for _, step := range wf.Steps {
+		if err := step.Execute(context.Background(), state); err != nil {
+			t.Fatalf("Step %s failed: %v", step.Name(), err)
+		}
+	}
+
+	result, ok := state.StepResults["ratio"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected object result, got %T", state.StepResults["ratio"])
+	}
+	if result["ratio"] != 0.75 {
+		t.Errorf("Expected ratio 0.75, got %v", result["ratio"])
+	}
+	if result["label"] != "CACHE 75%" {
+		t.Errorf("Expected label 'CACHE 75%%', got %v", result["label"])
+	}
+	if wf.Steps[1].Type() != workflow.StepTypeTransform {
+		t.Errorf("Expected transform step type, got %s", wf.Steps[1].Type())
+	}
+}
+
+func TestTransformStep_ReadOnlyState(t *testing.T) {
+	wf := workflow.New("transform-readonly").
+		Transform("merged", `merge(data.config, {extra: true})`).Then().
+		Build()
+
+	config := map[string]any{"mode": "fast"}
+	state := &workflow.State{
+		Data:        map[string]any{"config": config},
+		StepResults: make(map[string]any),
+	}
+
+	if err := wf.Steps[0].Execute(context.Background(), state); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, exists := config["extra"]; exists {
+		t.Error("Transform must not modify state data")
+	}
+	if len(state.Data) != 1 {
+		t.Errorf("Expected state data unchanged, got %v", state.Data)
+	}
+}
+
+func TestTransformStep_CompileError(t *testing.T) {
+	wf := workflow.New("transform-bad").
+		Transform("bad", `1 +`).Then().
+		Build()
+
+	state := &workflow.State{
+		Data:        make(map[string]any),
+		StepResults: make(map[string]any),
+	}
+
+	err := wf.Steps[0].Execute(context.Background(), state)
+	if err == nil {
+		t.Fatal("Expected compile error")
+	}
+	var exprErr *workflow.ExprError
+	if !errors.As(err, &exprErr) {
+		t.Errorf("Expected wrapped *ExprError, got %v", err)
+	}
+}
+
+func TestTransformStep_MaxSteps(t *testing.T) {
+	wf := workflow.New("transform-bounded").
+		Transform("spin", `let f = n => f(n); f(1)`).MaxSteps(100).Then().
+		Build()
+
+	state := &workflow.State{
+		Data:        make(map[string]any),
+		StepResults: make(map[string]any),
+	}
+
+	err := wf.Steps[0].Execute(context.Background(), state)
+	if err == nil || !strings.Contains(err.Error(), "step limit of 100") {
+		t.Errorf("Expected step limit error, got %v", err)
+	}
+}
+
+func TestExprCondition(t *testing.T) {
+	cond := workflow.ExprCondition(`data.score >= 80 && contains(data.tags, "vip")`)
+
+	state := &workflow.State{
+		Data: map[string]any{"score": 85, "tags": []string{"vip"}},
+	}
+	if !cond(state) {
+		t.Error("Expected condition to be true")
+	}
+
+	state.Data["score"] = 10
+	if cond(state) {
+		t.Error("Expected condition to be false")
+	}
+
+	if workflow.ExprCondition(`1 +`)(state) {
+		t.Error("Invalid script should evaluate to false")
+	}
+}
@@ -0,0 +1,379 @@
+// Package workflow provides diagrams of workflows in Mermaid and DOT.
+package workflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToMermaid renders the workflow as a Mermaid flowchart, with a node per
+// step annotated with its type, retries and timeouts. Branches, loops and
+// parallel blocks become edges; sub-workflows become nested subgraphs.
+func (w *Workflow) ToMermaid() string {
+	g := buildGraph(w)
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	g.writeMermaid(&b, 0, "    ")
+	for _, e := range g.edges {
+		arrow := "-->"
+		if e.dashed {
+			arrow = "-.->"
+		}
+		if e.label != "" {
+			arrow += "|" + mermaidEscape(e.label) + "|"
+		}
+		fmt.Fprintf(&b, "    %s %s %s\n", e.from, arrow, e.to)
+	}
+	return b.String()
+}
+
+// ToDOT renders the workflow as a Graphviz digraph with the same nodes and
+// edges as ToMermaid; sub-workflows become clusters.
+func (w *Workflow) ToDOT() string {
+	g := buildGraph(w)
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n    rankdir=TB;\n    node [fontname=\"Helvetica\"];\n", dotQuote(w.Name))
+	g.writeDOT(&b, 0, "    ")
+	for _, e := range g.edges {
+		var attrs []string
+		if e.label != "" {
+			attrs = append(attrs, "label="+dotQuote(e.label))
+		}
+		if e.dashed {
+			attrs = append(attrs, "style=dashed")
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, "    %s -> %s [%s];\n", e.from, e.to, strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(&b, "    %s -> %s;\n", e.from, e.to)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// nodeShape is how a graph node is drawn.
+type nodeShape int
+
+const (
+	shapeBox nodeShape = iota
+	shapeRounded
+	shapeDiamond
+	shapeHexagon
+	shapeCircle
+	shapeSubroutine
+)
+
+type graphNode struct {
+	id    string
+	label string
+	shape nodeShape
+	group int // index into graph.groups, 0 for the top level
+}
+
+type graphEdge struct {
+	from, to string
+	label    string
+	dashed   bool
+}
+
+// graphGroup is a sub-workflow subgraph.
+type graphGroup struct {
+	id     string
+	label  string
+	parent int
+}
+
+// graphExit is a node the next step connects from, with the label of
+// that edge.
+type graphExit struct {
+	id    string
+	label string
+}
+
+type graph struct {
+	nodes  []graphNode
+	edges  []graphEdge
+	groups []graphGroup
+	group  int
+	// expanding holds the sub-workflows being walked, so cycles are drawn
+	// once instead of recursing forever.
+	expanding map[*Workflow]bool
+	// byName maps step names to nodes in each group for OnErrorGoto edges.
+	byName map[int]map[string]string
+	gotos  []graphGoto
+}
+
+type graphGoto struct {
+	from, step string
+	group      int
+}
+
+func buildGraph(w *Workflow) *graph {
+	g := &graph{
+		groups:    []graphGroup{{}},
+		expanding: map[*Workflow]bool{w: true},
+		byName:    make(map[int]map[string]string),
+	}
+	start := g.node("start", shapeCircle, "")
+	exits := g.steps(w.Steps, []graphExit{{id: start}})
+	end := g.node("end", shapeCircle, "")
+	g.connect(exits, end)
+	for _, p := range g.gotos {
+		if to, ok := g.byName[p.group][p.step]; ok {
+			g.edges = append(g.edges, graphEdge{from: p.from, to: to, label: "on error", dashed: true})
+		}
+	}
+	return g
+}
+
+func (g *graph) node(label string, shape nodeShape, step string) string {
+	id := fmt.Sprintf("n%d", len(g.nodes))
+	g.nodes = append(g.nodes, graphNode{id: id, label: label, shape: shape, group: g.group})
+	if step != "" {
+		names := g.byName[g.group]
+		if names == nil {
+			names = make(map[string]string)
+			g.byName[g.group] = names
+		}
+		if _, dup := names[step]; !dup {
+			names[step] = id
+		}
+	}
+	return id
+}
+
+func (g *graph) connect(exits []graphExit, to string) {
+	for _, x := range exits {
+		g.edges = append(g.edges, graphEdge{from: x.id, to: to, label: x.label})
+	}
+}
+
+// steps chains steps after exits and returns the exits of the last one.
+func (g *graph) steps(steps []Step, exits []graphExit) []graphExit {
+	for _, step := range steps {
+		exits = g.step(step, exits)
+	}
+	return exits
+}
+
+// branch walks steps as a branch leaving from with label. An empty branch
+// leaves from itself.
+func (g *graph) branch(steps []Step, from, label string) []graphExit {
+	if len(steps) == 0 {
+		return []graphExit{{id: from, label: label}}
+	}
+	return g.steps(steps, []graphExit{{id: from, label: label}})
+}
+
+func (g *graph) step(step Step, exits []graphExit) []graphExit {
+	annotations := []string{string(step.Type())}
+	label := func() string {
+		return step.Name() + "\n" + strings.Join(annotations, ", ")
+	}
+
+	switch s := step.(type) {
+	case *ActionStep:
+		if p := s.retryPolicy; p != nil && p.MaxAttempts > 1 {
+			annotations = append(annotations, fmt.Sprintf("retry %dx", p.MaxAttempts))
+		}
+		if s.timeout > 0 {
+			annotations = append(annotations, "timeout "+s.timeout.String())
+		}
+		if s.compensation != nil {
+			annotations = append(annotations, "compensated")
+		}
+		id := g.node(label(), shapeBox, s.name)
+		g.connect(exits, id)
+		if s.onErrorGoto != "" {
+			g.gotos = append(g.gotos, graphGoto{from: id, step: s.onErrorGoto, group: g.group})
+		}
+		return []graphExit{{id: id}}
+
+	case *ConditionStep:
+		if s.conditionSrc != "" {
+			annotations = append(annotations, s.conditionSrc)
+		}
+		id := g.node(label(), shapeDiamond, s.name)
+		g.connect(exits, id)
+		out := g.branch(s.thenSteps, id, "then")
+		for i, steps := range s.elifSteps {
+			elif := "else if"
+			if i < len(s.elifSrcs) {
+				elif += " " + s.elifSrcs[i]
+			}
+			out = append(out, g.branch(steps, id, elif)...)
+		}
+		return append(out, g.branch(s.elseSteps, id, "else")...)
+
+	case *LoopStep:
+		if s.forEachKey != "" {
+			annotations = append(annotations, "for each "+s.forEachKey)
+		}
+		if s.whileSrc != "" {
+			annotations = append(annotations, "while "+s.whileSrc)
+		}
+		if s.maxIterations > 0 {
+			annotations = append(annotations, fmt.Sprintf("max %d", s.maxIterations))
+		}
+		id := g.node(label(), shapeHexagon, s.name)
+		g.connect(exits, id)
+		if len(s.steps) > 0 {
+			g.connect(g.branch(s.steps, id, "body"), id)
+		}
+		return []graphExit{{id: id, label: "done"}}
+
+	case *ParallelStep:
+		switch s.waitStrategy {
+		case WaitAny:
+			annotations = append(annotations, "wait any")
+		case WaitCount:
+			annotations = append(annotations, fmt.Sprintf("wait %d of %d", s.waitCount, len(s.steps)))
+		}
+		fork := g.node(label(), shapeHexagon, s.name)
+		g.connect(exits, fork)
+		join := g.node("join", shapeCircle, "")
+		for _, branch := range s.steps {
+			g.connect(g.step(branch, []graphExit{{id: fork}}), join)
+		}
+		if len(s.steps) == 0 {
+			g.connect([]graphExit{{id: fork}}, join)
+		}
+		return []graphExit{{id: join}}
+
+	case *SubWorkflowStep:
+		if s.async {
+			annotations = append(annotations, "async")
+		}
+		expand := s.workflow != nil && !g.expanding[s.workflow]
+		if s.workflow != nil {
+			annotations = append(annotations, s.workflow.Name)
+			if !expand {
+				annotations = append(annotations, "cycle")
+			}
+		}
+		id := g.node(label(), shapeSubroutine, s.name)
+		g.connect(exits, id)
+		if !expand {
+			return []graphExit{{id: id}}
+		}
+
+		parent := g.group
+		g.groups = append(g.groups, graphGroup{id: fmt.Sprintf("g%d", len(g.groups)), label: s.workflow.Name, parent: parent})
+		g.group = len(g.groups) - 1
+		g.expanding[s.workflow] = true
+		inner := g.steps(s.workflow.Steps, []graphExit{{id: id}})
+		delete(g.expanding, s.workflow)
+		g.group = parent
+
+		if s.async {
+			return []graphExit{{id: id}}
+		}
+		return inner
+
+	case *AwaitStep:
+		if s.awaitType == AwaitTypeSignal {
+			annotations = append(annotations, "signal "+s.signalName)
+		} else {
+			annotations = append(annotations, "approval")
+		}
+		if s.timeout > 0 {
+			annotations = append(annotations, "timeout "+s.timeout.String())
+		}
+		id := g.node(label(), shapeRounded, s.name)
+		g.connect(exits, id)
+		return []graphExit{{id: id}}
+
+	case *SleepStep:
+		annotations = append(annotations, s.duration.String())
+	case *TimerStep:
+		if s.cronExpr != "" {
+			annotations = append(annotations, s.cronExpr)
+		}
+	case *WaitForChildStep:
+		annotations = append(annotations, s.child)
+	case *ToolStep:
+		annotations = append(annotations, s.toolName)
+	}
+
+	id := g.node(label(), shapeBox, step.Name())
+	g.connect(exits, id)
+	return []graphExit{{id: id}}
+}
+
+// writeMermaid writes the nodes of group and its subgraphs.
+func (g *graph) writeMermaid(b *strings.Builder, group int, indent string) {
+	for _, n := range g.nodes {
+		if n.group != group {
+			continue
+		}
+		text := `"` + mermaidEscape(n.label) + `"`
+		var open, close string
+		switch n.shape {
+		case shapeRounded:
+			open, close = "(", ")"
+		case shapeDiamond:
+			open, close = "{", "}"
+		case shapeHexagon:
+			open, close = "{{", "}}"
+		case shapeCircle:
+			open, close = "((", "))"
+		case shapeSubroutine:
+			open, close = "[[", "]]"
+		default:
+			open, close = "[", "]"
+		}
+		fmt.Fprintf(b, "%s%s%s%s%s\n", indent, n.id, open, text, close)
+	}
+	for i, sub := range g.groups {
+		if i == 0 || sub.parent != group {
+			continue
+		}
+		fmt.Fprintf(b, "%ssubgraph %s[\"%s\"]\n", indent, sub.id, mermaidEscape(sub.label))
+		g.writeMermaid(b, i, indent+"    ")
+		fmt.Fprintf(b, "%send\n", indent)
+	}
+}
+
+// writeDOT writes the nodes of group and its clusters.
+func (g *graph) writeDOT(b *strings.Builder, group int, indent string) {
+	for _, n := range g.nodes {
+		if n.group != group {
+			continue
+		}
+		var shape string
+		switch n.shape {
+		case shapeRounded:
+			shape = "box, style=rounded"
+		case shapeDiamond:
+			shape = "diamond"
+		case shapeHexagon:
+			shape = "hexagon"
+		case shapeCircle:
+			shape = "circle"
+		case shapeSubroutine:
+			shape = "box, peripheries=2"
+		default:
+			shape = "box"
+		}
+		fmt.Fprintf(b, "%s%s [label=%s, shape=%s];\n", indent, n.id, dotQuote(n.label), shape)
+	}
+	for i, sub := range g.groups {
+		if i == 0 || sub.parent != group {
+			continue
+		}
+		fmt.Fprintf(b, "%ssubgraph cluster_%s {\n%s    label=%s;\n", indent, sub.id, indent, dotQuote(sub.label))
+		g.writeDOT(b, i, indent+"    ")
+		fmt.Fprintf(b, "%s}\n", indent)
+	}
+}
+
+// mermaidEscape makes s safe inside a quoted Mermaid label.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "\n", "<br/>", "|", "#124;").Replace(s)
+}
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
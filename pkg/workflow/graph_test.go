@@ -0,0 +1,88 @@
+// Package workflow_test provides tests for workflow diagrams.
+package workflow_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func step(wf *workflow.Workflow) workflow.Step { return wf.Steps[0] }
+
+func graphWorkflow() *workflow.Workflow {
+	billing := workflow.New("billing").
+		Step("charge", succeed("charged")).Retry(workflow.NewRetryPolicy().Attempts(3)).Timeout(30 * time.Second).Then().
+		Build()
+	return workflow.New("order").
+		Step("validate", succeed("ok")).OnErrorGoto("refund").Then().
+		If("big", func(state *workflow.State) bool { return true }).
+		Then(step(workflow.New("x").AwaitApproval("review", []string{"ops"}).Timeout(time.Hour).Then().Build())).
+		ElseIf(func(state *workflow.State) bool { return false }, step(workflow.New("x").Step("flag", succeed("")).Then().Build())).
+		End().
+		Loop("items").ForEach("items").MaxIterations(10).Do(step(workflow.New("x").Step("pack", succeed("")).Then().Build())).End().
+		Parallel("notify",
+			step(workflow.New("x").Step("email", succeed("")).Then().Build()),
+			step(workflow.New("x").Step("sms", succeed("")).Then().Build()),
+		).WaitFor(workflow.WaitAny).Then().
+		SubWorkflow("pay", billing).Then().
+		Step("refund", succeed("")).Then().
+		Build()
+}
+
+func TestWorkflow_ToMermaid(t *testing.T) {
+	out := graphWorkflow().ToMermaid()
+	for _, want := range []string{
+		"flowchart TD\n",
+		`{"big<br/>condition"}`,
+		`("review<br/>await, approval, timeout 1h0m0s")`,
+		`{{"items<br/>loop, for each items, max 10"}}`,
+		`{{"notify<br/>parallel, wait any"}}`,
+		`[["pay<br/>subworkflow, billing"]]`,
+		`subgraph g1["billing"]`,
+		`"charge<br/>action, retry 3x, timeout 30s"`,
+		"-->|then|",
+		"-->|else if|",
+		"-->|else|",
+		"-->|body|",
+		"-->|done|",
+		"-.->|on error|",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestWorkflow_ToDOT(t *testing.T) {
+	out := graphWorkflow().ToDOT()
+	for _, want := range []string{
+		`digraph "order" {`,
+		`[label="big\ncondition", shape=diamond]`,
+		`subgraph cluster_g1 {`,
+		`label="billing";`,
+		`[label="on error", style=dashed]`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "{") != strings.Count(out, "}") {
+		t.Errorf("Unbalanced braces in:\n%s", out)
+	}
+}
+
+func TestWorkflow_GraphSubWorkflowCycle(t *testing.T) {
+	retry := workflow.New("retry").Step("attempt", succeed("")).Then().Build()
+	retry.Steps = append(retry.Steps, step(workflow.New("x").SubWorkflow("again", retry).Then().Build()))
+	parent := workflow.New("parent").SubWorkflow("run", retry).Then().Build()
+
+	out := parent.ToMermaid()
+	if !strings.Contains(out, `[["again<br/>subworkflow, retry, cycle"]]`) {
+		t.Errorf("Expected the cycle drawn as a single node:\n%s", out)
+	}
+	if n := strings.Count(out, "subgraph "); n != 1 {
+		t.Errorf("Expected the cycle expanded once, got %d subgraphs", n)
+	}
+}
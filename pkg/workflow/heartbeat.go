@@ -0,0 +1,262 @@
+// Package workflow provides heartbeat-based detection of stuck executions.
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/notify"
+)
+
+// HistoryStuck is the history entry type recorded when an execution is
+// flagged as stuck.
+const HistoryStuck = "stuck"
+
+// EventStuck is the event name carried in stuck notifications.
+const EventStuck = "workflow.stuck"
+
+// ErrStuck is the cancellation cause of runs cancelled by the stuck monitor.
+var ErrStuck = errors.New("workflow: no heartbeat within the stuck threshold")
+
+// StuckAfter flags executions whose last heartbeat is older than d.
+// The engine heartbeats between steps; long-running handlers should call
+// Heartbeat to show progress. Await and sleep steps are never flagged.
+func (b *Builder) StuckAfter(d time.Duration) *Builder {
+	b.workflow.stuckAfter = d
+	return b
+}
+
+// CancelWhenStuck cancels executions once they are flagged as stuck.
+// The step's context is cancelled with ErrStuck and the run fails.
+func (b *Builder) CancelWhenStuck() *Builder {
+	b.workflow.cancelStuck = true
+	return b
+}
+
+type stateContextKey struct{}
+
+func stateFromContext(ctx context.Context) (*State, bool) {
+	s, ok := ctx.Value(stateContextKey{}).(*State)
+	return s, ok && s != nil
+}
+
+// RunIDFromContext returns the ID of the run executing ctx. ok is false
+// outside a workflow step.
+func RunIDFromContext(ctx context.Context) (id string, ok bool) {
+	s, ok := stateFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return s.ID, true
+}
+
+// Heartbeat records progress for the execution running in ctx.
+// It is a no-op outside a workflow step.
+func Heartbeat(ctx context.Context) {
+	e, ok := engineFromContext(ctx)
+	if !ok {
+		return
+	}
+	if state, ok := stateFromContext(ctx); ok {
+		e.heartbeat(state)
+	}
+}
+
+func (e *Engine) heartbeat(state *State) {
+	now := e.now()
+	state.mu.Lock()
+	state.LastHeartbeat = now
+	state.StuckSince = time.Time{}
+	state.mu.Unlock()
+}
+
+// StuckExecution describes a running execution without a recent heartbeat.
+type StuckExecution struct {
+	StateID       string        `json:"state_id"`
+	Workflow      string        `json:"workflow"`
+	Step          string        `json:"step"`
+	LastHeartbeat time.Time     `json:"last_heartbeat"`
+	Age           time.Duration `json:"age"`
+	Threshold     time.Duration `json:"threshold"`
+	Flagged       bool          `json:"flagged"` // an alert has been sent
+	AutoCancel    bool          `json:"auto_cancel"`
+}
+
+type stuckExecution struct {
+	StuckExecution
+	state    *State
+	workflow *Workflow
+}
+
+// stuck returns running executions whose heartbeat is older than their
+// workflow's threshold.
+func (e *Engine) stuck() []stuckExecution {
+	now := e.now()
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var out []stuckExecution
+	for id, state := range e.running {
+		wf, ok := e.definitions[id]
+		if !ok || wf.stuckAfter <= 0 {
+			continue
+		}
+
+		state.mu.RLock()
+		current, beat, flagged := state.CurrentStep, state.LastHeartbeat, !state.StuckSince.IsZero()
+		waiting := state.AwaitingStep != ""
+		state.mu.RUnlock()
+		if waiting || beat.IsZero() || current >= len(wf.Steps) {
+			continue
+		}
+		step := wf.Steps[current]
+		if step.Type() == StepTypeSleep || step.Type() == StepTypeAwait {
+			continue
+		}
+
+		age := now.Sub(beat)
+		if age < wf.stuckAfter {
+			continue
+		}
+		out = append(out, stuckExecution{
+			StuckExecution: StuckExecution{
+				StateID:       id,
+				Workflow:      wf.Name,
+				Step:          step.Name(),
+				LastHeartbeat: beat,
+				Age:           age,
+				Threshold:     wf.stuckAfter,
+				Flagged:       flagged,
+				AutoCancel:    wf.cancelStuck,
+			},
+			state:    state,
+			workflow: wf,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].LastHeartbeat.Equal(out[j].LastHeartbeat) {
+			return out[i].LastHeartbeat.Before(out[j].LastHeartbeat)
+		}
+		return out[i].StateID < out[j].StateID
+	})
+	return out
+}
+
+// Stuck lists running executions without a heartbeat within their
+// workflow's threshold, oldest heartbeat first.
+func (e *Engine) Stuck() []StuckExecution {
+	list := []StuckExecution{}
+	for _, s := range e.stuck() {
+		list = append(list, s.StuckExecution)
+	}
+	return list
+}
+
+// CheckStuck flags newly stuck executions and returns how many were flagged.
+// Each stall is flagged once: the state history records it, the engine
+// notifier receives a workflow.stuck alert and, if the workflow uses
+// CancelWhenStuck, the run is cancelled.
+func (e *Engine) CheckStuck(ctx context.Context) int {
+	now := e.now()
+
+	flagged := 0
+	for _, s := range e.stuck() {
+		if !s.state.markStuck(s.Step, s.Age, now) {
+			continue
+		}
+		flagged++
+
+		if err := e.alertStuck(ctx, s.StuckExecution); err != nil {
+			s.state.mu.Lock()
+			s.state.Errors = append(s.state.Errors, fmt.Sprintf("stuck alert failed: %v", err))
+			s.state.mu.Unlock()
+		}
+		if e.persistence != nil {
+			e.persistence.Save(ctx, s.state)
+		}
+		if s.workflow.cancelStuck {
+			e.cancelRun(s.StateID, ErrStuck)
+		}
+	}
+	return flagged
+}
+
+// markStuck records a stuck history entry unless the current stall is
+// already flagged.
+func (s *State) markStuck(step string, age time.Duration, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.StuckSince.IsZero() {
+		return false
+	}
+	s.StuckSince = now
+	s.History = append(s.History, HistoryEntry{
+		Type:      HistoryStuck,
+		Step:      step,
+		Message:   fmt.Sprintf("no heartbeat for %v", age),
+		Timestamp: now,
+	})
+	return true
+}
+
+func (e *Engine) alertStuck(ctx context.Context, s StuckExecution) error {
+	e.mu.RLock()
+	n := e.notifier
+	e.mu.RUnlock()
+	if n == nil {
+		return nil
+	}
+
+	body := fmt.Sprintf("Step '%s' has not reported progress for %v", s.Step, s.Age.Round(time.Second))
+	if s.AutoCancel {
+		body += "; the run is being cancelled"
+	}
+	return n.Notify(ctx, notify.Message{
+		Title:    fmt.Sprintf("Workflow %s is stuck", s.StateID),
+		Body:     body,
+		Severity: notify.SeverityCritical,
+		Fields: map[string]string{
+			"event":          EventStuck,
+			"state_id":       s.StateID,
+			"workflow":       s.Workflow,
+			"step":           s.Step,
+			"last_heartbeat": s.LastHeartbeat.Format(time.RFC3339),
+			"age":            s.Age.String(),
+		},
+		Timestamp: e.now(),
+	})
+}
+
+// StartStuckMonitor checks for stuck executions every interval until ctx is done.
+func (e *Engine) StartStuckMonitor(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.CheckStuck(ctx)
+			}
+		}
+	}()
+}
+
+// ============ Cancellation ============
+
+// cancelRun cancels a running execution's context with cause.
+func (e *Engine) cancelRun(stateID string, cause error) bool {
+	e.mu.RLock()
+	cancel, ok := e.cancels[stateID]
+	e.mu.RUnlock()
+	if ok {
+		cancel(cause)
+	}
+	return ok
+}
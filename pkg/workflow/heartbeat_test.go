@@ -0,0 +1,193 @@
+// Package workflow_test provides tests for stuck-step detection.
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// blockingWorkflow has a step that never returns unless its context is
+// cancelled. started is closed once the step is running.
+func blockingWorkflow(started chan struct{}, beats <-chan struct{}) *workflow.Builder {
+	return workflow.New("blocking").
+		Step("prepare", func(ctx context.Context, state *workflow.State) (any, error) {
+			return "ok", nil
+		}).Then().
+		Step("call-api", func(ctx context.Context, state *workflow.State) (any, error) {
+			close(started)
+			for {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-beats:
+					workflow.Heartbeat(ctx)
+				}
+			}
+		}).Then()
+}
+
+func startBlocking(t *testing.T, engine *workflow.Engine, wf *workflow.Workflow, started chan struct{}) (<-chan execResult, context.CancelFunc) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan execResult, 1)
+	go func() {
+		state, err := engine.Execute(ctx, wf, nil)
+		done <- execResult{state, err}
+	}()
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Blocking step never started")
+	}
+	return done, cancel
+}
+
+func TestStuck_DetectAndAlert(t *testing.T) {
+	clock := workflow.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	notifier := &recordingNotifier{}
+	engine := workflow.NewEngine(nil)
+	engine.SetClock(clock)
+	engine.SetNotifier(notifier)
+
+	started := make(chan struct{})
+	wf := blockingWorkflow(started, nil).StuckAfter(10 * time.Minute).Build()
+	done, cancel := startBlocking(t, engine, wf, started)
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	clock.Advance(9 * time.Minute)
+	if n := engine.CheckStuck(context.Background()); n != 0 || len(engine.Stuck()) != 0 {
+		t.Fatalf("Flagged before the threshold: %d", n)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if n := engine.CheckStuck(context.Background()); n != 1 {
+		t.Fatalf("Expected 1 stuck execution, got %d", n)
+	}
+	if n := engine.CheckStuck(context.Background()); n != 0 {
+		t.Errorf("Stall flagged twice: %d", n)
+	}
+
+	stuck := engine.Stuck()
+	if len(stuck) != 1 {
+		t.Fatalf("Expected 1 stuck execution, got %+v", stuck)
+	}
+	if s := stuck[0]; s.Step != "call-api" || s.Age != 11*time.Minute || !s.Flagged || s.AutoCancel {
+		t.Errorf("Unexpected stuck execution: %+v", s)
+	}
+
+	if notifier.count() != 1 {
+		t.Fatalf("Expected 1 alert, got %d", notifier.count())
+	}
+	msg := notifier.messages[0]
+	if msg.Fields["event"] != workflow.EventStuck || msg.Fields["step"] != "call-api" {
+		t.Errorf("Unexpected alert: %+v", msg)
+	}
+
+	state, ok := engine.GetState(stuck[0].StateID)
+	if !ok {
+		t.Fatal("State not running")
+	}
+	var found bool
+	for _, h := range state.History {
+		if h.Type == workflow.HistoryStuck && h.Step == "call-api" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Stuck entry missing from history")
+	}
+}
+
+func TestStuck_HeartbeatKeepsRunAlive(t *testing.T) {
+	clock := workflow.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	engine := workflow.NewEngine(nil)
+	engine.SetClock(clock)
+
+	started := make(chan struct{})
+	beats := make(chan struct{})
+	wf := blockingWorkflow(started, beats).StuckAfter(10 * time.Minute).Build()
+	done, cancel := startBlocking(t, engine, wf, started)
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for i := 0; i < 3; i++ {
+		clock.Advance(6 * time.Minute)
+		beats <- struct{}{}
+		beats <- struct{}{} // the first beat has been recorded once the second is received
+		if n := engine.CheckStuck(context.Background()); n != 0 {
+			t.Fatalf("Heartbeating run flagged as stuck after %d beats", i+1)
+		}
+	}
+
+	clock.Advance(10 * time.Minute)
+	if n := engine.CheckStuck(context.Background()); n != 1 {
+		t.Errorf("Expected run to be flagged once heartbeats stop, got %d", n)
+	}
+}
+
+func TestStuck_AutoCancel(t *testing.T) {
+	clock := workflow.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	notifier := &recordingNotifier{}
+	engine := workflow.NewEngine(nil)
+	engine.SetClock(clock)
+	engine.SetNotifier(notifier)
+
+	started := make(chan struct{})
+	wf := blockingWorkflow(started, nil).StuckAfter(time.Minute).CancelWhenStuck().Build()
+	done, cancel := startBlocking(t, engine, wf, started)
+	defer cancel()
+
+	clock.Advance(5 * time.Minute)
+	if n := engine.CheckStuck(context.Background()); n != 1 {
+		t.Fatalf("Expected 1 stuck execution, got %d", n)
+	}
+
+	var res execResult
+	select {
+	case res = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stuck run was not cancelled")
+	}
+	if !errors.Is(res.err, workflow.ErrStuck) {
+		t.Errorf("Expected ErrStuck, got %v", res.err)
+	}
+	if res.state.Status != workflow.StatusFailed {
+		t.Errorf("Expected failed status, got %s", res.state.Status)
+	}
+	if notifier.count() != 1 {
+		t.Errorf("Expected 1 alert, got %d", notifier.count())
+	}
+	if len(engine.Stuck()) != 0 {
+		t.Error("Cancelled run still listed as stuck")
+	}
+}
+
+func TestStuck_AwaitNotFlagged(t *testing.T) {
+	clock := workflow.NewFakeClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+	engine := workflow.NewEngine(nil)
+	engine.SetClock(clock)
+
+	wf := workflow.New("approval").
+		StuckAfter(time.Minute).
+		AwaitSignal("wait", "go").Then().
+		Build()
+	done := startAwaiting(t, engine, wf)
+	defer func() {
+		engine.SendSignal(context.Background(), "go", nil)
+		<-done
+	}()
+
+	clock.Advance(time.Hour)
+	if n := engine.CheckStuck(context.Background()); n != 0 {
+		t.Errorf("Await flagged as stuck: %d", n)
+	}
+}
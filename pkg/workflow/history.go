@@ -0,0 +1,80 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EventStepExecuted is emitted after every recorded StepExecution.
+const EventStepExecuted = "workflow.step_executed"
+
+// StepExecution records one execution of a step: every attempt of an
+// action step, and every run of another top-level step.
+type StepExecution struct {
+	Step        string        `json:"step"`
+	Attempt     int           `json:"attempt"`
+	StartedAt   time.Time     `json:"started_at"`
+	CompletedAt time.Time     `json:"completed_at"`
+	Duration    time.Duration `json:"duration"`
+	Error       string        `json:"error,omitempty"`
+	// Result is the JSON encoding of the step result, cut to 256 bytes.
+	Result string `json:"result,omitempty"`
+}
+
+// GetHistory returns the step executions of a run, running or persisted.
+func (e *Engine) GetHistory(ctx context.Context, stateID string) ([]StepExecution, error) {
+	state, err := e.LoadState(ctx, stateID)
+	if err != nil {
+		return nil, err
+	}
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	return append([]StepExecution(nil), state.Executions...), nil
+}
+
+// stepNow returns the current time on the clock of the engine running ctx.
+func stepNow(ctx context.Context) time.Time {
+	if engine, ok := engineFromContext(ctx); ok {
+		return engine.now()
+	}
+	return time.Now()
+}
+
+// recordExecution appends an execution of step that began at started to
+// the state and emits it.
+func recordExecution(ctx context.Context, state *State, step string, attempt int, started time.Time, result any, err error) {
+	completed := stepNow(ctx)
+	exec := StepExecution{
+		Step:        step,
+		Attempt:     attempt,
+		StartedAt:   started,
+		CompletedAt: completed,
+		Duration:    completed.Sub(started),
+	}
+	if err != nil {
+		exec.Error = err.Error()
+	} else if result != nil {
+		exec.Result = resultPreview(result)
+	}
+
+	state.mu.Lock()
+	state.Executions = append(state.Executions, exec)
+	state.mu.Unlock()
+
+	if engine, ok := engineFromContext(ctx); ok {
+		engine.publish(ctx, state, EventStepExecuted, step, err, &exec)
+	}
+}
+
+func resultPreview(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data = []byte(fmt.Sprint(v))
+	}
+	if len(data) > truncatedPreviewSize {
+		return string(data[:truncatedPreviewSize]) + "..."
+	}
+	return string(data)
+}
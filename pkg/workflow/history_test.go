@@ -0,0 +1,90 @@
+// Package workflow_test provides tests for step execution history.
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func TestEngine_GetHistory(t *testing.T) {
+	clock := workflow.NewFakeClock(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC))
+	store := workflow.NewMemoryPersistence()
+	engine := workflow.NewEngine(store)
+	engine.SetClock(clock)
+	var executed []*workflow.StepExecution
+	engine.Subscribe(func(ctx context.Context, ev workflow.RunEvent) {
+		if ev.Type == workflow.EventStepExecuted {
+			executed = append(executed, ev.Execution)
+		}
+	})
+
+	calls := 0
+	wf := workflow.New("import").
+		Step("fetch", func(ctx context.Context, state *workflow.State) (any, error) {
+			calls++
+			clock.Advance(time.Second)
+			if calls == 1 {
+				return nil, errors.New("timeout")
+			}
+			return strings.Repeat("x", 300), nil
+		}).Retry(workflow.NewRetryPolicy().Attempts(2).Exponential(time.Millisecond, time.Millisecond)).Then().
+		Checkpoint("fetched").
+		Build()
+
+	state, err := engine.Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	history, err := engine.GetHistory(context.Background(), state.ID)
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 3 || len(executed) != 3 {
+		t.Fatalf("Expected 3 executions and events, got %+v and %d events", history, len(executed))
+	}
+	failed, fetched, checkpoint := history[0], history[1], history[2]
+	if failed.Step != "fetch" || failed.Attempt != 1 || failed.Error != "timeout" || failed.Duration != time.Second {
+		t.Errorf("Unexpected first attempt: %+v", failed)
+	}
+	if fetched.Attempt != 2 || fetched.Error != "" || !fetched.CompletedAt.Equal(fetched.StartedAt.Add(time.Second)) {
+		t.Errorf("Unexpected second attempt: %+v", fetched)
+	}
+	if len(fetched.Result) != 259 || !strings.HasSuffix(fetched.Result, "...") {
+		t.Errorf("Expected a truncated result preview, got %d bytes", len(fetched.Result))
+	}
+	if checkpoint.Step != "fetched" || checkpoint.Attempt != 1 {
+		t.Errorf("Unexpected checkpoint execution: %+v", checkpoint)
+	}
+
+	if _, err := engine.GetHistory(context.Background(), "missing"); err == nil {
+		t.Error("Expected an error for an unknown run")
+	}
+}
+
+func TestParallelStep_MergesHistory(t *testing.T) {
+	ok := func(ctx context.Context, state *workflow.State) (any, error) { return "ok", nil }
+	wf := workflow.New("fanout").
+		Parallel("notify",
+			action("email", ok),
+			action("chat", ok),
+		).Then().
+		Build()
+
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := map[string]bool{}
+	for _, exec := range state.Executions {
+		steps[exec.Step] = true
+	}
+	if len(state.Executions) != 3 || !steps["email"] || !steps["chat"] || !steps["notify"] {
+		t.Errorf("Expected both branches and the parallel step, got %+v", state.Executions)
+	}
+}
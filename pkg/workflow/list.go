@@ -0,0 +1,117 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ListFilter selects stored runs. Zero fields match everything.
+type ListFilter struct {
+	Workflow string
+	Status   Status
+	// Since and Until bound StartedAt: Since <= StartedAt < Until.
+	Since time.Time
+	Until time.Time
+	// Offset skips that many matches; Limit caps the result, zero meaning
+	// no limit.
+	Offset int
+	Limit  int
+}
+
+func (f ListFilter) match(state *State) bool {
+	switch {
+	case f.Workflow != "" && state.Workflow != f.Workflow:
+		return false
+	case f.Status != "" && state.Status != f.Status:
+		return false
+	case !f.Since.IsZero() && state.StartedAt.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !state.StartedAt.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// page applies the offset and limit to matches sorted oldest first.
+func (f ListFilter) page(states []*State) []*State {
+	if f.Offset >= len(states) {
+		return nil
+	}
+	states = states[max(f.Offset, 0):]
+	if f.Limit > 0 && len(states) > f.Limit {
+		states = states[:f.Limit]
+	}
+	return states
+}
+
+// RunSummary is a short description of a run for listings.
+type RunSummary struct {
+	ID           string    `json:"id"`
+	Workflow     string    `json:"workflow"`
+	Status       Status    `json:"status"`
+	CurrentStep  int       `json:"current_step"`
+	AwaitingStep string    `json:"awaiting_step,omitempty"`
+	Errors       int       `json:"errors,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+	CompletedAt  time.Time `json:"completed_at,omitempty"`
+}
+
+// Summary returns a summary of the state.
+func (s *State) Summary() RunSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return RunSummary{
+		ID:           s.ID,
+		Workflow:     s.Workflow,
+		Status:       s.Status,
+		CurrentStep:  s.CurrentStep,
+		AwaitingStep: s.AwaitingStep,
+		Errors:       len(s.Errors),
+		StartedAt:    s.StartedAt,
+		CompletedAt:  s.CompletedAt,
+	}
+}
+
+// ListRunning returns the runs executing on this engine, oldest first.
+func (e *Engine) ListRunning() []*State {
+	e.mu.RLock()
+	states := make([]*State, 0, len(e.running))
+	for _, state := range e.running {
+		states = append(states, state)
+	}
+	e.mu.RUnlock()
+	sortStates(states)
+	return states
+}
+
+// ListByStatus returns the stored runs with status, oldest first.
+func (e *Engine) ListByStatus(ctx context.Context, status Status) ([]*State, error) {
+	if _, ok := e.persistence.(RunLister); e.persistence != nil && !ok {
+		return e.persistence.ListByStatus(ctx, status)
+	}
+	return e.List(ctx, ListFilter{Status: status})
+}
+
+// List returns the stored runs matching filter, oldest first. Without
+// persistence it lists the runs executing on this engine. Persistence
+// that does not implement RunLister is an error.
+func (e *Engine) List(ctx context.Context, filter ListFilter) ([]*State, error) {
+	if e.persistence != nil {
+		lister, ok := e.persistence.(RunLister)
+		if !ok {
+			return nil, fmt.Errorf("workflow: %T cannot list runs", e.persistence)
+		}
+		return lister.List(ctx, filter)
+	}
+	var states []*State
+	for _, state := range e.ListRunning() {
+		state.mu.RLock()
+		ok := filter.match(state)
+		state.mu.RUnlock()
+		if ok {
+			states = append(states, state)
+		}
+	}
+	return filter.page(states), nil
+}
@@ -0,0 +1,235 @@
+// Package workflow provides transactional job enqueueing from steps.
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/queue"
+)
+
+// SetOutbox enables EnqueueAfterCommit. Jobs staged by a step are stored
+// in the workflow state by the save that follows the step and sent through
+// outbox afterwards. Create the outbox with the engine's OutboxStore and
+// run its relay to recover intents left unsent by a crash:
+//
+//	outbox := queue.NewOutbox(q, engine.OutboxStore())
+//	engine.SetOutbox(outbox)
+//	outbox.StartRelay(ctx, 5*time.Second)
+func (e *Engine) SetOutbox(outbox *queue.Outbox) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.outbox = outbox
+}
+
+func (e *Engine) getOutbox() *queue.Outbox {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.outbox
+}
+
+// EnqueueAfterCommit stages job to be enqueued once the current step has
+// completed and its result is saved. If the step fails the job is dropped;
+// if the process crashes after the save, the outbox relay enqueues it.
+// A step that is re-run stages its jobs under the same intent IDs, so each
+// job is enqueued once.
+func EnqueueAfterCommit(ctx context.Context, job *queue.Job) error {
+	e, ok := engineFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("workflow: EnqueueAfterCommit called outside a workflow step")
+	}
+	state, ok := stateFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("workflow: EnqueueAfterCommit called outside a workflow step")
+	}
+	outbox := e.getOutbox()
+	if outbox == nil {
+		return fmt.Errorf("workflow: no outbox configured")
+	}
+
+	state.mu.RLock()
+	step := state.CurrentStep
+	state.mu.RUnlock()
+
+	_, err := outbox.Stage(ctx, outboxKey(state.ID, step), job)
+	return err
+}
+
+func outboxKey(stateID string, step int) string {
+	return fmt.Sprintf("%s/%d", stateID, step)
+}
+
+// commitOutbox stores the intents staged by step in state, saves it and
+// sends them.
+func (e *Engine) commitOutbox(ctx context.Context, state *State, step int) {
+	outbox := e.getOutbox()
+	if outbox == nil {
+		return
+	}
+	intents := outbox.Take(outboxKey(state.ID, step))
+	if len(intents) == 0 {
+		return
+	}
+
+	// A re-run step stages intents already in the state; keep the stored
+	// copy so intents sent before are not sent again.
+	var unsent []queue.Intent
+	state.mu.Lock()
+	for _, intent := range intents {
+		i := intentIndex(state.Outbox, intent.ID)
+		if i < 0 {
+			state.Outbox = append(state.Outbox, intent)
+			unsent = append(unsent, intent)
+		} else if !state.Outbox[i].Sent() {
+			unsent = append(unsent, state.Outbox[i])
+		}
+	}
+	state.mu.Unlock()
+
+	if e.persistence != nil {
+		if err := e.persistence.Save(ctx, state); err != nil {
+			return // the intents are not durable; the relay cannot recover them
+		}
+	}
+	outbox.Send(ctx, unsent)
+}
+
+func (e *Engine) discardOutbox(state *State, step int) {
+	if outbox := e.getOutbox(); outbox != nil {
+		outbox.Discard(outboxKey(state.ID, step))
+	}
+}
+
+// discardStaged drops the jobs staged so far by the step running in ctx.
+func discardStaged(ctx context.Context, state *State) {
+	if e, ok := engineFromContext(ctx); ok {
+		state.mu.RLock()
+		step := state.CurrentStep
+		state.mu.RUnlock()
+		e.discardOutbox(state, step)
+	}
+}
+
+// pendingIntents reports whether s holds outbox intents not yet sent.
+func (s *State) pendingIntents() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, intent := range s.Outbox {
+		if !intent.Sent() {
+			return true
+		}
+	}
+	return false
+}
+
+func intentIndex(intents []queue.Intent, id string) int {
+	for i, intent := range intents {
+		if intent.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ============ Intent Store ============
+
+// OutboxStore returns the queue.IntentStore over the engine's workflow
+// states, for use with queue.NewOutbox.
+func (e *Engine) OutboxStore() queue.IntentStore {
+	return outboxStore{e}
+}
+
+type outboxStore struct{ e *Engine }
+
+var allStatuses = []Status{
+	StatusPending, StatusRunning, StatusPaused, StatusAwaitingSignal,
+	StatusAwaitingApproval, StatusAwaitingTimer, StatusCompleted, StatusFailed,
+	StatusCompensating, StatusCancelled,
+}
+
+// statesWithIntents returns the states holding unsent outbox intents,
+// preferring the in-memory copy of running states. Persistence that is
+// not an OutboxIndex is scanned in full.
+func (s outboxStore) statesWithIntents(ctx context.Context) ([]*State, error) {
+	if s.e.persistence == nil {
+		return nil, fmt.Errorf("workflow: outbox requires persistence")
+	}
+
+	var stored []*State
+	if index, ok := s.e.persistence.(OutboxIndex); ok {
+		var err error
+		if stored, err = index.PendingOutbox(ctx); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, status := range allStatuses {
+			found, err := s.e.persistence.ListByStatus(ctx, status)
+			if err != nil {
+				return nil, err
+			}
+			stored = append(stored, found...)
+		}
+	}
+
+	var states []*State
+	for _, state := range stored {
+		if running, ok := s.e.GetState(state.ID); ok {
+			state = running
+		}
+		if state.pendingIntents() {
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+// Pending returns the unsent intents of all stored states.
+func (s outboxStore) Pending(ctx context.Context) ([]queue.Intent, error) {
+	states, err := s.statesWithIntents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []queue.Intent
+	for _, state := range states {
+		state.mu.RLock()
+		for _, intent := range state.Outbox {
+			if !intent.Sent() {
+				pending = append(pending, intent)
+			}
+		}
+		state.mu.RUnlock()
+	}
+	return pending, nil
+}
+
+// MarkSent sets SentAt on the intents with ids and saves their states.
+func (s outboxStore) MarkSent(ctx context.Context, ids []string, sentAt time.Time) error {
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+
+	states, err := s.statesWithIntents(ctx)
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		changed := false
+		state.mu.Lock()
+		for i := range state.Outbox {
+			if want[state.Outbox[i].ID] && !state.Outbox[i].Sent() {
+				state.Outbox[i].SentAt = sentAt
+				changed = true
+			}
+		}
+		state.mu.Unlock()
+		if changed {
+			if err := s.e.persistence.Save(ctx, state); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
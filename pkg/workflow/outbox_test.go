@@ -0,0 +1,209 @@
+// Package workflow_test provides tests for the transactional job outbox.
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/queue"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// crashingQueue fails every enqueue, standing in for a process that dies
+// after saving the step result but before the job reaches the queue.
+type crashingQueue struct{ *queue.MemoryQueue }
+
+func (crashingQueue) Enqueue(ctx context.Context, job *queue.Job) error {
+	return errors.New("process crashed")
+}
+
+// flakyStore fails MarkSent once, standing in for a crash between enqueue
+// and recording the intent as sent.
+type flakyStore struct {
+	queue.IntentStore
+	failed bool
+}
+
+func (f *flakyStore) MarkSent(ctx context.Context, ids []string, sentAt time.Time) error {
+	if !f.failed {
+		f.failed = true
+		return errors.New("process crashed")
+	}
+	return f.IntentStore.MarkSent(ctx, ids, sentAt)
+}
+
+func orderWorkflow(fail *bool) *workflow.Workflow {
+	return workflow.New("orders").
+		Step("charge", func(ctx context.Context, state *workflow.State) (any, error) {
+			job, _ := queue.NewJob("send_receipt", map[string]string{"order": "42"})
+			if err := workflow.EnqueueAfterCommit(ctx, job); err != nil {
+				return nil, err
+			}
+			if fail != nil && *fail {
+				return nil, errors.New("card declined")
+			}
+			return "charged", nil
+		}).Then().
+		Build()
+}
+
+func queueLen(t *testing.T, q queue.Queue) int64 {
+	t.Helper()
+	n, err := q.Len(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestEnqueueAfterCommit(t *testing.T) {
+	persistence := workflow.NewMemoryPersistence()
+	engine := workflow.NewEngine(persistence)
+	q := queue.NewMemoryQueue()
+	engine.SetOutbox(queue.NewOutbox(q, engine.OutboxStore()))
+
+	state, err := engine.Execute(context.Background(), orderWorkflow(nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queueLen(t, q) != 1 {
+		t.Fatalf("Expected 1 job, got %d", queueLen(t, q))
+	}
+	if len(state.Outbox) != 1 || !state.Outbox[0].Sent() {
+		t.Fatalf("Expected sent intent in state, got %+v", state.Outbox)
+	}
+
+	job, _ := q.Dequeue(context.Background(), time.Second)
+	if job.ID != state.Outbox[0].ID || job.Type != "send_receipt" {
+		t.Errorf("Expected job with intent ID, got %+v", job)
+	}
+}
+
+func TestEnqueueAfterCommit_CrashBeforeRelay(t *testing.T) {
+	persistence := workflow.NewMemoryPersistence()
+
+	// First process: the step result and intent are saved, the job is lost.
+	crashed := workflow.NewEngine(persistence)
+	crashed.SetOutbox(queue.NewOutbox(crashingQueue{queue.NewMemoryQueue()}, crashed.OutboxStore()))
+	state, err := crashed.Execute(context.Background(), orderWorkflow(nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := persistence.Load(context.Background(), state.ID)
+	if len(stored.Outbox) != 1 || stored.Outbox[0].Sent() {
+		t.Fatalf("Expected unsent intent persisted, got %+v", stored.Outbox)
+	}
+
+	// Recovery: a new process relays the pending intent once.
+	engine := workflow.NewEngine(persistence)
+	q := queue.NewMemoryQueue()
+	outbox := queue.NewOutbox(q, engine.OutboxStore())
+	for i := 0; i < 3; i++ {
+		if _, err := outbox.Relay(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if queueLen(t, q) != 1 {
+		t.Errorf("Expected exactly 1 job after recovery, got %d", queueLen(t, q))
+	}
+	stored, _ = persistence.Load(context.Background(), state.ID)
+	if !stored.Outbox[0].Sent() {
+		t.Error("Expected intent marked sent after relay")
+	}
+}
+
+func TestEnqueueAfterCommit_CrashAfterEnqueue(t *testing.T) {
+	persistence := workflow.NewMemoryPersistence()
+	deliveries := queue.NewMemoryDeliveryLog() // durable across processes
+	q := queue.NewMemoryQueue()
+
+	crashed := workflow.NewEngine(persistence)
+	store := &flakyStore{IntentStore: crashed.OutboxStore()}
+	crashed.SetOutbox(queue.NewOutbox(q, store, queue.WithDeliveryLog(deliveries)))
+	if _, err := crashed.Execute(context.Background(), orderWorkflow(nil), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := workflow.NewEngine(persistence)
+	outbox := queue.NewOutbox(q, engine.OutboxStore(), queue.WithDeliveryLog(deliveries))
+	n, err := outbox.Relay(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("Expected the pending intent to be marked sent, got %d", n)
+	}
+	if queueLen(t, q) != 1 {
+		t.Errorf("Expected exactly 1 job, got %d", queueLen(t, q))
+	}
+}
+
+func TestEnqueueAfterCommit_FailedStep(t *testing.T) {
+	persistence := workflow.NewMemoryPersistence()
+	engine := workflow.NewEngine(persistence)
+	q := queue.NewMemoryQueue()
+	outbox := queue.NewOutbox(q, engine.OutboxStore())
+	engine.SetOutbox(outbox)
+
+	fail := true
+	state, err := engine.Execute(context.Background(), orderWorkflow(&fail), nil)
+	if err == nil {
+		t.Fatal("Expected step failure")
+	}
+	outbox.Relay(context.Background())
+	if queueLen(t, q) != 0 || len(state.Outbox) != 0 {
+		t.Errorf("Jobs staged by a failed step must not be enqueued")
+	}
+}
+
+func TestEnqueueAfterCommit_Retry(t *testing.T) {
+	engine := workflow.NewEngine(workflow.NewMemoryPersistence())
+	q := queue.NewMemoryQueue()
+	engine.SetOutbox(queue.NewOutbox(q, engine.OutboxStore()))
+
+	attempts := 0
+	wf := workflow.New("retrying").
+		Step("charge", func(ctx context.Context, state *workflow.State) (any, error) {
+			attempts++
+			job, _ := queue.NewJob("send_receipt", attempts)
+			workflow.EnqueueAfterCommit(ctx, job)
+			if attempts < 3 {
+				return nil, errors.New("timeout")
+			}
+			return "charged", nil
+		}).Retry(&workflow.RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond}).Then().
+		Build()
+
+	state, err := engine.Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queueLen(t, q) != 1 || len(state.Outbox) != 1 {
+		t.Fatalf("Expected only the successful attempt's job, got %d", queueLen(t, q))
+	}
+	job, _ := q.Dequeue(context.Background(), time.Second)
+	var attempt int
+	job.UnmarshalPayload(&attempt)
+	if attempt != 3 {
+		t.Errorf("Expected job from attempt 3, got %d", attempt)
+	}
+}
+
+func TestEnqueueAfterCommit_Errors(t *testing.T) {
+	job, _ := queue.NewJob("x", 1)
+	if err := workflow.EnqueueAfterCommit(context.Background(), job); err == nil {
+		t.Error("Expected error outside a workflow step")
+	}
+
+	engine := workflow.NewEngine(workflow.NewMemoryPersistence())
+	wf := workflow.New("no-outbox").
+		Step("s", func(ctx context.Context, state *workflow.State) (any, error) {
+			return nil, workflow.EnqueueAfterCommit(ctx, job)
+		}).Then().
+		Build()
+	if _, err := engine.Execute(context.Background(), wf, nil); err == nil {
+		t.Error("Expected error without an outbox")
+	}
+}
@@ -0,0 +1,597 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ============ Persistence ============
+
+var (
+	// ErrStateNotFound is returned when a workflow state does not exist.
+	ErrStateNotFound = errors.New("workflow: state not found")
+	// ErrDefinitionNotFound is returned when a workflow definition does not exist.
+	ErrDefinitionNotFound = errors.New("workflow: definition not found")
+)
+
+// Persistence handles durable workflow storage.
+// Implementations must be safe for concurrent use.
+type Persistence interface {
+	// Save creates or replaces a workflow state.
+	Save(ctx context.Context, state *State) error
+	// Load returns a workflow state or ErrStateNotFound.
+	Load(ctx context.Context, id string) (*State, error)
+	// Delete removes a workflow state. Deleting a missing state is not an error.
+	Delete(ctx context.Context, id string) error
+	// ListByStatus returns the states with status, oldest first.
+	ListByStatus(ctx context.Context, status Status) ([]*State, error)
+
+	// SaveDefinition creates or replaces a workflow definition.
+	SaveDefinition(ctx context.Context, def *Definition) error
+	// LoadDefinition returns a workflow definition or ErrDefinitionNotFound.
+	LoadDefinition(ctx context.Context, name string) (*Definition, error)
+	// ListDefinitions returns all workflow definitions sorted by name.
+	ListDefinitions(ctx context.Context) ([]*Definition, error)
+}
+
+// RunLister is implemented by Persistence that can list states by a
+// filter. All implementations in this package do.
+type RunLister interface {
+	// List returns the states matching filter, oldest first.
+	List(ctx context.Context, filter ListFilter) ([]*State, error)
+}
+
+// OutboxIndex is implemented by Persistence that tracks the states holding
+// unsent outbox intents, so the outbox relay does not read every stored
+// state. All implementations in this package do.
+type OutboxIndex interface {
+	// PendingOutbox returns the states with unsent outbox intents.
+	PendingOutbox(ctx context.Context) ([]*State, error)
+}
+
+// Definition is a stored, serializable workflow definition such as a
+// rendered blueprint spec.
+type Definition struct {
+	Name      string          `json:"name"`
+	Version   string          `json:"version,omitempty"`
+	Source    string          `json:"source,omitempty"` // e.g. the blueprint it came from
+	Spec      json.RawMessage `json:"spec"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+func sortStates(states []*State) {
+	sort.SliceStable(states, func(i, j int) bool {
+		if states[i].StartedAt.Equal(states[j].StartedAt) {
+			return states[i].ID < states[j].ID
+		}
+		return states[i].StartedAt.Before(states[j].StartedAt)
+	})
+}
+
+// ============ Redis Persistence ============
+
+// RedisPersistence stores workflow state in Redis/DragonflyDB.
+// States expire after seven days. List reads a sorted set per workflow and
+// status, scored by start time, that Save and Delete keep up to date;
+// states saved before the index existed are not listed. Save trims the
+// entries of expired states, and an index itself expires seven days after
+// its last save.
+type RedisPersistence struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewPersistence creates persistence with Redis.
+func NewPersistence(client *redis.Client) *RedisPersistence {
+	return NewRedisPersistence(client, "goflow:workflow")
+}
+
+// NewRedisPersistence creates Redis persistence storing keys under prefix.
+func NewRedisPersistence(client *redis.Client, prefix string) *RedisPersistence {
+	return &RedisPersistence{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (p *RedisPersistence) key(id string) string   { return fmt.Sprintf("%s:%s", p.prefix, id) }
+func (p *RedisPersistence) definitionsKey() string { return p.prefix + ":definitions" }
+func (p *RedisPersistence) indexOfKey() string     { return p.prefix + ":index-of" } // id -> index key
+func (p *RedisPersistence) indexesKey() string     { return p.prefix + ":indexes" }  // all index keys
+func (p *RedisPersistence) savedKey() string       { return p.prefix + ":saved" }    // ids by last save
+func (p *RedisPersistence) outboxKey() string      { return p.prefix + ":outbox" }   // ids with unsent intents
+
+// stateTTL is how long a state is kept after its last save.
+const stateTTL = 7 * 24 * time.Hour
+
+// trimBatch bounds the expired index entries one Save removes.
+const trimBatch = 100
+
+func (p *RedisPersistence) indexKey(workflow string, status Status) string {
+	return fmt.Sprintf("%s:index:%s:%s", p.prefix, status, workflow)
+}
+
+// isStateKey reports whether key holds a state rather than an index or
+// the definitions.
+func (p *RedisPersistence) isStateKey(key string) bool {
+	return key != p.definitionsKey() && key != p.indexOfKey() && key != p.indexesKey() &&
+		key != p.savedKey() && key != p.outboxKey() && !strings.HasPrefix(key, p.prefix+":index:")
+}
+
+// Save saves workflow state. The state and its index entry are written in
+// one transaction, retried if the state is saved or deleted concurrently.
+func (p *RedisPersistence) Save(ctx context.Context, state *State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	index := p.indexKey(state.Workflow, state.Status)
+	pending := state.pendingIntents()
+	now := time.Now()
+	save := func(tx *redis.Tx) error {
+		old, err := tx.HGet(ctx, p.indexOfKey(), state.ID).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, p.key(state.ID), data, stateTTL)
+			if old != "" && old != index {
+				pipe.ZRem(ctx, old, state.ID)
+			}
+			pipe.ZAdd(ctx, index, redis.Z{Score: float64(state.StartedAt.UnixMicro()), Member: state.ID})
+			pipe.ZAdd(ctx, p.savedKey(), redis.Z{Score: float64(now.UnixMicro()), Member: state.ID})
+			pipe.HSet(ctx, p.indexOfKey(), state.ID, index)
+			pipe.SAdd(ctx, p.indexesKey(), index)
+			if pending {
+				pipe.SAdd(ctx, p.outboxKey(), state.ID)
+			} else {
+				pipe.SRem(ctx, p.outboxKey(), state.ID)
+			}
+			for _, key := range []string{index, p.savedKey(), p.indexOfKey(), p.indexesKey(), p.outboxKey()} {
+				pipe.Expire(ctx, key, stateTTL)
+			}
+			return nil
+		})
+		return err
+	}
+	if err := p.watch(ctx, save, p.key(state.ID)); err != nil {
+		return err
+	}
+	return p.trim(ctx, now.Add(-stateTTL))
+}
+
+// watch runs fn in a transaction watching keys, retrying a few times when
+// another client changes them first.
+func (p *RedisPersistence) watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error {
+	var err error
+	for range 5 {
+		if err = p.client.Watch(ctx, fn, keys...); err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return err
+}
+
+// trim removes the index entries of states last saved before cutoff,
+// which have expired.
+func (p *RedisPersistence) trim(ctx context.Context, cutoff time.Time) error {
+	ids, err := p.client.ZRangeByScore(ctx, p.savedKey(), &redis.ZRangeBy{
+		Min: "-inf", Max: strconv.FormatInt(cutoff.UnixMicro(), 10), Count: trimBatch,
+	}).Result()
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	indexes, err := p.client.HMGet(ctx, p.indexOfKey(), ids...).Result()
+	if err != nil {
+		return err
+	}
+	_, err = p.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			if index, ok := indexes[i].(string); ok {
+				pipe.ZRem(ctx, index, id)
+			}
+			pipe.HDel(ctx, p.indexOfKey(), id)
+			pipe.ZRem(ctx, p.savedKey(), id)
+			pipe.SRem(ctx, p.outboxKey(), id)
+		}
+		return nil
+	})
+	return err
+}
+
+// Load loads workflow state.
+func (p *RedisPersistence) Load(ctx context.Context, id string) (*State, error) {
+	data, err := p.client.Get(ctx, p.key(id)).Bytes()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("%w: %s", ErrStateNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Delete removes workflow state.
+func (p *RedisPersistence) Delete(ctx context.Context, id string) error {
+	return p.watch(ctx, func(tx *redis.Tx) error {
+		index, err := tx.HGet(ctx, p.indexOfKey(), id).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, p.key(id))
+			if index != "" {
+				pipe.ZRem(ctx, index, id)
+			}
+			pipe.HDel(ctx, p.indexOfKey(), id)
+			pipe.ZRem(ctx, p.savedKey(), id)
+			pipe.SRem(ctx, p.outboxKey(), id)
+			return nil
+		})
+		return err
+	}, p.key(id))
+}
+
+// ListByStatus scans all stored states and returns those with status.
+func (p *RedisPersistence) ListByStatus(ctx context.Context, status Status) ([]*State, error) {
+	var states []*State
+	iter := p.client.Scan(ctx, 0, p.prefix+":*", 100).Iterator()
+	for iter.Next(ctx) {
+		if !p.isStateKey(iter.Val()) {
+			continue
+		}
+		data, err := p.client.Get(ctx, iter.Val()).Bytes()
+		if err == redis.Nil {
+			continue // expired or deleted since the scan
+		}
+		if err != nil {
+			return nil, err
+		}
+		var state State
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("workflow: decode %s: %w", iter.Val(), err)
+		}
+		if state.Status == status {
+			states = append(states, &state)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sortStates(states)
+	return states, nil
+}
+
+// List returns the indexed states matching filter. Only the requested
+// page is read from the indexes; states that expired since the last Save
+// trimmed them are left out, so a page may come back short.
+func (p *RedisPersistence) List(ctx context.Context, filter ListFilter) ([]*State, error) {
+	var indexes []string
+	if filter.Workflow != "" && filter.Status != "" {
+		indexes = []string{p.indexKey(filter.Workflow, filter.Status)}
+	} else {
+		all, err := p.client.SMembers(ctx, p.indexesKey()).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, index := range all {
+			status, workflow, _ := strings.Cut(strings.TrimPrefix(index, p.prefix+":index:"), ":")
+			if (filter.Status == "" || Status(status) == filter.Status) &&
+				(filter.Workflow == "" || workflow == filter.Workflow) {
+				indexes = append(indexes, index)
+			}
+		}
+	}
+
+	offset := max(filter.Offset, 0)
+	rng := &redis.ZRangeBy{Min: "-inf", Max: "+inf"}
+	if !filter.Since.IsZero() {
+		rng.Min = strconv.FormatInt(filter.Since.UnixMicro(), 10)
+	}
+	if !filter.Until.IsZero() {
+		rng.Max = "(" + strconv.FormatInt(filter.Until.UnixMicro(), 10)
+	}
+	// One index pages itself; several are each read up to the end of the
+	// page and merged.
+	if len(indexes) == 1 {
+		rng.Offset, rng.Count = int64(offset), -1
+		offset = 0
+	}
+	if filter.Limit > 0 {
+		rng.Count = int64(filter.Limit)
+		if len(indexes) > 1 {
+			rng.Count += int64(offset)
+		}
+	}
+	var entries []redis.Z
+	for _, index := range indexes {
+		found, err := p.client.ZRangeByScoreWithScores(ctx, index, rng).Result()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, found...)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score == entries[j].Score {
+			return entries[i].Member.(string) < entries[j].Member.(string)
+		}
+		return entries[i].Score < entries[j].Score
+	})
+	if offset >= len(entries) {
+		return nil, nil
+	}
+	entries = entries[offset:]
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.Member.(string)
+	}
+	states, err := p.getAll(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	matched := states[:0]
+	for _, state := range states {
+		if filter.match(state) {
+			matched = append(matched, state)
+		}
+	}
+	return matched, nil
+}
+
+// PendingOutbox returns the states with unsent outbox intents.
+func (p *RedisPersistence) PendingOutbox(ctx context.Context) ([]*State, error) {
+	ids, err := p.client.SMembers(ctx, p.outboxKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	return p.getAll(ctx, ids)
+}
+
+// getAll reads the states with ids in one round trip, in order. States
+// that expired, trimmed by a later Save, or that this version cannot
+// decode are left out.
+func (p *RedisPersistence) getAll(ctx context.Context, ids []string) ([]*State, error) {
+	gets := make([]*redis.StringCmd, len(ids))
+	_, err := p.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			gets[i] = pipe.Get(ctx, p.key(id))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	var states []*State
+	for _, get := range gets {
+		data, err := get.Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var state State
+		if json.Unmarshal(data, &state) != nil {
+			continue
+		}
+		states = append(states, &state)
+	}
+	return states, nil
+}
+
+// SaveDefinition saves a workflow definition.
+func (p *RedisPersistence) SaveDefinition(ctx context.Context, def *Definition) error {
+	data, err := json.Marshal(def)
+	if err != nil {
+		return err
+	}
+	return p.client.HSet(ctx, p.definitionsKey(), def.Name, data).Err()
+}
+
+// LoadDefinition loads a workflow definition.
+func (p *RedisPersistence) LoadDefinition(ctx context.Context, name string) (*Definition, error) {
+	data, err := p.client.HGet(ctx, p.definitionsKey(), name).Bytes()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("%w: %s", ErrDefinitionNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var def Definition
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// ListDefinitions lists all workflow definitions.
+func (p *RedisPersistence) ListDefinitions(ctx context.Context) ([]*Definition, error) {
+	all, err := p.client.HGetAll(ctx, p.definitionsKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	defs := make([]*Definition, 0, len(all))
+	for name, data := range all {
+		var def Definition
+		if err := json.Unmarshal([]byte(data), &def); err != nil {
+			return nil, fmt.Errorf("workflow: decode definition %s: %w", name, err)
+		}
+		defs = append(defs, &def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs, nil
+}
+
+// ============ Memory Persistence ============
+
+// MemoryPersistence stores workflow state in memory.
+// Useful for testing and development without a database.
+type MemoryPersistence struct {
+	states      map[string][]byte
+	definitions map[string][]byte
+	outbox      map[string]bool // ids with unsent intents
+	mu          sync.RWMutex
+}
+
+// NewMemoryPersistence creates in-memory persistence.
+func NewMemoryPersistence() *MemoryPersistence {
+	return &MemoryPersistence{
+		states:      make(map[string][]byte),
+		definitions: make(map[string][]byte),
+		outbox:      make(map[string]bool),
+	}
+}
+
+// Save saves workflow state.
+func (p *MemoryPersistence) Save(ctx context.Context, state *State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.states[state.ID] = data
+	if state.pendingIntents() {
+		p.outbox[state.ID] = true
+	} else {
+		delete(p.outbox, state.ID)
+	}
+	return nil
+}
+
+// Load loads workflow state.
+func (p *MemoryPersistence) Load(ctx context.Context, id string) (*State, error) {
+	p.mu.RLock()
+	data, ok := p.states[id]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrStateNotFound, id)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Delete removes workflow state.
+func (p *MemoryPersistence) Delete(ctx context.Context, id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.states, id)
+	delete(p.outbox, id)
+	return nil
+}
+
+// ListByStatus returns the states with status.
+func (p *MemoryPersistence) ListByStatus(ctx context.Context, status Status) ([]*State, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var states []*State
+	for _, data := range p.states {
+		var state State
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, err
+		}
+		if state.Status == status {
+			states = append(states, &state)
+		}
+	}
+	sortStates(states)
+	return states, nil
+}
+
+// List returns the states matching filter.
+func (p *MemoryPersistence) List(ctx context.Context, filter ListFilter) ([]*State, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var states []*State
+	for _, data := range p.states {
+		var state State
+		if err := json.Unmarshal(data, &state); err != nil {
+			continue
+		}
+		if filter.match(&state) {
+			states = append(states, &state)
+		}
+	}
+	sortStates(states)
+	return filter.page(states), nil
+}
+
+// PendingOutbox returns the states with unsent outbox intents.
+func (p *MemoryPersistence) PendingOutbox(ctx context.Context) ([]*State, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var states []*State
+	for id := range p.outbox {
+		var state State
+		if err := json.Unmarshal(p.states[id], &state); err != nil {
+			return nil, err
+		}
+		states = append(states, &state)
+	}
+	sortStates(states)
+	return states, nil
+}
+
+// SaveDefinition saves a workflow definition.
+func (p *MemoryPersistence) SaveDefinition(ctx context.Context, def *Definition) error {
+	data, err := json.Marshal(def)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.definitions[def.Name] = data
+	return nil
+}
+
+// LoadDefinition loads a workflow definition.
+func (p *MemoryPersistence) LoadDefinition(ctx context.Context, name string) (*Definition, error) {
+	p.mu.RLock()
+	data, ok := p.definitions[name]
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDefinitionNotFound, name)
+	}
+	var def Definition
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// ListDefinitions lists all workflow definitions.
+func (p *MemoryPersistence) ListDefinitions(ctx context.Context) ([]*Definition, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	defs := make([]*Definition, 0, len(p.definitions))
+	for _, data := range p.definitions {
+		var def Definition
+		if err := json.Unmarshal(data, &def); err != nil {
+			return nil, err
+		}
+		defs = append(defs, &def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs, nil
+}
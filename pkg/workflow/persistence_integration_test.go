@@ -0,0 +1,152 @@
+//go:build integration
+
+// Package workflow_test runs the persistence and cron store conformance
+// suites against real backends. Set GOFLOW_TEST_POSTGRES_DSN and
+// GOFLOW_TEST_REDIS_ADDR and run with -tags integration.
+package workflow_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+	"github.com/nuulab/goflow/pkg/workflow/workflowtest"
+)
+
+// ephemeralPool returns a pool bound to a fresh schema that is dropped
+// when the test ends.
+func ephemeralPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("GOFLOW_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("GOFLOW_TEST_POSTGRES_DSN not set")
+	}
+	ctx := context.Background()
+	admin, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("goflow_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Close()
+		admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		admin.Close()
+	})
+	return pool
+}
+
+func TestPostgresPersistence(t *testing.T) {
+	workflowtest.RunPersistence(t, func(t *testing.T) workflow.Persistence {
+		p, err := workflow.NewPostgresPersistence(context.Background(), ephemeralPool(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	})
+}
+
+func TestRedisPersistence(t *testing.T) {
+	addr := os.Getenv("GOFLOW_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("GOFLOW_TEST_REDIS_ADDR not set")
+	}
+	workflowtest.RunPersistence(t, func(t *testing.T) workflow.Persistence {
+		client := redis.NewClient(&redis.Options{Addr: addr})
+		prefix := fmt.Sprintf("goflow-test:%d", time.Now().UnixNano())
+		t.Cleanup(func() {
+			ctx := context.Background()
+			keys, _ := client.Keys(ctx, prefix+":*").Result()
+			if len(keys) > 0 {
+				client.Del(ctx, keys...)
+			}
+			client.Close()
+		})
+		return workflow.NewRedisPersistence(client, prefix)
+	})
+}
+
+func TestRedisPersistence_ListSkipsUnindexed(t *testing.T) {
+	addr := os.Getenv("GOFLOW_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("GOFLOW_TEST_REDIS_ADDR not set")
+	}
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	prefix := fmt.Sprintf("goflow-test:%d", time.Now().UnixNano())
+	defer func() {
+		keys, _ := client.Keys(ctx, prefix+":*").Result()
+		if len(keys) > 0 {
+			client.Del(ctx, keys...)
+		}
+		client.Close()
+	}()
+	p := workflow.NewRedisPersistence(client, prefix)
+
+	// A state stored before indexing, and an indexed state that expired.
+	client.Set(ctx, prefix+":legacy", `{"id":"legacy","workflow":"orders","status":"completed"}`, time.Hour)
+	p.Save(ctx, &workflow.State{ID: "gone", Workflow: "orders", Status: workflow.StatusCompleted})
+	client.Del(ctx, prefix+":gone")
+	p.Save(ctx, &workflow.State{ID: "kept", Workflow: "orders", Status: workflow.StatusCompleted})
+
+	states, err := p.List(ctx, workflow.ListFilter{Workflow: "orders"})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(states) != 1 || states[0].ID != "kept" {
+		t.Errorf("Expected only the indexed state, got %d states", len(states))
+	}
+	if completed, err := p.ListByStatus(ctx, workflow.StatusCompleted); err != nil || len(completed) != 2 {
+		t.Errorf("Expected ListByStatus to still find both stored states, got %d (%v)", len(completed), err)
+	}
+
+	// The next Save trims the entry of a state last saved over 7 days ago.
+	index := prefix + ":index:completed:orders"
+	client.ZAdd(ctx, prefix+":saved", redis.Z{Score: float64(time.Now().Add(-8 * 24 * time.Hour).UnixMicro()), Member: "gone"})
+	p.Save(ctx, &workflow.State{ID: "kept", Workflow: "orders", Status: workflow.StatusCompleted})
+	if ids, _ := client.ZRange(ctx, index, 0, -1).Result(); len(ids) != 1 || ids[0] != "kept" {
+		t.Errorf("Expected the expired entry trimmed, got %v", ids)
+	}
+	if ttl := client.TTL(ctx, index).Val(); ttl <= 0 {
+		t.Errorf("Expected the index to expire, got TTL %v", ttl)
+	}
+}
+
+func TestRedisCronStore(t *testing.T) {
+	addr := os.Getenv("GOFLOW_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("GOFLOW_TEST_REDIS_ADDR not set")
+	}
+	workflowtest.RunCronStore(t, func(t *testing.T) workflow.CronStore {
+		client := redis.NewClient(&redis.Options{Addr: addr})
+		prefix := fmt.Sprintf("goflow-test:cron:%d", time.Now().UnixNano())
+		t.Cleanup(func() {
+			ctx := context.Background()
+			keys, _ := client.Keys(ctx, prefix+":*").Result()
+			if len(keys) > 0 {
+				client.Del(ctx, keys...)
+			}
+			client.Close()
+		})
+		return workflow.NewRedisCronStore(client, prefix)
+	})
+}
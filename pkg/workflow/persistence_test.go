@@ -0,0 +1,84 @@
+// Package workflow_test provides tests for workflow persistence.
+package workflow_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+	"github.com/nuulab/goflow/pkg/workflow/workflowtest"
+)
+
+func TestMemoryPersistence(t *testing.T) {
+	workflowtest.RunPersistence(t, func(t *testing.T) workflow.Persistence {
+		return workflow.NewMemoryPersistence()
+	})
+}
+
+func TestEngine_PersistsThroughInterface(t *testing.T) {
+	store := workflow.NewMemoryPersistence()
+	engine := workflow.NewEngine(store)
+
+	wf := workflow.New("persisted").
+		Step("greet", func(ctx context.Context, state *workflow.State) (any, error) {
+			return "hello", nil
+		}).Then().Build()
+
+	state, err := engine.Execute(context.Background(), wf, map[string]any{"user": "ada"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	completed, err := store.ListByStatus(context.Background(), workflow.StatusCompleted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completed) != 1 || completed[0].ID != state.ID {
+		t.Fatalf("expected the run to be persisted as completed, got %+v", completed)
+	}
+	if completed[0].Workflow != "persisted" || completed[0].StepResults["greet"] != "hello" {
+		t.Errorf("persisted state = %+v", completed[0])
+	}
+
+	loaded, err := engine.LoadState(context.Background(), state.ID)
+	if err != nil || loaded.ID != state.ID {
+		t.Errorf("LoadState = %v, %v", loaded, err)
+	}
+}
+
+func TestEngine_List(t *testing.T) {
+	ctx := context.Background()
+	wf := workflow.New("held").AwaitSignal("go", "go").Then().Build()
+
+	engine := workflow.NewEngine(nil)
+	engine.Register(wf)
+	id, err := engine.Start(ctx, "held", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.SendSignal(ctx, "go", nil)
+	waitFor(t, "the run to await", func() bool { return engine.AwaitingSummary().Total == 1 })
+
+	if running := engine.ListRunning(); len(running) != 1 || running[0].ID != id {
+		t.Errorf("ListRunning = %v", running)
+	}
+	// Without persistence, listings cover the running executions.
+	states, err := engine.ListByStatus(ctx, workflow.StatusAwaitingSignal)
+	if err != nil || len(states) != 1 || states[0].Summary().ID != id {
+		t.Errorf("ListByStatus = %v, %v", states, err)
+	}
+	if states, _ := engine.List(ctx, workflow.ListFilter{Workflow: "other"}); len(states) != 0 {
+		t.Errorf("Expected no runs of another workflow, got %d", len(states))
+	}
+
+	stored := workflow.NewEngine(workflow.NewMemoryPersistence())
+	quick := workflow.New("quick").Checkpoint("done").Build()
+	state, err := stored.Execute(ctx, quick, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	completed, err := stored.ListByStatus(ctx, workflow.StatusCompleted)
+	if err != nil || len(completed) != 1 || completed[0].ID != state.ID {
+		t.Errorf("ListByStatus from persistence = %v, %v", completed, err)
+	}
+}
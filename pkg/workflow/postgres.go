@@ -0,0 +1,231 @@
+package workflow
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ============ Postgres Persistence ============
+
+//go:embed postgres_schema.sql
+var postgresSchema string
+
+// PostgresPersistence stores workflow state in Postgres. Each state is a
+// JSONB document with its workflow name, status and start time in indexed
+// columns for querying.
+type PostgresPersistence struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresPersistence creates Postgres persistence and migrates the
+// schema. The pool is owned by the caller.
+func NewPostgresPersistence(ctx context.Context, pool *pgxpool.Pool) (*PostgresPersistence, error) {
+	p := &PostgresPersistence{pool: pool}
+	if err := p.Migrate(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Migrate creates the tables and indexes if they do not exist.
+func (p *PostgresPersistence) Migrate(ctx context.Context) error {
+	if _, err := p.pool.Exec(ctx, postgresSchema); err != nil {
+		return fmt.Errorf("workflow: migrate postgres schema: %w", err)
+	}
+	return nil
+}
+
+// Save saves workflow state.
+func (p *PostgresPersistence) Save(ctx context.Context, state *State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	_, err = p.pool.Exec(ctx, `
+		INSERT INTO goflow_workflow_states (id, workflow, workflow_id, status, started_at, updated_at, pending_outbox, state)
+		VALUES ($1, $2, $3, $4, $5, now(), $6, $7)
+		ON CONFLICT (id) DO UPDATE SET
+			workflow = EXCLUDED.workflow,
+			workflow_id = EXCLUDED.workflow_id,
+			status = EXCLUDED.status,
+			started_at = EXCLUDED.started_at,
+			updated_at = now(),
+			pending_outbox = EXCLUDED.pending_outbox,
+			state = EXCLUDED.state`,
+		state.ID, state.Workflow, state.WorkflowID, string(state.Status), state.StartedAt, state.pendingIntents(), data)
+	return err
+}
+
+// Load loads workflow state.
+func (p *PostgresPersistence) Load(ctx context.Context, id string) (*State, error) {
+	var data []byte
+	err := p.pool.QueryRow(ctx, `SELECT state FROM goflow_workflow_states WHERE id = $1`, id).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrStateNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Delete removes workflow state.
+func (p *PostgresPersistence) Delete(ctx context.Context, id string) error {
+	_, err := p.pool.Exec(ctx, `DELETE FROM goflow_workflow_states WHERE id = $1`, id)
+	return err
+}
+
+// ListByStatus returns the states with status, oldest first.
+func (p *PostgresPersistence) ListByStatus(ctx context.Context, status Status) ([]*State, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT state FROM goflow_workflow_states
+		WHERE status = $1
+		ORDER BY started_at, id`, string(status))
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*State, error) {
+		var data []byte
+		if err := row.Scan(&data); err != nil {
+			return nil, err
+		}
+		var state State
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, err
+		}
+		return &state, nil
+	})
+}
+
+// PendingOutbox returns the states with unsent outbox intents.
+func (p *PostgresPersistence) PendingOutbox(ctx context.Context) ([]*State, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT state FROM goflow_workflow_states
+		WHERE pending_outbox
+		ORDER BY started_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*State, error) {
+		var data []byte
+		if err := row.Scan(&data); err != nil {
+			return nil, err
+		}
+		var state State
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, err
+		}
+		return &state, nil
+	})
+}
+
+// List returns the states matching filter, oldest first.
+func (p *PostgresPersistence) List(ctx context.Context, filter ListFilter) ([]*State, error) {
+	var where []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.Workflow != "" {
+		add("workflow = $%d", filter.Workflow)
+	}
+	if filter.Status != "" {
+		add("status = $%d", string(filter.Status))
+	}
+	if !filter.Since.IsZero() {
+		add("started_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		add("started_at < $%d", filter.Until)
+	}
+
+	query := "SELECT state FROM goflow_workflow_states"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY started_at, id"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query += fmt.Sprintf(" OFFSET %d", filter.Offset)
+	}
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*State, error) {
+		var data []byte
+		if err := row.Scan(&data); err != nil {
+			return nil, err
+		}
+		var state State
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, err
+		}
+		return &state, nil
+	})
+}
+
+// SaveDefinition saves a workflow definition.
+func (p *PostgresPersistence) SaveDefinition(ctx context.Context, def *Definition) error {
+	data, err := json.Marshal(def)
+	if err != nil {
+		return err
+	}
+	_, err = p.pool.Exec(ctx, `
+		INSERT INTO goflow_workflow_definitions (name, definition, updated_at)
+		VALUES ($1, $2, now())
+		ON CONFLICT (name) DO UPDATE SET definition = EXCLUDED.definition, updated_at = now()`,
+		def.Name, data)
+	return err
+}
+
+// LoadDefinition loads a workflow definition.
+func (p *PostgresPersistence) LoadDefinition(ctx context.Context, name string) (*Definition, error) {
+	var data []byte
+	err := p.pool.QueryRow(ctx, `SELECT definition FROM goflow_workflow_definitions WHERE name = $1`, name).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrDefinitionNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var def Definition
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// ListDefinitions lists all workflow definitions.
+func (p *PostgresPersistence) ListDefinitions(ctx context.Context) ([]*Definition, error) {
+	rows, err := p.pool.Query(ctx, `SELECT definition FROM goflow_workflow_definitions ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Definition, error) {
+		var data []byte
+		if err := row.Scan(&data); err != nil {
+			return nil, err
+		}
+		var def Definition
+		if err := json.Unmarshal(data, &def); err != nil {
+			return nil, err
+		}
+		return &def, nil
+	})
+}
@@ -0,0 +1,24 @@
+-- Schema for PostgresPersistence. Statements are idempotent and run on startup.
+
+CREATE TABLE IF NOT EXISTS goflow_workflow_states (
+    id          TEXT PRIMARY KEY,
+    workflow    TEXT NOT NULL DEFAULT '',
+    workflow_id TEXT NOT NULL DEFAULT '',
+    status      TEXT NOT NULL,
+    started_at  TIMESTAMPTZ NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    state       JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS goflow_workflow_states_workflow_idx ON goflow_workflow_states (workflow);
+CREATE INDEX IF NOT EXISTS goflow_workflow_states_status_idx ON goflow_workflow_states (status, started_at);
+CREATE INDEX IF NOT EXISTS goflow_workflow_states_started_at_idx ON goflow_workflow_states (started_at);
+
+ALTER TABLE goflow_workflow_states ADD COLUMN IF NOT EXISTS pending_outbox BOOLEAN NOT NULL DEFAULT false;
+CREATE INDEX IF NOT EXISTS goflow_workflow_states_outbox_idx ON goflow_workflow_states (id) WHERE pending_outbox;
+
+CREATE TABLE IF NOT EXISTS goflow_workflow_definitions (
+    name       TEXT PRIMARY KEY,
+    definition JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,56 @@
+// Package workflow provides preview runs that execute only the first steps.
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// HistoryPreview is the history entry type recorded when a preview stops
+// before the last step.
+const HistoryPreview = "preview"
+
+// ExecutePrefix runs only the first n steps of workflow synchronously for
+// quick validation. The state is marked as a preview in persistence and
+// history, and is not reported to the digest. A preview that stops early
+// completes with WouldContinue set.
+func (e *Engine) ExecutePrefix(ctx context.Context, workflow *Workflow, input map[string]any, n int) (*State, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("workflow: preview step count must be positive, got %d", n)
+	}
+	if err := workflow.Validate(); err != nil {
+		return nil, err
+	}
+
+	state := &State{
+		ID:           fmt.Sprintf("%s-preview-%d", workflow.Name, time.Now().UnixNano()),
+		Workflow:     workflow.Name,
+		WorkflowID:   workflow.ID,
+		Status:       StatusRunning,
+		Data:         input,
+		StepResults:  make(map[string]any),
+		Checkpoints:  make(map[string]int),
+		StartedAt:    time.Now(),
+		Preview:      true,
+		PreviewSteps: n,
+	}
+	if input == nil {
+		state.Data = make(map[string]any)
+	}
+
+	return e.ExecuteWithState(ctx, workflow, state)
+}
+
+func (e *Engine) stopPreview(state *State, ran, total int) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.CurrentStep = ran
+	state.WouldContinue = true
+	state.History = append(state.History, HistoryEntry{
+		Type:      HistoryPreview,
+		Message:   fmt.Sprintf("preview stopped after %d of %d steps", ran, total),
+		Timestamp: e.now(),
+	})
+}
@@ -0,0 +1,101 @@
+// Package workflow_test provides tests for preview runs.
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/notify"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func countingWorkflow(ran *[]string) *workflow.Workflow {
+	step := func(name string) func(ctx context.Context, state *workflow.State) (any, error) {
+		return func(ctx context.Context, state *workflow.State) (any, error) {
+			*ran = append(*ran, name)
+			return name + "-done", nil
+		}
+	}
+	return workflow.New("pipeline").
+		Step("fetch", step("fetch")).Then().
+		Step("transform", step("transform")).Then().
+		Step("publish", step("publish")).Then().
+		Build()
+}
+
+func TestExecutePrefix(t *testing.T) {
+	var ran []string
+	persistence := workflow.NewMemoryPersistence()
+	engine := workflow.NewEngine(persistence)
+
+	state, err := engine.ExecutePrefix(context.Background(), countingWorkflow(&ran), map[string]any{"id": 1}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 2 || ran[1] != "transform" {
+		t.Fatalf("Expected only the first two steps to run, got %v", ran)
+	}
+	if state.Status != workflow.StatusCompleted || !state.WouldContinue {
+		t.Errorf("Expected completed preview that would continue, got %s/%v", state.Status, state.WouldContinue)
+	}
+	if state.StepResults["transform"] != "transform-done" || state.StepResults["publish"] != nil {
+		t.Errorf("Unexpected step results: %v", state.StepResults)
+	}
+
+	last := state.History[len(state.History)-1]
+	if last.Type != workflow.HistoryPreview || last.Message != "preview stopped after 2 of 3 steps" {
+		t.Errorf("Expected preview history entry, got %+v", last)
+	}
+
+	saved, err := persistence.Load(context.Background(), state.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved.Preview || saved.PreviewSteps != 2 || !saved.WouldContinue {
+		t.Errorf("Expected preview marker in persistence, got %+v", saved)
+	}
+}
+
+func TestExecutePrefix_WholeWorkflow(t *testing.T) {
+	var ran []string
+	engine := workflow.NewEngine(nil)
+
+	state, err := engine.ExecutePrefix(context.Background(), countingWorkflow(&ran), nil, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 3 || state.WouldContinue || !state.Preview {
+		t.Errorf("Expected full preview run, got steps %v, would continue %v", ran, state.WouldContinue)
+	}
+
+	if _, err := engine.ExecutePrefix(context.Background(), countingWorkflow(&ran), nil, 0); err == nil {
+		t.Error("Expected error for zero preview steps")
+	}
+}
+
+func TestExecutePrefix_SkipsDigest(t *testing.T) {
+	notifier := &recordingNotifier{}
+	engine := workflow.NewEngine(nil)
+	engine.SetDigest(notify.NewDigest(notifier))
+
+	wf := workflow.New("failing").
+		Step("boom", func(ctx context.Context, state *workflow.State) (any, error) {
+			return nil, errors.New("boom")
+		}).Then().
+		Build()
+
+	if _, err := engine.ExecutePrefix(context.Background(), wf, nil, 1); err == nil {
+		t.Fatal("Expected preview failure")
+	}
+	if notifier.count() != 0 {
+		t.Errorf("Preview failures must not reach the digest, got %d notifications", notifier.count())
+	}
+
+	if _, err := engine.Execute(context.Background(), wf, nil); err == nil {
+		t.Fatal("Expected failure")
+	}
+	if notifier.count() != 1 {
+		t.Errorf("Expected regular failure to notify, got %d", notifier.count())
+	}
+}
@@ -0,0 +1,82 @@
+// Package workflow_test provides tests for retry policies.
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func failing(err error) func() (any, error) {
+	return func() (any, error) { return nil, err }
+}
+
+func TestRetryPolicy_Jitter(t *testing.T) {
+	var delays []time.Duration
+	policy := workflow.NewRetryPolicy().Attempts(20).Exponential(time.Millisecond, time.Millisecond).
+		WithJitter(0.5).
+		OnRetry(func(attempt int, err error, next time.Duration) {
+			delays = append(delays, next)
+		})
+
+	boom := errors.New("boom")
+	if _, err := policy.Execute(context.Background(), failing(boom)); !errors.Is(err, boom) {
+		t.Fatalf("Expected the last error, got %v", err)
+	}
+	if len(delays) != 19 {
+		t.Fatalf("Expected 19 retries, got %d", len(delays))
+	}
+	distinct := make(map[time.Duration]bool)
+	for _, d := range delays {
+		if d < 500*time.Microsecond || d > 1500*time.Microsecond {
+			t.Errorf("Delay %v outside 50%% of 1ms", d)
+		}
+		distinct[d] = true
+	}
+	if len(distinct) < 2 {
+		t.Error("Expected jittered delays to differ")
+	}
+
+	delays = nil
+	policy.WithFullJitter()
+	policy.Execute(context.Background(), failing(boom))
+	for _, d := range delays {
+		if d < 0 || d > time.Millisecond {
+			t.Errorf("Full jitter delay %v outside [0, 1ms]", d)
+		}
+	}
+}
+
+func TestRetryPolicy_Budget(t *testing.T) {
+	var attempts []int
+	policy := workflow.NewRetryPolicy().Attempts(10).Exponential(10*time.Millisecond, time.Second).
+		WithBudget(35 * time.Millisecond).
+		OnRetry(func(attempt int, err error, next time.Duration) {
+			attempts = append(attempts, attempt)
+		})
+
+	boom := errors.New("boom")
+	_, err := policy.Execute(context.Background(), failing(boom))
+	if !errors.Is(err, workflow.ErrRetryBudgetExceeded) || !errors.Is(err, boom) {
+		t.Fatalf("Expected a budget error wrapping the last error, got %v", err)
+	}
+	// 10ms + 20ms fit the budget; the 40ms third backoff does not.
+	if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+		t.Errorf("Expected retries after attempts 1 and 2, got %v", attempts)
+	}
+}
+
+func TestRetryPolicy_CancelDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := workflow.NewRetryPolicy().Attempts(2).Exponential(time.Hour, time.Hour).
+		OnRetry(func(attempt int, err error, next time.Duration) { cancel() })
+
+	boom := errors.New("boom")
+	_, err := policy.Execute(ctx, failing(boom))
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, boom) {
+		t.Errorf("Expected ctx.Err() wrapped with the last error, got %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HistoryStep is the history entry type recorded after each top-level step.
+// Entries carry either a full snapshot of Data and StepResults or a diff
+// against the previous entry.
+const HistoryStep = "step"
+
+// SnapshotPolicy controls the state snapshots recorded in step history.
+type SnapshotPolicy struct {
+	// Every records a full snapshot every N step entries; the entries in
+	// between store diffs.
+	Every int
+	// MaxValueSize truncates added or changed values whose JSON encoding
+	// exceeds this many bytes. Zero disables truncation.
+	MaxValueSize int
+}
+
+// DefaultSnapshotPolicy is used by engines without an explicit policy.
+var DefaultSnapshotPolicy = SnapshotPolicy{Every: 10, MaxValueSize: 64 << 10}
+
+// truncatedPreviewSize is the length of the preview kept for truncated values.
+const truncatedPreviewSize = 256
+
+// Diff operations.
+const (
+	DiffAdd    = "add"
+	DiffChange = "change"
+	DiffRemove = "remove"
+)
+
+// DiffOp is one change between two state snapshots. Path is a JSON pointer
+// rooted at {"data": ..., "results": ...}, e.g. /data/order/status.
+type DiffOp struct {
+	Op        string `json:"op"`
+	Path      string `json:"path"`
+	Value     any    `json:"value"`
+	Truncated bool   `json:"truncated,omitempty"` // Value is a preview
+}
+
+// StateSnapshot is the reconstructed state after a step.
+type StateSnapshot struct {
+	Step        string         `json:"step"`
+	Seq         int            `json:"seq"`
+	Data        map[string]any `json:"data"`
+	StepResults map[string]any `json:"step_results"`
+	// Truncated lists paths whose values were truncated in the history and
+	// are only previews.
+	Truncated []string `json:"truncated,omitempty"`
+}
+
+// SetSnapshotPolicy sets how step history snapshots are recorded.
+func (e *Engine) SetSnapshotPolicy(p SnapshotPolicy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.snapshots = p
+}
+
+// recordStep appends a step history entry with a snapshot or diff of the state.
+func (e *Engine) recordStep(state *State, step Step) {
+	e.mu.RLock()
+	policy := e.snapshots
+	e.mu.RUnlock()
+	if policy.Every <= 0 {
+		policy = DefaultSnapshotPolicy
+	}
+	now := e.now()
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	snap, err := jsonSnapshot(map[string]any{"data": state.Data, "results": state.StepResults})
+	if err != nil {
+		return // state is not serializable; it cannot be persisted either
+	}
+
+	seq := 1
+	for _, h := range state.History {
+		if h.Type == HistoryStep {
+			seq++
+		}
+	}
+	entry := HistoryEntry{Type: HistoryStep, Step: step.Name(), Seq: seq, Timestamp: now}
+	if state.lastSnapshot == nil || (seq-1)%policy.Every == 0 {
+		entry.Snapshot = snap
+	} else {
+		diffValues("", state.lastSnapshot, snap, policy.MaxValueSize, &entry.Diff)
+	}
+	state.lastSnapshot = snap
+	state.History = append(state.History, entry)
+}
+
+// StateAt reconstructs Data and StepResults as they were after step, given
+// by name (latest execution) or by sequence number.
+func (s *State) StateAt(step string) (*StateSnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []HistoryEntry
+	for _, h := range s.History {
+		if h.Type == HistoryStep {
+			entries = append(entries, h)
+		}
+	}
+
+	target := -1
+	for i, h := range entries {
+		if h.Step == step {
+			target = i
+		}
+	}
+	if target < 0 {
+		if seq, err := strconv.Atoi(step); err == nil {
+			for i, h := range entries {
+				if h.Seq == seq {
+					target = i
+				}
+			}
+		}
+	}
+	if target < 0 {
+		return nil, fmt.Errorf("step '%s' not found in history", step)
+	}
+
+	base := target
+	for base >= 0 && entries[base].Snapshot == nil {
+		base--
+	}
+	if base < 0 {
+		return nil, fmt.Errorf("no snapshot recorded before step '%s'", step)
+	}
+
+	cur := deepCopy(entries[base].Snapshot).(map[string]any)
+	truncated := make(map[string]bool)
+	for _, h := range entries[base+1 : target+1] {
+		for _, op := range h.Diff {
+			if err := applyOp(cur, op); err != nil {
+				return nil, fmt.Errorf("step '%s': %w", h.Step, err)
+			}
+			if op.Truncated {
+				truncated[op.Path] = true
+			} else {
+				delete(truncated, op.Path)
+			}
+		}
+	}
+
+	out := &StateSnapshot{
+		Step:        entries[target].Step,
+		Seq:         entries[target].Seq,
+		Data:        asMap(cur["data"]),
+		StepResults: asMap(cur["results"]),
+	}
+	for path := range truncated {
+		out.Truncated = append(out.Truncated, path)
+	}
+	sort.Strings(out.Truncated)
+	return out, nil
+}
+
+// LoadState returns a running execution's state or loads it from persistence.
+func (e *Engine) LoadState(ctx context.Context, stateID string) (*State, error) {
+	if state, ok := e.GetState(stateID); ok {
+		return state, nil
+	}
+	if e.persistence == nil {
+		return nil, fmt.Errorf("%w: %s", ErrStateNotFound, stateID)
+	}
+	return e.persistence.Load(ctx, stateID)
+}
+
+// ============ Diffing ============
+
+// jsonSnapshot converts v to its generic JSON form so snapshots compare by value.
+func jsonSnapshot(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]any
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
+// diffValues appends the operations turning old into new. Objects are
+// compared key by key; any other changed value is replaced whole.
+func diffValues(path string, old, new any, maxSize int, ops *[]DiffOp) {
+	oldMap, oldOK := old.(map[string]any)
+	newMap, newOK := new.(map[string]any)
+	if oldOK && newOK {
+		keys := make([]string, 0, len(oldMap)+len(newMap))
+		for k := range oldMap {
+			keys = append(keys, k)
+		}
+		for k := range newMap {
+			if _, ok := oldMap[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			child := path + "/" + escapePointer(k)
+			ov, inOld := oldMap[k]
+			nv, inNew := newMap[k]
+			switch {
+			case !inNew:
+				*ops = append(*ops, DiffOp{Op: DiffRemove, Path: child})
+			case !inOld:
+				*ops = append(*ops, valueOp(DiffAdd, child, nv, maxSize))
+			default:
+				diffValues(child, ov, nv, maxSize, ops)
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(old, new) {
+		*ops = append(*ops, valueOp(DiffChange, path, new, maxSize))
+	}
+}
+
+func valueOp(op, path string, value any, maxSize int) DiffOp {
+	if maxSize > 0 {
+		if data, err := json.Marshal(value); err == nil && len(data) > maxSize {
+			preview := data[:min(len(data), truncatedPreviewSize)]
+			return DiffOp{Op: op, Path: path, Value: string(preview) + "...", Truncated: true}
+		}
+	}
+	return DiffOp{Op: op, Path: path, Value: value}
+}
+
+func applyOp(root map[string]any, op DiffOp) error {
+	tokens := strings.Split(strings.TrimPrefix(op.Path, "/"), "/")
+	parent := root
+	for _, tok := range tokens[:len(tokens)-1] {
+		key := unescapePointer(tok)
+		child, ok := parent[key].(map[string]any)
+		if !ok {
+			if op.Op == DiffRemove {
+				return nil
+			}
+			child = make(map[string]any)
+			parent[key] = child
+		}
+		parent = child
+	}
+
+	key := unescapePointer(tokens[len(tokens)-1])
+	switch op.Op {
+	case DiffAdd, DiffChange:
+		parent[key] = deepCopy(op.Value)
+	case DiffRemove:
+		delete(parent, key)
+	default:
+		return fmt.Errorf("unknown diff op %q", op.Op)
+	}
+	return nil
+}
+
+func deepCopy(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			out[k] = deepCopy(val)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, val := range t {
+			out[i] = deepCopy(val)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func asMap(v any) map[string]any {
+	if m, ok := v.(map[string]any); ok {
+		return m
+	}
+	return map[string]any{}
+}
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+func escapePointer(s string) string   { return pointerEscaper.Replace(s) }
+func unescapePointer(s string) string { return pointerUnescaper.Replace(s) }
@@ -0,0 +1,154 @@
+// Package workflow_test provides tests for differential step history.
+package workflow_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func stateJSON(t *testing.T, data, results map[string]any) string {
+	t.Helper()
+	out, err := json.Marshal(map[string]any{"data": data, "results": results})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// mutatingWorkflow builds a run with a large document that changes a little
+// at every step. expected receives the state after each step.
+func mutatingWorkflow(t *testing.T, steps int, expected map[string]string) *workflow.Workflow {
+	b := workflow.New("mutating")
+	for i := 0; i < steps; i++ {
+		i := i
+		b.Step(fmt.Sprintf("step-%d", i), func(ctx context.Context, state *workflow.State) (any, error) {
+			if i > 0 {
+				expected[fmt.Sprintf("step-%d", i-1)] = stateJSON(t, state.Data, state.StepResults)
+			}
+			switch {
+			case i == 0:
+				doc := make(map[string]any)
+				for f := 0; f < 500; f++ {
+					doc[fmt.Sprintf("field_%03d", f)] = strings.Repeat("x", 100)
+				}
+				state.Data["doc"] = doc
+			case i == 12:
+				delete(state.Data, "tags")
+			default:
+				state.Data["doc"].(map[string]any)[fmt.Sprintf("field_%03d", i)] = fmt.Sprintf("updated at %d", i)
+				state.Data["tags"] = []any{"step", i}
+			}
+			state.Data["counter"] = i
+			return map[string]any{"step": i, "ok": true}, nil
+		})
+	}
+	return b.Build()
+}
+
+func runMutating(t *testing.T, policy workflow.SnapshotPolicy) (*workflow.State, map[string]string) {
+	t.Helper()
+	const steps = 25
+	expected := make(map[string]string)
+	engine := workflow.NewEngine(nil)
+	engine.SetSnapshotPolicy(policy)
+
+	state, err := engine.Execute(context.Background(), mutatingWorkflow(t, steps, expected), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected[fmt.Sprintf("step-%d", steps-1)] = stateJSON(t, state.Data, state.StepResults)
+	return state, expected
+}
+
+func TestStateAt_Reconstruction(t *testing.T) {
+	state, expected := runMutating(t, workflow.SnapshotPolicy{Every: 10})
+
+	// Reconstruction must also work after a persistence round trip.
+	blob, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &workflow.State{}
+	if err := json.Unmarshal(blob, restored); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []*workflow.State{state, restored} {
+		for step, want := range expected {
+			snap, err := s.StateAt(step)
+			if err != nil {
+				t.Fatalf("StateAt(%s): %v", step, err)
+			}
+			if got := stateJSON(t, snap.Data, snap.StepResults); got != want {
+				t.Fatalf("StateAt(%s) differs from the recorded state", step)
+			}
+		}
+	}
+
+	snap, err := state.StateAt("13")
+	if err != nil || snap.Step != "step-12" {
+		t.Errorf("Expected lookup by sequence number, got %+v, %v", snap, err)
+	}
+	if _, err := state.StateAt("missing"); err == nil {
+		t.Error("Expected error for unknown step")
+	}
+}
+
+func TestStateAt_StorageReduction(t *testing.T) {
+	diffed, _ := runMutating(t, workflow.SnapshotPolicy{Every: 10})
+	full, _ := runMutating(t, workflow.SnapshotPolicy{Every: 1})
+
+	diffedSize := len(mustJSON(t, diffed.History))
+	fullSize := len(mustJSON(t, full.History))
+	t.Logf("history size: %d bytes with diffs, %d bytes with full snapshots (%.1f%%)",
+		diffedSize, fullSize, 100*float64(diffedSize)/float64(fullSize))
+
+	if diffedSize*5 > fullSize {
+		t.Errorf("Expected diffs to reduce history at least 5x, got %d vs %d bytes", diffedSize, fullSize)
+	}
+}
+
+func TestStateAt_Truncation(t *testing.T) {
+	engine := workflow.NewEngine(nil)
+	engine.SetSnapshotPolicy(workflow.SnapshotPolicy{Every: 10, MaxValueSize: 1024})
+
+	wf := workflow.New("truncate").
+		Step("init", func(ctx context.Context, state *workflow.State) (any, error) {
+			return "ok", nil
+		}).Then().
+		Step("load", func(ctx context.Context, state *workflow.State) (any, error) {
+			state.Data["blob"] = strings.Repeat("y", 4096)
+			return "ok", nil
+		}).Then().
+		Build()
+
+	state, err := engine.Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := state.StateAt("load")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Truncated) != 1 || snap.Truncated[0] != "/data/blob" {
+		t.Errorf("Expected truncated path reported, got %v", snap.Truncated)
+	}
+	if blob, _ := snap.Data["blob"].(string); len(blob) >= 1024 || !strings.HasSuffix(blob, "...") {
+		t.Errorf("Expected truncated preview, got %d bytes", len(blob))
+	}
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
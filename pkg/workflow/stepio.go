@@ -0,0 +1,136 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ActionHandlerWithInput is an action handler that receives the step's
+// resolved Input.
+type ActionHandlerWithInput func(ctx context.Context, state *State, input map[string]any) (any, error)
+
+type stepInputContextKey struct{}
+
+// StepInput returns the resolved Input of the action step running with
+// ctx, or nil. It lets plain ActionHandlers read their input too.
+func StepInput(ctx context.Context) map[string]any {
+	input, _ := ctx.Value(stepInputContextKey{}).(map[string]any)
+	return input
+}
+
+// StepWithInput adds an action step whose handler receives the values set
+// with ActionBuilder.Input.
+func (b *Builder) StepWithInput(name string, handler ActionHandlerWithInput) *ActionBuilder {
+	return b.Step(name, func(ctx context.Context, state *State) (any, error) {
+		return handler(ctx, state, StepInput(ctx))
+	})
+}
+
+// Input sets templates resolved against the state before each execution
+// of the step, such as "{{ steps.fetch.result.url }}" or
+// "{{ data.user_id }}". Templates see data, results, steps.<name>.result,
+// id and workflow_id; a value that is a single expression keeps its type.
+// A path that does not resolve fails the step.
+func (ab *ActionBuilder) Input(input map[string]string) *ActionBuilder {
+	source := make(map[string]any, len(input))
+	for k, v := range input {
+		source[k] = v
+	}
+	ab.step.input = compileStepInput(source)
+	return ab
+}
+
+// Output copies the step's result into state.Data under key.
+func (ab *ActionBuilder) Output(key string) *ActionBuilder {
+	ab.step.output = key
+	return ab
+}
+
+// stepInput is the compiled Input of an action step.
+type stepInput struct {
+	source map[string]any
+	root   map[string]any
+	err    error
+}
+
+func compileStepInput(source map[string]any) *stepInput {
+	in := &stepInput{source: source}
+	root, err := compileTemplateValue(source, "", make(map[string]bool))
+	if err != nil {
+		in.err = err
+		return in
+	}
+	in.root = root.(map[string]any)
+	return in
+}
+
+// resolve renders the input against state.
+func (in *stepInput) resolve(state *State) (map[string]any, error) {
+	if in.err != nil {
+		return nil, in.err
+	}
+	vars := stateVars(state)
+	steps := make(map[string]any)
+	for name, result := range vars["results"].(map[string]any) {
+		steps[name] = map[string]any{"result": result}
+	}
+	vars["steps"] = steps
+
+	out := make(map[string]any, len(in.root))
+	for _, key := range sortedKeys(in.root) {
+		v, err := renderTemplateValue(in.root[key], key, vars)
+		if err != nil || v == nil {
+			if path := unresolvedPath(in.root[key], vars); path != "" {
+				return nil, fmt.Errorf("input %s: unresolved path %s", key, path)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[key] = v
+	}
+	return out, nil
+}
+
+// unresolvedPath returns the first path referenced by a compiled template
+// value that is missing from vars, or "".
+func unresolvedPath(v any, vars map[string]any) string {
+	var refs []string
+	var collect func(v any)
+	collect = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for _, item := range v {
+				collect(item)
+			}
+		case []any:
+			for _, item := range v {
+				collect(item)
+			}
+		case *templateString:
+			for _, part := range v.parts {
+				if part.expr != nil {
+					refs = append(refs, part.expr.prog.references()...)
+				}
+			}
+		}
+	}
+	collect(v)
+	sort.Strings(refs)
+
+	for _, ref := range refs {
+		var cur any = vars
+		for _, name := range strings.Split(ref, ".") {
+			m, ok := cur.(map[string]any)
+			if !ok {
+				break // not a map; the expression language decides
+			}
+			if cur, ok = m[name]; !ok || cur == nil {
+				return ref
+			}
+		}
+	}
+	return ""
+}
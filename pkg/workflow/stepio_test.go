@@ -0,0 +1,78 @@
+// Package workflow_test provides tests for step inputs and outputs.
+package workflow_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func TestActionBuilder_InputOutput(t *testing.T) {
+	var got map[string]any
+	wf := workflow.New("profile").
+		Step("fetch", func(ctx context.Context, state *workflow.State) (any, error) {
+			return map[string]any{"url": "https://example.com/u/7", "size": 3}, nil
+		}).Output("profile").Then().
+		StepWithInput("store", func(ctx context.Context, state *workflow.State, input map[string]any) (any, error) {
+			got = input
+			return "stored", nil
+		}).Input(map[string]string{
+		"url":  "{{ steps.fetch.result.url }}",
+		"size": "{{ steps.fetch.result.size }}",
+		"key":  "user-{{ data.user_id }}",
+	}).Then().
+		Build()
+
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, map[string]any{"user_id": 7})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if got["url"] != "https://example.com/u/7" || fmt.Sprint(got["size"]) != "3" || got["key"] != "user-7" {
+		t.Errorf("Unexpected input: %v", got)
+	}
+	if profile, _ := state.Get("profile"); profile.(map[string]any)["size"] != 3 {
+		t.Errorf("Expected the fetch result under data.profile, got %v", profile)
+	}
+}
+
+func TestActionBuilder_InputPlainHandler(t *testing.T) {
+	var got map[string]any
+	wf := workflow.New("plain").
+		Step("read", func(ctx context.Context, state *workflow.State) (any, error) {
+			got = workflow.StepInput(ctx)
+			return nil, nil
+		}).Input(map[string]string{"id": "{{ data.id }}"}).Then().
+		Build()
+
+	if _, err := workflow.NewEngine(nil).Execute(context.Background(), wf, map[string]any{"id": "a1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got["id"] != "a1" {
+		t.Errorf("Expected StepInput to return the input, got %v", got)
+	}
+}
+
+func TestActionBuilder_InputUnresolved(t *testing.T) {
+	called := false
+	wf := workflow.New("unresolved").
+		StepWithInput("store", func(ctx context.Context, state *workflow.State, input map[string]any) (any, error) {
+			called = true
+			return nil, nil
+		}).Input(map[string]string{"url": "{{ steps.fetch.result.url }}"}).OnErrorGoto("fallback").Then().
+		Step("fallback", func(ctx context.Context, state *workflow.State) (any, error) { return "ok", nil }).Then().
+		Build()
+
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatalf("Expected the fallback to recover, got %v", err)
+	}
+	if called {
+		t.Error("Handler ran with an unresolved input")
+	}
+	if len(state.StepErrors) != 1 || !strings.Contains(state.StepErrors[0].Message, "input url: unresolved path steps.fetch.result.url") {
+		t.Errorf("Expected an unresolved path error, got %+v", state.StepErrors)
+	}
+}
@@ -0,0 +1,112 @@
+// Package workflow_test provides tests for sub-workflow steps.
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func TestSubWorkflow_UsesParentEngine(t *testing.T) {
+	child := workflow.New("child").
+		AwaitSignal("approve", "approved").Then().
+		Step("finish", succeed("finished")).Then().
+		Build()
+	parent := workflow.New("parent").
+		SubWorkflow("review", child).Then().
+		Build()
+
+	store := workflow.NewMemoryPersistence()
+	engine := workflow.NewEngine(store)
+	engine.Register(parent)
+	id, err := engine.Start(context.Background(), "parent", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	childID := id + "-review"
+	waitFor(t, "the child to await its signal", func() bool {
+		saved, err := store.Load(context.Background(), childID)
+		return err == nil && saved.Status == workflow.StatusAwaitingSignal
+	})
+	if _, running := engine.GetState(childID); !running {
+		t.Error("Expected the child among running executions")
+	}
+
+	engine.SendSignal(context.Background(), "approved", nil)
+	var final *workflow.State
+	waitFor(t, "the parent to complete", func() bool {
+		final, _ = store.Load(context.Background(), id)
+		return final.Status == workflow.StatusCompleted
+	})
+	results, _ := final.StepResults["review"].(map[string]any)
+	if results["finish"] != "finished" || final.Children["review"] != childID {
+		t.Errorf("Unexpected parent state: %v %v", final.StepResults, final.Children)
+	}
+}
+
+func TestSubWorkflow_FailureReachesParentOnError(t *testing.T) {
+	child := workflow.New("child").Step("charge", failWith(errors.New("card declined"))).Then().Build()
+	var handled error
+	parent := workflow.New("parent").
+		OnError(func(ctx context.Context, state *workflow.State, err error) error {
+			handled = err
+			return nil
+		}).
+		SubWorkflow("pay", child).Then().
+		Step("notify", succeed("notified")).Then().
+		Build()
+
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), parent, nil)
+	if err != nil {
+		t.Fatalf("Expected the parent to recover, got %v", err)
+	}
+	if handled == nil || !strings.Contains(handled.Error(), "card declined") || state.StepResults["notify"] != "notified" {
+		t.Errorf("Expected OnError to see the child failure, got %v", handled)
+	}
+}
+
+func TestSubWorkflow_Async(t *testing.T) {
+	release := make(chan struct{})
+	child := workflow.New("child").
+		Step("work", func(ctx context.Context, state *workflow.State) (any, error) {
+			<-release
+			return state.GetString("item") + " done", nil
+		}).Then().
+		Build()
+	parent := workflow.New("parent").
+		SubWorkflow("background", child).WithInput(map[string]any{"item": "report"}).Async().Then().
+		Step("meanwhile", func(ctx context.Context, state *workflow.State) (any, error) {
+			close(release) // runs while the child is still blocked
+			return "ok", nil
+		}).Then().
+		WaitForChild("join", "background").
+		Build()
+
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), parent, nil)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if state.StepResults["background"] != state.Children["background"] {
+		t.Errorf("Expected the child ID as the async result, got %v", state.StepResults["background"])
+	}
+	if results, _ := state.StepResults["join"].(map[string]any); results["work"] != "report done" {
+		t.Errorf("Expected the child's results from the join, got %v", state.StepResults["join"])
+	}
+
+	failing := workflow.New("parent").
+		SubWorkflow("background", workflow.New("child").Step("x", failWith(errors.New("boom"))).Then().Build()).Async().Then().
+		WaitForChild("join", "background").
+		Build()
+	if _, err := workflow.NewEngine(nil).Execute(context.Background(), failing, nil); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Expected the child failure from the join, got %v", err)
+	}
+
+	bad := workflow.New("bad").WaitForChild("join", "nothing").Build()
+	if err := bad.Validate(); err == nil || !strings.Contains(err.Error(), "not an async sub-workflow") {
+		t.Errorf("Expected a validation error, got %v", err)
+	}
+}
@@ -0,0 +1,396 @@
+// Package workflow provides payload templates rendered when triggers fire.
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+// PayloadTemplate builds a job or workflow payload from trigger context.
+// String values may embed expressions in {{ }}; a value that is a single
+// expression keeps the expression's type, anything else is interpolated
+// into a string:
+//
+//	{"date": "{{today}}", "region": "{{data.region | upper}}", "days": "{{7}}"}
+//
+// Every template can read now (RFC 3339), today (YYYY-MM-DD), env.NAME and
+// secrets.NAME; triggers add their own variables (see TemplateScope).
+type PayloadTemplate struct {
+	source map[string]any
+	root   map[string]any
+	refs   []string
+}
+
+// templateString is a string value containing at least one expression.
+type templateString struct {
+	parts []templatePart
+}
+
+type templatePart struct {
+	text string
+	expr *Expr
+}
+
+// TriggerContext is what a template is rendered with.
+type TriggerContext struct {
+	// Time is when the trigger fired; zero uses the current time.
+	Time time.Time
+	// Vars are the trigger's own variables, such as the webhook payload.
+	Vars map[string]any
+	// Secrets resolves secrets.NAME references. Unresolved names are null.
+	Secrets func(name string) (string, bool)
+}
+
+// TemplateScope describes the variables a trigger provides, mapping each
+// variable to its known fields. A nil field list allows any field.
+type TemplateScope map[string][]string
+
+// templateBuiltins are provided to every template.
+var templateBuiltins = TemplateScope{
+	"now":     {},
+	"today":   {},
+	"env":     nil,
+	"secrets": nil,
+}
+
+// CompilePayloadTemplate parses every expression in tmpl.
+func CompilePayloadTemplate(tmpl map[string]any) (*PayloadTemplate, error) {
+	t := &PayloadTemplate{source: tmpl}
+	refs := make(map[string]bool)
+	root, err := compileTemplateValue(tmpl, "", refs)
+	if err != nil {
+		return nil, err
+	}
+	t.root = root.(map[string]any)
+	for ref := range refs {
+		t.refs = append(t.refs, ref)
+	}
+	sort.Strings(t.refs)
+	return t, nil
+}
+
+// Source returns the template as written.
+func (t *PayloadTemplate) Source() map[string]any { return t.source }
+
+// References returns the variables the template reads, as dotted paths
+// such as "data.region".
+func (t *PayloadTemplate) References() []string { return t.refs }
+
+func compileTemplateValue(v any, path string, refs map[string]bool) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			compiled, err := compileTemplateValue(item, joinPath(path, k), refs)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = compiled
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			compiled, err := compileTemplateValue(item, fmt.Sprintf("%s[%d]", path, i), refs)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = compiled
+		}
+		return out, nil
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		return compileTemplateString(v, path, refs)
+	}
+	return v, nil
+}
+
+func compileTemplateString(s, path string, refs map[string]bool) (*templateString, error) {
+	ts := &templateString{}
+	for s != "" {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			ts.parts = append(ts.parts, templatePart{text: s})
+			break
+		}
+		if start > 0 {
+			ts.parts = append(ts.parts, templatePart{text: s[:start]})
+		}
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("workflow: template field %q: unclosed {{", path)
+		}
+		expr, err := CompileExpr(s[start+2 : start+end])
+		if err != nil {
+			return nil, fmt.Errorf("workflow: template field %q: %w", path, err)
+		}
+		for _, ref := range expr.prog.references() {
+			refs[ref] = true
+		}
+		ts.parts = append(ts.parts, templatePart{expr: expr})
+		s = s[start+end+2:]
+	}
+	return ts, nil
+}
+
+// ============ Rendering ============
+
+// Render evaluates the template. Errors name the field that failed.
+func (t *PayloadTemplate) Render(tc TriggerContext) (map[string]any, error) {
+	vars := t.vars(tc)
+	out, err := renderTemplateValue(t.root, "", vars)
+	if err != nil {
+		return nil, err
+	}
+	return out.(map[string]any), nil
+}
+
+// vars builds the variables for tc. Only the env and secret names the
+// template references are resolved.
+func (t *PayloadTemplate) vars(tc TriggerContext) map[string]any {
+	now := tc.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	env := make(map[string]any)
+	secrets := make(map[string]any)
+	for _, ref := range t.refs {
+		root, field, _ := strings.Cut(ref, ".")
+		name, _, _ := strings.Cut(field, ".")
+		switch {
+		case name == "":
+		case root == "env":
+			if v, ok := os.LookupEnv(name); ok {
+				env[name] = v
+			}
+		case root == "secrets" && tc.Secrets != nil:
+			if v, ok := tc.Secrets(name); ok {
+				secrets[name] = v
+			}
+		}
+	}
+
+	vars := map[string]any{
+		"now":     now.Format(time.RFC3339),
+		"today":   now.Format(time.DateOnly),
+		"env":     env,
+		"secrets": secrets,
+	}
+	for k, v := range tc.Vars {
+		vars[k] = v
+	}
+	return vars
+}
+
+func renderTemplateValue(v any, path string, vars map[string]any) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			rendered, err := renderTemplateValue(item, joinPath(path, k), vars)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = rendered
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			rendered, err := renderTemplateValue(item, fmt.Sprintf("%s[%d]", path, i), vars)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	case *templateString:
+		if len(v.parts) == 1 && v.parts[0].expr != nil {
+			out, err := v.parts[0].expr.Eval(vars)
+			if err != nil {
+				return nil, fmt.Errorf("workflow: rendering template field %q: %w", path, err)
+			}
+			return out, nil
+		}
+		var sb strings.Builder
+		for _, part := range v.parts {
+			if part.expr == nil {
+				sb.WriteString(part.text)
+				continue
+			}
+			out, err := part.expr.Eval(vars)
+			if err != nil {
+				return nil, fmt.Errorf("workflow: rendering template field %q: %w", path, err)
+			}
+			if out != nil {
+				sb.WriteString(toString(out))
+			}
+		}
+		return sb.String(), nil
+	}
+	return v, nil
+}
+
+// ============ Static Analysis ============
+
+// Check validates the template against the variables scope provides and,
+// when schema is non-nil, against a job payload schema. Problems that
+// would make every render fail (undefined variables, missing required
+// fields, literal values of the wrong type) are returned as an error;
+// likely mistakes (unknown fields of a known variable, fields the schema
+// does not declare) are returned as warnings.
+func (t *PayloadTemplate) Check(scope TemplateScope, schema *tools.Schema) (warnings []string, err error) {
+	var problems []string
+
+	undefined := make(map[string]bool)
+	for _, ref := range t.refs {
+		parts := strings.Split(ref, ".")
+		fields, ok := scope[parts[0]]
+		if !ok {
+			fields, ok = templateBuiltins[parts[0]]
+		}
+		if !ok {
+			if !undefined[parts[0]] {
+				undefined[parts[0]] = true
+				problems = append(problems, fmt.Sprintf("undefined variable %q", parts[0]))
+			}
+			continue
+		}
+		if len(parts) > 1 && fields != nil && !containsString(fields, parts[1]) {
+			warnings = append(warnings, fmt.Sprintf("unknown field %q", parts[0]+"."+parts[1]))
+		}
+	}
+
+	if schema != nil {
+		for _, name := range schema.Required {
+			if _, ok := t.root[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: required by the payload schema", name))
+			}
+		}
+
+		names := make([]string, 0, len(t.root))
+		for name := range t.root {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		static := make(map[string]any)
+		for _, name := range names {
+			prop, ok := schema.Properties[name]
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("%s: not declared in the payload schema", name))
+				continue
+			}
+			switch v := t.root[name].(type) {
+			case *templateString:
+				// Interpolated strings are always strings.
+				if len(v.parts) > 1 && prop.Type != "" && prop.Type != "string" {
+					problems = append(problems, fmt.Sprintf("%s: expected %s, template renders a string", name, prop.Type))
+				}
+			default:
+				static[name] = v
+			}
+		}
+		var verr *tools.ValidationError
+		if errors.As((tools.Schema{Properties: schema.Properties}).Validate(static), &verr) {
+			problems = append(problems, verr.Problems...)
+		}
+	}
+
+	if len(problems) > 0 {
+		return warnings, fmt.Errorf("workflow: invalid payload template: %s", strings.Join(problems, "; "))
+	}
+	return warnings, nil
+}
+
+// references returns the free variables the program reads as dotted
+// paths. Let-bound names, lambda parameters and builtins are excluded.
+func (prog *program) references() []string {
+	refs := make(map[string]bool)
+	bound := make(map[string]bool)
+	for _, let := range prog.lets {
+		collectReferences(let.value, bound, refs)
+		bound[let.name] = true
+	}
+	collectReferences(prog.result, bound, refs)
+
+	out := make([]string, 0, len(refs))
+	for ref := range refs {
+		out = append(out, ref)
+	}
+	return out
+}
+
+func collectReferences(n node, bound map[string]bool, refs map[string]bool) {
+	switch n := n.(type) {
+	case *identNode:
+		if !bound[n.name] && builtins[n.name] == nil {
+			refs[n.name] = true
+		}
+	case *memberNode:
+		names := []string{n.name}
+		x := n.x
+		for {
+			m, ok := x.(*memberNode)
+			if !ok {
+				break
+			}
+			names = append([]string{m.name}, names...)
+			x = m.x
+		}
+		if id, ok := x.(*identNode); ok && !bound[id.name] && builtins[id.name] == nil {
+			refs[id.name+"."+strings.Join(names, ".")] = true
+			return
+		}
+		collectReferences(x, bound, refs)
+	case *arrayNode:
+		for _, item := range n.items {
+			collectReferences(item, bound, refs)
+		}
+	case *objectNode:
+		for _, v := range n.values {
+			collectReferences(v, bound, refs)
+		}
+	case *unaryNode:
+		collectReferences(n.x, bound, refs)
+	case *binaryNode:
+		collectReferences(n.x, bound, refs)
+		collectReferences(n.y, bound, refs)
+	case *ternaryNode:
+		collectReferences(n.cond, bound, refs)
+		collectReferences(n.then, bound, refs)
+		collectReferences(n.other, bound, refs)
+	case *indexNode:
+		collectReferences(n.x, bound, refs)
+		collectReferences(n.index, bound, refs)
+	case *callNode:
+		collectReferences(n.fn, bound, refs)
+		for _, arg := range n.args {
+			collectReferences(arg, bound, refs)
+		}
+	case *lambdaNode:
+		inner := make(map[string]bool, len(bound)+len(n.params))
+		for k := range bound {
+			inner[k] = true
+		}
+		for _, p := range n.params {
+			inner[p] = true
+		}
+		collectReferences(n.body, inner, refs)
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,257 @@
+// Package workflow_test provides tests for payload templates and scheduled jobs.
+package workflow_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/queue"
+	"github.com/nuulab/goflow/pkg/tools"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+var reportSchema = tools.Schema{
+	Type: "object",
+	Properties: map[string]tools.Property{
+		"date":   {Type: "string"},
+		"region": {Type: "string", Enum: []string{"EU", "US"}},
+		"days":   {Type: "number"},
+	},
+	Required: []string{"date", "region"},
+}
+
+func TestPayloadTemplate_Render(t *testing.T) {
+	t.Setenv("REPORT_BUCKET", "reports-prod")
+	tmpl, err := workflow.CompilePayloadTemplate(map[string]any{
+		"date":   "{{today}}",
+		"region": "{{data.region | trim | upper}}",
+		"days":   "{{data.days | default(7)}}",
+		"path":   "s3://{{env.REPORT_BUCKET}}/{{today}}.csv",
+		"token":  "{{secrets.API_TOKEN}}",
+		"static": []any{1, "two"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2024, 3, 9, 6, 0, 0, 0, time.UTC)
+	out, err := tmpl.Render(workflow.TriggerContext{
+		Time: at,
+		Vars: map[string]any{"data": map[string]any{"region": " eu "}},
+		Secrets: func(name string) (string, bool) {
+			return "tok-" + name, name == "API_TOKEN"
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"date":   "2024-03-09",
+		"region": "EU",
+		"days":   7.0,
+		"path":   "s3://reports-prod/2024-03-09.csv",
+		"token":  "tok-API_TOKEN",
+	}
+	for k, v := range want {
+		if out[k] != v {
+			t.Errorf("%s = %#v, want %#v", k, out[k], v)
+		}
+	}
+	if static, _ := out["static"].([]any); len(static) != 2 {
+		t.Errorf("Static values should pass through, got %#v", out["static"])
+	}
+
+	refs := strings.Join(tmpl.References(), ",")
+	if refs != "data.days,data.region,env.REPORT_BUCKET,secrets.API_TOKEN,today" {
+		t.Errorf("Unexpected references: %s", refs)
+	}
+}
+
+func TestPayloadTemplate_RenderError(t *testing.T) {
+	tmpl, err := workflow.CompilePayloadTemplate(map[string]any{"n": "{{1 / data}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tmpl.Render(workflow.TriggerContext{Vars: map[string]any{"data": "x"}})
+	if err == nil || !strings.Contains(err.Error(), `field "n"`) {
+		t.Errorf("Expected an error naming the field, got %v", err)
+	}
+
+	if _, err := workflow.CompilePayloadTemplate(map[string]any{"x": "{{today"}); err == nil {
+		t.Error("Expected an unclosed expression to be rejected")
+	}
+}
+
+func TestPayloadTemplate_Check(t *testing.T) {
+	scope := workflow.TemplateScope{"schedule": {"id", "expression"}}
+
+	tests := []struct {
+		name     string
+		tmpl     map[string]any
+		wantErr  string
+		warnings []string
+	}{
+		{
+			name: "valid",
+			tmpl: map[string]any{"date": "{{today}}", "region": "EU", "days": 7},
+		},
+		{
+			name:     "unknown field",
+			tmpl:     map[string]any{"date": "{{schedule.name}}", "region": "US"},
+			warnings: []string{`unknown field "schedule.name"`},
+		},
+		{
+			name:     "undeclared key",
+			tmpl:     map[string]any{"date": "{{today}}", "region": "EU", "extra": true},
+			warnings: []string{"extra: not declared in the payload schema"},
+		},
+		{
+			name:    "undefined variable",
+			tmpl:    map[string]any{"date": "{{data.date}}", "region": "{{data.region}}"},
+			wantErr: `undefined variable "data"`,
+		},
+		{
+			name:    "missing required",
+			tmpl:    map[string]any{"date": "{{today}}"},
+			wantErr: "region: required by the payload schema",
+		},
+		{
+			name:    "literal outside enum",
+			tmpl:    map[string]any{"date": "{{today}}", "region": "APAC"},
+			wantErr: "region",
+		},
+		{
+			name:    "interpolated number",
+			tmpl:    map[string]any{"date": "{{today}}", "region": "EU", "days": "{{1}} days"},
+			wantErr: "days: expected number",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := workflow.CompilePayloadTemplate(tt.tmpl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			warnings, err := tmpl.Check(scope, &reportSchema)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if strings.Join(warnings, "|") != strings.Join(tt.warnings, "|") {
+				t.Errorf("warnings = %q, want %q", warnings, tt.warnings)
+			}
+			if tt.name == "undefined variable" && strings.Count(err.Error(), "undefined") != 1 {
+				t.Errorf("Expected one problem per undefined variable: %v", err)
+			}
+		})
+	}
+}
+
+// ============ Scheduled Job Tests ============
+
+func TestCron_ScheduledJob(t *testing.T) {
+	ctx := context.Background()
+	q := queue.NewMemoryQueue()
+	schemas := queue.NewJobSchemas()
+	schemas.Register("report", reportSchema)
+
+	cron := workflow.NewCron(nil)
+	cron.SetQueue(q, schemas)
+
+	warnings, err := cron.AddJob("nightly", "report", "0 6 * * *", map[string]any{
+		"date":   "{{today}}",
+		"region": "EU",
+		"source": "{{schedule.id}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "source") {
+		t.Errorf("Expected a warning for the undeclared key, got %q", warnings)
+	}
+
+	if err := cron.Trigger(ctx, "nightly"); err != nil {
+		t.Fatal(err)
+	}
+	job, err := q.Dequeue(ctx, time.Second)
+	if err != nil || job == nil {
+		t.Fatalf("Expected an enqueued job, got %v, %v", job, err)
+	}
+	var payload map[string]any
+	json.Unmarshal(job.Payload, &payload)
+	if job.Type != "report" || payload["date"] != time.Now().Format(time.DateOnly) || payload["source"] != "nightly" {
+		t.Errorf("Unexpected job %s: %s", job.Type, job.Payload)
+	}
+	if job.Metadata["cron_schedule_id"] != "nightly" {
+		t.Errorf("Missing schedule metadata: %v", job.Metadata)
+	}
+}
+
+func TestCron_ScheduledJobRejected(t *testing.T) {
+	ctx := context.Background()
+	q := queue.NewMemoryQueue()
+	schemas := queue.NewJobSchemas()
+	cron := workflow.NewCron(nil)
+	cron.SetQueue(q, schemas)
+
+	if _, err := cron.AddJob("bad", "report", "0 6 * * *", map[string]any{"region": "EU"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The schema is registered after the schedule, so only the rendered
+	// payload can catch the missing field.
+	schemas.Register("report", reportSchema)
+	if _, err := cron.AddJob("worse", "report", "0 6 * * *", map[string]any{"region": "EU"}); err == nil {
+		t.Error("Expected AddJob to reject a template missing a required field")
+	}
+
+	err := cron.Trigger(ctx, "bad")
+	if err == nil || !strings.Contains(err.Error(), "date") {
+		t.Fatalf("Expected a schema error, got %v", err)
+	}
+	if n, _ := q.Len(ctx); n != 0 {
+		t.Errorf("Invalid payload was enqueued")
+	}
+	for _, s := range cron.List() {
+		if s.ID == "bad" && s.LastError == "" {
+			t.Error("Expected LastError to be recorded")
+		}
+	}
+}
+
+func TestCron_WorkflowInputIsLiteral(t *testing.T) {
+	ctx := context.Background()
+	inputs := make(chan map[string]any, 2)
+	engine := workflow.NewEngine(nil)
+	engine.Register(workflow.New("report").
+		Step("read", func(ctx context.Context, state *workflow.State) (any, error) {
+			inputs <- map[string]any{"note": state.GetString("note")}
+			return nil, nil
+		}).Then().
+		Build())
+	cron := workflow.NewCron(engine)
+	input := map[string]any{"note": "{{today}}"}
+	cron.Add("literal", "report", "@daily", input)
+	cron.Add("templated", "report", "@daily", input, workflow.WithTemplate())
+
+	for id, want := range map[string]string{"literal": "{{today}}", "templated": time.Now().Format(time.DateOnly)} {
+		if _, err := cron.TriggerNow(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-inputs:
+			if got["note"] != want {
+				t.Errorf("%s: expected %q, got %q", id, want, got["note"])
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: workflow did not run", id)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package workflow_test provides tests for run timeouts.
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// hang blocks until its context ends and reports the cause.
+func hang(ctx context.Context, state *workflow.State) (any, error) {
+	<-ctx.Done()
+	return nil, context.Cause(ctx)
+}
+
+func TestEngine_RunTimeout(t *testing.T) {
+	compensated := make(chan struct{})
+	wf := workflow.New("hanging").
+		Timeout(20*time.Millisecond).
+		Step("reserve", func(ctx context.Context, state *workflow.State) (any, error) { return "ok", nil }).
+		Compensate(func(ctx context.Context, state *workflow.State) error {
+			close(compensated)
+			return nil
+		}).Then().
+		Step("call", hang).Then().
+		Build()
+
+	store := workflow.NewMemoryPersistence()
+	engine := workflow.NewEngine(store)
+	engine.Register(wf)
+	id, err := engine.Start(context.Background(), "hanging", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var state *workflow.State
+	waitFor(t, "the run to time out", func() bool {
+		state, err = store.Load(context.Background(), id)
+		return err == nil && state.Status == workflow.StatusFailed
+	})
+	select {
+	case <-compensated:
+	default:
+		t.Error("Expected the compensation to run")
+	}
+	if last := state.Errors[len(state.Errors)-1]; !strings.Contains(last, "run timed out") {
+		t.Errorf("Expected a timeout error, got %q", state.Errors)
+	}
+	waitFor(t, "the run to finish", func() bool {
+		_, running := engine.GetState(id)
+		return !running
+	})
+}
+
+func TestEngine_SetRunTimeout(t *testing.T) {
+	engine := workflow.NewEngine(nil)
+	engine.SetRunTimeout(10 * time.Millisecond)
+
+	_, err := engine.Execute(context.Background(), workflow.New("default").Step("call", hang).Then().Build(), nil)
+	if !errors.Is(err, workflow.ErrRunTimeout) {
+		t.Errorf("Expected the engine default to apply, got %v", err)
+	}
+
+	// The workflow's own timeout overrides the default.
+	wf := workflow.New("patient").
+		Timeout(time.Second).
+		Step("wait", func(ctx context.Context, state *workflow.State) (any, error) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(30 * time.Millisecond):
+				return "done", nil
+			}
+		}).Then().
+		Build()
+	if _, err := engine.Execute(context.Background(), wf, nil); err != nil {
+		t.Errorf("Expected the workflow timeout to win, got %v", err)
+	}
+}
+
+func TestRunTimeout_NestedStepTimeouts(t *testing.T) {
+	t.Run("step timeout first", func(t *testing.T) {
+		wf := workflow.New("step-first").
+			Timeout(time.Second).
+			Step("call", hang).Timeout(10*time.Millisecond).OnErrorGoto("fallback").Then().
+			Step("fallback", func(ctx context.Context, state *workflow.State) (any, error) { return "ok", nil }).Then().
+			Build()
+		state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil)
+		if err != nil {
+			t.Fatalf("Expected the step timeout to be handled, got %v", err)
+		}
+		if e := state.StepErrors[0]; e.Step != "call" || !strings.Contains(e.Message, "deadline exceeded") {
+			t.Errorf("Expected the step's own deadline, got %+v", e)
+		}
+	})
+
+	t.Run("run timeout first", func(t *testing.T) {
+		handled := false
+		wf := workflow.New("run-first").
+			Timeout(10*time.Millisecond).
+			OnError(func(ctx context.Context, state *workflow.State, err error) error {
+				handled = true
+				return nil // the run still ends: its context is done
+			}).
+			Step("call", hang).Timeout(time.Second).Then().
+			Step("after", func(ctx context.Context, state *workflow.State) (any, error) { return "ran", nil }).Then().
+			Build()
+		state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil)
+		if !errors.Is(err, workflow.ErrRunTimeout) || state.Status != workflow.StatusFailed {
+			t.Fatalf("Expected the run timeout, got %v (%s)", err, state.Status)
+		}
+		if e := state.StepErrors[0]; !strings.Contains(e.Message, "run timed out") {
+			t.Errorf("Expected the step to see the run timeout as its cause, got %+v", e)
+		}
+		if !handled || state.StepResults["after"] != nil {
+			t.Errorf("Expected no steps after the timeout, got %v", state.StepResults)
+		}
+	})
+}
+
+func TestRunTimeout_StepIgnoringContext(t *testing.T) {
+	wf := workflow.New("stubborn").
+		Timeout(10*time.Millisecond).
+		Step("sleep", func(ctx context.Context, state *workflow.State) (any, error) {
+			time.Sleep(40 * time.Millisecond)
+			return "done", nil
+		}).Then().
+		Build()
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil)
+	if !errors.Is(err, workflow.ErrRunTimeout) || state.Status != workflow.StatusFailed {
+		t.Fatalf("Expected the run to fail with the timeout, got %v (%s)", err, state.Status)
+	}
+	if last := state.Errors[len(state.Errors)-1]; !strings.Contains(last, "run timed out") {
+		t.Errorf("Expected a timeout error, got %q", state.Errors)
+	}
+}
@@ -0,0 +1,118 @@
+// Package workflow_test provides tests for timer steps.
+package workflow_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+func TestTimerStep_WaitUntil(t *testing.T) {
+	start := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	wf := workflow.New("remind").
+		WaitUntilFunc("until-due", func(state *workflow.State) time.Time {
+			return start.Add(20 * time.Millisecond)
+		}).
+		WaitUntil("past", start.Add(-time.Hour)).
+		Build()
+
+	engine := workflow.NewEngine(nil)
+	engine.SetClock(workflow.NewFakeClock(start))
+	began := time.Now()
+	state, err := engine.Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if elapsed := time.Since(began); elapsed < 20*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected to wait about 20ms, waited %v", elapsed)
+	}
+	if state.AwaitingStep != "" || !state.WakeAt.IsZero() {
+		t.Errorf("Expected the timer to be cleared, got %q %v", state.AwaitingStep, state.WakeAt)
+	}
+}
+
+func TestTimerStep_WaitForCron(t *testing.T) {
+	// Sunday 23:00; the next "Mondays at 09:00" is ten hours away.
+	clock := workflow.NewFakeClock(time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC))
+	wf := workflow.New("weekly").WaitForCron("monday", "0 9 * * 1").Build()
+
+	store := workflow.NewMemoryPersistence()
+	engine := workflow.NewEngine(store)
+	engine.SetClock(clock)
+	engine.Register(wf)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	id, err := engine.Start(ctx, "weekly", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var saved *workflow.State
+	waitFor(t, "the timer to be saved", func() bool {
+		saved, err = store.Load(context.Background(), id)
+		return err == nil && saved.Status == workflow.StatusAwaitingTimer
+	})
+	if want := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC); !saved.WakeAt.Equal(want) || saved.AwaitingStep != "monday" {
+		t.Errorf("Expected to wake at %v in monday, got %v in %q", want, saved.WakeAt, saved.AwaitingStep)
+	}
+
+	bad := workflow.New("bad").WaitForCron("never", "61 * * * *").Build()
+	if _, err := workflow.NewEngine(nil).Execute(context.Background(), bad, nil); err == nil || !strings.Contains(err.Error(), "wait 'never'") {
+		t.Errorf("Expected a cron error, got %v", err)
+	}
+}
+
+func TestTimerStep_ResumesRemainingWait(t *testing.T) {
+	start := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	var computed int
+	wf := workflow.New("cooldown").
+		WaitUntilFunc("cool", func(state *workflow.State) time.Time {
+			computed++
+			return start.Add(time.Hour)
+		}).
+		Step("done", func(ctx context.Context, state *workflow.State) (any, error) {
+			return "done", nil
+		}).Then().
+		Build()
+
+	// Crash with an hour left to wait.
+	ctx, cancel := context.WithCancel(context.Background())
+	store := workflow.NewMemoryPersistence()
+	first := workflow.NewEngine(store)
+	first.SetClock(workflow.NewFakeClock(start))
+	first.Register(wf)
+	id, err := first.Start(ctx, "cooldown", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved *workflow.State
+	waitFor(t, "the timer to be saved", func() bool {
+		saved, err = store.Load(context.Background(), id)
+		return err == nil && saved.Status == workflow.StatusAwaitingTimer
+	})
+	cancel()
+	restarted := workflow.NewMemoryPersistence()
+	restarted.Save(context.Background(), saved)
+
+	// After the restart only 20ms of the hour remain.
+	engine := workflow.NewEngine(restarted)
+	engine.SetClock(workflow.NewFakeClock(start.Add(time.Hour - 20*time.Millisecond)))
+	engine.Register(wf)
+	if n, err := engine.ResumeAwaiting(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Expected 1 run resumed, got %d (%v)", n, err)
+	}
+	var final *workflow.State
+	waitFor(t, "the run to complete", func() bool {
+		final, _ = restarted.Load(context.Background(), id)
+		return final.Status == workflow.StatusCompleted
+	})
+	if computed != 1 {
+		t.Errorf("Expected the target to be computed once, computed %d times", computed)
+	}
+	if final.StepResults["done"] != "done" || !final.WakeAt.IsZero() {
+		t.Errorf("Unexpected final state: %+v", final)
+	}
+}
@@ -0,0 +1,230 @@
+// Package workflow provides tool and agent steps with workflow-scoped registries.
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/tools"
+)
+
+type toolsContextKey struct{}
+
+// ContextWithTools returns a context carrying the tool registry visible to steps.
+func ContextWithTools(ctx context.Context, registry *tools.Registry) context.Context {
+	return context.WithValue(ctx, toolsContextKey{}, registry)
+}
+
+// ToolsFromContext returns the tool registry visible to the current step.
+func ToolsFromContext(ctx context.Context) (*tools.Registry, bool) {
+	registry, ok := ctx.Value(toolsContextKey{}).(*tools.Registry)
+	return registry, ok && registry != nil
+}
+
+// Tools declares the tools this workflow may use. Agent and tool steps only
+// see the declared tools, even if the engine's registry holds more.
+func (b *Builder) Tools(names ...string) *Builder {
+	b.workflow.toolNames = append(b.workflow.toolNames, names...)
+	return b
+}
+
+// Toolkit declares all tools of a toolkit for this workflow.
+func (b *Builder) Toolkit(tk *tools.Toolkit) *Builder {
+	b.workflow.toolkits = append(b.workflow.toolkits, tk)
+	return b
+}
+
+// CallTool adds a step that executes a tool with input built from state.
+func (b *Builder) CallTool(name, toolName string, input func(state *State) string) *Builder {
+	b.workflow.Steps = append(b.workflow.Steps, &ToolStep{
+		name:     name,
+		toolName: toolName,
+		input:    input,
+	})
+	return b
+}
+
+// Agent adds a step that runs an agent built from the workflow's tools.
+// The task is built from state and the agent's output is stored as the step result.
+func (b *Builder) Agent(name string, factory AgentFactory, task func(state *State) string) *Builder {
+	b.workflow.Steps = append(b.workflow.Steps, &AgentStep{
+		name:    name,
+		factory: factory,
+		task:    task,
+	})
+	return b
+}
+
+// DeclaredTools returns the tool names declared by the workflow, including
+// toolkit tools, or nil if the workflow does not restrict its tools.
+func (w *Workflow) DeclaredTools() []string {
+	if len(w.toolNames) == 0 && len(w.toolkits) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, name := range w.toolNames {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, tk := range w.toolkits {
+		for _, tool := range tk.Tools {
+			if !seen[tool.Name] {
+				seen[tool.Name] = true
+				names = append(names, tool.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks statically known tool references against the declared tools.
+func (w *Workflow) Validate() error {
+	if err := w.validateGotos(); err != nil {
+		return err
+	}
+	if err := w.validateChildren(); err != nil {
+		return err
+	}
+	declared := w.DeclaredTools()
+	if declared == nil {
+		return nil
+	}
+	allowed := make(map[string]bool, len(declared))
+	for _, name := range declared {
+		allowed[name] = true
+	}
+
+	var err error
+	walkSteps(w.Steps, func(step Step) {
+		if ts, ok := step.(*ToolStep); ok && err == nil && !allowed[ts.toolName] {
+			err = fmt.Errorf("workflow %s: step '%s' uses tool %q which is not declared", w.Name, ts.name, ts.toolName)
+		}
+	})
+	return err
+}
+
+// walkSteps visits steps and their nested branches depth-first.
+func walkSteps(steps []Step, visit func(Step)) {
+	for _, step := range steps {
+		visit(step)
+		switch s := step.(type) {
+		case *ConditionStep:
+			walkSteps(s.thenSteps, visit)
+			for _, branch := range s.elifSteps {
+				walkSteps(branch, visit)
+			}
+			walkSteps(s.elseSteps, visit)
+		case *LoopStep:
+			walkSteps(s.steps, visit)
+		case *ParallelStep:
+			walkSteps(s.steps, visit)
+		}
+	}
+}
+
+// scopedTools builds the registry visible to the workflow's steps.
+func (e *Engine) scopedTools(workflow *Workflow) (*tools.Registry, error) {
+	e.mu.RLock()
+	global := e.registry
+	e.mu.RUnlock()
+
+	if len(workflow.toolNames) == 0 && len(workflow.toolkits) == 0 {
+		return global, nil
+	}
+
+	scoped := tools.NewRegistry()
+	if len(workflow.toolNames) > 0 {
+		if global == nil {
+			return nil, fmt.Errorf("workflow %s declares tools but the engine has no registry", workflow.Name)
+		}
+		subset, err := global.Subset(workflow.toolNames...)
+		if err != nil {
+			return nil, fmt.Errorf("workflow %s: %w", workflow.Name, err)
+		}
+		scoped = subset
+	}
+	for _, tk := range workflow.toolkits {
+		for _, tool := range tk.Tools {
+			if _, exists := scoped.Get(tool.Name); !exists {
+				scoped.Register(tool)
+			}
+		}
+	}
+	return scoped, nil
+}
+
+// ============ Tool Step ============
+
+// ToolStep executes a single tool from the workflow's registry.
+type ToolStep struct {
+	name     string
+	toolName string
+	input    func(state *State) string
+}
+
+func (s *ToolStep) Name() string   { return s.name }
+func (s *ToolStep) Type() StepType { return StepTypeTool }
+
+func (s *ToolStep) Execute(ctx context.Context, state *State) error {
+	registry, ok := ToolsFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("tool step '%s': no tool registry available", s.name)
+	}
+	tool, ok := registry.Get(s.toolName)
+	if !ok {
+		return fmt.Errorf("tool step '%s': tool %q is not available to this workflow", s.name, s.toolName)
+	}
+
+	input := "{}"
+	if s.input != nil {
+		input = s.input(state)
+	}
+
+	output, err := tool.Execute(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	state.mu.Lock()
+	state.StepResults[s.name] = output
+	state.mu.Unlock()
+	return nil
+}
+
+// ============ Agent Step ============
+
+// AgentFactory creates an agent using the given (workflow-scoped) registry.
+type AgentFactory func(registry *tools.Registry) *agent.Agent
+
+// AgentStep runs an agent with the workflow's tools.
+type AgentStep struct {
+	name    string
+	factory AgentFactory
+	task    func(state *State) string
+}
+
+func (s *AgentStep) Name() string   { return s.name }
+func (s *AgentStep) Type() StepType { return StepTypeAgent }
+
+func (s *AgentStep) Execute(ctx context.Context, state *State) error {
+	registry, ok := ToolsFromContext(ctx)
+	if !ok {
+		registry = tools.NewRegistry()
+	}
+
+	result, err := s.factory(registry).Run(ctx, s.task(state))
+	if err != nil {
+		return fmt.Errorf("agent step '%s': %w", s.name, err)
+	}
+
+	state.mu.Lock()
+	state.StepResults[s.name] = result.Output
+	state.mu.Unlock()
+	return nil
+}
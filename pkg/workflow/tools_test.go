@@ -0,0 +1,157 @@
+// Package workflow_test provides tests for workflow-scoped tool registries.
+package workflow_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nuulab/goflow/pkg/agent"
+	"github.com/nuulab/goflow/pkg/core"
+	"github.com/nuulab/goflow/pkg/tools"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// probeLLM calls the "secret" tool first and then reports what it observed.
+type probeLLM struct {
+	systemPrompt string
+	observation  string
+}
+
+func (p *probeLLM) Generate(ctx context.Context, prompt string, opts ...core.Option) (string, error) {
+	return "", fmt.Errorf("not supported")
+}
+
+func (p *probeLLM) GenerateChat(ctx context.Context, messages []core.Message, opts ...core.Option) (string, error) {
+	p.systemPrompt = messages[0].Content
+	if len(messages) == 2 {
+		return `{"action": "secret", "action_input": {}}`, nil
+	}
+	p.observation = messages[len(messages)-1].Content
+	return `{"action": "final_answer", "action_input": "done"}`, nil
+}
+
+func (p *probeLLM) Stream(ctx context.Context, prompt string, opts ...core.Option) (<-chan string, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (p *probeLLM) StreamChat(ctx context.Context, messages []core.Message, opts ...core.Option) (<-chan string, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func globalRegistry(secretCalled *bool) *tools.Registry {
+	registry := tools.BuiltinTools()
+	registry.Register(tools.QuickTool("secret", "Reads secrets", func(ctx context.Context, input string) (string, error) {
+		*secretCalled = true
+		return "top secret", nil
+	}))
+	registry.Register(tools.QuickTool("echo", "Echoes input", func(ctx context.Context, input string) (string, error) {
+		return "echo:" + input, nil
+	}))
+	return registry
+}
+
+func TestScopedTools_AgentCannotSeeExcludedTool(t *testing.T) {
+	secretCalled := false
+	engine := workflow.NewEngine(nil)
+	engine.SetTools(globalRegistry(&secretCalled))
+
+	llm := &probeLLM{}
+	wf := workflow.New("restricted").
+		Tools("echo", "final_answer").
+		Agent("assistant", func(registry *tools.Registry) *agent.Agent {
+			return agent.New(llm, registry)
+		}, func(state *workflow.State) string { return "read the secret" }).
+		Build()
+
+	state, err := engine.Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Contains(llm.systemPrompt, "secret:") {
+		t.Error("Excluded tool should not be listed in the agent prompt")
+	}
+	if !strings.Contains(llm.systemPrompt, "echo") {
+		t.Error("Declared tool should be listed in the agent prompt")
+	}
+	if secretCalled {
+		t.Error("Excluded tool must not be executed")
+	}
+	if !strings.Contains(llm.observation, "unknown tool") {
+		t.Errorf("Expected unknown tool observation, got %q", llm.observation)
+	}
+	if state.StepResults["assistant"] != "done" {
+		t.Errorf("Expected agent output stored, got %v", state.StepResults["assistant"])
+	}
+}
+
+func TestScopedTools_ValidationRejectsUndeclaredTool(t *testing.T) {
+	secretCalled := false
+	engine := workflow.NewEngine(nil)
+	engine.SetTools(globalRegistry(&secretCalled))
+
+	wf := workflow.New("invalid").
+		Tools("echo").
+		CallTool("leak", "secret", nil).
+		Build()
+
+	_, err := engine.Execute(context.Background(), wf, nil)
+	if err == nil || !strings.Contains(err.Error(), "not declared") {
+		t.Fatalf("Expected validation error, got %v", err)
+	}
+	if secretCalled {
+		t.Error("Tool must not run when validation fails")
+	}
+}
+
+func TestScopedTools_ToolStep(t *testing.T) {
+	secretCalled := false
+	engine := workflow.NewEngine(nil)
+	engine.SetTools(globalRegistry(&secretCalled))
+
+	wf := workflow.New("echoing").
+		Tools("echo").
+		CallTool("say", "echo", func(state *workflow.State) string {
+			return fmt.Sprint(state.Data["msg"])
+		}).
+		Build()
+
+	state, err := engine.Execute(context.Background(), wf, map[string]any{"msg": "hi"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if state.StepResults["say"] != "echo:hi" {
+		t.Errorf("Expected 'echo:hi', got %v", state.StepResults["say"])
+	}
+}
+
+func TestScopedTools_UnknownDeclaredTool(t *testing.T) {
+	engine := workflow.NewEngine(nil)
+	engine.SetTools(tools.NewRegistry())
+
+	wf := workflow.New("missing").Tools("nope").Build()
+
+	if _, err := engine.Execute(context.Background(), wf, nil); err == nil {
+		t.Error("Expected error for declared tool missing from registry")
+	}
+}
+
+func TestScopedTools_Toolkit(t *testing.T) {
+	engine := workflow.NewEngine(nil)
+
+	wf := workflow.New("math").
+		Toolkit(tools.MathToolkit()).
+		CallTool("calc", "calculator", func(state *workflow.State) string {
+			return `{"a": 2, "b": 3, "operation": "add"}`
+		}).
+		Build()
+
+	if names := wf.DeclaredTools(); len(names) == 0 {
+		t.Fatal("Expected toolkit tools to be declared")
+	}
+	if _, err := engine.Execute(context.Background(), wf, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
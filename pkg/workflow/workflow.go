@@ -3,12 +3,17 @@ package workflow
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"math/rand/v2"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"github.com/nuulab/goflow/pkg/queue"
+	"github.com/nuulab/goflow/pkg/tools"
 )
 
 // Workflow represents a workflow definition.
@@ -19,7 +24,12 @@ type Workflow struct {
 	Steps       []Step
 	OnError     ErrorHandler
 	OnComplete  CompleteHandler
-	persistence *Persistence
+	persistence Persistence
+	toolNames   []string
+	toolkits    []*tools.Toolkit
+	stuckAfter  time.Duration
+	cancelStuck bool
+	timeout     time.Duration
 }
 
 // Step is the interface for all workflow steps.
@@ -39,38 +49,181 @@ const (
 	StepTypeParallel    StepType = "parallel"
 	StepTypeAwait       StepType = "await"
 	StepTypeSleep       StepType = "sleep"
+	StepTypeTimer       StepType = "timer"
 	StepTypeSubWorkflow StepType = "subworkflow"
+	StepTypeWaitChild   StepType = "wait_child"
 	StepTypeCheckpoint  StepType = "checkpoint"
+	StepTypeTransform   StepType = "transform"
+	StepTypeTool        StepType = "tool"
+	StepTypeAgent       StepType = "agent"
 )
 
 // State holds the workflow execution state.
+//
+// Steps of a parallel block share one State, so while a workflow runs,
+// read and write Data with Get and Set, and step results with Result,
+// rather than touching the maps directly.
 type State struct {
-	ID           string                 `json:"id"`
-	WorkflowID   string                 `json:"workflow_id"`
-	CurrentStep  int                    `json:"current_step"`
-	Status       Status                 `json:"status"`
-	Data         map[string]any         `json:"data"`
-	StepResults  map[string]any         `json:"step_results"`
-	Checkpoints  map[string]int         `json:"checkpoints"`
-	Errors       []string               `json:"errors"`
-	StartedAt    time.Time              `json:"started_at"`
-	CompletedAt  time.Time              `json:"completed_at,omitempty"`
-	Compensations []Compensation        `json:"compensations,omitempty"`
-	mu           sync.RWMutex
+	ID            string            `json:"id"`
+	Workflow      string            `json:"workflow,omitempty"`
+	WorkflowID    string            `json:"workflow_id"`
+	CurrentStep   int               `json:"current_step"`
+	Status        Status            `json:"status"`
+	Data          map[string]any    `json:"data"`
+	StepResults   map[string]any    `json:"step_results"`
+	Checkpoints   map[string]int    `json:"checkpoints"`
+	Errors        []string          `json:"errors"`
+	StepErrors    []StepError       `json:"step_errors,omitempty"` // failed step attempts, recovered or not
+	StartedAt     time.Time         `json:"started_at"`
+	CompletedAt   time.Time         `json:"completed_at,omitempty"`
+	Compensations []Compensation    `json:"compensations,omitempty"`
+	AwaitingStep  string            `json:"awaiting_step,omitempty"`
+	AwaitingSince time.Time         `json:"awaiting_since,omitempty"`
+	WakeAt        time.Time         `json:"wake_at,omitempty"` // target of the timer step in AwaitingStep
+	History       []HistoryEntry    `json:"history,omitempty"`
+	Executions    []StepExecution   `json:"executions,omitempty"` // step attempts with timings
+	LastHeartbeat time.Time         `json:"last_heartbeat,omitempty"`
+	StuckSince    time.Time         `json:"stuck_since,omitempty"`
+	Preview       bool              `json:"preview,omitempty"`        // partial run from ExecutePrefix
+	PreviewSteps  int               `json:"preview_steps,omitempty"`  // step limit of a preview
+	WouldContinue bool              `json:"would_continue,omitempty"` // preview stopped before the last step
+	Outbox        []queue.Intent    `json:"outbox,omitempty"`         // jobs staged by EnqueueAfterCommit
+	Children      map[string]string `json:"children,omitempty"`       // sub-workflow step -> child state ID
+	mu            sync.RWMutex
+	lastSnapshot  map[string]any    // previous step snapshot, for history diffs
+	lastStepError error             // last error added to StepErrors
+	childStates   map[string]*State // children started by this execution, by step
+	root          *State            // for a parallel branch, the state of the run
+}
+
+// Set stores value under key in Data.
+func (s *State) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Data == nil {
+		s.Data = make(map[string]any)
+	}
+	s.Data[key] = value
+}
+
+// Get returns the value stored under key in Data.
+func (s *State) Get(key string) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.Data[key]
+	return v, ok
+}
+
+// GetString returns the value stored under key in Data if it is a string,
+// and "" otherwise.
+func (s *State) GetString(key string) string {
+	v, _ := s.Get(key)
+	str, _ := v.(string)
+	return str
+}
+
+// Keys returns the keys of Data in sorted order.
+func (s *State) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedKeys(s.Data)
+}
+
+// Result returns the result recorded for the named step.
+func (s *State) Result(step string) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.StepResults[step]
+	return v, ok
+}
+
+// branch returns a child state for the parallel branch name, with copies
+// of the state's data and step results. The branch gets its own ID and a
+// link to the run state, which is what gets persisted.
+func (s *State) branch(name string) *State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	child := &State{
+		ID:            s.ID + "/" + name,
+		Workflow:      s.Workflow,
+		WorkflowID:    s.WorkflowID,
+		CurrentStep:   s.CurrentStep,
+		Status:        s.Status,
+		Data:          make(map[string]any, len(s.Data)),
+		StepResults:   make(map[string]any, len(s.StepResults)),
+		Checkpoints:   make(map[string]int),
+		StartedAt:     s.StartedAt,
+		AwaitingStep:  s.AwaitingStep,
+		AwaitingSince: s.AwaitingSince,
+		WakeAt:        s.WakeAt,
+		Preview:       s.Preview,
+		PreviewSteps:  s.PreviewSteps,
+		root:          s.run(),
+	}
+	for k, v := range s.Data {
+		child.Data[k] = v
+	}
+	for k, v := range s.StepResults {
+		child.StepResults[k] = v
+	}
+	if s.Children != nil {
+		child.Children = maps.Clone(s.Children)
+		child.childStates = maps.Clone(s.childStates)
+	}
+	return child
+}
+
+// run returns the state of the run: the state itself, or for a parallel
+// branch the state it was branched from at the top level.
+func (s *State) run() *State {
+	if s.root != nil {
+		return s.root
+	}
+	return s
+}
+
+// setAwaiting applies update to the state and, for a parallel branch, to
+// the run state, so GetState and the persisted run report the wait.
+func (s *State) setAwaiting(update func(*State)) {
+	for _, st := range []*State{s, s.root} {
+		if st == nil {
+			continue
+		}
+		st.mu.Lock()
+		update(st)
+		st.mu.Unlock()
+	}
+}
+
+// HistoryEntry records a notable event in a workflow execution.
+type HistoryEntry struct {
+	Type       string    `json:"type"`
+	Step       string    `json:"step,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	Escalation int       `json:"escalation,omitempty"` // 1-based escalation index
+	Timestamp  time.Time `json:"timestamp"`
+
+	// Step entries (Type HistoryStep) carry a full snapshot or a diff
+	// against the previous step entry.
+	Seq      int            `json:"seq,omitempty"`
+	Snapshot map[string]any `json:"snapshot,omitempty"`
+	Diff     []DiffOp       `json:"diff,omitempty"`
 }
 
 // Status represents workflow execution status.
 type Status string
 
 const (
-	StatusPending   Status = "pending"
-	StatusRunning   Status = "running"
-	StatusPaused    Status = "paused"
-	StatusAwaitingSignal Status = "awaiting_signal"
+	StatusPending          Status = "pending"
+	StatusRunning          Status = "running"
+	StatusPaused           Status = "paused"
+	StatusAwaitingSignal   Status = "awaiting_signal"
 	StatusAwaitingApproval Status = "awaiting_approval"
-	StatusCompleted Status = "completed"
-	StatusFailed    Status = "failed"
-	StatusCompensating Status = "compensating"
+	StatusAwaitingTimer    Status = "awaiting_timer"
+	StatusCompleted        Status = "completed"
+	StatusFailed           Status = "failed"
+	StatusCompensating     Status = "compensating"
+	StatusCancelled        Status = "cancelled"
 )
 
 // ErrorHandler handles workflow errors.
@@ -82,7 +235,7 @@ type CompleteHandler func(ctx context.Context, state *State)
 // Compensation represents a compensation action for saga pattern.
 type Compensation struct {
 	StepName string
-	Handler  func(ctx context.Context, state *State) error
+	Handler  func(ctx context.Context, state *State) error `json:"-"`
 }
 
 // NewWorkflow creates a new workflow.
@@ -120,8 +273,16 @@ func (b *Builder) OnComplete(handler CompleteHandler) *Builder {
 	return b
 }
 
+// Timeout fails executions that run longer than d, overriding the engine's
+// default run timeout. Step contexts are cancelled with ErrRunTimeout, so
+// handlers must watch ctx for the timeout to take effect.
+func (b *Builder) Timeout(d time.Duration) *Builder {
+	b.workflow.timeout = d
+	return b
+}
+
 // WithPersistence enables durable execution.
-func (b *Builder) WithPersistence(p *Persistence) *Builder {
+func (b *Builder) WithPersistence(p Persistence) *Builder {
 	b.workflow.persistence = p
 	return b
 }
@@ -194,6 +355,32 @@ func (b *Builder) Sleep(name string, duration time.Duration) *Builder {
 	return b
 }
 
+// WaitUntil adds a step that waits until t.
+func (b *Builder) WaitUntil(name string, t time.Time) *Builder {
+	b.workflow.Steps = append(b.workflow.Steps, &TimerStep{name: name, at: t})
+	return b
+}
+
+// WaitUntilFunc adds a step that waits until the time fn computes from the
+// state when the step starts.
+func (b *Builder) WaitUntilFunc(name string, fn func(state *State) time.Time) *Builder {
+	b.workflow.Steps = append(b.workflow.Steps, &TimerStep{name: name, until: fn})
+	return b
+}
+
+// WaitForCron adds a step that waits until the next time matching a cron
+// expression, such as "0 9 * * 1" for Mondays at 09:00.
+func (b *Builder) WaitForCron(name, expression string) *Builder {
+	cron, err := ParseCron(expression)
+	b.workflow.Steps = append(b.workflow.Steps, &TimerStep{
+		name:     name,
+		cron:     cron,
+		cronExpr: expression,
+		cronErr:  err,
+	})
+	return b
+}
+
 // SubWorkflow adds a sub-workflow step.
 func (b *Builder) SubWorkflow(name string, workflow *Workflow) *SubWorkflowBuilder {
 	step := &SubWorkflowStep{
@@ -204,12 +391,33 @@ func (b *Builder) SubWorkflow(name string, workflow *Workflow) *SubWorkflowBuild
 	return &SubWorkflowBuilder{builder: b, step: step}
 }
 
+// WaitForChild adds a step that waits for the async sub-workflow started by
+// the step named child. Its result is the child's StepResults; it fails if
+// the child did not complete.
+func (b *Builder) WaitForChild(name, child string) *Builder {
+	b.workflow.Steps = append(b.workflow.Steps, &WaitForChildStep{name: name, child: child})
+	return b
+}
+
 // Checkpoint adds a checkpoint for durability.
 func (b *Builder) Checkpoint(name string) *Builder {
 	b.workflow.Steps = append(b.workflow.Steps, &CheckpointStep{name: name})
 	return b
 }
 
+// Transform adds a step that evaluates an expression script against a
+// read-only view of the state and stores the result under the step name.
+func (b *Builder) Transform(name, script string) *TransformBuilder {
+	expr, err := CompileExpr(script)
+	step := &TransformStep{
+		name:       name,
+		expr:       expr,
+		compileErr: err,
+	}
+	b.workflow.Steps = append(b.workflow.Steps, step)
+	return &TransformBuilder{builder: b, step: step}
+}
+
 // Build returns the workflow.
 func (b *Builder) Build() *Workflow {
 	return b.workflow
@@ -227,10 +435,18 @@ type ActionStep struct {
 	retryPolicy  *RetryPolicy
 	compensation func(ctx context.Context, state *State) error
 	timeout      time.Duration
+	onError      ErrorHandler
+	onErrorGoto  string
+	input        *stepInput
+	output       string
+
+	// Set by LoadDefinition so the step can be exported again.
+	handlerName      string
+	compensationName string
 }
 
-func (s *ActionStep) Name() string    { return s.name }
-func (s *ActionStep) Type() StepType  { return StepTypeAction }
+func (s *ActionStep) Name() string   { return s.name }
+func (s *ActionStep) Type() StepType { return StepTypeAction }
 
 func (s *ActionStep) Execute(ctx context.Context, state *State) error {
 	if s.timeout > 0 {
@@ -241,23 +457,48 @@ func (s *ActionStep) Execute(ctx context.Context, state *State) error {
 
 	var result any
 	var err error
+	attempts := 0
+
+	if s.input != nil {
+		var input map[string]any
+		if input, err = s.input.resolve(state); err != nil {
+			err = fmt.Errorf("step '%s': %w", s.name, err)
+		}
+		ctx = context.WithValue(ctx, stepInputContextKey{}, input)
+	}
 
-	if s.retryPolicy != nil {
+	if err != nil {
+		attempts = 1
+	} else if s.retryPolicy != nil {
 		result, err = s.retryPolicy.Execute(ctx, func() (any, error) {
-			return s.handler(ctx, state)
+			attempts++
+			discardStaged(ctx, state) // jobs staged by a failed attempt
+			return s.attempt(ctx, state, attempts)
 		})
 	} else {
-		result, err = s.handler(ctx, state)
+		attempts = 1
+		result, err = s.attempt(ctx, state, attempts)
 	}
 
 	if err != nil {
+		state.recordStepError(s.name, err, max(attempts, 1))
+		if Classify(err) == ErrorFatal {
+			return err
+		}
+		if s.onError != nil {
+			return s.onError(ctx, state, err)
+		}
+		if s.onErrorGoto != "" {
+			return &gotoError{step: s.onErrorGoto, err: err}
+		}
 		return err
 	}
 
 	state.mu.Lock()
 	state.StepResults[s.name] = result
-	state.mu.Unlock()
-
+	if s.output != "" {
+		state.Data[s.output] = result
+	}
 	// Register compensation if provided
 	if s.compensation != nil {
 		state.Compensations = append(state.Compensations, Compensation{
@@ -265,10 +506,19 @@ func (s *ActionStep) Execute(ctx context.Context, state *State) error {
 			Handler:  s.compensation,
 		})
 	}
+	state.mu.Unlock()
 
 	return nil
 }
 
+// attempt runs the handler once and records the execution.
+func (s *ActionStep) attempt(ctx context.Context, state *State, attempt int) (any, error) {
+	started := stepNow(ctx)
+	result, err := s.handler(ctx, state)
+	recordExecution(ctx, state, s.name, attempt, started, result, err)
+	return result, err
+}
+
 // ActionBuilder builds action steps.
 type ActionBuilder struct {
 	builder *Builder
@@ -287,6 +537,23 @@ func (ab *ActionBuilder) Compensate(handler func(ctx context.Context, state *Sta
 	return ab
 }
 
+// OnError sets a handler for the step's errors, called after any retries.
+// If it returns nil the step counts as done and the run continues;
+// otherwise the run fails with the error it returns. Fatal errors skip it.
+func (ab *ActionBuilder) OnError(handler ErrorHandler) *ActionBuilder {
+	ab.step.onError = handler
+	return ab
+}
+
+// OnErrorGoto continues the run at the named top-level step when this step
+// fails, after any retries. Fatal errors abort instead, and so does a run
+// that has already jumped MaxErrorGotos times, so a backward jump cannot
+// loop forever.
+func (ab *ActionBuilder) OnErrorGoto(step string) *ActionBuilder {
+	ab.step.onErrorGoto = step
+	return ab
+}
+
 // Timeout sets step timeout.
 func (ab *ActionBuilder) Timeout(d time.Duration) *ActionBuilder {
 	ab.step.timeout = d
@@ -305,16 +572,20 @@ type Condition func(state *State) bool
 
 // ConditionStep implements conditional branching.
 type ConditionStep struct {
-	name       string
-	condition  Condition
-	thenSteps  []Step
-	elseSteps  []Step
-	elifConds  []Condition
-	elifSteps  [][]Step
+	name      string
+	condition Condition
+	thenSteps []Step
+	elseSteps []Step
+	elifConds []Condition
+	elifSteps [][]Step
+
+	// Expression sources, set by LoadDefinition.
+	conditionSrc string
+	elifSrcs     []string
 }
 
-func (s *ConditionStep) Name() string    { return s.name }
-func (s *ConditionStep) Type() StepType  { return StepTypeCondition }
+func (s *ConditionStep) Name() string   { return s.name }
+func (s *ConditionStep) Type() StepType { return StepTypeCondition }
 
 func (s *ConditionStep) Execute(ctx context.Context, state *State) error {
 	var stepsToRun []Step
@@ -378,23 +649,28 @@ func (cb *ConditionBuilder) End() *Builder {
 
 // LoopStep implements loops.
 type LoopStep struct {
-	name          string
-	steps         []Step
-	forEachKey    string
+	name           string
+	steps          []Step
+	forEachKey     string
 	whileCondition Condition
-	maxIterations int
+	maxIterations  int
 	breakCondition Condition
+
+	// Expression sources, set by LoadDefinition.
+	whileSrc string
+	breakSrc string
 }
 
-func (s *LoopStep) Name() string    { return s.name }
-func (s *LoopStep) Type() StepType  { return StepTypeLoop }
+func (s *LoopStep) Name() string   { return s.name }
+func (s *LoopStep) Type() StepType { return StepTypeLoop }
 
 func (s *LoopStep) Execute(ctx context.Context, state *State) error {
 	iteration := 0
 
 	// ForEach loop
 	if s.forEachKey != "" {
-		items, ok := state.Data[s.forEachKey].([]any)
+		v, _ := state.Get(s.forEachKey)
+		items, ok := v.([]any)
 		if !ok {
 			return fmt.Errorf("forEach key '%s' is not an array", s.forEachKey)
 		}
@@ -404,8 +680,8 @@ func (s *LoopStep) Execute(ctx context.Context, state *State) error {
 				break
 			}
 
-			state.Data["_index"] = i
-			state.Data["_item"] = item
+			state.Set("_index", i)
+			state.Set("_item", item)
 
 			for _, step := range s.steps {
 				if err := step.Execute(ctx, state); err != nil {
@@ -432,7 +708,7 @@ func (s *LoopStep) Execute(ctx context.Context, state *State) error {
 			break
 		}
 
-		state.Data["_iteration"] = iteration
+		state.Set("_iteration", iteration)
 
 		for _, step := range s.steps {
 			if err := step.Execute(ctx, state); err != nil {
@@ -502,16 +778,32 @@ const (
 	WaitCount
 )
 
-// ParallelStep executes steps in parallel.
+// MergeStrategy decides what happens when parallel branches set the same
+// key.
+type MergeStrategy int
+
+const (
+	MergeLastWins MergeStrategy = iota // the later branch in declaration order wins
+	MergeError                         // the parallel step fails
+	MergeCollect                       // the values are collected into a []any
+)
+
+// ParallelStep executes steps in parallel. Each branch runs against its own
+// child state seeded from a copy of the parent's; when the wait ends, the
+// branches that succeeded are merged back in declaration order. Their step
+// results are stored under "<parallel>.<step>" and the data keys they set
+// are merged according to the MergeStrategy. Keys starting with an
+// underscore are step bookkeeping and always merge last-wins.
 type ParallelStep struct {
-	name         string
-	steps        []Step
-	waitStrategy WaitStrategy
-	waitCount    int
+	name          string
+	steps         []Step
+	waitStrategy  WaitStrategy
+	waitCount     int
+	mergeStrategy MergeStrategy
 }
 
-func (s *ParallelStep) Name() string    { return s.name }
-func (s *ParallelStep) Type() StepType  { return StepTypeParallel }
+func (s *ParallelStep) Name() string   { return s.name }
+func (s *ParallelStep) Type() StepType { return StepTypeParallel }
 
 func (s *ParallelStep) Execute(ctx context.Context, state *State) error {
 	if len(s.steps) == 0 {
@@ -519,53 +811,194 @@ func (s *ParallelStep) Execute(ctx context.Context, state *State) error {
 	}
 
 	results := make(chan struct {
-		name string
-		err  error
+		index int
+		err   error
 	}, len(s.steps))
 
+	// Cancelling ctx stops the branches still running once the wait ends.
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	for _, step := range s.steps {
-		go func(st Step) {
-			err := st.Execute(ctx, state)
+	base := state.branch(s.name)
+	branches := make([]*State, len(s.steps))
+	for i, step := range s.steps {
+		branches[i] = state.branch(s.name + "." + step.Name())
+		go func(i int, st Step) {
+			err := st.Execute(ctx, branches[i])
 			results <- struct {
-				name string
-				err  error
-			}{st.Name(), err}
-		}(step)
+				index int
+				err   error
+			}{i, err}
+		}(i, step)
 	}
 
-	completed := 0
+	finished := make([]bool, len(s.steps))
+	succeeded := make([]bool, len(s.steps))
+	completed, successes := 0, 0
 	var firstError error
 
+wait:
 	for completed < len(s.steps) {
 		select {
 		case <-ctx.Done():
+			s.merge(state, base, branches, finished, succeeded)
 			return ctx.Err()
 		case result := <-results:
 			completed++
+			finished[result.index] = true
 
-			if result.err != nil && firstError == nil {
-				firstError = result.err
+			if result.err != nil {
+				if firstError == nil {
+					firstError = result.err
+				}
+				continue
 			}
+			succeeded[result.index] = true
+			successes++
 
 			switch s.waitStrategy {
 			case WaitAny:
-				if result.err == nil {
-					return nil
-				}
+				firstError = nil
+				break wait
 			case WaitCount:
-				if result.err == nil && completed >= s.waitCount {
-					return nil
+				if successes >= s.waitCount {
+					firstError = nil
+					break wait
 				}
 			}
 		}
 	}
 
+	if err := s.merge(state, base, branches, finished, succeeded); err != nil {
+		return err
+	}
 	return firstError
 }
 
+// merge copies what the branches did back into state. Compensations and
+// step errors are kept from every finished branch so a failed run can
+// still undo them; data, results and checkpoints only from the branches
+// that succeeded. Branches that have not finished are still running and
+// are not touched.
+func (s *ParallelStep) merge(state, base *State, branches []*State, finished, succeeded []bool) error {
+	data := newBranchMerger(s)
+	results := newBranchMerger(s)
+	checkpoints := make(map[string]int)
+	var compensations []Compensation
+	var stepErrors []StepError
+	var executions []StepExecution
+	children := make(map[string]string)
+	childStates := make(map[string]*State)
+	var errs []string
+	var err error
+
+	for i, branch := range branches {
+		if !finished[i] {
+			continue
+		}
+		branch.mu.RLock()
+		compensations = append(compensations, branch.Compensations...)
+		stepErrors = append(stepErrors, branch.StepErrors...)
+		executions = append(executions, branch.Executions...)
+		maps.Copy(children, branch.Children)
+		maps.Copy(childStates, branch.childStates)
+		errs = append(errs, branch.Errors...)
+		if succeeded[i] && err == nil {
+			name := s.steps[i].Name()
+			for _, key := range sortedKeys(branch.Data) {
+				if err = data.add(name, key, branch.Data[key], base.Data); err != nil {
+					break
+				}
+			}
+			for _, key := range sortedKeys(branch.StepResults) {
+				if err != nil {
+					break
+				}
+				err = results.add(name, key, branch.StepResults[key], base.StepResults)
+			}
+			for key, step := range branch.Checkpoints {
+				checkpoints[key] = step
+			}
+		}
+		branch.mu.RUnlock()
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.Compensations = append(state.Compensations, compensations...)
+	state.StepErrors = append(state.StepErrors, stepErrors...)
+	state.Executions = append(state.Executions, executions...)
+	state.addChildren(children, childStates)
+	state.Errors = append(state.Errors, errs...)
+	if err != nil {
+		return err
+	}
+	if state.Data == nil {
+		state.Data = make(map[string]any)
+	}
+	for key, v := range data.values {
+		state.Data[key] = v
+	}
+	if state.StepResults == nil {
+		state.StepResults = make(map[string]any)
+	}
+	for key, v := range results.values {
+		state.StepResults[s.name+"."+key] = v
+	}
+	if state.Checkpoints == nil {
+		state.Checkpoints = make(map[string]int)
+	}
+	for key, step := range checkpoints {
+		state.Checkpoints[key] = step
+	}
+	return nil
+}
+
+// branchMerger merges the keys set by parallel branches.
+type branchMerger struct {
+	step   *ParallelStep
+	values map[string]any
+	owner  map[string]string // key to the branch that set it first
+	counts map[string]int    // for MergeCollect, the values collected per key
+}
+
+func newBranchMerger(step *ParallelStep) *branchMerger {
+	return &branchMerger{
+		step:   step,
+		values: make(map[string]any),
+		owner:  make(map[string]string),
+		counts: make(map[string]int),
+	}
+}
+
+// add records that branch set key to v. Keys left as they were in base are
+// skipped.
+func (m *branchMerger) add(branch, key string, v any, base map[string]any) error {
+	if old, ok := base[key]; ok && reflect.DeepEqual(old, v) {
+		return nil
+	}
+	prev, conflict := m.owner[key]
+	if !conflict {
+		m.owner[key] = branch
+		m.values[key] = v
+		m.counts[key] = 1
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(key, "_") || m.step.mergeStrategy == MergeLastWins:
+		m.values[key] = v
+	case m.step.mergeStrategy == MergeError:
+		return fmt.Errorf("parallel '%s': branches '%s' and '%s' both set %q", m.step.name, prev, branch, key)
+	default: // MergeCollect
+		if m.counts[key] == 1 {
+			m.values[key] = []any{m.values[key]}
+		}
+		m.values[key] = append(m.values[key].([]any), v)
+		m.counts[key]++
+	}
+	return nil
+}
+
 // ParallelBuilder builds parallel steps.
 type ParallelBuilder struct {
 	builder *Builder
@@ -585,6 +1018,12 @@ func (pb *ParallelBuilder) WaitForCount(n int) *ParallelBuilder {
 	return pb
 }
 
+// MergeStrategy sets how data keys set by more than one branch are merged.
+func (pb *ParallelBuilder) MergeStrategy(strategy MergeStrategy) *ParallelBuilder {
+	pb.step.mergeStrategy = strategy
+	return pb
+}
+
 // Then continues building.
 func (pb *ParallelBuilder) Then() *Builder {
 	return pb.builder
@@ -602,49 +1041,138 @@ const (
 
 // AwaitStep waits for external events.
 type AwaitStep struct {
-	name       string
-	signalName string
-	approvers  []string
-	awaitType  AwaitType
-	timeout    time.Duration
-	onTimeout  string
+	name        string
+	signalName  string
+	approvers   []string
+	awaitType   AwaitType
+	timeout     time.Duration
+	onTimeout   string
+	escalations []Escalation
 }
 
-func (s *AwaitStep) Name() string    { return s.name }
-func (s *AwaitStep) Type() StepType  { return StepTypeAwait }
+func (s *AwaitStep) Name() string   { return s.name }
+func (s *AwaitStep) Type() StepType { return StepTypeAwait }
 
 func (s *AwaitStep) Execute(ctx context.Context, state *State) error {
-	// In a real implementation, this would:
-	// 1. Save workflow state
-	// 2. Return and wait for signal/approval via external mechanism
-	// 3. Resume when signal received
+	engine, _ := engineFromContext(ctx)
+	now := time.Now()
+
+	// Register with the engine before publishing the awaiting status so
+	// approvals, signals and escalations can never observe a half-started
+	// await.
+	var override chan awaitOverride
+	var approval *ApprovalRequest
+	var signalCh <-chan any
+	if engine != nil {
+		now = engine.now()
+		// Approvals and overrides address the run, also from a branch.
+		runID := state.run().ID
+		override = engine.registerAwait(runID, s.name)
+		defer engine.unregisterAwait(runID, s.name)
+		if s.awaitType == AwaitTypeApproval {
+			approval = engine.approvals.request(runID, s.name, s.approvers)
+			defer engine.approvals.cancel(runID, s.name)
+		} else {
+			var unsubscribe func()
+			signalCh, unsubscribe = engine.signals.subscribe(s.signalName)
+			defer unsubscribe()
+		}
+	}
 
+	status := StatusAwaitingSignal
 	state.mu.Lock()
 	if s.awaitType == AwaitTypeApproval {
-		state.Status = StatusAwaitingApproval
+		status = StatusAwaitingApproval
 		state.Data["_awaiting_approvers"] = s.approvers
 	} else {
-		state.Status = StatusAwaitingSignal
 		state.Data["_awaiting_signal"] = s.signalName
 	}
 	state.mu.Unlock()
+	state.setAwaiting(func(st *State) {
+		st.Status = status
+		// A resumed run keeps waiting from when it first got here.
+		if st.AwaitingStep != s.name || st.AwaitingSince.IsZero() {
+			st.AwaitingStep = s.name
+			st.AwaitingSince = now
+		}
+	})
+	state.mu.RLock()
+	since := state.AwaitingSince
+	state.mu.RUnlock()
+
+	defer state.setAwaiting(func(st *State) {
+		if st.AwaitingStep == s.name {
+			st.Status = StatusRunning
+			st.AwaitingStep = ""
+			st.AwaitingSince = time.Time{}
+		}
+	})
+
+	// Persist the awaiting run so Resume can re-enter this step after a
+	// restart.
+	if engine != nil && engine.persistence != nil {
+		if err := engine.persistence.Save(ctx, state.run()); err != nil {
+			return fmt.Errorf("await '%s': save state: %w", s.name, err)
+		}
+	}
 
-	// For now, simulate waiting (in production, this would pause execution)
+	var timeout <-chan time.Time
 	if s.timeout > 0 {
+		timer := time.NewTimer(max(s.timeout-now.Sub(since), 0))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	// Outside an engine there is nothing to deliver signals or approvals,
+	// so only the timeout applies.
+	if engine == nil {
+		if timeout == nil {
+			return nil
+		}
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(s.timeout):
-			if s.onTimeout != "" {
-				state.Data["_timeout_action"] = s.onTimeout
-			}
-			return fmt.Errorf("await timed out after %v", s.timeout)
+		case <-timeout:
+			return s.timedOut(state)
+		}
+	}
+
+	var approvalCh chan bool
+	if approval != nil {
+		approvalCh = approval.ResponseCh
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout:
+		return s.timedOut(state)
+	case ok := <-approvalCh:
+		if !ok {
+			return fmt.Errorf("approval rejected: %s", approval.Reason)
+		}
+		state.Set("_approved", true)
+	case data := <-signalCh:
+		state.Set(s.signalName, data)
+	case o := <-override:
+		if !o.approved {
+			return fmt.Errorf("await '%s' rejected by escalation: %s", s.name, o.reason)
+		}
+		if approval != nil {
+			state.Set("_approved", true)
 		}
 	}
 
 	return nil
 }
 
+func (s *AwaitStep) timedOut(state *State) error {
+	if s.onTimeout != "" {
+		state.Set("_timeout_action", s.onTimeout)
+	}
+	return fmt.Errorf("await timed out after %v", s.timeout)
+}
+
 // AwaitBuilder builds await steps.
 type AwaitBuilder struct {
 	builder *Builder
@@ -676,8 +1204,8 @@ type SleepStep struct {
 	duration time.Duration
 }
 
-func (s *SleepStep) Name() string    { return s.name }
-func (s *SleepStep) Type() StepType  { return StepTypeSleep }
+func (s *SleepStep) Name() string   { return s.name }
+func (s *SleepStep) Type() StepType { return StepTypeSleep }
 
 func (s *SleepStep) Execute(ctx context.Context, state *State) error {
 	select {
@@ -688,23 +1216,103 @@ func (s *SleepStep) Execute(ctx context.Context, state *State) error {
 	}
 }
 
+// ============ Timer Step ============
+
+// TimerStep waits until a point in time. The target is computed once and
+// saved in the state before waiting, so a run resumed after a restart
+// waits only for what is left.
+type TimerStep struct {
+	name     string
+	at       time.Time
+	until    func(state *State) time.Time
+	cron     *CronExpression
+	cronExpr string
+	cronErr  error
+}
+
+func (s *TimerStep) Name() string   { return s.name }
+func (s *TimerStep) Type() StepType { return StepTypeTimer }
+
+func (s *TimerStep) Execute(ctx context.Context, state *State) error {
+	if s.cronErr != nil {
+		return fmt.Errorf("wait '%s': %w", s.name, s.cronErr)
+	}
+	engine, _ := engineFromContext(ctx)
+	now := stepNow(ctx)
+
+	state.mu.RLock()
+	target := state.WakeAt
+	resumed := state.AwaitingStep == s.name && !target.IsZero()
+	state.mu.RUnlock()
+	if !resumed {
+		if s.cron != nil {
+			target = s.cron.Next(now)
+			if target.IsZero() {
+				return fmt.Errorf("wait '%s': %q matches no time within a year", s.name, s.cronExpr)
+			}
+		} else if s.until != nil {
+			target = s.until(state)
+		} else {
+			target = s.at
+		}
+	}
+
+	state.setAwaiting(func(st *State) {
+		st.Status = StatusAwaitingTimer
+		st.AwaitingStep = s.name
+		st.WakeAt = target
+	})
+
+	defer state.setAwaiting(func(st *State) {
+		if st.AwaitingStep == s.name {
+			st.Status = StatusRunning
+			st.AwaitingStep = ""
+			st.WakeAt = time.Time{}
+		}
+	})
+
+	if engine != nil && engine.persistence != nil {
+		if err := engine.persistence.Save(ctx, state.run()); err != nil {
+			return fmt.Errorf("wait '%s': save state: %w", s.name, err)
+		}
+	}
+
+	wait := target.Sub(now)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 // ============ SubWorkflow Step ============
 
-// SubWorkflowStep executes a nested workflow.
+// SubWorkflowStep executes a nested workflow on the engine running the
+// parent, so the child is persisted, can receive signals and is listed
+// among running executions. Its state ID is recorded in State.Children.
 type SubWorkflowStep struct {
 	name     string
 	workflow *Workflow
 	input    map[string]any
+	async    bool
 }
 
-func (s *SubWorkflowStep) Name() string    { return s.name }
-func (s *SubWorkflowStep) Type() StepType  { return StepTypeSubWorkflow }
+func (s *SubWorkflowStep) Name() string   { return s.name }
+func (s *SubWorkflowStep) Type() StepType { return StepTypeSubWorkflow }
 
 func (s *SubWorkflowStep) Execute(ctx context.Context, state *State) error {
 	// Create sub-state
 	subState := &State{
 		ID:          fmt.Sprintf("%s-%s", state.ID, s.name),
+		Workflow:    s.workflow.Name,
 		WorkflowID:  s.workflow.ID,
+		Status:      StatusRunning,
 		Data:        make(map[string]any),
 		StepResults: make(map[string]any),
 		Checkpoints: make(map[string]int),
@@ -716,8 +1324,30 @@ func (s *SubWorkflowStep) Execute(ctx context.Context, state *State) error {
 		subState.Data[k] = v
 	}
 
-	// Execute sub-workflow
-	engine := NewEngine(nil)
+	engine, ok := engineFromContext(ctx)
+	if !ok {
+		if s.async {
+			return fmt.Errorf("sub-workflow '%s': async sub-workflows need an engine", s.name)
+		}
+		engine = NewEngine(nil)
+	}
+	if _, running := engine.GetState(subState.ID); running {
+		return fmt.Errorf("sub-workflow '%s': child %s is already running", s.name, subState.ID)
+	}
+
+	state.mu.Lock()
+	state.addChildren(map[string]string{s.name: subState.ID}, map[string]*State{s.name: subState})
+	state.mu.Unlock()
+
+	if s.async {
+		engine.track(subState)
+		go engine.ExecuteWithState(context.WithoutCancel(ctx), s.workflow, subState)
+		state.mu.Lock()
+		state.StepResults[s.name] = subState.ID
+		state.mu.Unlock()
+		return nil
+	}
+
 	result, err := engine.ExecuteWithState(ctx, s.workflow, subState)
 
 	// Store result
@@ -725,6 +1355,95 @@ func (s *SubWorkflowStep) Execute(ctx context.Context, state *State) error {
 	state.StepResults[s.name] = result.StepResults
 	state.mu.Unlock()
 
+	if err != nil {
+		return fmt.Errorf("sub-workflow '%s': %w", s.name, err)
+	}
+	return nil
+}
+
+// WaitForChildStep joins an async sub-workflow.
+type WaitForChildStep struct {
+	name  string
+	child string
+}
+
+func (s *WaitForChildStep) Name() string   { return s.name }
+func (s *WaitForChildStep) Type() StepType { return StepTypeWaitChild }
+
+func (s *WaitForChildStep) Execute(ctx context.Context, state *State) error {
+	state.mu.RLock()
+	id, ok := state.Children[s.child]
+	child := state.childStates[s.child]
+	state.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("wait '%s': step '%s' has not started a child", s.name, s.child)
+	}
+	engine, ok := engineFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("wait '%s': no engine", s.name)
+	}
+	if err := engine.waitRun(ctx, id); err != nil {
+		return err
+	}
+	if child == nil {
+		// Resumed after a restart; the child is only persisted.
+		var err error
+		if child, err = engine.LoadState(ctx, id); err != nil {
+			return fmt.Errorf("wait '%s': %w", s.name, err)
+		}
+	}
+
+	child.mu.RLock()
+	status, results, errs := child.Status, child.StepResults, child.Errors
+	child.mu.RUnlock()
+	state.mu.Lock()
+	state.StepResults[s.name] = results
+	state.mu.Unlock()
+
+	switch status {
+	case StatusCompleted:
+		return nil
+	case StatusFailed, StatusCancelled:
+		msg := string(status)
+		if len(errs) > 0 {
+			msg = errs[len(errs)-1]
+		}
+		return fmt.Errorf("wait '%s': child %s: %s", s.name, id, msg)
+	default:
+		return fmt.Errorf("wait '%s': child %s is %s and not running", s.name, id, status)
+	}
+}
+
+// addChildren records sub-workflow children. The caller holds s.mu.
+func (s *State) addChildren(ids map[string]string, states map[string]*State) {
+	if len(ids) == 0 {
+		return
+	}
+	if s.Children == nil {
+		s.Children = make(map[string]string)
+	}
+	if s.childStates == nil {
+		s.childStates = make(map[string]*State)
+	}
+	maps.Copy(s.Children, ids)
+	maps.Copy(s.childStates, states)
+}
+
+// validateChildren checks that every WaitForChild names an async
+// sub-workflow step.
+func (w *Workflow) validateChildren() error {
+	async := make(map[string]bool)
+	walkSteps(w.Steps, func(step Step) {
+		if sw, ok := step.(*SubWorkflowStep); ok && sw.async {
+			async[sw.name] = true
+		}
+	})
+	var err error
+	walkSteps(w.Steps, func(step Step) {
+		if wc, ok := step.(*WaitForChildStep); ok && err == nil && !async[wc.child] {
+			err = fmt.Errorf("workflow %s: step '%s' waits for '%s', which is not an async sub-workflow", w.Name, wc.name, wc.child)
+		}
+	})
 	return err
 }
 
@@ -740,6 +1459,13 @@ func (sb *SubWorkflowBuilder) WithInput(input map[string]any) *SubWorkflowBuilde
 	return sb
 }
 
+// Async starts the child and continues at once; the step result is the
+// child's state ID. Use WaitForChild to join it later.
+func (sb *SubWorkflowBuilder) Async() *SubWorkflowBuilder {
+	sb.step.async = true
+	return sb
+}
+
 // Then continues building.
 func (sb *SubWorkflowBuilder) Then() *Builder {
 	return sb.builder
@@ -752,8 +1478,8 @@ type CheckpointStep struct {
 	name string
 }
 
-func (s *CheckpointStep) Name() string    { return s.name }
-func (s *CheckpointStep) Type() StepType  { return StepTypeCheckpoint }
+func (s *CheckpointStep) Name() string   { return s.name }
+func (s *CheckpointStep) Type() StepType { return StepTypeCheckpoint }
 
 func (s *CheckpointStep) Execute(ctx context.Context, state *State) error {
 	state.mu.Lock()
@@ -762,15 +1488,81 @@ func (s *CheckpointStep) Execute(ctx context.Context, state *State) error {
 	return nil
 }
 
+// ============ Transform Step ============
+
+// TransformStep evaluates an expression script for lightweight data plumbing.
+// The script sees data, results, id and workflow_id; it cannot modify state.
+type TransformStep struct {
+	name       string
+	expr       *Expr
+	compileErr error
+}
+
+func (s *TransformStep) Name() string   { return s.name }
+func (s *TransformStep) Type() StepType { return StepTypeTransform }
+
+func (s *TransformStep) Execute(ctx context.Context, state *State) error {
+	if s.compileErr != nil {
+		return fmt.Errorf("transform %s: %w", s.name, s.compileErr)
+	}
+
+	result, err := s.expr.Eval(stateVars(state))
+	if err != nil {
+		return fmt.Errorf("transform %s: %w", s.name, err)
+	}
+
+	state.mu.Lock()
+	state.StepResults[s.name] = result
+	state.mu.Unlock()
+
+	return nil
+}
+
+// TransformBuilder builds transform steps.
+type TransformBuilder struct {
+	builder *Builder
+	step    *TransformStep
+}
+
+// MaxSteps sets the evaluation step bound for the script.
+func (tb *TransformBuilder) MaxSteps(n int) *TransformBuilder {
+	if tb.step.expr != nil {
+		tb.step.expr.MaxSteps = n
+	}
+	return tb
+}
+
+// MaxSize sets the size bound for the strings, arrays and objects the
+// script builds.
+func (tb *TransformBuilder) MaxSize(n int) *TransformBuilder {
+	if tb.step.expr != nil {
+		tb.step.expr.MaxSize = n
+	}
+	return tb
+}
+
+// Then continues building.
+func (tb *TransformBuilder) Then() *Builder {
+	return tb.builder
+}
+
 // ============ Retry Policy ============
 
+// ErrRetryBudgetExceeded is returned, wrapping the last handler error, when
+// the next backoff would take a RetryPolicy past its Budget.
+var ErrRetryBudgetExceeded = errors.New("workflow: retry budget exceeded")
+
 // RetryPolicy defines retry behavior.
 type RetryPolicy struct {
-	MaxAttempts     int
-	InitialDelay    time.Duration
-	MaxDelay        time.Duration
-	Multiplier      float64
-	RetryOn         func(error) bool
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	RetryOn      func(error) bool
+	Jitter       float64       // randomizes each delay by up to this fraction either way
+	FullJitter   bool          // picks each delay uniformly between zero and the backoff
+	Budget       time.Duration // max cumulative delay; zero means unlimited
+	Notify       func(attempt int, err error, nextDelay time.Duration)
 }
 
 // NewRetryPolicy creates a new retry policy.
@@ -802,9 +1594,51 @@ func (p *RetryPolicy) OnError(filter func(error) bool) *RetryPolicy {
 	return p
 }
 
+// WithJitter randomizes each delay by up to fraction of it, so workers
+// retrying the same dependency spread out. 0.2 waits 80-120% of the backoff.
+func (p *RetryPolicy) WithJitter(fraction float64) *RetryPolicy {
+	p.Jitter = fraction
+	return p
+}
+
+// WithFullJitter waits a random delay between zero and the backoff.
+func (p *RetryPolicy) WithFullJitter() *RetryPolicy {
+	p.FullJitter = true
+	return p
+}
+
+// WithBudget caps the total time spent waiting between attempts. A retry
+// whose delay would exceed the budget is not made.
+func (p *RetryPolicy) WithBudget(d time.Duration) *RetryPolicy {
+	p.Budget = d
+	return p
+}
+
+// OnRetry sets a callback run before each backoff with the failed attempt
+// (from 1), its error and the delay before the next attempt.
+func (p *RetryPolicy) OnRetry(fn func(attempt int, err error, nextDelay time.Duration)) *RetryPolicy {
+	p.Notify = fn
+	return p
+}
+
+// jitter applies the policy's jitter to a backoff delay.
+func (p *RetryPolicy) jitter(delay time.Duration) time.Duration {
+	switch {
+	case delay <= 0:
+		return delay
+	case p.FullJitter:
+		return time.Duration(rand.Int64N(int64(delay) + 1))
+	case p.Jitter > 0:
+		f := min(p.Jitter, 1)
+		return time.Duration(float64(delay) * (1 - f + 2*f*rand.Float64()))
+	}
+	return delay
+}
+
 // Execute runs with retries.
 func (p *RetryPolicy) Execute(ctx context.Context, fn func() (any, error)) (any, error) {
 	var lastErr error
+	var waited time.Duration
 	delay := p.InitialDelay
 
 	for attempt := 0; attempt < p.MaxAttempts; attempt++ {
@@ -818,12 +1652,24 @@ func (p *RetryPolicy) Execute(ctx context.Context, fn func() (any, error)) (any,
 		if p.RetryOn != nil && !p.RetryOn(err) {
 			return nil, err
 		}
+		if class := Classify(err); p.RetryOn == nil && (class == ErrorFatal || class == ErrorSkippable) {
+			return nil, err
+		}
 
 		if attempt < p.MaxAttempts-1 {
+			wait := p.jitter(delay)
+			if p.Budget > 0 && waited+wait > p.Budget {
+				return nil, fmt.Errorf("%w: %w", ErrRetryBudgetExceeded, lastErr)
+			}
+			waited += wait
+			if p.Notify != nil {
+				p.Notify(attempt+1, err, wait)
+			}
+
 			select {
 			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(delay):
+				return nil, fmt.Errorf("%w (last error: %w)", ctx.Err(), lastErr)
+			case <-time.After(wait):
 			}
 
 			delay = time.Duration(float64(delay) * p.Multiplier)
@@ -835,49 +1681,3 @@ func (p *RetryPolicy) Execute(ctx context.Context, fn func() (any, error)) (any,
 
 	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
 }
-
-// ============ Persistence ============
-
-// Persistence handles durable workflow storage.
-type Persistence struct {
-	client *redis.Client
-	prefix string
-}
-
-// NewPersistence creates persistence with Redis.
-func NewPersistence(client *redis.Client) *Persistence {
-	return &Persistence{
-		client: client,
-		prefix: "goflow:workflow",
-	}
-}
-
-// Save saves workflow state.
-func (p *Persistence) Save(ctx context.Context, state *State) error {
-	key := fmt.Sprintf("%s:%s", p.prefix, state.ID)
-	data, err := json.Marshal(state)
-	if err != nil {
-		return err
-	}
-	return p.client.Set(ctx, key, data, 7*24*time.Hour).Err()
-}
-
-// Load loads workflow state.
-func (p *Persistence) Load(ctx context.Context, id string) (*State, error) {
-	key := fmt.Sprintf("%s:%s", p.prefix, id)
-	data, err := p.client.Get(ctx, key).Bytes()
-	if err != nil {
-		return nil, err
-	}
-	var state State
-	if err := json.Unmarshal(data, &state); err != nil {
-		return nil, err
-	}
-	return &state, nil
-}
-
-// Delete removes workflow state.
-func (p *Persistence) Delete(ctx context.Context, id string) error {
-	key := fmt.Sprintf("%s:%s", p.prefix, id)
-	return p.client.Del(ctx, key).Err()
-}
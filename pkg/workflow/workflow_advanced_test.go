@@ -4,6 +4,7 @@ package workflow_test
 import (
 	"context"
 	"errors"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -109,6 +110,179 @@ func TestParallelStep_PartialFailure(t *testing.T) {
 	}
 }
 
+func TestParallelStep_ConcurrentWrites(t *testing.T) {
+	// Each branch is a loop, so the loops' own bookkeeping writes race
+	// with the branches' writes unless both lock the state.
+	var branches []workflow.Step
+	for _, key := range []string{"a", "b", "c"} {
+		body := &writeStep{name: "write-" + key, key: key}
+		loop := workflow.New("branch").Loop("loop-" + key).MaxIterations(50).Do(body).End().Build()
+		branches = append(branches, loop.Steps[0])
+	}
+
+	wf := workflow.New("parallel-writes").
+		Parallel("parallel", branches...).
+		Then().
+		Build()
+
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if v, _ := state.Get(key); v != 50 {
+			t.Errorf("Expected %s = 50, got %v", key, v)
+		}
+	}
+}
+
+// action builds a standalone action step for use as a parallel branch.
+func action(name string, handler workflow.ActionHandler) workflow.Step {
+	return workflow.New(name).Step(name, handler).Then().Build().Steps[0]
+}
+
+func setter(name, key string, value any) workflow.Step {
+	return action(name, func(ctx context.Context, state *workflow.State) (any, error) {
+		state.Set(key, value)
+		return name + "-done", nil
+	})
+}
+
+func TestParallelStep_BranchStates(t *testing.T) {
+	var sawA bool
+	wf := workflow.New("parallel-branches").
+		Parallel("fetch",
+			setter("users", "a", 1),
+			action("orders", func(ctx context.Context, state *workflow.State) (any, error) {
+				time.Sleep(10 * time.Millisecond)
+				_, sawA = state.Get("a")
+				state.Set("b", 2)
+				return "orders-done", nil
+			}),
+		).Then().
+		Build()
+
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, map[string]any{"input": true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if sawA {
+		t.Error("Branch saw data written by its sibling")
+	}
+	if state.Data["a"] != 1 || state.Data["b"] != 2 || state.Data["input"] != true {
+		t.Errorf("Unexpected merged data: %v", state.Data)
+	}
+	if state.StepResults["fetch.users"] != "users-done" || state.StepResults["fetch.orders"] != "orders-done" {
+		t.Errorf("Expected namespaced results, got %v", state.StepResults)
+	}
+	if _, ok := state.StepResults["users"]; ok {
+		t.Error("Branch result leaked under its bare name")
+	}
+}
+
+func TestParallelStep_MergeStrategies(t *testing.T) {
+	run := func(strategy workflow.MergeStrategy) (*workflow.State, error) {
+		wf := workflow.New("parallel-merge").
+			Parallel("p", setter("first", "x", "one"), setter("second", "x", "two"), setter("third", "y", 3)).
+			MergeStrategy(strategy).
+			Then().
+			Build()
+		return workflow.NewEngine(nil).Execute(context.Background(), wf, map[string]any{"x": "base"})
+	}
+
+	state, err := run(workflow.MergeLastWins)
+	if err != nil || state.Data["x"] != "two" || state.Data["y"] != 3 {
+		t.Errorf("Last-wins: expected x = two, got %v (%v)", state.Data, err)
+	}
+
+	state, err = run(workflow.MergeCollect)
+	if got, ok := state.Data["x"].([]any); err != nil || !ok || len(got) != 2 || got[0] != "one" || got[1] != "two" {
+		t.Errorf("Collect: expected [one two], got %v (%v)", state.Data["x"], err)
+	}
+	if state.Data["y"] != 3 {
+		t.Errorf("Collect: expected the unconflicted key as is, got %v", state.Data["y"])
+	}
+
+	_, err = run(workflow.MergeError)
+	if err == nil || !strings.Contains(err.Error(), "'first' and 'second' both set \"x\"") {
+		t.Errorf("Error: expected a conflict error, got %v", err)
+	}
+}
+
+func TestParallelStep_WaitAnyCancelsLosers(t *testing.T) {
+	cancelled := make(chan error, 1)
+	wf := workflow.New("parallel-any").
+		Parallel("race",
+			setter("fast", "winner", "fast"),
+			action("slow", func(ctx context.Context, state *workflow.State) (any, error) {
+				<-ctx.Done()
+				cancelled <- ctx.Err()
+				state.Set("winner", "slow")
+				return nil, ctx.Err()
+			}),
+		).WaitFor(workflow.WaitAny).
+		Then().
+		Build()
+
+	state, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the losing branch to be cancelled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Losing branch was not cancelled")
+	}
+	if v, _ := state.Get("winner"); v != "fast" {
+		t.Errorf("Expected only the winner merged, got %v", v)
+	}
+}
+
+func TestParallelStep_KeepsCompensations(t *testing.T) {
+	var compensated atomic.Int32
+	charge := workflow.New("charge").
+		Step("charge", func(ctx context.Context, state *workflow.State) (any, error) { return "ok", nil }).
+		Compensate(func(ctx context.Context, state *workflow.State) error {
+			compensated.Add(1)
+			return nil
+		}).
+		Then().Build().Steps[0]
+	fail := action("fail", func(ctx context.Context, state *workflow.State) (any, error) {
+		time.Sleep(10 * time.Millisecond)
+		return nil, errors.New("boom")
+	})
+
+	wf := workflow.New("parallel-saga").Parallel("p", charge, fail).Then().Build()
+	if _, err := workflow.NewEngine(nil).Execute(context.Background(), wf, nil); err == nil {
+		t.Fatal("Expected the parallel step to fail")
+	}
+	if compensated.Load() != 1 {
+		t.Errorf("Expected the successful branch to be compensated, got %d", compensated.Load())
+	}
+}
+
+func TestState_Accessors(t *testing.T) {
+	state := &workflow.State{}
+	state.Set("name", "order-1")
+	state.Set("count", 3)
+
+	if v, ok := state.Get("count"); !ok || v != 3 {
+		t.Errorf("Expected count 3, got %v", v)
+	}
+	if _, ok := state.Get("missing"); ok {
+		t.Error("Expected missing key to be absent")
+	}
+	if state.GetString("name") != "order-1" || state.GetString("count") != "" {
+		t.Errorf("Unexpected GetString results: %q %q", state.GetString("name"), state.GetString("count"))
+	}
+	if keys := state.Keys(); len(keys) != 2 || keys[0] != "count" || keys[1] != "name" {
+		t.Errorf("Expected sorted keys, got %v", keys)
+	}
+}
+
 // ============ Sleep Step Tests ============
 
 func TestSleepStep_Execute(t *testing.T) {
@@ -372,6 +546,22 @@ func (s *errorStep) Execute(ctx context.Context, state *workflow.State) error {
 func (s *errorStep) Name() string          { return s.name }
 func (s *errorStep) Type() workflow.StepType { return workflow.StepTypeAction }
 
+type writeStep struct {
+	name string
+	key  string
+}
+
+func (s *writeStep) Execute(ctx context.Context, state *workflow.State) error {
+	v, _ := state.Get(s.key)
+	n, _ := v.(int)
+	state.Set(s.key, n+1)
+	state.Keys()
+	return nil
+}
+
+func (s *writeStep) Name() string          { return s.name }
+func (s *writeStep) Type() workflow.StepType { return workflow.StepTypeAction }
+
 type callbackStep struct {
 	name     string
 	callback func()
@@ -0,0 +1,129 @@
+package workflowtest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// CronStoreFactory returns an empty cron store for one subtest. It should
+// register any cleanup with t.
+type CronStoreFactory func(t *testing.T) workflow.CronStore
+
+// RunCronStore runs the conformance suite against cron stores created by
+// newStore.
+func RunCronStore(t *testing.T, newStore CronStoreFactory) {
+	t.Run("Schedules", func(t *testing.T) { testSchedules(t, newStore(t)) })
+	t.Run("Runs", func(t *testing.T) { testRuns(t, newStore(t)) })
+	t.Run("Lock", func(t *testing.T) { testLock(t, newStore(t)) })
+}
+
+func testSchedules(t *testing.T, store workflow.CronStore) {
+	ctx := context.Background()
+	nightly := &workflow.ScheduleRecord{
+		ID:         "nightly",
+		Workflow:   "report",
+		Expression: "0 2 * * *",
+		Location:   "UTC",
+		Input:      map[string]any{"region": "eu"},
+		Enabled:    true,
+		Overlap:    workflow.QueueBehind,
+		CatchUp:    2,
+		LastRun:    base,
+		NextRun:    base.Add(24 * time.Hour),
+		LastError:  "boom",
+		Skipped:    1,
+	}
+	hourly := &workflow.ScheduleRecord{ID: "hourly", JobType: "sync", Expression: "@hourly"}
+	for _, r := range []*workflow.ScheduleRecord{nightly, hourly} {
+		if err := store.SaveSchedule(ctx, r); err != nil {
+			t.Fatalf("SaveSchedule(%s): %v", r.ID, err)
+		}
+	}
+
+	records, err := store.ListSchedules(ctx)
+	if err != nil {
+		t.Fatalf("ListSchedules: %v", err)
+	}
+	if len(records) != 2 || records[0].ID != "hourly" || records[1].ID != "nightly" {
+		t.Fatalf("Expected hourly and nightly sorted by ID, got %+v", records)
+	}
+	if got, want := canonical(t, records[1]), canonical(t, nightly); !reflect.DeepEqual(got, want) {
+		t.Errorf("Round trip mismatch:\n got %v\nwant %v", got, want)
+	}
+
+	nightly.Enabled = false
+	store.SaveSchedule(ctx, nightly)
+	store.AppendRun(ctx, workflow.CronRun{ScheduleID: "hourly", At: base})
+	if err := store.DeleteSchedule(ctx, "hourly"); err != nil {
+		t.Fatalf("DeleteSchedule: %v", err)
+	}
+	if err := store.DeleteSchedule(ctx, "missing"); err != nil {
+		t.Errorf("Deleting a missing schedule failed: %v", err)
+	}
+	records, _ = store.ListSchedules(ctx)
+	if len(records) != 1 || records[0].Enabled {
+		t.Errorf("Expected only the disabled nightly schedule, got %+v", records)
+	}
+	if runs, _ := store.Runs(ctx, "hourly", 0); len(runs) != 0 {
+		t.Errorf("Expected DeleteSchedule to drop the run log, got %+v", runs)
+	}
+}
+
+func testRuns(t *testing.T, store workflow.CronStore) {
+	ctx := context.Background()
+	for i := range 105 {
+		run := workflow.CronRun{ScheduleID: "nightly", At: base.Add(time.Duration(i) * time.Minute), RunID: fmt.Sprintf("run-%d", i)}
+		if i == 104 {
+			run.Error = "boom"
+		}
+		if err := store.AppendRun(ctx, run); err != nil {
+			t.Fatalf("AppendRun: %v", err)
+		}
+	}
+	store.AppendRun(ctx, workflow.CronRun{ScheduleID: "other", At: base})
+
+	runs, err := store.Runs(ctx, "nightly", 2)
+	if err != nil {
+		t.Fatalf("Runs: %v", err)
+	}
+	if len(runs) != 2 || runs[0].RunID != "run-104" || runs[0].Error != "boom" || runs[1].RunID != "run-103" {
+		t.Errorf("Expected the newest runs first, got %+v", runs)
+	}
+	if !runs[0].At.Equal(base.Add(104 * time.Minute)) {
+		t.Errorf("Expected At to round trip, got %v", runs[0].At)
+	}
+	all, _ := store.Runs(ctx, "nightly", 0)
+	if len(all) != 100 || all[99].RunID != "run-5" {
+		t.Errorf("Expected the log capped at the newest 100 runs, got %d", len(all))
+	}
+}
+
+func testLock(t *testing.T, store workflow.CronStore) {
+	ctx := context.Background()
+	ok, err := store.Lock(ctx, "nightly", base, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("Expected the first lock to succeed, got %v, %v", ok, err)
+	}
+	if ok, _ := store.Lock(ctx, "nightly", base, time.Minute); ok {
+		t.Error("Expected a second claim of the same fire to fail")
+	}
+	if ok, _ := store.Lock(ctx, "nightly", base.Add(time.Minute), time.Minute); !ok {
+		t.Error("Expected the next fire to be claimable")
+	}
+	if ok, _ := store.Lock(ctx, "hourly", base, time.Minute); !ok {
+		t.Error("Expected another schedule's fire to be claimable")
+	}
+
+	if ok, _ := store.Lock(ctx, "brief", base, 50*time.Millisecond); !ok {
+		t.Fatal("Expected the brief lock to succeed")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if ok, _ := store.Lock(ctx, "brief", base, time.Minute); !ok {
+		t.Error("Expected an expired lock to be claimable")
+	}
+}
@@ -0,0 +1,315 @@
+// Package workflowtest provides conformance suites for workflow.Persistence
+// and workflow.CronStore implementations.
+package workflowtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nuulab/goflow/pkg/queue"
+	"github.com/nuulab/goflow/pkg/workflow"
+)
+
+// Factory returns empty persistence for one subtest. It should register any
+// cleanup with t.
+type Factory func(t *testing.T) workflow.Persistence
+
+// RunPersistence runs the conformance suite against persistence created by
+// newPersistence.
+func RunPersistence(t *testing.T, newPersistence Factory) {
+	t.Run("RoundTrip", func(t *testing.T) { testRoundTrip(t, newPersistence(t)) })
+	t.Run("Overwrite", func(t *testing.T) { testOverwrite(t, newPersistence(t)) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, newPersistence(t)) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, newPersistence(t)) })
+	t.Run("ListByStatus", func(t *testing.T) { testListByStatus(t, newPersistence(t)) })
+	t.Run("List", func(t *testing.T) { testList(t, newPersistence(t)) })
+	t.Run("PendingOutbox", func(t *testing.T) { testPendingOutbox(t, newPersistence(t)) })
+	t.Run("Definitions", func(t *testing.T) { testDefinitions(t, newPersistence(t)) })
+}
+
+var base = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newState(id string, status workflow.Status, started time.Time) *workflow.State {
+	return &workflow.State{
+		ID:          id,
+		Workflow:    "orders",
+		WorkflowID:  "orders-1",
+		CurrentStep: 2,
+		Status:      status,
+		Data:        map[string]any{"order": map[string]any{"id": "A-1", "total": 42.5}, "tags": []any{"rush"}},
+		StepResults: map[string]any{"validate": true},
+		Checkpoints: map[string]int{"validated": 1},
+		Errors:      []string{},
+		StartedAt:   started,
+		History: []workflow.HistoryEntry{
+			{Type: workflow.HistoryStep, Step: "validate", Seq: 1, Timestamp: started},
+		},
+	}
+}
+
+// canonical strips representation differences such as time zones and
+// nil versus empty collections by comparing the JSON form.
+func canonical(t *testing.T, v any) any {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func testRoundTrip(t *testing.T, p workflow.Persistence) {
+	ctx := context.Background()
+	state := newState("run-1", workflow.StatusRunning, base)
+	if err := p.Save(ctx, state); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err := p.Load(ctx, "run-1")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !got.StartedAt.Equal(base) {
+		t.Errorf("StartedAt = %v, want %v", got.StartedAt, base)
+	}
+	if !reflect.DeepEqual(canonical(t, got), canonical(t, state)) {
+		t.Errorf("loaded state differs:\n got  %v\n want %v", canonical(t, got), canonical(t, state))
+	}
+}
+
+func testOverwrite(t *testing.T, p workflow.Persistence) {
+	ctx := context.Background()
+	state := newState("run-1", workflow.StatusRunning, base)
+	if err := p.Save(ctx, state); err != nil {
+		t.Fatal(err)
+	}
+	state.Status = workflow.StatusCompleted
+	state.StepResults["ship"] = "done"
+	if err := p.Save(ctx, state); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := p.Load(ctx, "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != workflow.StatusCompleted || got.StepResults["ship"] != "done" {
+		t.Errorf("overwrite not applied: status=%s results=%v", got.Status, got.StepResults)
+	}
+}
+
+func testNotFound(t *testing.T, p workflow.Persistence) {
+	ctx := context.Background()
+	if _, err := p.Load(ctx, "missing"); !errors.Is(err, workflow.ErrStateNotFound) {
+		t.Errorf("Load(missing) error = %v, want ErrStateNotFound", err)
+	}
+	if _, err := p.LoadDefinition(ctx, "missing"); !errors.Is(err, workflow.ErrDefinitionNotFound) {
+		t.Errorf("LoadDefinition(missing) error = %v, want ErrDefinitionNotFound", err)
+	}
+}
+
+func testDelete(t *testing.T, p workflow.Persistence) {
+	ctx := context.Background()
+	if err := p.Save(ctx, newState("run-1", workflow.StatusRunning, base)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Delete(ctx, "run-1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := p.Load(ctx, "run-1"); !errors.Is(err, workflow.ErrStateNotFound) {
+		t.Errorf("Load after Delete error = %v, want ErrStateNotFound", err)
+	}
+	if err := p.Delete(ctx, "run-1"); err != nil {
+		t.Errorf("Delete of missing state: %v", err)
+	}
+}
+
+func testListByStatus(t *testing.T, p workflow.Persistence) {
+	ctx := context.Background()
+	statuses := []workflow.Status{
+		workflow.StatusFailed, workflow.StatusCompleted, workflow.StatusFailed,
+		workflow.StatusRunning, workflow.StatusFailed,
+	}
+	// Save out of start order to check sorting.
+	for _, i := range []int{3, 0, 4, 1, 2} {
+		id := fmt.Sprintf("run-%d", i)
+		if err := p.Save(ctx, newState(id, statuses[i], base.Add(time.Duration(i)*time.Minute))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failed, err := p.ListByStatus(ctx, workflow.StatusFailed)
+	if err != nil {
+		t.Fatalf("ListByStatus: %v", err)
+	}
+	var ids []string
+	for _, s := range failed {
+		ids = append(ids, s.ID)
+	}
+	if want := []string{"run-0", "run-2", "run-4"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("failed runs = %v, want %v", ids, want)
+	}
+
+	paused, err := p.ListByStatus(ctx, workflow.StatusPaused)
+	if err != nil {
+		t.Fatalf("ListByStatus: %v", err)
+	}
+	if len(paused) != 0 {
+		t.Errorf("expected no paused runs, got %d", len(paused))
+	}
+}
+
+func testList(t *testing.T, p workflow.Persistence) {
+	lister, ok := p.(workflow.RunLister)
+	if !ok {
+		t.Skip("persistence does not implement workflow.RunLister")
+	}
+	ctx := context.Background()
+	for i, status := range []workflow.Status{
+		workflow.StatusCompleted, workflow.StatusFailed, workflow.StatusCompleted,
+		workflow.StatusCompleted, workflow.StatusRunning,
+	} {
+		state := newState(fmt.Sprintf("run-%d", i), status, base.Add(time.Duration(i)*time.Minute))
+		if i == 3 {
+			state.Workflow = "refunds"
+		}
+		if err := p.Save(ctx, state); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A status change moves the run between listings.
+	moved := newState("run-4", workflow.StatusCompleted, base.Add(4*time.Minute))
+	if err := p.Save(ctx, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Delete(ctx, "run-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter workflow.ListFilter
+		want   []string
+	}{
+		{"all", workflow.ListFilter{}, []string{"run-0", "run-1", "run-3", "run-4"}},
+		{"workflow", workflow.ListFilter{Workflow: "orders"}, []string{"run-0", "run-1", "run-4"}},
+		{"status", workflow.ListFilter{Status: workflow.StatusCompleted}, []string{"run-0", "run-3", "run-4"}},
+		{"both", workflow.ListFilter{Workflow: "orders", Status: workflow.StatusCompleted}, []string{"run-0", "run-4"}},
+		{"running", workflow.ListFilter{Status: workflow.StatusRunning}, nil},
+		{"time range", workflow.ListFilter{Since: base.Add(time.Minute), Until: base.Add(4 * time.Minute)}, []string{"run-1", "run-3"}},
+		{"page", workflow.ListFilter{Offset: 1, Limit: 2}, []string{"run-1", "run-3"}},
+		{"past the end", workflow.ListFilter{Offset: 10}, nil},
+	}
+	for _, tt := range tests {
+		states, err := lister.List(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: List: %v", tt.name, err)
+		}
+		var ids []string
+		for _, s := range states {
+			ids = append(ids, s.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("%s: ids = %v, want %v", tt.name, ids, tt.want)
+		}
+	}
+}
+
+func testPendingOutbox(t *testing.T, p workflow.Persistence) {
+	index, ok := p.(workflow.OutboxIndex)
+	if !ok {
+		t.Skip("persistence does not implement workflow.OutboxIndex")
+	}
+	ctx := context.Background()
+	pending := func() []string {
+		t.Helper()
+		states, err := index.PendingOutbox(ctx)
+		if err != nil {
+			t.Fatalf("PendingOutbox: %v", err)
+		}
+		var ids []string
+		for _, s := range states {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	unsent := newState("unsent", workflow.StatusRunning, base)
+	unsent.Outbox = []queue.Intent{{ID: "i1", StagedAt: base}}
+	sent := newState("sent", workflow.StatusCompleted, base.Add(time.Minute))
+	sent.Outbox = []queue.Intent{{ID: "i2", StagedAt: base, SentAt: base}}
+	for _, state := range []*workflow.State{unsent, sent, newState("none", workflow.StatusRunning, base)} {
+		if err := p.Save(ctx, state); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids := pending(); !reflect.DeepEqual(ids, []string{"unsent"}) {
+		t.Errorf("ids = %v, want [unsent]", ids)
+	}
+
+	// Sending the intent or deleting the state drops it from the index.
+	unsent.Outbox[0].SentAt = base
+	if err := p.Save(ctx, unsent); err != nil {
+		t.Fatal(err)
+	}
+	if ids := pending(); len(ids) != 0 {
+		t.Errorf("Expected no pending states after sending, got %v", ids)
+	}
+	sent.Outbox[0].SentAt = time.Time{}
+	p.Save(ctx, sent)
+	if err := p.Delete(ctx, "sent"); err != nil {
+		t.Fatal(err)
+	}
+	if ids := pending(); len(ids) != 0 {
+		t.Errorf("Expected no pending states after delete, got %v", ids)
+	}
+}
+
+func testDefinitions(t *testing.T, p workflow.Persistence) {
+	ctx := context.Background()
+	for _, name := range []string{"triage", "deploy"} {
+		def := &workflow.Definition{
+			Name:      name,
+			Version:   "1",
+			Source:    "blueprint:" + name,
+			Spec:      json.RawMessage(`{"steps":[{"name":"a"}]}`),
+			UpdatedAt: base,
+		}
+		if err := p.SaveDefinition(ctx, def); err != nil {
+			t.Fatalf("SaveDefinition: %v", err)
+		}
+	}
+	if err := p.SaveDefinition(ctx, &workflow.Definition{
+		Name: "deploy", Version: "2", Spec: json.RawMessage(`{"steps":[]}`), UpdatedAt: base,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := p.LoadDefinition(ctx, "deploy")
+	if err != nil {
+		t.Fatalf("LoadDefinition: %v", err)
+	}
+	if got.Version != "2" || !reflect.DeepEqual(canonical(t, got.Spec), canonical(t, json.RawMessage(`{"steps":[]}`))) {
+		t.Errorf("definition not replaced: %+v", got)
+	}
+
+	defs, err := p.ListDefinitions(ctx)
+	if err != nil {
+		t.Fatalf("ListDefinitions: %v", err)
+	}
+	if len(defs) != 2 || defs[0].Name != "deploy" || defs[1].Name != "triage" {
+		t.Fatalf("ListDefinitions = %+v, want deploy, triage", defs)
+	}
+	if defs[1].Source != "blueprint:triage" {
+		t.Errorf("Source = %q", defs[1].Source)
+	}
+}